@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputPath string
+	inputPath  string
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Copy a bolt or SQLite metadata database",
+	Long: `Write a consistent copy of a bolt or SQLite metadata database.
+A running server offers the same through GET /admin/metadata/backup.
+
+Examples:
+  astore-admin backup --dsn /var/lib/astore/metadata.db --out metadata.bak`,
+	Args: cobra.NoArgs,
+	RunE: runBackup,
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Replace a metadata database with a backup",
+	Long: `Replace a bolt or SQLite metadata database with a backup written by
+astore-admin backup or the server. Stop the server first.
+
+Examples:
+  astore-admin restore --dsn /var/lib/astore/metadata.db --in metadata.bak`,
+	Args: cobra.NoArgs,
+	RunE: runRestore,
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all metadata records as JSON lines",
+	Long: `Export all metadata records as JSON lines, readable by every driver.
+
+Examples:
+  # Move metadata from bolt to PostgreSQL
+  astore-admin export --dsn /var/lib/astore/metadata.db --out metadata.jsonl
+  astore-admin import --driver postgres --dsn "postgres://astore@db/astore" --in metadata.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import metadata records exported as JSON lines",
+	Long: `Import metadata records written by astore-admin export or
+GET /admin/metadata/export. Records with the same identity are replaced;
+replication tasks are appended to the queue.`,
+	Args: cobra.NoArgs,
+	RunE: runImport,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+
+	for _, cmd := range []*cobra.Command{backupCmd, exportCmd} {
+		cmd.Flags().StringVar(&outputPath, "out", "", "output file (default is stdout)")
+	}
+	for _, cmd := range []*cobra.Command{restoreCmd, importCmd} {
+		cmd.Flags().StringVar(&inputPath, "in", "", "input file (default is stdin)")
+	}
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	return withStore(func(store storage.MetadataStore) error {
+		return writeOutput(cmd, func(w io.Writer) error {
+			_, err := store.Backup(w)
+			return err
+		})
+	})
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	config, err := metadataConfig()
+	if err != nil {
+		return err
+	}
+
+	return readInput(cmd, func(r io.Reader) error {
+		if err := storage.RestoreMetadataBackup(config, r); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Restored %s\n", config.DSN)
+		return nil
+	})
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	return withStore(func(store storage.MetadataStore) error {
+		return writeOutput(cmd, func(w io.Writer) error {
+			count, err := storage.ExportMetadata(store, w)
+			if err == nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d records\n", count)
+			}
+			return err
+		})
+	})
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	return withStore(func(store storage.MetadataStore) error {
+		return readInput(cmd, func(r io.Reader) error {
+			count, err := storage.ImportMetadata(store, r)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Imported %d records\n", count)
+			return err
+		})
+	})
+}
+
+// withStore opens the metadata store without migrating it
+func withStore(fn func(store storage.MetadataStore) error) error {
+	config, err := metadataConfig()
+	if err != nil {
+		return err
+	}
+	config.Migrations.DryRun = true
+
+	store, err := storage.OpenMetadataStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return fn(store)
+}
+
+// writeOutput calls fn with the --out file or stdout
+func writeOutput(cmd *cobra.Command, fn func(w io.Writer) error) error {
+	if outputPath == "" {
+		return fn(cmd.OutOrStdout())
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return f.Close()
+}
+
+// readInput calls fn with the --in file or stdin
+func readInput(cmd *cobra.Command, fn func(r io.Reader) error) error {
+	if inputPath == "" {
+		return fn(cmd.InOrStdin())
+	}
+
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+	return fn(f)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+func TestBackupCommands(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "metadata.db")
+	store, err := storage.NewBoltMetadataStore(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StorePolicy(&models.Policy{ID: "policy-1"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Export from bolt and import into SQLite
+	export := filepath.Join(dir, "metadata.jsonl")
+	sqlite := filepath.Join(dir, "metadata.sqlite")
+	runAdmin(t, "export", "--dsn", source, "--out", export)
+	runAdmin(t, "migrate", "--driver", "sqlite", "--dsn", sqlite)
+	runAdmin(t, "import", "--driver", "sqlite", "--dsn", sqlite, "--in", export)
+	assertPolicy(t, &storage.MetadataConfig{Driver: storage.MetadataDriverSQLite, DSN: sqlite})
+
+	// Back up bolt and restore into a new file
+	backup := filepath.Join(dir, "metadata.bak")
+	restored := filepath.Join(dir, "restored.db")
+	runAdmin(t, "backup", "--dsn", source, "--out", backup)
+	runAdmin(t, "restore", "--dsn", restored, "--in", backup)
+	assertPolicy(t, &storage.MetadataConfig{DSN: restored})
+}
+
+func assertPolicy(t *testing.T, config *storage.MetadataConfig) {
+	t.Helper()
+	store, err := storage.OpenMetadataStore(config)
+	if err != nil {
+		t.Fatalf("open %s: %v", config.DSN, err)
+	}
+	defer store.Close()
+	if _, err := store.GetPolicy("policy-1"); err != nil {
+		t.Errorf("policy missing from %s: %v", config.DSN, err)
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// compactCmd represents the compact command
+var compactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Release space freed by deleted metadata records",
+	Long: `Rewrite a metadata database to release space freed by deleted records,
+such as audit log entries removed by retention. Stop the server before
+compacting a bolt database.
+
+Examples:
+  astore-admin compact --dsn /var/lib/astore/metadata.db`,
+	Args: cobra.NoArgs,
+	RunE: runCompact,
+}
+
+func init() {
+	rootCmd.AddCommand(compactCmd)
+}
+
+func runCompact(cmd *cobra.Command, args []string) error {
+	config, err := metadataConfig()
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.CompactMetadataStore(config)
+	if err != nil {
+		return fmt.Errorf("compaction failed: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if result.Before > 0 {
+		fmt.Fprintf(out, "Compacted: %d -> %d bytes\n", result.Before, result.After)
+	} else {
+		fmt.Fprintln(out, "Compacted")
+	}
+	return nil
+}
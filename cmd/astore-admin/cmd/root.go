@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Global flags
+	metadataDriver string
+	metadataDSN    string
+)
+
+// rootCmd represents the base command
+var rootCmd = &cobra.Command{
+	Use:   "astore-admin",
+	Short: "Zot Artifact Store administration",
+	Long: `astore-admin operates directly on a Zot Artifact Store metadata database.
+
+Stop the server before running commands against a bolt or SQLite database;
+bolt databases cannot be opened while the server holds them.`,
+	Version:      "1.0.0",
+	SilenceUsage: true,
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+func Execute() error {
+	return rootCmd.Execute()
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&metadataDriver, "driver", storage.MetadataDriverBolt, "metadata store driver: bolt, sqlite or postgres")
+	rootCmd.PersistentFlags().StringVar(&metadataDSN, "dsn", "", "metadata database file path or connection string")
+}
+
+// metadataConfig returns the metadata store selected by the global flags
+func metadataConfig() (*storage.MetadataConfig, error) {
+	if metadataDSN == "" {
+		return nil, fmt.Errorf("metadata database is required (use --dsn flag)")
+	}
+	return &storage.MetadataConfig{Driver: metadataDriver, DSN: metadataDSN}, nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dryRun    bool
+	backupDir string
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Show the schema version of a metadata database",
+	Long: `Show the schema version of a metadata database and the migrations
+this build would apply to it. The database is not changed.
+
+Examples:
+  # Inspect a bolt database
+  astore-admin schema --dsn /var/lib/astore/metadata.db`,
+	Args: cobra.NoArgs,
+	RunE: runSchema,
+}
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Long: `Apply pending schema migrations to a metadata database.
+
+Examples:
+  # Show what would be applied
+  astore-admin migrate --dsn /var/lib/astore/metadata.db --dry-run
+
+  # Back up the database, then migrate it
+  astore-admin migrate --dsn /var/lib/astore/metadata.db --backup-dir /var/backups/astore`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list pending migrations without applying them")
+	migrateCmd.Flags().StringVar(&backupDir, "backup-dir", "", "copy the database to this directory before migrating (bolt and sqlite)")
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	config, err := metadataConfig()
+	if err != nil {
+		return err
+	}
+
+	status, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{DryRun: true})
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	printSchemaStatus(cmd.OutOrStdout(), status)
+	return nil
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	config, err := metadataConfig()
+	if err != nil {
+		return err
+	}
+
+	status, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{DryRun: dryRun, BackupDir: backupDir})
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if status.Backup != "" {
+		fmt.Fprintf(out, "Backup:  %s\n", status.Backup)
+	}
+	for _, migration := range status.Applied {
+		fmt.Fprintf(out, "Applied: %d %s\n", migration.Version, migration.Description)
+	}
+	printSchemaStatus(out, status)
+	return nil
+}
+
+// printSchemaStatus writes the schema version and pending migrations
+func printSchemaStatus(out io.Writer, status *storage.SchemaStatus) {
+	fmt.Fprintf(out, "Schema version: %d (latest %d)\n", status.Version, status.Latest)
+	if len(status.Pending) == 0 {
+		fmt.Fprintln(out, "Up to date")
+		return
+	}
+
+	fmt.Fprintln(out, "Pending migrations:")
+	for _, migration := range status.Pending {
+		fmt.Fprintf(out, "  %d %s\n", migration.Version, migration.Description)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+// runAdmin runs astore-admin with args and returns its standard output
+func runAdmin(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs(args)
+	metadataDriver, metadataDSN = storage.MetadataDriverBolt, ""
+	dryRun, backupDir, outputPath, inputPath = false, "", "", ""
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("astore-admin %v: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestMigrateCommand(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "metadata.db")
+
+	if out := runAdmin(t, "migrate", "--dry-run", "--dsn", dsn); !strings.Contains(out, "Pending migrations:") {
+		t.Errorf("dry run should list pending migrations, got:\n%s", out)
+	}
+	if out := runAdmin(t, "migrate", "--dsn", dsn); !strings.Contains(out, "Applied: 1 ") || !strings.Contains(out, "Up to date") {
+		t.Errorf("migrate should apply migrations, got:\n%s", out)
+	}
+	if out := runAdmin(t, "schema", "--dsn", dsn); !strings.Contains(out, "Up to date") {
+		t.Errorf("schema should report an up to date database, got:\n%s", out)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"github.com/candlekeep/zot-artifact-store/cmd/astore-admin/cmd"
+)
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var accessSourceIP string
+
+// accessCmd represents the access command
+var accessCmd = &cobra.Command{
+	Use:   "access",
+	Short: "Query who may do what",
+	Long: `Query the server's user directory and what its users may do.
+
+The directory holds users seen authenticating, service accounts and any
+configured directory source such as an LDIF export of an LDAP directory.`,
+}
+
+var accessUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "List directory users",
+	Args:  cobra.NoArgs,
+	RunE:  runAccessUsers,
+}
+
+var accessCheckCmd = &cobra.Command{
+	Use:   "check <user> <resource>",
+	Short: "Show what a user may do on a resource",
+	Long: `Show which actions a user, given by ID or username, may take on a resource.
+
+Examples:
+  # What can alice do on the releases bucket?
+  astore access check alice releases
+
+  # ... on one artifact, from a given address
+  astore access check alice releases/app.jar --source-ip 10.0.0.1`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAccessCheck,
+}
+
+var accessWhoCmd = &cobra.Command{
+	Use:   "who <action> <resource>",
+	Short: "List the users allowed an action on a resource",
+	Long: `List the directory users allowed an action on a resource.
+
+Examples:
+  # Who can write to the releases bucket?
+  astore access who write releases`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAccessWho,
+}
+
+func init() {
+	rootCmd.AddCommand(accessCmd)
+	accessCmd.AddCommand(accessUsersCmd)
+	accessCmd.AddCommand(accessCheckCmd)
+	accessCmd.AddCommand(accessWhoCmd)
+
+	accessCheckCmd.Flags().StringVar(&accessSourceIP, "source-ip", "", "client address to evaluate sourceIp policy conditions with")
+}
+
+func runAccessUsers(cmd *cobra.Command, args []string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	users, err := c.ListUsers(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	if len(users) == 0 {
+		fmt.Println("No users found")
+		return nil
+	}
+
+	fmt.Printf("Found %d user(s):\n", len(users))
+	for _, user := range users {
+		fmt.Printf("  %-24s %-16s roles: %s  groups: %s\n",
+			user.Username, user.Metadata["source"], listOrNone(user.Roles), listOrNone(user.Groups))
+	}
+	return nil
+}
+
+func runAccessCheck(cmd *cobra.Command, args []string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	access, err := c.GetUserAccess(context.Background(), args[0], args[1], accessSourceIP)
+	if err != nil {
+		return fmt.Errorf("failed to check access: %w", err)
+	}
+
+	fmt.Printf("User:     %s (roles: %s, groups: %s)\n",
+		access.User.Username, listOrNone(access.User.Roles), listOrNone(access.User.Groups))
+	fmt.Printf("Resource: %s (policy version %d)\n", access.Resource, access.PolicyVersion)
+	fmt.Printf("Allowed:  %s\n\n", listOrNone(access.Allowed))
+	for _, decision := range access.Decisions {
+		effect := "deny"
+		if decision.Allowed {
+			effect = "allow"
+		}
+		reason := decision.Reason
+		if decision.DecidingPolicy != "" {
+			reason += " (" + decision.DecidingPolicy + ")"
+		}
+		fmt.Printf("  %-6s %-5s  %s\n", decision.Action, effect, reason)
+	}
+	return nil
+}
+
+func runAccessWho(cmd *cobra.Command, args []string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	grantees, err := c.WhoCan(context.Background(), args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to query access: %w", err)
+	}
+
+	if grantees.Anonymous {
+		fmt.Printf("Anyone, including unauthenticated clients, may %s %s\n", grantees.Action, grantees.Resource)
+	}
+	if len(grantees.Users) == 0 {
+		fmt.Printf("None of %d directory user(s) may %s %s\n", grantees.Evaluated, grantees.Action, grantees.Resource)
+		return nil
+	}
+
+	fmt.Printf("%d of %d directory user(s) may %s %s:\n",
+		len(grantees.Users), grantees.Evaluated, grantees.Action, grantees.Resource)
+	for _, grantee := range grantees.Users {
+		policy := grantee.DecidingPolicy
+		if policy == "" {
+			policy = grantee.Reason
+		}
+		fmt.Printf("  %-24s %-16s %s\n", grantee.Username, grantee.Source, policy)
+	}
+	return nil
+}
+
+// listOrNone joins values, or returns "none" if there are none
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyFormat     string
+	policyOutput     string
+	policyDryRun     bool
+	policyIfVersion  uint64
+	policySince      time.Duration
+	policyLimit      int
+	policySkipWhatIf bool
+	policyAllChanges bool
+)
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage access control policies as code",
+	Long: `Manage access control policies as a YAML or JSON policy document.
+
+A policy document is the complete set of policies: applying it creates and
+updates the policies it lists and deletes all others.`,
+}
+
+var policyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all policies",
+	Long: `Export all policies as a policy document.
+
+Examples:
+  # Print policies as YAML
+  astore policy export
+
+  # Save policies as JSON
+  astore policy export -o policies.json`,
+	Args: cobra.NoArgs,
+	RunE: runPolicyExport,
+}
+
+var policyDiffCmd = &cobra.Command{
+	Use:   "diff <file>",
+	Short: "Show what applying a policy document would change",
+	Long: `Show which policies applying a policy document would create, change and
+delete, and replay recent audited requests against it to show which
+authorization decisions would change.
+
+Use - as the file to read the document from standard input.
+
+Examples:
+  # Review a change before applying it
+  astore policy diff policies.yaml
+
+  # Replay the last week of requests
+  astore policy diff policies.yaml --since 168h --limit 10000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPolicyDiff,
+}
+
+var policyApplyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Apply a policy document",
+	Long: `Replace all policies with those of a policy document.
+
+Use - as the file to read the document from standard input.
+
+Examples:
+  # Apply policies from git
+  astore policy apply policies.yaml
+
+  # Apply only if nobody changed policies since version 42
+  astore policy apply policies.yaml --if-version 42`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPolicyApply,
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyExportCmd)
+	policyCmd.AddCommand(policyDiffCmd)
+	policyCmd.AddCommand(policyApplyCmd)
+
+	policyCmd.PersistentFlags().StringVar(&policyFormat, "format", "", "policy document format, yaml or json (default from the file extension, else yaml)")
+
+	policyExportCmd.Flags().StringVarP(&policyOutput, "output", "o", "", "write the document to a file instead of standard output")
+
+	policyDiffCmd.Flags().DurationVar(&policySince, "since", 24*time.Hour, "replay requests audited within this duration")
+	policyDiffCmd.Flags().IntVar(&policyLimit, "limit", 1000, "maximum number of audited requests to replay")
+	policyDiffCmd.Flags().BoolVar(&policySkipWhatIf, "no-what-if", false, "do not replay audited requests")
+	policyDiffCmd.Flags().BoolVar(&policyAllChanges, "all", false, "list every changed decision instead of the first 20")
+
+	policyApplyCmd.Flags().BoolVar(&policyDryRun, "dry-run", false, "show the changes without applying them")
+	policyApplyCmd.Flags().Uint64Var(&policyIfVersion, "if-version", 0, "apply only if policies are still at this version")
+}
+
+func runPolicyExport(cmd *cobra.Command, args []string) error {
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	format := policyDocumentFormat(policyOutput)
+	document, err := c.ExportPolicies(context.Background(), format)
+	if err != nil {
+		return fmt.Errorf("failed to export policies: %w", err)
+	}
+
+	if policyOutput == "" {
+		_, err = os.Stdout.Write(document)
+		return err
+	}
+	if err := os.WriteFile(policyOutput, document, 0644); err != nil {
+		return fmt.Errorf("failed to write policy document: %w", err)
+	}
+	fmt.Printf("Exported policies to %s\n", policyOutput)
+	return nil
+}
+
+func runPolicyDiff(cmd *cobra.Command, args []string) error {
+	document, format, err := readPolicyDocument(args[0])
+	if err != nil {
+		return err
+	}
+
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	result, err := c.ImportPolicies(ctx, document, format, &client.PolicyImportOptions{DryRun: true})
+	if err != nil {
+		return fmt.Errorf("failed to diff policies: %w", err)
+	}
+	printPolicyPlan(&result.Plan, result.Version)
+
+	if policySkipWhatIf {
+		return nil
+	}
+
+	simulation, err := c.SimulatePolicies(ctx, document, format, &client.PolicySimulationOptions{Since: policySince, Limit: policyLimit})
+	if err != nil {
+		return fmt.Errorf("failed to simulate policies: %w", err)
+	}
+	printPolicySimulation(simulation)
+	return nil
+}
+
+func runPolicyApply(cmd *cobra.Command, args []string) error {
+	document, format, err := readPolicyDocument(args[0])
+	if err != nil {
+		return err
+	}
+
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	opts := &client.PolicyImportOptions{DryRun: policyDryRun, IfVersion: policyIfVersion}
+	result, err := c.ImportPolicies(context.Background(), document, format, opts)
+	if err != nil {
+		return fmt.Errorf("failed to apply policies: %w", err)
+	}
+
+	printPolicyPlan(&result.Plan, result.Version)
+	switch {
+	case result.DryRun:
+		fmt.Println("\nDry run: no changes applied")
+	case result.Applied:
+		fmt.Printf("\nPolicies applied (version %d)\n", result.Version)
+	default:
+		fmt.Println("\nPolicies are up to date")
+	}
+	return nil
+}
+
+// readPolicyDocument reads a policy document from a file, or from standard
+// input if path is -
+func readPolicyDocument(path string) ([]byte, string, error) {
+	var document []byte
+	var err error
+	if path == "-" {
+		document, err = io.ReadAll(os.Stdin)
+	} else {
+		document, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read policy document: %w", err)
+	}
+	return document, policyDocumentFormat(path), nil
+}
+
+// policyDocumentFormat returns the --format flag, or the format of a file
+// name's extension
+func policyDocumentFormat(path string) string {
+	if policyFormat != "" {
+		return policyFormat
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return client.PolicyFormatJSON
+	}
+	return client.PolicyFormatYAML
+}
+
+// printPolicyPlan prints the policies a plan creates, updates and deletes
+func printPolicyPlan(plan *client.PolicyPlan, version uint64) {
+	if len(plan.Created) == 0 && len(plan.Updated) == 0 && len(plan.Deleted) == 0 {
+		fmt.Printf("No policy changes (%d unchanged, version %d)\n", plan.Unchanged, version)
+		return
+	}
+
+	fmt.Printf("Policy changes against version %d:\n", version)
+	for _, id := range plan.Created {
+		fmt.Printf("  + %s\n", id)
+	}
+	for _, update := range plan.Updated {
+		fmt.Printf("  ~ %s (%s)\n", update.ID, strings.Join(update.Fields, ", "))
+	}
+	for _, id := range plan.Deleted {
+		fmt.Printf("  - %s\n", id)
+	}
+	fmt.Printf("%d to create, %d to change, %d to delete, %d unchanged\n",
+		len(plan.Created), len(plan.Updated), len(plan.Deleted), plan.Unchanged)
+}
+
+// printPolicySimulation prints the authorization decisions a simulation changed
+func printPolicySimulation(simulation *client.PolicySimulation) {
+	fmt.Printf("\nWhat-if: replayed %d audited request(s)", simulation.Replayed)
+	if simulation.Skipped > 0 {
+		fmt.Printf(", skipped %d without an authorization decision", simulation.Skipped)
+	}
+	fmt.Println()
+
+	if len(simulation.Changes) == 0 {
+		fmt.Println("No authorization decisions would change")
+		return
+	}
+	fmt.Printf("%d decision(s) would change: %d granted, %d revoked\n",
+		len(simulation.Changes), simulation.Granted, simulation.Revoked)
+
+	shown := simulation.Changes
+	if !policyAllChanges && len(shown) > 20 {
+		shown = shown[:20]
+	}
+	for _, change := range shown {
+		policy := change.ProposedPolicy
+		if policy == "" {
+			policy = change.ProposedReason
+		}
+		fmt.Printf("  %s  %-5s -> %-5s  %s %s %s  (%s)\n",
+			change.Timestamp.Format("2006-01-02 15:04:05"),
+			change.Current, change.Proposed,
+			change.Username, change.Action, change.Resource, policy)
+	}
+	if len(shown) < len(simulation.Changes) {
+		fmt.Printf("  ... %d more; use --all to list them\n", len(simulation.Changes)-len(shown))
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchLimit    int
+	searchCursor   string
+	searchLongForm bool
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search artifacts",
+	Long: `Search artifacts across all buckets.
+
+A query is a list of terms that must all match. A term is a field, an
+operator and a value, or a bare word matched against the words of keys,
+metadata and tags. Prefix a term with "-" to negate it.
+
+Fields:
+  key, bucket, type, digest   text; ":" matches a glob (* and ?), "=" and "!=" compare exactly
+  meta.<name>, tag.<name>     user metadata and tags, compared like text
+  size                        bytes, or with a KB/MB/GB/TB unit (1KB = 1024 bytes)
+  created, updated            RFC 3339 time, YYYY-MM-DD date, or an age such as 7d or 12h
+  signed, sbom, attested      supply-chain status, true or false
+
+Operators: : = != > >= < <=
+
+Examples:
+  # Large artifacts in a bucket
+  astore search "bucket=releases size>100MB"
+
+  # Artifacts built from a commit
+  astore search meta.git-sha=abc123
+
+  # Unsigned RPMs uploaded in the last week
+  astore search "key:*.rpm signed=false created>7d"
+
+  # Artifacts mentioning nginx, with details
+  astore search nginx --long`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSearch,
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 100, "maximum number of results to return")
+	searchCmd.Flags().StringVar(&searchCursor, "cursor", "", "continue after a previous page of results")
+	searchCmd.Flags().BoolVarP(&searchLongForm, "long", "l", false, "use long listing format")
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	// Create client
+	c, err := getClient()
+	if err != nil {
+		return err
+	}
+
+	opts := &client.SearchOptions{
+		Limit:  searchLimit,
+		Cursor: searchCursor,
+	}
+
+	result, err := c.Search(context.Background(), strings.Join(args, " "), opts)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	if len(result.Results) == 0 {
+		fmt.Println("No artifacts found")
+		return nil
+	}
+
+	fmt.Printf("Found %d artifact(s):\n", len(result.Results))
+
+	if searchLongForm {
+		var maxPathLen int
+		for _, hit := range result.Results {
+			if n := len(hit.Bucket) + 1 + len(hit.Key); n > maxPathLen {
+				maxPathLen = n
+			}
+		}
+
+		// Print header
+		fmt.Printf("  %-*s  %12s  %-19s  %s\n", maxPathLen, "ARTIFACT", "SIZE", "LAST MODIFIED", "SUPPLY CHAIN")
+		fmt.Println(strings.Repeat("-", maxPathLen+12+19+14+8))
+
+		for _, hit := range result.Results {
+			fmt.Printf("  %-*s  %12s  %-19s  %s\n",
+				maxPathLen,
+				hit.Bucket+"/"+hit.Key,
+				formatSize(hit.Size),
+				hit.UpdatedAt.Format("2006-01-02 15:04:05"),
+				supplyChainSummary(hit))
+		}
+	} else {
+		for _, hit := range result.Results {
+			fmt.Printf("  %s/%s\n", hit.Bucket, hit.Key)
+		}
+	}
+
+	if result.NextCursor != "" {
+		fmt.Printf("\nMore results available: --cursor %s\n", result.NextCursor)
+	}
+
+	return nil
+}
+
+// supplyChainSummary lists the supply-chain records an artifact has
+func supplyChainSummary(hit client.SearchHit) string {
+	var parts []string
+	if hit.Signed {
+		parts = append(parts, "signed")
+	}
+	if hit.HasSBOM {
+		parts = append(parts, "sbom")
+	}
+	if hit.Attested {
+		parts = append(parts, "attested")
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ",")
+}
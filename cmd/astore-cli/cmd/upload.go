@@ -14,8 +14,15 @@ import (
 var (
 	uploadContentType string
 	uploadMetadata    []string
+	uploadTags        []string
+	uploadResumable   bool
+	uploadResumeID    string
+	uploadChunkSize   int64
 )
 
+// resumableThreshold is the file size above which uploads are resumable by default
+const resumableThreshold = 64 * 1024 * 1024
+
 // uploadCmd represents the upload command
 var uploadCmd = &cobra.Command{
 	Use:   "upload <local-file> <bucket/key>",
@@ -30,7 +37,13 @@ Examples:
   astore upload --content-type application/gzip app.tar.gz releases/app-1.0.0.tar.gz
 
   # Upload with metadata
-  astore upload --metadata version=1.0.0 --metadata author=ci app.tar.gz releases/app-1.0.0.tar.gz`,
+  astore upload --metadata version=1.0.0 --metadata author=ci app.tar.gz releases/app-1.0.0.tar.gz
+
+  # Upload with tags
+  astore upload --tag env=prod --tag team=platform app.tar.gz releases/app-1.0.0.tar.gz
+
+  # Resume an interrupted upload (files over 64MiB are resumable by default)
+  astore upload --resume 3f2a9c1e-... disk.img images/disk-1.0.img`,
 	Args: cobra.ExactArgs(2),
 	RunE: runUpload,
 }
@@ -40,6 +53,10 @@ func init() {
 
 	uploadCmd.Flags().StringVar(&uploadContentType, "content-type", "", "content type of the artifact")
 	uploadCmd.Flags().StringArrayVarP(&uploadMetadata, "metadata", "m", []string{}, "metadata key=value pairs")
+	uploadCmd.Flags().StringArrayVarP(&uploadTags, "tag", "t", []string{}, "tag key=value pairs")
+	uploadCmd.Flags().BoolVar(&uploadResumable, "resumable", false, "upload in chunks that can be resumed after an interruption")
+	uploadCmd.Flags().StringVar(&uploadResumeID, "resume", "", "resume the interrupted upload with this ID")
+	uploadCmd.Flags().Int64Var(&uploadChunkSize, "chunk-size", client.DefaultChunkSize/(1024*1024), "chunk size in MiB for resumable uploads")
 }
 
 func runUpload(cmd *cobra.Command, args []string) error {
@@ -62,12 +79,6 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cannot upload directory: %s", localFile)
 	}
 
-	// Read file
-	data, err := os.ReadFile(localFile)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
 	// Parse metadata
 	metadata := make(map[string]string)
 	for _, kv := range uploadMetadata {
@@ -78,6 +89,16 @@ func runUpload(cmd *cobra.Command, args []string) error {
 		metadata[parts[0]] = parts[1]
 	}
 
+	// Parse tags
+	tags := make(map[string]string)
+	for _, kv := range uploadTags {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid tag format: %s (expected key=value)", kv)
+		}
+		tags[parts[0]] = parts[1]
+	}
+
 	// Determine content type
 	contentType := uploadContentType
 	if contentType == "" {
@@ -92,13 +113,24 @@ func runUpload(cmd *cobra.Command, args []string) error {
 
 	// Prepare upload options
 	opts := &client.UploadOptions{
-		ContentType:      contentType,
-		Metadata:         metadata,
-		ProgressCallback: getProgressCallback(int64(len(data)), "Upload"),
+		ContentType: contentType,
+		Metadata:    metadata,
+		Tags:        tags,
 	}
 
-	// Upload
 	ctx := context.Background()
+	if uploadResumable || uploadResumeID != "" || fileInfo.Size() > resumableThreshold {
+		return runResumableUpload(ctx, c, localFile, fileInfo.Size(), bucket, key, opts)
+	}
+
+	// Read file
+	data, err := os.ReadFile(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	opts.ProgressCallback = getProgressCallback(int64(len(data)), "Upload")
+
+	// Upload
 	if verbose {
 		fmt.Printf("Uploading %s to %s/%s (%s)\n", localFile, bucket, key, formatSize(int64(len(data))))
 	}
@@ -116,6 +148,59 @@ func runUpload(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// runResumableUpload sends a file in chunks, continuing the upload named by
+// --resume from the offset the server acknowledged
+func runResumableUpload(ctx context.Context, c *client.Client, localFile string, size int64, bucket, key string, opts *client.UploadOptions) error {
+	if uploadChunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive")
+	}
+
+	file, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	uploadID := uploadResumeID
+	if uploadID == "" {
+		upload, err := c.CreateResumableUpload(ctx, bucket, key, size, opts)
+		if err != nil {
+			return fmt.Errorf("upload failed: %w", err)
+		}
+		uploadID = upload.UploadID
+	} else {
+		upload, err := c.GetResumableUpload(ctx, bucket, key, uploadID)
+		if err != nil {
+			return fmt.Errorf("failed to find upload %s: %w", uploadID, err)
+		}
+		if upload.TotalSize != size {
+			return fmt.Errorf("upload %s expects %s but %s is %s", uploadID, formatSize(upload.TotalSize), localFile, formatSize(size))
+		}
+		if verbose {
+			fmt.Printf("Resuming upload %s at %s\n", uploadID, formatSize(upload.UploadedSize))
+		}
+	}
+
+	if verbose {
+		fmt.Printf("Uploading %s to %s/%s (%s, upload ID %s)\n", localFile, bucket, key, formatSize(size), uploadID)
+	}
+
+	_, err = c.ResumeUpload(ctx, bucket, key, uploadID, file, &client.ResumeOptions{
+		ChunkSize:        uploadChunkSize * 1024 * 1024,
+		ProgressCallback: getProgressCallback(size, "Upload"),
+	})
+	if err != nil {
+		return fmt.Errorf("upload interrupted: %w\nresume with: astore upload --resume %s %s %s/%s", err, uploadID, localFile, bucket, key)
+	}
+
+	if verbose {
+		fmt.Println() // New line after progress
+	}
+	fmt.Printf("✓ Uploaded %s to %s/%s\n", localFile, bucket, key)
+
+	return nil
+}
+
 func guessContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 	switch ext {
@@ -9,10 +9,14 @@ import (
 	"syscall"
 
 	"github.com/candlekeep/zot-artifact-store/internal/extensions"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/backup"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions/metrics"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions/rbac"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/relations"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions/s3api"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/search"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions/supplychain"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"gopkg.in/yaml.v2"
 	"zotregistry.io/zot/pkg/api"
 	"zotregistry.io/zot/pkg/api/config"
@@ -24,6 +28,11 @@ const version = "0.1.0-dev"
 func main() {
 	// Parse command-line flags
 	configFile := flag.String("config", "", "Path to configuration file")
+	metadataDriver := flag.String("metadata-driver", storage.MetadataDriverBolt, "Metadata store driver: bolt, sqlite or postgres")
+	metadataDSN := flag.String("metadata-dsn", "", "Metadata store file path or connection string (defaults to the storage root directory)")
+	metadataBackupDir := flag.String("metadata-backup-dir", "", "Back up the metadata database to this directory before applying schema migrations")
+	metadataDryRun := flag.Bool("metadata-migrate-dry-run", false, "Refuse to start while metadata schema migrations are pending instead of applying them")
+	metadataTombstones := flag.Bool("metadata-tombstones", false, "Archive supply-chain records of deleted or overwritten artifacts instead of dropping them")
 	flag.Parse()
 
 	fmt.Printf("Zot Artifact Store v%s\n", version)
@@ -81,6 +90,31 @@ func main() {
 		logger.Error().Err(err).Msg("Failed to register metrics extension")
 		os.Exit(1)
 	}
+	if err := extRegistry.Register(backup.NewBackupExtension()); err != nil {
+		logger.Error().Err(err).Msg("Failed to register backup extension")
+		os.Exit(1)
+	}
+	if err := extRegistry.Register(search.NewSearchExtension()); err != nil {
+		logger.Error().Err(err).Msg("Failed to register search extension")
+		os.Exit(1)
+	}
+	if err := extRegistry.Register(relations.NewRelationsExtension()); err != nil {
+		logger.Error().Err(err).Msg("Failed to register relations extension")
+		os.Exit(1)
+	}
+
+	// Open the metadata store shared by all extensions (closed by ShutdownAll)
+	logger.Info().Str("driver", *metadataDriver).Msg("Opening metadata store")
+	metadataConfig := &storage.MetadataConfig{
+		Driver:     *metadataDriver,
+		DSN:        *metadataDSN,
+		Migrations: storage.MigrationOptions{DryRun: *metadataDryRun, BackupDir: *metadataBackupDir},
+		Tombstones: *metadataTombstones,
+	}
+	if err := extRegistry.OpenMetadataStore(cfg, metadataConfig); err != nil {
+		logger.Error().Err(err).Msg("Failed to open metadata store")
+		os.Exit(1)
+	}
 
 	// Set up context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
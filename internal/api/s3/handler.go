@@ -4,14 +4,18 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/models"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"github.com/google/uuid"
@@ -22,14 +26,22 @@ import (
 
 // Handler handles S3-compatible API requests
 type Handler struct {
-	metadataStore *storage.MetadataStore
+	metadataStore storage.MetadataStore
 	logger        log.Logger
 	dataDir       string
+	notifier      ChangeNotifier
+	uploadLocks   sync.Map // Resumable upload ID to the *sync.Mutex held while it changes
 	// TODO: Add Zot storage controller when needed for integration
 }
 
+// ChangeNotifier is told about object writes and deletes, e.g. to queue replication
+type ChangeNotifier interface {
+	ObjectChanged(bucket, key string) error
+	ObjectDeleted(bucket, key string) error
+}
+
 // NewHandler creates a new S3 API handler
-func NewHandler(metadataStore *storage.MetadataStore, dataDir string, logger log.Logger) *Handler {
+func NewHandler(metadataStore storage.MetadataStore, dataDir string, logger log.Logger) *Handler {
 	return &Handler{
 		metadataStore: metadataStore,
 		logger:        logger,
@@ -37,6 +49,11 @@ func NewHandler(metadataStore *storage.MetadataStore, dataDir string, logger log
 	}
 }
 
+// SetChangeNotifier sets the notifier called after objects are written or deleted
+func (h *Handler) SetChangeNotifier(notifier ChangeNotifier) {
+	h.notifier = notifier
+}
+
 // RegisterRoutes registers S3 API routes
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// Bucket operations
@@ -45,6 +62,13 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/s3/{bucket}", h.DeleteBucket).Methods("DELETE")
 	router.HandleFunc("/s3/{bucket}", h.ListObjects).Methods("GET")
 
+	// Resumable upload operations, registered before the object routes they share paths with
+	router.HandleFunc("/s3/{bucket}/{key:.*}", h.CreateResumableUpload).Methods("POST").Queries("resumable", "")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", h.PatchResumableUpload).Methods("PATCH").Queries("resumable", "{uploadId}")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", h.HeadResumableUpload).Methods("HEAD").Queries("resumable", "{uploadId}")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", h.GetResumableUpload).Methods("GET").Queries("resumable", "{uploadId}")
+	router.HandleFunc("/s3/{bucket}/{key:.*}", h.AbortResumableUpload).Methods("DELETE").Queries("resumable", "{uploadId}")
+
 	// Object operations
 	router.HandleFunc("/s3/{bucket}/{key:.*}", h.PutObject).Methods("PUT").Queries()
 	router.HandleFunc("/s3/{bucket}/{key:.*}", h.GetObject).Methods("GET")
@@ -58,6 +82,24 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/s3/{bucket}/{key:.*}", h.AbortMultipartUpload).Methods("DELETE").Queries("uploadId", "{uploadId}")
 }
 
+// RoutePermissions declares the action and resource each route requires.
+// Object routes share permissions across their query variants.
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	const object = "{bucket}/{key}"
+	return []auth.RoutePermission{
+		{Method: "GET", Path: "/s3", Action: models.ActionList, Resource: "*"},
+		{Method: "PUT", Path: "/s3/{bucket}", Action: models.ActionWrite, Resource: "{bucket}"},
+		{Method: "DELETE", Path: "/s3/{bucket}", Action: models.ActionDelete, Resource: "{bucket}"},
+		{Method: "GET", Path: "/s3/{bucket}", Action: models.ActionList, Resource: "{bucket}"},
+		{Method: "GET", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionRead, Resource: object},
+		{Method: "HEAD", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionRead, Resource: object},
+		{Method: "PUT", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: object},
+		{Method: "POST", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: object},
+		{Method: "PATCH", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: object},
+		{Method: "DELETE", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionDelete, Resource: object},
+	}
+}
+
 // === Bucket Operations ===
 
 // ListBuckets lists all buckets
@@ -187,6 +229,12 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, err := extractTags(r.Header)
+	if err != nil {
+		http.Error(w, "Invalid tagging header", http.StatusBadRequest)
+		return
+	}
+
 	// Read and store the object
 	hash := md5.New()
 	tempFile := filepath.Join(h.dataDir, bucketName, key)
@@ -213,6 +261,7 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 		MD5:         md5Sum,
 		StoragePath: tempFile,
 		Metadata:    extractMetadata(r.Header),
+		Tags:        tags,
 	}
 
 	if err := h.metadataStore.StoreArtifact(artifact); err != nil {
@@ -227,6 +276,7 @@ func (h *Handler) PutObject(w http.ResponseWriter, r *http.Request) {
 	h.metadataStore.UpdateBucket(bucket)
 
 	h.logger.Info().Str("bucket", bucketName).Str("key", key).Int64("size", size).Msg("object uploaded")
+	h.notifyChanged(bucketName, key)
 
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, md5Sum))
 	w.WriteHeader(http.StatusOK)
@@ -313,18 +363,22 @@ func (h *Handler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete file
-	if err := h.deleteFile(artifact.StoragePath); err != nil {
-		h.logger.Error().Err(err).Str("bucket", bucketName).Str("key", key).Msg("failed to delete object file")
-	}
-
-	// Delete metadata
+	// Delete metadata first, so an object other artifacts depend on is left intact
 	if err := h.metadataStore.DeleteArtifact(bucketName, key); err != nil {
+		if errors.Is(err, storage.ErrArtifactHasDependents) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		h.logger.Error().Err(err).Str("bucket", bucketName).Str("key", key).Msg("failed to delete artifact metadata")
 		http.Error(w, "Failed to delete object", http.StatusInternalServerError)
 		return
 	}
 
+	// Delete file
+	if err := h.deleteFile(artifact.StoragePath); err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucketName).Str("key", key).Msg("failed to delete object file")
+	}
+
 	// Update bucket statistics
 	bucket, _ := h.metadataStore.GetBucket(bucketName)
 	if bucket != nil {
@@ -334,6 +388,7 @@ func (h *Handler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info().Str("bucket", bucketName).Str("key", key).Msg("object deleted")
+	h.notifyDeleted(bucketName, key)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -351,6 +406,12 @@ func (h *Handler) InitiateMultipartUpload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	tags, err := extractTags(r.Header)
+	if err != nil {
+		http.Error(w, "Invalid tagging header", http.StatusBadRequest)
+		return
+	}
+
 	uploadID := uuid.New().String()
 	upload := &models.MultipartUpload{
 		UploadID:    uploadID,
@@ -358,6 +419,7 @@ func (h *Handler) InitiateMultipartUpload(w http.ResponseWriter, r *http.Request
 		Key:         objectKey,
 		ContentType: r.Header.Get("Content-Type"),
 		Metadata:    extractMetadata(r.Header),
+		Tags:        tags,
 		Parts:       []models.MultipartPart{},
 	}
 
@@ -471,6 +533,7 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 		ContentType: upload.ContentType,
 		StoragePath: finalPath,
 		Metadata:    upload.Metadata,
+		Tags:        upload.Tags,
 		IsMultipart: true,
 		UploadID:    uploadID,
 	}
@@ -487,6 +550,7 @@ func (h *Handler) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request
 	}
 
 	h.logger.Info().Str("bucket", bucketName).Str("key", objectKey).Str("uploadId", uploadID).Msg("multipart upload completed")
+	h.notifyChanged(bucketName, objectKey)
 
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"bucket": bucketName,
@@ -520,6 +584,45 @@ func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{})
 	json.NewEncoder(w).Encode(data)
 }
 
+func (h *Handler) notifyChanged(bucket, key string) {
+	if h.notifier == nil {
+		return
+	}
+	if err := h.notifier.ObjectChanged(bucket, key); err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("failed to record object change")
+	}
+}
+
+func (h *Handler) notifyDeleted(bucket, key string) {
+	if h.notifier == nil {
+		return
+	}
+	if err := h.notifier.ObjectDeleted(bucket, key); err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("failed to record object deletion")
+	}
+}
+
+// extractTags parses object tags from the URL-encoded x-amz-tagging header
+func extractTags(headers http.Header) (map[string]string, error) {
+	header := headers.Get("X-Amz-Tagging")
+	if header == "" {
+		return nil, nil
+	}
+
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(values))
+	for key, v := range values {
+		if key == "" {
+			return nil, fmt.Errorf("empty tag key")
+		}
+		tags[key] = v[0]
+	}
+	return tags, nil
+}
+
 func extractMetadata(headers http.Header) map[string]string {
 	metadata := make(map[string]string)
 	for key, values := range headers {
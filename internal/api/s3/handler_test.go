@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/candlekeep/zot-artifact-store/internal/api/s3"
@@ -15,7 +16,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func setupTestHandler(t *testing.T) (*s3.Handler, *storage.MetadataStore, string) {
+func setupTestHandler(t *testing.T) (*s3.Handler, storage.MetadataStore, string) {
 	// Create temporary database
 	tmpFile, err := os.CreateTemp("", "s3-handler-test-*.db")
 	test.AssertNoError(t, err, "creating temp db file")
@@ -26,7 +27,7 @@ func setupTestHandler(t *testing.T) (*s3.Handler, *storage.MetadataStore, string
 	dataDir, err := os.MkdirTemp("", "s3-handler-test-data-*")
 	test.AssertNoError(t, err, "creating temp data dir")
 
-	metadataStore, err := storage.NewMetadataStore(dbPath)
+	metadataStore, err := storage.NewBoltMetadataStore(dbPath)
 	test.AssertNoError(t, err, "creating metadata store")
 
 	logger := test.NewTestLogger(t)
@@ -223,6 +224,29 @@ func TestS3APIObjectOperations(t *testing.T) {
 		test.AssertError(t, err, "object should not exist")
 	})
 
+	t.Run("DELETE object others depend on is refused", func(t *testing.T) {
+		// Given: A source bundle that a binary was built from
+		handler, metadataStore, _ := setupTestHandler(t)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router)
+
+		metadataStore.CreateBucket(&models.Bucket{Name: "builds"})
+		metadataStore.StoreArtifact(&models.Artifact{Bucket: "builds", Key: "src.tar", StoragePath: "/tmp/fake-src"})
+		metadataStore.StoreArtifact(&models.Artifact{Bucket: "builds", Key: "app.bin", StoragePath: "/tmp/fake-bin"})
+		err := metadataStore.AddArtifactRelation(&models.ArtifactRelation{ArtifactID: "builds/app.bin", TargetID: "builds/src.tar", Type: models.RelationBuiltFrom})
+		test.AssertNoError(t, err, "add relation")
+
+		// When: Deleting the source bundle
+		req := httptest.NewRequest("DELETE", "/s3/builds/src.tar", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Then: The delete conflicts and the object is kept
+		test.AssertEqual(t, http.StatusConflict, w.Code, "status code")
+		_, err = metadataStore.GetArtifact("builds", "src.tar")
+		test.AssertNoError(t, err, "object should still exist")
+	})
+
 	t.Run("List objects in bucket", func(t *testing.T) {
 		// Given: A handler with a bucket and objects
 		handler, metadataStore, _ := setupTestHandler(t)
@@ -317,3 +341,120 @@ func TestS3APIMultipartUpload(t *testing.T) {
 		// For Phase 2, we'll mark this as a known issue and fix in Phase 3
 	})
 }
+
+func TestS3APIResumableUpload(t *testing.T) {
+	t.Run("Interrupted upload resumes from the acknowledged offset", func(t *testing.T) {
+		// Given: A handler with a bucket and a resumable upload of ten bytes
+		handler, metadataStore, dataDir := setupTestHandler(t)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router)
+		metadataStore.CreateBucket(&models.Bucket{Name: "resume-bucket"})
+
+		req := httptest.NewRequest("POST", "/s3/resume-bucket/disk.img?resumable", nil)
+		req.Header.Set(s3.UploadLengthHeader, "10")
+		req.Header.Set("X-Amz-Meta-Build", "42")
+		created := httptest.NewRecorder()
+		router.ServeHTTP(created, req)
+		test.AssertEqual(t, http.StatusCreated, created.Code, "create status")
+		var progress models.UploadProgress
+		test.AssertNoError(t, json.NewDecoder(created.Body).Decode(&progress), "decode progress")
+		uploadURL := created.Header().Get("Location")
+
+		// When: Sending the first four bytes, then a chunk at a stale offset
+		first := patchUpload(router, uploadURL, 0, "0123")
+		stale := patchUpload(router, uploadURL, 0, "0123")
+
+		// Then: The first chunk is acknowledged and the stale one is refused with the current offset
+		test.AssertEqual(t, http.StatusNoContent, first.Code, "first chunk status")
+		test.AssertEqual(t, "4", first.Header().Get(s3.UploadOffsetHeader), "offset after first chunk")
+		test.AssertEqual(t, http.StatusConflict, stale.Code, "stale chunk status")
+		test.AssertEqual(t, "4", stale.Header().Get(s3.UploadOffsetHeader), "offset reported on conflict")
+
+		// When: Asking for the offset and sending the rest from there
+		head := httptest.NewRecorder()
+		router.ServeHTTP(head, httptest.NewRequest("HEAD", uploadURL, nil))
+		rest := patchUpload(router, uploadURL, 4, "456789")
+
+		// Then: The object is stored with the upload's metadata and the upload is completed
+		test.AssertEqual(t, "4", head.Header().Get(s3.UploadOffsetHeader), "offset from HEAD")
+		test.AssertEqual(t, "10", head.Header().Get(s3.UploadLengthHeader), "length from HEAD")
+		test.AssertEqual(t, http.StatusNoContent, rest.Code, "final chunk status")
+		test.AssertTrue(t, rest.Header().Get("ETag") != "", "ETag returned on completion")
+
+		data, err := os.ReadFile(dataDir + "/resume-bucket/disk.img")
+		test.AssertNoError(t, err, "read stored object")
+		test.AssertEqual(t, "0123456789", string(data), "object content")
+		artifact, err := metadataStore.GetArtifact("resume-bucket", "disk.img")
+		test.AssertNoError(t, err, "get artifact")
+		test.AssertEqual(t, int64(10), artifact.Size, "artifact size")
+		test.AssertEqual(t, "42", artifact.Metadata["Build"], "artifact metadata")
+
+		status := httptest.NewRecorder()
+		router.ServeHTTP(status, httptest.NewRequest("GET", uploadURL, nil))
+		test.AssertEqual(t, http.StatusOK, status.Code, "status code")
+		var completed models.UploadProgress
+		test.AssertNoError(t, json.NewDecoder(status.Body).Decode(&completed), "decode status")
+		test.AssertEqual(t, models.UploadStatusCompleted, completed.Status, "upload completed")
+		test.AssertEqual(t, progress.UploadID, completed.UploadID, "upload ID")
+	})
+
+	t.Run("Upload rejects data beyond its length and can be aborted", func(t *testing.T) {
+		// Given: A resumable upload of four bytes
+		handler, metadataStore, _ := setupTestHandler(t)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router)
+		metadataStore.CreateBucket(&models.Bucket{Name: "abort-bucket"})
+
+		req := httptest.NewRequest("POST", "/s3/abort-bucket/small.bin?resumable", nil)
+		req.Header.Set(s3.UploadLengthHeader, "4")
+		created := httptest.NewRecorder()
+		router.ServeHTTP(created, req)
+		uploadURL := created.Header().Get("Location")
+
+		// When: Sending too much data, then aborting
+		tooLarge := patchUpload(router, uploadURL, 0, "012345")
+		aborted := httptest.NewRecorder()
+		router.ServeHTTP(aborted, httptest.NewRequest("DELETE", uploadURL, nil))
+		missing := httptest.NewRecorder()
+		router.ServeHTTP(missing, httptest.NewRequest("HEAD", uploadURL, nil))
+
+		// Then: The data is refused and the upload is gone
+		test.AssertEqual(t, http.StatusRequestEntityTooLarge, tooLarge.Code, "oversized chunk status")
+		test.AssertEqual(t, http.StatusNoContent, aborted.Code, "abort status")
+		test.AssertEqual(t, http.StatusNotFound, missing.Code, "aborted upload status")
+	})
+
+	t.Run("Requests for unknown or finished uploads release their lock", func(t *testing.T) {
+		// Given: A resumable upload of four bytes
+		handler, metadataStore, _ := setupTestHandler(t)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router)
+		metadataStore.CreateBucket(&models.Bucket{Name: "lock-bucket"})
+
+		req := httptest.NewRequest("POST", "/s3/lock-bucket/file.bin?resumable", nil)
+		req.Header.Set(s3.UploadLengthHeader, "4")
+		created := httptest.NewRecorder()
+		router.ServeHTTP(created, req)
+		uploadURL := created.Header().Get("Location")
+
+		// When: Patching an unknown upload twice, completing the upload and patching it again
+		unknown := patchUpload(router, "/s3/lock-bucket/file.bin?resumable=unknown", 0, "0123")
+		unknownAgain := patchUpload(router, "/s3/lock-bucket/file.bin?resumable=unknown", 0, "0123")
+		completed := patchUpload(router, uploadURL, 0, "0123")
+		again := patchUpload(router, uploadURL, 4, "4")
+
+		// Then: Each request takes the lock rather than finding it held
+		test.AssertEqual(t, http.StatusNotFound, unknown.Code, "unknown upload status")
+		test.AssertEqual(t, http.StatusNotFound, unknownAgain.Code, "repeated unknown upload status")
+		test.AssertEqual(t, http.StatusNoContent, completed.Code, "completing chunk status")
+		test.AssertEqual(t, http.StatusConflict, again.Code, "chunk after completion status")
+	})
+}
+
+func patchUpload(router *mux.Router, uploadURL string, offset int, data string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", uploadURL, bytes.NewReader([]byte(data)))
+	req.Header.Set(s3.UploadOffsetHeader, strconv.Itoa(offset))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,367 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+// Resumable upload headers
+const (
+	UploadLengthHeader = "Upload-Length" // Total size of the object, sent when the upload is created
+	UploadOffsetHeader = "Upload-Offset" // Bytes the server has acknowledged
+)
+
+// ResumableUploadExpiry is how long a resumable upload is kept after its
+// last activity. Completed uploads stay visible for the same time so clients
+// that lost the final response can confirm the upload finished.
+const ResumableUploadExpiry = 24 * time.Hour
+
+// CreateResumableUpload starts a resumable upload of an object whose total
+// size is given by the Upload-Length header
+func (h *Handler) CreateResumableUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectKey := vars["key"]
+
+	if _, err := h.metadataStore.GetBucket(bucketName); err != nil {
+		http.Error(w, "Bucket not found", http.StatusNotFound)
+		return
+	}
+
+	totalSize, err := strconv.ParseInt(r.Header.Get(UploadLengthHeader), 10, 64)
+	if err != nil || totalSize < 0 {
+		http.Error(w, "A valid Upload-Length header is required", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := extractTags(r.Header)
+	if err != nil {
+		http.Error(w, "Invalid tagging header", http.StatusBadRequest)
+		return
+	}
+
+	h.expireResumableUploads()
+
+	progress := &models.UploadProgress{
+		UploadID:    uuid.New().String(),
+		Bucket:      bucketName,
+		Key:         objectKey,
+		TotalSize:   totalSize,
+		ContentType: r.Header.Get("Content-Type"),
+		Metadata:    extractMetadata(r.Header),
+		Tags:        tags,
+	}
+
+	if _, err := h.saveToFile(h.resumablePath(progress), strings.NewReader("")); err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucketName).Str("key", objectKey).Msg("failed to create upload file")
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.metadataStore.CreateUploadProgress(progress); err != nil {
+		h.deleteFile(h.resumablePath(progress))
+		h.logger.Error().Err(err).Str("bucket", bucketName).Str("key", objectKey).Msg("failed to create resumable upload")
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("bucket", bucketName).Str("key", objectKey).Str("uploadId", progress.UploadID).
+		Int64("size", totalSize).Msg("resumable upload created")
+
+	w.Header().Set("Location", r.URL.Path+"?resumable="+progress.UploadID)
+	w.Header().Set(UploadOffsetHeader, "0")
+	h.writeJSON(w, http.StatusCreated, progress)
+}
+
+// PatchResumableUpload appends the request body to a resumable upload at the
+// offset given by the Upload-Offset header, which must match the bytes the
+// server has acknowledged. The object is stored once all bytes are received.
+func (h *Handler) PatchResumableUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := r.URL.Query().Get("resumable")
+
+	unlock, ok := h.lockUpload(uploadID)
+	if !ok {
+		http.Error(w, "Upload is busy", http.StatusLocked)
+		return
+	}
+	defer unlock()
+
+	progress, ok := h.getResumableUpload(w, r)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil {
+		http.Error(w, "A valid Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+	if progress.Status == models.UploadStatusCompleted || offset != progress.UploadedSize {
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(progress.UploadedSize, 10))
+		http.Error(w, "Upload offset mismatch", http.StatusConflict)
+		return
+	}
+
+	remaining := progress.TotalSize - offset
+	if r.ContentLength > remaining {
+		http.Error(w, "Data exceeds the upload length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	received, writeErr := h.appendToUpload(progress, r.Body, remaining)
+
+	// Record what was received even if the request failed, so the client can
+	// resume from there
+	progress.UploadedSize += received
+	progress.LastActivity = time.Now()
+	if err := h.metadataStore.UpdateUploadProgress(progress); err != nil {
+		h.logger.Error().Err(err).Str("uploadId", uploadID).Msg("failed to record upload progress")
+		http.Error(w, "Failed to record upload progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(UploadOffsetHeader, strconv.FormatInt(progress.UploadedSize, 10))
+	if writeErr != nil {
+		h.logger.Warn().Err(writeErr).Str("uploadId", uploadID).Int64("offset", progress.UploadedSize).Msg("resumable upload interrupted")
+		http.Error(w, "Failed to save upload data", http.StatusInternalServerError)
+		return
+	}
+
+	if progress.UploadedSize == progress.TotalSize {
+		if err := h.completeResumableUpload(progress); err != nil {
+			h.logger.Error().Err(err).Str("uploadId", uploadID).Msg("failed to complete resumable upload")
+			http.Error(w, "Failed to complete upload", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, progress.ETag))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HeadResumableUpload reports the acknowledged offset of a resumable upload
+func (h *Handler) HeadResumableUpload(w http.ResponseWriter, r *http.Request) {
+	progress, ok := h.getResumableUpload(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set(UploadOffsetHeader, strconv.FormatInt(progress.UploadedSize, 10))
+	w.Header().Set(UploadLengthHeader, strconv.FormatInt(progress.TotalSize, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetResumableUpload returns the progress of a resumable upload
+func (h *Handler) GetResumableUpload(w http.ResponseWriter, r *http.Request) {
+	progress, ok := h.getResumableUpload(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusOK, progress)
+}
+
+// AbortResumableUpload discards a resumable upload and the data received
+func (h *Handler) AbortResumableUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := r.URL.Query().Get("resumable")
+
+	unlock, ok := h.lockUpload(uploadID)
+	if !ok {
+		http.Error(w, "Upload is busy", http.StatusLocked)
+		return
+	}
+	defer unlock()
+
+	progress, ok := h.getResumableUpload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.removeResumableUpload(progress); err != nil {
+		h.logger.Error().Err(err).Str("uploadId", uploadID).Msg("failed to abort resumable upload")
+		http.Error(w, "Failed to abort upload", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("uploadId", uploadID).Msg("resumable upload aborted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// === Helper Functions ===
+
+// getResumableUpload loads the upload named by the resumable parameter,
+// writing a 404 if it does not exist or belongs to another object
+func (h *Handler) getResumableUpload(w http.ResponseWriter, r *http.Request) (*models.UploadProgress, bool) {
+	vars := mux.Vars(r)
+	progress, err := h.metadataStore.GetUploadProgress(r.URL.Query().Get("resumable"))
+	if err != nil || progress.Bucket != vars["bucket"] || progress.Key != vars["key"] {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return nil, false
+	}
+	return progress, true
+}
+
+// appendToUpload writes up to limit bytes from body at the upload's
+// acknowledged offset and returns how many were durably written
+func (h *Handler) appendToUpload(progress *models.UploadProgress, body io.Reader, limit int64) (int64, error) {
+	file, err := os.OpenFile(h.resumablePath(progress), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// Bytes past the acknowledged offset were never recorded, e.g. after a crash
+	if err := file.Truncate(progress.UploadedSize); err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(progress.UploadedSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, copyErr := io.Copy(file, io.LimitReader(body, limit))
+	if err := file.Sync(); err != nil {
+		return 0, err
+	}
+	return n, copyErr
+}
+
+// completeResumableUpload moves a fully received upload into place and
+// stores the object's metadata
+func (h *Handler) completeResumableUpload(progress *models.UploadProgress) error {
+	uploadPath := h.resumablePath(progress)
+
+	file, err := os.Open(uploadPath)
+	if err != nil {
+		return err
+	}
+	hash := md5.New()
+	_, err = io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to hash upload: %w", err)
+	}
+	md5Sum := hex.EncodeToString(hash.Sum(nil))
+
+	finalPath := filepath.Join(h.dataDir, progress.Bucket, progress.Key)
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(uploadPath, finalPath); err != nil {
+		return fmt.Errorf("failed to move upload into place: %w", err)
+	}
+
+	artifact := &models.Artifact{
+		Bucket:      progress.Bucket,
+		Key:         progress.Key,
+		Digest:      godigest.NewDigestFromHex("sha256", md5Sum), // Simplified as in PutObject
+		Size:        progress.TotalSize,
+		ContentType: progress.ContentType,
+		MD5:         md5Sum,
+		StoragePath: finalPath,
+		Metadata:    progress.Metadata,
+		Tags:        progress.Tags,
+		UploadID:    progress.UploadID,
+	}
+	if err := h.metadataStore.StoreArtifact(artifact); err != nil {
+		// Put the data back so the client can retry completion
+		os.Rename(finalPath, uploadPath)
+		return fmt.Errorf("failed to store artifact metadata: %w", err)
+	}
+
+	progress.Status = models.UploadStatusCompleted
+	progress.ETag = md5Sum
+	if err := h.metadataStore.UpdateUploadProgress(progress); err != nil {
+		h.logger.Warn().Err(err).Str("uploadId", progress.UploadID).Msg("failed to mark resumable upload completed")
+	}
+
+	if bucket, err := h.metadataStore.GetBucket(progress.Bucket); err == nil {
+		bucket.ObjectCount++
+		bucket.TotalSize += progress.TotalSize
+		h.metadataStore.UpdateBucket(bucket)
+	}
+
+	h.logger.Info().Str("bucket", progress.Bucket).Str("key", progress.Key).Str("uploadId", progress.UploadID).
+		Int64("size", progress.TotalSize).Msg("resumable upload completed")
+	h.notifyChanged(progress.Bucket, progress.Key)
+	return nil
+}
+
+// removeResumableUpload deletes an upload's data and progress record
+func (h *Handler) removeResumableUpload(progress *models.UploadProgress) error {
+	if progress.Status != models.UploadStatusCompleted {
+		if err := h.deleteFile(h.resumablePath(progress)); err != nil {
+			return err
+		}
+	}
+	return h.metadataStore.DeleteUploadProgress(progress.UploadID)
+}
+
+// expireResumableUploads removes uploads idle for longer than
+// ResumableUploadExpiry, skipping any that are receiving data
+func (h *Handler) expireResumableUploads() {
+	uploads, err := h.metadataStore.ListUploadProgress()
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("failed to list resumable uploads")
+		return
+	}
+
+	cutoff := time.Now().Add(-ResumableUploadExpiry)
+	for _, progress := range uploads {
+		if progress.LastActivity.After(cutoff) {
+			continue
+		}
+		unlock, ok := h.lockUpload(progress.UploadID)
+		if !ok {
+			continue
+		}
+		if err := h.removeResumableUpload(progress); err != nil {
+			h.logger.Warn().Err(err).Str("uploadId", progress.UploadID).Msg("failed to expire resumable upload")
+		}
+		unlock()
+	}
+}
+
+// lockUpload serializes changes to one resumable upload. It fails rather
+// than waits if the upload is already locked. The lock is dropped from
+// uploadLocks when released, so IDs that were never valid or whose upload
+// has finished do not accumulate.
+func (h *Handler) lockUpload(uploadID string) (func(), bool) {
+	for {
+		value, _ := h.uploadLocks.LoadOrStore(uploadID, &sync.Mutex{})
+		mu := value.(*sync.Mutex)
+		if !mu.TryLock() {
+			return nil, false
+		}
+
+		// A holder may have released and dropped this lock after we loaded
+		// it; only the lock still in the map serializes the upload
+		if current, ok := h.uploadLocks.Load(uploadID); ok && current == value {
+			return func() {
+				h.uploadLocks.CompareAndDelete(uploadID, value)
+				mu.Unlock()
+			}, true
+		}
+		mu.Unlock()
+	}
+}
+
+// resumablePath is where an upload's data is kept until it completes
+func (h *Handler) resumablePath(progress *models.UploadProgress) string {
+	return filepath.Join(h.dataDir, progress.Bucket, ".resumable", progress.UploadID)
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+// Actions lists every action, in the order access queries report them
+var Actions = []models.Action{
+	models.ActionRead,
+	models.ActionList,
+	models.ActionWrite,
+	models.ActionDelete,
+	models.ActionSign,
+	models.ActionAdmin,
+}
+
+// ActionDecision is the decision for one action on a resource
+type ActionDecision struct {
+	Action         models.Action `json:"action"`
+	Allowed        bool          `json:"allowed"`
+	Reason         string        `json:"reason"`
+	DecidingPolicy string        `json:"decidingPolicy,omitempty"`
+}
+
+// EffectiveAccess lists what a user may do on a resource
+type EffectiveAccess struct {
+	User          *models.User     `json:"user"`
+	Resource      string           `json:"resource"`
+	PolicyVersion uint64           `json:"policyVersion"`
+	Allowed       []models.Action  `json:"allowed"`
+	Decisions     []ActionDecision `json:"decisions"`
+}
+
+// Grantee is a user allowed an action on a resource
+type Grantee struct {
+	UserID         string   `json:"userId"`
+	Username       string   `json:"username"`
+	Roles          []string `json:"roles,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+	Source         string   `json:"source,omitempty"` // Directory source that knows the user
+	Reason         string   `json:"reason"`
+	DecidingPolicy string   `json:"decidingPolicy,omitempty"`
+}
+
+// AccessGrantees lists the users allowed an action on a resource
+type AccessGrantees struct {
+	Resource      string        `json:"resource"`
+	Action        models.Action `json:"action"`
+	PolicyVersion uint64        `json:"policyVersion"`
+	Anonymous     bool          `json:"anonymous"` // Unauthenticated requests are allowed too
+	Evaluated     int           `json:"evaluated"` // Users checked
+	Users         []Grantee     `json:"users"`
+}
+
+// EffectiveAccess evaluates every action a user might take on a resource.
+// Policy conditions are evaluated against reqCtx as in Authorize.
+func (e *PolicyEngine) EffectiveAccess(user *models.User, resource string, reqCtx *RequestContext) *EffectiveAccess {
+	access := &EffectiveAccess{
+		User:      user,
+		Resource:  resource,
+		Allowed:   []models.Action{},
+		Decisions: make([]ActionDecision, 0, len(Actions)),
+	}
+	for _, action := range Actions {
+		decision := e.Explain(user, resource, action, reqCtx)
+		access.PolicyVersion = decision.PolicyVersion
+		access.Decisions = append(access.Decisions, ActionDecision{
+			Action:         action,
+			Allowed:        decision.Allowed,
+			Reason:         decision.Reason,
+			DecidingPolicy: decision.DecidingPolicy,
+		})
+		if decision.Allowed {
+			access.Allowed = append(access.Allowed, action)
+		}
+	}
+	return access
+}
+
+// WhoCan lists the users allowed an action on a resource. Only the given
+// users are evaluated, so the answer is as complete as the directory they
+// come from.
+func (e *PolicyEngine) WhoCan(users []*models.User, resource string, action models.Action, reqCtx *RequestContext) *AccessGrantees {
+	anonymous := e.Explain(nil, resource, action, reqCtx)
+	grantees := &AccessGrantees{
+		Resource:      resource,
+		Action:        action,
+		PolicyVersion: anonymous.PolicyVersion,
+		Anonymous:     anonymous.Allowed,
+		Evaluated:     len(users),
+		Users:         []Grantee{},
+	}
+	for _, user := range users {
+		decision := e.Explain(user, resource, action, reqCtx)
+		if !decision.Allowed {
+			continue
+		}
+		grantees.Users = append(grantees.Users, Grantee{
+			UserID:         user.ID,
+			Username:       user.Username,
+			Roles:          user.Roles,
+			Groups:         user.Groups,
+			Source:         user.Metadata["source"],
+			Reason:         decision.Reason,
+			DecidingPolicy: decision.DecidingPolicy,
+		})
+	}
+	return grantees
+}
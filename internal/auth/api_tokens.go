@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/google/uuid"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// APITokenPrefix starts every API token, distinguishing it from a JWT.
+// Tokens have the form astore_<token ID>_<secret>.
+const APITokenPrefix = "astore_"
+
+// lastUsedResolution limits how often a token's last use is written
+const lastUsedResolution = time.Minute
+
+// AuthMethodAPIToken is recorded in User.Metadata["authMethod"] for users
+// authenticated by an API token
+const AuthMethodAPIToken = "api-token"
+
+// APITokenRequest describes a token to create
+type APITokenRequest struct {
+	Name      string              `json:"name"`
+	Scopes    []models.Permission `json:"scopes,omitempty"`
+	ExpiresAt *time.Time          `json:"expiresAt,omitempty"` // Nil never expires
+}
+
+// APITokenManager creates, validates and revokes API tokens for users and
+// service accounts
+type APITokenManager struct {
+	store  storage.MetadataStore
+	logger log.Logger
+}
+
+// NewAPITokenManager creates an API token manager
+func NewAPITokenManager(store storage.MetadataStore, logger log.Logger) *APITokenManager {
+	return &APITokenManager{store: store, logger: logger}
+}
+
+// IsAPIToken reports whether a bearer token is an API token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// CreateServiceAccount stores a new service account
+func (m *APITokenManager) CreateServiceAccount(account *models.ServiceAccount) error {
+	if account.Name == "" {
+		return fmt.Errorf("service account name is required")
+	}
+	accounts, err := m.store.ListServiceAccounts()
+	if err != nil {
+		return fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	for _, existing := range accounts {
+		if existing.Name == account.Name {
+			return fmt.Errorf("service account %s already exists", account.Name)
+		}
+	}
+
+	account.ID = uuid.New().String()
+	if err := m.store.StoreServiceAccount(account); err != nil {
+		return fmt.Errorf("failed to store service account: %w", err)
+	}
+	return nil
+}
+
+// DeleteServiceAccount deletes a service account and all of its tokens
+func (m *APITokenManager) DeleteServiceAccount(id string) error {
+	if _, err := m.store.GetServiceAccount(id); err != nil {
+		return err
+	}
+	tokens, err := m.ListTokens(id)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := m.store.DeleteAPIToken(token.ID); err != nil {
+			return fmt.Errorf("failed to delete API token %s: %w", token.ID, err)
+		}
+	}
+	return m.store.DeleteServiceAccount(id)
+}
+
+// CreateUserToken creates a personal access token for a user. The token
+// carries the user's current roles and groups, which cannot be revisited
+// once the user leaves the identity provider, so personal tokens must be
+// scoped and expire. The returned secret is the bearer token and cannot be
+// recovered later.
+func (m *APITokenManager) CreateUserToken(user *models.User, request *APITokenRequest) (*models.APIToken, string, error) {
+	if len(request.Scopes) == 0 {
+		return nil, "", fmt.Errorf("personal tokens need at least one scope")
+	}
+	if request.ExpiresAt == nil {
+		return nil, "", fmt.Errorf("personal tokens need an expiry")
+	}
+	token := &models.APIToken{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    user.Roles,
+		Groups:   user.Groups,
+	}
+	return m.createToken(token, request)
+}
+
+// CreateServiceAccountToken creates a token for a service account. The
+// returned secret is the bearer token and cannot be recovered later.
+func (m *APITokenManager) CreateServiceAccountToken(accountID string, request *APITokenRequest) (*models.APIToken, string, error) {
+	account, err := m.store.GetServiceAccount(accountID)
+	if err != nil {
+		return nil, "", err
+	}
+	token := &models.APIToken{
+		UserID:           account.ID,
+		Username:         account.Name,
+		ServiceAccountID: account.ID,
+	}
+	return m.createToken(token, request)
+}
+
+func (m *APITokenManager) createToken(token *models.APIToken, request *APITokenRequest) (*models.APIToken, string, error) {
+	if request.Name == "" {
+		return nil, "", fmt.Errorf("token name is required")
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("token expiry must be in the future")
+	}
+	for _, scope := range request.Scopes {
+		if scope.Resource == "" || len(scope.Actions) == 0 {
+			return nil, "", fmt.Errorf("token scopes need a resource and at least one action")
+		}
+		if err := ValidateResourcePattern(scope.Resource); err != nil {
+			return nil, "", err
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token secret: %w", err)
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	// Token IDs are hex so the ID and secret split unambiguously on "_"
+	token.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	token.Name = request.Name
+	token.Scopes = request.Scopes
+	token.SecretHash = hashSecret(encodedSecret)
+	token.ExpiresAt = request.ExpiresAt
+	token.CreatedAt = time.Now()
+
+	if err := m.store.StoreAPIToken(token); err != nil {
+		return nil, "", fmt.Errorf("failed to store API token: %w", err)
+	}
+
+	m.logger.Info().Str("tokenId", token.ID).Str("owner", token.UserID).Msg("API token created")
+	return token, APITokenPrefix + token.ID + "_" + encodedSecret, nil
+}
+
+// GetToken returns a token by ID
+func (m *APITokenManager) GetToken(id string) (*models.APIToken, error) {
+	return m.store.GetAPIToken(id)
+}
+
+// ListTokens lists the tokens owned by a user or service account, or all
+// tokens if ownerID is empty
+func (m *APITokenManager) ListTokens(ownerID string) ([]*models.APIToken, error) {
+	tokens, err := m.store.ListAPITokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	if ownerID == "" {
+		return tokens, nil
+	}
+
+	owned := make([]*models.APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.UserID == ownerID {
+			owned = append(owned, token)
+		}
+	}
+	return owned, nil
+}
+
+// RevokeToken revokes a token. Revoked tokens are kept for auditing.
+func (m *APITokenManager) RevokeToken(id string) error {
+	if _, err := m.store.GetAPIToken(id); err != nil {
+		return err
+	}
+
+	err := m.store.UpdateAPIToken(id, func(token *models.APIToken) error {
+		if token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	m.logger.Info().Str("tokenId", id).Msg("API token revoked")
+	return nil
+}
+
+// ValidateToken validates an API token and returns the user it acts as
+func (m *APITokenManager) ValidateToken(bearer string) (*models.User, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(bearer, APITokenPrefix), "_")
+	if !IsAPIToken(bearer) || !ok || id == "" || secret == "" {
+		return nil, fmt.Errorf("malformed API token")
+	}
+
+	token, err := m.store.GetAPIToken(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API token")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.SecretHash)) != 1 {
+		return nil, fmt.Errorf("invalid API token")
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil {
+		return nil, fmt.Errorf("API token revoked")
+	}
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, fmt.Errorf("API token expired")
+	}
+
+	user := &models.User{
+		ID:       token.UserID,
+		Username: token.Username,
+		Roles:    token.Roles,
+		Groups:   token.Groups,
+		Scopes:   token.Scopes,
+		Metadata: map[string]string{"authMethod": AuthMethodAPIToken, "tokenId": token.ID},
+	}
+
+	// Service accounts act with their current roles and groups
+	if token.ServiceAccountID != "" {
+		account, err := m.store.GetServiceAccount(token.ServiceAccountID)
+		if err != nil {
+			return nil, fmt.Errorf("service account of API token not found")
+		}
+		user.Username = account.Name
+		user.Roles = account.Roles
+		user.Groups = account.Groups
+	}
+
+	// Only the last-used time is written, so a revocation racing this
+	// request is kept
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		err := m.store.UpdateAPIToken(token.ID, func(stored *models.APIToken) error {
+			stored.LastUsedAt = &now
+			return nil
+		})
+		if err != nil {
+			m.logger.Warn().Err(err).Str("tokenId", token.ID).Msg("failed to record API token use")
+		}
+	}
+
+	return user, nil
+}
+
+// IsAPITokenUser reports whether a user was authenticated by an API token
+func IsAPITokenUser(user *models.User) bool {
+	return user != nil && user.Metadata["authMethod"] == AuthMethodAPIToken
+}
+
+// hashSecret hashes a token secret. Secrets are 256 random bits, so a fast
+// hash is enough to make a leaked hash useless.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,220 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func newTokenManager(t *testing.T) (*auth.APITokenManager, storage.MetadataStore) {
+	store := test.NewTestMetadataStore(t)
+	return auth.NewAPITokenManager(store, test.NewTestLogger(t)), store
+}
+
+// personalTokenRequest requests a day-long personal token that can read anything
+func personalTokenRequest(name string) *auth.APITokenRequest {
+	expiresAt := time.Now().Add(24 * time.Hour)
+	return &auth.APITokenRequest{
+		Name:      name,
+		Scopes:    []models.Permission{{Resource: "*", Actions: []models.Action{models.ActionRead}}},
+		ExpiresAt: &expiresAt,
+	}
+}
+
+func TestAPITokenManager(t *testing.T) {
+	alice := &models.User{ID: "user-1", Username: "alice", Roles: []string{"developer"}}
+
+	t.Run("Personal tokens act as their user", func(t *testing.T) {
+		// Given: A personal token scoped to reading one bucket
+		manager, store := newTokenManager(t)
+		request := personalTokenRequest("laptop")
+		request.Scopes = []models.Permission{{Resource: "releases/*", Actions: []models.Action{models.ActionRead}}}
+		token, secret, err := manager.CreateUserToken(alice, request)
+		test.AssertNoError(t, err, "creating token")
+		test.AssertTrue(t, strings.HasPrefix(secret, auth.APITokenPrefix), "secret prefix")
+		test.AssertFalse(t, strings.Contains(token.SecretHash, secret), "only the hash is stored")
+
+		// When: Validating the secret
+		user, err := manager.ValidateToken(secret)
+
+		// Then: It maps to the user with the token's scopes, and its use is recorded
+		test.AssertNoError(t, err, "validating token")
+		test.AssertEqual(t, "user-1", user.ID, "user ID")
+		test.AssertEqual(t, "developer", strings.Join(user.Roles, ","), "roles")
+		test.AssertEqual(t, 1, len(user.Scopes), "scopes")
+		test.AssertTrue(t, auth.IsAPITokenUser(user), "authenticated by API token")
+		stored, err := store.GetAPIToken(token.ID)
+		test.AssertNoError(t, err, "getting token")
+		test.AssertTrue(t, stored.LastUsedAt != nil, "last use recorded")
+	})
+
+	t.Run("Invalid, revoked and expired tokens are rejected", func(t *testing.T) {
+		// Given: A valid token
+		manager, store := newTokenManager(t)
+		token, secret, err := manager.CreateUserToken(alice, personalTokenRequest("ci"))
+		test.AssertNoError(t, err, "creating token")
+
+		// When/Then: Malformed or wrong secrets are rejected
+		_, err = manager.ValidateToken("astore_nonsense")
+		test.AssertError(t, err, "malformed token")
+		_, err = manager.ValidateToken(auth.APITokenPrefix + token.ID + "_wrong")
+		test.AssertError(t, err, "wrong secret")
+
+		// And: An expired token is rejected
+		past := time.Now().Add(-time.Minute)
+		stored, err := store.GetAPIToken(token.ID)
+		test.AssertNoError(t, err, "getting token")
+		stored.ExpiresAt = &past
+		test.AssertNoError(t, store.StoreAPIToken(stored), "expiring token")
+		_, err = manager.ValidateToken(secret)
+		test.AssertError(t, err, "expired token")
+
+		// And: A revoked token is rejected
+		_, secret, err = manager.CreateUserToken(alice, personalTokenRequest("ci-2"))
+		test.AssertNoError(t, err, "creating token")
+		user, err := manager.ValidateToken(secret)
+		test.AssertNoError(t, err, "validating token before revocation")
+		test.AssertNoError(t, manager.RevokeToken(user.Metadata["tokenId"]), "revoking token")
+		_, err = manager.ValidateToken(secret)
+		test.AssertError(t, err, "revoked token")
+	})
+
+	t.Run("Service account tokens use the account's current roles", func(t *testing.T) {
+		// Given: A service account with a token
+		manager, store := newTokenManager(t)
+		account := &models.ServiceAccount{Name: "ci-robot", Roles: []string{"uploader"}}
+		test.AssertNoError(t, manager.CreateServiceAccount(account), "creating service account")
+		test.AssertError(t, manager.CreateServiceAccount(&models.ServiceAccount{Name: "ci-robot"}), "duplicate name")
+		_, secret, err := manager.CreateServiceAccountToken(account.ID, &auth.APITokenRequest{Name: "pipeline"})
+		test.AssertNoError(t, err, "creating token")
+
+		// When: The account's roles change
+		account.Roles = []string{"publisher"}
+		test.AssertNoError(t, store.StoreServiceAccount(account), "updating service account")
+		user, err := manager.ValidateToken(secret)
+
+		// Then: The token acts with the new roles
+		test.AssertNoError(t, err, "validating token")
+		test.AssertEqual(t, "ci-robot", user.Username, "username")
+		test.AssertEqual(t, "publisher", strings.Join(user.Roles, ","), "roles")
+
+		// And: Deleting the account deletes its tokens
+		test.AssertNoError(t, manager.DeleteServiceAccount(account.ID), "deleting service account")
+		tokens, err := manager.ListTokens(account.ID)
+		test.AssertNoError(t, err, "listing tokens")
+		test.AssertEqual(t, 0, len(tokens), "tokens after deletion")
+		_, err = manager.ValidateToken(secret)
+		test.AssertError(t, err, "token of deleted account")
+	})
+
+	t.Run("Token requests are validated", func(t *testing.T) {
+		// Given: A token manager
+		manager, _ := newTokenManager(t)
+		past := time.Now().Add(-time.Hour)
+
+		// When/Then: Requests without a name, with past expiry or empty scopes fail
+		_, _, err := manager.CreateUserToken(alice, personalTokenRequest(""))
+		test.AssertError(t, err, "token without name")
+		request := personalTokenRequest("old")
+		request.ExpiresAt = &past
+		_, _, err = manager.CreateUserToken(alice, request)
+		test.AssertError(t, err, "token expiring in the past")
+		request = personalTokenRequest("empty")
+		request.Scopes = []models.Permission{{Resource: "releases"}}
+		_, _, err = manager.CreateUserToken(alice, request)
+		test.AssertError(t, err, "scope without actions")
+
+		// And: Personal tokens without scopes or expiry fail
+		request = personalTokenRequest("unscoped")
+		request.Scopes = nil
+		_, _, err = manager.CreateUserToken(alice, request)
+		test.AssertError(t, err, "personal token without scopes")
+		request = personalTokenRequest("forever")
+		request.ExpiresAt = nil
+		_, _, err = manager.CreateUserToken(alice, request)
+		test.AssertError(t, err, "personal token without expiry")
+	})
+
+	t.Run("Recording use keeps a concurrent revocation", func(t *testing.T) {
+		// Given: A revoked token
+		manager, store := newTokenManager(t)
+		token, secret, err := manager.CreateUserToken(alice, personalTokenRequest("ci"))
+		test.AssertNoError(t, err, "creating token")
+		test.AssertNoError(t, manager.RevokeToken(token.ID), "revoking token")
+
+		// When: Its use is recorded
+		now := time.Now()
+		err = store.UpdateAPIToken(token.ID, func(stored *models.APIToken) error {
+			stored.LastUsedAt = &now
+			return nil
+		})
+		test.AssertNoError(t, err, "recording use")
+
+		// Then: The token stays revoked
+		stored, err := store.GetAPIToken(token.ID)
+		test.AssertNoError(t, err, "getting token")
+		test.AssertTrue(t, stored.RevokedAt != nil, "token still revoked")
+		_, err = manager.ValidateToken(secret)
+		test.AssertError(t, err, "revoked token")
+	})
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	// Given: An admin whose token is scoped to reading one bucket
+	engine := auth.NewPolicyEngine(false)
+	user := &models.User{
+		ID:     "admin-1",
+		Roles:  []string{"admin"},
+		Scopes: []models.Permission{{Resource: "releases/*", Actions: []models.Action{models.ActionRead}}},
+	}
+
+	// When/Then: Only reads in the bucket are allowed
+	allowed, _ := engine.Authorize(user, "releases/app.jar", models.ActionRead, nil)
+	test.AssertTrue(t, allowed, "read in scope")
+	allowed, _ = engine.Authorize(user, "releases/app.jar", models.ActionWrite, nil)
+	test.AssertFalse(t, allowed, "write outside scope")
+	allowed, _ = engine.Authorize(user, "private/app.jar", models.ActionRead, nil)
+	test.AssertFalse(t, allowed, "read in other bucket")
+}
+
+func TestMiddlewareAcceptsAPITokens(t *testing.T) {
+	// Given: Middleware accepting API tokens and a policy allowing alice to read
+	manager, _ := newTokenManager(t)
+	engine := auth.NewPolicyEngine(false)
+	engine.AddPolicy(&models.Policy{ID: "p1", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"alice"}})
+	middleware := auth.NewMiddleware(nil, engine, test.NewTestLogger(t), true)
+	middleware.SetAPITokenManager(manager)
+
+	table, err := auth.NewRouteTable([]auth.RoutePermission{
+		{Method: "GET", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionRead, Resource: "{bucket}/{key}"},
+	})
+	test.AssertNoError(t, err, "building route table")
+	router := mux.NewRouter()
+	router.HandleFunc("/s3/{bucket}/{key:.*}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	router.Use(middleware.AuthenticateRequest, middleware.AuthorizeRoutes(table))
+
+	_, secret, err := manager.CreateUserToken(&models.User{ID: "user-1", Username: "alice"}, personalTokenRequest("ci"))
+	test.AssertNoError(t, err, "creating token")
+
+	request := func(bearer string) int {
+		r := httptest.NewRequest("GET", "/s3/releases/app.jar", nil)
+		r.Header.Set("Authorization", "Bearer "+bearer)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// When/Then: The token is accepted and policies apply to its user
+	test.AssertEqual(t, http.StatusOK, request(secret), "valid API token")
+	test.AssertEqual(t, http.StatusUnauthorized, request(secret+"x"), "invalid API token")
+}
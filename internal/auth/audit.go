@@ -3,6 +3,9 @@ package auth
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/candlekeep/zot-artifact-store/internal/models"
@@ -11,15 +14,23 @@ import (
 	"zotregistry.io/zot/pkg/log"
 )
 
-// AuditLogger logs all access attempts and API calls
+// AuditLogger logs all access attempts and API calls. Entries are linked
+// into a hash chain and optionally streamed to external sinks.
 type AuditLogger struct {
-	store   *storage.MetadataStore
+	store   storage.MetadataStore
 	logger  log.Logger
 	enabled bool
+
+	chain              auditChain
+	checkpointInterval time.Duration   // How often RunCheckpoints records a checkpoint
+	proxies            *TrustedProxies // Optional; nil ignores forwarding headers
+
+	sinksMu sync.RWMutex  // Held for reading while sending, so Close waits for sends
+	sinks   []*sinkStream // External destinations entries are streamed to
 }
 
 // NewAuditLogger creates a new audit logger
-func NewAuditLogger(store *storage.MetadataStore, logger log.Logger, enabled bool) *AuditLogger {
+func NewAuditLogger(store storage.MetadataStore, logger log.Logger, enabled bool) *AuditLogger {
 	return &AuditLogger{
 		store:   store,
 		logger:  logger,
@@ -27,12 +38,77 @@ func NewAuditLogger(store *storage.MetadataStore, logger log.Logger, enabled boo
 	}
 }
 
+// SetTrustedProxies records the client IP forwarded by trusted proxies
+// instead of the proxy's address
+func (a *AuditLogger) SetTrustedProxies(proxies *TrustedProxies) {
+	a.proxies = proxies
+}
+
+// Metadata keys of audit entries recording authorization decisions, which
+// the policy simulator replays
+const (
+	auditDecision       = "decision" // allow or deny
+	auditDecidingPolicy = "decidingPolicy"
+	auditPolicyVersion  = "policyVersion"
+	auditPath           = "path"
+	auditSourceIP       = "sourceIp" // As policy conditions saw it
+	auditRoles          = "roles"    // Comma-separated
+	auditGroups         = "groups"   // Comma-separated
+	auditScopes         = "scopes"   // JSON; set for scoped API tokens
+	auditAnonymous      = "anonymous"
+)
+
 // LogAccess logs an access attempt
 func (a *AuditLogger) LogAccess(r *http.Request, status int, user *models.User, err error) {
 	if !a.enabled {
 		return
 	}
 
+	a.record(a.newAuditLog(r, status, user, err))
+}
+
+// LogDecision logs an authorization decision for a request. The entry's
+// action and resource are those that were authorized, and its metadata
+// holds what is needed to evaluate the request again.
+func (a *AuditLogger) LogDecision(r *http.Request, status int, user *models.User, resource string, action models.Action, reqCtx *RequestContext, decision *Decision) {
+	if !a.enabled {
+		return
+	}
+
+	log := a.newAuditLog(r, status, user, nil)
+	log.Action = string(action)
+	log.Resource = resource
+	log.Metadata[auditPath] = r.URL.Path
+	log.Metadata[auditPolicyVersion] = strconv.FormatUint(decision.PolicyVersion, 10)
+	if decision.Allowed {
+		log.Metadata[auditDecision] = "allow"
+	} else {
+		log.Metadata[auditDecision] = "deny"
+		log.Error = decision.Reason
+	}
+	if decision.DecidingPolicy != "" {
+		log.Metadata[auditDecidingPolicy] = decision.DecidingPolicy
+	}
+	if reqCtx != nil && reqCtx.SourceIP != nil {
+		log.Metadata[auditSourceIP] = reqCtx.SourceIP.String()
+	}
+
+	if user == nil {
+		log.Metadata[auditAnonymous] = "true"
+	} else {
+		log.Metadata[auditRoles] = strings.Join(user.Roles, ",")
+		log.Metadata[auditGroups] = strings.Join(user.Groups, ",")
+		if len(user.Scopes) > 0 {
+			scopesJSON, _ := json.Marshal(user.Scopes)
+			log.Metadata[auditScopes] = string(scopesJSON)
+		}
+	}
+
+	a.record(log)
+}
+
+// newAuditLog builds the audit entry of a request
+func (a *AuditLogger) newAuditLog(r *http.Request, status int, user *models.User, err error) *models.AuditLog {
 	log := &models.AuditLog{
 		ID:        uuid.New().String(),
 		Timestamp: time.Now(),
@@ -40,7 +116,7 @@ func (a *AuditLogger) LogAccess(r *http.Request, status int, user *models.User,
 		Resource:  r.URL.Path,
 		Method:    r.Method,
 		Status:    status,
-		IPAddress: getClientIP(r),
+		IPAddress: a.clientIP(r),
 		UserAgent: r.UserAgent(),
 		Metadata:  make(map[string]string),
 	}
@@ -63,10 +139,28 @@ func (a *AuditLogger) LogAccess(r *http.Request, status int, user *models.User,
 		log.Metadata["query"] = string(queryJSON)
 	}
 
-	// Store audit log
-	if err := a.store.StoreAuditLog(log); err != nil {
+	return log
+}
+
+// record links an audit entry into the hash chain, stores it, streams it
+// to the sinks and writes it to the structured log. An entry that fails to
+// store, including when the chain's head cannot be read, is streamed and
+// logged without a place in the chain.
+func (a *AuditLogger) record(log *models.AuditLog) {
+	head, err := a.chain.append(a.store, log)
+	if err != nil {
 		a.logger.Error().Err(err).Msg("failed to store audit log")
+	} else if a.chain.dueCheckpoint(head) {
+		if _, err := a.chain.checkpoint(a.store, head); err != nil {
+			a.logger.Error().Err(err).Msg("failed to record audit checkpoint")
+		}
+	}
+
+	a.sinksMu.RLock()
+	for _, sink := range a.sinks {
+		sink.send(log)
 	}
+	a.sinksMu.RUnlock()
 
 	// Also log to structured logger
 	logEvent := a.logger.Info().
@@ -96,6 +190,11 @@ func (a *AuditLogger) GetAuditLogs(userID string, resource string, startTime, en
 	return a.store.ListAuditLogs(userID, resource, startTime, endTime, limit)
 }
 
+// QueryAuditLogs retrieves a page of audit logs, newest first
+func (a *AuditLogger) QueryAuditLogs(query *storage.AuditLogQuery) (*storage.AuditLogPage, error) {
+	return a.store.QueryAuditLogs(query)
+}
+
 // AuditMiddleware wraps an HTTP handler with audit logging
 func (a *AuditLogger) AuditMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -127,18 +226,11 @@ func (w *responseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// clientIP returns the client IP address of a request, or its remote
+// address if that is not an IP
+func (a *AuditLogger) clientIP(r *http.Request) string {
+	if ip := a.proxies.ClientIP(r); ip != nil {
+		return ip.String()
 	}
-
-	// Fall back to remote address
 	return r.RemoteAddr
 }
@@ -0,0 +1,502 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+// AuditIntegrityConfig configures the signed checkpoints of the audit log
+// hash chain
+type AuditIntegrityConfig struct {
+	CheckpointEvery    int           `json:"checkpointEvery" mapstructure:"checkpointEvery"`       // Entries between checkpoints
+	CheckpointInterval time.Duration `json:"checkpointInterval" mapstructure:"checkpointInterval"` // Also checkpoint new entries this often (0 disables)
+	SigningKeyFile     string        `json:"signingKeyFile" mapstructure:"signingKeyFile"`         // PEM PKCS #8 Ed25519 private key; checkpoints are unsigned without one
+}
+
+// DefaultAuditIntegrityConfig returns the default audit checkpoint configuration
+func DefaultAuditIntegrityConfig() *AuditIntegrityConfig {
+	return &AuditIntegrityConfig{
+		CheckpointEvery:    1000,
+		CheckpointInterval: time.Hour,
+	}
+}
+
+// Types of problems audit log verification reports
+const (
+	AuditIssueEdited             = "edited"              // Entry does not match its hash
+	AuditIssueGap                = "gap"                 // Entries missing between two entries
+	AuditIssueBrokenLink         = "broken-link"         // Entry does not chain to its predecessor
+	AuditIssueOutOfOrder         = "out-of-order"        // Sequence does not increase with time
+	AuditIssueUnchained          = "unchained"           // Entry without a hash among chained entries
+	AuditIssueTruncated          = "truncated"           // Newest entries missing
+	AuditIssueCheckpointMismatch = "checkpoint-mismatch" // Entry differs from a checkpoint
+	AuditIssueBadSignature       = "bad-signature"       // Checkpoint signature does not verify
+	AuditIssueUnrecordedExpiry   = "unrecorded-expiry"   // Oldest entries missing without a retention checkpoint
+)
+
+// maxAuditIssues is the number of issues a verification lists
+const maxAuditIssues = 100
+
+// AuditIssue is a problem found verifying the audit log
+type AuditIssue struct {
+	Type     string `json:"type"`
+	Sequence uint64 `json:"sequence"`
+	EntryID  string `json:"entryId,omitempty"`
+	Detail   string `json:"detail"`
+}
+
+// AuditVerification reports whether the audit log hash chain is intact
+type AuditVerification struct {
+	Valid               bool         `json:"valid"`
+	Entries             int          `json:"entries"`             // Chained entries checked
+	Unchained           int          `json:"unchained"`           // Entries logged before hash chaining
+	FirstSequence       uint64       `json:"firstSequence"`       // Oldest retained entry; earlier ones expired
+	LastSequence        uint64       `json:"lastSequence"`        // Newest entry
+	Checkpoints         int          `json:"checkpoints"`         // Checkpoints checked against entries
+	ExpiredCheckpoints  int          `json:"expiredCheckpoints"`  // Checkpoints of expired entries
+	UnsignedCheckpoints int          `json:"unsignedCheckpoints"` // Checkpoints without a signature
+	IssueCount          int          `json:"issueCount"`
+	Issues              []AuditIssue `json:"issues"` // The first 100 issues
+	VerifiedAt          time.Time    `json:"verifiedAt"`
+}
+
+func (v *AuditVerification) addIssue(issueType string, sequence uint64, entryID, detail string) {
+	v.IssueCount++
+	if len(v.Issues) < maxAuditIssues {
+		v.Issues = append(v.Issues, AuditIssue{Type: issueType, Sequence: sequence, EntryID: entryID, Detail: detail})
+	}
+}
+
+// auditChain extends the audit log hash chain. The store serializes appends
+// and hands each the newest stored entry, so processes sharing a store
+// extend one chain; mu only guards this process's settings and view of it.
+type auditChain struct {
+	mu       sync.Mutex
+	sequence uint64 // Newest entry appended or read, to detect truncation
+
+	checkpointEvery int // 0 disables checkpoints by count
+	signer          *auditSigner
+}
+
+// chainHead is the position new entries are chained after
+type chainHead struct {
+	sequence       uint64
+	hash           string
+	last           time.Time
+	lastCheckpoint uint64
+}
+
+// newChainHead continues the chain from the newest stored entry or, if
+// entries after it are missing or all entries expired, from the newest
+// checkpoint
+func newChainHead(newest *models.AuditLog, checkpoint *models.AuditCheckpoint) chainHead {
+	var head chainHead
+	if newest != nil {
+		head.sequence = newest.Sequence
+		head.hash = newest.Hash
+		head.last = newest.Timestamp
+	}
+	if checkpoint != nil {
+		head.lastCheckpoint = checkpoint.Sequence
+		if checkpoint.Sequence > head.sequence {
+			head.sequence = checkpoint.Sequence
+			head.hash = checkpoint.Hash
+		}
+	}
+	return head
+}
+
+// loadChainHead reads the chain's head from the store
+func loadChainHead(store storage.MetadataStore) (chainHead, error) {
+	page, err := store.QueryAuditLogs(&storage.AuditLogQuery{Limit: 1})
+	if err != nil {
+		return chainHead{}, fmt.Errorf("failed to load audit log head: %w", err)
+	}
+	checkpoints, err := store.ListAuditCheckpoints()
+	if err != nil {
+		return chainHead{}, fmt.Errorf("failed to load audit checkpoints: %w", err)
+	}
+
+	var newest *models.AuditLog
+	if len(page.Logs) > 0 {
+		newest = page.Logs[0]
+	}
+	var checkpoint *models.AuditCheckpoint
+	if len(checkpoints) > 0 {
+		checkpoint = checkpoints[len(checkpoints)-1]
+	}
+	return newChainHead(newest, checkpoint), nil
+}
+
+// link chains an entry after the head, giving it a timestamp after its
+// predecessor's so that time order is chain order
+func (h *chainHead) link(log *models.AuditLog) {
+	if !log.Timestamp.After(h.last) {
+		log.Timestamp = h.last.Add(time.Nanosecond)
+	}
+	log.Timestamp = log.Timestamp.UTC()
+	h.sequence++
+	log.Sequence = h.sequence
+	log.PrevHash = h.hash
+	log.Hash = auditLogHash(log)
+	h.hash = log.Hash
+	h.last = log.Timestamp
+}
+
+// append links an entry to the newest stored one and stores it, returning
+// the chain's head after it. An entry that fails to store is left unlinked.
+func (c *auditChain) append(store storage.MetadataStore, log *models.AuditLog) (chainHead, error) {
+	var head chainHead
+	err := store.AppendAuditLog(log, func(newest *models.AuditLog, checkpoint *models.AuditCheckpoint) error {
+		head = newChainHead(newest, checkpoint)
+		head.link(log)
+		return nil
+	})
+	if err != nil {
+		log.Sequence, log.PrevHash, log.Hash = 0, "", ""
+		return chainHead{}, err
+	}
+	c.observe(head.sequence)
+	return head, nil
+}
+
+// observe records that the chain reached sequence
+func (c *auditChain) observe(sequence uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sequence > c.sequence {
+		c.sequence = sequence
+	}
+}
+
+// dueCheckpoint reports whether a checkpoint by count is due at head
+func (c *auditChain) dueCheckpoint(head chainHead) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.checkpointEvery > 0 && head.sequence-head.lastCheckpoint >= uint64(c.checkpointEvery)
+}
+
+// checkpoint records head if entries were appended since the last
+// checkpoint
+func (c *auditChain) checkpoint(store storage.MetadataStore, head chainHead) (*models.AuditCheckpoint, error) {
+	if head.sequence == 0 || head.sequence == head.lastCheckpoint {
+		return nil, nil
+	}
+	checkpoint := &models.AuditCheckpoint{
+		ID:        fmt.Sprintf("%020d", head.sequence),
+		Sequence:  head.sequence,
+		Hash:      head.hash,
+		Timestamp: time.Now().UTC(),
+	}
+	c.mu.Lock()
+	signer := c.signer
+	c.mu.Unlock()
+	if signer != nil {
+		signer.sign(checkpoint)
+	}
+	if err := store.StoreAuditCheckpoint(checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to store audit checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// retentionCheckpoint records the newest chained entry among expiring ones,
+// which the oldest retained entry chains to. It returns nil if none of them
+// were chained.
+func (c *auditChain) retentionCheckpoint(logs []*models.AuditLog) *models.AuditCheckpoint {
+	var newest *models.AuditLog
+	for _, log := range logs {
+		if log.Hash != "" && (newest == nil || log.Sequence > newest.Sequence) {
+			newest = log
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+	checkpoint := &models.AuditCheckpoint{
+		ID:        fmt.Sprintf("%020d-retention", newest.Sequence),
+		Sequence:  newest.Sequence,
+		Hash:      newest.Hash,
+		Timestamp: time.Now().UTC(),
+		Retention: true,
+	}
+	c.mu.Lock()
+	signer := c.signer
+	c.mu.Unlock()
+	if signer != nil {
+		signer.sign(checkpoint)
+	}
+	return checkpoint
+}
+
+// auditLogHash returns the SHA-256 hash of an entry's JSON without its hash
+func auditLogHash(log *models.AuditLog) string {
+	unhashed := *log
+	unhashed.Hash = ""
+	data, _ := json.Marshal(&unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditSigner signs audit checkpoints with an Ed25519 key
+type auditSigner struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// loadAuditSigner reads a PEM PKCS #8 Ed25519 private key
+func loadAuditSigner(path string) (*auditSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("audit signing key %s is not PEM", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("audit signing key must be an Ed25519 key")
+	}
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &auditSigner{key: key, keyID: hex.EncodeToString(sum[:8])}, nil
+}
+
+func (s *auditSigner) sign(checkpoint *models.AuditCheckpoint) {
+	checkpoint.KeyID = s.keyID
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, checkpointMessage(checkpoint)))
+}
+
+// verify reports whether a checkpoint was signed by this key
+func (s *auditSigner) verify(checkpoint *models.AuditCheckpoint) bool {
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil || checkpoint.KeyID != s.keyID {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), checkpointMessage(checkpoint), signature)
+}
+
+// checkpointMessage is what a checkpoint's signature signs
+func checkpointMessage(checkpoint *models.AuditCheckpoint) []byte {
+	kind := "checkpoint"
+	if checkpoint.Retention {
+		kind = "retention"
+	}
+	return []byte(fmt.Sprintf("astore audit %s\n%d\n%s\n%s\n",
+		kind, checkpoint.Sequence, checkpoint.Hash, checkpoint.Timestamp.UTC().Format(time.RFC3339Nano)))
+}
+
+// ConfigureCheckpoints enables signed checkpoints of the hash chain
+func (a *AuditLogger) ConfigureCheckpoints(config *AuditIntegrityConfig) error {
+	if config.CheckpointEvery < 0 || config.CheckpointInterval < 0 {
+		return fmt.Errorf("audit checkpoint intervals must not be negative")
+	}
+	var signer *auditSigner
+	if config.SigningKeyFile != "" {
+		var err error
+		signer, err = loadAuditSigner(config.SigningKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.chain.mu.Lock()
+	defer a.chain.mu.Unlock()
+	a.chain.checkpointEvery = config.CheckpointEvery
+	a.chain.signer = signer
+	a.checkpointInterval = config.CheckpointInterval
+	return nil
+}
+
+// Checkpoint records the hash chain's head, if entries were logged since
+// the last checkpoint. It returns nil if there was nothing to record.
+func (a *AuditLogger) Checkpoint() (*models.AuditCheckpoint, error) {
+	head, err := loadChainHead(a.store)
+	if err != nil {
+		return nil, err
+	}
+	a.chain.observe(head.sequence)
+	return a.chain.checkpoint(a.store, head)
+}
+
+// RunCheckpoints records a checkpoint every checkpoint interval until the
+// context is cancelled, and a last one when it is
+func (a *AuditLogger) RunCheckpoints(ctx context.Context) {
+	if a.checkpointInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(a.checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if _, err := a.Checkpoint(); err != nil {
+				a.logger.Error().Err(err).Msg("failed to record audit checkpoint")
+			}
+			return
+		case <-ticker.C:
+			if _, err := a.Checkpoint(); err != nil {
+				a.logger.Error().Err(err).Msg("failed to record audit checkpoint")
+			}
+		}
+	}
+}
+
+// VerifyChain checks every stored entry against its hash and its
+// predecessor, and the chain against the stored checkpoints. Entries that
+// expired through retention are not reported: the oldest retained entry must
+// chain to the newest retention checkpoint, or start the chain if there is
+// none. With a signing key only signed retention checkpoints count. Entries
+// logged before hash chaining are counted but cannot be verified.
+func (a *AuditLogger) VerifyChain() (*AuditVerification, error) {
+	loaded, err := loadChainHead(a.store)
+	if err != nil {
+		return nil, err
+	}
+	a.chain.observe(loaded.sequence)
+	a.chain.mu.Lock()
+	head := a.chain.sequence
+	signer := a.chain.signer
+	a.chain.mu.Unlock()
+
+	checkpoints, err := a.store.ListAuditCheckpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+	entryHashes := make(map[uint64]string, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		entryHashes[checkpoint.Sequence] = ""
+	}
+
+	result := &AuditVerification{Issues: []AuditIssue{}, VerifiedAt: time.Now().UTC()}
+
+	// Walk newest first, checking each entry against the newer one
+	var newer *models.AuditLog
+	var unchained []*models.AuditLog // Unchained entries since the last chained one
+	query := &storage.AuditLogQuery{Limit: 1000}
+	for {
+		page, err := a.store.QueryAuditLogs(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit logs: %w", err)
+		}
+		for _, entry := range page.Logs {
+			if entry.Hash == "" {
+				result.Unchained++
+				unchained = append(unchained, entry)
+				continue
+			}
+			// Entries without a hash are only expected before the chain began
+			for _, inserted := range unchained {
+				result.Unchained--
+				result.addIssue(AuditIssueUnchained, entry.Sequence, inserted.ID, "entry without a hash inside the chain")
+			}
+			unchained = nil
+
+			result.Entries++
+			if auditLogHash(entry) != entry.Hash {
+				result.addIssue(AuditIssueEdited, entry.Sequence, entry.ID, "entry does not match its hash")
+			}
+			if _, ok := entryHashes[entry.Sequence]; ok {
+				entryHashes[entry.Sequence] = entry.Hash
+			}
+
+			if newer == nil {
+				result.LastSequence = entry.Sequence
+			} else {
+				switch {
+				case newer.Sequence <= entry.Sequence:
+					result.addIssue(AuditIssueOutOfOrder, newer.Sequence, newer.ID,
+						fmt.Sprintf("sequence %d logged after sequence %d", newer.Sequence, entry.Sequence))
+				case newer.Sequence != entry.Sequence+1:
+					result.addIssue(AuditIssueGap, entry.Sequence+1, "",
+						fmt.Sprintf("entries %d to %d are missing", entry.Sequence+1, newer.Sequence-1))
+				case newer.PrevHash != entry.Hash:
+					result.addIssue(AuditIssueBrokenLink, newer.Sequence, newer.ID, "entry does not chain to its predecessor")
+				}
+			}
+			result.FirstSequence = entry.Sequence
+			newer = entry
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	// Retention removes the oldest entries, so with some entries left the
+	// newest ones can only be missing if they were removed
+	if result.Entries > 0 && head > result.LastSequence {
+		result.addIssue(AuditIssueTruncated, result.LastSequence+1, "",
+			fmt.Sprintf("entries %d to %d were logged but are missing", result.LastSequence+1, head))
+	}
+
+	var watermark *models.AuditCheckpoint // Newest trusted retention checkpoint
+	for _, checkpoint := range checkpoints {
+		trusted := signer == nil
+		switch {
+		case checkpoint.Signature == "":
+			result.UnsignedCheckpoints++
+		case signer != nil && !signer.verify(checkpoint):
+			result.addIssue(AuditIssueBadSignature, checkpoint.Sequence, "", "checkpoint signature does not verify with the configured key")
+		default:
+			trusted = true
+		}
+		if checkpoint.Retention && trusted && (watermark == nil || checkpoint.Sequence > watermark.Sequence) {
+			watermark = checkpoint
+		}
+
+		switch {
+		case result.Entries == 0 || checkpoint.Sequence < result.FirstSequence:
+			result.ExpiredCheckpoints++
+		case checkpoint.Sequence > result.LastSequence:
+			// The missing entries are already reported as truncated
+		default:
+			hash := entryHashes[checkpoint.Sequence]
+			if hash == "" {
+				// The entry is missing; its gap is already reported
+				continue
+			}
+			result.Checkpoints++
+			if hash != checkpoint.Hash {
+				result.addIssue(AuditIssueCheckpointMismatch, checkpoint.Sequence, "", "entry hash differs from the checkpoint")
+			}
+		}
+	}
+
+	// Only retention may remove the oldest entries, and it records where
+	if oldest := newer; oldest != nil {
+		var sequence uint64 = 1
+		var prevHash string
+		if watermark != nil {
+			sequence, prevHash = watermark.Sequence+1, watermark.Hash
+		}
+		switch {
+		case oldest.Sequence > sequence:
+			result.addIssue(AuditIssueUnrecordedExpiry, sequence, "",
+				fmt.Sprintf("entries %d to %d are missing without a retention checkpoint", sequence, oldest.Sequence-1))
+		case oldest.Sequence == sequence && oldest.PrevHash != prevHash:
+			result.addIssue(AuditIssueBrokenLink, oldest.Sequence, oldest.ID, "oldest entry does not chain to the retention checkpoint")
+		}
+	}
+
+	result.Valid = result.IssueCount == 0
+	return result, nil
+}
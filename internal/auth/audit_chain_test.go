@@ -0,0 +1,283 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// newChainedAuditLog logs count requests to an audit logger checkpointing
+// every checkpointEvery entries with a fresh signing key
+func newChainedAuditLog(t *testing.T, count, checkpointEvery int) (*auth.AuditLogger, storage.MetadataStore, *auth.AuditIntegrityConfig) {
+	_, store := newTokenManager(t)
+	config := &auth.AuditIntegrityConfig{CheckpointEvery: checkpointEvery, SigningKeyFile: writeSigningKey(t)}
+	audit := auth.NewAuditLogger(store, test.NewTestLogger(t), true)
+	test.AssertNoError(t, audit.ConfigureCheckpoints(config), "configuring checkpoints")
+	for i := 0; i < count; i++ {
+		audit.LogAccess(httptest.NewRequest("GET", "/s3/releases/app.jar", nil), http.StatusOK, nil, nil)
+	}
+	return audit, store, config
+}
+
+// writeSigningKey writes a new Ed25519 private key as PEM PKCS #8
+func writeSigningKey(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	test.AssertNoError(t, err, "generating key")
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	test.AssertNoError(t, err, "encoding key")
+	path := filepath.Join(t.TempDir(), "audit-key.pem")
+	test.AssertNoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600), "writing key")
+	return path
+}
+
+// oldestFirst returns all stored audit entries, oldest first
+func oldestFirst(t *testing.T, store storage.MetadataStore) []*models.AuditLog {
+	page, err := store.QueryAuditLogs(&storage.AuditLogQuery{})
+	test.AssertNoError(t, err, "querying audit logs")
+	logs := page.Logs
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return logs
+}
+
+// verifyCopy copies entries, except those skip rejects, and all
+// checkpoints into a new store and verifies the copy
+func verifyCopy(t *testing.T, store storage.MetadataStore, config *auth.AuditIntegrityConfig, skip func(*models.AuditLog) bool) *auth.AuditVerification {
+	_, copied := newTokenManager(t)
+	for _, entry := range oldestFirst(t, store) {
+		if !skip(entry) {
+			test.AssertNoError(t, copied.StoreAuditLog(entry), "copying audit log")
+		}
+	}
+	checkpoints, err := store.ListAuditCheckpoints()
+	test.AssertNoError(t, err, "listing checkpoints")
+	for _, checkpoint := range checkpoints {
+		test.AssertNoError(t, copied.StoreAuditCheckpoint(checkpoint), "copying checkpoint")
+	}
+
+	audit := auth.NewAuditLogger(copied, test.NewTestLogger(t), true)
+	test.AssertNoError(t, audit.ConfigureCheckpoints(config), "configuring checkpoints")
+	verification, err := audit.VerifyChain()
+	test.AssertNoError(t, err, "verifying copy")
+	return verification
+}
+
+func TestAuditChain(t *testing.T) {
+	t.Run("Intact chain verifies", func(t *testing.T) {
+		// Given: Ten entries checkpointed every four
+		audit, store, _ := newChainedAuditLog(t, 10, 4)
+
+		// When: Verifying the chain
+		verification, err := audit.VerifyChain()
+
+		// Then: Every entry links to its predecessor and matches the checkpoints
+		test.AssertNoError(t, err, "verifying")
+		test.AssertTrue(t, verification.Valid, "chain is valid")
+		test.AssertEqual(t, 10, verification.Entries, "entries checked")
+		test.AssertEqual(t, uint64(1), verification.FirstSequence, "first sequence")
+		test.AssertEqual(t, uint64(10), verification.LastSequence, "last sequence")
+		test.AssertEqual(t, 2, verification.Checkpoints, "checkpoints checked")
+		test.AssertEqual(t, 0, verification.UnsignedCheckpoints, "checkpoints are signed")
+		logs := oldestFirst(t, store)
+		test.AssertEqual(t, logs[0].Hash, logs[1].PrevHash, "entries are linked")
+	})
+
+	t.Run("Edited entries are detected", func(t *testing.T) {
+		// Given: An entry whose status was rewritten in the store
+		audit, store, _ := newChainedAuditLog(t, 5, 0)
+		edited := oldestFirst(t, store)[2]
+		edited.Status = http.StatusForbidden
+		test.AssertNoError(t, store.StoreAuditLog(edited), "rewriting entry")
+
+		// When: Verifying the chain
+		verification, err := audit.VerifyChain()
+
+		// Then: The edit is reported
+		test.AssertNoError(t, err, "verifying")
+		test.AssertFalse(t, verification.Valid, "chain is invalid")
+		test.AssertEqual(t, auth.AuditIssueEdited, verification.Issues[0].Type, "issue type")
+		test.AssertEqual(t, edited.ID, verification.Issues[0].EntryID, "edited entry")
+	})
+
+	t.Run("Deleted entries are detected", func(t *testing.T) {
+		// Given: Entries checkpointed every four, copied without the 3rd and the last four
+		_, store, config := newChainedAuditLog(t, 10, 4)
+
+		// When: Verifying the copy
+		verification := verifyCopy(t, store, config, func(entry *models.AuditLog) bool {
+			return entry.Sequence == 3 || entry.Sequence > 6
+		})
+
+		// Then: The gap and the entries missing before the last checkpoint are reported
+		test.AssertFalse(t, verification.Valid, "chain is invalid")
+		test.AssertEqual(t, 2, verification.IssueCount, "issues")
+		test.AssertEqual(t, auth.AuditIssueGap, verification.Issues[0].Type, "gap")
+		test.AssertEqual(t, uint64(3), verification.Issues[0].Sequence, "missing entry")
+		test.AssertEqual(t, auth.AuditIssueTruncated, verification.Issues[1].Type, "truncation")
+		test.AssertEqual(t, uint64(7), verification.Issues[1].Sequence, "first missing entry")
+	})
+
+	t.Run("Expired entries are not reported", func(t *testing.T) {
+		// Given: Entries whose oldest three expired through retention
+		audit, store, _ := newChainedAuditLog(t, 6, 2)
+		retention, err := auth.NewAuditRetention(&auth.RetentionConfig{MaxEntries: 3}, store, nil, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "creating retention")
+		retention.SetAuditLogger(audit)
+		result, err := retention.EnforceNow(context.Background())
+		test.AssertNoError(t, err, "enforcing retention")
+		test.AssertEqual(t, 3, result.Expired, "expired entries")
+
+		// When: Verifying the chain
+		verification, err := audit.VerifyChain()
+
+		// Then: The chain starts at the oldest retained entry
+		test.AssertNoError(t, err, "verifying")
+		test.AssertTrue(t, verification.Valid, "chain is valid")
+		test.AssertEqual(t, uint64(4), verification.FirstSequence, "first sequence")
+		test.AssertEqual(t, 2, verification.ExpiredCheckpoints, "expired checkpoint and retention checkpoint")
+	})
+
+	t.Run("Oldest entries removed outside retention are detected", func(t *testing.T) {
+		// Given: Entries whose oldest were removed, once after a retention run
+		// and once with an unsigned retention checkpoint
+		audit, store, _ := newChainedAuditLog(t, 8, 2)
+		retention, err := auth.NewAuditRetention(&auth.RetentionConfig{MaxEntries: 6}, store, nil, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "creating retention")
+		retention.SetAuditLogger(audit)
+		_, err = retention.EnforceNow(context.Background())
+		test.AssertNoError(t, err, "enforcing retention")
+		_, err = store.ExpireAuditLogs(oldestFirst(t, store)[2].Timestamp, 0, func(logs []*models.AuditLog) (*models.AuditCheckpoint, error) {
+			newest := logs[len(logs)-1]
+			return &models.AuditCheckpoint{ID: "forged", Sequence: newest.Sequence, Hash: newest.Hash, Retention: true}, nil
+		})
+		test.AssertNoError(t, err, "removing entries")
+
+		// When: Verifying the chain
+		verification, err := audit.VerifyChain()
+
+		// Then: The removal is reported from the last signed retention checkpoint
+		test.AssertNoError(t, err, "verifying")
+		test.AssertFalse(t, verification.Valid, "chain is invalid")
+		test.AssertEqual(t, 1, verification.IssueCount, "issues")
+		test.AssertEqual(t, auth.AuditIssueUnrecordedExpiry, verification.Issues[0].Type, "issue type")
+		test.AssertEqual(t, uint64(3), verification.Issues[0].Sequence, "first removed entry")
+	})
+
+	t.Run("Oldest entries with stripped hashes are detected", func(t *testing.T) {
+		// Given: A copy whose oldest two entries lost their hashes, as if
+		// logged before hash chaining
+		_, store, config := newChainedAuditLog(t, 5, 0)
+		_, copied := newTokenManager(t)
+		for i, entry := range oldestFirst(t, store) {
+			if i < 2 {
+				entry.Sequence, entry.PrevHash, entry.Hash = 0, "", ""
+			}
+			test.AssertNoError(t, copied.StoreAuditLog(entry), "copying audit log")
+		}
+		audit := auth.NewAuditLogger(copied, test.NewTestLogger(t), true)
+		test.AssertNoError(t, audit.ConfigureCheckpoints(config), "configuring checkpoints")
+
+		// When: Verifying the copy
+		verification, err := audit.VerifyChain()
+
+		// Then: The chain does not start where it began
+		test.AssertNoError(t, err, "verifying")
+		test.AssertFalse(t, verification.Valid, "chain is invalid")
+		test.AssertEqual(t, auth.AuditIssueUnrecordedExpiry, verification.Issues[0].Type, "issue type")
+	})
+
+	t.Run("Forged checkpoints are detected", func(t *testing.T) {
+		// Given: A checkpoint re-signed with another key
+		_, store, config := newChainedAuditLog(t, 4, 4)
+		forger := auth.NewAuditLogger(store, test.NewTestLogger(t), true)
+		test.AssertNoError(t, forger.ConfigureCheckpoints(&auth.AuditIntegrityConfig{SigningKeyFile: writeSigningKey(t)}), "configuring forger")
+		forger.LogAccess(httptest.NewRequest("GET", "/s3/releases/app.jar", nil), http.StatusOK, nil, nil)
+		_, err := forger.Checkpoint()
+		test.AssertNoError(t, err, "forging checkpoint")
+
+		// When: Verifying with the real key
+		verification := verifyCopy(t, store, config, func(*models.AuditLog) bool { return false })
+
+		// Then: The forged signature is reported
+		test.AssertFalse(t, verification.Valid, "chain is invalid")
+		test.AssertEqual(t, auth.AuditIssueBadSignature, verification.Issues[0].Type, "issue type")
+		test.AssertEqual(t, uint64(5), verification.Issues[0].Sequence, "forged checkpoint")
+	})
+
+	t.Run("Loggers sharing a store extend one chain", func(t *testing.T) {
+		// Given: Two audit loggers, as on two replicas, sharing a store
+		audit, store, config := newChainedAuditLog(t, 0, 4)
+		replica := auth.NewAuditLogger(store, test.NewTestLogger(t), true)
+		test.AssertNoError(t, replica.ConfigureCheckpoints(config), "configuring replica")
+
+		// When: Both log entries concurrently
+		var wg sync.WaitGroup
+		for _, logger := range []*auth.AuditLogger{audit, replica} {
+			wg.Add(1)
+			go func(logger *auth.AuditLogger) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					logger.LogAccess(httptest.NewRequest("GET", "/s3/releases/app.jar", nil), http.StatusOK, nil, nil)
+				}
+			}(logger)
+		}
+		wg.Wait()
+		verification, err := replica.VerifyChain()
+
+		// Then: The entries form one intact chain
+		test.AssertNoError(t, err, "verifying")
+		test.AssertTrue(t, verification.Valid, "chain is valid")
+		test.AssertEqual(t, 40, verification.Entries, "chained entries")
+		test.AssertEqual(t, uint64(40), verification.LastSequence, "last sequence")
+	})
+
+	t.Run("Entries are not linked when the chain cannot be read", func(t *testing.T) {
+		// Given: A store that fails the second append
+		_, store := newTokenManager(t)
+		flaky := &flakyAuditStore{MetadataStore: store, failAt: 2}
+		audit := auth.NewAuditLogger(flaky, test.NewTestLogger(t), true)
+
+		// When: Logging three entries
+		for i := 0; i < 3; i++ {
+			audit.LogAccess(httptest.NewRequest("GET", "/s3/releases/app.jar", nil), http.StatusOK, nil, nil)
+		}
+		verification, err := audit.VerifyChain()
+
+		// Then: The stored entries chain without a gap
+		test.AssertNoError(t, err, "verifying")
+		test.AssertTrue(t, verification.Valid, "chain is valid")
+		test.AssertEqual(t, 2, verification.Entries, "chained entries")
+		test.AssertEqual(t, uint64(2), verification.LastSequence, "last sequence")
+	})
+}
+
+// flakyAuditStore fails one audit log append, as an unreachable database
+// would
+type flakyAuditStore struct {
+	storage.MetadataStore
+	appends int
+	failAt  int
+}
+
+func (f *flakyAuditStore) AppendAuditLog(log *models.AuditLog, link func(newest *models.AuditLog, checkpoint *models.AuditCheckpoint) error) error {
+	f.appends++
+	if f.appends == f.failAt {
+		return errors.New("database unavailable")
+	}
+	return f.MetadataStore.AppendAuditLog(log, link)
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// RetentionConfig controls how long audit log entries are kept
+type RetentionConfig struct {
+	Enabled    bool           `json:"enabled" mapstructure:"enabled"`
+	Interval   time.Duration  `json:"interval" mapstructure:"interval"`
+	MaxAge     time.Duration  `json:"maxAge" mapstructure:"maxAge"`         // Expire entries older than this (0 keeps them)
+	MaxEntries int            `json:"maxEntries" mapstructure:"maxEntries"` // Expire the oldest entries beyond this count (0 is unlimited)
+	BatchSize  int            `json:"batchSize" mapstructure:"batchSize"`   // Entries expired, and archived, per transaction
+	Archive    *ArchiveConfig `json:"archive" mapstructure:"archive"`       // Archive expired entries before removing them
+}
+
+// ArchiveConfig selects where expired audit log entries are archived
+type ArchiveConfig struct {
+	Backend *storage.BackendConfig `json:"backend" mapstructure:"backend"`
+	Bucket  string                 `json:"bucket" mapstructure:"bucket"`
+	Prefix  string                 `json:"prefix" mapstructure:"prefix"`
+}
+
+// DefaultRetentionConfig returns the default audit log retention configuration
+func DefaultRetentionConfig() *RetentionConfig {
+	return &RetentionConfig{
+		Enabled:   false,
+		Interval:  time.Hour,
+		MaxAge:    90 * 24 * time.Hour,
+		BatchSize: 1000,
+	}
+}
+
+// RetentionResult summarises one retention run
+type RetentionResult struct {
+	Expired  int       `json:"expired"`
+	Archives []string  `json:"archives,omitempty"` // Backend keys written
+	RanAt    time.Time `json:"ranAt"`
+}
+
+// AuditRetention expires audit log entries by age and count, archiving them
+// to a storage backend as gzip-compressed JSON lines. Each removal records a
+// retention checkpoint, so verification can tell expiry from deletion.
+type AuditRetention struct {
+	config  *RetentionConfig
+	store   storage.MetadataStore
+	backend storage.Backend // nil when expired entries are not archived
+	chain   *auditChain     // Signs retention checkpoints with its key
+	logger  log.Logger
+	mu      sync.Mutex // Serializes runs
+}
+
+// NewAuditRetention creates an audit log retention job. backend may be nil
+// if config.Archive is not set.
+func NewAuditRetention(config *RetentionConfig, store storage.MetadataStore, backend storage.Backend, logger log.Logger) (*AuditRetention, error) {
+	defaults := DefaultRetentionConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.MaxAge < 0 || config.MaxEntries < 0 {
+		return nil, fmt.Errorf("audit retention limits must not be negative")
+	}
+	if config.Archive != nil {
+		if backend == nil {
+			return nil, fmt.Errorf("audit log archival requires a backend")
+		}
+		if config.Archive.Bucket == "" {
+			config.Archive.Bucket = "astore-audit"
+		}
+	}
+
+	return &AuditRetention{
+		config:  config,
+		store:   store,
+		backend: backend,
+		chain:   &auditChain{},
+		logger:  logger,
+	}, nil
+}
+
+// SetAuditLogger signs retention checkpoints with the audit logger's
+// checkpoint key. Without one they are unsigned.
+func (r *AuditRetention) SetAuditLogger(audit *AuditLogger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chain = &audit.chain
+}
+
+// Run enforces retention every interval until the context is cancelled
+func (r *AuditRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	r.logger.Info().Dur("interval", r.config.Interval).Dur("maxAge", r.config.MaxAge).Int("maxEntries", r.config.MaxEntries).Msg("audit log retention started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info().Msg("audit log retention stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.EnforceNow(ctx); err != nil {
+				r.logger.Error().Err(err).Msg("audit log retention failed")
+			}
+		}
+	}
+}
+
+// EnforceNow expires every entry outside the retention limits
+func (r *AuditRetention) EnforceNow(ctx context.Context) (*RetentionResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &RetentionResult{RanAt: time.Now().UTC()}
+
+	if r.config.MaxAge > 0 {
+		cutoff := result.RanAt.Add(-r.config.MaxAge)
+		for {
+			removed, err := r.expire(ctx, cutoff, r.config.BatchSize, result)
+			if err != nil {
+				return result, err
+			}
+			if removed < r.config.BatchSize {
+				break
+			}
+		}
+	}
+
+	if r.config.MaxEntries > 0 {
+		count, err := r.store.CountAuditLogs()
+		if err != nil {
+			return result, err
+		}
+		for excess := count - r.config.MaxEntries; excess > 0; {
+			batch := r.config.BatchSize
+			if excess < batch {
+				batch = excess
+			}
+			removed, err := r.expire(ctx, time.Time{}, batch, result)
+			if err != nil {
+				return result, err
+			}
+			if removed == 0 {
+				break
+			}
+			excess -= removed
+		}
+	}
+
+	if result.Expired > 0 {
+		r.logger.Info().Int("expired", result.Expired).Int("archives", len(result.Archives)).Msg("audit log entries expired")
+	}
+	return result, nil
+}
+
+// expire removes one batch, archiving it first if configured, and records
+// where the chain was cut
+func (r *AuditRetention) expire(ctx context.Context, before time.Time, limit int, result *RetentionResult) (int, error) {
+	expired := func(logs []*models.AuditLog) (*models.AuditCheckpoint, error) {
+		if r.config.Archive != nil {
+			key, err := r.writeArchive(ctx, logs)
+			if err != nil {
+				return nil, err
+			}
+			result.Archives = append(result.Archives, key)
+		}
+		return r.chain.retentionCheckpoint(logs), nil
+	}
+
+	removed, err := r.store.ExpireAuditLogs(before, limit, expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire audit logs: %w", err)
+	}
+	result.Expired += removed
+	return removed, nil
+}
+
+// writeArchive uploads logs, oldest first, as one gzip-compressed JSON-lines object
+func (r *AuditRetention) writeArchive(ctx context.Context, logs []*models.AuditLog) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, entry := range logs {
+		if err := encoder.Encode(entry); err != nil {
+			return "", fmt.Errorf("failed to encode audit log: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	bucket := r.config.Archive.Bucket
+	exists, err := r.backend.BucketExists(ctx, bucket)
+	if err != nil {
+		return "", fmt.Errorf("failed to check archive bucket: %w", err)
+	}
+	if !exists {
+		if err := r.backend.CreateBucket(ctx, bucket); err != nil {
+			return "", fmt.Errorf("failed to create archive bucket: %w", err)
+		}
+	}
+
+	// Named by the range of entries it holds, so archives list chronologically
+	const layout = "20060102T150405.000000000Z"
+	key := r.config.Archive.Prefix + "audit-" + logs[0].Timestamp.UTC().Format(layout) + "-" +
+		logs[len(logs)-1].Timestamp.UTC().Format(layout) + ".jsonl.gz"
+	size := int64(buf.Len())
+	if _, err := r.backend.WriteObject(ctx, bucket, key, &buf, size); err != nil {
+		return "", fmt.Errorf("failed to upload audit log archive: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package auth_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestAuditRetention(t *testing.T) {
+	t.Run("Expired entries are archived before removal", func(t *testing.T) {
+		// Given: Three old and two recent audit log entries, archived in batches of two
+		store := test.NewTestMetadataStore(t)
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			age := time.Minute
+			if i < 3 {
+				age = 100 * 24 * time.Hour
+			}
+			storeAuditLog(t, store, fmt.Sprintf("log-%d", i), now.Add(-age).Add(time.Duration(i)*time.Millisecond))
+		}
+		backend := storage.NewMemoryBackend(nil)
+		config := &auth.RetentionConfig{
+			MaxAge:    90 * 24 * time.Hour,
+			BatchSize: 2,
+			Archive:   &auth.ArchiveConfig{Bucket: "archive", Prefix: "audit/"},
+		}
+		retention, err := auth.NewAuditRetention(config, store, backend, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create retention")
+
+		// When: Enforcing retention
+		result, err := retention.EnforceNow(context.Background())
+
+		// Then: The old entries are gone and archived as compressed JSON lines
+		test.AssertNoError(t, err, "enforce retention")
+		test.AssertEqual(t, 3, result.Expired, "expired entries")
+		test.AssertEqual(t, 2, len(result.Archives), "archive objects")
+		count, err := store.CountAuditLogs()
+		test.AssertNoError(t, err, "count")
+		test.AssertEqual(t, 2, count, "entries kept")
+
+		lines := 0
+		for _, key := range result.Archives {
+			reader, err := backend.ReadObject(context.Background(), "archive", key)
+			test.AssertNoError(t, err, "read archive")
+			gz, err := gzip.NewReader(reader)
+			test.AssertNoError(t, err, "decompress archive")
+			scanner := bufio.NewScanner(gz)
+			for scanner.Scan() {
+				lines++
+			}
+			reader.Close()
+		}
+		test.AssertEqual(t, 3, lines, "archived entries")
+	})
+
+	t.Run("Oldest entries beyond the size limit are removed", func(t *testing.T) {
+		// Given: Five recent entries and a limit of three
+		store := test.NewTestMetadataStore(t)
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			storeAuditLog(t, store, fmt.Sprintf("log-%d", i), now.Add(time.Duration(i)*time.Millisecond))
+		}
+		retention, err := auth.NewAuditRetention(&auth.RetentionConfig{MaxEntries: 3}, store, nil, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create retention")
+
+		// When: Enforcing retention
+		result, err := retention.EnforceNow(context.Background())
+
+		// Then: Only the newest three remain
+		test.AssertNoError(t, err, "enforce retention")
+		test.AssertEqual(t, 2, result.Expired, "expired entries")
+		logs, err := store.ListAuditLogs("", "", time.Time{}, time.Time{}, 0)
+		test.AssertNoError(t, err, "list logs")
+		test.AssertEqual(t, 3, len(logs), "entries kept")
+		test.AssertEqual(t, "log-2", logs[2].ID, "oldest kept entry")
+	})
+
+	t.Run("Archival requires a backend", func(t *testing.T) {
+		// Given: An archive configuration without a backend
+		config := &auth.RetentionConfig{Archive: &auth.ArchiveConfig{Bucket: "archive"}}
+
+		// When: Creating the retention job
+		_, err := auth.NewAuditRetention(config, test.NewTestMetadataStore(t), nil, test.NewTestLogger(t))
+
+		// Then: It is rejected
+		test.AssertError(t, err, "missing backend")
+	})
+}
+
+func storeAuditLog(t *testing.T, store storage.MetadataStore, id string, at time.Time) {
+	t.Helper()
+	log := &models.AuditLog{ID: id, Timestamp: at, UserID: "alice", Resource: "bucket/app"}
+	test.AssertNoError(t, store.StoreAuditLog(log), "store audit log")
+}
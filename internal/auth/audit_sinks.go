@@ -0,0 +1,440 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Audit sink types and formats
+const (
+	AuditSinkFile    = "file"
+	AuditSinkSyslog  = "syslog"
+	AuditSinkWebhook = "webhook"
+
+	AuditFormatJSON = "json"
+	AuditFormatCEF  = "cef"
+)
+
+// AuditSinkConfig configures an external destination audit entries are
+// streamed to
+type AuditSinkConfig struct {
+	Type   string `json:"type" mapstructure:"type"`     // file, syslog or webhook
+	Format string `json:"format" mapstructure:"format"` // json (default) or cef
+
+	Path string `json:"path" mapstructure:"path"` // file: appended to
+
+	Network string `json:"network" mapstructure:"network"` // syslog: udp (default), tcp or unix
+	Address string `json:"address" mapstructure:"address"` // syslog: host:port or socket path
+	Tag     string `json:"tag" mapstructure:"tag"`         // syslog: app name (default astore)
+
+	URL     string            `json:"url" mapstructure:"url"`         // webhook: batches are POSTed as lines
+	Headers map[string]string `json:"headers" mapstructure:"headers"` // webhook: e.g. Authorization
+	Timeout time.Duration     `json:"timeout" mapstructure:"timeout"` // webhook: per request (default 10s)
+
+	BufferSize    int           `json:"bufferSize" mapstructure:"bufferSize"`       // Entries queued before new ones are dropped (default 1000)
+	BatchSize     int           `json:"batchSize" mapstructure:"batchSize"`         // Entries written at once (default 100)
+	FlushInterval time.Duration `json:"flushInterval" mapstructure:"flushInterval"` // Longest an entry waits for a batch (default 1s)
+}
+
+// AuditSink writes formatted audit entries to an external destination
+type AuditSink interface {
+	// WriteEntries writes a batch of formatted entries, one per element
+	WriteEntries(entries [][]byte) error
+	Close() error
+	String() string
+}
+
+// NewAuditSink creates the sink a configuration describes
+func NewAuditSink(config *AuditSinkConfig) (AuditSink, error) {
+	switch config.Type {
+	case AuditSinkFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("file audit sink requires a path")
+		}
+		file, err := os.OpenFile(config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit sink file: %w", err)
+		}
+		return &fileSink{file: file}, nil
+	case AuditSinkSyslog:
+		if config.Address == "" {
+			return nil, fmt.Errorf("syslog audit sink requires an address")
+		}
+		network := config.Network
+		if network == "" {
+			network = "udp"
+		}
+		tag := config.Tag
+		if tag == "" {
+			tag = "astore"
+		}
+		hostname, _ := os.Hostname()
+		if hostname == "" {
+			hostname = "-"
+		}
+		return &syslogSink{network: network, address: config.Address, tag: tag, hostname: hostname}, nil
+	case AuditSinkWebhook:
+		if config.URL == "" {
+			return nil, fmt.Errorf("webhook audit sink requires a URL")
+		}
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		contentType := "application/x-ndjson"
+		if config.Format == AuditFormatCEF {
+			contentType = "text/plain"
+		}
+		return &webhookSink{url: config.URL, headers: config.Headers, contentType: contentType, client: &http.Client{Timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink type %q", config.Type)
+	}
+}
+
+// AddSink streams every audit entry logged from now on to a sink, in the
+// configured format. Entries are queued and written in batches so a slow
+// sink never delays requests; entries that do not fit the queue are dropped.
+func (a *AuditLogger) AddSink(sink AuditSink, config *AuditSinkConfig) error {
+	format := config.Format
+	if format == "" {
+		format = AuditFormatJSON
+	}
+	if format != AuditFormatJSON && format != AuditFormatCEF {
+		return fmt.Errorf("unknown audit sink format %q", format)
+	}
+	bufferSize, batchSize, flushInterval := config.BufferSize, config.BatchSize, config.FlushInterval
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	stream := &sinkStream{
+		sink:          sink,
+		format:        format,
+		queue:         make(chan *models.AuditLog, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        a.logger,
+		done:          make(chan struct{}),
+	}
+	go stream.run()
+
+	a.sinksMu.Lock()
+	a.sinks = append(a.sinks, stream)
+	a.sinksMu.Unlock()
+	return nil
+}
+
+// Close writes the entries queued for sinks and closes them. Entries
+// logged afterwards are no longer streamed.
+func (a *AuditLogger) Close() error {
+	a.sinksMu.Lock()
+	sinks := a.sinks
+	a.sinks = nil
+	a.sinksMu.Unlock()
+
+	var errs []string
+	for _, stream := range sinks {
+		if err := stream.close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", stream.sink, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close audit sinks: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sinkStream queues entries for a sink and writes them in batches
+type sinkStream struct {
+	sink          AuditSink
+	format        string
+	queue         chan *models.AuditLog
+	batchSize     int
+	flushInterval time.Duration
+	logger        log.Logger
+	dropped       atomic.Int64
+	closeOnce     sync.Once
+	done          chan struct{}
+}
+
+// send queues an entry without blocking, dropping it if the queue is full
+func (s *sinkStream) send(entry *models.AuditLog) {
+	select {
+	case s.queue <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *sinkStream) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	flush := func() {
+		if dropped := s.dropped.Swap(0); dropped > 0 {
+			s.logger.Warn().Str("sink", s.sink.String()).Int64("dropped", dropped).Msg("audit sink queue full, entries dropped")
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.sink.WriteEntries(batch); err != nil {
+			s.logger.Error().Err(err).Str("sink", s.sink.String()).Int("entries", len(batch)).Msg("failed to write audit entries to sink")
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case entry, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			formatted, err := formatAuditEntry(entry, s.format)
+			if err != nil {
+				s.logger.Error().Err(err).Str("auditId", entry.ID).Msg("failed to format audit entry")
+				continue
+			}
+			batch = append(batch, formatted)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *sinkStream) close() error {
+	s.closeOnce.Do(func() { close(s.queue) })
+	<-s.done
+	return s.sink.Close()
+}
+
+// formatAuditEntry formats an entry as one JSON object or CEF line
+func formatAuditEntry(entry *models.AuditLog, format string) ([]byte, error) {
+	if format == AuditFormatCEF {
+		return []byte(formatCEF(entry)), nil
+	}
+	return json.Marshal(entry)
+}
+
+// CEF header fields of audit entries
+const (
+	cefVendor  = "Candlekeep"
+	cefProduct = "astore"
+	cefVersion = "1"
+)
+
+// formatCEF formats an entry in the ArcSight Common Event Format
+func formatCEF(entry *models.AuditLog) string {
+	name := "HTTP request"
+	outcome := strconv.Itoa(entry.Status)
+	switch entry.Metadata[auditDecision] {
+	case "allow":
+		name, outcome = "Access allowed", "allow"
+	case "deny":
+		name, outcome = "Access denied", "deny"
+	}
+	severity := 3
+	switch {
+	case entry.Status >= 500:
+		severity = 7
+	case entry.Status == http.StatusUnauthorized || entry.Status == http.StatusForbidden:
+		severity = 6
+	case entry.Status >= 400:
+		severity = 4
+	}
+
+	var ext []string
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefExtensionValue(value))
+		}
+	}
+	add("rt", strconv.FormatInt(entry.Timestamp.UnixMilli(), 10))
+	add("externalId", entry.ID)
+	add("suid", entry.UserID)
+	add("suser", entry.Username)
+	if parseHostIP(entry.IPAddress) != nil {
+		add("src", parseHostIP(entry.IPAddress).String())
+	}
+	add("requestMethod", entry.Method)
+	add("request", entry.Resource)
+	add("act", entry.Action)
+	add("outcome", outcome)
+	add("reason", entry.Error)
+	add("requestClientApplication", entry.UserAgent)
+	add("cn1Label", "status")
+	add("cn1", strconv.Itoa(entry.Status))
+	if entry.Hash != "" {
+		add("cn2Label", "sequence")
+		add("cn2", strconv.FormatUint(entry.Sequence, 10))
+		add("cs1Label", "hash")
+		add("cs1", entry.Hash)
+		add("cs2Label", "prevHash")
+		add("cs2", entry.PrevHash)
+	}
+	add("cs3Label", "decidingPolicy")
+	add("cs3", entry.Metadata[auditDecidingPolicy])
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeaderValue(cefVendor), cefHeaderValue(cefProduct), cefVersion,
+		cefHeaderValue(entry.Action), cefHeaderValue(name), severity, strings.Join(ext, " "))
+}
+
+// cefHeaderValue escapes a CEF header field
+func cefHeaderValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// cefExtensionValue escapes a CEF extension value
+func cefExtensionValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`).Replace(value)
+}
+
+// fileSink appends entries to a file, one per line
+type fileSink struct {
+	file *os.File
+}
+
+func (s *fileSink) WriteEntries(entries [][]byte) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *fileSink) String() string {
+	return "file " + s.file.Name()
+}
+
+// syslogSink sends entries as RFC 5424 syslog messages of the authpriv
+// facility, reconnecting after failures
+type syslogSink struct {
+	network  string
+	address  string
+	tag      string
+	hostname string
+	conn     net.Conn
+}
+
+// syslogPriority is facility authpriv (10) at severity informational (6)
+const syslogPriority = 10*8 + 6
+
+func (s *syslogSink) WriteEntries(entries [][]byte) error {
+	for _, entry := range entries {
+		if err := s.write(entry); err != nil {
+			// Reconnect once, e.g. after the syslog daemon restarted
+			s.closeConn()
+			if err := s.write(entry); err != nil {
+				s.closeConn()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) write(entry []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		s.conn = conn
+	}
+	message := fmt.Sprintf("<%d>1 %s %s %s - audit - %s", syslogPriority,
+		time.Now().UTC().Format(time.RFC3339Nano), s.hostname, s.tag, entry)
+	if s.network == "tcp" {
+		// Octet-counting framing (RFC 6587)
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+func (s *syslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *syslogSink) Close() error {
+	s.closeConn()
+	return nil
+}
+
+func (s *syslogSink) String() string {
+	return "syslog " + s.network + "://" + s.address
+}
+
+// webhookSink POSTs each batch of entries, one per line
+type webhookSink struct {
+	url         string
+	headers     map[string]string
+	contentType string
+	client      *http.Client
+}
+
+func (s *webhookSink) WriteEntries(entries [][]byte) error {
+	body := append(bytes.Join(entries, []byte("\n")), '\n')
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *webhookSink) String() string {
+	return "webhook " + s.url
+}
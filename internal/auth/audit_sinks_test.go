@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// logToSink logs one allowed and one denied request to a sink and closes it
+func logToSink(t *testing.T, config *auth.AuditSinkConfig) {
+	_, store := newTokenManager(t)
+	audit := auth.NewAuditLogger(store, test.NewTestLogger(t), true)
+	sink, err := auth.NewAuditSink(config)
+	test.AssertNoError(t, err, "creating sink")
+	test.AssertNoError(t, audit.AddSink(sink, config), "adding sink")
+
+	user := &models.User{ID: "user-1", Username: "dev"}
+	audit.LogAccess(httptest.NewRequest("GET", "/s3/releases/app.jar", nil), http.StatusOK, user, nil)
+	audit.LogAccess(httptest.NewRequest("PUT", "/s3/releases/app=1|2.jar", nil), http.StatusForbidden, user, nil)
+	test.AssertNoError(t, audit.Close(), "closing sinks")
+}
+
+func TestAuditSinks(t *testing.T) {
+	t.Run("File sink appends JSON lines", func(t *testing.T) {
+		// Given: A file sink
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+		// When: Logging two requests
+		logToSink(t, &auth.AuditSinkConfig{Type: auth.AuditSinkFile, Path: path})
+
+		// Then: Each entry is a JSON line carrying the hash chain
+		data, err := os.ReadFile(path)
+		test.AssertNoError(t, err, "reading sink file")
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		test.AssertEqual(t, 2, len(lines), "lines")
+		var first, second models.AuditLog
+		test.AssertNoError(t, json.Unmarshal([]byte(lines[0]), &first), "parsing first entry")
+		test.AssertNoError(t, json.Unmarshal([]byte(lines[1]), &second), "parsing second entry")
+		test.AssertEqual(t, uint64(2), second.Sequence, "sequence")
+		test.AssertEqual(t, first.Hash, second.PrevHash, "chained entries")
+	})
+
+	t.Run("Webhook sink posts CEF batches", func(t *testing.T) {
+		// Given: A webhook receiving CEF
+		var body, token string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body += string(data)
+			token = r.Header.Get("Authorization")
+		}))
+		defer server.Close()
+
+		// When: Logging two requests
+		logToSink(t, &auth.AuditSinkConfig{Type: auth.AuditSinkWebhook, Format: auth.AuditFormatCEF, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+
+		// Then: Both entries arrive as escaped CEF lines
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		test.AssertEqual(t, 2, len(lines), "CEF lines")
+		test.AssertEqual(t, "Bearer secret", token, "configured header")
+		test.AssertTrue(t, strings.HasPrefix(lines[0], "CEF:0|Candlekeep|astore|1|GET|HTTP request|3|"), "CEF header")
+		test.AssertTrue(t, strings.Contains(lines[1], `request=/s3/releases/app\=1|2.jar`), "escaped extension")
+		test.AssertTrue(t, strings.Contains(lines[1], "|PUT|HTTP request|6|"), "denied severity")
+		test.AssertTrue(t, strings.Contains(lines[1], "cn2Label=sequence cn2=2"), "sequence")
+	})
+
+	t.Run("Syslog sink sends RFC 5424 messages", func(t *testing.T) {
+		// Given: A UDP syslog receiver
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		test.AssertNoError(t, err, "listening")
+		defer conn.Close()
+
+		// When: Logging two requests
+		logToSink(t, &auth.AuditSinkConfig{Type: auth.AuditSinkSyslog, Address: conn.LocalAddr().String(), Tag: "astore-test"})
+
+		// Then: Each entry is an authpriv message
+		buf := make([]byte, 64*1024)
+		n, _, err := conn.ReadFrom(buf)
+		test.AssertNoError(t, err, "receiving message")
+		message := string(buf[:n])
+		test.AssertTrue(t, strings.HasPrefix(message, "<86>1 "), "priority and version")
+		test.AssertTrue(t, strings.Contains(message, " astore-test - audit - {"), "app name and JSON entry")
+	})
+
+	t.Run("Unknown sinks are rejected", func(t *testing.T) {
+		_, err := auth.NewAuditSink(&auth.AuditSinkConfig{Type: "kafka"})
+		test.AssertError(t, err, "unknown sink type")
+		_, err = auth.NewAuditSink(&auth.AuditSinkConfig{Type: auth.AuditSinkWebhook})
+		test.AssertError(t, err, "webhook without URL")
+	})
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the reverse proxies whose forwarding headers are
+// believed when extracting a request's client IP
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// NewTrustedProxies parses the IP addresses and CIDR ranges of trusted proxies
+func NewTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	p := &TrustedProxies{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		p.networks = append(p.networks, network)
+	}
+	return p, nil
+}
+
+// Trusts reports whether ip is a trusted proxy
+func (p *TrustedProxies) Trusts(ip net.IP) bool {
+	if p == nil || ip == nil {
+		return false
+	}
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client that made a request, or nil if the
+// remote address is not an IP. Forwarding headers are only believed from
+// trusted proxies: X-Forwarded-For is read from the right, skipping trusted
+// proxies, and X-Real-IP is used when it is absent. A nil TrustedProxies
+// trusts no proxy.
+func (p *TrustedProxies) ClientIP(r *http.Request) net.IP {
+	client := parseHostIP(r.RemoteAddr)
+	if !p.Trusts(client) {
+		return client
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := parseHostIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				// A trusted proxy forwarded garbage; it is the last hop we know
+				return client
+			}
+			client = hop
+			if !p.Trusts(hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if realIP := parseHostIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP
+	}
+	return client
+}
+
+// parseHostIP parses an IP address with or without a port
+func parseHostIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(strings.Trim(address, "[]"))
+}
@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := auth.NewTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	test.AssertNoError(t, err, "parsing trusted proxies")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{"Direct client", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"Untrusted client spoofing headers", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"Trusted proxy", "192.0.2.1:443", "198.51.100.1", "", "198.51.100.1"},
+		{"Chain of trusted proxies", "192.0.2.1:443", "198.51.100.1, 10.1.2.3", "", "198.51.100.1"},
+		{"Spoofed hop left of the client", "192.0.2.1:443", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"Garbage from a trusted proxy", "192.0.2.1:443", "not-an-ip, 10.1.2.3", "", "10.1.2.3"},
+		{"X-Real-IP from a trusted proxy", "10.0.0.5:443", "", "198.51.100.9", "198.51.100.9"},
+		{"IPv6 client", "[2001:db8::1]:5000", "", "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given: A request arriving from remoteAddr with forwarding headers
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			// When: Extracting the client IP
+			ip := proxies.ClientIP(r)
+
+			// Then: Headers are believed only from trusted proxies
+			test.AssertEqual(t, tt.expected, ip.String(), "client IP")
+		})
+	}
+
+	_, err = auth.NewTrustedProxies([]string{"10.0.0.0/33"})
+	test.AssertError(t, err, "invalid CIDR")
+}
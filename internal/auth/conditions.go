@@ -0,0 +1,615 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+// Policy condition keys. Keys with a trailing colon take a name, e.g.
+// header:X-Build-Id or tag:stage.
+const (
+	ConditionSourceIP    = "sourceIp"    // Comma-separated IPs or CIDRs
+	ConditionTimeWindow  = "timeWindow"  // [days ]HH:MM-HH:MM[ timezone], e.g. Mon-Fri 08:00-18:00 Europe/Berlin
+	ConditionNotBefore   = "notBefore"   // RFC 3339 time
+	ConditionNotAfter    = "notAfter"    // RFC 3339 time
+	ConditionHeader      = "header:"     // Comma-separated patterns on a request header
+	ConditionObjectSize  = "objectSize"  // Comparison in bytes, e.g. <=104857600
+	ConditionContentType = "contentType" // Comma-separated patterns, e.g. application/*
+	ConditionTag         = "tag:"        // Comma-separated patterns on an object tag
+	ConditionMetadata    = "metadata:"   // Comma-separated patterns on object metadata
+	ConditionSigned      = "signed"      // true or false
+)
+
+// ObjectAttributes describes the object a request acts on
+type ObjectAttributes struct {
+	Size        int64
+	ContentType string
+	Tags        map[string]string
+	Metadata    map[string]string
+	Signed      bool
+}
+
+// ObjectResolver looks up the attributes of the object a resource names
+type ObjectResolver interface {
+	ResolveObject(resource string) (*ObjectAttributes, error)
+}
+
+// UploadResolver looks up unfinished uploads. Object resolvers that also
+// implement it let conditions on multipart and resumable upload requests
+// see the object the upload creates rather than the request body.
+type UploadResolver interface {
+	GetMultipartUpload(uploadID string) (*models.MultipartUpload, error)
+	GetUploadProgress(uploadID string) (*models.UploadProgress, error)
+}
+
+// RequestContext holds the request attributes policy conditions are
+// evaluated against
+type RequestContext struct {
+	SourceIP net.IP
+	Time     time.Time
+	Headers  http.Header
+
+	// Object is the object being uploaded or, if nil, is looked up with
+	// Resolver the first time a condition needs it
+	Object   *ObjectAttributes
+	Resolver ObjectResolver
+
+	upload     func(resource string) (*ObjectAttributes, error) // Looks up the object of an unfinished upload
+	resolveErr error
+}
+
+// uploadLengthHeader declares the total size of a resumable upload
+const uploadLengthHeader = "Upload-Length"
+
+// NewRequestContext builds the request context of an HTTP request. For
+// uploads the object attributes are those of the object being created: taken
+// from the request for single requests and resumable upload creation, and
+// from the upload so far for multipart parts and completion and resumable
+// chunks. Otherwise they are looked up with resolver when needed.
+func NewRequestContext(r *http.Request, action models.Action, resolver ObjectResolver) *RequestContext {
+	rc := &RequestContext{
+		Time:     time.Now(),
+		Headers:  r.Header,
+		Resolver: resolver,
+	}
+	rc.SourceIP = parseHostIP(r.RemoteAddr)
+	if action != models.ActionWrite {
+		return rc
+	}
+
+	uploads, _ := resolver.(UploadResolver)
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("resumable"):
+		size, err := strconv.ParseInt(r.Header.Get(uploadLengthHeader), 10, 64)
+		if err != nil {
+			size = -1
+		}
+		rc.Object = requestObject(r, size)
+	case r.Method == http.MethodPatch && query.Get("resumable") != "":
+		uploadID := query.Get("resumable")
+		rc.upload = func(resource string) (*ObjectAttributes, error) {
+			return resumableObject(uploads, uploadID, resource)
+		}
+	case r.Method == http.MethodPut && query.Has("uploadId") && query.Has("partNumber"):
+		uploadID := query.Get("uploadId")
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		rc.upload = func(resource string) (*ObjectAttributes, error) {
+			return multipartObject(uploads, uploadID, resource, partNumber, r.ContentLength)
+		}
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		uploadID := query.Get("uploadId")
+		rc.upload = func(resource string) (*ObjectAttributes, error) {
+			return multipartObject(uploads, uploadID, resource, 0, 0)
+		}
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		// Nothing is uploaded yet; parts and completion are checked
+		// against the growing object
+		rc.Object = requestObject(r, 0)
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		rc.Object = requestObject(r, r.ContentLength)
+	}
+	return rc
+}
+
+// requestObject describes an object whose attributes are sent with the
+// request that creates it
+func requestObject(r *http.Request, size int64) *ObjectAttributes {
+	object := &ObjectAttributes{
+		Size:        size,
+		ContentType: r.Header.Get("Content-Type"),
+		Metadata:    make(map[string]string),
+	}
+	if values, err := url.ParseQuery(r.Header.Get("X-Amz-Tagging")); err == nil {
+		object.Tags = make(map[string]string, len(values))
+		for key, v := range values {
+			object.Tags[key] = v[0]
+		}
+	}
+	for key, values := range r.Header {
+		if strings.HasPrefix(key, "X-Amz-Meta-") && len(values) > 0 {
+			object.Metadata[strings.TrimPrefix(key, "X-Amz-Meta-")] = values[0]
+		}
+	}
+	return object
+}
+
+// resumableObject describes the object a resumable upload creates, sized by
+// the length declared when the upload was created
+func resumableObject(uploads UploadResolver, uploadID, resource string) (*ObjectAttributes, error) {
+	if uploads == nil {
+		return nil, fmt.Errorf("upload attributes are not available")
+	}
+	progress, err := uploads.GetUploadProgress(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if progress.Bucket+"/"+progress.Key != resource {
+		return nil, fmt.Errorf("upload %s is not for %s", uploadID, resource)
+	}
+	return &ObjectAttributes{
+		Size:        progress.TotalSize,
+		ContentType: progress.ContentType,
+		Tags:        progress.Tags,
+		Metadata:    progress.Metadata,
+	}, nil
+}
+
+// multipartObject describes the object a multipart upload creates, sized by
+// its parts. A part being uploaded counts with partSize in place of any
+// earlier upload of the same part.
+func multipartObject(uploads UploadResolver, uploadID, resource string, partNumber int, partSize int64) (*ObjectAttributes, error) {
+	if uploads == nil {
+		return nil, fmt.Errorf("upload attributes are not available")
+	}
+	upload, err := uploads.GetMultipartUpload(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Bucket+"/"+upload.Key != resource {
+		return nil, fmt.Errorf("upload %s is not for %s", uploadID, resource)
+	}
+	size := partSize
+	for _, part := range upload.Parts {
+		if part.PartNumber != partNumber {
+			size += part.Size
+		}
+	}
+	if partSize < 0 {
+		size = -1
+	}
+	return &ObjectAttributes{
+		Size:        size,
+		ContentType: upload.ContentType,
+		Tags:        upload.Tags,
+		Metadata:    upload.Metadata,
+	}, nil
+}
+
+// object returns the attributes of the object a resource names
+func (rc *RequestContext) object(resource string) (*ObjectAttributes, error) {
+	if rc.Object != nil {
+		return rc.Object, nil
+	}
+	if rc.resolveErr != nil {
+		return nil, rc.resolveErr
+	}
+	resolve := rc.upload
+	if resolve == nil {
+		if rc.Resolver == nil {
+			return nil, fmt.Errorf("object attributes are not available")
+		}
+		resolve = rc.Resolver.ResolveObject
+	}
+	rc.Object, rc.resolveErr = resolve(resource)
+	return rc.Object, rc.resolveErr
+}
+
+// metadataObjectResolver resolves bucket/key resources from the metadata store
+type metadataObjectResolver struct {
+	store storage.MetadataStore
+}
+
+// NewMetadataObjectResolver creates an object resolver that looks up
+// artifacts and their signatures in the metadata store
+func NewMetadataObjectResolver(store storage.MetadataStore) ObjectResolver {
+	return &metadataObjectResolver{store: store}
+}
+
+func (o *metadataObjectResolver) GetMultipartUpload(uploadID string) (*models.MultipartUpload, error) {
+	return o.store.GetMultipartUpload(uploadID)
+}
+
+func (o *metadataObjectResolver) GetUploadProgress(uploadID string) (*models.UploadProgress, error) {
+	return o.store.GetUploadProgress(uploadID)
+}
+
+func (o *metadataObjectResolver) ResolveObject(resource string) (*ObjectAttributes, error) {
+	bucket, key, ok := strings.Cut(resource, "/")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("resource %s is not an object", resource)
+	}
+	artifact, err := o.store.GetArtifact(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := o.store.ListSignaturesForArtifact(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signatures: %w", err)
+	}
+	return &ObjectAttributes{
+		Size:        artifact.Size,
+		ContentType: artifact.ContentType,
+		Tags:        artifact.Tags,
+		Metadata:    artifact.Metadata,
+		Signed:      len(signatures) > 0 || len(artifact.Signatures) > 0,
+	}, nil
+}
+
+// condition is a parsed policy condition
+type condition struct {
+	key    string
+	negate bool
+	eval   func(rc *RequestContext, resource string) (bool, error)
+}
+
+// compiledConditions are the parsed conditions of a policy
+type compiledConditions struct {
+	conditions []*condition
+	err        error // Set if a condition does not parse; evaluation then fails
+}
+
+// compileConditions parses policy conditions once, so decisions need not
+// parse them again. A condition that does not parse is kept as an error
+// that every evaluation reports.
+func compileConditions(conditions map[string]string) *compiledConditions {
+	compiled := &compiledConditions{conditions: make([]*condition, 0, len(conditions))}
+	keys := make([]string, 0, len(conditions))
+	for key := range conditions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cond, err := parseCondition(key, conditions[key])
+		if err != nil {
+			return &compiledConditions{err: err}
+		}
+		compiled.conditions = append(compiled.conditions, cond)
+	}
+	return compiled
+}
+
+// ValidateConditions checks that policy conditions parse
+func ValidateConditions(conditions map[string]string) error {
+	for key, value := range conditions {
+		if _, err := parseCondition(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate reports whether all conditions hold for a request. An error
+// means a condition could not be evaluated, e.g. because the request
+// context lacks the attribute it tests.
+func (c *compiledConditions) evaluate(rc *RequestContext, resource string) (bool, error) {
+	if c.err != nil {
+		return false, c.err
+	}
+	if len(c.conditions) == 0 {
+		return true, nil
+	}
+	if rc == nil {
+		return false, fmt.Errorf("policy conditions need a request context")
+	}
+	for _, cond := range c.conditions {
+		matched, err := cond.eval(rc, resource)
+		if err != nil {
+			return false, fmt.Errorf("condition %s: %w", cond.key, err)
+		}
+		if matched == cond.negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// parseCondition parses a condition. A value starting with ! negates it.
+func parseCondition(key, value string) (*condition, error) {
+	cond := &condition{key: key}
+	if strings.HasPrefix(value, "!") {
+		cond.negate = true
+		value = strings.TrimPrefix(value, "!")
+	}
+	value = strings.TrimSpace(value)
+
+	var err error
+	switch {
+	case key == ConditionSourceIP:
+		cond.eval, err = sourceIPCondition(value)
+	case key == ConditionTimeWindow:
+		cond.eval, err = timeWindowCondition(value)
+	case key == ConditionNotBefore, key == ConditionNotAfter:
+		cond.eval, err = timeBoundCondition(key, value)
+	case strings.HasPrefix(key, ConditionHeader):
+		name := strings.TrimPrefix(key, ConditionHeader)
+		cond.eval, err = patternCondition(value, func(rc *RequestContext, _ string) (string, bool, error) {
+			values, ok := rc.Headers[http.CanonicalHeaderKey(name)]
+			if !ok || len(values) == 0 {
+				return "", false, nil
+			}
+			return values[0], true, nil
+		})
+	case key == ConditionObjectSize:
+		cond.eval, err = objectSizeCondition(value)
+	case key == ConditionContentType:
+		cond.eval, err = patternCondition(value, objectField(func(o *ObjectAttributes) (string, bool) {
+			return o.ContentType, o.ContentType != ""
+		}))
+	case strings.HasPrefix(key, ConditionTag):
+		name := strings.TrimPrefix(key, ConditionTag)
+		cond.eval, err = patternCondition(value, objectField(func(o *ObjectAttributes) (string, bool) {
+			v, ok := o.Tags[name]
+			return v, ok
+		}))
+	case strings.HasPrefix(key, ConditionMetadata):
+		name := strings.TrimPrefix(key, ConditionMetadata)
+		cond.eval, err = patternCondition(value, objectField(func(o *ObjectAttributes) (string, bool) {
+			for k, v := range o.Metadata {
+				if strings.EqualFold(k, name) {
+					return v, true
+				}
+			}
+			return "", false
+		}))
+	case key == ConditionSigned:
+		cond.eval, err = signedCondition(value)
+	default:
+		return nil, fmt.Errorf("unknown policy condition %q", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy condition %s: %w", key, err)
+	}
+	return cond, nil
+}
+
+func sourceIPCondition(value string) (func(*RequestContext, string) (bool, error), error) {
+	var networks []*net.IPNet
+	for _, entry := range splitList(value) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no networks given")
+	}
+
+	return func(rc *RequestContext, _ string) (bool, error) {
+		if rc.SourceIP == nil {
+			return false, fmt.Errorf("source IP is unknown")
+		}
+		for _, network := range networks {
+			if network.Contains(rc.SourceIP) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// timeWindowCondition matches times of day, optionally on some weekdays and
+// in a time zone (UTC by default). Windows may wrap past midnight.
+func timeWindowCondition(value string) (func(*RequestContext, string) (bool, error), error) {
+	fields := strings.Fields(value)
+	days := make(map[time.Weekday]bool)
+	location := time.UTC
+
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		for _, part := range strings.Split(fields[0], ",") {
+			first, last, isRange := strings.Cut(strings.ToLower(part), "-")
+			from, ok := weekdays[first]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekday %q", first)
+			}
+			to := from
+			if isRange {
+				if to, ok = weekdays[last]; !ok {
+					return nil, fmt.Errorf("invalid weekday %q", last)
+				}
+			}
+			for d := from; ; d = (d + 1) % 7 {
+				days[d] = true
+				if d == to {
+					break
+				}
+			}
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 2 {
+		var err error
+		if location, err = loadLocation(fields[1]); err != nil {
+			return nil, err
+		}
+		fields = fields[:1]
+	}
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("expected [days ]HH:MM-HH:MM[ timezone]")
+	}
+
+	startText, endText, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM")
+	}
+	start, err := time.Parse("15:04", startText)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse("15:04", endText)
+	if err != nil {
+		return nil, err
+	}
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	return func(rc *RequestContext, _ string) (bool, error) {
+		now := rc.Time.In(location)
+		minute := now.Hour()*60 + now.Minute()
+		day := now.Weekday()
+		var inWindow bool
+		if startMinute <= endMinute {
+			inWindow = minute >= startMinute && minute < endMinute
+		} else {
+			// The window wraps past midnight; its early hours belong to the previous day
+			inWindow = minute >= startMinute || minute < endMinute
+			if minute < endMinute {
+				day = (day + 6) % 7
+			}
+		}
+		return inWindow && (len(days) == 0 || days[day]), nil
+	}, nil
+}
+
+// locations caches loaded time zones by name
+var locations sync.Map
+
+// loadLocation loads a time zone, reading the zone database only the first
+// time a name is used
+func loadLocation(name string) (*time.Location, error) {
+	if location, ok := locations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, location)
+	return location, nil
+}
+
+func timeBoundCondition(key, value string) (func(*RequestContext, string) (bool, error), error) {
+	bound, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return func(rc *RequestContext, _ string) (bool, error) {
+		if key == ConditionNotBefore {
+			return !rc.Time.Before(bound), nil
+		}
+		return !rc.Time.After(bound), nil
+	}, nil
+}
+
+func objectSizeCondition(value string) (func(*RequestContext, string) (bool, error), error) {
+	operator := strings.TrimRight(value, "0123456789 ")
+	limit, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(value, operator)), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	compare := map[string]func(int64) bool{
+		"<":  func(size int64) bool { return size < limit },
+		"<=": func(size int64) bool { return size <= limit },
+		">":  func(size int64) bool { return size > limit },
+		">=": func(size int64) bool { return size >= limit },
+		"=":  func(size int64) bool { return size == limit },
+		"":   func(size int64) bool { return size == limit },
+	}[operator]
+	if compare == nil {
+		return nil, fmt.Errorf("invalid comparison %q", operator)
+	}
+
+	return func(rc *RequestContext, resource string) (bool, error) {
+		object, err := rc.object(resource)
+		if err != nil {
+			return false, err
+		}
+		if object.Size < 0 {
+			return false, fmt.Errorf("object size is unknown")
+		}
+		return compare(object.Size), nil
+	}, nil
+}
+
+func signedCondition(value string) (func(*RequestContext, string) (bool, error), error) {
+	want, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(rc *RequestContext, resource string) (bool, error) {
+		object, err := rc.object(resource)
+		if err != nil {
+			return false, err
+		}
+		return object.Signed == want, nil
+	}, nil
+}
+
+// patternCondition matches a value against comma-separated path.Match
+// patterns. A missing value matches no pattern.
+func patternCondition(value string, lookup func(*RequestContext, string) (string, bool, error)) (func(*RequestContext, string) (bool, error), error) {
+	patterns := splitList(value)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+	return func(rc *RequestContext, resource string) (bool, error) {
+		actual, ok, err := lookup(rc, resource)
+		if err != nil || !ok {
+			return false, err
+		}
+		return matchesAny(patterns, actual), nil
+	}, nil
+}
+
+// objectField adapts an object attribute to a pattern condition lookup
+func objectField(field func(*ObjectAttributes) (string, bool)) func(*RequestContext, string) (string, bool, error) {
+	return func(rc *RequestContext, resource string) (string, bool, error) {
+		object, err := rc.object(resource)
+		if err != nil {
+			return "", false, err
+		}
+		value, ok := field(object)
+		return value, ok, nil
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,216 @@
+package auth_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// objectResolver resolves objects from a map
+type objectResolver map[string]*auth.ObjectAttributes
+
+func (o objectResolver) ResolveObject(resource string) (*auth.ObjectAttributes, error) {
+	if object, ok := o[resource]; ok {
+		return object, nil
+	}
+	return nil, fmt.Errorf("object %s not found", resource)
+}
+
+func TestPolicyConditions(t *testing.T) {
+	developer := &models.User{ID: "user-1", Username: "dev", Roles: []string{"developer"}}
+	objects := objectResolver{
+		"prod/signed.jar":   {Size: 1024, ContentType: "application/java-archive", Signed: true},
+		"prod/unsigned.jar": {Size: 1024, ContentType: "application/java-archive", Tags: map[string]string{"stage": "rc"}},
+	}
+	requestFrom := func(ip string) *auth.RequestContext {
+		return &auth.RequestContext{SourceIP: net.ParseIP(ip), Time: time.Now(), Headers: http.Header{}, Resolver: objects}
+	}
+
+	t.Run("Only signed artifacts are read from prod outside the office", func(t *testing.T) {
+		// Given: Developers may read prod, except unsigned artifacts from outside the office
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "read", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:developer"}})
+		engine.AddPolicy(&models.Policy{
+			ID: "signed-only", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny,
+			Conditions: map[string]string{"sourceIp": "!10.0.0.0/8, 192.168.1.7", "signed": "false"},
+		})
+
+		// When/Then: Unsigned artifacts are only readable from the office
+		allowed, _ := engine.Authorize(developer, "prod/unsigned.jar", models.ActionRead, requestFrom("10.1.2.3"))
+		test.AssertTrue(t, allowed, "unsigned artifact from the office")
+		allowed, _ = engine.Authorize(developer, "prod/unsigned.jar", models.ActionRead, requestFrom("192.168.1.7"))
+		test.AssertTrue(t, allowed, "unsigned artifact from an office address")
+		allowed, _ = engine.Authorize(developer, "prod/unsigned.jar", models.ActionRead, requestFrom("203.0.113.9"))
+		test.AssertFalse(t, allowed, "unsigned artifact from outside")
+		allowed, _ = engine.Authorize(developer, "prod/signed.jar", models.ActionRead, requestFrom("203.0.113.9"))
+		test.AssertTrue(t, allowed, "signed artifact from outside")
+	})
+
+	t.Run("Conditions that cannot be evaluated fail closed", func(t *testing.T) {
+		// Given: A conditional allow and a conditional deny
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "office", Resource: "dev/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Conditions: map[string]string{"sourceIp": "10.0.0.0/8"}})
+		engine.AddPolicy(&models.Policy{ID: "all", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+		engine.AddPolicy(&models.Policy{ID: "rc", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny, Conditions: map[string]string{"tag:stage": "rc"}})
+
+		// When/Then: Without a request context the allow does not apply and the deny does
+		allowed, _ := engine.Authorize(developer, "dev/app.jar", models.ActionRead, nil)
+		test.AssertFalse(t, allowed, "conditional allow without context")
+		allowed, _ = engine.Authorize(developer, "prod/signed.jar", models.ActionRead, nil)
+		test.AssertFalse(t, allowed, "conditional deny without context")
+
+		// And: With a context the deny only applies to matching objects
+		allowed, _ = engine.Authorize(developer, "prod/signed.jar", models.ActionRead, requestFrom("10.0.0.1"))
+		test.AssertTrue(t, allowed, "object without the tag")
+		allowed, _ = engine.Authorize(developer, "prod/unsigned.jar", models.ActionRead, requestFrom("10.0.0.1"))
+		test.AssertFalse(t, allowed, "object with the tag")
+		allowed, _ = engine.Authorize(developer, "prod/missing.jar", models.ActionRead, requestFrom("10.0.0.1"))
+		test.AssertFalse(t, allowed, "object that cannot be resolved")
+	})
+
+	t.Run("Time windows", func(t *testing.T) {
+		// Given: Writes allowed during business hours and during a night window
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "day", Resource: "day/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow, Conditions: map[string]string{"timeWindow": "Mon-Fri 08:00-18:00 UTC"}})
+		engine.AddPolicy(&models.Policy{ID: "night", Resource: "night/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow, Conditions: map[string]string{"timeWindow": "Sat 22:00-02:00"}})
+		at := func(when string) *auth.RequestContext {
+			parsed, err := time.Parse(time.RFC3339, when)
+			test.AssertNoError(t, err, "parsing time")
+			return &auth.RequestContext{Time: parsed}
+		}
+
+		// When/Then: Only times inside the windows are allowed
+		allowed, _ := engine.Authorize(developer, "day/a", models.ActionWrite, at("2025-03-05T09:30:00Z")) // Wednesday
+		test.AssertTrue(t, allowed, "weekday morning")
+		allowed, _ = engine.Authorize(developer, "day/a", models.ActionWrite, at("2025-03-05T18:00:00Z"))
+		test.AssertFalse(t, allowed, "end of the window")
+		allowed, _ = engine.Authorize(developer, "day/a", models.ActionWrite, at("2025-03-08T09:30:00Z")) // Saturday
+		test.AssertFalse(t, allowed, "weekend morning")
+		allowed, _ = engine.Authorize(developer, "night/a", models.ActionWrite, at("2025-03-09T01:00:00Z")) // Early Sunday
+		test.AssertTrue(t, allowed, "past midnight of a window starting Saturday")
+		allowed, _ = engine.Authorize(developer, "night/a", models.ActionWrite, at("2025-03-10T01:00:00Z")) // Early Monday
+		test.AssertFalse(t, allowed, "past midnight of Sunday")
+	})
+
+	t.Run("Uploads are checked against the request", func(t *testing.T) {
+		// Given: Uploads of small jars from the build pipeline
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{
+			ID: "uploads", Resource: "releases/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow,
+			Conditions: map[string]string{
+				"objectSize":         "<=1048576",
+				"contentType":        "application/java-archive, application/zip",
+				"header:X-Pipeline":  "release-*",
+				"metadata:team":      "core",
+				"tag:classification": "!secret",
+			},
+		})
+		upload := func(size int, modify func(r *http.Request)) bool {
+			r := httptest.NewRequest("PUT", "/s3/releases/app.jar", strings.NewReader(strings.Repeat("x", size)))
+			r.Header.Set("Content-Type", "application/java-archive")
+			r.Header.Set("X-Pipeline", "release-42")
+			r.Header.Set("X-Amz-Meta-Team", "core")
+			if modify != nil {
+				modify(r)
+			}
+			allowed, _ := engine.Authorize(developer, "releases/app.jar", models.ActionWrite, auth.NewRequestContext(r, models.ActionWrite, nil))
+			return allowed
+		}
+
+		// When/Then: Each condition is checked
+		test.AssertTrue(t, upload(1024, nil), "matching upload")
+		test.AssertFalse(t, upload(2<<20, nil), "upload too large")
+		test.AssertFalse(t, upload(1024, func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }), "wrong content type")
+		test.AssertFalse(t, upload(1024, func(r *http.Request) { r.Header.Del("X-Pipeline") }), "missing header")
+		test.AssertFalse(t, upload(1024, func(r *http.Request) { r.Header.Set("X-Amz-Meta-Team", "web") }), "other team")
+		test.AssertFalse(t, upload(1024, func(r *http.Request) { r.Header.Set("X-Amz-Tagging", "classification=secret") }), "secret tag")
+	})
+
+	t.Run("Uploads in several requests are checked against the whole object", func(t *testing.T) {
+		// Given: A size limit on releases and unfinished uploads of large objects
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "uploads", Resource: "releases/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow})
+		engine.AddPolicy(&models.Policy{
+			ID: "size-limit", Resource: "releases/*", Actions: []string{"write"}, Effect: models.PolicyEffectDeny,
+			Conditions: map[string]string{"objectSize": ">1048576"},
+		})
+		_, store := newTokenManager(t)
+		test.AssertNoError(t, store.CreateMultipartUpload(&models.MultipartUpload{
+			UploadID: "mp-1", Bucket: "releases", Key: "app.jar",
+			Parts: []models.MultipartPart{{PartNumber: 1, Size: 800 << 10}},
+		}), "creating multipart upload")
+		test.AssertNoError(t, store.CreateUploadProgress(&models.UploadProgress{
+			UploadID: "ru-1", Bucket: "releases", Key: "app.jar", TotalSize: 2 << 20,
+		}), "creating resumable upload")
+		test.AssertNoError(t, store.CreateUploadProgress(&models.UploadProgress{
+			UploadID: "ru-2", Bucket: "releases", Key: "other.jar", TotalSize: 1024,
+		}), "creating resumable upload")
+		resolver := auth.NewMetadataObjectResolver(store)
+		allowed := func(r *http.Request) bool {
+			allowed, _ := engine.Authorize(developer, "releases/app.jar", models.ActionWrite, auth.NewRequestContext(r, models.ActionWrite, resolver))
+			return allowed
+		}
+		withBody := func(method, target string, size int) *http.Request {
+			return httptest.NewRequest(method, target, strings.NewReader(strings.Repeat("x", size)))
+		}
+
+		// When/Then: Parts count with the parts already uploaded
+		test.AssertTrue(t, allowed(withBody("POST", "/s3/releases/app.jar?uploads", 0)), "initiating multipart upload")
+		test.AssertTrue(t, allowed(withBody("PUT", "/s3/releases/app.jar?uploadId=mp-1&partNumber=1", 1024)), "replacing a part")
+		test.AssertFalse(t, allowed(withBody("PUT", "/s3/releases/app.jar?uploadId=mp-1&partNumber=2", 800<<10)), "part past the limit")
+		test.AssertTrue(t, allowed(withBody("POST", "/s3/releases/app.jar?uploadId=mp-1", 0)), "completing small upload")
+
+		// When/Then: Resumable uploads are checked against their declared length
+		create := withBody("POST", "/s3/releases/app.jar?resumable", 0)
+		create.Header.Set("Upload-Length", "2097152")
+		test.AssertFalse(t, allowed(create), "creating large resumable upload")
+		test.AssertFalse(t, allowed(withBody("PATCH", "/s3/releases/app.jar?resumable=ru-1", 1024)), "chunk of large upload")
+		test.AssertFalse(t, allowed(withBody("PATCH", "/s3/releases/app.jar?resumable=ru-2", 1024)), "upload of another object")
+	})
+
+	t.Run("Invalid conditions are rejected", func(t *testing.T) {
+		// Given/When/Then: Unknown keys and malformed values fail validation
+		test.AssertNoError(t, auth.ValidateConditions(map[string]string{"sourceIp": "10.0.0.0/8", "notAfter": "2030-01-01T00:00:00Z"}), "valid conditions")
+		for key, value := range map[string]string{
+			"region":      "eu",
+			"sourceIp":    "10.0.0.0/33",
+			"timeWindow":  "Someday 08:00-18:00",
+			"objectSize":  "~100",
+			"signed":      "maybe",
+			"contentType": "application/[",
+		} {
+			test.AssertError(t, auth.ValidateConditions(map[string]string{key: value}), key)
+		}
+	})
+}
+
+func TestMetadataObjectResolver(t *testing.T) {
+	// Given: A stored artifact with a signature
+	_, store := newTokenManager(t)
+	test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "prod", Key: "app.jar", Size: 42, ContentType: "application/java-archive", Tags: map[string]string{"stage": "ga"}}), "storing artifact")
+	test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "prod", Key: "other.jar", Size: 7}), "storing artifact")
+	test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "prod/app.jar", SignedBy: "ci"}), "storing signature")
+	resolver := auth.NewMetadataObjectResolver(store)
+
+	// When: Resolving the artifacts
+	signed, err := resolver.ResolveObject("prod/app.jar")
+	test.AssertNoError(t, err, "resolving signed artifact")
+	unsigned, err := resolver.ResolveObject("prod/other.jar")
+	test.AssertNoError(t, err, "resolving unsigned artifact")
+
+	// Then: Their attributes and signatures are reported
+	test.AssertEqual(t, int64(42), signed.Size, "size")
+	test.AssertEqual(t, "ga", signed.Tags["stage"], "tag")
+	test.AssertTrue(t, signed.Signed, "signed artifact")
+	test.AssertFalse(t, unsigned.Signed, "unsigned artifact")
+	_, err = resolver.ResolveObject("prod")
+	test.AssertError(t, err, "bucket is not an object")
+}
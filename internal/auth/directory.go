@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// ErrUserNotFound is returned for users no directory source knows
+var ErrUserNotFound = errors.New("user not found")
+
+// AuthMethodOIDC is the source recorded for users authenticated by a JWT
+const AuthMethodOIDC = "oidc"
+
+// knownUserResolution is how often an unchanged known user's last seen
+// time is written, so authenticated requests rarely write to the store
+const knownUserResolution = time.Hour
+
+// UserDirectoryConfig configures the optional sources of the user directory
+type UserDirectoryConfig struct {
+	LDIFFile   string            `json:"ldifFile" mapstructure:"ldifFile"`     // LDAP users and groups exported as LDIF
+	GroupRoles map[string]string `json:"groupRoles" mapstructure:"groupRoles"` // Role of the members of each LDIF group
+}
+
+// UserSource provides users, with their roles and groups, to a user directory
+type UserSource interface {
+	// LookupUser returns the user with an ID or username, or ErrUserNotFound
+	LookupUser(name string) (*models.User, error)
+	ListUsers() ([]*models.User, error)
+	String() string
+}
+
+// UserDirectory looks users up in several sources, in order, to answer
+// authorization queries about users who are not making the request
+type UserDirectory struct {
+	sources []UserSource
+}
+
+// NewUserDirectory creates a user directory over sources. Earlier sources
+// take precedence for users several sources know.
+func NewUserDirectory(sources ...UserSource) *UserDirectory {
+	return &UserDirectory{sources: sources}
+}
+
+// LookupUser returns the user with an ID or username from the first source
+// that knows them
+func (d *UserDirectory) LookupUser(name string) (*models.User, error) {
+	for _, source := range d.sources {
+		user, err := source.LookupUser(name)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrUserNotFound) {
+			return nil, fmt.Errorf("failed to look up user in %s: %w", source, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+}
+
+// ListUsers lists the users of all sources, sorted by username. A user
+// several sources know is listed once, as the first source has them.
+func (d *UserDirectory) ListUsers() ([]*models.User, error) {
+	seen := make(map[string]bool)
+	var users []*models.User
+	for _, source := range d.sources {
+		sourceUsers, err := source.ListUsers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users in %s: %w", source, err)
+		}
+		for _, user := range sourceUsers {
+			if seen[user.ID] {
+				continue
+			}
+			seen[user.ID] = true
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// KnownUsers records the users seen authenticating with OIDC tokens or
+// client certificates, with the roles and groups they last had
+type KnownUsers struct {
+	store  storage.MetadataStore
+	logger log.Logger
+
+	mu       sync.Mutex
+	recorded map[string]*models.KnownUser // Last stored version of each user
+}
+
+// NewKnownUsers creates a known user source backed by the metadata store
+func NewKnownUsers(store storage.MetadataStore, logger log.Logger) *KnownUsers {
+	return &KnownUsers{store: store, logger: logger, recorded: make(map[string]*models.KnownUser)}
+}
+
+// Record records a user who authenticated. Users authenticated by API
+// tokens are not recorded: personal tokens carry a snapshot of their
+// owner's roles, and service accounts are a source of their own.
+func (k *KnownUsers) Record(user *models.User) {
+	if user == nil || IsAPITokenUser(user) {
+		return
+	}
+	source := user.Metadata["authMethod"]
+	if source == "" {
+		source = AuthMethodOIDC
+	}
+
+	now := time.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	previous, ok := k.recorded[user.ID]
+	if !ok {
+		if stored, err := k.store.GetKnownUser(user.ID); err == nil {
+			previous = stored
+		}
+	}
+	known := &models.KnownUser{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		Groups:    user.Groups,
+		Source:    source,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	if previous != nil {
+		known.FirstSeen = previous.FirstSeen
+		if sameKnownUser(previous, known) && now.Sub(previous.LastSeen) < knownUserResolution {
+			k.recorded[user.ID] = previous
+			return
+		}
+	}
+
+	if err := k.store.StoreKnownUser(known); err != nil {
+		k.logger.Warn().Err(err).Str("userId", user.ID).Msg("failed to record known user")
+		return
+	}
+	k.recorded[user.ID] = known
+}
+
+// LookupUser returns a known user by ID or username
+func (k *KnownUsers) LookupUser(name string) (*models.User, error) {
+	if known, err := k.store.GetKnownUser(name); err == nil {
+		return knownUserToUser(known), nil
+	}
+	users, err := k.store.ListKnownUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, known := range users {
+		if known.Username == name {
+			return knownUserToUser(known), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// ListUsers lists all known users
+func (k *KnownUsers) ListUsers() ([]*models.User, error) {
+	known, err := k.store.ListKnownUsers()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*models.User, len(known))
+	for i, user := range known {
+		users[i] = knownUserToUser(user)
+	}
+	return users, nil
+}
+
+func (k *KnownUsers) String() string {
+	return "known users"
+}
+
+// sameKnownUser reports whether two records of a user have the same attributes
+func sameKnownUser(a, b *models.KnownUser) bool {
+	return a.Username == b.Username && a.Email == b.Email && a.Source == b.Source &&
+		equalStrings(a.Roles, b.Roles) && equalStrings(a.Groups, b.Groups)
+}
+
+// knownUserToUser returns the user a known user record describes
+func knownUserToUser(known *models.KnownUser) *models.User {
+	return &models.User{
+		ID:       known.ID,
+		Username: known.Username,
+		Email:    known.Email,
+		Roles:    known.Roles,
+		Groups:   known.Groups,
+		Metadata: map[string]string{
+			"source":   known.Source,
+			"lastSeen": known.LastSeen.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// serviceAccountSource provides the users service accounts act as
+type serviceAccountSource struct {
+	store storage.MetadataStore
+}
+
+// NewServiceAccountSource creates a user source of the service accounts in
+// the metadata store
+func NewServiceAccountSource(store storage.MetadataStore) UserSource {
+	return &serviceAccountSource{store: store}
+}
+
+func (s *serviceAccountSource) LookupUser(name string) (*models.User, error) {
+	if account, err := s.store.GetServiceAccount(name); err == nil {
+		return serviceAccountUser(account), nil
+	}
+	accounts, err := s.store.ListServiceAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if account.Name == name {
+			return serviceAccountUser(account), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *serviceAccountSource) ListUsers() ([]*models.User, error) {
+	accounts, err := s.store.ListServiceAccounts()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*models.User, len(accounts))
+	for i, account := range accounts {
+		users[i] = serviceAccountUser(account)
+	}
+	return users, nil
+}
+
+func (s *serviceAccountSource) String() string {
+	return "service accounts"
+}
+
+// serviceAccountUser returns the user a service account's tokens act as
+func serviceAccountUser(account *models.ServiceAccount) *models.User {
+	return &models.User{
+		ID:       account.ID,
+		Username: account.Name,
+		Roles:    account.Roles,
+		Groups:   account.Groups,
+		Metadata: map[string]string{"source": "service-account"},
+	}
+}
@@ -0,0 +1,113 @@
+package auth_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+const directoryLDIF = `version: 1
+
+# Users
+dn: uid=alice,ou=people,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: alice
+mail: alice@example.com
+memberOf: cn=release-managers,ou=groups,dc=example,dc=com
+
+dn: uid=bob,ou=people,dc=example,dc=com
+uid: bob
+mail:: Ym9iQGV4YW1wbGUuY29t
+
+dn: cn=developers,ou=groups,dc=example,dc=com
+objectClass: groupOfNames
+cn: developers
+member: uid=alice,ou=people,
+ dc=example,dc=com
+member: UID=bob, ou=people, dc=example, dc=com
+`
+
+func TestUserDirectory(t *testing.T) {
+	t.Run("LDIF users with groups and roles", func(t *testing.T) {
+		// Given: An LDIF file with two users and group memberships
+		path := filepath.Join(t.TempDir(), "users.ldif")
+		test.AssertNoError(t, os.WriteFile(path, []byte(directoryLDIF), 0644), "writing LDIF")
+		source := auth.NewLDIFUserSource(path, map[string]string{"release-managers": "admin", "developers": "developer"})
+
+		// When: Looking the users up
+		alice, err := source.LookupUser("alice")
+		test.AssertNoError(t, err, "looking up alice")
+		bob, err := source.LookupUser("bob")
+		test.AssertNoError(t, err, "looking up bob")
+
+		// Then: Groups come from memberOf and group members, and roles from the groups
+		test.AssertEqual(t, "alice@example.com", alice.Email, "email")
+		test.AssertEqual(t, "developers,release-managers", strings.Join(alice.Groups, ","), "alice groups")
+		test.AssertEqual(t, "admin,developer", strings.Join(alice.Roles, ","), "alice roles")
+		test.AssertEqual(t, "bob@example.com", bob.Email, "base64 email")
+		test.AssertEqual(t, "developer", strings.Join(bob.Roles, ","), "bob roles")
+		_, err = source.LookupUser("carol")
+		test.AssertTrue(t, errors.Is(err, auth.ErrUserNotFound), "unknown user")
+	})
+
+	t.Run("Known users are recorded and listed with service accounts", func(t *testing.T) {
+		// Given: A directory of known users and service accounts
+		manager, store := newTokenManager(t)
+		known := auth.NewKnownUsers(store, test.NewTestLogger(t))
+		directory := auth.NewUserDirectory(known, auth.NewServiceAccountSource(store))
+		test.AssertNoError(t, manager.CreateServiceAccount(&models.ServiceAccount{Name: "ci", Roles: []string{"publisher"}}), "creating service account")
+
+		// When: Users authenticate with an OIDC token and an API token
+		known.Record(&models.User{ID: "sub-1", Username: "alice", Roles: []string{"developer"}})
+		known.Record(&models.User{ID: "sub-1", Username: "alice", Roles: []string{"developer", "admin"}})
+		known.Record(&models.User{ID: "tok-1", Username: "token", Metadata: map[string]string{"authMethod": auth.AuthMethodAPIToken}})
+
+		// Then: The OIDC user is known with their latest roles, and token users are not recorded
+		alice, err := directory.LookupUser("alice")
+		test.AssertNoError(t, err, "looking up alice")
+		test.AssertEqual(t, "developer,admin", strings.Join(alice.Roles, ","), "latest roles")
+		test.AssertEqual(t, auth.AuthMethodOIDC, alice.Metadata["source"], "source")
+		ci, err := directory.LookupUser("ci")
+		test.AssertNoError(t, err, "looking up service account")
+		test.AssertEqual(t, "publisher", strings.Join(ci.Roles, ","), "service account roles")
+		users, err := directory.ListUsers()
+		test.AssertNoError(t, err, "listing users")
+		test.AssertEqual(t, 2, len(users), "directory users")
+		_, err = directory.LookupUser("token")
+		test.AssertTrue(t, errors.Is(err, auth.ErrUserNotFound), "token user not recorded")
+	})
+}
+
+func TestAccessQueries(t *testing.T) {
+	// Given: Developers may read and write releases, and admins may do anything
+	engine := auth.NewPolicyEngine(false)
+	engine.AddPolicy(&models.Policy{ID: "devs", Resource: "releases/**", Actions: []string{"read", "list", "write"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:developer"}})
+	engine.AddPolicy(&models.Policy{ID: "admins", Resource: "*", Actions: []string{"*"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:admin"}})
+	dev := &models.User{ID: "u1", Username: "dev", Roles: []string{"developer"}}
+	admin := &models.User{ID: "u2", Username: "root", Roles: []string{"admin"}}
+	guest := &models.User{ID: "u3", Username: "guest"}
+	reqCtx := &auth.RequestContext{}
+
+	// When: Asking what the developer may do and who can write releases
+	access := engine.EffectiveAccess(dev, "releases/app.jar", reqCtx)
+	grantees := engine.WhoCan([]*models.User{dev, admin, guest}, "releases/new.jar", models.ActionWrite, reqCtx)
+
+	// Then: Each answer follows the policies
+	allowed := make([]string, len(access.Allowed))
+	for i, action := range access.Allowed {
+		allowed[i] = string(action)
+	}
+	test.AssertEqual(t, "read,list,write", strings.Join(allowed, ","), "developer actions")
+	test.AssertEqual(t, len(auth.Actions), len(access.Decisions), "decision per action")
+	test.AssertEqual(t, 3, grantees.Evaluated, "evaluated users")
+	test.AssertEqual(t, 2, len(grantees.Users), "grantees")
+	test.AssertEqual(t, "devs", grantees.Users[0].DecidingPolicy, "developer policy")
+	test.AssertEqual(t, "root", grantees.Users[1].Username, "admin grantee")
+	test.AssertFalse(t, grantees.Anonymous, "anonymous writes")
+}
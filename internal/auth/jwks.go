@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keySet caches an issuer's signing keys. Keys are refetched once they are
+// older than the refresh interval, and when a token names an unknown key so
+// rotated keys are picked up without waiting.
+type keySet struct {
+	config     *OIDCConfig
+	httpClient *http.Client
+
+	fetchMu sync.Mutex // Serializes discovery and fetches
+	jwksURL string     // Guarded by fetchMu
+
+	mu        sync.RWMutex
+	keys      map[string]interface{} // Public keys by key ID
+	fetchedAt time.Time              // Last successful fetch
+	checkedAt time.Time              // Last fetch attempt
+}
+
+// get returns the public key with a key ID
+func (s *keySet) get(kid string) (interface{}, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetchedAt) >= s.config.RefreshInterval
+	checkedAt := s.checkedAt
+	s.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !checkedAt.IsZero() && time.Since(checkedAt) < s.config.MinRefreshInterval {
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("public key not found for kid: %s", kid)
+	}
+
+	if err := s.refresh(checkedAt); err != nil {
+		if ok {
+			// Keep using the cached key while the provider is unreachable
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.mu.RLock()
+	key, ok = s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("public key not found for kid: %s", kid)
+	}
+	return key, nil
+}
+
+// refresh refetches the keys unless another caller has done so since checkedAt
+func (s *keySet) refresh(checkedAt time.Time) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.RLock()
+	current := s.checkedAt
+	s.mu.RUnlock()
+	if current.After(checkedAt) {
+		return nil
+	}
+
+	keys, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.fetchedAt = s.checkedAt
+	return nil
+}
+
+// fetch downloads the JWKS, discovering its URL from the issuer if needed
+func (s *keySet) fetch() (map[string]interface{}, error) {
+	if s.jwksURL == "" {
+		jwksURL, err := s.discover()
+		if err != nil {
+			return nil, err
+		}
+		s.jwksURL = jwksURL
+	}
+
+	var jwks JWKSet
+	if err := s.getJSON(s.jwksURL, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for i := range jwks.Keys {
+		jwk := &jwks.Keys[i]
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped so they cannot break the others
+		key, err := jwkToPublicKey(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// discover reads the JWKS URL from the issuer's OpenID Connect discovery document
+func (s *keySet) discover() (string, error) {
+	var document struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(s.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := s.getJSON(discoveryURL, &document); err != nil {
+		return "", fmt.Errorf("failed to discover OIDC configuration: %w", err)
+	}
+	if document.Issuer != s.config.Issuer {
+		return "", fmt.Errorf("discovered issuer %q does not match %q", document.Issuer, s.config.Issuer)
+	}
+	if document.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC configuration has no jwks_uri")
+	}
+	return document.JWKSURI, nil
+}
+
+func (s *keySet) getJSON(url string, v interface{}) error {
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwkToPublicKey converts a JWK to an RSA, ECDSA or Ed25519 public key
+func jwkToPublicKey(jwk *JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeKeyParam(jwk.N, "modulus")
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeKeyParam(jwk.E, "exponent")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := decodeKeyParam(jwk.X, "x coordinate")
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeKeyParam(jwk.Y, "y coordinate")
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return key, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := decodeKeyParam(jwk.X, "public key")
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key size: %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+func decodeKeyParam(value, name string) ([]byte, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return decoded, nil
+}
@@ -2,11 +2,8 @@ package auth
 
 import (
 	"context"
-	"crypto/rsa"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"net/http"
 	"strings"
 	"time"
@@ -15,33 +12,97 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// JWTValidator validates JWT tokens from Keycloak
+// OIDCConfig configures validation of tokens issued by an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer             string        `json:"issuer" mapstructure:"issuer"`                         // Must equal the token's iss claim
+	Audience           []string      `json:"audience" mapstructure:"audience"`                     // The token's aud must contain one of these (empty skips the check)
+	JWKSURL            string        `json:"jwksUrl" mapstructure:"jwksUrl"`                       // Discovered from the issuer when empty
+	Algorithms         []string      `json:"algorithms" mapstructure:"algorithms"`                 // Accepted signing algorithms
+	ClockSkew          time.Duration `json:"clockSkew" mapstructure:"clockSkew"`                   // Tolerance applied to exp, nbf and iat
+	RefreshInterval    time.Duration `json:"refreshInterval" mapstructure:"refreshInterval"`       // How often signing keys are refetched
+	MinRefreshInterval time.Duration `json:"minRefreshInterval" mapstructure:"minRefreshInterval"` // Minimum time between refetches for unknown key IDs
+	UsernameClaim      string        `json:"usernameClaim" mapstructure:"usernameClaim"`           // Claim paths are dot-separated, e.g. realm_access.roles
+	EmailClaim         string        `json:"emailClaim" mapstructure:"emailClaim"`
+	RolesClaim         string        `json:"rolesClaim" mapstructure:"rolesClaim"`
+	GroupsClaim        string        `json:"groupsClaim" mapstructure:"groupsClaim"`
+}
+
+// DefaultOIDCConfig returns the default OIDC configuration, mapping claims
+// the way Keycloak issues them
+func DefaultOIDCConfig() *OIDCConfig {
+	return &OIDCConfig{
+		Algorithms:         []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"},
+		ClockSkew:          time.Minute,
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: 30 * time.Second,
+		UsernameClaim:      "preferred_username",
+		EmailClaim:         "email",
+		RolesClaim:         "realm_access.roles",
+		GroupsClaim:        "groups",
+	}
+}
+
+// JWTValidator validates JWT tokens issued by an OpenID Connect provider
 type JWTValidator struct {
-	keycloakURL string
-	realm       string
-	publicKeys  map[string]*rsa.PublicKey
-	httpClient  *http.Client
+	config *OIDCConfig
+	keys   *keySet
 }
 
-// NewJWTValidator creates a new JWT validator
+// NewJWTValidator creates a JWT validator for a Keycloak realm
 func NewJWTValidator(keycloakURL, realm string) *JWTValidator {
-	return &JWTValidator{
-		keycloakURL: keycloakURL,
-		realm:       realm,
-		publicKeys:  make(map[string]*rsa.PublicKey),
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+	config := DefaultOIDCConfig()
+	config.Issuer = fmt.Sprintf("%s/realms/%s", strings.TrimSuffix(keycloakURL, "/"), realm)
+	config.JWKSURL = config.Issuer + "/protocol/openid-connect/certs"
+	return newJWTValidator(config)
+}
+
+// NewOIDCValidator creates a JWT validator for any OpenID Connect provider.
+// Empty or zero fields of config take their DefaultOIDCConfig values.
+func NewOIDCValidator(config *OIDCConfig) (*JWTValidator, error) {
+	if config == nil || config.Issuer == "" {
+		return nil, fmt.Errorf("OIDC issuer is required")
+	}
+
+	resolved := *config
+	defaults := DefaultOIDCConfig()
+	if len(resolved.Algorithms) == 0 {
+		resolved.Algorithms = defaults.Algorithms
+	}
+	if resolved.ClockSkew <= 0 {
+		resolved.ClockSkew = defaults.ClockSkew
+	}
+	if resolved.RefreshInterval <= 0 {
+		resolved.RefreshInterval = defaults.RefreshInterval
+	}
+	if resolved.MinRefreshInterval <= 0 {
+		// Without a minimum, every token naming an unknown key would fetch the keys
+		resolved.MinRefreshInterval = defaults.MinRefreshInterval
+	}
+	if resolved.UsernameClaim == "" {
+		resolved.UsernameClaim = defaults.UsernameClaim
 	}
+	if resolved.EmailClaim == "" {
+		resolved.EmailClaim = defaults.EmailClaim
+	}
+	if resolved.RolesClaim == "" {
+		resolved.RolesClaim = defaults.RolesClaim
+	}
+	if resolved.GroupsClaim == "" {
+		resolved.GroupsClaim = defaults.GroupsClaim
+	}
+	return newJWTValidator(&resolved), nil
 }
 
-// KeycloakClaims represents JWT claims from Keycloak
-type KeycloakClaims struct {
-	jwt.RegisteredClaims
-	Email             string                 `json:"email"`
-	PreferredUsername string                 `json:"preferred_username"`
-	RealmAccess       map[string]interface{} `json:"realm_access"`
-	Groups            []string               `json:"groups"`
+func newJWTValidator(config *OIDCConfig) *JWTValidator {
+	return &JWTValidator{
+		config: config,
+		keys: &keySet{
+			config:     config,
+			jwksURL:    config.JWKSURL,
+			keys:       make(map[string]interface{}),
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+		},
+	}
 }
 
 // JWK represents a JSON Web Key
@@ -50,8 +111,11 @@ type JWK struct {
 	Kty string `json:"kty"`
 	Alg string `json:"alg"`
 	Use string `json:"use"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
 }
 
 // JWKSet represents a set of JSON Web Keys
@@ -61,129 +125,142 @@ type JWKSet struct {
 
 // ValidateToken validates a JWT token and extracts user information
 func (v *JWTValidator) ValidateToken(tokenString string) (*models.User, error) {
-	// Parse token without verification to get the key ID
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &KeycloakClaims{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+	parser := &jwt.Parser{
+		ValidMethods: v.config.Algorithms,
+		// Time-based claims are checked below with the configured clock skew
+		SkipClaimsValidation: true,
 	}
 
-	// Get key ID from token header
-	kid, ok := token.Header["kid"].(string)
-	if !ok {
-		return nil, fmt.Errorf("token missing kid header")
-	}
-
-	// Get public key for verification
-	publicKey, err := v.getPublicKey(kid)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get public key: %w", err)
-	}
-
-	// Verify and parse token with public key
-	claims := &KeycloakClaims{}
-	token, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing algorithm
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return publicKey, nil
+	claims := jwt.MapClaims{}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.get(kid)
 	})
-
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate token: %w", err)
 	}
-
 	if !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
 
-	// Extract roles from realm access
-	roles := []string{}
-	if realmAccess, ok := claims.RealmAccess["roles"].([]interface{}); ok {
-		for _, role := range realmAccess {
-			if roleStr, ok := role.(string); ok {
-				roles = append(roles, roleStr)
-			}
-		}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("token missing sub claim")
+	}
+
+	// Build user model from the configured claim paths
+	username, _ := claimValue(claims, v.config.UsernameClaim).(string)
+	if username == "" {
+		username = subject
 	}
+	email, _ := claimValue(claims, v.config.EmailClaim).(string)
 
-	// Build user model
 	user := &models.User{
-		ID:       claims.Subject,
-		Username: claims.PreferredUsername,
-		Email:    claims.Email,
-		Roles:    roles,
-		Groups:   claims.Groups,
+		ID:       subject,
+		Username: username,
+		Email:    email,
+		Roles:    claimStrings(claims, v.config.RolesClaim),
+		Groups:   claimStrings(claims, v.config.GroupsClaim),
 	}
 
 	return user, nil
 }
 
-// getPublicKey fetches and caches the public key for token verification
-func (v *JWTValidator) getPublicKey(kid string) (*rsa.PublicKey, error) {
-	// Check cache first
-	if key, ok := v.publicKeys[kid]; ok {
-		return key, nil
+// validateClaims checks the issuer, audience and validity period of a token
+func (v *JWTValidator) validateClaims(claims jwt.MapClaims) error {
+	if issuer, _ := claims["iss"].(string); issuer != v.config.Issuer {
+		return fmt.Errorf("unexpected token issuer: %q", issuer)
 	}
 
-	// Fetch JWK set from Keycloak
-	jwksURL := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/certs", v.keycloakURL, v.realm)
-	resp, err := v.httpClient.Get(jwksURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	if len(v.config.Audience) > 0 {
+		matched := false
+		for _, audience := range v.config.Audience {
+			if claims.VerifyAudience(audience, true) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("token audience not accepted")
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
-	}
+	now := time.Now()
+	skew := v.config.ClockSkew
 
-	var jwks JWKSet
-	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
-		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	expiresAt, ok := numericClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("token missing exp claim")
+	}
+	if now.After(expiresAt.Add(skew)) {
+		return fmt.Errorf("token expired")
+	}
+	if notBefore, ok := numericClaim(claims, "nbf"); ok && now.Add(skew).Before(notBefore) {
+		return fmt.Errorf("token not valid yet")
+	}
+	if issuedAt, ok := numericClaim(claims, "iat"); ok && now.Add(skew).Before(issuedAt) {
+		return fmt.Errorf("token issued in the future")
 	}
 
-	// Find the key with matching kid
-	for _, jwk := range jwks.Keys {
-		if jwk.Kid == kid {
-			publicKey, err := v.jwkToPublicKey(&jwk)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert JWK to public key: %w", err)
-			}
+	return nil
+}
 
-			// Cache the key
-			v.publicKeys[kid] = publicKey
-			return publicKey, nil
+// numericClaim returns a NumericDate claim as a time
+func numericClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return time.Unix(0, int64(value*float64(time.Second))), true
+	case json.Number:
+		seconds, err := value.Float64()
+		if err != nil {
+			return time.Time{}, false
 		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), true
 	}
-
-	return nil, fmt.Errorf("public key not found for kid: %s", kid)
+	return time.Time{}, false
 }
 
-// jwkToPublicKey converts a JWK to an RSA public key
-func (v *JWTValidator) jwkToPublicKey(jwk *JWK) (*rsa.PublicKey, error) {
-	// Decode modulus
-	nBytes, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+// claimValue resolves a dot-separated claim path. A claim whose name itself
+// contains dots, such as a namespaced URL, is matched before the path is split.
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
 	}
-
-	// Decode exponent
-	eBytes, err := base64.RawURLEncoding.DecodeString(jwk.E)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	if value, ok := claims[path]; ok {
+		return value
 	}
 
-	// Convert exponent bytes to int
-	var eInt int
-	for _, b := range eBytes {
-		eInt = eInt<<8 + int(b)
+	name, rest, found := strings.Cut(path, ".")
+	if !found {
+		return nil
 	}
+	nested, ok := claims[name].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return claimValue(nested, rest)
+}
 
-	return &rsa.PublicKey{
-		N: new(big.Int).SetBytes(nBytes),
-		E: eInt,
-	}, nil
+// claimStrings resolves a claim path holding a string or a list of strings
+func claimStrings(claims map[string]interface{}, path string) []string {
+	values := []string{}
+	switch value := claimValue(claims, path).(type) {
+	case string:
+		if value != "" {
+			values = append(values, value)
+		}
+	case []interface{}:
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+	return values
 }
 
 // ExtractBearerToken extracts the bearer token from the Authorization header
@@ -0,0 +1,397 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// fakeIdP is an in-process OpenID Connect provider serving discovery and JWKS
+type fakeIdP struct {
+	server      *httptest.Server
+	mu          sync.Mutex
+	keys        []auth.JWK
+	jwksFetches int
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   idp.server.URL,
+			"jwks_uri": idp.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksFetches++
+		json.NewEncoder(w).Encode(auth.JWKSet{Keys: idp.keys})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// addKey publishes a public key under a key ID
+func (idp *fakeIdP) addKey(t *testing.T, kid string, key crypto.PublicKey) {
+	jwk := auth.JWK{Kid: kid, Use: "sig"}
+	encode := base64.RawURLEncoding.EncodeToString
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty, jwk.N, jwk.E = "RSA", encode(k.N.Bytes()), encode(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty, jwk.Crv = "EC", k.Curve.Params().Name
+		jwk.X, jwk.Y = encode(k.X.FillBytes(make([]byte, size))), encode(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", encode(k)
+	default:
+		t.Fatalf("unsupported key type %T", key)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = append(idp.keys, jwk)
+}
+
+func (idp *fakeIdP) fetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksFetches
+}
+
+// claims returns valid claims for the provider, overridden by extra
+func (idp *fakeIdP) claims(extra jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"sub":                "user-1",
+		"aud":                "astore",
+		"exp":                now.Add(time.Hour).Unix(),
+		"iat":                now.Unix(),
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	return claims
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	test.AssertNoError(t, err, "signing token")
+	return signed
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNoError(t, err, "generating RSA key")
+	return key
+}
+
+func newOIDCValidator(t *testing.T, idp *fakeIdP, configure func(*auth.OIDCConfig)) *auth.JWTValidator {
+	config := auth.DefaultOIDCConfig()
+	config.Issuer = idp.server.URL
+	config.Audience = []string{"astore"}
+	config.MinRefreshInterval = time.Nanosecond // Refetch on every unknown key ID
+	if configure != nil {
+		configure(config)
+	}
+	validator, err := auth.NewOIDCValidator(config)
+	test.AssertNoError(t, err, "creating OIDC validator")
+	return validator
+}
+
+func TestOIDCValidator(t *testing.T) {
+	t.Run("Validates tokens using discovered keys", func(t *testing.T) {
+		// Given: A provider publishing an RSA key
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, nil)
+
+		// When: Validating a token signed with it
+		user, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(jwt.MapClaims{
+			"realm_access": map[string]interface{}{"roles": []string{"developer"}},
+			"groups":       []string{"platform"},
+		})))
+
+		// Then: The user is built from the default claims
+		test.AssertNoError(t, err, "validating token")
+		test.AssertEqual(t, "user-1", user.ID, "user ID")
+		test.AssertEqual(t, "alice", user.Username, "username")
+		test.AssertEqual(t, "alice@example.com", user.Email, "email")
+		test.AssertEqual(t, "developer", strings.Join(user.Roles, ","), "roles")
+		test.AssertEqual(t, "platform", strings.Join(user.Groups, ","), "groups")
+	})
+
+	t.Run("Supports ES256 and EdDSA keys", func(t *testing.T) {
+		// Given: A provider publishing ECDSA and Ed25519 keys
+		idp := newFakeIdP(t)
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNoError(t, err, "generating ECDSA key")
+		edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+		test.AssertNoError(t, err, "generating Ed25519 key")
+		idp.addKey(t, "ec-1", &ecKey.PublicKey)
+		idp.addKey(t, "ed-1", edPublic)
+		validator := newOIDCValidator(t, idp, nil)
+
+		// When/Then: Tokens signed with either key are accepted
+		_, err = validator.ValidateToken(sign(t, jwt.SigningMethodES256, "ec-1", ecKey, idp.claims(nil)))
+		test.AssertNoError(t, err, "validating ES256 token")
+		_, err = validator.ValidateToken(sign(t, jwt.SigningMethodEdDSA, "ed-1", edKey, idp.claims(nil)))
+		test.AssertNoError(t, err, "validating EdDSA token")
+	})
+
+	t.Run("Maps configured claim paths", func(t *testing.T) {
+		// Given: A validator reading Okta-style namespaced claims
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, func(config *auth.OIDCConfig) {
+			config.UsernameClaim = "name"
+			config.RolesClaim = "https://astore.example.com/roles"
+			config.GroupsClaim = "ext.teams"
+		})
+
+		// When: Validating a token carrying those claims
+		user, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(jwt.MapClaims{
+			"name":                             "Alice",
+			"https://astore.example.com/roles": "admin",
+			"ext":                              map[string]interface{}{"teams": []string{"sre", "platform"}},
+		})))
+
+		// Then: The user is built from the configured paths
+		test.AssertNoError(t, err, "validating token")
+		test.AssertEqual(t, "Alice", user.Username, "username")
+		test.AssertEqual(t, "admin", strings.Join(user.Roles, ","), "roles")
+		test.AssertEqual(t, "sre,platform", strings.Join(user.Groups, ","), "groups")
+	})
+
+	t.Run("Rejects tokens failing claim validation", func(t *testing.T) {
+		// Given: A validator with one minute of clock skew
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, nil)
+		now := time.Now()
+
+		cases := map[string]jwt.MapClaims{
+			"wrong issuer":     {"iss": "https://evil.example.com"},
+			"wrong audience":   {"aud": "other-service"},
+			"expired":          {"exp": now.Add(-2 * time.Minute).Unix()},
+			"missing expiry":   {"exp": nil},
+			"not yet valid":    {"nbf": now.Add(2 * time.Minute).Unix()},
+			"issued in future": {"iat": now.Add(2 * time.Minute).Unix()},
+			"missing subject":  {"sub": ""},
+		}
+		for name, extra := range cases {
+			// When: Validating the token
+			_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(extra)))
+
+			// Then: It is rejected
+			test.AssertError(t, err, name)
+		}
+
+		// And: Expiry within the clock skew is tolerated
+		_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(jwt.MapClaims{
+			"exp": now.Add(-30 * time.Second).Unix(),
+		})))
+		test.AssertNoError(t, err, "expiry within clock skew")
+	})
+
+	t.Run("Rejects disallowed algorithms", func(t *testing.T) {
+		// Given: A validator accepting only RS256
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, func(config *auth.OIDCConfig) {
+			config.Algorithms = []string{"RS256"}
+		})
+
+		// When: Validating an RS512 and an unsigned token
+		_, rs512Err := validator.ValidateToken(sign(t, jwt.SigningMethodRS512, "rsa-1", key, idp.claims(nil)))
+		_, noneErr := validator.ValidateToken(sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, idp.claims(nil)))
+
+		// Then: Both are rejected
+		test.AssertError(t, rs512Err, "RS512 token")
+		test.AssertError(t, noneErr, "unsigned token")
+	})
+
+	t.Run("Refetches keys for unknown key IDs", func(t *testing.T) {
+		// Given: A validator that has cached the provider's keys
+		idp := newFakeIdP(t)
+		oldKey := newRSAKey(t)
+		idp.addKey(t, "old", &oldKey.PublicKey)
+		validator := newOIDCValidator(t, idp, nil)
+		_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "old", oldKey, idp.claims(nil)))
+		test.AssertNoError(t, err, "validating token with old key")
+
+		// When: The provider rotates to a new key
+		newKey := newRSAKey(t)
+		idp.addKey(t, "new", &newKey.PublicKey)
+		_, err = validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "new", newKey, idp.claims(nil)))
+
+		// Then: The new key is fetched on the miss
+		test.AssertNoError(t, err, "validating token with new key")
+		test.AssertEqual(t, 2, idp.fetches(), "JWKS fetches")
+	})
+
+	t.Run("Rate limits refetches for unknown key IDs", func(t *testing.T) {
+		// Given: A validator with a minimum refresh interval
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, func(config *auth.OIDCConfig) {
+			config.MinRefreshInterval = time.Hour
+		})
+
+		// When: Validating tokens naming unknown keys
+		for i := 0; i < 3; i++ {
+			_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "unknown", key, idp.claims(nil)))
+			test.AssertError(t, err, "unknown key")
+		}
+
+		// Then: The provider is asked only once
+		test.AssertEqual(t, 1, idp.fetches(), "JWKS fetches")
+	})
+
+	t.Run("Defaults unset intervals", func(t *testing.T) {
+		// Given: A validator configured without clock skew or minimum refresh interval
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, func(config *auth.OIDCConfig) {
+			config.ClockSkew = 0
+			config.MinRefreshInterval = 0
+		})
+
+		// When: Validating tokens naming unknown keys
+		for i := 0; i < 3; i++ {
+			_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "unknown", key, idp.claims(nil)))
+			test.AssertError(t, err, "unknown key")
+		}
+
+		// Then: The provider is asked only once within the default interval
+		test.AssertEqual(t, 1, idp.fetches(), "JWKS fetches")
+
+		// And: The default clock skew is applied
+		_, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(jwt.MapClaims{
+			"exp": time.Now().Add(-30 * time.Second).Unix(),
+		})))
+		test.AssertNoError(t, err, "expiry within default clock skew")
+	})
+
+	t.Run("Refreshes keys periodically", func(t *testing.T) {
+		// Given: A validator with a short refresh interval
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, func(config *auth.OIDCConfig) {
+			config.RefreshInterval = 10 * time.Millisecond
+		})
+		token := sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(nil))
+		_, err := validator.ValidateToken(token)
+		test.AssertNoError(t, err, "first validation")
+
+		// When: Validating again after the interval
+		time.Sleep(20 * time.Millisecond)
+		_, err = validator.ValidateToken(token)
+
+		// Then: The keys are refetched
+		test.AssertNoError(t, err, "second validation")
+		test.AssertEqual(t, 2, idp.fetches(), "JWKS fetches")
+	})
+
+	t.Run("Validates tokens concurrently", func(t *testing.T) {
+		// Given: A validator with no cached keys
+		idp := newFakeIdP(t)
+		key := newRSAKey(t)
+		idp.addKey(t, "rsa-1", &key.PublicKey)
+		validator := newOIDCValidator(t, idp, nil)
+		token := sign(t, jwt.SigningMethodRS256, "rsa-1", key, idp.claims(nil))
+
+		// When: Validating from many goroutines at once
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := validator.ValidateToken(token)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Then: Every validation succeeds with a single fetch
+		for err := range errs {
+			test.AssertNoError(t, err, "concurrent validation")
+		}
+		test.AssertEqual(t, 1, idp.fetches(), "JWKS fetches")
+	})
+
+	t.Run("Requires an issuer", func(t *testing.T) {
+		// Given: A configuration without an issuer
+		config := auth.DefaultOIDCConfig()
+
+		// When: Creating a validator
+		_, err := auth.NewOIDCValidator(config)
+
+		// Then: Creation fails
+		test.AssertError(t, err, "validator without issuer")
+	})
+}
+
+func TestKeycloakValidator(t *testing.T) {
+	// Given: A Keycloak realm publishing its certs
+	key := newRSAKey(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/realms/astore/protocol/openid-connect/certs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.JWKSet{Keys: []auth.JWK{{
+			Kid: "kc-1",
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	validator := auth.NewJWTValidator(server.URL, "astore")
+
+	// When: Validating a Keycloak token
+	user, err := validator.ValidateToken(sign(t, jwt.SigningMethodRS256, "kc-1", key, jwt.MapClaims{
+		"iss":                server.URL + "/realms/astore",
+		"sub":                "user-1",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"preferred_username": "alice",
+		"realm_access":       map[string]interface{}{"roles": []string{"admin"}},
+	}))
+
+	// Then: Realm roles are mapped
+	test.AssertNoError(t, err, "validating Keycloak token")
+	test.AssertEqual(t, "alice", user.Username, "username")
+	test.AssertEqual(t, "admin", strings.Join(user.Roles, ","), "roles")
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+// ldifUserSource provides the users of an LDIF file, such as an export of
+// an LDAP directory or a local stand-in for one. The file is read again
+// whenever it changes.
+type ldifUserSource struct {
+	path       string
+	groupRoles map[string]string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	users   []*models.User
+}
+
+// NewLDIFUserSource creates a user source reading an LDIF file. Entries
+// with a uid are users; their groups are the groups whose member,
+// uniqueMember or memberUid names them and the first RDN values of their
+// memberOf DNs. groupRoles maps group names to the role their members have.
+func NewLDIFUserSource(path string, groupRoles map[string]string) UserSource {
+	return &ldifUserSource{path: path, groupRoles: groupRoles}
+}
+
+func (s *ldifUserSource) LookupUser(name string) (*models.User, error) {
+	users, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.ID == name || user.Username == name {
+			return user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *ldifUserSource) ListUsers() ([]*models.User, error) {
+	return s.load()
+}
+
+func (s *ldifUserSource) String() string {
+	return s.path
+}
+
+// load returns the users of the file, parsing it again if it changed
+func (s *ldifUserSource) load() ([]*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LDIF file: %w", err)
+	}
+	if s.users != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.users, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LDIF file: %w", err)
+	}
+	entries, err := parseLDIF(data)
+	if err != nil {
+		return nil, err
+	}
+	s.users = ldifUsers(entries, s.groupRoles)
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return s.users, nil
+}
+
+// ldifEntry is an LDIF entry's DN and attributes, keyed by lower-case name
+type ldifEntry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+func (e *ldifEntry) first(name string) string {
+	if values := e.attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseLDIF parses the entries of an LDIF content file
+func parseLDIF(data []byte) ([]*ldifEntry, error) {
+	var entries []*ldifEntry
+	var lines []string
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry := &ldifEntry{attributes: make(map[string][]string)}
+		for _, line := range lines {
+			name, value, err := parseLDIFLine(line)
+			if err != nil {
+				return err
+			}
+			if name == "dn" {
+				entry.dn = value
+				continue
+			}
+			entry.attributes[name] = append(entry.attributes[name], value)
+		}
+		if entry.dn != "" {
+			entries = append(entries, entry)
+		}
+		lines = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " "):
+			// A folded line continues the previous one
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("invalid LDIF: continuation without a line")
+			}
+			lines[len(lines)-1] += line[1:]
+		case len(lines) == 0 && strings.HasPrefix(strings.ToLower(line), "version:"):
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LDIF: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseLDIFLine parses an attribute line, decoding base64 values
+func parseLDIFLine(line string) (string, string, error) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid LDIF line %q", line)
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		// Attribute options such as ;lang-en
+		name = name[:i]
+	}
+	if strings.HasPrefix(value, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid base64 value of %s: %w", name, err)
+		}
+		return name, string(decoded), nil
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// ldifUsers builds the users of LDIF entries and assigns their groups and roles
+func ldifUsers(entries []*ldifEntry, groupRoles map[string]string) []*models.User {
+	var users []*models.User
+	byDN := make(map[string]*models.User)
+	byUID := make(map[string]*models.User)
+	groups := make(map[*models.User]map[string]bool)
+	addGroup := func(user *models.User, group string) {
+		if user == nil || group == "" {
+			return
+		}
+		if groups[user] == nil {
+			groups[user] = make(map[string]bool)
+		}
+		groups[user][group] = true
+	}
+
+	for _, entry := range entries {
+		uid := entry.first("uid")
+		if uid == "" {
+			continue
+		}
+		user := &models.User{ID: uid, Username: uid, Email: entry.first("mail"), Metadata: map[string]string{"source": "ldif", "dn": entry.dn}}
+		users = append(users, user)
+		byDN[normalizeDN(entry.dn)] = user
+		byUID[uid] = user
+		for _, groupDN := range entry.attributes["memberof"] {
+			addGroup(user, rdnValue(groupDN))
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.first("uid") != "" {
+			continue
+		}
+		group := entry.first("cn")
+		if group == "" {
+			group = rdnValue(entry.dn)
+		}
+		for _, attribute := range []string{"member", "uniquemember"} {
+			for _, memberDN := range entry.attributes[attribute] {
+				addGroup(byDN[normalizeDN(memberDN)], group)
+			}
+		}
+		for _, uid := range entry.attributes["memberuid"] {
+			addGroup(byUID[uid], group)
+		}
+	}
+
+	for _, user := range users {
+		roles := make(map[string]bool)
+		for group := range groups[user] {
+			user.Groups = append(user.Groups, group)
+			if role := groupRoles[group]; role != "" {
+				roles[role] = true
+			}
+		}
+		for role := range roles {
+			user.Roles = append(user.Roles, role)
+		}
+		sort.Strings(user.Groups)
+		sort.Strings(user.Roles)
+	}
+	return users
+}
+
+// rdnValue returns the value of a DN's first RDN, e.g. developers for
+// cn=developers,ou=groups,dc=example,dc=com
+func rdnValue(dn string) string {
+	rdn, _, _ := strings.Cut(dn, ",")
+	_, value, ok := strings.Cut(rdn, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// normalizeDN lower-cases a DN and removes spaces around its separators
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		name, value, _ := strings.Cut(part, "=")
+		parts[i] = strings.TrimSpace(name) + "=" + strings.TrimSpace(value)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -12,6 +14,12 @@ import (
 // Middleware provides authentication and authorization middleware
 type Middleware struct {
 	jwtValidator *JWTValidator
+	apiTokens    *APITokenManager          // Optional; nil rejects API tokens
+	certificates *CertificateAuthenticator // Optional; nil ignores client certificates
+	objects      ObjectResolver            // Optional; nil fails object policy conditions
+	audit        *AuditLogger              // Optional; nil records no authorization decisions
+	knownUsers   *KnownUsers               // Optional; nil records no authenticated users
+	proxies      *TrustedProxies           // Optional; nil ignores forwarding headers
 	policyEngine *PolicyEngine
 	logger       log.Logger
 	enabled      bool
@@ -27,7 +35,45 @@ func NewMiddleware(jwtValidator *JWTValidator, policyEngine *PolicyEngine, logge
 	}
 }
 
-// AuthenticateRequest is middleware that validates JWT tokens
+// SetAPITokenManager enables authentication with API tokens alongside JWTs
+func (m *Middleware) SetAPITokenManager(apiTokens *APITokenManager) {
+	m.apiTokens = apiTokens
+}
+
+// SetCertificateAuthenticator enables authentication with verified TLS
+// client certificates
+func (m *Middleware) SetCertificateAuthenticator(certificates *CertificateAuthenticator) {
+	m.certificates = certificates
+}
+
+// SetAuditLogger records the authorization decisions of AuthorizeRoutes in
+// the audit log
+func (m *Middleware) SetAuditLogger(audit *AuditLogger) {
+	m.audit = audit
+}
+
+// SetKnownUsers records users who authenticate, so the user directory can
+// answer queries about them later
+func (m *Middleware) SetKnownUsers(knownUsers *KnownUsers) {
+	m.knownUsers = knownUsers
+}
+
+// SetTrustedProxies believes the forwarding headers of trusted proxies when
+// evaluating sourceIp policy conditions
+func (m *Middleware) SetTrustedProxies(proxies *TrustedProxies) {
+	m.proxies = proxies
+}
+
+// SetObjectResolver sets the resolver policy conditions use to look up the
+// objects requests act on
+func (m *Middleware) SetObjectResolver(objects ObjectResolver) {
+	m.objects = objects
+}
+
+// AuthenticateRequest is middleware that authenticates requests with JWT or
+// API bearer tokens, or with a verified TLS client certificate. When both
+// are presented the token identifies the user and the certificate's user is
+// recorded in the user's metadata.
 func (m *Middleware) AuthenticateRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication if disabled
@@ -36,10 +82,44 @@ func (m *Middleware) AuthenticateRequest(next http.Handler) http.Handler {
 			return
 		}
 
+		// Authenticate the client certificate, if certificates are enabled
+		var certUser *models.User
+		if m.certificates != nil {
+			var err error
+			certUser, err = m.certificates.Authenticate(r.TLS)
+			if errors.Is(err, ErrNoClientCertificate) && m.certificates.Required() {
+				http.Error(w, "Client certificate required", http.StatusUnauthorized)
+				return
+			}
+			if err != nil && !errors.Is(err, ErrNoClientCertificate) {
+				m.logger.Error().Err(err).Msg("failed to authenticate client certificate")
+				http.Error(w, "Invalid client certificate", http.StatusUnauthorized)
+				return
+			}
+		}
+
 		// Try to extract bearer token
+		var user *models.User
 		token, err := ExtractBearerToken(r)
-		if err != nil {
-			// No token - check if anonymous access is allowed
+		switch {
+		case err == nil:
+			// Validate token
+			user, err = m.validateToken(token)
+			if err != nil {
+				m.logger.Error().Err(err).Msg("failed to validate token")
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+			if certUser != nil {
+				if user.Metadata == nil {
+					user.Metadata = make(map[string]string)
+				}
+				user.Metadata["clientCertificate"] = certUser.ID
+			}
+		case certUser != nil:
+			user = certUser
+		default:
+			// No credentials - check if anonymous access is allowed
 			authCtx := &models.AuthContext{
 				User:        nil,
 				Permissions: m.policyEngine.GetPermissions(nil),
@@ -51,12 +131,8 @@ func (m *Middleware) AuthenticateRequest(next http.Handler) http.Handler {
 			return
 		}
 
-		// Validate token
-		user, err := m.jwtValidator.ValidateToken(token)
-		if err != nil {
-			m.logger.Error().Err(err).Msg("failed to validate token")
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
+		if m.knownUsers != nil {
+			m.knownUsers.Record(user)
 		}
 
 		// Get user permissions
@@ -82,6 +158,20 @@ func (m *Middleware) AuthenticateRequest(next http.Handler) http.Handler {
 	})
 }
 
+// validateToken validates an API token or a JWT
+func (m *Middleware) validateToken(token string) (*models.User, error) {
+	if IsAPIToken(token) {
+		if m.apiTokens == nil {
+			return nil, fmt.Errorf("API tokens are not enabled")
+		}
+		return m.apiTokens.ValidateToken(token)
+	}
+	if m.jwtValidator == nil {
+		return nil, fmt.Errorf("JWT validation is not configured")
+	}
+	return m.jwtValidator.ValidateToken(token)
+}
+
 // RequireAuth ensures a user is authenticated
 func (m *Middleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -116,7 +206,7 @@ func (m *Middleware) AuthorizeAction(resource string, action models.Action) func
 			}
 
 			// Authorize the action
-			authorized, err := m.policyEngine.Authorize(authCtx.User, resource, action)
+			authorized, err := m.policyEngine.Authorize(authCtx.User, resource, action, m.requestContext(r, action))
 			if err != nil || !authorized {
 				m.logger.Warn().
 					Str("user", getUsername(authCtx.User)).
@@ -154,7 +244,7 @@ func MapMethodToAction(method string) models.Action {
 	switch method {
 	case http.MethodGet, http.MethodHead:
 		return models.ActionRead
-	case http.MethodPut, http.MethodPost:
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
 		return models.ActionWrite
 	case http.MethodDelete:
 		return models.ActionDelete
@@ -170,3 +260,13 @@ func getUsername(user *models.User) string {
 	}
 	return user.Username
 }
+
+// requestContext builds the request context policies are evaluated against,
+// with the client IP forwarded by trusted proxies
+func (m *Middleware) requestContext(r *http.Request, action models.Action) *RequestContext {
+	reqCtx := NewRequestContext(r, action, m.objects)
+	if m.proxies != nil {
+		reqCtx.SourceIP = m.proxies.ClientIP(r)
+	}
+	return reqCtx
+}
@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Certificate fields a user ID can be taken from
+const (
+	CertificateUserIDFromCN    = "cn"
+	CertificateUserIDFromURI   = "san-uri"
+	CertificateUserIDFromDNS   = "san-dns"
+	CertificateUserIDFromEmail = "san-email"
+)
+
+// AuthMethodClientCertificate is recorded in User.Metadata["authMethod"] for
+// users authenticated by a TLS client certificate
+const AuthMethodClientCertificate = "client-certificate"
+
+// ErrNoClientCertificate is returned when a request has no verified client certificate
+var ErrNoClientCertificate = errors.New("no verified client certificate")
+
+// CertificateConfig configures authentication with TLS client certificates.
+// Certificates are verified against the CA by the TLS server; this only maps
+// verified certificates to users.
+type CertificateConfig struct {
+	UserIDFrom   string            `json:"userIdFrom" mapstructure:"userIdFrom"`     // cn (default), san-uri, san-dns or san-email
+	URIPrefix    string            `json:"uriPrefix" mapstructure:"uriPrefix"`       // Only SAN URIs with this prefix are used, and the prefix is stripped
+	GroupsFromOU bool              `json:"groupsFromOU" mapstructure:"groupsFromOU"` // Subject organizational units become groups
+	Rules        []CertificateRule `json:"rules" mapstructure:"rules"`
+	AllowedUsers []string          `json:"allowedUsers" mapstructure:"allowedUsers"` // User ID patterns allowed to authenticate (empty allows all)
+	CRLFiles     []string          `json:"crlFiles" mapstructure:"crlFiles"`         // PEM or DER certificate revocation lists
+	CRLReload    time.Duration     `json:"crlReload" mapstructure:"crlReload"`       // How often CRLFiles are reloaded (default 1h)
+	Required     bool              `json:"required" mapstructure:"required"`         // Reject requests without a certificate, even with a bearer token
+}
+
+// CertificateRule grants roles and groups to certificate users whose ID
+// matches a pattern
+type CertificateRule struct {
+	Match    string   `json:"match" mapstructure:"match"`       // path.Match pattern on the user ID, e.g. build-*
+	IssuerCN string   `json:"issuerCN" mapstructure:"issuerCN"` // Optional issuing CA common name
+	Roles    []string `json:"roles" mapstructure:"roles"`
+	Groups   []string `json:"groups" mapstructure:"groups"`
+}
+
+// DefaultCertificateConfig returns the default client certificate
+// configuration: the subject CN is the user ID and OUs are groups
+func DefaultCertificateConfig() *CertificateConfig {
+	return &CertificateConfig{
+		UserIDFrom:   CertificateUserIDFromCN,
+		GroupsFromOU: true,
+		CRLReload:    time.Hour,
+	}
+}
+
+// CertificateAuthenticator maps verified TLS client certificates to users
+type CertificateAuthenticator struct {
+	config *CertificateConfig
+	logger log.Logger
+
+	mu   sync.RWMutex
+	crls []*x509.RevocationList
+}
+
+// NewCertificateAuthenticator creates a certificate authenticator, loading its CRLs
+func NewCertificateAuthenticator(config *CertificateConfig, logger log.Logger) (*CertificateAuthenticator, error) {
+	switch config.UserIDFrom {
+	case "":
+		resolved := *config
+		resolved.UserIDFrom = CertificateUserIDFromCN
+		config = &resolved
+	case CertificateUserIDFromCN, CertificateUserIDFromURI, CertificateUserIDFromDNS, CertificateUserIDFromEmail:
+	default:
+		return nil, fmt.Errorf("unknown certificate user ID field: %s", config.UserIDFrom)
+	}
+	for _, pattern := range append(append([]string{}, config.AllowedUsers...), rulePatterns(config.Rules)...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid certificate user pattern %q: %w", pattern, err)
+		}
+	}
+
+	if config.CRLReload <= 0 {
+		resolved := *config
+		resolved.CRLReload = DefaultCertificateConfig().CRLReload
+		config = &resolved
+	}
+
+	a := &CertificateAuthenticator{config: config, logger: logger}
+	if err := a.ReloadCRLs(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ReloadCRLs reads CRLFiles again, so revocations published since they were
+// last read take effect. If any file cannot be read, the CRLs in use are kept.
+// CRLs past their next update are still used, since their revocations remain
+// valid, but are logged so the stale file is noticed.
+func (a *CertificateAuthenticator) ReloadCRLs() error {
+	var crls []*x509.RevocationList
+	for _, file := range a.config.CRLFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read CRL: %w", err)
+		}
+		if block, _ := pem.Decode(data); block != nil {
+			data = block.Bytes
+		}
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse CRL %s: %w", file, err)
+		}
+		if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+			a.logger.Warn().Str("file", file).Time("nextUpdate", crl.NextUpdate).Msg("CRL is past its next update")
+		}
+		crls = append(crls, crl)
+	}
+
+	a.mu.Lock()
+	a.crls = crls
+	a.mu.Unlock()
+	return nil
+}
+
+// RunCRLReload reloads the CRLs every CRLReload interval until the context
+// is cancelled. It returns at once if no CRL files are configured.
+func (a *CertificateAuthenticator) RunCRLReload(ctx context.Context) {
+	if len(a.config.CRLFiles) == 0 {
+		return
+	}
+	ticker := time.NewTicker(a.config.CRLReload)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.ReloadCRLs(); err != nil {
+				a.logger.Error().Err(err).Msg("failed to reload CRLs, keeping the previous ones")
+			}
+		}
+	}
+}
+
+// Required reports whether requests must present a client certificate
+func (a *CertificateAuthenticator) Required() bool {
+	return a.config.Required
+}
+
+// Authenticate returns the user of the verified client certificate of a
+// TLS connection, or ErrNoClientCertificate if there is none
+func (a *CertificateAuthenticator) Authenticate(state *tls.ConnectionState) (*models.User, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, ErrNoClientCertificate
+	}
+	chain := state.VerifiedChains[0]
+	cert := chain[0]
+
+	if err := a.checkRevocation(chain); err != nil {
+		return nil, err
+	}
+
+	userID, err := a.userID(cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.config.AllowedUsers) > 0 && !matchesAny(a.config.AllowedUsers, userID) {
+		return nil, fmt.Errorf("certificate user %s is not allowed", userID)
+	}
+
+	user := &models.User{
+		ID:       userID,
+		Username: userID,
+		Roles:    []string{},
+		Groups:   []string{},
+		Metadata: map[string]string{
+			"authMethod":        AuthMethodClientCertificate,
+			"certificateSerial": cert.SerialNumber.String(),
+		},
+	}
+	if len(cert.EmailAddresses) > 0 {
+		user.Email = cert.EmailAddresses[0]
+	}
+	if a.config.GroupsFromOU {
+		user.Groups = append(user.Groups, cert.Subject.OrganizationalUnit...)
+	}
+	for _, rule := range a.config.Rules {
+		if rule.IssuerCN != "" && rule.IssuerCN != cert.Issuer.CommonName {
+			continue
+		}
+		if matched, _ := path.Match(rule.Match, userID); matched {
+			user.Roles = append(user.Roles, rule.Roles...)
+			user.Groups = append(user.Groups, rule.Groups...)
+		}
+	}
+	return user, nil
+}
+
+// userID takes the user ID from the configured certificate field
+func (a *CertificateAuthenticator) userID(cert *x509.Certificate) (string, error) {
+	switch a.config.UserIDFrom {
+	case CertificateUserIDFromURI:
+		for _, uri := range cert.URIs {
+			if value := uri.String(); strings.HasPrefix(value, a.config.URIPrefix) {
+				return strings.TrimPrefix(value, a.config.URIPrefix), nil
+			}
+		}
+	case CertificateUserIDFromDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0], nil
+		}
+	case CertificateUserIDFromEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0], nil
+		}
+	default:
+		if cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName, nil
+		}
+	}
+	return "", fmt.Errorf("client certificate has no %s to identify its user", a.config.UserIDFrom)
+}
+
+// checkRevocation rejects a certificate listed by a CRL of its issuer. CRLs
+// whose signature does not verify against the issuer are ignored. A chain
+// without the issuer certificate cannot verify its issuer's CRLs, so the
+// certificate is rejected when there are any.
+func (a *CertificateAuthenticator) checkRevocation(chain []*x509.Certificate) error {
+	cert := chain[0]
+	var issuer *x509.Certificate
+	if len(chain) > 1 {
+		issuer = chain[1]
+	} else if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		issuer = cert
+	}
+
+	a.mu.RLock()
+	crls := a.crls
+	a.mu.RUnlock()
+
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if issuer == nil {
+			return fmt.Errorf("revocation of client certificate %s cannot be checked without its issuer certificate", cert.SerialNumber)
+		}
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, revoked := range crl.RevokedCertificateEntries {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return fmt.Errorf("client certificate %s is revoked", cert.SerialNumber)
+			}
+		}
+	}
+	return nil
+}
+
+func rulePatterns(rules []CertificateRule) []string {
+	patterns := make([]string, len(rules))
+	for i, rule := range rules {
+		patterns[i] = rule.Match
+	}
+	return patterns
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
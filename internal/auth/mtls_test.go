@@ -0,0 +1,350 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+// testCA is a certificate authority generated for a test
+type testCA struct {
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNoError(t, err, "generating CA key")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	test.AssertNoError(t, err, "creating CA certificate")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNoError(t, err, "parsing CA certificate")
+	return &testCA{cert: cert, key: key, serial: 1}
+}
+
+// issue creates a client certificate from a subject template
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNoError(t, err, "generating client key")
+	ca.serial++
+	template.SerialNumber = big.NewInt(ca.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	test.AssertNoError(t, err, "creating client certificate")
+	leaf, err := x509.ParseCertificate(der)
+	test.AssertNoError(t, err, "parsing client certificate")
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// revoke writes a CRL revoking certificates and returns its path
+func (ca *testCA) revoke(t *testing.T, certs ...tls.Certificate) string {
+	list := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, cert := range certs {
+		list.RevokedCertificateEntries = append(list.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.Leaf.SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, list, ca.cert, ca.key)
+	test.AssertNoError(t, err, "creating CRL")
+	path := filepath.Join(t.TempDir(), "ca.crl")
+	test.AssertNoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600), "writing CRL")
+	return path
+}
+
+// verified returns the connection state of a TLS server that verified cert
+func (ca *testCA) verified(cert tls.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert.Leaf, ca.cert}}}
+}
+
+func newCertificateAuthenticator(t *testing.T, configure func(*auth.CertificateConfig)) *auth.CertificateAuthenticator {
+	config := auth.DefaultCertificateConfig()
+	if configure != nil {
+		configure(config)
+	}
+	authenticator, err := auth.NewCertificateAuthenticator(config, test.NewTestLogger(t))
+	test.AssertNoError(t, err, "creating certificate authenticator")
+	return authenticator
+}
+
+func TestCertificateAuthenticator(t *testing.T) {
+	ca := newTestCA(t, "Build Farm CA")
+
+	t.Run("Maps the subject to a user", func(t *testing.T) {
+		// Given: Rules granting roles to build agents issued by the build farm CA
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.Rules = []auth.CertificateRule{
+				{Match: "build-*", IssuerCN: "Build Farm CA", Roles: []string{"uploader"}},
+				{Match: "build-*", IssuerCN: "Other CA", Roles: []string{"admin"}},
+			}
+		})
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-42", OrganizationalUnit: []string{"ci", "linux"}}})
+
+		// When: Authenticating the certificate
+		user, err := authenticator.Authenticate(ca.verified(cert))
+
+		// Then: The CN is the user, OUs are groups and matching rules add roles
+		test.AssertNoError(t, err, "authenticating certificate")
+		test.AssertEqual(t, "build-42", user.ID, "user ID")
+		test.AssertEqual(t, "ci,linux", strings.Join(user.Groups, ","), "groups")
+		test.AssertEqual(t, "uploader", strings.Join(user.Roles, ","), "roles")
+		test.AssertEqual(t, auth.AuthMethodClientCertificate, user.Metadata["authMethod"], "auth method")
+	})
+
+	t.Run("Maps SAN URIs with a prefix", func(t *testing.T) {
+		// Given: Users identified by SPIFFE IDs
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.UserIDFrom = auth.CertificateUserIDFromURI
+			config.URIPrefix = "spiffe://build.example.com/"
+		})
+		other, _ := url.Parse("https://example.com/agent")
+		spiffe, _ := url.Parse("spiffe://build.example.com/agent/7")
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ignored"}, URIs: []*url.URL{other, spiffe}})
+
+		// When: Authenticating the certificate
+		user, err := authenticator.Authenticate(ca.verified(cert))
+
+		// Then: The matching URI without its prefix is the user
+		test.AssertNoError(t, err, "authenticating certificate")
+		test.AssertEqual(t, "agent/7", user.ID, "user ID")
+	})
+
+	t.Run("Rejects users outside the allowlist", func(t *testing.T) {
+		// Given: An allowlist of build agents
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.AllowedUsers = []string{"build-*"}
+		})
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "laptop-1"}})
+
+		// When: Authenticating another certificate
+		_, err := authenticator.Authenticate(ca.verified(cert))
+
+		// Then: It is rejected
+		test.AssertError(t, err, "certificate outside allowlist")
+	})
+
+	t.Run("Rejects revoked certificates", func(t *testing.T) {
+		// Given: A CRL revoking one of two certificates
+		revoked := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-1"}})
+		valid := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-2"}})
+		crl := ca.revoke(t, revoked)
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.CRLFiles = []string{crl}
+		})
+
+		// When/Then: Only the certificate not on the CRL is accepted
+		_, err := authenticator.Authenticate(ca.verified(revoked))
+		test.AssertError(t, err, "revoked certificate")
+		_, err = authenticator.Authenticate(ca.verified(valid))
+		test.AssertNoError(t, err, "valid certificate")
+	})
+
+	t.Run("Ignores CRLs of other issuers", func(t *testing.T) {
+		// Given: A CRL from another CA listing the same serial number
+		other := newTestCA(t, "Other CA")
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-3"}})
+		crl := other.revoke(t, cert)
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.CRLFiles = []string{crl}
+		})
+
+		// When: Authenticating the certificate
+		_, err := authenticator.Authenticate(ca.verified(cert))
+
+		// Then: It is accepted
+		test.AssertNoError(t, err, "certificate not revoked by its issuer")
+	})
+
+	t.Run("Rejects chains without the issuer of a CRL", func(t *testing.T) {
+		// Given: A CRL of the certificate's issuer
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-4"}})
+		crl := ca.revoke(t)
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.CRLFiles = []string{crl}
+		})
+
+		// When: Authenticating a chain without the issuer certificate
+		_, err := authenticator.Authenticate(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert.Leaf}}})
+
+		// Then: It is rejected, as the CRL cannot be verified
+		test.AssertError(t, err, "chain without issuer")
+	})
+
+	t.Run("Reloaded CRLs take effect", func(t *testing.T) {
+		// Given: An authenticator whose CRL revokes nothing yet
+		cert := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-5"}})
+		crl := ca.revoke(t)
+		authenticator := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.CRLFiles = []string{crl}
+		})
+		_, err := authenticator.Authenticate(ca.verified(cert))
+		test.AssertNoError(t, err, "certificate before revocation")
+
+		// When: The CRL file is replaced by one revoking the certificate and reloaded
+		updated, err := os.ReadFile(ca.revoke(t, cert))
+		test.AssertNoError(t, err, "reading updated CRL")
+		test.AssertNoError(t, os.WriteFile(crl, updated, 0600), "replacing CRL")
+		test.AssertNoError(t, authenticator.ReloadCRLs(), "reloading CRLs")
+
+		// Then: The certificate is rejected
+		_, err = authenticator.Authenticate(ca.verified(cert))
+		test.AssertError(t, err, "certificate after revocation")
+
+		// And: A broken CRL file keeps the CRLs in use
+		test.AssertNoError(t, os.WriteFile(crl, []byte("not a CRL"), 0600), "breaking CRL")
+		test.AssertError(t, authenticator.ReloadCRLs(), "reloading broken CRL")
+		_, err = authenticator.Authenticate(ca.verified(cert))
+		test.AssertError(t, err, "certificate still revoked")
+	})
+
+	t.Run("Reports connections without a verified certificate", func(t *testing.T) {
+		// Given: A plain connection
+		authenticator := newCertificateAuthenticator(t, nil)
+
+		// When: Authenticating it
+		_, err := authenticator.Authenticate(&tls.ConnectionState{})
+
+		// Then: There is no certificate
+		test.AssertTrue(t, errors.Is(err, auth.ErrNoClientCertificate), "no client certificate")
+	})
+
+	t.Run("Rejects invalid configuration", func(t *testing.T) {
+		// Given/When: Configurations with an unknown field or bad pattern
+		_, fieldErr := auth.NewCertificateAuthenticator(&auth.CertificateConfig{UserIDFrom: "serial"}, test.NewTestLogger(t))
+		_, patternErr := auth.NewCertificateAuthenticator(&auth.CertificateConfig{AllowedUsers: []string{"build-["}}, test.NewTestLogger(t))
+
+		// Then: Both are rejected
+		test.AssertError(t, fieldErr, "unknown user ID field")
+		test.AssertError(t, patternErr, "invalid pattern")
+	})
+}
+
+func TestMiddlewareClientCertificates(t *testing.T) {
+	ca := newTestCA(t, "Build Farm CA")
+	agent := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "build-1", OrganizationalUnit: []string{"ci"}}})
+
+	// newServer serves GET /whoami over TLS, verifying client certificates from ca
+	newServer := func(t *testing.T, required bool) (*httptest.Server, string) {
+		manager, _ := newTokenManager(t)
+		_, secret, err := manager.CreateUserToken(&models.User{ID: "user-1", Username: "alice"}, personalTokenRequest("ci"))
+		test.AssertNoError(t, err, "creating token")
+
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "p1", Resource: "*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"group:ci", "alice"}})
+		certificates := newCertificateAuthenticator(t, func(config *auth.CertificateConfig) {
+			config.Required = required
+		})
+		middleware := auth.NewMiddleware(nil, engine, test.NewTestLogger(t), true)
+		middleware.SetAPITokenManager(manager)
+		middleware.SetCertificateAuthenticator(certificates)
+
+		table, err := auth.NewRouteTable([]auth.RoutePermission{{Method: "GET", Path: "/whoami", Action: models.ActionRead, Resource: "whoami"}})
+		test.AssertNoError(t, err, "building route table")
+		router := mux.NewRouter()
+		router.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+			user, _ := auth.GetUserFromContext(r.Context())
+			w.Write([]byte(user.ID + " " + user.Metadata["clientCertificate"]))
+		}).Methods("GET")
+		router.Use(middleware.AuthenticateRequest, middleware.AuthorizeRoutes(table))
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+		server := httptest.NewUnstartedServer(router)
+		server.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server, secret
+	}
+
+	whoami := func(t *testing.T, server *httptest.Server, cert *tls.Certificate, bearer string) (int, string) {
+		client := server.Client()
+		transport := client.Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		client.Transport = transport
+
+		req, err := http.NewRequest("GET", server.URL+"/whoami", nil)
+		test.AssertNoError(t, err, "creating request")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := client.Do(req)
+		test.AssertNoError(t, err, "sending request")
+		defer resp.Body.Close()
+		body := make([]byte, 256)
+		n, _ := resp.Body.Read(body)
+		return resp.StatusCode, strings.TrimSpace(string(body[:n]))
+	}
+
+	t.Run("Certificates authenticate requests on their own", func(t *testing.T) {
+		// Given: A server accepting client certificates
+		server, _ := newServer(t, false)
+
+		// When: Calling it with only a certificate
+		code, body := whoami(t, server, &agent, "")
+
+		// Then: The certificate's user is authorized by its OU group
+		test.AssertEqual(t, http.StatusOK, code, "status")
+		test.AssertEqual(t, "build-1", body, "user")
+	})
+
+	t.Run("Tokens identify the user when combined with certificates", func(t *testing.T) {
+		// Given: A server accepting client certificates
+		server, secret := newServer(t, false)
+
+		// When: Calling it with a certificate and a token
+		code, body := whoami(t, server, &agent, secret)
+
+		// Then: The token's user is authenticated, with the certificate recorded
+		test.AssertEqual(t, http.StatusOK, code, "status")
+		test.AssertEqual(t, "user-1 build-1", body, "user and certificate")
+	})
+
+	t.Run("Required certificates reject token-only requests", func(t *testing.T) {
+		// Given: A server requiring client certificates
+		server, secret := newServer(t, true)
+
+		// When/Then: A token alone is rejected, and with a certificate accepted
+		code, _ := whoami(t, server, nil, secret)
+		test.AssertEqual(t, http.StatusUnauthorized, code, "token without certificate")
+		code, _ = whoami(t, server, &agent, secret)
+		test.AssertEqual(t, http.StatusOK, code, "token with certificate")
+	})
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RegexPatternPrefix marks a resource pattern as a regular expression
+// matched against the whole resource, e.g. re:^releases/v[0-9]+/.*$
+const RegexPatternPrefix = "re:"
+
+// allResources matches every resource
+var allResources = &resourceMatcher{all: true}
+
+// resourceMatcher is a compiled resource pattern
+type resourceMatcher struct {
+	all     bool           // Matches every resource
+	literal string         // Matches exactly this resource when re is nil
+	re      *regexp.Regexp // Matches glob and regex patterns
+	bucket  string         // Literal first path segment every match has, "" if unknown
+}
+
+// ValidateResourcePattern checks that a resource pattern compiles
+func ValidateResourcePattern(pattern string) error {
+	_, err := compileResourcePattern(pattern)
+	return err
+}
+
+// compileResourcePattern compiles a resource pattern. Globs support * and ?
+// within a path segment, ** across segments and [...] character classes.
+// After a literal prefix a trailing /* matches the prefix and everything
+// below it, so bucket/* keeps matching whole buckets. Patterns starting with
+// re: are regular expressions.
+func compileResourcePattern(pattern string) (*resourceMatcher, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("resource pattern is empty")
+	}
+	if pattern == "*" || pattern == "**" {
+		return &resourceMatcher{all: true}, nil
+	}
+
+	if strings.HasPrefix(pattern, RegexPatternPrefix) {
+		re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(pattern, RegexPatternPrefix) + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource regex %q: %w", pattern, err)
+		}
+		prefix, complete := re.LiteralPrefix()
+		if complete {
+			return &resourceMatcher{literal: prefix, bucket: bucketOf(prefix)}, nil
+		}
+		matcher := &resourceMatcher{re: re}
+		if i := strings.Index(prefix, "/"); i >= 0 {
+			matcher.bucket = prefix[:i]
+		}
+		return matcher, nil
+	}
+
+	// A trailing /* after a literal prefix matches the prefix itself and
+	// everything below it
+	if prefix := strings.TrimSuffix(pattern, "/*"); prefix != pattern && !strings.ContainsAny(prefix, `*?[\`) {
+		re := regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `(?:/.*)?$`)
+		return &resourceMatcher{re: re, bucket: bucketOf(prefix)}, nil
+	}
+
+	expr, literalPrefix, exact, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource pattern %q: %w", pattern, err)
+	}
+	if exact {
+		return &resourceMatcher{literal: literalPrefix, bucket: bucketOf(literalPrefix)}, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource pattern %q: %w", pattern, err)
+	}
+	matcher := &resourceMatcher{re: re}
+	if i := strings.Index(literalPrefix, "/"); i >= 0 {
+		matcher.bucket = literalPrefix[:i]
+	}
+	return matcher, nil
+}
+
+// matches reports whether a resource matches the pattern
+func (m *resourceMatcher) matches(resource string) bool {
+	switch {
+	case m.all:
+		return true
+	case m.re != nil:
+		return m.re.MatchString(resource)
+	default:
+		return m.literal == resource
+	}
+}
+
+// globToRegexp translates a glob into an anchored regular expression. It
+// also returns the literal text the glob starts with, and whether that is
+// the whole glob.
+func globToRegexp(glob string) (string, string, bool, error) {
+	var expr strings.Builder
+	var literal strings.Builder
+	inLiteral := true
+	expr.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			inLiteral = false
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// **/ matches zero or more whole segments
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			inLiteral = false
+			expr.WriteString("[^/]")
+		case '[':
+			inLiteral = false
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 || (end == 0 && i+2 >= len(glob)) {
+				return "", "", false, fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if end == 0 {
+				// A ] right after [ is part of the class
+				next := strings.IndexByte(glob[i+2:], ']')
+				if next < 0 {
+					return "", "", false, fmt.Errorf("unterminated character class")
+				}
+				class = glob[i+1 : i+2+next]
+			}
+			i += len(class) + 1
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+		case '\\':
+			if i+1 >= len(glob) {
+				return "", "", false, fmt.Errorf("trailing escape")
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+			if inLiteral {
+				literal.WriteByte(glob[i])
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+			if inLiteral {
+				literal.WriteByte(c)
+			}
+		}
+	}
+
+	expr.WriteString("$")
+	return expr.String(), literal.String(), inLiteral, nil
+}
+
+// bucketOf returns the first path segment of a resource
+func bucketOf(resource string) string {
+	bucket, _, _ := strings.Cut(resource, "/")
+	return bucket
+}
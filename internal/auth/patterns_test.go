@@ -0,0 +1,180 @@
+package auth_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestResourcePatterns(t *testing.T) {
+	user := &models.User{ID: "user-1", Username: "dev"}
+	cases := []struct {
+		pattern  string
+		resource string
+		matches  bool
+	}{
+		// Exact names and the legacy bucket/* prefix
+		{"releases", "releases", true},
+		{"releases", "releases/app.jar", false},
+		{"releases/*", "releases", true},
+		{"releases/*", "releases/v1/linux/app.tar.gz", true},
+		{"releases/*", "releases-old/app.jar", false},
+		{"*", "any/thing", true},
+
+		// Globs
+		{"releases/*/linux-*.tar.gz", "releases/v1/linux-amd64.tar.gz", true},
+		{"releases/*/linux-*.tar.gz", "releases/v1/extra/linux-amd64.tar.gz", false},
+		{"releases/*/linux-*.tar.gz", "releases/v1/darwin-amd64.tar.gz", false},
+		{"*/nightly/*", "app/nightly/build.zip", true},
+		{"*/nightly/*", "app/nightly/2025/build.zip", false},
+		{"*/nightly/**", "app/nightly/2025/build.zip", true},
+		{"releases/**/*.jar", "releases/app.jar", true},
+		{"releases/**/*.jar", "releases/a/b/app.jar", true},
+		{"releases/**/*.jar", "releases/a/b/app.war", false},
+		{"builds/v?", "builds/v1", true},
+		{"builds/v?", "builds/v10", false},
+		{"builds/v[0-9]", "builds/v7", true},
+		{"builds/v[!0-9]", "builds/v7", false},
+		{"builds/v[!0-9]", "builds/vx", true},
+		{`builds/\*`, "builds/*", true},
+		{`builds/\*`, "builds/x", false},
+
+		// Regular expressions
+		{"re:releases/v[0-9]+/.*", "releases/v12/app.jar", true},
+		{"re:releases/v[0-9]+/.*", "releases/beta/app.jar", false},
+		{"re:(dev|staging)/.*", "staging/app.jar", true},
+		{"re:(dev|staging)/.*", "prod/app.jar", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.pattern+" "+c.resource, func(t *testing.T) {
+			// Given: A policy allowing reads of the pattern
+			test.AssertNoError(t, auth.ValidateResourcePattern(c.pattern), "valid pattern")
+			engine := auth.NewPolicyEngine(false)
+			engine.AddPolicy(&models.Policy{ID: "p1", Resource: c.pattern, Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+
+			// When: Reading the resource
+			allowed, _ := engine.Authorize(user, c.resource, models.ActionRead, nil)
+
+			// Then: It is allowed only if the pattern matches
+			test.AssertEqual(t, c.matches, allowed, "pattern matches")
+		})
+	}
+
+	t.Run("Invalid patterns are rejected", func(t *testing.T) {
+		// Given/When/Then: Malformed globs and regexes fail validation
+		for _, pattern := range []string{"", "releases/[a-", `releases/\`, "re:releases/(", "re:a{2,1}"} {
+			test.AssertError(t, auth.ValidateResourcePattern(pattern), pattern)
+		}
+	})
+}
+
+func TestPolicyPriorities(t *testing.T) {
+	user := &models.User{ID: "user-1", Username: "dev", Roles: []string{"developer"}}
+
+	t.Run("Higher priorities decide first", func(t *testing.T) {
+		// Given: A broad deny overridden for one path by a higher priority allow
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "deny-prod", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny})
+		engine.AddPolicy(&models.Policy{ID: "allow-docs", Resource: "prod/docs/**", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: 10})
+
+		// When/Then: The allow decides inside its path and the deny elsewhere
+		allowed, _ := engine.Authorize(user, "prod/docs/index.html", models.ActionRead, nil)
+		test.AssertTrue(t, allowed, "higher priority allow")
+		allowed, _ = engine.Authorize(user, "prod/app.jar", models.ActionRead, nil)
+		test.AssertFalse(t, allowed, "deny elsewhere")
+	})
+
+	t.Run("Deny wins within a priority", func(t *testing.T) {
+		// Given: An allow and a deny at the same priority
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "allow", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: 5})
+		engine.AddPolicy(&models.Policy{ID: "deny", Resource: "prod/*.exe", Actions: []string{"read"}, Effect: models.PolicyEffectDeny, Priority: 5})
+
+		// When: Explaining a read of a matching resource
+		decision := engine.Explain(user, "prod/setup.exe", models.ActionRead, nil)
+
+		// Then: The deny decides
+		test.AssertFalse(t, decision.Allowed, "denied")
+		test.AssertEqual(t, "deny", decision.DecidingPolicy, "deciding policy")
+	})
+
+	t.Run("Explain traces matching policies", func(t *testing.T) {
+		// Given: Policies at several priorities, one conditional and one for other users
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "low-allow", Resource: "releases/**", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+		engine.AddPolicy(&models.Policy{ID: "office", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: 20, Conditions: map[string]string{"sourceIp": "10.0.0.0/8"}})
+		engine.AddPolicy(&models.Policy{ID: "mid-deny", Resource: "re:releases/.*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny, Priority: 10, Principals: []string{"role:developer"}})
+		engine.AddPolicy(&models.Policy{ID: "others", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: 30, Principals: []string{"role:ops"}})
+		engine.AddPolicy(&models.Policy{ID: "writes", Resource: "releases/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow, Priority: 30})
+
+		// When: Explaining a read
+		decision := engine.Explain(user, "releases/app.jar", models.ActionRead, nil)
+
+		// Then: Matching policies are listed by priority with how they were evaluated
+		test.AssertFalse(t, decision.Allowed, "denied")
+		test.AssertEqual(t, "mid-deny", decision.DecidingPolicy, "deciding policy")
+		test.AssertEqual(t, 3, len(decision.Trace), "matching policies")
+		test.AssertEqual(t, "office", decision.Trace[0].PolicyID, "first evaluated")
+		test.AssertFalse(t, decision.Trace[0].Applied, "conditional allow without context")
+		test.AssertEqual(t, "mid-deny", decision.Trace[1].PolicyID, "second evaluated")
+		test.AssertTrue(t, decision.Trace[1].Applied, "deny applied")
+		test.AssertEqual(t, "low-allow", decision.Trace[2].PolicyID, "third evaluated")
+		test.AssertEqual(t, "a higher priority decided", decision.Trace[2].Reason, "lower priority skipped")
+	})
+
+	t.Run("Denies with invalid patterns fail closed", func(t *testing.T) {
+		// Given: An allow and a deny whose pattern does not compile, as a
+		// literal stored before patterns existed might not
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "allow", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+		engine.AddPolicy(&models.Policy{ID: "deny", Resource: "prod/[legacy", Actions: []string{"read"}, Effect: models.PolicyEffectDeny})
+
+		// When: Explaining a read the allow matches
+		decision := engine.Explain(user, "prod/app.jar", models.ActionRead, nil)
+
+		// Then: The deny still applies
+		test.AssertFalse(t, decision.Allowed, "denied")
+		test.AssertEqual(t, "deny", decision.DecidingPolicy, "deciding policy")
+	})
+
+	t.Run("Removed policies leave the index", func(t *testing.T) {
+		// Given: Two policies on the same bucket
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{ID: "deny", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny})
+		engine.AddPolicy(&models.Policy{ID: "allow", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+
+		// When: The deny is removed
+		engine.RemovePolicy("deny")
+
+		// Then: The allow decides
+		allowed, _ := engine.Authorize(user, "prod/app.jar", models.ActionRead, nil)
+		test.AssertTrue(t, allowed, "allowed after removal")
+		test.AssertEqual(t, 1, len(engine.ListPolicies()), "remaining policies")
+	})
+}
+
+// BenchmarkAuthorizeManyPolicies authorizes against thousands of policies
+// spread over many buckets
+func BenchmarkAuthorizeManyPolicies(b *testing.B) {
+	engine := auth.NewPolicyEngine(false)
+	for i := 0; i < 5000; i++ {
+		engine.AddPolicy(&models.Policy{
+			ID:         fmt.Sprintf("p%d", i),
+			Resource:   fmt.Sprintf("bucket-%d/**/*.jar", i%1000),
+			Actions:    []string{"read"},
+			Effect:     models.PolicyEffectAllow,
+			Principals: []string{fmt.Sprintf("group:team-%d", i%50)},
+		})
+	}
+	engine.AddPolicy(&models.Policy{ID: "global", Resource: "*/nightly/**", Actions: []string{"read"}, Effect: models.PolicyEffectDeny})
+	user := &models.User{ID: "user-1", Username: "dev", Groups: []string{"team-7"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.Authorize(user, "bucket-507/releases/app.jar", models.ActionRead, nil)
+	}
+}
@@ -1,102 +1,350 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/candlekeep/zot-artifact-store/internal/models"
 )
 
-// PolicyEngine evaluates access control policies
+// ErrPolicyVersionConflict is returned when policies changed since the
+// version an update was based on
+var ErrPolicyVersionConflict = errors.New("policies changed since the expected version")
+
+// PolicyEngine evaluates access control policies. Policies are held in
+// immutable, versioned sets: updates build a new set and swap it in
+// atomically, so evaluations never lock and always see one consistent set.
 type PolicyEngine struct {
-	policies          []*models.Policy
+	writeMu           sync.Mutex // Serializes updates
+	current           atomic.Pointer[policySet]
 	allowAnonymousGet bool
 }
 
+// policySet is an immutable, indexed set of policies
+type policySet struct {
+	version   uint64
+	policies  []*models.Policy
+	compiled  []*compiledPolicy
+	byBucket  map[string][]*compiledPolicy // Policies whose matches all lie in one bucket
+	anyBucket []*compiledPolicy            // Policies that may match any bucket
+	matchers  map[string]*resourceMatcher  // Compiled patterns, reused by the next set
+}
+
+// compiledPolicy is a policy with its compiled resource pattern and
+// conditions
+type compiledPolicy struct {
+	policy     *models.Policy
+	resource   *resourceMatcher // Nil if an allow's pattern is invalid; the policy matches nothing
+	conditions *compiledConditions
+	order      int
+}
+
+// Decision explains an authorization decision
+type Decision struct {
+	Allowed        bool          `json:"allowed"`
+	Reason         string        `json:"reason"`
+	DecidingPolicy string        `json:"decidingPolicy,omitempty"`
+	PolicyVersion  uint64        `json:"policyVersion"`
+	Trace          []PolicyMatch `json:"trace"` // Policies matching the resource, action and principal, in evaluation order
+}
+
+// PolicyMatch records how a matching policy was evaluated
+type PolicyMatch struct {
+	PolicyID string              `json:"policyId"`
+	Name     string              `json:"name,omitempty"`
+	Resource string              `json:"resource"`
+	Effect   models.PolicyEffect `json:"effect"`
+	Priority int                 `json:"priority"`
+	Applied  bool                `json:"applied"`
+	Reason   string              `json:"reason,omitempty"` // Why an unapplied policy did not apply
+}
+
 // NewPolicyEngine creates a new policy engine
 func NewPolicyEngine(allowAnonymousGet bool) *PolicyEngine {
-	return &PolicyEngine{
-		policies:          make([]*models.Policy, 0),
-		allowAnonymousGet: allowAnonymousGet,
+	e := &PolicyEngine{allowAnonymousGet: allowAnonymousGet}
+	e.current.Store(newPolicySet(0, nil, nil))
+	return e
+}
+
+// newPolicySet compiles and indexes policies, reusing the patterns
+// compiled for the previous set
+func newPolicySet(version uint64, policies []*models.Policy, previous *policySet) *policySet {
+	set := &policySet{
+		version:  version,
+		policies: policies,
+		compiled: make([]*compiledPolicy, len(policies)),
+		byBucket: make(map[string][]*compiledPolicy),
+		matchers: make(map[string]*resourceMatcher, len(policies)),
+	}
+	for i, policy := range policies {
+		resource, ok := set.matchers[policy.Resource]
+		if !ok && previous != nil {
+			resource, ok = previous.matchers[policy.Resource]
+		}
+		if !ok {
+			resource, _ = compileResourcePattern(policy.Resource)
+		}
+		set.matchers[policy.Resource] = resource
+
+		// A deny that cannot be evaluated fails closed, as it does for
+		// conditions
+		if resource == nil && policy.Effect == models.PolicyEffectDeny {
+			resource = allResources
+		}
+
+		compiled := &compiledPolicy{
+			policy:     policy,
+			resource:   resource,
+			conditions: compileConditions(policy.Conditions),
+			order:      i,
+		}
+		set.compiled[i] = compiled
+		switch {
+		case resource == nil:
+		case resource.bucket != "":
+			set.byBucket[resource.bucket] = append(set.byBucket[resource.bucket], compiled)
+		default:
+			set.anyBucket = append(set.anyBucket, compiled)
+		}
 	}
+	return set
 }
 
-// AddPolicy adds a policy to the engine
-func (e *PolicyEngine) AddPolicy(policy *models.Policy) {
-	e.policies = append(e.policies, policy)
+// update builds a new policy set from the current policies and swaps it in
+func (e *PolicyEngine) update(change func(policies []*models.Policy) []*models.Policy) uint64 {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	current := e.current.Load()
+	policies := change(append([]*models.Policy(nil), current.policies...))
+	next := newPolicySet(current.version+1, policies, current)
+	e.current.Store(next)
+	return next.version
+}
+
+// AddPolicy adds a policy to the engine. An allow whose resource pattern
+// does not compile matches nothing and such a deny matches every resource;
+// use ValidatePolicy first.
+func (e *PolicyEngine) AddPolicy(policy *models.Policy) uint64 {
+	return e.update(func(policies []*models.Policy) []*models.Policy {
+		return append(policies, policy)
+	})
+}
+
+// PutPolicy atomically replaces the policy with the same ID, or adds it
+func (e *PolicyEngine) PutPolicy(policy *models.Policy) uint64 {
+	return e.update(func(policies []*models.Policy) []*models.Policy {
+		for i, p := range policies {
+			if p.ID == policy.ID {
+				policies[i] = policy
+				return policies
+			}
+		}
+		return append(policies, policy)
+	})
 }
 
 // RemovePolicy removes a policy by ID
-func (e *PolicyEngine) RemovePolicy(policyID string) {
-	for i, p := range e.policies {
-		if p.ID == policyID {
-			e.policies = append(e.policies[:i], e.policies[i+1:]...)
-			return
+func (e *PolicyEngine) RemovePolicy(policyID string) uint64 {
+	return e.update(func(policies []*models.Policy) []*models.Policy {
+		for i, p := range policies {
+			if p.ID == policyID {
+				return append(policies[:i], policies[i+1:]...)
+			}
 		}
+		return policies
+	})
+}
+
+// ReplacePolicies atomically replaces all policies
+func (e *PolicyEngine) ReplacePolicies(policies []*models.Policy) uint64 {
+	return e.update(func([]*models.Policy) []*models.Policy {
+		return append([]*models.Policy(nil), policies...)
+	})
+}
+
+// ReplacePoliciesIfVersion atomically replaces all policies if the policy
+// version is still the expected one
+func (e *PolicyEngine) ReplacePoliciesIfVersion(expected uint64, policies []*models.Policy) (uint64, error) {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	current := e.current.Load()
+	if current.version != expected {
+		return current.version, ErrPolicyVersionConflict
 	}
+	next := newPolicySet(current.version+1, append([]*models.Policy(nil), policies...), current)
+	e.current.Store(next)
+	return next.version, nil
+}
+
+// Version returns the version of the current policy set. It increases with
+// every update.
+func (e *PolicyEngine) Version() uint64 {
+	return e.current.Load().version
 }
 
 // ListPolicies returns all policies
 func (e *PolicyEngine) ListPolicies() []*models.Policy {
-	return e.policies
+	return append([]*models.Policy(nil), e.current.Load().policies...)
+}
+
+// ValidatePolicy checks that a policy can be evaluated
+func ValidatePolicy(policy *models.Policy) error {
+	if policy.ID == "" {
+		return fmt.Errorf("policy ID is required")
+	}
+	if len(policy.Actions) == 0 {
+		return fmt.Errorf("policy %s needs at least one action", policy.ID)
+	}
+	switch policy.Effect {
+	case models.PolicyEffectAllow, models.PolicyEffectDeny:
+	default:
+		return fmt.Errorf("policy %s has unknown effect %q", policy.ID, policy.Effect)
+	}
+	if err := ValidateResourcePattern(policy.Resource); err != nil {
+		return fmt.Errorf("policy %s: %w", policy.ID, err)
+	}
+	if err := ValidateConditions(policy.Conditions); err != nil {
+		return fmt.Errorf("policy %s: %w", policy.ID, err)
+	}
+	return nil
+}
+
+// Authorize checks if a user is authorized to perform an action on a
+// resource. Policy conditions are evaluated against reqCtx; a nil reqCtx
+// evaluates no condition, so conditional allows never apply and
+// conditional denies always do.
+func (e *PolicyEngine) Authorize(user *models.User, resource string, action models.Action, reqCtx *RequestContext) (bool, error) {
+	decision := e.Explain(user, resource, action, reqCtx)
+	if !decision.Allowed {
+		return false, errors.New(decision.Reason)
+	}
+	return true, nil
 }
 
-// Authorize checks if a user is authorized to perform an action on a resource
-func (e *PolicyEngine) Authorize(user *models.User, resource string, action models.Action) (bool, error) {
+// Explain evaluates an authorization request like Authorize and reports
+// which policies matched and which one decided.
+//
+// Policies are evaluated from the highest priority down. The highest
+// priority with an applying policy decides: a deny at that priority wins
+// over an allow, and lower priorities are not considered.
+func (e *PolicyEngine) Explain(user *models.User, resource string, action models.Action, reqCtx *RequestContext) *Decision {
+	set := e.current.Load()
+	decision := &Decision{PolicyVersion: set.version, Trace: []PolicyMatch{}}
+
 	// Handle anonymous access for GET operations
 	if user == nil {
 		if e.allowAnonymousGet && action == models.ActionRead {
-			return true, nil
+			decision.Allowed = true
+			decision.Reason = "anonymous read access is allowed"
+			return decision
 		}
-		return false, fmt.Errorf("authentication required")
+		decision.Reason = "authentication required"
+		return decision
+	}
+
+	// A scoped API token limits its user, whatever their roles
+	if len(user.Scopes) > 0 && !e.inScope(user.Scopes, resource, action) {
+		decision.Reason = "outside the scope of the API token"
+		return decision
 	}
 
 	// Admin role has full access
 	if e.hasRole(user, "admin") {
-		return true, nil
+		decision.Allowed = true
+		decision.Reason = "admin role has full access"
+		return decision
 	}
 
-	// Check policies
-	allowCount := 0
-	denyCount := 0
-
-	for _, policy := range e.policies {
-		// Check if policy applies to this resource
-		if !e.matchesResource(policy.Resource, resource) {
-			continue
+	// Collect the policies matching the resource, action and principal
+	candidates := make([]*compiledPolicy, 0, len(set.anyBucket))
+	candidates = append(candidates, set.byBucket[bucketOf(resource)]...)
+	candidates = append(candidates, set.anyBucket...)
+
+	matched := candidates[:0]
+	for _, compiled := range candidates {
+		policy := compiled.policy
+		if compiled.resource.matches(resource) &&
+			e.containsAction(policy.Actions, string(action)) &&
+			e.matchesPrincipal(policy.Principals, user) {
+			matched = append(matched, compiled)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].policy.Priority != matched[j].policy.Priority {
+			return matched[i].policy.Priority > matched[j].policy.Priority
+		}
+		return matched[i].order < matched[j].order
+	})
+
+	// Evaluate conditions; the first priority with an applying policy decides
+	var decidedAt *int
+	for _, compiled := range matched {
+		policy := compiled.policy
+		match := PolicyMatch{
+			PolicyID: policy.ID,
+			Name:     policy.Name,
+			Resource: policy.Resource,
+			Effect:   policy.Effect,
+			Priority: policy.Priority,
 		}
 
-		// Check if policy applies to this action
-		if !e.containsAction(policy.Actions, string(action)) {
+		if decidedAt != nil && policy.Priority < *decidedAt {
+			match.Reason = "a higher priority decided"
+			decision.Trace = append(decision.Trace, match)
 			continue
 		}
 
-		// Check if policy applies to this principal
-		if !e.matchesPrincipal(policy.Principals, user) {
+		// Conditions that cannot be evaluated fail closed
+		ok, err := compiled.conditions.evaluate(reqCtx, resource)
+		switch {
+		case policy.Effect == models.PolicyEffectDeny && err != nil:
+			match.Applied = true
+			match.Reason = err.Error()
+		case err != nil:
+			match.Reason = err.Error()
+		case !ok:
+			match.Reason = "conditions not met"
+		default:
+			match.Applied = true
+		}
+		decision.Trace = append(decision.Trace, match)
+		if !match.Applied {
 			continue
 		}
 
-		// Apply policy effect
+		// Deny takes precedence within a priority
+		undecided := decision.DecidingPolicy == ""
 		switch policy.Effect {
-		case models.PolicyEffectAllow:
-			allowCount++
 		case models.PolicyEffectDeny:
-			denyCount++
+			if undecided || decision.Allowed {
+				decision.Allowed = false
+				decision.Reason = "access denied by policy"
+				decision.DecidingPolicy = policy.ID
+			}
+		case models.PolicyEffectAllow:
+			if undecided {
+				decision.Allowed = true
+				decision.Reason = "allowed by policy"
+				decision.DecidingPolicy = policy.ID
+			}
+		default:
+			continue
 		}
-	}
-
-	// Deny takes precedence
-	if denyCount > 0 {
-		return false, fmt.Errorf("access denied by policy")
-	}
-
-	// If any policy allows, grant access
-	if allowCount > 0 {
-		return true, nil
+		priority := policy.Priority
+		decidedAt = &priority
 	}
 
 	// Default deny
-	return false, fmt.Errorf("no policy allows this action")
+	if decision.DecidingPolicy == "" {
+		decision.Reason = "no policy allows this action"
+	}
+	return decision
 }
 
 // GetPermissions returns all permissions for a user
@@ -119,6 +367,7 @@ func (e *PolicyEngine) GetPermissions(user *models.User) []models.Permission {
 				models.ActionDelete,
 				models.ActionList,
 				models.ActionAdmin,
+				models.ActionSign,
 			}},
 		}
 	}
@@ -126,7 +375,7 @@ func (e *PolicyEngine) GetPermissions(user *models.User) []models.Permission {
 	// Collect permissions from policies
 	permMap := make(map[string]map[models.Action]bool)
 
-	for _, policy := range e.policies {
+	for _, policy := range e.current.Load().policies {
 		if policy.Effect != models.PolicyEffectAllow {
 			continue
 		}
@@ -161,26 +410,24 @@ func (e *PolicyEngine) GetPermissions(user *models.User) []models.Permission {
 	return permissions
 }
 
-// matchesResource checks if a policy resource pattern matches the actual resource
+// matchesResource checks if a resource pattern matches the actual resource
 func (e *PolicyEngine) matchesResource(pattern, resource string) bool {
-	// Exact match
-	if pattern == resource {
-		return true
-	}
-
-	// Wildcard match
-	if pattern == "*" {
-		return true
-	}
+	matcher, err := compileResourcePattern(pattern)
+	return err == nil && matcher.matches(resource)
+}
 
-	// Prefix match for bucket patterns (e.g., "mybucket/*")
-	if strings.HasSuffix(pattern, "/*") {
-		prefix := strings.TrimSuffix(pattern, "/*")
-		if strings.HasPrefix(resource, prefix+"/") || resource == prefix {
-			return true
+// inScope checks if any scope grants an action on a resource
+func (e *PolicyEngine) inScope(scopes []models.Permission, resource string, action models.Action) bool {
+	for _, scope := range scopes {
+		if !e.matchesResource(scope.Resource, resource) {
+			continue
+		}
+		for _, a := range scope.Actions {
+			if a == action || a == "*" {
+				return true
+			}
 		}
 	}
-
 	return false
 }
 
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyPlan lists the changes that make a set of policies match a policy
+// document
+type PolicyPlan struct {
+	Created   []string       `json:"created"`
+	Updated   []PolicyUpdate `json:"updated"`
+	Deleted   []string       `json:"deleted"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// PolicyUpdate names a policy that changes and the fields that differ
+type PolicyUpdate struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// HasChanges reports whether applying the plan changes any policy
+func (p *PolicyPlan) HasChanges() bool {
+	return len(p.Created) > 0 || len(p.Updated) > 0 || len(p.Deleted) > 0
+}
+
+// PlanPolicies compares the current policies with the desired ones, which
+// replace them completely. Policies are matched by ID; timestamps are
+// ignored.
+func PlanPolicies(current, desired []*models.Policy) *PolicyPlan {
+	plan := &PolicyPlan{Created: []string{}, Updated: []PolicyUpdate{}, Deleted: []string{}}
+
+	existing := make(map[string]*models.Policy, len(current))
+	for _, policy := range current {
+		existing[policy.ID] = policy
+	}
+	wanted := make(map[string]bool, len(desired))
+	for _, policy := range desired {
+		wanted[policy.ID] = true
+		previous, ok := existing[policy.ID]
+		if !ok {
+			plan.Created = append(plan.Created, policy.ID)
+			continue
+		}
+		if fields := changedPolicyFields(previous, policy); len(fields) > 0 {
+			plan.Updated = append(plan.Updated, PolicyUpdate{ID: policy.ID, Fields: fields})
+		} else {
+			plan.Unchanged++
+		}
+	}
+	for _, policy := range current {
+		if !wanted[policy.ID] {
+			plan.Deleted = append(plan.Deleted, policy.ID)
+		}
+	}
+
+	sort.Strings(plan.Created)
+	sort.Strings(plan.Deleted)
+	sort.Slice(plan.Updated, func(i, j int) bool { return plan.Updated[i].ID < plan.Updated[j].ID })
+	return plan
+}
+
+// changedPolicyFields lists the fields, by their API names, that differ
+// between two versions of a policy. Empty and missing lists are equal.
+func changedPolicyFields(a, b *models.Policy) []string {
+	var fields []string
+	if a.Name != b.Name {
+		fields = append(fields, "name")
+	}
+	if a.Description != b.Description {
+		fields = append(fields, "description")
+	}
+	if a.Resource != b.Resource {
+		fields = append(fields, "resource")
+	}
+	if !equalStrings(a.Actions, b.Actions) {
+		fields = append(fields, "actions")
+	}
+	if a.Effect != b.Effect {
+		fields = append(fields, "effect")
+	}
+	if !equalStrings(a.Principals, b.Principals) {
+		fields = append(fields, "principals")
+	}
+	if !equalConditions(a.Conditions, b.Conditions) {
+		fields = append(fields, "conditions")
+	}
+	if a.Priority != b.Priority {
+		fields = append(fields, "priority")
+	}
+	return fields
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalConditions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// exportedPolicy is a policy as written to a policy document, without
+// timestamps
+type exportedPolicy struct {
+	ID          string              `json:"id" yaml:"id"`
+	Name        string              `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string              `json:"description,omitempty" yaml:"description,omitempty"`
+	Resource    string              `json:"resource" yaml:"resource"`
+	Actions     []string            `json:"actions" yaml:"actions,flow"`
+	Effect      models.PolicyEffect `json:"effect" yaml:"effect"`
+	Principals  []string            `json:"principals,omitempty" yaml:"principals,omitempty,flow"`
+	Conditions  map[string]string   `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Priority    int                 `json:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+// MarshalPolicies writes policies as a policy document that ParsePolicies
+// reads back. Policies are sorted by ID so exports of the same policies
+// are identical.
+func MarshalPolicies(policies []*models.Policy, format string) ([]byte, error) {
+	document := struct {
+		Policies []exportedPolicy `json:"policies" yaml:"policies"`
+	}{Policies: make([]exportedPolicy, 0, len(policies))}
+	for _, policy := range policies {
+		document.Policies = append(document.Policies, exportedPolicy{
+			ID:          policy.ID,
+			Name:        policy.Name,
+			Description: policy.Description,
+			Resource:    policy.Resource,
+			Actions:     policy.Actions,
+			Effect:      policy.Effect,
+			Principals:  policy.Principals,
+			Conditions:  policy.Conditions,
+			Priority:    policy.Priority,
+		})
+	}
+	sort.Slice(document.Policies, func(i, j int) bool { return document.Policies[i].ID < document.Policies[j].ID })
+
+	switch format {
+	case PolicyFormatYAML:
+		data, err := yaml.Marshal(&document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode policies: %w", err)
+		}
+		return data, nil
+	case PolicyFormatJSON:
+		data, err := json.MarshalIndent(&document, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode policies: %w", err)
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unknown policy format %q", format)
+	}
+}
+
+// PolicySimulation reports how replaying audited requests against proposed
+// policies changes their authorization decisions
+type PolicySimulation struct {
+	CurrentVersion uint64           `json:"currentVersion"`
+	Replayed       int              `json:"replayed"`
+	Skipped        int              `json:"skipped"` // Entries that record no authorization decision
+	Granted        int              `json:"granted"` // Denied now, allowed by the proposed policies
+	Revoked        int              `json:"revoked"` // Allowed now, denied by the proposed policies
+	Changes        []DecisionChange `json:"changes"`
+}
+
+// DecisionChange is an audited request whose decision the proposed policies
+// change
+type DecisionChange struct {
+	AuditID          string    `json:"auditId"`
+	Timestamp        time.Time `json:"timestamp"`
+	UserID           string    `json:"userId"`
+	Username         string    `json:"username"`
+	Action           string    `json:"action"`
+	Resource         string    `json:"resource"`
+	Current          string    `json:"current"`  // allow or deny
+	Proposed         string    `json:"proposed"` // allow or deny
+	CurrentPolicy    string    `json:"currentPolicy,omitempty"`
+	ProposedPolicy   string    `json:"proposedPolicy,omitempty"`
+	ProposedReason   string    `json:"proposedReason"`
+	RecordedDecision string    `json:"recordedDecision"` // The decision when the request was made
+}
+
+// SimulatePolicies replays audited authorization decisions against the
+// current policies of engine and against proposed policies, and reports the
+// requests whose decision would change.
+//
+// Requests are evaluated as the user's roles, groups and token scopes,
+// source IP and time were recorded. Headers are not recorded, so header
+// conditions never match, and object conditions are evaluated against
+// objects as resolver finds them now.
+func SimulatePolicies(engine *PolicyEngine, proposed []*models.Policy, logs []*models.AuditLog, resolver ObjectResolver) *PolicySimulation {
+	candidate := NewPolicyEngine(engine.allowAnonymousGet)
+	candidate.ReplacePolicies(proposed)
+
+	simulation := &PolicySimulation{CurrentVersion: engine.Version(), Changes: []DecisionChange{}}
+	for _, entry := range logs {
+		recorded := entry.Metadata[auditDecision]
+		if recorded == "" {
+			simulation.Skipped++
+			continue
+		}
+		simulation.Replayed++
+
+		user := replayUser(entry)
+		action := models.Action(entry.Action)
+		current := engine.Explain(user, entry.Resource, action, replayContext(entry, resolver))
+		next := candidate.Explain(user, entry.Resource, action, replayContext(entry, resolver))
+		if current.Allowed == next.Allowed {
+			continue
+		}
+
+		if next.Allowed {
+			simulation.Granted++
+		} else {
+			simulation.Revoked++
+		}
+		simulation.Changes = append(simulation.Changes, DecisionChange{
+			AuditID:          entry.ID,
+			Timestamp:        entry.Timestamp,
+			UserID:           entry.UserID,
+			Username:         entry.Username,
+			Action:           entry.Action,
+			Resource:         entry.Resource,
+			Current:          decisionEffect(current),
+			Proposed:         decisionEffect(next),
+			CurrentPolicy:    current.DecidingPolicy,
+			ProposedPolicy:   next.DecidingPolicy,
+			ProposedReason:   next.Reason,
+			RecordedDecision: recorded,
+		})
+	}
+	return simulation
+}
+
+// replayUser rebuilds the user of an audited decision, nil if anonymous
+func replayUser(entry *models.AuditLog) *models.User {
+	if entry.Metadata[auditAnonymous] == "true" {
+		return nil
+	}
+	user := &models.User{
+		ID:       entry.UserID,
+		Username: entry.Username,
+		Roles:    splitList(entry.Metadata[auditRoles]),
+		Groups:   splitList(entry.Metadata[auditGroups]),
+	}
+	if scopes := entry.Metadata[auditScopes]; scopes != "" {
+		if err := json.Unmarshal([]byte(scopes), &user.Scopes); err != nil {
+			// Unreadable scopes grant nothing rather than everything
+			user.Scopes = []models.Permission{{}}
+		}
+	}
+	return user
+}
+
+// replayContext rebuilds the request context of an audited decision
+func replayContext(entry *models.AuditLog, resolver ObjectResolver) *RequestContext {
+	rc := &RequestContext{Time: entry.Timestamp, Resolver: resolver}
+	if ip := entry.Metadata[auditSourceIP]; ip != "" {
+		rc.SourceIP = net.ParseIP(ip)
+	}
+	return rc
+}
+
+// decisionEffect names the outcome of a decision
+func decisionEffect(decision *Decision) string {
+	if decision.Allowed {
+		return "allow"
+	}
+	return "deny"
+}
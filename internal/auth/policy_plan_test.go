@@ -0,0 +1,112 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func TestPlanPolicies(t *testing.T) {
+	// Given: Stored policies and a document that edits, adds and drops some
+	current := []*models.Policy{
+		{ID: "keep", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow},
+		{ID: "edit", Resource: "dev/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{}},
+		{ID: "drop", Resource: "tmp/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow},
+	}
+	desired := []*models.Policy{
+		{ID: "keep", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow},
+		{ID: "edit", Resource: "dev/**", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: 5},
+		{ID: "add", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny},
+	}
+
+	// When: Planning the import
+	plan := auth.PlanPolicies(current, desired)
+
+	// Then: Each change is reported once, with the fields that differ
+	test.AssertTrue(t, plan.HasChanges(), "plan has changes")
+	test.AssertEqual(t, "add", strings.Join(plan.Created, ","), "created")
+	test.AssertEqual(t, "drop", strings.Join(plan.Deleted, ","), "deleted")
+	test.AssertEqual(t, 1, len(plan.Updated), "updated")
+	test.AssertEqual(t, "resource,priority", strings.Join(plan.Updated[0].Fields, ","), "changed fields")
+	test.AssertEqual(t, 1, plan.Unchanged, "unchanged")
+	test.AssertFalse(t, auth.PlanPolicies(desired, desired).HasChanges(), "no changes against itself")
+}
+
+func TestMarshalPolicies(t *testing.T) {
+	// Given: Policies parsed from a document
+	policies, err := auth.ParsePolicies([]byte(policyYAML), auth.PolicyFormatYAML)
+	test.AssertNoError(t, err, "parsing policies")
+
+	for _, format := range []string{auth.PolicyFormatYAML, auth.PolicyFormatJSON} {
+		// When: Exporting and importing them again
+		data, err := auth.MarshalPolicies(policies, format)
+		test.AssertNoError(t, err, "exporting "+format)
+		parsed, err := auth.ParsePolicies(data, format)
+		test.AssertNoError(t, err, "parsing "+format+" export")
+
+		// Then: Nothing changes, and timestamps are not exported
+		test.AssertFalse(t, auth.PlanPolicies(policies, parsed).HasChanges(), format+" round trip")
+		test.AssertFalse(t, strings.Contains(string(data), "createdAt"), format+" without timestamps")
+	}
+}
+
+func TestSimulatePolicies(t *testing.T) {
+	// Given: Reads and writes recorded in the audit log under the current policies
+	_, store := newTokenManager(t)
+	engine := auth.NewPolicyEngine(false)
+	engine.AddPolicy(&models.Policy{ID: "read", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:developer"}})
+	audit := auth.NewAuditLogger(store, test.NewTestLogger(t), true)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	router.HandleFunc("/s3/{bucket}/{key:.*}", ok).Methods("GET", "PUT")
+	table, err := auth.NewRouteTable(routeTestPermissions)
+	test.AssertNoError(t, err, "building route table")
+	middleware := auth.NewMiddleware(nil, engine, test.NewTestLogger(t), true)
+	middleware.SetAuditLogger(audit)
+	router.Use(withUser(&models.User{ID: "user-1", Username: "dev", Roles: []string{"developer"}, Groups: []string{"web"}}))
+	router.Use(middleware.AuthorizeRoutes(table))
+
+	test.AssertEqual(t, http.StatusOK, serve(router, "GET", "/s3/releases/app.jar"), "allowed read")
+	test.AssertEqual(t, http.StatusForbidden, serve(router, "PUT", "/s3/releases/app.jar"), "denied write")
+	test.AssertEqual(t, http.StatusOK, serve(router, "GET", "/s3/releases/web.jar"), "allowed read")
+	audit.LogAccess(httptest.NewRequest("GET", "/metrics", nil), http.StatusOK, nil, nil)
+
+	page, err := store.QueryAuditLogs(&storage.AuditLogQuery{})
+	test.AssertNoError(t, err, "querying audit log")
+	test.AssertEqual(t, 4, len(page.Logs), "audit entries")
+
+	// When: Replaying them against policies that grant the web group writes
+	// and stop developers reading web.jar
+	proposed := []*models.Policy{
+		{ID: "read", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:developer"}},
+		{ID: "web-writes", Resource: "releases/*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow, Principals: []string{"group:web"}},
+		{ID: "no-web", Resource: "releases/web.jar", Actions: []string{"read"}, Effect: models.PolicyEffectDeny},
+	}
+	simulation := auth.SimulatePolicies(engine, proposed, page.Logs, auth.NewMetadataObjectResolver(store))
+
+	// Then: Only the changed decisions are reported
+	test.AssertEqual(t, 3, simulation.Replayed, "replayed decisions")
+	test.AssertEqual(t, 1, simulation.Skipped, "entries without a decision")
+	test.AssertEqual(t, 1, simulation.Granted, "granted")
+	test.AssertEqual(t, 1, simulation.Revoked, "revoked")
+	for _, change := range simulation.Changes {
+		switch change.Resource {
+		case "releases/app.jar":
+			test.AssertEqual(t, "write", change.Action, "granted action")
+			test.AssertEqual(t, "web-writes", change.ProposedPolicy, "granting policy")
+			test.AssertEqual(t, "deny", change.RecordedDecision, "recorded decision")
+		case "releases/web.jar":
+			test.AssertEqual(t, "deny", change.Proposed, "revoked read")
+			test.AssertEqual(t, "no-web", change.ProposedPolicy, "revoking policy")
+		default:
+			t.Fatalf("unexpected change for %s", change.Resource)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"gopkg.in/yaml.v2"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Policy document formats
+const (
+	PolicyFormatJSON = "json"
+	PolicyFormatYAML = "yaml"
+)
+
+// PolicyDocument is a set of policies in a policy file
+type PolicyDocument struct {
+	Policies []*models.Policy `json:"policies"`
+}
+
+// PolicySource provides the complete set of policies to load
+type PolicySource interface {
+	LoadPolicies() ([]*models.Policy, error)
+	String() string
+}
+
+// storePolicySource loads policies from the metadata store
+type storePolicySource struct {
+	store storage.MetadataStore
+}
+
+// NewStorePolicySource creates a policy source reading the metadata store
+func NewStorePolicySource(store storage.MetadataStore) PolicySource {
+	return &storePolicySource{store: store}
+}
+
+// LoadPolicies lists and validates the stored policies. Policies stored
+// before resource patterns were introduced may no longer compile, so the
+// set is rejected as a whole rather than evaluated without them.
+func (s *storePolicySource) LoadPolicies() ([]*models.Policy, error) {
+	policies, err := s.store.ListPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	if err := ValidatePolicies(policies); err != nil {
+		return nil, fmt.Errorf("invalid stored policy: %w", err)
+	}
+	return policies, nil
+}
+
+func (s *storePolicySource) String() string {
+	return "metadata store"
+}
+
+// filePolicySource loads policies from a YAML or JSON policy file
+type filePolicySource struct {
+	path string
+}
+
+// NewFilePolicySource creates a policy source reading a policy file. Files
+// ending in .yaml or .yml are YAML; others are JSON.
+func NewFilePolicySource(path string) PolicySource {
+	return &filePolicySource{path: path}
+}
+
+// LoadPolicies reads and validates the policy file. A file with any invalid
+// policy is rejected as a whole.
+func (s *filePolicySource) LoadPolicies() ([]*models.Policy, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	policies, err := ParsePolicies(data, PolicyFormatForPath(s.path))
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePolicies(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (s *filePolicySource) String() string {
+	return s.path
+}
+
+// PolicyFormatForPath returns the policy format of a file name
+func PolicyFormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return PolicyFormatYAML
+	default:
+		return PolicyFormatJSON
+	}
+}
+
+// ParsePolicies parses a policy document. Field names are those of the
+// policy API in both formats, and unknown fields are rejected.
+func ParsePolicies(data []byte, format string) ([]*models.Policy, error) {
+	if format == PolicyFormatYAML {
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid YAML policy document: %w", err)
+		}
+		converted, err := yamlToJSON(document)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML policy document: %w", err)
+		}
+		if data, err = json.Marshal(converted); err != nil {
+			return nil, fmt.Errorf("invalid YAML policy document: %w", err)
+		}
+	}
+
+	var document PolicyDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid policy document: %w", err)
+	}
+	for _, policy := range document.Policies {
+		if policy == nil {
+			return nil, fmt.Errorf("invalid policy document: empty policy")
+		}
+	}
+	return document.Policies, nil
+}
+
+// ValidatePolicies validates policies and checks their IDs are unique
+func ValidatePolicies(policies []*models.Policy) error {
+	ids := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		if err := ValidatePolicy(policy); err != nil {
+			return err
+		}
+		if ids[policy.ID] {
+			return fmt.Errorf("policy %s is defined more than once", policy.ID)
+		}
+		ids[policy.ID] = true
+	}
+	return nil
+}
+
+// yamlToJSON converts decoded YAML, whose maps have interface{} keys, into
+// values encoding/json can marshal
+func yamlToJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v", key)
+			}
+			convertedItem, err := yamlToJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[name] = convertedItem
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			convertedItem, err := yamlToJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = convertedItem
+		}
+		return converted, nil
+	default:
+		return v, nil
+	}
+}
+
+// PolicyReloader loads the policies of a source into a policy engine, once
+// or periodically
+type PolicyReloader struct {
+	engine   *PolicyEngine
+	source   PolicySource
+	interval time.Duration
+	logger   log.Logger
+
+	mu sync.Mutex // Serializes reloads
+}
+
+// NewPolicyReloader creates a policy reloader. Run reloads every interval.
+func NewPolicyReloader(engine *PolicyEngine, source PolicySource, interval time.Duration, logger log.Logger) *PolicyReloader {
+	return &PolicyReloader{engine: engine, source: source, interval: interval, logger: logger}
+}
+
+// Source returns the source policies are loaded from
+func (r *PolicyReloader) Source() PolicySource {
+	return r.source
+}
+
+// Reload loads the source's policies into the engine if they differ from
+// the engine's and returns the engine's policy version. If the source fails
+// or any policy is invalid the current policies are kept and an error is
+// returned, as is ErrPolicyVersionConflict if the engine's policies changed
+// while loading.
+func (r *PolicyReloader) Reload() (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Policies changed through the API after this are not overwritten
+	// with what the source held before the change
+	current := r.engine.current.Load()
+	policies, err := r.source.LoadPolicies()
+	if err != nil {
+		return r.engine.Version(), err
+	}
+	// A set with an invalid policy could silently drop a deny
+	if err := ValidatePolicies(policies); err != nil {
+		return r.engine.Version(), err
+	}
+
+	digest, err := policiesDigest(policies)
+	if err != nil {
+		return r.engine.Version(), err
+	}
+	currentDigest, err := policiesDigest(current.policies)
+	if err != nil {
+		return r.engine.Version(), err
+	}
+	if digest == currentDigest {
+		return current.version, nil
+	}
+
+	version, err := r.engine.ReplacePoliciesIfVersion(current.version, policies)
+	if err != nil {
+		return version, err
+	}
+	r.logger.Info().Str("source", r.source.String()).Int("count", len(policies)).Uint64("version", version).Msg("policies reloaded")
+	return version, nil
+}
+
+// Run reloads policies every interval until the context is cancelled
+func (r *PolicyReloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.logger.Info().Str("source", r.source.String()).Dur("interval", r.interval).Msg("policy reloading started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info().Msg("policy reloading stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.Reload(); err != nil {
+				r.logger.Error().Err(err).Str("source", r.source.String()).Msg("policy reload failed, keeping current policies")
+			}
+		}
+	}
+}
+
+// policiesDigest hashes policies independently of their order, ignoring
+// timestamps
+func policiesDigest(policies []*models.Policy) ([sha256.Size]byte, error) {
+	encoded := make([]string, len(policies))
+	for i, policy := range policies {
+		normalized := *policy
+		normalized.CreatedAt = time.Time{}
+		normalized.UpdatedAt = time.Time{}
+		data, err := json.Marshal(&normalized)
+		if err != nil {
+			return [sha256.Size]byte{}, fmt.Errorf("failed to encode policy %s: %w", policy.ID, err)
+		}
+		encoded[i] = string(data)
+	}
+	sort.Strings(encoded)
+	return sha256.Sum256([]byte(strings.Join(encoded, "\n"))), nil
+}
@@ -0,0 +1,308 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+const policyYAML = `policies:
+  - id: read-releases
+    resource: releases/**
+    actions: [read]
+    effect: allow
+    principals: ["role:developer"]
+  - id: no-nightly
+    resource: "*/nightly/**"
+    actions: [read]
+    effect: deny
+    priority: 10
+    conditions:
+      sourceIp: "!10.0.0.0/8"
+`
+
+func TestPolicyEngineConcurrency(t *testing.T) {
+	// Given: An engine with a policy every evaluation depends on
+	engine := auth.NewPolicyEngine(false)
+	engine.AddPolicy(&models.Policy{ID: "base", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+	user := &models.User{ID: "user-1", Username: "dev"}
+
+	// When: Policies are updated while other goroutines evaluate them
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	denied := make(chan string, 1)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var lastVersion uint64
+			for ctx.Err() == nil {
+				decision := engine.Explain(user, "releases/app.jar", models.ActionRead, nil)
+				if !decision.Allowed {
+					select {
+					case denied <- decision.Reason:
+					default:
+					}
+				}
+				if decision.PolicyVersion < lastVersion {
+					select {
+					case denied <- "policy version went backwards":
+					default:
+					}
+				}
+				lastVersion = decision.PolicyVersion
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < 100; i++ {
+				id := fmt.Sprintf("p-%d-%d", w, i)
+				engine.AddPolicy(&models.Policy{ID: id, Resource: fmt.Sprintf("bucket-%d/*", i), Actions: []string{"write"}, Effect: models.PolicyEffectAllow})
+				engine.PutPolicy(&models.Policy{ID: "base", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Priority: i})
+				engine.RemovePolicy(id)
+			}
+		}(w)
+	}
+	writers.Wait()
+	cancel()
+	wg.Wait()
+
+	// Then: Every evaluation saw the base policy and versions only increased
+	select {
+	case reason := <-denied:
+		t.Fatalf("evaluation during updates failed: %s", reason)
+	default:
+	}
+	test.AssertEqual(t, uint64(1+4*100*3), engine.Version(), "one version per update")
+	test.AssertEqual(t, 1, len(engine.ListPolicies()), "remaining policies")
+}
+
+func TestReplacePoliciesIfVersion(t *testing.T) {
+	// Given: An engine whose policies change after a version was read
+	engine := auth.NewPolicyEngine(false)
+	version := engine.AddPolicy(&models.Policy{ID: "p1", Resource: "*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+	engine.AddPolicy(&models.Policy{ID: "p2", Resource: "*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow})
+
+	// When: Replacing the policies based on the stale version
+	_, err := engine.ReplacePoliciesIfVersion(version, nil)
+
+	// Then: The replacement is refused until it is based on the current version
+	test.AssertTrue(t, errors.Is(err, auth.ErrPolicyVersionConflict), "version conflict")
+	test.AssertEqual(t, 2, len(engine.ListPolicies()), "policies kept")
+	next, err := engine.ReplacePoliciesIfVersion(engine.Version(), nil)
+	test.AssertNoError(t, err, "replacing at the current version")
+	test.AssertEqual(t, version+2, next, "new version")
+	test.AssertEqual(t, 0, len(engine.ListPolicies()), "policies replaced")
+}
+
+func TestPolicyReloader(t *testing.T) {
+	developer := &models.User{ID: "user-1", Username: "dev", Roles: []string{"developer"}}
+
+	t.Run("Reloads a policy file when it changes", func(t *testing.T) {
+		// Given: A YAML policy file
+		path := filepath.Join(t.TempDir(), "policies.yaml")
+		test.AssertNoError(t, os.WriteFile(path, []byte(policyYAML), 0600), "writing policy file")
+		engine := auth.NewPolicyEngine(false)
+		reloader := auth.NewPolicyReloader(engine, auth.NewFilePolicySource(path), time.Hour, test.NewTestLogger(t))
+
+		// When: Loading it
+		version, err := reloader.Reload()
+
+		// Then: Its policies are evaluated
+		test.AssertNoError(t, err, "loading policy file")
+		test.AssertEqual(t, 2, len(engine.ListPolicies()), "policies loaded")
+		allowed, _ := engine.Authorize(developer, "releases/v1/app.jar", models.ActionRead, nil)
+		test.AssertTrue(t, allowed, "policy from file applies")
+
+		// And: Reloading an unchanged file keeps the version
+		again, err := reloader.Reload()
+		test.AssertNoError(t, err, "reloading unchanged file")
+		test.AssertEqual(t, version, again, "unchanged version")
+
+		// And: An invalid file is rejected and the loaded policies are kept
+		test.AssertNoError(t, os.WriteFile(path, []byte("policies:\n  - id: broken\n    resource: \"re:(\"\n    actions: [read]\n    effect: allow\n"), 0600), "writing invalid file")
+		_, err = reloader.Reload()
+		test.AssertError(t, err, "invalid policy file")
+		test.AssertEqual(t, version, engine.Version(), "version kept")
+		test.AssertEqual(t, 2, len(engine.ListPolicies()), "policies kept")
+	})
+
+	t.Run("Run picks up edits", func(t *testing.T) {
+		// Given: A running reloader of a JSON policy file
+		path := filepath.Join(t.TempDir(), "policies.json")
+		test.AssertNoError(t, os.WriteFile(path, []byte(`{"policies": []}`), 0600), "writing policy file")
+		engine := auth.NewPolicyEngine(false)
+		reloader := auth.NewPolicyReloader(engine, auth.NewFilePolicySource(path), 10*time.Millisecond, test.NewTestLogger(t))
+		_, err := reloader.Reload()
+		test.AssertNoError(t, err, "loading policy file")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reloader.Run(ctx)
+
+		// When: The file is edited
+		test.AssertNoError(t, os.WriteFile(path, []byte(`{"policies": [{"id": "p1", "resource": "*", "actions": ["read"], "effect": "allow"}]}`), 0600), "editing policy file")
+
+		// Then: The new policies are loaded
+		deadline := time.Now().Add(5 * time.Second)
+		for len(engine.ListPolicies()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		test.AssertEqual(t, 1, len(engine.ListPolicies()), "edited policies loaded")
+	})
+
+	t.Run("Reloads policies stored by other replicas", func(t *testing.T) {
+		// Given: An engine loaded from the metadata store
+		_, store := newTokenManager(t)
+		engine := auth.NewPolicyEngine(false)
+		reloader := auth.NewPolicyReloader(engine, auth.NewStorePolicySource(store), time.Hour, test.NewTestLogger(t))
+		_, err := reloader.Reload()
+		test.AssertNoError(t, err, "loading empty store")
+
+		// When: Another replica stores a policy and policies are reloaded
+		test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p1", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow}), "storing policy")
+		_, err = reloader.Reload()
+
+		// Then: The policy applies
+		test.AssertNoError(t, err, "reloading store")
+		allowed, _ := engine.Authorize(developer, "releases/app.jar", models.ActionRead, nil)
+		test.AssertTrue(t, allowed, "stored policy applies")
+	})
+
+	t.Run("Rejects stored policies whose patterns do not compile", func(t *testing.T) {
+		// Given: Policies loaded from the store
+		_, store := newTokenManager(t)
+		test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p1", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow}), "storing policy")
+		engine := auth.NewPolicyEngine(false)
+		reloader := auth.NewPolicyReloader(engine, auth.NewStorePolicySource(store), time.Hour, test.NewTestLogger(t))
+		version, err := reloader.Reload()
+		test.AssertNoError(t, err, "loading store")
+
+		// When: A policy whose pattern does not compile is stored and policies are reloaded
+		test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p2", Resource: "releases/[old", Actions: []string{"read"}, Effect: models.PolicyEffectDeny}), "storing policy")
+		_, err = reloader.Reload()
+
+		// Then: The reload fails and the loaded policies are kept
+		test.AssertError(t, err, "invalid stored policy")
+		test.AssertEqual(t, version, engine.Version(), "version kept")
+		test.AssertEqual(t, 1, len(engine.ListPolicies()), "policies kept")
+	})
+
+	t.Run("Rejects sources with invalid policies", func(t *testing.T) {
+		// Given: An engine with a deny loaded from a source
+		engine := auth.NewPolicyEngine(false)
+		deny := &models.Policy{ID: "deny", Resource: "prod/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny}
+		source := &staticPolicySource{policies: []*models.Policy{deny}}
+		reloader := auth.NewPolicyReloader(engine, source, time.Hour, test.NewTestLogger(t))
+		version, err := reloader.Reload()
+		test.AssertNoError(t, err, "loading source")
+
+		// When: The source's deny gets an invalid pattern
+		source.policies = []*models.Policy{{ID: "deny", Resource: "prod/[", Actions: []string{"read"}, Effect: models.PolicyEffectDeny}}
+		_, err = reloader.Reload()
+
+		// Then: The reload fails and the loaded deny is kept
+		test.AssertError(t, err, "invalid policy")
+		test.AssertEqual(t, version, engine.Version(), "version kept")
+		test.AssertEqual(t, "prod/*", engine.ListPolicies()[0].Resource, "loaded deny kept")
+	})
+
+	t.Run("Reloads a source reverted after API edits", func(t *testing.T) {
+		// Given: Policies loaded from the store, then edited through the API
+		_, store := newTokenManager(t)
+		readReleases := &models.Policy{ID: "p1", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow, Principals: []string{"role:developer"}}
+		test.AssertNoError(t, store.StorePolicy(readReleases), "storing policy")
+		engine := auth.NewPolicyEngine(false)
+		reloader := auth.NewPolicyReloader(engine, auth.NewStorePolicySource(store), time.Hour, test.NewTestLogger(t))
+		_, err := reloader.Reload()
+		test.AssertNoError(t, err, "loading store")
+		denyReleases := &models.Policy{ID: "p1", Resource: "releases/*", Actions: []string{"read"}, Effect: models.PolicyEffectDeny, Principals: []string{"role:developer"}}
+		test.AssertNoError(t, store.StorePolicy(denyReleases), "editing policy")
+		engine.PutPolicy(denyReleases)
+
+		// When: Another replica restores the loaded policies and policies are reloaded
+		test.AssertNoError(t, store.StorePolicy(readReleases), "restoring policy")
+		_, err = reloader.Reload()
+
+		// Then: The restored policy applies
+		test.AssertNoError(t, err, "reloading store")
+		allowed, _ := engine.Authorize(developer, "releases/app.jar", models.ActionRead, nil)
+		test.AssertTrue(t, allowed, "restored policy applies")
+	})
+
+	t.Run("Keeps policies changed while loading", func(t *testing.T) {
+		// Given: A source whose load races a policy change
+		engine := auth.NewPolicyEngine(false)
+		source := racingPolicySource{load: func() {
+			engine.AddPolicy(&models.Policy{ID: "api", Resource: "*", Actions: []string{"read"}, Effect: models.PolicyEffectAllow})
+		}}
+		reloader := auth.NewPolicyReloader(engine, source, time.Hour, test.NewTestLogger(t))
+
+		// When: Reloading
+		_, err := reloader.Reload()
+
+		// Then: The reload is refused and the change is kept
+		test.AssertTrue(t, errors.Is(err, auth.ErrPolicyVersionConflict), "version conflict")
+		policies := engine.ListPolicies()
+		test.AssertEqual(t, 1, len(policies), "changed policies kept")
+		test.AssertEqual(t, "api", policies[0].ID, "changed policy")
+	})
+}
+
+// staticPolicySource loads the policies it holds
+type staticPolicySource struct {
+	policies []*models.Policy
+}
+
+func (s *staticPolicySource) LoadPolicies() ([]*models.Policy, error) {
+	return s.policies, nil
+}
+
+func (s *staticPolicySource) String() string {
+	return "static source"
+}
+
+// racingPolicySource loads one policy, calling load while loading
+type racingPolicySource struct {
+	load func()
+}
+
+func (s racingPolicySource) LoadPolicies() ([]*models.Policy, error) {
+	s.load()
+	return []*models.Policy{{ID: "file", Resource: "*", Actions: []string{"write"}, Effect: models.PolicyEffectAllow}}, nil
+}
+
+func (s racingPolicySource) String() string {
+	return "racing source"
+}
+
+func TestParsePolicies(t *testing.T) {
+	// Given/When: The same policies in YAML and JSON
+	fromYAML, err := auth.ParsePolicies([]byte(policyYAML), auth.PolicyFormatYAML)
+	test.AssertNoError(t, err, "parsing YAML")
+	fromJSON, err := auth.ParsePolicies([]byte(`{"policies": [{"id": "no-nightly", "resource": "*/nightly/**", "actions": ["read"], "effect": "deny", "priority": 10}]}`), auth.PolicyFormatJSON)
+	test.AssertNoError(t, err, "parsing JSON")
+
+	// Then: Fields use the API's names in both
+	test.AssertEqual(t, 2, len(fromYAML), "YAML policies")
+	test.AssertEqual(t, "!10.0.0.0/8", fromYAML[1].Conditions["sourceIp"], "YAML conditions")
+	test.AssertEqual(t, fromJSON[0].Priority, fromYAML[1].Priority, "priority")
+	test.AssertEqual(t, auth.PolicyFormatYAML, auth.PolicyFormatForPath("policies.YML"), "YAML extension")
+
+	// And: Unknown fields are rejected
+	_, err = auth.ParsePolicies([]byte("policies:\n  - id: p1\n    resources: [a]\n"), auth.PolicyFormatYAML)
+	test.AssertError(t, err, "unknown field")
+}
@@ -19,7 +19,7 @@ func TestPolicyEngine(t *testing.T) {
 		}
 
 		// When: Checking authorization for any action
-		allowed, err := engine.Authorize(user, "any-bucket", models.ActionWrite)
+		allowed, err := engine.Authorize(user, "any-bucket", models.ActionWrite, nil)
 
 		// Then: Access is granted
 		test.AssertNoError(t, err, "admin authorization")
@@ -44,7 +44,7 @@ func TestPolicyEngine(t *testing.T) {
 		}
 
 		// When: User accesses allowed resource
-		allowed, err := engine.Authorize(user, "mybucket", models.ActionRead)
+		allowed, err := engine.Authorize(user, "mybucket", models.ActionRead, nil)
 
 		// Then: Access is granted
 		test.AssertNoError(t, err, "policy authorization")
@@ -69,7 +69,7 @@ func TestPolicyEngine(t *testing.T) {
 		}
 
 		// When: User accesses different resource
-		allowed, _ := engine.Authorize(user, "otherbucket", models.ActionRead)
+		allowed, _ := engine.Authorize(user, "otherbucket", models.ActionRead, nil)
 
 		// Then: Access is denied
 		test.AssertFalse(t, allowed, "no access to other resources")
@@ -93,7 +93,7 @@ func TestPolicyEngine(t *testing.T) {
 		}
 
 		// When: User accesses any resource
-		allowed, _ := engine.Authorize(user, "anybucket", models.ActionRead)
+		allowed, _ := engine.Authorize(user, "anybucket", models.ActionRead, nil)
 
 		// Then: Access is granted
 		test.AssertTrue(t, allowed, "wildcard allows all resources")
@@ -127,7 +127,7 @@ func TestPolicyEngine(t *testing.T) {
 		}
 
 		// When: Checking authorization
-		allowed, _ := engine.Authorize(user, "mybucket", models.ActionRead)
+		allowed, _ := engine.Authorize(user, "mybucket", models.ActionRead, nil)
 
 		// Then: Access is denied
 		test.AssertFalse(t, allowed, "deny takes precedence")
@@ -138,7 +138,7 @@ func TestPolicyEngine(t *testing.T) {
 		engine := auth.NewPolicyEngine(true)
 
 		// When: Anonymous user tries to read
-		allowed, err := engine.Authorize(nil, "anybucket", models.ActionRead)
+		allowed, err := engine.Authorize(nil, "anybucket", models.ActionRead, nil)
 
 		// Then: Access is granted
 		test.AssertNoError(t, err, "anonymous read")
@@ -150,9 +150,20 @@ func TestPolicyEngine(t *testing.T) {
 		engine := auth.NewPolicyEngine(true)
 
 		// When: Anonymous user tries to write
-		allowed, _ := engine.Authorize(nil, "anybucket", models.ActionWrite)
+		allowed, _ := engine.Authorize(nil, "anybucket", models.ActionWrite, nil)
 
 		// Then: Access is denied
 		test.AssertFalse(t, allowed, "anonymous write denied")
 	})
+
+	t.Run("Anonymous list denied", func(t *testing.T) {
+		// Given: Policy engine with anonymous GET enabled
+		engine := auth.NewPolicyEngine(true)
+
+		// When: Anonymous user tries to list
+		allowed, _ := engine.Authorize(nil, "anybucket", models.ActionList, nil)
+
+		// Then: Access is denied, anonymous GET only grants reads
+		test.AssertFalse(t, allowed, "anonymous list denied")
+	})
 }
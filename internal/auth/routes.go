@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// RoutePermission declares the action a route requires and the resource it
+// acts on
+type RoutePermission struct {
+	Method   string        `json:"method"`
+	Path     string        `json:"path"` // Path template exactly as registered, e.g. /s3/{bucket}/{key:.*}
+	Action   models.Action `json:"action,omitempty"`
+	Resource string        `json:"resource,omitempty"` // Route variables in braces are substituted, e.g. {bucket}/{key}
+	Public   bool          `json:"public,omitempty"`   // Served without authorization, e.g. health probes
+
+	// Authenticated routes are served to any authenticated user, e.g. to
+	// manage their own API tokens; the handler limits what they can reach
+	Authenticated bool `json:"authenticated,omitempty"`
+}
+
+// ResourceFor expands the resource template with a request's route variables.
+// The template is expanded in one pass, so braces inside a variable's value
+// are never substituted.
+func (p *RoutePermission) ResourceFor(vars map[string]string) string {
+	replacements := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(p.Resource)
+}
+
+// RouteTable holds the permissions declared for a router's routes
+type RouteTable struct {
+	permissions map[string]RoutePermission
+}
+
+// NewRouteTable indexes route permissions. Each method and path may be
+// declared once; routes that differ only in query parameters share it.
+func NewRouteTable(permissions []RoutePermission) (*RouteTable, error) {
+	table := &RouteTable{permissions: make(map[string]RoutePermission, len(permissions))}
+	for _, permission := range permissions {
+		if permission.Action == "" && !permission.Public && !permission.Authenticated {
+			return nil, fmt.Errorf("route %s %s declares no action", permission.Method, permission.Path)
+		}
+		key := routeKey(permission.Method, permission.Path)
+		if _, exists := table.permissions[key]; exists {
+			return nil, fmt.Errorf("route %s %s is declared more than once", permission.Method, permission.Path)
+		}
+		table.permissions[key] = permission
+	}
+	return table, nil
+}
+
+// Lookup returns the permission declared for the route a request matched
+func (t *RouteTable) Lookup(r *http.Request) (*RoutePermission, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil, false
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return nil, false
+	}
+	permission, ok := t.permissions[routeKey(r.Method, path)]
+	return &permission, ok
+}
+
+// Uncovered lists the routes of router, as "METHOD path", that have no
+// declared permission
+func (t *RouteTable) Uncovered(router *mux.Router) ([]string, error) {
+	var uncovered []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			// Routes without a path, such as subrouters matching on host, have no handler
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"*"}
+		}
+		for _, method := range methods {
+			if _, ok := t.permissions[routeKey(method, path)]; !ok {
+				uncovered = append(uncovered, method+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(uncovered)
+	return uncovered, nil
+}
+
+// AuthorizeRoutes authorizes each request for the action and resource its
+// route declares in routes. Requests to routes without a declaration are
+// refused, so a route added without one fails closed.
+func (m *Middleware) AuthorizeRoutes(routes *RouteTable) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !m.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			permission, ok := routes.Lookup(r)
+			if !ok {
+				m.logger.Error().Str("method", r.Method).Str("path", r.URL.Path).Msg("route has no declared permission")
+				http.Error(w, "Access denied", http.StatusForbidden)
+				return
+			}
+			if permission.Public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			authCtx, ok := GetAuthContextFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			if permission.Authenticated {
+				if authCtx.IsAnonymous {
+					http.Error(w, "Authentication required", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			resource := permission.ResourceFor(mux.Vars(r))
+			reqCtx := m.requestContext(r, permission.Action)
+			decision := m.policyEngine.Explain(authCtx.User, resource, permission.Action, reqCtx)
+			if !decision.Allowed {
+				m.logger.Warn().
+					Str("user", getUsername(authCtx.User)).
+					Str("resource", resource).
+					Str("action", string(permission.Action)).
+					Str("reason", decision.Reason).
+					Msg("authorization denied")
+
+				status := http.StatusForbidden
+				if authCtx.IsAnonymous {
+					status = http.StatusUnauthorized
+				}
+				if m.audit != nil {
+					m.audit.LogDecision(r, status, authCtx.User, resource, permission.Action, reqCtx, decision)
+				}
+				if authCtx.IsAnonymous {
+					http.Error(w, "Authentication required", status)
+					return
+				}
+				http.Error(w, "Access denied", status)
+				return
+			}
+
+			if m.audit == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+			m.audit.LogDecision(r, wrapped.statusCode, authCtx.User, resource, permission.Action, reqCtx, decision)
+		})
+	}
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
@@ -0,0 +1,196 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+// withUser adds an authenticated user's auth context to every request
+func withUser(user *models.User) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCtx := &models.AuthContext{User: user}
+			ctx := context.WithValue(r.Context(), auth.AuthContextKey, authCtx)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func newRouteTestRouter(t *testing.T, engine *auth.PolicyEngine, permissions []auth.RoutePermission, user *models.User) *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	router.HandleFunc("/s3/{bucket}/{key:.*}", ok).Methods("GET", "PUT")
+	router.HandleFunc("/health", ok).Methods("GET")
+	router.HandleFunc("/rbac/tokens", ok).Methods("POST")
+	router.HandleFunc("/undeclared", ok).Methods("GET")
+
+	table, err := auth.NewRouteTable(permissions)
+	test.AssertNoError(t, err, "building route table")
+
+	middleware := auth.NewMiddleware(nil, engine, test.NewTestLogger(t), true)
+	if user != nil {
+		router.Use(withUser(user))
+	} else {
+		router.Use(middleware.AuthenticateRequest)
+	}
+	router.Use(middleware.AuthorizeRoutes(table))
+	return router
+}
+
+func serve(router *mux.Router, method, path string) int {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+var routeTestPermissions = []auth.RoutePermission{
+	{Method: "GET", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionRead, Resource: "{bucket}/{key}"},
+	{Method: "PUT", Path: "/s3/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: "{bucket}/{key}"},
+	{Method: "GET", Path: "/health", Public: true},
+	{Method: "POST", Path: "/rbac/tokens", Authenticated: true},
+}
+
+func TestRouteTable(t *testing.T) {
+	t.Run("Rejects routes without an action", func(t *testing.T) {
+		// Given: A permission with neither an action nor public access
+		permissions := []auth.RoutePermission{{Method: "GET", Path: "/things"}}
+
+		// When: Building the route table
+		_, err := auth.NewRouteTable(permissions)
+
+		// Then: The declaration is rejected
+		test.AssertError(t, err, "route without action")
+	})
+
+	t.Run("Rejects duplicate declarations", func(t *testing.T) {
+		// Given: Two permissions for the same route
+		permissions := []auth.RoutePermission{
+			{Method: "GET", Path: "/things", Action: models.ActionRead},
+			{Method: "GET", Path: "/things", Action: models.ActionList},
+		}
+
+		// When: Building the route table
+		_, err := auth.NewRouteTable(permissions)
+
+		// Then: The declaration is rejected
+		test.AssertError(t, err, "duplicate route")
+	})
+
+	t.Run("Expands resource templates", func(t *testing.T) {
+		// Given: A permission with a resource template
+		permission := auth.RoutePermission{Resource: "{bucket}/{key}"}
+
+		// When: Expanding it with route variables
+		resource := permission.ResourceFor(map[string]string{"bucket": "releases", "key": "v1/app.tar.gz"})
+
+		// Then: The variables are substituted
+		test.AssertEqual(t, "releases/v1/app.tar.gz", resource, "expanded resource")
+	})
+
+	t.Run("Does not expand placeholders inside variables", func(t *testing.T) {
+		// Given: Variables whose values look like placeholders
+		permission := auth.RoutePermission{Resource: "{bucket}/{key}"}
+		vars := map[string]string{"bucket": "{key}", "key": "{bucket}/app.jar"}
+
+		// When: Expanding the template repeatedly
+		for i := 0; i < 20; i++ {
+			resource := permission.ResourceFor(vars)
+
+			// Then: The values are inserted verbatim
+			test.AssertEqual(t, "{key}/{bucket}/app.jar", resource, "expanded resource")
+		}
+	})
+
+	t.Run("Lists uncovered routes", func(t *testing.T) {
+		// Given: A router with a route missing from the table
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(false), routeTestPermissions, nil)
+		table, err := auth.NewRouteTable(routeTestPermissions)
+		test.AssertNoError(t, err, "building route table")
+
+		// When: Checking coverage
+		uncovered, err := table.Uncovered(router)
+
+		// Then: Only the undeclared route is reported
+		test.AssertNoError(t, err, "walking routes")
+		test.AssertEqual(t, 1, len(uncovered), "uncovered route count")
+		test.AssertEqual(t, "GET /undeclared", uncovered[0], "uncovered route")
+	})
+}
+
+func TestAuthorizeRoutes(t *testing.T) {
+	t.Run("Authorizes the declared action on the expanded resource", func(t *testing.T) {
+		// Given: A user allowed to read, but not write, one bucket
+		engine := auth.NewPolicyEngine(false)
+		engine.AddPolicy(&models.Policy{
+			ID:         "readers",
+			Resource:   "releases/*",
+			Actions:    []string{"read"},
+			Effect:     models.PolicyEffectAllow,
+			Principals: []string{"user-1"},
+		})
+		router := newRouteTestRouter(t, engine, routeTestPermissions, &models.User{ID: "user-1", Username: "alice"})
+
+		// When/Then: Reads of that bucket are allowed and everything else is denied
+		test.AssertEqual(t, http.StatusOK, serve(router, "GET", "/s3/releases/app.tar.gz"), "read in bucket")
+		test.AssertEqual(t, http.StatusForbidden, serve(router, "PUT", "/s3/releases/app.tar.gz"), "write in bucket")
+		test.AssertEqual(t, http.StatusForbidden, serve(router, "GET", "/s3/private/app.tar.gz"), "read in other bucket")
+	})
+
+	t.Run("Refuses routes without a declared permission", func(t *testing.T) {
+		// Given: An admin, who may do anything
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(false), routeTestPermissions, &models.User{ID: "admin-1", Roles: []string{"admin"}})
+
+		// When: Requesting an undeclared route
+		code := serve(router, "GET", "/undeclared")
+
+		// Then: The request is refused
+		test.AssertEqual(t, http.StatusForbidden, code, "undeclared route")
+	})
+
+	t.Run("Serves public routes anonymously", func(t *testing.T) {
+		// Given: A router without anonymous access
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(false), routeTestPermissions, nil)
+
+		// When/Then: Public routes are served and others require authentication
+		test.AssertEqual(t, http.StatusOK, serve(router, "GET", "/health"), "public route")
+		test.AssertEqual(t, http.StatusUnauthorized, serve(router, "GET", "/s3/releases/app.tar.gz"), "anonymous read")
+	})
+
+	t.Run("Serves authenticated routes to any authenticated user", func(t *testing.T) {
+		// Given: A user without any policy or role
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(false), routeTestPermissions, &models.User{ID: "user-1", Username: "alice", Roles: []string{"developer"}})
+
+		// When: Creating a personal token
+		code := serve(router, "POST", "/rbac/tokens")
+
+		// Then: The request reaches the handler
+		test.AssertEqual(t, http.StatusOK, code, "authenticated route")
+	})
+
+	t.Run("Refuses authenticated routes to anonymous users", func(t *testing.T) {
+		// Given: A router allowing anonymous access
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(true), routeTestPermissions, nil)
+
+		// When: Creating a personal token anonymously
+		code := serve(router, "POST", "/rbac/tokens")
+
+		// Then: Authentication is required
+		test.AssertEqual(t, http.StatusUnauthorized, code, "anonymous request")
+	})
+
+	t.Run("Allows anonymous reads only when configured", func(t *testing.T) {
+		// Given: A router allowing anonymous GET
+		router := newRouteTestRouter(t, auth.NewPolicyEngine(true), routeTestPermissions, nil)
+
+		// When/Then: Anonymous reads succeed and writes require authentication
+		test.AssertEqual(t, http.StatusOK, serve(router, "GET", "/s3/releases/app.tar.gz"), "anonymous read")
+		test.AssertEqual(t, http.StatusUnauthorized, serve(router, "PUT", "/s3/releases/app.tar.gz"), "anonymous write")
+	})
+}
@@ -0,0 +1,114 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/backup"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func TestScheduler(t *testing.T) {
+	for _, format := range []string{backup.FormatNative, backup.FormatJSONL} {
+		format := format
+		t.Run("Backup is written to the backend as "+format, func(t *testing.T) {
+			// Given: A metadata store with a policy and an in-memory backend
+			store := test.NewTestMetadataStore(t)
+			test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "policy-1"}), "store policy")
+			backend := storage.NewMemoryBackend(nil)
+			scheduler, err := backup.NewScheduler(&backup.Config{Format: format, Prefix: "nightly/"}, store, backend, test.NewTestLogger(t))
+			test.AssertNoError(t, err, "create scheduler")
+
+			// When: Taking a backup
+			result, err := scheduler.BackupNow(context.Background())
+
+			// Then: The backend holds it under the configured bucket and prefix
+			test.AssertNoError(t, err, "backup")
+			test.AssertEqual(t, "astore-backups", result.Bucket, "default bucket")
+			test.AssertTrue(t, strings.HasPrefix(result.Key, "nightly/metadata-"), "key prefix")
+			test.AssertTrue(t, strings.HasSuffix(result.Key, backup.Extension(format)), "key extension")
+			size, err := backend.GetObjectSize(context.Background(), result.Bucket, result.Key)
+			test.AssertNoError(t, err, "backup object")
+			test.AssertEqual(t, result.Size, size, "backup size")
+			test.AssertEqual(t, result, scheduler.Last(), "last backup")
+		})
+	}
+
+	t.Run("Unknown formats are rejected", func(t *testing.T) {
+		_, err := backup.NewScheduler(&backup.Config{Format: "zip"}, test.NewTestMetadataStore(t), storage.NewMemoryBackend(nil), test.NewTestLogger(t))
+		test.AssertError(t, err, "unknown format")
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("Export and import move metadata between servers", func(t *testing.T) {
+		// Given: A source server with a signature and an empty destination
+		source := test.NewTestMetadataStore(t)
+		test.AssertNoError(t, source.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "releases/app.jar"}), "store signature")
+		destination := test.NewTestMetadataStore(t)
+		sourceRouter, destinationRouter := mux.NewRouter(), mux.NewRouter()
+		backup.NewHandler(source, nil, test.NewTestLogger(t)).RegisterRoutes(sourceRouter)
+		backup.NewHandler(destination, nil, test.NewTestLogger(t)).RegisterRoutes(destinationRouter)
+
+		// When: Exporting from the source and importing into the destination
+		exported := httptest.NewRecorder()
+		sourceRouter.ServeHTTP(exported, httptest.NewRequest("GET", "/admin/metadata/export", nil))
+		imported := httptest.NewRecorder()
+		destinationRouter.ServeHTTP(imported, httptest.NewRequest("POST", "/admin/metadata/import", exported.Body))
+
+		// Then: The destination holds the signature
+		test.AssertEqual(t, http.StatusOK, exported.Code, "export status")
+		test.AssertTrue(t, strings.Contains(exported.Header().Get("Content-Disposition"), ".jsonl"), "export filename")
+		test.AssertEqual(t, http.StatusOK, imported.Code, "import status")
+		var response map[string]int
+		test.AssertNoError(t, json.NewDecoder(imported.Body).Decode(&response), "decode import response")
+		test.AssertEqual(t, 1, response["imported"], "imported records")
+		_, err := destination.GetSignature("sig-1")
+		test.AssertNoError(t, err, "imported signature")
+	})
+
+	t.Run("Backup download is a usable database", func(t *testing.T) {
+		// Given: A store with a policy
+		store := test.NewTestMetadataStore(t)
+		test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "policy-1"}), "store policy")
+		router := mux.NewRouter()
+		backup.NewHandler(store, nil, test.NewTestLogger(t)).RegisterRoutes(router)
+
+		// When: Downloading a backup and restoring it elsewhere
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/metadata/backup", nil))
+		test.AssertEqual(t, http.StatusOK, recorder.Code, "backup status")
+		config := &storage.MetadataConfig{DSN: filepath.Join(t.TempDir(), "restored.db")}
+		test.AssertNoError(t, storage.RestoreMetadataBackup(config, bytes.NewReader(recorder.Body.Bytes())), "restore")
+
+		// Then: The restored database holds the policy
+		restored, err := storage.OpenMetadataStore(config)
+		test.AssertNoError(t, err, "open restored store")
+		defer restored.Close()
+		_, err = restored.GetPolicy("policy-1")
+		test.AssertNoError(t, err, "restored policy")
+	})
+
+	t.Run("Backups to a backend require a scheduler", func(t *testing.T) {
+		router := mux.NewRouter()
+		backup.NewHandler(test.NewTestMetadataStore(t), nil, test.NewTestLogger(t)).RegisterRoutes(router)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", "/admin/metadata/backups", nil))
+		test.AssertEqual(t, http.StatusNotImplemented, recorder.Code, "no backend configured")
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", "/admin/metadata/import", io.NopCloser(strings.NewReader("{"))))
+		test.AssertEqual(t, http.StatusBadRequest, recorder.Code, "invalid import")
+	})
+}
@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Handler serves metadata backup and export endpoints
+type Handler struct {
+	store     storage.MetadataStore
+	scheduler *Scheduler // nil when no backup backend is configured
+	logger    log.Logger
+}
+
+// NewHandler creates a new backup handler. scheduler may be nil.
+func NewHandler(store storage.MetadataStore, scheduler *Scheduler, logger log.Logger) *Handler {
+	return &Handler{
+		store:     store,
+		scheduler: scheduler,
+		logger:    logger,
+	}
+}
+
+// RegisterRoutes registers backup routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/metadata/backup", h.DownloadBackup).Methods("GET")
+	router.HandleFunc("/admin/metadata/backups", h.CreateBackup).Methods("POST")
+	router.HandleFunc("/admin/metadata/backups/latest", h.GetLatestBackup).Methods("GET")
+	router.HandleFunc("/admin/metadata/export", h.Export).Methods("GET")
+	router.HandleFunc("/admin/metadata/import", h.Import).Methods("POST")
+}
+
+// RoutePermissions declares that every metadata backup route requires the
+// admin action
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	return []auth.RoutePermission{
+		{Method: "GET", Path: "/admin/metadata/backup", Action: models.ActionAdmin, Resource: "metadata"},
+		{Method: "POST", Path: "/admin/metadata/backups", Action: models.ActionAdmin, Resource: "metadata"},
+		{Method: "GET", Path: "/admin/metadata/backups/latest", Action: models.ActionAdmin, Resource: "metadata"},
+		{Method: "GET", Path: "/admin/metadata/export", Action: models.ActionAdmin, Resource: "metadata"},
+		{Method: "POST", Path: "/admin/metadata/import", Action: models.ActionAdmin, Resource: "metadata"},
+	}
+}
+
+// DownloadBackup streams a consistent copy of the metadata database
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	h.download(w, FormatNative, "application/octet-stream")
+}
+
+// Export streams every metadata record as JSON lines
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	h.download(w, FormatJSONL, "application/x-ndjson")
+}
+
+// Import loads JSON lines produced by Export into the metadata store
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	count, err := storage.ImportMetadata(h.store, r.Body)
+	if err != nil {
+		h.logger.Error().Err(err).Int("imported", count).Msg("failed to import metadata")
+		h.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":    err.Error(),
+			"imported": count,
+		})
+		return
+	}
+
+	h.logger.Info().Int("imported", count).Msg("metadata imported")
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"imported": count})
+}
+
+// CreateBackup writes a backup to the configured backend
+func (h *Handler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "No backup backend configured", http.StatusNotImplemented)
+		return
+	}
+
+	result, err := h.scheduler.BackupNow(r.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to back up metadata")
+		http.Error(w, "Failed to back up metadata", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, result)
+}
+
+// GetLatestBackup reports the most recent backup written to the backend
+func (h *Handler) GetLatestBackup(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "No backup backend configured", http.StatusNotImplemented)
+		return
+	}
+
+	result := h.scheduler.Last()
+	if result == nil {
+		http.Error(w, "No backup written since startup", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// download streams a backup in format as a file attachment
+func (h *Handler) download(w http.ResponseWriter, format, contentType string) {
+	filename := "metadata-" + time.Now().UTC().Format("20060102T150405Z") + Extension(format)
+	aw := &attachmentWriter{w: w, filename: filename, contentType: contentType}
+
+	if err := Write(h.store, format, aw); err != nil {
+		h.logger.Error().Err(err).Str("format", format).Msg("failed to stream metadata backup")
+		if !aw.started {
+			http.Error(w, "Failed to back up metadata: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// The status was already sent; abort so the client sees a truncated download
+		panic(http.ErrAbortHandler)
+	}
+	if !aw.started {
+		// An empty export still downloads as a file
+		aw.Write(nil)
+	}
+}
+
+// attachmentWriter sends download headers with the first write, so errors
+// raised before any data is produced can still be reported with a status
+type attachmentWriter struct {
+	w           http.ResponseWriter
+	filename    string
+	contentType string
+	started     bool
+}
+
+func (a *attachmentWriter) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", a.contentType)
+		a.w.Header().Set("Content-Disposition", `attachment; filename="`+a.filename+`"`)
+		a.w.WriteHeader(http.StatusOK)
+	}
+	return a.w.Write(p)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Backup formats
+const (
+	FormatNative = "native" // The store's own database file (bolt and sqlite)
+	FormatJSONL  = "jsonl"  // Portable JSON-lines export, readable by every driver
+)
+
+// Config holds the metadata backup configuration
+type Config struct {
+	Enabled  bool                   `json:"enabled" mapstructure:"enabled"` // Run scheduled backups
+	Interval time.Duration          `json:"interval" mapstructure:"interval"`
+	Format   string                 `json:"format" mapstructure:"format"` // native or jsonl
+	Backend  *storage.BackendConfig `json:"backend" mapstructure:"backend"`
+	Bucket   string                 `json:"bucket" mapstructure:"bucket"`
+	Prefix   string                 `json:"prefix" mapstructure:"prefix"`
+}
+
+// DefaultConfig returns the default backup configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Enabled:  false,
+		Interval: 24 * time.Hour,
+		Format:   FormatNative,
+		Bucket:   "astore-backups",
+		Prefix:   "metadata/",
+	}
+}
+
+// Result describes a backup written to the backend
+type Result struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	Format    string    `json:"format"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Scheduler writes metadata backups to a storage backend
+type Scheduler struct {
+	config  *Config
+	store   storage.MetadataStore
+	backend storage.Backend
+	logger  log.Logger
+	mu      sync.Mutex // Serializes backups
+	last    *Result
+}
+
+// NewScheduler creates a new backup scheduler
+func NewScheduler(config *Config, store storage.MetadataStore, backend storage.Backend, logger log.Logger) (*Scheduler, error) {
+	defaults := DefaultConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Format == "" {
+		config.Format = defaults.Format
+	}
+	if config.Bucket == "" {
+		config.Bucket = defaults.Bucket
+	}
+	if config.Format != FormatNative && config.Format != FormatJSONL {
+		return nil, fmt.Errorf("unknown backup format %q (expected %s or %s)", config.Format, FormatNative, FormatJSONL)
+	}
+
+	return &Scheduler{
+		config:  config,
+		store:   store,
+		backend: backend,
+		logger:  logger,
+	}, nil
+}
+
+// Run takes a backup every interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	s.logger.Info().Dur("interval", s.config.Interval).Str("bucket", s.config.Bucket).Msg("metadata backups started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("metadata backups stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.BackupNow(ctx); err != nil {
+				s.logger.Error().Err(err).Msg("scheduled metadata backup failed")
+			}
+		}
+	}
+}
+
+// BackupNow writes a backup to the backend and returns where it was stored
+func (s *Scheduler) BackupNow(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Backends need the size up front, so the backup is staged in a file
+	tmp, err := os.CreateTemp("", "astore-metadata-*.bak")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := Write(s.store, s.config.Format, tmp); err != nil {
+		return nil, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.backend.BucketExists(ctx, s.config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check backup bucket: %w", err)
+	}
+	if !exists {
+		if err := s.backend.CreateBucket(ctx, s.config.Bucket); err != nil {
+			return nil, fmt.Errorf("failed to create backup bucket: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	result := &Result{
+		Bucket:    s.config.Bucket,
+		Key:       s.config.Prefix + "metadata-" + now.Format("20060102T150405Z") + Extension(s.config.Format),
+		Format:    s.config.Format,
+		Size:      size,
+		CreatedAt: now,
+	}
+	if _, err := s.backend.WriteObject(ctx, result.Bucket, result.Key, tmp, size); err != nil {
+		return nil, fmt.Errorf("failed to upload backup: %w", err)
+	}
+
+	s.last = result
+	s.logger.Info().Str("bucket", result.Bucket).Str("key", result.Key).Int64("size", size).Msg("metadata backup written")
+	return result, nil
+}
+
+// Last returns the most recent successful backup, or nil
+func (s *Scheduler) Last() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Write writes a backup of store in format to w
+func Write(store storage.MetadataStore, format string, w io.Writer) error {
+	switch format {
+	case FormatNative:
+		if _, err := store.Backup(w); err != nil {
+			return err
+		}
+	case FormatJSONL:
+		if _, err := storage.ExportMetadata(store, w); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown backup format %q", format)
+	}
+	return nil
+}
+
+// Extension returns the file extension used for backups in format
+func Extension(format string) string {
+	if format == FormatJSONL {
+		return ".jsonl"
+	}
+	return ".db"
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	backupPkg "github.com/candlekeep/zot-artifact-store/internal/backup"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/api/config"
+	"zotregistry.io/zot/pkg/log"
+	zotStorage "zotregistry.io/zot/pkg/storage"
+)
+
+// BackupExtension provides metadata backup, export and import
+type BackupExtension struct {
+	config        *backupPkg.Config
+	logger        log.Logger
+	metadataStore storage.MetadataStore
+	scheduler     *backupPkg.Scheduler
+	handler       *backupPkg.Handler
+	stopSchedule  context.CancelFunc
+}
+
+// NewBackupExtension creates a new backup extension
+func NewBackupExtension() *BackupExtension {
+	return &BackupExtension{}
+}
+
+// Name returns the extension name
+func (e *BackupExtension) Name() string {
+	return "backup"
+}
+
+// IsEnabled checks if the extension is enabled
+func (e *BackupExtension) IsEnabled(cfg *config.Config) bool {
+	// The admin endpoints are always available; scheduled backups are opt-in
+	return true
+}
+
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *BackupExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
+// Setup initializes the extension
+func (e *BackupExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
+	e.logger = logger
+
+	// Load extension-specific configuration
+	e.config = backupPkg.DefaultConfig()
+
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
+	}
+
+	if e.config.Enabled {
+		if err := e.setupSchedule(); err != nil {
+			return err
+		}
+	}
+
+	e.handler = backupPkg.NewHandler(e.metadataStore, e.scheduler, logger)
+
+	e.logger.Info().
+		Bool("scheduled", e.config.Enabled).
+		Msg("Backup extension initialized")
+
+	return nil
+}
+
+// RegisterRoutes registers backup routes
+func (e *BackupExtension) RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error {
+	if e.handler == nil {
+		return fmt.Errorf("backup handler not initialized")
+	}
+
+	e.handler.RegisterRoutes(router)
+	e.logger.Info().Msg("Backup routes registered")
+
+	return nil
+}
+
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *BackupExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions()
+}
+
+// Shutdown performs cleanup
+func (e *BackupExtension) Shutdown(ctx context.Context) error {
+	e.logger.Info().Msg("Backup extension shutdown")
+
+	if e.stopSchedule != nil {
+		e.stopSchedule()
+	}
+
+	return nil
+}
+
+// setupSchedule creates the backup backend and starts scheduled backups
+func (e *BackupExtension) setupSchedule() error {
+	if e.config.Backend == nil {
+		return fmt.Errorf("scheduled backups require a backend configuration")
+	}
+
+	backend, err := storage.NewBackend(e.config.Backend)
+	if err != nil {
+		return fmt.Errorf("failed to initialize backup backend: %w", err)
+	}
+
+	scheduler, err := backupPkg.NewScheduler(e.config, e.metadataStore, backend, e.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize scheduled backups: %w", err)
+	}
+	e.scheduler = scheduler
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopSchedule = cancel
+	go scheduler.Run(ctx)
+
+	return nil
+}
@@ -2,13 +2,23 @@ package extensions
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"github.com/gorilla/mux"
 	"zotregistry.io/zot/pkg/api/config"
 	"zotregistry.io/zot/pkg/log"
-	"zotregistry.io/zot/pkg/storage"
+	zotStorage "zotregistry.io/zot/pkg/storage"
 )
 
+// DefaultMetadataDBPath is used when no storage root directory is configured
+const DefaultMetadataDBPath = "/tmp/zot-artifacts/metadata.db"
+
 // Extension defines the interface that all Zot Artifact Store extensions must implement.
 // Extensions provide additional functionality beyond the core Zot registry capabilities.
 type Extension interface {
@@ -19,16 +29,48 @@ type Extension interface {
 	IsEnabled(cfg *config.Config) bool
 
 	// Setup initializes the extension with configuration and dependencies
-	Setup(cfg *config.Config, storeController storage.StoreController, log log.Logger) error
+	Setup(cfg *config.Config, storeController zotStorage.StoreController, log log.Logger) error
 
 	// RegisterRoutes registers HTTP routes for the extension
 	// This is called during server initialization to add extension-specific endpoints
-	RegisterRoutes(router *mux.Router, storeController storage.StoreController) error
+	RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error
 
 	// Shutdown performs cleanup when the extension is being shut down
 	Shutdown(ctx context.Context) error
 }
 
+// MetadataConsumer is implemented by extensions that use the shared metadata store.
+// The registry injects the store before calling Setup.
+type MetadataConsumer interface {
+	SetMetadataStore(store storage.MetadataStore)
+}
+
+// RoutePermissionProvider is implemented by extensions that register routes.
+// Every route must declare the action it requires and the resource it acts on.
+type RoutePermissionProvider interface {
+	RoutePermissions() []auth.RoutePermission
+}
+
+// AuthProvider is implemented by the extension that authenticates requests.
+// The registry authorizes every extension route with its middleware.
+type AuthProvider interface {
+	GetMiddleware() *auth.Middleware
+}
+
+// ChangeNotifierProvider is implemented by the extension that tracks object
+// changes, e.g. to queue replication. A nil notifier means changes are not
+// tracked.
+type ChangeNotifierProvider interface {
+	ChangeNotifier() replication.ChangeNotifier
+}
+
+// ChangeNotifierConsumer is implemented by extensions that change objects or
+// their records outside the S3 API. After setup the registry passes them the
+// provider's notifier.
+type ChangeNotifierConsumer interface {
+	SetChangeNotifier(notifier replication.ChangeNotifier)
+}
+
 // ExtensionConfig defines common configuration for all extensions
 type ExtensionConfig struct {
 	Enabled bool `json:"enabled" mapstructure:"enabled"`
@@ -36,8 +78,9 @@ type ExtensionConfig struct {
 
 // Registry manages all registered extensions
 type Registry struct {
-	extensions map[string]Extension
-	logger     log.Logger
+	extensions    map[string]Extension
+	metadataStore storage.MetadataStore
+	logger        log.Logger
 }
 
 // NewRegistry creates a new extension registry
@@ -70,14 +113,72 @@ func (r *Registry) GetAll() map[string]Extension {
 	return r.extensions
 }
 
+// MetadataDBPath returns the metadata database path for a configuration
+func MetadataDBPath(cfg *config.Config) string {
+	if cfg.Storage.RootDirectory == "" {
+		return DefaultMetadataDBPath
+	}
+	return filepath.Join(cfg.Storage.RootDirectory, "metadata.db")
+}
+
+// OpenMetadataStore opens the metadata store shared by all extensions.
+// A nil metadataConfig selects BoltDB. File-based stores without a DSN are
+// created in the storage root directory. Opening the store brings its schema
+// up to date. The registry closes the store in ShutdownAll after all
+// extensions have shut down.
+func (r *Registry) OpenMetadataStore(cfg *config.Config, metadataConfig *storage.MetadataConfig) error {
+	if r.metadataStore != nil {
+		return fmt.Errorf("metadata store already open")
+	}
+
+	resolved := storage.MetadataConfig{Driver: storage.MetadataDriverBolt}
+	if metadataConfig != nil {
+		resolved = *metadataConfig
+	}
+	if resolved.DSN == "" {
+		switch resolved.Driver {
+		case "", storage.MetadataDriverBolt:
+			resolved.DSN = MetadataDBPath(cfg)
+		case storage.MetadataDriverSQLite:
+			resolved.DSN = filepath.Join(filepath.Dir(MetadataDBPath(cfg)), "metadata.sqlite")
+		}
+	}
+
+	store, err := storage.OpenMetadataStore(&resolved)
+	if err != nil {
+		return fmt.Errorf("failed to open metadata store: %w", err)
+	}
+	r.metadataStore = store
+	r.logger.Info().Str("driver", resolved.Driver).Msg("opened metadata store")
+	return nil
+}
+
+// SetMetadataStore sets an already open metadata store to share with extensions.
+// The registry takes ownership and closes it in ShutdownAll.
+func (r *Registry) SetMetadataStore(store storage.MetadataStore) {
+	r.metadataStore = store
+}
+
+// MetadataStore returns the shared metadata store, or nil if none is open
+func (r *Registry) MetadataStore() storage.MetadataStore {
+	return r.metadataStore
+}
+
 // SetupAll initializes all enabled extensions
-func (r *Registry) SetupAll(cfg *config.Config, storeController storage.StoreController) error {
+func (r *Registry) SetupAll(cfg *config.Config, storeController zotStorage.StoreController) error {
 	for name, ext := range r.extensions {
 		if !ext.IsEnabled(cfg) {
 			r.logger.Info().Str("extension", name).Msg("extension disabled, skipping setup")
 			continue
 		}
 
+		if consumer, ok := ext.(MetadataConsumer); ok {
+			if r.metadataStore == nil {
+				return fmt.Errorf("extension %s requires the metadata store, but it is not open", name)
+			}
+			consumer.SetMetadataStore(r.metadataStore)
+		}
+
 		r.logger.Info().Str("extension", name).Msg("setting up extension")
 		if err := ext.Setup(cfg, storeController, r.logger); err != nil {
 			r.logger.Error().Err(err).Str("extension", name).Msg("failed to setup extension")
@@ -85,11 +186,32 @@ func (r *Registry) SetupAll(cfg *config.Config, storeController storage.StoreCon
 		}
 		r.logger.Info().Str("extension", name).Msg("extension setup complete")
 	}
+	r.connectChangeNotifier(cfg)
 	return nil
 }
 
+// connectChangeNotifier passes the notifier of the extension tracking object
+// changes to the extensions that make changes outside the S3 API
+func (r *Registry) connectChangeNotifier(cfg *config.Config) {
+	var notifier replication.ChangeNotifier
+	for _, name := range r.enabledNames(cfg) {
+		if provider, ok := r.extensions[name].(ChangeNotifierProvider); ok && provider.ChangeNotifier() != nil {
+			notifier = provider.ChangeNotifier()
+			break
+		}
+	}
+	if notifier == nil {
+		return
+	}
+	for _, name := range r.enabledNames(cfg) {
+		if consumer, ok := r.extensions[name].(ChangeNotifierConsumer); ok {
+			consumer.SetChangeNotifier(notifier)
+		}
+	}
+}
+
 // RegisterAllRoutes registers HTTP routes for all enabled extensions
-func (r *Registry) RegisterAllRoutes(router *mux.Router, cfg *config.Config, storeController storage.StoreController) error {
+func (r *Registry) RegisterAllRoutes(router *mux.Router, cfg *config.Config, storeController zotStorage.StoreController) error {
 	for name, ext := range r.extensions {
 		if !ext.IsEnabled(cfg) {
 			continue
@@ -101,9 +223,64 @@ func (r *Registry) RegisterAllRoutes(router *mux.Router, cfg *config.Config, sto
 			return err
 		}
 	}
+	return r.authorizeRoutes(router, cfg)
+}
+
+// RouteTable collects the route permissions declared by all enabled extensions
+func (r *Registry) RouteTable(cfg *config.Config) (*auth.RouteTable, error) {
+	var permissions []auth.RoutePermission
+	for _, name := range r.enabledNames(cfg) {
+		if provider, ok := r.extensions[name].(RoutePermissionProvider); ok {
+			permissions = append(permissions, provider.RoutePermissions()...)
+		}
+	}
+	return auth.NewRouteTable(permissions)
+}
+
+// authorizeRoutes applies the middleware of the enabled auth provider to the
+// router, so every request is authorized for the permission its route
+// declares. Registration fails if any route declares none.
+func (r *Registry) authorizeRoutes(router *mux.Router, cfg *config.Config) error {
+	var middleware *auth.Middleware
+	for _, name := range r.enabledNames(cfg) {
+		if provider, ok := r.extensions[name].(AuthProvider); ok && provider.GetMiddleware() != nil {
+			middleware = provider.GetMiddleware()
+			break
+		}
+	}
+	if middleware == nil {
+		r.logger.Warn().Msg("no authentication extension enabled, extension routes are not authorized")
+		return nil
+	}
+
+	table, err := r.RouteTable(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid route permissions: %w", err)
+	}
+	uncovered, err := table.Uncovered(router)
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+	if len(uncovered) > 0 {
+		return fmt.Errorf("routes without declared permissions: %s", strings.Join(uncovered, ", "))
+	}
+
+	router.Use(middleware.AuthenticateRequest, middleware.AuthorizeRoutes(table))
 	return nil
 }
 
+// enabledNames returns the names of enabled extensions in a stable order
+func (r *Registry) enabledNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(r.extensions))
+	for name, ext := range r.extensions {
+		if ext.IsEnabled(cfg) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ShutdownAll shuts down all extensions gracefully
 func (r *Registry) ShutdownAll(ctx context.Context) error {
 	for name, ext := range r.extensions {
@@ -113,5 +290,13 @@ func (r *Registry) ShutdownAll(ctx context.Context) error {
 			// Continue shutting down other extensions even if one fails
 		}
 	}
+
+	// Close the shared metadata store once no extension can use it
+	if r.metadataStore != nil {
+		if err := r.metadataStore.Close(); err != nil {
+			r.logger.Error().Err(err).Msg("failed to close metadata store")
+		}
+		r.metadataStore = nil
+	}
 	return nil
 }
@@ -1,12 +1,30 @@
 package extensions_test
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/backup"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/metrics"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/rbac"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/relations"
 	"github.com/candlekeep/zot-artifact-store/internal/extensions/s3api"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/search"
+	"github.com/candlekeep/zot-artifact-store/internal/extensions/supplychain"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	scPkg "github.com/candlekeep/zot-artifact-store/internal/supplychain"
 	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
 	"zotregistry.io/zot/pkg/api/config"
+	"zotregistry.io/zot/pkg/log"
+	zotStorage "zotregistry.io/zot/pkg/storage"
 )
 
 // TestExtensionRegistry tests the extension registry functionality
@@ -44,9 +62,258 @@ func TestExtensionRegistry(t *testing.T) {
 		test.AssertTrue(t, exists, "s3api extension should exist")
 	})
 
-	// TODO: Add setup and shutdown tests in Phase 2+ when storage controller is actually used
-	// t.Run("Setup all enabled extensions", func(t *testing.T) { ... })
-	// t.Run("Shutdown all extensions", func(t *testing.T) { ... })
+	t.Run("Setup all enabled extensions with shared metadata store", func(t *testing.T) {
+		// Given: A registry with an open metadata store
+		logger := test.NewTestLogger(t)
+		registry := extensions.NewRegistry(logger)
+		registry.Register(s3api.NewS3APIExtension())
+		cfg := config.New()
+		cfg.Storage.RootDirectory = t.TempDir()
+		test.AssertNoError(t, registry.OpenMetadataStore(cfg, nil), "opening metadata store")
+
+		// When: Setting up all extensions
+		var storeController zotStorage.StoreController
+		err := registry.SetupAll(cfg, storeController)
+
+		// Then: Setup succeeds using the registry's store
+		test.AssertNoError(t, err, "setting up extensions")
+		test.AssertTrue(t, registry.MetadataStore() != nil, "metadata store should be open")
+		test.AssertNoError(t, registry.ShutdownAll(context.Background()), "shutting down extensions")
+	})
+
+	t.Run("Setup fails without metadata store", func(t *testing.T) {
+		// Given: A registry without a metadata store
+		registry := extensions.NewRegistry(test.NewTestLogger(t))
+		registry.Register(s3api.NewS3APIExtension())
+
+		// When: Setting up all extensions
+		var storeController zotStorage.StoreController
+		err := registry.SetupAll(config.New(), storeController)
+
+		// Then: Setup reports the missing store
+		test.AssertError(t, err, "setup without metadata store")
+	})
+
+	t.Run("Shutdown all closes metadata store after extensions", func(t *testing.T) {
+		// Given: Extensions set up with the shared store
+		registry := extensions.NewRegistry(test.NewTestLogger(t))
+		registry.Register(s3api.NewS3APIExtension())
+		cfg := config.New()
+		cfg.Storage.RootDirectory = t.TempDir()
+		test.AssertNoError(t, registry.OpenMetadataStore(cfg, nil), "opening metadata store")
+		store := registry.MetadataStore()
+		var storeController zotStorage.StoreController
+		test.AssertNoError(t, registry.SetupAll(cfg, storeController), "setting up extensions")
+
+		// When: Shutting down all extensions
+		err := registry.ShutdownAll(context.Background())
+
+		// Then: The store is closed and released by the registry
+		test.AssertNoError(t, err, "shutting down extensions")
+		test.AssertTrue(t, registry.MetadataStore() == nil, "registry should release the store")
+		_, err = store.ListBuckets()
+		test.AssertError(t, err, "store should be closed")
+
+		// And: The database can be opened again
+		test.AssertNoError(t, registry.OpenMetadataStore(cfg, nil), "reopening metadata store")
+		test.AssertNoError(t, registry.ShutdownAll(context.Background()), "shutting down again")
+	})
+}
+
+// TestSharedMetadataStore verifies that extensions share one metadata store
+func TestSharedMetadataStore(t *testing.T) {
+	// Given: One store injected into every extension that uses it
+	cfg := config.New()
+	cfg.Storage.RootDirectory = t.TempDir()
+	store, err := storage.NewBoltMetadataStore(extensions.MetadataDBPath(cfg))
+	test.AssertNoError(t, err, "opening metadata store")
+	defer store.Close()
+
+	exts := []extensions.Extension{
+		s3api.NewS3APIExtension(),
+		rbac.NewRBACExtension(),
+		supplychain.NewSupplyChainExtension(),
+		metrics.NewMetricsExtension(),
+		backup.NewBackupExtension(),
+		search.NewSearchExtension(),
+		relations.NewRelationsExtension(),
+	}
+
+	// When: Setting up each extension
+	var storeController zotStorage.StoreController
+	for _, ext := range exts {
+		consumer, ok := ext.(extensions.MetadataConsumer)
+		test.AssertTrue(t, ok, ext.Name()+" should consume the metadata store")
+		consumer.SetMetadataStore(store)
+		test.AssertNoError(t, ext.Setup(cfg, storeController, test.NewTestLogger(t)), "setting up "+ext.Name())
+	}
+
+	// Then: Every route the extensions register declares its permission
+	router := mux.NewRouter()
+	var permissions []auth.RoutePermission
+	for _, ext := range exts {
+		test.AssertNoError(t, ext.RegisterRoutes(router, storeController), "registering routes of "+ext.Name())
+		provider, ok := ext.(extensions.RoutePermissionProvider)
+		test.AssertTrue(t, ok, ext.Name()+" should declare route permissions")
+		permissions = append(permissions, provider.RoutePermissions()...)
+	}
+	table, err := auth.NewRouteTable(permissions)
+	test.AssertNoError(t, err, "building route table")
+	uncovered, err := table.Uncovered(router)
+	test.AssertNoError(t, err, "walking routes")
+	test.AssertEqual(t, "", strings.Join(uncovered, ", "), "routes without declared permissions")
+
+	// And: Shutting down extensions leaves the shared store open
+	for _, ext := range exts {
+		test.AssertNoError(t, ext.Shutdown(context.Background()), "shutting down "+ext.Name())
+	}
+	_, err = store.ListBuckets()
+	test.AssertNoError(t, err, "store should remain open")
+}
+
+// routeExtension is a minimal extension serving /things
+type routeExtension struct {
+	name        string
+	middleware  *auth.Middleware
+	permissions []auth.RoutePermission
+}
+
+func (e *routeExtension) Name() string                      { return e.name }
+func (e *routeExtension) IsEnabled(cfg *config.Config) bool { return true }
+func (e *routeExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
+	return nil
+}
+func (e *routeExtension) RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/"+e.name, ok).Methods("GET", "PUT")
+	return nil
+}
+func (e *routeExtension) Shutdown(ctx context.Context) error       { return nil }
+func (e *routeExtension) GetMiddleware() *auth.Middleware          { return e.middleware }
+func (e *routeExtension) RoutePermissions() []auth.RoutePermission { return e.permissions }
+
+// recordingNotifier records the objects it is told about
+type recordingNotifier struct {
+	changed []string
+}
+
+func (n *recordingNotifier) ObjectChanged(bucket, key string) error {
+	n.changed = append(n.changed, bucket+"/"+key)
+	return nil
+}
+
+func (n *recordingNotifier) ObjectDeleted(bucket, key string) error { return nil }
+
+// notifierExtension provides or consumes a change notifier
+type notifierExtension struct {
+	routeExtension
+	provided replication.ChangeNotifier
+	consumed replication.ChangeNotifier
+}
+
+func (e *notifierExtension) ChangeNotifier() replication.ChangeNotifier { return e.provided }
+func (e *notifierExtension) SetChangeNotifier(notifier replication.ChangeNotifier) {
+	e.consumed = notifier
+}
+
+// TestChangeNotifier tests that supply-chain changes reach the replicator
+func TestChangeNotifier(t *testing.T) {
+	t.Run("Setup passes the provider's notifier to consumers", func(t *testing.T) {
+		// Given: An extension tracking changes and one making changes
+		registry := extensions.NewRegistry(test.NewTestLogger(t))
+		notifier := &recordingNotifier{}
+		provider := &notifierExtension{routeExtension: routeExtension{name: "provider"}, provided: notifier}
+		consumer := &notifierExtension{routeExtension: routeExtension{name: "consumer"}}
+		registry.Register(provider)
+		registry.Register(consumer)
+
+		// When: Setting up all extensions
+		var storeController zotStorage.StoreController
+		err := registry.SetupAll(config.New(), storeController)
+
+		// Then: The consumer reports changes to the provider's notifier
+		test.AssertNoError(t, err, "setting up extensions")
+		test.AssertTrue(t, consumer.consumed == replication.ChangeNotifier(notifier), "consumer has the notifier")
+	})
+
+	t.Run("Supply-chain records queue their artifact for replication", func(t *testing.T) {
+		// Given: A supply chain handler with a notifier and a stored artifact
+		store := test.NewTestMetadataStore(t)
+		test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases", Key: "app.jar"}), "storing artifact")
+		signer, _, _, err := scPkg.GenerateKeyPair(2048)
+		test.AssertNoError(t, err, "generating key")
+		handler := supplychain.NewHandler(store, signer, test.NewTestLogger(t))
+		notifier := &recordingNotifier{}
+		handler.SetChangeNotifier(notifier)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router)
+
+		// When: Signing the artifact and attaching an SBOM and an attestation
+		for _, request := range []struct{ path, body string }{
+			{"/supplychain/sign/releases/app.jar", `{"signedBy": "ci"}`},
+			{"/supplychain/sbom/releases/app.jar", `{"format": "spdx", "content": "{}"}`},
+			{"/supplychain/attestations/releases/app.jar", `{"type": "build"}`},
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", request.path, strings.NewReader(request.body)))
+			test.AssertEqual(t, http.StatusCreated, w.Code, request.path)
+		}
+
+		// Then: Each change queues the artifact
+		test.AssertEqual(t, 3, len(notifier.changed), "changes")
+		for _, changed := range notifier.changed {
+			test.AssertEqual(t, "releases/app.jar", changed, "changed object")
+		}
+	})
+}
+
+// TestRegistryAuthorizesRoutes tests that the registry enforces declared route permissions
+func TestRegistryAuthorizesRoutes(t *testing.T) {
+	thingPermissions := []auth.RoutePermission{
+		{Method: "GET", Path: "/things", Action: models.ActionRead, Resource: "things"},
+		{Method: "PUT", Path: "/things", Action: models.ActionWrite, Resource: "things"},
+	}
+
+	t.Run("Requests are authorized with the auth provider's middleware", func(t *testing.T) {
+		// Given: An enabled auth provider allowing anonymous reads
+		logger := test.NewTestLogger(t)
+		middleware := auth.NewMiddleware(nil, auth.NewPolicyEngine(true), logger, true)
+		registry := extensions.NewRegistry(logger)
+		registry.Register(&routeExtension{name: "things", middleware: middleware, permissions: thingPermissions})
+		router := mux.NewRouter()
+		var storeController zotStorage.StoreController
+
+		// When: Registering all routes
+		err := registry.RegisterAllRoutes(router, config.New(), storeController)
+
+		// Then: Anonymous reads are served and anonymous writes are refused
+		test.AssertNoError(t, err, "registering routes")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/things", nil))
+		test.AssertEqual(t, http.StatusOK, w.Code, "anonymous read")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/things", nil))
+		test.AssertEqual(t, http.StatusUnauthorized, w.Code, "anonymous write")
+	})
+
+	t.Run("Routes without declared permissions are rejected", func(t *testing.T) {
+		// Given: An auth provider and an extension declaring only one of its routes
+		logger := test.NewTestLogger(t)
+		middleware := auth.NewMiddleware(nil, auth.NewPolicyEngine(false), logger, true)
+		registry := extensions.NewRegistry(logger)
+		registry.Register(&routeExtension{name: "things", middleware: middleware, permissions: thingPermissions})
+		registry.Register(&routeExtension{name: "widgets", permissions: []auth.RoutePermission{
+			{Method: "GET", Path: "/widgets", Action: models.ActionRead, Resource: "widgets"},
+		}})
+		var storeController zotStorage.StoreController
+
+		// When: Registering all routes
+		err := registry.RegisterAllRoutes(mux.NewRouter(), config.New(), storeController)
+
+		// Then: Registration fails naming the undeclared route
+		test.AssertError(t, err, "registering undeclared route")
+		test.AssertTrue(t, strings.Contains(err.Error(), "PUT /widgets"), "error should name the route")
+	})
 }
 
 // TestS3APIExtension tests the S3 API extension
@@ -78,6 +345,55 @@ func TestS3APIExtension(t *testing.T) {
 	// t.Run("Extension setup succeeds", func(t *testing.T) { ... })
 }
 
+// TestRBACPolicyChanges tests that policy changes keep the store and engine together
+func TestRBACPolicyChanges(t *testing.T) {
+	// Given: An RBAC handler serving the policy API
+	store := test.NewTestMetadataStore(t)
+	engine := auth.NewPolicyEngine(false)
+	handler := rbac.NewHandler(engine, nil, nil, store, test.NewTestLogger(t))
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	// When: Creating a policy at the current version
+	w := serve("POST", "/rbac/policies?ifVersion=0", `{"id": "p1", "resource": "releases/*", "actions": ["read"]}`)
+
+	// Then: It is stored and evaluated, and the new version is returned
+	test.AssertEqual(t, http.StatusCreated, w.Code, "create policy")
+	test.AssertEqual(t, "1", w.Header().Get("X-Policy-Version"), "version after create")
+	_, err := store.GetPolicy("p1")
+	test.AssertNoError(t, err, "stored policy")
+	test.AssertEqual(t, 1, len(engine.ListPolicies()), "evaluated policies")
+
+	// And: Changes based on a stale version are refused
+	w = serve("PUT", "/rbac/policies/p1?ifVersion=0", `{"resource": "releases/*", "actions": ["write"]}`)
+	test.AssertEqual(t, http.StatusConflict, w.Code, "stale update")
+	w = serve("DELETE", "/rbac/policies/p1?ifVersion=0", "")
+	test.AssertEqual(t, http.StatusConflict, w.Code, "stale delete")
+
+	// And: Creating a policy with a stored ID replaces it in the engine
+	w = serve("POST", "/rbac/policies", `{"id": "p1", "resource": "releases/*", "actions": ["write"]}`)
+	test.AssertEqual(t, http.StatusCreated, w.Code, "recreate policy")
+	test.AssertEqual(t, 1, len(engine.ListPolicies()), "evaluated policies after recreate")
+
+	// And: Policies are validated like policy files
+	w = serve("PUT", "/rbac/policies/p1", `{"resource": "releases/*", "actions": ["read"], "effect": "maybe"}`)
+	test.AssertEqual(t, http.StatusBadRequest, w.Code, "unknown effect")
+	w = serve("PUT", "/rbac/policies/p1", `{"resource": "releases/*"}`)
+	test.AssertEqual(t, http.StatusBadRequest, w.Code, "no actions")
+
+	// And: A delete at the current version removes the policy from both
+	w = serve("DELETE", "/rbac/policies/p1?ifVersion=2", "")
+	test.AssertEqual(t, http.StatusNoContent, w.Code, "delete policy")
+	test.AssertEqual(t, 0, len(engine.ListPolicies()), "evaluated policies after delete")
+	_, err = store.GetPolicy("p1")
+	test.AssertError(t, err, "deleted policy")
+}
+
 // Benchmark tests for performance
 func BenchmarkExtensionRegistration(b *testing.B) {
 	logger := test.NewTestLogger(&testing.T{})
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/metrics"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"zotregistry.io/zot/pkg/log"
@@ -48,6 +50,21 @@ func (h *Handler) RegisterRoutes(router *mux.Router, config *Config) {
 	}
 }
 
+// RoutePermissions declares the action and resource each route requires.
+// Health probes are public so orchestrators can call them without credentials.
+func (h *Handler) RoutePermissions(config *Config) []auth.RoutePermission {
+	var permissions []auth.RoutePermission
+	if config.Prometheus.Enabled {
+		permissions = append(permissions, auth.RoutePermission{Method: "GET", Path: config.Prometheus.Path, Action: models.ActionRead, Resource: "metrics"})
+	}
+	if config.Health.Enabled {
+		for _, path := range []string{config.Health.HealthPath, config.Health.ReadinessPath, config.Health.LivenessPath} {
+			permissions = append(permissions, auth.RoutePermission{Method: "GET", Path: path, Public: true})
+		}
+	}
+	return permissions
+}
+
 // GetHealth returns comprehensive health information
 func (h *Handler) GetHealth(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -3,8 +3,8 @@ package metrics
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/metrics"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"github.com/gorilla/mux"
@@ -18,7 +18,7 @@ type MetricsExtension struct {
 	config            *Config
 	logger            log.Logger
 	storeController   zotStorage.StoreController
-	metadataStore     *storage.MetadataStore
+	metadataStore     storage.MetadataStore
 	prometheusMetrics *metrics.PrometheusCollector
 	healthChecker     *metrics.HealthChecker
 	tracingProvider   *metrics.TracingProvider
@@ -27,11 +27,10 @@ type MetricsExtension struct {
 
 // Config holds the metrics extension configuration
 type Config struct {
-	Enabled         bool          `json:"enabled" mapstructure:"enabled"`
-	Prometheus      PrometheusCfg `json:"prometheus" mapstructure:"prometheus"`
-	Tracing         TracingCfg    `json:"tracing" mapstructure:"tracing"`
-	Health          HealthCfg     `json:"health" mapstructure:"health"`
-	MetadataDBPath  string        `json:"metadataDBPath" mapstructure:"metadataDBPath"`
+	Enabled    bool          `json:"enabled" mapstructure:"enabled"`
+	Prometheus PrometheusCfg `json:"prometheus" mapstructure:"prometheus"`
+	Tracing    TracingCfg    `json:"tracing" mapstructure:"tracing"`
+	Health     HealthCfg     `json:"health" mapstructure:"health"`
 }
 
 // PrometheusCfg holds Prometheus configuration
@@ -70,6 +69,11 @@ func (e *MetricsExtension) IsEnabled(cfg *config.Config) bool {
 	return e.config != nil && e.config.Enabled
 }
 
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *MetricsExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
 // Setup initializes the extension
 func (e *MetricsExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
 	e.logger = logger
@@ -95,23 +99,15 @@ func (e *MetricsExtension) Setup(cfg *config.Config, storeController zotStorage.
 		},
 	}
 
-	// Set metadata DB path
-	if cfg.Storage.RootDirectory != "" {
-		e.config.MetadataDBPath = filepath.Join(cfg.Storage.RootDirectory, "metadata.db")
-	} else {
-		e.config.MetadataDBPath = "/tmp/zot-artifacts/metadata.db"
-	}
-
-	// Initialize metadata store (shared with other extensions)
-	metadataStore, err := storage.NewMetadataStore(e.config.MetadataDBPath)
-	if err != nil {
-		return fmt.Errorf("failed to initialize metadata store: %w", err)
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
 	}
-	e.metadataStore = metadataStore
 
 	// Initialize Prometheus metrics collector
 	if e.config.Prometheus.Enabled {
 		e.prometheusMetrics = metrics.NewPrometheusCollector()
+		storage.SetCacheMetrics(e.prometheusMetrics)
 		e.logger.Info().Msg("Prometheus metrics collector initialized")
 	}
 
@@ -162,6 +158,14 @@ func (e *MetricsExtension) RegisterRoutes(router *mux.Router, storeController zo
 	return nil
 }
 
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *MetricsExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions(e.config)
+}
+
 // Shutdown performs cleanup
 func (e *MetricsExtension) Shutdown(ctx context.Context) error {
 	e.logger.Info().Msg("Metrics extension shutdown")
@@ -173,14 +177,6 @@ func (e *MetricsExtension) Shutdown(ctx context.Context) error {
 		}
 	}
 
-	// Close metadata store
-	if e.metadataStore != nil {
-		if err := e.metadataStore.Close(); err != nil {
-			e.logger.Error().Err(err).Msg("failed to close metadata store")
-			return err
-		}
-	}
-
 	return nil
 }
 
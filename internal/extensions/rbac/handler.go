@@ -2,8 +2,13 @@ package rbac
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/candlekeep/zot-artifact-store/internal/auth"
@@ -18,40 +23,131 @@ import (
 type Handler struct {
 	policyEngine  *auth.PolicyEngine
 	auditLogger   *auth.AuditLogger
-	metadataStore *storage.MetadataStore
+	apiTokens     *auth.APITokenManager
+	metadataStore storage.MetadataStore
 	logger        log.Logger
+
+	policyReloader   *auth.PolicyReloader // Optional; nil disables POST /rbac/policies/reload
+	policiesFromFile bool                 // Policies are managed in a policy file and read-only here
+	userDirectory    *auth.UserDirectory  // Users authorization queries are answered for
+
+	policyMu sync.Mutex // Serializes policy changes, so the store and engine change together
 }
 
 // NewHandler creates a new RBAC handler
-func NewHandler(policyEngine *auth.PolicyEngine, auditLogger *auth.AuditLogger, metadataStore *storage.MetadataStore, logger log.Logger) *Handler {
+func NewHandler(policyEngine *auth.PolicyEngine, auditLogger *auth.AuditLogger, apiTokens *auth.APITokenManager, metadataStore storage.MetadataStore, logger log.Logger) *Handler {
 	return &Handler{
 		policyEngine:  policyEngine,
 		auditLogger:   auditLogger,
+		apiTokens:     apiTokens,
 		metadataStore: metadataStore,
 		logger:        logger,
 	}
 }
 
+// SetPolicyReloader enables reloading policies on request. If policies come
+// from a policy file, the policy API becomes read-only.
+func (h *Handler) SetPolicyReloader(reloader *auth.PolicyReloader, fromFile bool) {
+	h.policyReloader = reloader
+	h.policiesFromFile = fromFile
+}
+
+// SetUserDirectory sets the directory users of authorization queries are
+// looked up in
+func (h *Handler) SetUserDirectory(directory *auth.UserDirectory) {
+	h.userDirectory = directory
+}
+
 // RegisterRoutes registers RBAC API routes
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// Policy management
 	router.HandleFunc("/rbac/policies", h.CreatePolicy).Methods("POST")
 	router.HandleFunc("/rbac/policies", h.ListPolicies).Methods("GET")
+	router.HandleFunc("/rbac/policies/export", h.ExportPolicies).Methods("GET")
+	router.HandleFunc("/rbac/policies/import", h.ImportPolicies).Methods("POST")
+	router.HandleFunc("/rbac/policies/simulate", h.SimulatePolicies).Methods("POST")
 	router.HandleFunc("/rbac/policies/{id}", h.GetPolicy).Methods("GET")
 	router.HandleFunc("/rbac/policies/{id}", h.UpdatePolicy).Methods("PUT")
 	router.HandleFunc("/rbac/policies/{id}", h.DeletePolicy).Methods("DELETE")
+	router.HandleFunc("/rbac/policies/reload", h.ReloadPolicies).Methods("POST")
 
 	// Authorization check
 	router.HandleFunc("/rbac/authorize", h.CheckAuthorization).Methods("POST")
+	router.HandleFunc("/rbac/access", h.WhoCan).Methods("GET")
+
+	// User directory
+	router.HandleFunc("/rbac/users", h.ListUsers).Methods("GET")
+	router.HandleFunc("/rbac/users/{id}", h.GetUser).Methods("GET")
+	router.HandleFunc("/rbac/users/{id}/access", h.GetUserAccess).Methods("GET")
 
 	// Audit logs
 	router.HandleFunc("/rbac/audit", h.ListAuditLogs).Methods("GET")
+	router.HandleFunc("/rbac/audit/verify", h.VerifyAuditLogs).Methods("GET")
+
+	// Service accounts and their tokens
+	router.HandleFunc("/rbac/service-accounts", h.CreateServiceAccount).Methods("POST")
+	router.HandleFunc("/rbac/service-accounts", h.ListServiceAccounts).Methods("GET")
+	router.HandleFunc("/rbac/service-accounts/{id}", h.GetServiceAccount).Methods("GET")
+	router.HandleFunc("/rbac/service-accounts/{id}", h.DeleteServiceAccount).Methods("DELETE")
+	router.HandleFunc("/rbac/service-accounts/{id}/tokens", h.CreateServiceAccountToken).Methods("POST")
+	router.HandleFunc("/rbac/service-accounts/{id}/tokens", h.ListServiceAccountTokens).Methods("GET")
+
+	// Personal access tokens of the calling user
+	router.HandleFunc("/rbac/tokens", h.CreateToken).Methods("POST")
+	router.HandleFunc("/rbac/tokens", h.ListTokens).Methods("GET")
+	router.HandleFunc("/rbac/tokens/{id}", h.RevokeToken).Methods("DELETE")
+}
+
+// RoutePermissions declares that every RBAC route requires the admin action,
+// except personal token routes, which any authenticated user may call
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	permissions := []auth.RoutePermission{
+		{Method: "POST", Path: "/rbac/policies"},
+		{Method: "GET", Path: "/rbac/policies"},
+		{Method: "GET", Path: "/rbac/policies/{id}"},
+		{Method: "PUT", Path: "/rbac/policies/{id}"},
+		{Method: "DELETE", Path: "/rbac/policies/{id}"},
+		{Method: "POST", Path: "/rbac/policies/reload"},
+		{Method: "GET", Path: "/rbac/policies/export"},
+		{Method: "POST", Path: "/rbac/policies/import"},
+		{Method: "POST", Path: "/rbac/policies/simulate"},
+		{Method: "POST", Path: "/rbac/authorize"},
+		{Method: "GET", Path: "/rbac/access"},
+		{Method: "GET", Path: "/rbac/users"},
+		{Method: "GET", Path: "/rbac/users/{id}"},
+		{Method: "GET", Path: "/rbac/users/{id}/access"},
+		{Method: "GET", Path: "/rbac/audit"},
+		{Method: "GET", Path: "/rbac/audit/verify"},
+		{Method: "POST", Path: "/rbac/service-accounts"},
+		{Method: "GET", Path: "/rbac/service-accounts"},
+		{Method: "GET", Path: "/rbac/service-accounts/{id}"},
+		{Method: "DELETE", Path: "/rbac/service-accounts/{id}"},
+		{Method: "POST", Path: "/rbac/service-accounts/{id}/tokens"},
+		{Method: "GET", Path: "/rbac/service-accounts/{id}/tokens"},
+	}
+	for i := range permissions {
+		permissions[i].Action = models.ActionAdmin
+		permissions[i].Resource = "rbac"
+	}
+	return append(permissions,
+		auth.RoutePermission{Method: "POST", Path: "/rbac/tokens", Authenticated: true},
+		auth.RoutePermission{Method: "GET", Path: "/rbac/tokens", Authenticated: true},
+		auth.RoutePermission{Method: "DELETE", Path: "/rbac/tokens/{id}", Authenticated: true},
+	)
 }
 
 // === Policy Operations ===
 
 // CreatePolicy creates a new access control policy
 func (h *Handler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	if h.policiesReadOnly(w) {
+		return
+	}
+	ifVersion, ok := parseIfVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var policy models.Policy
 	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -69,16 +165,18 @@ func (h *Handler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 	policy.UpdatedAt = now
 
 	// Validate policy
-	if policy.Resource == "" {
-		http.Error(w, "Resource is required", http.StatusBadRequest)
-		return
+	if policy.Effect == "" {
+		policy.Effect = models.PolicyEffectAllow
 	}
-	if len(policy.Actions) == 0 {
-		http.Error(w, "At least one action is required", http.StatusBadRequest)
+	if err := auth.ValidatePolicy(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if policy.Effect == "" {
-		policy.Effect = models.PolicyEffectAllow
+
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	if !h.policyVersionMatches(w, ifVersion) {
+		return
 	}
 
 	// Store policy in database
@@ -88,17 +186,19 @@ func (h *Handler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add policy to engine
-	h.policyEngine.AddPolicy(&policy)
+	// Add policy to engine, replacing one stored with the same ID
+	version := h.policyEngine.PutPolicy(&policy)
 
 	h.logger.Info().Str("policyId", policy.ID).Str("resource", policy.Resource).Msg("policy created")
 
+	w.Header().Set("X-Policy-Version", strconv.FormatUint(version, 10))
 	h.writeJSON(w, http.StatusCreated, policy)
 }
 
 // ListPolicies lists all policies
 func (h *Handler) ListPolicies(w http.ResponseWriter, r *http.Request) {
-	policies, err := h.metadataStore.ListPolicies()
+	version := h.policyEngine.Version()
+	policies, err := h.currentPolicies()
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to list policies")
 		http.Error(w, "Failed to list policies", http.StatusInternalServerError)
@@ -108,6 +208,7 @@ func (h *Handler) ListPolicies(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"policies": policies,
 		"count":    len(policies),
+		"version":  version,
 	})
 }
 
@@ -116,6 +217,17 @@ func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	policyID := vars["id"]
 
+	if h.policiesFromFile {
+		for _, policy := range h.policyEngine.ListPolicies() {
+			if policy.ID == policyID {
+				h.writeJSON(w, http.StatusOK, policy)
+				return
+			}
+		}
+		http.Error(w, "Policy not found", http.StatusNotFound)
+		return
+	}
+
 	policy, err := h.metadataStore.GetPolicy(policyID)
 	if err != nil {
 		http.Error(w, "Policy not found", http.StatusNotFound)
@@ -127,6 +239,15 @@ func (h *Handler) GetPolicy(w http.ResponseWriter, r *http.Request) {
 
 // UpdatePolicy updates an existing policy
 func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	if h.policiesReadOnly(w) {
+		return
+	}
+
+	ifVersion, ok := parseIfVersion(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	policyID := vars["id"]
 
@@ -140,6 +261,20 @@ func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 	policy.ID = policyID
 	policy.UpdatedAt = time.Now()
 
+	if policy.Effect == "" {
+		policy.Effect = models.PolicyEffectAllow
+	}
+	if err := auth.ValidatePolicy(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	if !h.policyVersionMatches(w, ifVersion) {
+		return
+	}
+
 	// Store updated policy
 	if err := h.metadataStore.StorePolicy(&policy); err != nil {
 		h.logger.Error().Err(err).Msg("failed to update policy")
@@ -147,20 +282,35 @@ func (h *Handler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update policy in engine (remove and re-add)
-	h.policyEngine.RemovePolicy(policyID)
-	h.policyEngine.AddPolicy(&policy)
+	// Replace the policy in the engine atomically
+	version := h.policyEngine.PutPolicy(&policy)
 
 	h.logger.Info().Str("policyId", policyID).Msg("policy updated")
 
+	w.Header().Set("X-Policy-Version", strconv.FormatUint(version, 10))
 	h.writeJSON(w, http.StatusOK, policy)
 }
 
 // DeletePolicy deletes a policy
 func (h *Handler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	if h.policiesReadOnly(w) {
+		return
+	}
+
+	ifVersion, ok := parseIfVersion(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	policyID := vars["id"]
 
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	if !h.policyVersionMatches(w, ifVersion) {
+		return
+	}
+
 	// Delete from database
 	if err := h.metadataStore.DeletePolicy(policyID); err != nil {
 		h.logger.Error().Err(err).Str("policyId", policyID).Msg("failed to delete policy")
@@ -169,26 +319,323 @@ func (h *Handler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove from engine
-	h.policyEngine.RemovePolicy(policyID)
+	version := h.policyEngine.RemovePolicy(policyID)
 
 	h.logger.Info().Str("policyId", policyID).Msg("policy deleted")
 
+	w.Header().Set("X-Policy-Version", strconv.FormatUint(version, 10))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// parseIfVersion parses the optional ifVersion parameter, the policy
+// version a change is based on. It reports false after answering a
+// malformed value.
+func parseIfVersion(w http.ResponseWriter, r *http.Request) (*uint64, bool) {
+	value := r.URL.Query().Get("ifVersion")
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ifVersion", http.StatusBadRequest)
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// policyVersionMatches reports whether policies are still at the version a
+// change is based on, answering with a conflict if not. Callers hold
+// policyMu.
+func (h *Handler) policyVersionMatches(w http.ResponseWriter, ifVersion *uint64) bool {
+	if ifVersion != nil && *ifVersion != h.policyEngine.Version() {
+		http.Error(w, auth.ErrPolicyVersionConflict.Error(), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// ReloadPolicies reloads policies from the policy file or the database
+func (h *Handler) ReloadPolicies(w http.ResponseWriter, r *http.Request) {
+	if h.policyReloader == nil {
+		http.Error(w, "Policy reloading is not configured", http.StatusNotFound)
+		return
+	}
+
+	version, err := h.policyReloader.Reload()
+	if errors.Is(err, auth.ErrPolicyVersionConflict) {
+		http.Error(w, "Policies changed while reloading, retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to reload policies")
+		http.Error(w, "Failed to reload policies: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"source":  h.policyReloader.Source().String(),
+		"version": version,
+		"count":   len(h.policyEngine.ListPolicies()),
+	})
+}
+
+// maxPolicyDocumentSize limits the size of imported policy documents
+const maxPolicyDocumentSize = 10 << 20
+
+// defaultSimulationWindow and defaultSimulationLimit select the audit log
+// entries a simulation replays when not given
+const (
+	defaultSimulationWindow = 24 * time.Hour
+	defaultSimulationLimit  = 1000
+)
+
+// PolicyImportResponse reports the changes an import made or, in a dry run,
+// would make
+type PolicyImportResponse struct {
+	Plan    *auth.PolicyPlan `json:"plan"`
+	DryRun  bool             `json:"dryRun"`
+	Applied bool             `json:"applied"` // False in a dry run or if nothing changed
+	Version uint64           `json:"version"` // Policy version after the import
+}
+
+// ExportPolicies writes all policies as a policy document, in YAML unless
+// format=json is given
+func (h *Handler) ExportPolicies(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = auth.PolicyFormatYAML
+	}
+	if format != auth.PolicyFormatYAML && format != auth.PolicyFormatJSON {
+		http.Error(w, "Invalid format, expected yaml or json", http.StatusBadRequest)
+		return
+	}
+
+	policies, err := h.currentPolicies()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list policies")
+		http.Error(w, "Failed to list policies", http.StatusInternalServerError)
+		return
+	}
+	data, err := auth.MarshalPolicies(policies, format)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to export policies")
+		http.Error(w, "Failed to export policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+format)
+	w.Header().Set("X-Policy-Version", strconv.FormatUint(h.policyEngine.Version(), 10))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// ImportPolicies replaces all policies with those of a policy document.
+// Policies missing from the document are deleted. With dryRun=true the
+// changes are only reported; with ifVersion the import is refused if
+// policies changed since that version.
+func (h *Handler) ImportPolicies(w http.ResponseWriter, r *http.Request) {
+	if h.policiesReadOnly(w) {
+		return
+	}
+
+	dryRun, err := parseBoolQuery(r, "dryRun")
+	if err != nil {
+		http.Error(w, "Invalid dryRun", http.StatusBadRequest)
+		return
+	}
+	ifVersion, ok := parseIfVersion(w, r)
+	if !ok {
+		return
+	}
+
+	desired, err := h.readPolicyDocument(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+
+	if !h.policyVersionMatches(w, ifVersion) {
+		return
+	}
+	version := h.policyEngine.Version()
+
+	current, err := h.metadataStore.ListPolicies()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list policies")
+		http.Error(w, "Failed to list policies", http.StatusInternalServerError)
+		return
+	}
+	plan := auth.PlanPolicies(current, desired)
+	response := PolicyImportResponse{Plan: plan, DryRun: dryRun, Version: version}
+	if dryRun || !plan.HasChanges() {
+		h.writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if err := h.applyPolicies(current, desired, plan); err != nil {
+		h.logger.Error().Err(err).Msg("failed to import policies")
+		http.Error(w, "Failed to import policies", http.StatusInternalServerError)
+		return
+	}
+	response.Applied = true
+	response.Version = h.policyEngine.ReplacePolicies(desired)
+
+	h.logger.Info().
+		Int("created", len(plan.Created)).
+		Int("updated", len(plan.Updated)).
+		Int("deleted", len(plan.Deleted)).
+		Uint64("version", response.Version).
+		Msg("policies imported")
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// applyPolicies stores the changes of an import plan in one transaction,
+// so a failed import leaves the stored policies unchanged
+func (h *Handler) applyPolicies(current, desired []*models.Policy, plan *auth.PolicyPlan) error {
+	createdAt := make(map[string]time.Time, len(current))
+	for _, policy := range current {
+		createdAt[policy.ID] = policy.CreatedAt
+	}
+	changed := make(map[string]bool, len(plan.Created)+len(plan.Updated))
+	for _, id := range plan.Created {
+		changed[id] = true
+	}
+	for _, update := range plan.Updated {
+		changed[update.ID] = true
+	}
+
+	now := time.Now()
+	var stored []*models.Policy
+	for _, policy := range desired {
+		if created, ok := createdAt[policy.ID]; ok {
+			policy.CreatedAt = created
+		} else {
+			policy.CreatedAt = now
+		}
+		if changed[policy.ID] {
+			stored = append(stored, policy)
+		}
+	}
+	return h.metadataStore.ApplyPolicyChanges(stored, plan.Deleted)
+}
+
+// SimulatePolicies replays recent audited authorization decisions against
+// the policies of a policy document and reports the decisions that would
+// change. since (a duration, default 24h) and limit (default 1000) select
+// the entries replayed, newest first.
+func (h *Handler) SimulatePolicies(w http.ResponseWriter, r *http.Request) {
+	window := defaultSimulationWindow
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid since, expected a duration such as 24h", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+	limit := defaultSimulationLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	proposed, err := h.readPolicyDocument(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.auditLogger.QueryAuditLogs(&storage.AuditLogQuery{StartTime: time.Now().Add(-window), Limit: limit})
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to retrieve audit logs")
+		http.Error(w, "Failed to retrieve audit logs", http.StatusInternalServerError)
+		return
+	}
+
+	simulation := auth.SimulatePolicies(h.policyEngine, proposed, page.Logs, auth.NewMetadataObjectResolver(h.metadataStore))
+	h.writeJSON(w, http.StatusOK, simulation)
+}
+
+// readPolicyDocument reads and validates the policy document in a request
+// body. Its format is the format query parameter or, if not given, YAML
+// for YAML content types and JSON otherwise.
+func (h *Handler) readPolicyDocument(w http.ResponseWriter, r *http.Request) ([]*models.Policy, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = auth.PolicyFormatJSON
+		if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+			format = auth.PolicyFormatYAML
+		}
+	}
+	if format != auth.PolicyFormatYAML && format != auth.PolicyFormatJSON {
+		return nil, errors.New("invalid format, expected yaml or json")
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolicyDocumentSize))
+	if err != nil {
+		return nil, errors.New("failed to read policy document")
+	}
+	policies, err := auth.ParsePolicies(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.ValidatePolicies(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// currentPolicies returns the policies the policy API manages
+func (h *Handler) currentPolicies() ([]*models.Policy, error) {
+	if h.policiesFromFile {
+		return h.policyEngine.ListPolicies(), nil
+	}
+	return h.metadataStore.ListPolicies()
+}
+
+// parseBoolQuery parses an optional boolean query parameter
+func parseBoolQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// policiesReadOnly rejects policy changes when policies are managed in a
+// policy file
+func (h *Handler) policiesReadOnly(w http.ResponseWriter) bool {
+	if !h.policiesFromFile {
+		return false
+	}
+	http.Error(w, "Policies are managed in the policy file", http.StatusConflict)
+	return true
+}
+
 // === Authorization ===
 
 // AuthorizationRequest represents an authorization check request
 type AuthorizationRequest struct {
-	UserID   string       `json:"userId"`
-	Resource string       `json:"resource"`
+	UserID   string        `json:"userId"`
+	Resource string        `json:"resource"`
 	Action   models.Action `json:"action"`
+	SourceIP string        `json:"sourceIp,omitempty"` // Evaluated by sourceIp policy conditions
 }
 
 // AuthorizationResponse represents an authorization check response
 type AuthorizationResponse struct {
-	Allowed bool   `json:"allowed"`
-	Reason  string `json:"reason,omitempty"`
+	Allowed        bool               `json:"allowed"`
+	Reason         string             `json:"reason,omitempty"`
+	DecidingPolicy string             `json:"decidingPolicy,omitempty"`
+	Trace          []auth.PolicyMatch `json:"trace"` // Policies that matched, in evaluation order
 }
 
 // CheckAuthorization checks if a user is authorized to perform an action
@@ -199,72 +646,439 @@ func (h *Handler) CheckAuthorization(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now, we simulate a user lookup
-	// In production, this would look up the actual user from the database
-	user := &models.User{
-		ID:       req.UserID,
-		Username: req.UserID,
-		Roles:    []string{}, // Would be loaded from database
-		Groups:   []string{},
+	user, ok := h.lookupUser(w, req.UserID)
+	if !ok {
+		return
 	}
 
-	// Check authorization
-	allowed, err := h.policyEngine.Authorize(user, req.Resource, req.Action)
+	// Check authorization, evaluating conditions as if requested now
+	decision := h.policyEngine.Explain(user, req.Resource, req.Action, h.queryContext(req.SourceIP))
 
 	response := AuthorizationResponse{
-		Allowed: allowed,
+		Allowed:        decision.Allowed,
+		Reason:         decision.Reason,
+		DecidingPolicy: decision.DecidingPolicy,
+		Trace:          decision.Trace,
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// WhoCan lists the directory users allowed an action on a resource
+func (h *Handler) WhoCan(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, "resource is required", http.StatusBadRequest)
+		return
+	}
+	action := models.Action(r.URL.Query().Get("action"))
+	if action == "" {
+		action = models.ActionWrite
+	}
+	if !isAction(action) {
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
 	}
 
+	users, err := h.directoryUsers()
 	if err != nil {
-		response.Reason = err.Error()
+		h.logger.Error().Err(err).Msg("failed to list users")
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
 	}
 
-	h.writeJSON(w, http.StatusOK, response)
+	reqCtx := h.queryContext(r.URL.Query().Get("sourceIp"))
+	h.writeJSON(w, http.StatusOK, h.policyEngine.WhoCan(users, resource, action, reqCtx))
+}
+
+// lookupUser looks a user up in the directory, writing an error response
+// if the user is unknown
+func (h *Handler) lookupUser(w http.ResponseWriter, name string) (*models.User, bool) {
+	if name == "" {
+		http.Error(w, "userId is required", http.StatusBadRequest)
+		return nil, false
+	}
+	if h.userDirectory == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	user, err := h.userDirectory.LookupUser(name)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("user", name).Msg("failed to look up user")
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+// directoryUsers lists the users of the directory
+func (h *Handler) directoryUsers() ([]*models.User, error) {
+	if h.userDirectory == nil {
+		return []*models.User{}, nil
+	}
+	users, err := h.userDirectory.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	return users, nil
+}
+
+// queryContext returns the context authorization queries evaluate policy
+// conditions against: a request from sourceIP made now
+func (h *Handler) queryContext(sourceIP string) *auth.RequestContext {
+	return &auth.RequestContext{
+		SourceIP: net.ParseIP(sourceIP),
+		Time:     time.Now(),
+		Headers:  http.Header{},
+		Resolver: auth.NewMetadataObjectResolver(h.metadataStore),
+	}
+}
+
+// isAction reports whether action is a known action
+func isAction(action models.Action) bool {
+	for _, known := range auth.Actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// === User Directory ===
+
+// ListUsers lists the users of the user directory
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.directoryUsers()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list users")
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// GetUser retrieves a directory user by ID or username
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.lookupUser(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+// GetUserAccess lists what a directory user may do on a resource
+func (h *Handler) GetUserAccess(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, "resource is required", http.StatusBadRequest)
+		return
+	}
+	user, ok := h.lookupUser(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	reqCtx := h.queryContext(r.URL.Query().Get("sourceIp"))
+	h.writeJSON(w, http.StatusOK, h.policyEngine.EffectiveAccess(user, resource, reqCtx))
 }
 
 // === Audit Logs ===
 
-// ListAuditLogs retrieves audit logs with optional filtering
+// ListAuditLogs retrieves a page of audit logs with optional filtering.
+// Pass the returned nextCursor as cursor to fetch the following page.
 func (h *Handler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	userID := r.URL.Query().Get("userId")
-	resource := r.URL.Query().Get("resource")
-	limitStr := r.URL.Query().Get("limit")
-
-	limit := 100 // default
-	if limitStr != "" {
+	query := &storage.AuditLogQuery{
+		UserID:   r.URL.Query().Get("userId"),
+		Resource: r.URL.Query().Get("resource"),
+		Cursor:   r.URL.Query().Get("cursor"),
+		Limit:    100, // default
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
+			query.Limit = parsed
 		}
 	}
 
 	// Parse time range
-	var startTime, endTime time.Time
 	if startStr := r.URL.Query().Get("startTime"); startStr != "" {
 		if t, err := time.Parse(time.RFC3339, startStr); err == nil {
-			startTime = t
+			query.StartTime = t
 		}
 	}
 	if endStr := r.URL.Query().Get("endTime"); endStr != "" {
 		if t, err := time.Parse(time.RFC3339, endStr); err == nil {
-			endTime = t
+			query.EndTime = t
 		}
 	}
 
 	// Retrieve logs
-	logs, err := h.auditLogger.GetAuditLogs(userID, resource, startTime, endTime, limit)
+	page, err := h.auditLogger.QueryAuditLogs(query)
+	if errors.Is(err, storage.ErrInvalidAuditCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to retrieve audit logs")
 		http.Error(w, "Failed to retrieve audit logs", http.StatusInternalServerError)
 		return
 	}
 
+	response := map[string]interface{}{
+		"logs":  page.Logs,
+		"count": len(page.Logs),
+	}
+	if page.NextCursor != "" {
+		response["nextCursor"] = page.NextCursor
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// VerifyAuditLogs checks the audit log hash chain and its checkpoints for
+// edited, missing and inserted entries
+func (h *Handler) VerifyAuditLogs(w http.ResponseWriter, r *http.Request) {
+	verification, err := h.auditLogger.VerifyChain()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to verify audit logs")
+		http.Error(w, "Failed to verify audit logs", http.StatusInternalServerError)
+		return
+	}
+	if !verification.Valid {
+		h.logger.Warn().Int("issues", verification.IssueCount).Msg("audit log verification failed")
+	}
+
+	h.writeJSON(w, http.StatusOK, verification)
+}
+
+// === Service Accounts and API Tokens ===
+
+// CreateTokenResponse returns a new token and its secret, which is shown only once
+type CreateTokenResponse struct {
+	Token  *models.APIToken `json:"token"`
+	Secret string           `json:"secret"`
+}
+
+// CreateServiceAccount creates a service account
+func (h *Handler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	var account models.ServiceAccount
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if caller, ok := auth.GetUserFromContext(r.Context()); ok {
+		account.CreatedBy = caller.Username
+	}
+
+	if err := h.apiTokens.CreateServiceAccount(&account); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info().Str("serviceAccountId", account.ID).Str("name", account.Name).Msg("service account created")
+
+	h.writeJSON(w, http.StatusCreated, account)
+}
+
+// ListServiceAccounts lists all service accounts
+func (h *Handler) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.metadataStore.ListServiceAccounts()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list service accounts")
+		http.Error(w, "Failed to list service accounts", http.StatusInternalServerError)
+		return
+	}
+
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{
-		"logs":  logs,
-		"count": len(logs),
+		"serviceAccounts": accounts,
+		"count":           len(accounts),
 	})
 }
 
+// GetServiceAccount retrieves a service account by ID
+func (h *Handler) GetServiceAccount(w http.ResponseWriter, r *http.Request) {
+	account, err := h.metadataStore.GetServiceAccount(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Service account not found", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, account)
+}
+
+// DeleteServiceAccount deletes a service account and its tokens
+func (h *Handler) DeleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["id"]
+	if _, err := h.metadataStore.GetServiceAccount(accountID); err != nil {
+		http.Error(w, "Service account not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.apiTokens.DeleteServiceAccount(accountID); err != nil {
+		h.logger.Error().Err(err).Str("serviceAccountId", accountID).Msg("failed to delete service account")
+		http.Error(w, "Failed to delete service account", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("serviceAccountId", accountID).Msg("service account deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateServiceAccountToken creates an API token for a service account
+func (h *Handler) CreateServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	if !h.canMintTokens(w, r) {
+		return
+	}
+	accountID := mux.Vars(r)["id"]
+	if _, err := h.metadataStore.GetServiceAccount(accountID); err != nil {
+		http.Error(w, "Service account not found", http.StatusNotFound)
+		return
+	}
+
+	var req auth.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, secret, err := h.apiTokens.CreateServiceAccountToken(accountID, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, CreateTokenResponse{Token: tokenView(token), Secret: secret})
+}
+
+// ListServiceAccountTokens lists the API tokens of a service account
+func (h *Handler) ListServiceAccountTokens(w http.ResponseWriter, r *http.Request) {
+	h.listTokens(w, mux.Vars(r)["id"])
+}
+
+// CreateToken creates a personal access token for the calling user
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	if !h.canMintTokens(w, r) {
+		return
+	}
+	caller, _ := auth.GetUserFromContext(r.Context())
+
+	var req auth.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, secret, err := h.apiTokens.CreateUserToken(caller, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, CreateTokenResponse{Token: tokenView(token), Secret: secret})
+}
+
+// ListTokens lists the calling user's tokens. Admins may list another
+// user's tokens with the userId parameter.
+func (h *Handler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	caller, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	ownerID := caller.ID
+	if userID := r.URL.Query().Get("userId"); userID != "" && userID != caller.ID {
+		if !h.isAdmin(caller) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		ownerID = userID
+	}
+	h.listTokens(w, ownerID)
+}
+
+// RevokeToken revokes a token owned by the calling user, or any token for admins
+func (h *Handler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	caller, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	tokenID := mux.Vars(r)["id"]
+	token, err := h.apiTokens.GetToken(tokenID)
+	if err != nil || (token.UserID != caller.ID && !h.isAdmin(caller)) {
+		// Tokens of other users are reported as missing rather than forbidden
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.apiTokens.RevokeToken(tokenID); err != nil {
+		h.logger.Error().Err(err).Str("tokenId", tokenID).Msg("failed to revoke API token")
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listTokens(w http.ResponseWriter, ownerID string) {
+	tokens, err := h.apiTokens.ListTokens(ownerID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to list API tokens")
+		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]*models.APIToken, len(tokens))
+	for i, token := range tokens {
+		views[i] = tokenView(token)
+	}
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tokens": views,
+		"count":  len(views),
+	})
+}
+
+// canMintTokens refuses token creation to anonymous callers and to callers
+// authenticated by an API token, so a scoped token cannot mint a wider one
+func (h *Handler) canMintTokens(w http.ResponseWriter, r *http.Request) bool {
+	caller, ok := auth.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+	if auth.IsAPITokenUser(caller) {
+		http.Error(w, "API tokens cannot create API tokens", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) isAdmin(user *models.User) bool {
+	allowed, _ := h.policyEngine.Authorize(user, "rbac", models.ActionAdmin, nil)
+	return allowed
+}
+
+// tokenView returns a copy of a token without its secret hash
+func tokenView(token *models.APIToken) *models.APIToken {
+	view := *token
+	view.SecretHash = ""
+	return &view
+}
+
 // === Helper Functions ===
 
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
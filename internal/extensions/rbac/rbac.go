@@ -3,7 +3,7 @@ package rbac
 import (
 	"context"
 	"fmt"
-	"path/filepath"
+	"time"
 
 	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
@@ -18,21 +18,45 @@ type RBACExtension struct {
 	config          *Config
 	logger          log.Logger
 	storeController zotStorage.StoreController
-	metadataStore   *storage.MetadataStore
+	metadataStore   storage.MetadataStore
 	jwtValidator    *auth.JWTValidator
 	policyEngine    *auth.PolicyEngine
 	auditLogger     *auth.AuditLogger
+	apiTokens       *auth.APITokenManager
+	retention       *auth.AuditRetention
+	policyReloader  *auth.PolicyReloader
 	middleware      *auth.Middleware
 	handler         *Handler
+	stopRetention   context.CancelFunc
+	stopReload      context.CancelFunc
+	stopCheckpoints context.CancelFunc
+	stopCRLReload   context.CancelFunc
 }
 
 // Config holds the RBAC extension configuration
 type Config struct {
-	Enabled            bool           `json:"enabled" mapstructure:"enabled"`
-	Keycloak           KeycloakConfig `json:"keycloak" mapstructure:"keycloak"`
-	AuditLogging       bool           `json:"auditLogging" mapstructure:"auditLogging"`
-	AllowAnonymousGet  bool           `json:"allowAnonymousGet" mapstructure:"allowAnonymousGet"`
-	MetadataDBPath     string         `json:"metadataDBPath" mapstructure:"metadataDBPath"`
+	Enabled            bool                    `json:"enabled" mapstructure:"enabled"`
+	Keycloak           KeycloakConfig          `json:"keycloak" mapstructure:"keycloak"`
+	OIDC               *auth.OIDCConfig        `json:"oidc" mapstructure:"oidc"` // Any OpenID Connect provider; takes precedence over Keycloak
+	AuditLogging       bool                    `json:"auditLogging" mapstructure:"auditLogging"`
+	AllowAnonymousGet  bool                    `json:"allowAnonymousGet" mapstructure:"allowAnonymousGet"`
+	AuditRetention     *auth.RetentionConfig   `json:"auditRetention" mapstructure:"auditRetention"`
+	ClientCertificates *auth.CertificateConfig `json:"clientCertificates" mapstructure:"clientCertificates"` // TLS client certificates verified against http.tls.cacert
+
+	// PolicyFile, if set, is a YAML or JSON policy file that replaces the
+	// stored policies; the policy API is then read-only
+	PolicyFile           string        `json:"policyFile" mapstructure:"policyFile"`
+	PolicyReloadInterval time.Duration `json:"policyReloadInterval" mapstructure:"policyReloadInterval"` // How often policies are reloaded (0 disables)
+
+	// UserDirectory adds sources to the directory authorization queries look
+	// users up in, besides users seen authenticating and service accounts
+	UserDirectory *auth.UserDirectoryConfig `json:"userDirectory" mapstructure:"userDirectory"`
+
+	// TrustedProxies are IPs and CIDR ranges of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers give the client IP
+	TrustedProxies []string                   `json:"trustedProxies" mapstructure:"trustedProxies"`
+	AuditIntegrity *auth.AuditIntegrityConfig `json:"auditIntegrity" mapstructure:"auditIntegrity"` // Signed checkpoints of the audit hash chain
+	AuditSinks     []auth.AuditSinkConfig     `json:"auditSinks" mapstructure:"auditSinks"`         // External destinations audit entries are streamed to
 }
 
 // KeycloakConfig holds Keycloak-specific configuration
@@ -59,6 +83,11 @@ func (e *RBACExtension) IsEnabled(cfg *config.Config) bool {
 	return e.config != nil && e.config.Enabled
 }
 
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *RBACExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
 // Setup initializes the extension
 func (e *RBACExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
 	e.logger = logger
@@ -66,48 +95,86 @@ func (e *RBACExtension) Setup(cfg *config.Config, storeController zotStorage.Sto
 
 	// Load extension-specific configuration with defaults
 	e.config = &Config{
-		Enabled:           false, // Disabled by default
-		AuditLogging:      true,
-		AllowAnonymousGet: false,
+		Enabled:              false, // Disabled by default
+		AuditLogging:         true,
+		AllowAnonymousGet:    false,
+		AuditRetention:       auth.DefaultRetentionConfig(),
+		AuditIntegrity:       auth.DefaultAuditIntegrityConfig(),
+		PolicyReloadInterval: 30 * time.Second,
 		Keycloak: KeycloakConfig{
 			URL:   "http://localhost:8081",  // Default Keycloak URL
 			Realm: "zot-artifact-store",     // Default realm
 		},
 	}
 
-	// Set metadata DB path
-	if cfg.Storage.RootDirectory != "" {
-		e.config.MetadataDBPath = filepath.Join(cfg.Storage.RootDirectory, "metadata.db")
-	} else {
-		e.config.MetadataDBPath = "/tmp/zot-artifacts/metadata.db"
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
 	}
 
-	// Initialize metadata store (shared with S3 API extension)
-	metadataStore, err := storage.NewMetadataStore(e.config.MetadataDBPath)
-	if err != nil {
-		return fmt.Errorf("failed to initialize metadata store: %w", err)
+	// Initialize JWT validator for the configured OIDC provider, or Keycloak
+	if e.config.OIDC != nil {
+		validator, err := auth.NewOIDCValidator(e.config.OIDC)
+		if err != nil {
+			return fmt.Errorf("failed to initialize OIDC validator: %w", err)
+		}
+		e.jwtValidator = validator
+	} else {
+		e.jwtValidator = auth.NewJWTValidator(e.config.Keycloak.URL, e.config.Keycloak.Realm)
 	}
-	e.metadataStore = metadataStore
-
-	// Initialize JWT validator for Keycloak
-	e.jwtValidator = auth.NewJWTValidator(e.config.Keycloak.URL, e.config.Keycloak.Realm)
 
 	// Initialize policy engine
 	e.policyEngine = auth.NewPolicyEngine(e.config.AllowAnonymousGet)
 
-	// Load existing policies from database
-	if err := e.loadPolicies(); err != nil {
-		logger.Warn().Err(err).Msg("failed to load policies from database")
+	// Load policies from the policy file or the database, and keep them current
+	if err := e.setupPolicies(); err != nil {
+		return err
+	}
+
+	proxies, err := auth.NewTrustedProxies(e.config.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to parse trusted proxies: %w", err)
 	}
 
 	// Initialize audit logger
-	e.auditLogger = auth.NewAuditLogger(metadataStore, logger, e.config.AuditLogging)
+	e.auditLogger = auth.NewAuditLogger(e.metadataStore, logger, e.config.AuditLogging)
+	e.auditLogger.SetTrustedProxies(proxies)
+	if err := e.setupAuditIntegrity(); err != nil {
+		return err
+	}
+	if e.config.AuditRetention.Enabled {
+		if err := e.setupRetention(); err != nil {
+			return err
+		}
+	}
+
+	// Initialize API tokens for service accounts and personal access
+	e.apiTokens = auth.NewAPITokenManager(e.metadataStore, logger)
 
 	// Initialize auth middleware
 	e.middleware = auth.NewMiddleware(e.jwtValidator, e.policyEngine, logger, e.config.Enabled)
+	e.middleware.SetAPITokenManager(e.apiTokens)
+	e.middleware.SetObjectResolver(auth.NewMetadataObjectResolver(e.metadataStore))
+	e.middleware.SetAuditLogger(e.auditLogger)
+	e.middleware.SetTrustedProxies(proxies)
+	knownUsers := auth.NewKnownUsers(e.metadataStore, logger)
+	e.middleware.SetKnownUsers(knownUsers)
+	if e.config.ClientCertificates != nil {
+		certificates, err := auth.NewCertificateAuthenticator(e.config.ClientCertificates, logger)
+		if err != nil {
+			return fmt.Errorf("failed to initialize client certificate authentication: %w", err)
+		}
+		e.middleware.SetCertificateAuthenticator(certificates)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		e.stopCRLReload = cancel
+		go certificates.RunCRLReload(ctx)
+	}
 
 	// Initialize RBAC API handler
-	e.handler = NewHandler(e.policyEngine, e.auditLogger, e.metadataStore, logger)
+	e.handler = NewHandler(e.policyEngine, e.auditLogger, e.apiTokens, e.metadataStore, logger)
+	e.handler.SetPolicyReloader(e.policyReloader, e.config.PolicyFile != "")
+	e.handler.SetUserDirectory(e.newUserDirectory(knownUsers))
 
 	e.logger.Info().
 		Str("keycloakURL", e.config.Keycloak.URL).
@@ -132,14 +199,33 @@ func (e *RBACExtension) RegisterRoutes(router *mux.Router, storeController zotSt
 	return nil
 }
 
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *RBACExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions()
+}
+
 // Shutdown performs cleanup
 func (e *RBACExtension) Shutdown(ctx context.Context) error {
 	e.logger.Info().Msg("RBAC extension shutdown")
 
-	if e.metadataStore != nil {
-		if err := e.metadataStore.Close(); err != nil {
-			e.logger.Error().Err(err).Msg("failed to close metadata store")
-			return err
+	if e.stopRetention != nil {
+		e.stopRetention()
+	}
+	if e.stopReload != nil {
+		e.stopReload()
+	}
+	if e.stopCheckpoints != nil {
+		e.stopCheckpoints()
+	}
+	if e.stopCRLReload != nil {
+		e.stopCRLReload()
+	}
+	if e.auditLogger != nil {
+		if err := e.auditLogger.Close(); err != nil {
+			e.logger.Error().Err(err).Msg("failed to close audit sinks")
 		}
 	}
 
@@ -156,17 +242,98 @@ func (e *RBACExtension) GetAuditLogger() *auth.AuditLogger {
 	return e.auditLogger
 }
 
-// loadPolicies loads existing policies from the database into the policy engine
-func (e *RBACExtension) loadPolicies() error {
-	policies, err := e.metadataStore.ListPolicies()
+// newUserDirectory creates the directory of users seen authenticating,
+// service accounts and the configured sources
+func (e *RBACExtension) newUserDirectory(knownUsers *auth.KnownUsers) *auth.UserDirectory {
+	sources := []auth.UserSource{knownUsers, auth.NewServiceAccountSource(e.metadataStore)}
+	if e.config.UserDirectory != nil && e.config.UserDirectory.LDIFFile != "" {
+		sources = append(sources, auth.NewLDIFUserSource(e.config.UserDirectory.LDIFFile, e.config.UserDirectory.GroupRoles))
+	}
+	return auth.NewUserDirectory(sources...)
+}
+
+// setupPolicies loads policies from the policy file or the database and,
+// if an interval is configured, reloads them periodically. Periodic reloads
+// pick up edits of the policy file and changes made by other replicas
+// sharing the database.
+func (e *RBACExtension) setupPolicies() error {
+	source := auth.NewStorePolicySource(e.metadataStore)
+	if e.config.PolicyFile != "" {
+		source = auth.NewFilePolicySource(e.config.PolicyFile)
+	}
+	e.policyReloader = auth.NewPolicyReloader(e.policyEngine, source, e.config.PolicyReloadInterval, e.logger)
+
+	if _, err := e.policyReloader.Reload(); err != nil {
+		// A broken policy file must not start the server without policies
+		if e.config.PolicyFile != "" {
+			return fmt.Errorf("failed to load policy file: %w", err)
+		}
+		e.logger.Warn().Err(err).Msg("failed to load policies from database")
+	}
+
+	if e.config.PolicyReloadInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		e.stopReload = cancel
+		go e.policyReloader.Run(ctx)
+	}
+	return nil
+}
+
+// setupRetention creates the audit archive backend and starts audit log retention
+func (e *RBACExtension) setupRetention() error {
+	config := e.config.AuditRetention
+
+	var backend storage.Backend
+	if config.Archive != nil {
+		if config.Archive.Backend == nil {
+			return fmt.Errorf("audit log archival requires a backend configuration")
+		}
+		var err error
+		backend, err = storage.NewBackend(config.Archive.Backend)
+		if err != nil {
+			return fmt.Errorf("failed to initialize audit archive backend: %w", err)
+		}
+	}
+
+	retention, err := auth.NewAuditRetention(config, e.metadataStore, backend, e.logger)
 	if err != nil {
-		return fmt.Errorf("failed to list policies: %w", err)
+		return fmt.Errorf("failed to initialize audit log retention: %w", err)
 	}
+	retention.SetAuditLogger(e.auditLogger)
+	e.retention = retention
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopRetention = cancel
+	go retention.Run(ctx)
+
+	return nil
+}
 
-	for _, policy := range policies {
-		e.policyEngine.AddPolicy(policy)
+// setupAuditIntegrity configures audit checkpoints and sinks, and starts
+// periodic checkpoints
+func (e *RBACExtension) setupAuditIntegrity() error {
+	if e.config.AuditIntegrity != nil {
+		if err := e.auditLogger.ConfigureCheckpoints(e.config.AuditIntegrity); err != nil {
+			return fmt.Errorf("failed to configure audit checkpoints: %w", err)
+		}
+		if e.config.AuditIntegrity.CheckpointInterval > 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+			e.stopCheckpoints = cancel
+			go e.auditLogger.RunCheckpoints(ctx)
+		}
 	}
 
-	e.logger.Info().Int("count", len(policies)).Msg("loaded policies from database")
+	for i := range e.config.AuditSinks {
+		config := &e.config.AuditSinks[i]
+		sink, err := auth.NewAuditSink(config)
+		if err != nil {
+			return fmt.Errorf("failed to initialize audit sink: %w", err)
+		}
+		if err := e.auditLogger.AddSink(sink, config); err != nil {
+			sink.Close()
+			return fmt.Errorf("failed to initialize audit sink: %w", err)
+		}
+		e.logger.Info().Str("sink", sink.String()).Str("format", config.Format).Msg("audit sink enabled")
+	}
 	return nil
 }
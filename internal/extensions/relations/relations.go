@@ -0,0 +1,85 @@
+package relations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	graphPkg "github.com/candlekeep/zot-artifact-store/internal/graph"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/api/config"
+	"zotregistry.io/zot/pkg/log"
+	zotStorage "zotregistry.io/zot/pkg/storage"
+)
+
+// RelationsExtension provides typed relations between artifacts and walks of their graph
+type RelationsExtension struct {
+	logger        log.Logger
+	metadataStore storage.MetadataStore
+	handler       *graphPkg.Handler
+}
+
+// NewRelationsExtension creates a new relations extension
+func NewRelationsExtension() *RelationsExtension {
+	return &RelationsExtension{}
+}
+
+// Name returns the extension name
+func (e *RelationsExtension) Name() string {
+	return "relations"
+}
+
+// IsEnabled checks if the extension is enabled
+func (e *RelationsExtension) IsEnabled(cfg *config.Config) bool {
+	// Relations live in the metadata store, so they are always available
+	return true
+}
+
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *RelationsExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
+// Setup initializes the extension
+func (e *RelationsExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
+	e.logger = logger
+
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
+	}
+
+	e.handler = graphPkg.NewHandler(e.metadataStore, logger)
+
+	e.logger.Info().Msg("Relations extension initialized")
+
+	return nil
+}
+
+// RegisterRoutes registers relations routes
+func (e *RelationsExtension) RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error {
+	if e.handler == nil {
+		return fmt.Errorf("relations handler not initialized")
+	}
+
+	e.handler.RegisterRoutes(router)
+	e.logger.Info().Msg("Relations routes registered")
+
+	return nil
+}
+
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *RelationsExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions()
+}
+
+// Shutdown performs cleanup
+func (e *RelationsExtension) Shutdown(ctx context.Context) error {
+	e.logger.Info().Msg("Relations extension shutdown")
+
+	return nil
+}
@@ -3,9 +3,10 @@ package s3api
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 
 	"github.com/candlekeep/zot-artifact-store/internal/api/s3"
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"github.com/gorilla/mux"
 	"zotregistry.io/zot/pkg/api/config"
@@ -18,9 +19,11 @@ type S3APIExtension struct {
 	config          *Config
 	logger          log.Logger
 	storeController zotStorage.StoreController
-	metadataStore   *storage.MetadataStore
+	metadataStore   storage.MetadataStore
 	handler         *s3.Handler
 	dataDir         string
+	replicator      *replication.Replicator
+	stopReplication context.CancelFunc
 }
 
 // Config holds the S3 API extension configuration
@@ -31,7 +34,8 @@ type Config struct {
 	EnableMultipart    bool   `json:"enableMultipart" mapstructure:"enableMultipart"`
 	EnablePresignedURL bool   `json:"enablePresignedURL" mapstructure:"enablePresignedURL"`
 	DataDir            string `json:"dataDir" mapstructure:"dataDir"`
-	MetadataDBPath     string `json:"metadataDBPath" mapstructure:"metadataDBPath"`
+
+	Replication *replication.Config `json:"replication" mapstructure:"replication"`
 }
 
 // NewS3APIExtension creates a new S3 API extension
@@ -50,6 +54,11 @@ func (e *S3APIExtension) IsEnabled(cfg *config.Config) bool {
 	return true
 }
 
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *S3APIExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
 // Setup initializes the extension
 func (e *S3APIExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
 	e.logger = logger
@@ -63,33 +72,33 @@ func (e *S3APIExtension) Setup(cfg *config.Config, storeController zotStorage.St
 		EnableMultipart:    true,
 		EnablePresignedURL: true,
 		DataDir:            cfg.Storage.RootDirectory,
-		MetadataDBPath:     filepath.Join(cfg.Storage.RootDirectory, "metadata.db"),
+		Replication:        replication.DefaultConfig(),
 	}
 
 	// If no root directory is set, use a default
 	if e.config.DataDir == "" {
 		e.config.DataDir = "/tmp/zot-artifacts"
 	}
-	if e.config.MetadataDBPath == "" {
-		e.config.MetadataDBPath = "/tmp/zot-artifacts/metadata.db"
-	}
 
 	e.dataDir = e.config.DataDir
 
-	// Initialize metadata store
-	metadataStore, err := storage.NewMetadataStore(e.config.MetadataDBPath)
-	if err != nil {
-		return fmt.Errorf("failed to initialize metadata store: %w", err)
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
 	}
-	e.metadataStore = metadataStore
 
 	// Create S3 API handler
-	e.handler = s3.NewHandler(metadataStore, e.dataDir, logger)
+	e.handler = s3.NewHandler(e.metadataStore, e.dataDir, logger)
 	// TODO: Integrate with Zot storage controller when needed
 
+	if e.config.Replication.Enabled {
+		if err := e.setupReplication(); err != nil {
+			return err
+		}
+	}
+
 	e.logger.Info().
 		Str("dataDir", e.config.DataDir).
-		Str("metadataDB", e.config.MetadataDBPath).
 		Msg("S3 API extension initialized")
 
 	return nil
@@ -102,21 +111,73 @@ func (e *S3APIExtension) RegisterRoutes(router *mux.Router, storeController zotS
 	}
 
 	e.handler.RegisterRoutes(router)
+	if e.replicator != nil {
+		replication.NewHandler(e.replicator, e.logger).RegisterRoutes(router)
+	}
 	e.logger.Info().Msg("S3 API routes registered")
 
 	return nil
 }
 
+// ChangeNotifier returns the replicator that queues replication of changed
+// objects, or nil if replication is disabled
+func (e *S3APIExtension) ChangeNotifier() replication.ChangeNotifier {
+	if e.replicator == nil {
+		return nil
+	}
+	return e.replicator
+}
+
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *S3APIExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	permissions := e.handler.RoutePermissions()
+	if e.replicator != nil {
+		permissions = append(permissions, replication.NewHandler(e.replicator, e.logger).RoutePermissions()...)
+	}
+	return permissions
+}
+
 // Shutdown performs cleanup
 func (e *S3APIExtension) Shutdown(ctx context.Context) error {
 	e.logger.Info().Msg("S3 API extension shutdown")
 
-	if e.metadataStore != nil {
-		if err := e.metadataStore.Close(); err != nil {
-			e.logger.Error().Err(err).Msg("failed to close metadata store")
+	if e.stopReplication != nil {
+		e.stopReplication()
+	}
+
+	return nil
+}
+
+// setupReplication creates replication targets and starts the replicator
+func (e *S3APIExtension) setupReplication() error {
+	targets := make([]replication.Target, 0, len(e.config.Replication.Targets))
+	for _, targetConfig := range e.config.Replication.Targets {
+		target, err := replication.NewTarget(targetConfig)
+		if err != nil {
 			return err
 		}
+		targets = append(targets, target)
 	}
 
+	// The S3 handler stores objects as files under the data directory
+	source, err := storage.NewFileSystemBackend(e.dataDir, false)
+	if err != nil {
+		return fmt.Errorf("failed to initialize replication source: %w", err)
+	}
+
+	replicator, err := replication.NewReplicator(e.config.Replication, e.metadataStore, source, targets, e.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize replication: %w", err)
+	}
+	e.replicator = replicator
+	e.handler.SetChangeNotifier(replicator)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopReplication = cancel
+	go replicator.Run(ctx)
+
 	return nil
 }
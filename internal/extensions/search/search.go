@@ -0,0 +1,85 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	searchPkg "github.com/candlekeep/zot-artifact-store/internal/search"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/api/config"
+	"zotregistry.io/zot/pkg/log"
+	zotStorage "zotregistry.io/zot/pkg/storage"
+)
+
+// SearchExtension provides structured and full-text artifact search
+type SearchExtension struct {
+	logger        log.Logger
+	metadataStore storage.MetadataStore
+	handler       *searchPkg.Handler
+}
+
+// NewSearchExtension creates a new search extension
+func NewSearchExtension() *SearchExtension {
+	return &SearchExtension{}
+}
+
+// Name returns the extension name
+func (e *SearchExtension) Name() string {
+	return "search"
+}
+
+// IsEnabled checks if the extension is enabled
+func (e *SearchExtension) IsEnabled(cfg *config.Config) bool {
+	// Search only reads the metadata store, so it is always available
+	return true
+}
+
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *SearchExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
+// Setup initializes the extension
+func (e *SearchExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
+	e.logger = logger
+
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
+	}
+
+	e.handler = searchPkg.NewHandler(searchPkg.NewEngine(e.metadataStore), logger)
+
+	e.logger.Info().Msg("Search extension initialized")
+
+	return nil
+}
+
+// RegisterRoutes registers search routes
+func (e *SearchExtension) RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error {
+	if e.handler == nil {
+		return fmt.Errorf("search handler not initialized")
+	}
+
+	e.handler.RegisterRoutes(router)
+	e.logger.Info().Msg("Search routes registered")
+
+	return nil
+}
+
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *SearchExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions()
+}
+
+// Shutdown performs cleanup
+func (e *SearchExtension) Shutdown(ctx context.Context) error {
+	e.logger.Info().Msg("Search extension shutdown")
+
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
 	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	scPkg "github.com/candlekeep/zot-artifact-store/internal/supplychain"
 	"github.com/google/uuid"
@@ -17,13 +19,14 @@ import (
 
 // Handler handles supply chain security API requests
 type Handler struct {
-	metadataStore *storage.MetadataStore
+	metadataStore storage.MetadataStore
 	signer        *scPkg.Signer
 	logger        log.Logger
+	notifier      replication.ChangeNotifier
 }
 
 // NewHandler creates a new supply chain handler
-func NewHandler(metadataStore *storage.MetadataStore, signer *scPkg.Signer, logger log.Logger) *Handler {
+func NewHandler(metadataStore storage.MetadataStore, signer *scPkg.Signer, logger log.Logger) *Handler {
 	return &Handler{
 		metadataStore: metadataStore,
 		signer:        signer,
@@ -31,6 +34,12 @@ func NewHandler(metadataStore *storage.MetadataStore, signer *scPkg.Signer, logg
 	}
 }
 
+// SetChangeNotifier sets the notifier called after records are stored, e.g.
+// to queue replication of the artifact with its records
+func (h *Handler) SetChangeNotifier(notifier replication.ChangeNotifier) {
+	h.notifier = notifier
+}
+
 // RegisterRoutes registers supply chain security API routes
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// Signature operations
@@ -45,6 +54,29 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// Attestation operations
 	router.HandleFunc("/supplychain/attestations/{bucket}/{key:.*}", h.AddAttestation).Methods("POST")
 	router.HandleFunc("/supplychain/attestations/{bucket}/{key:.*}", h.GetAttestations).Methods("GET")
+
+	// Records archived when their artifact was deleted or overwritten
+	router.HandleFunc("/supplychain/tombstones/{bucket}/{key:.*}", h.GetTombstones).Methods("GET")
+
+	// Import of replicated records
+	router.HandleFunc("/supplychain/import/{bucket}/{key:.*}", h.ImportSupplyChain).Methods("POST")
+}
+
+// RoutePermissions declares the action and resource each route requires
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	const artifact = "{bucket}/{key}"
+	return []auth.RoutePermission{
+		{Method: "POST", Path: "/supplychain/sign/{bucket}/{key:.*}", Action: models.ActionSign, Resource: artifact},
+		{Method: "GET", Path: "/supplychain/signatures/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "POST", Path: "/supplychain/verify/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "POST", Path: "/supplychain/sbom/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: artifact},
+		{Method: "GET", Path: "/supplychain/sbom/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "POST", Path: "/supplychain/attestations/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: artifact},
+		{Method: "GET", Path: "/supplychain/attestations/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "GET", Path: "/supplychain/tombstones/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		// Imported documents may carry signatures
+		{Method: "POST", Path: "/supplychain/import/{bucket}/{key:.*}", Action: models.ActionSign, Resource: artifact},
+	}
 }
 
 // === Signature Operations ===
@@ -99,6 +131,7 @@ func (h *Handler) SignArtifact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info().Str("artifactId", artifactID).Str("signatureId", signature.ID).Msg("artifact signed")
+	h.notifyChanged(bucket, key)
 
 	h.writeJSON(w, http.StatusCreated, signature)
 }
@@ -238,6 +271,7 @@ func (h *Handler) AttachSBOM(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info().Str("artifactId", artifactID).Str("sbomId", sbom.ID).Str("format", string(req.Format)).Msg("SBOM attached")
+	h.notifyChanged(bucket, key)
 
 	// Return SBOM without content in response
 	response := map[string]interface{}{
@@ -325,6 +359,7 @@ func (h *Handler) AddAttestation(w http.ResponseWriter, r *http.Request) {
 		Str("attestationId", attestation.ID).
 		Str("type", string(req.Type)).
 		Msg("attestation added")
+	h.notifyChanged(bucket, key)
 
 	h.writeJSON(w, http.StatusCreated, attestation)
 }
@@ -350,6 +385,101 @@ func (h *Handler) GetAttestations(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// === Tombstone Operations ===
+
+// GetTombstones lists the archived supply-chain records of an artifact
+func (h *Handler) GetTombstones(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	key := vars["key"]
+	artifactID := bucket + "/" + key
+
+	tombstones, err := h.metadataStore.ListSupplyChainTombstones(artifactID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("artifactId", artifactID).Msg("failed to list tombstones")
+		http.Error(w, "Failed to list tombstones", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"artifactId": artifactID,
+		"tombstones": tombstones,
+		"count":      len(tombstones),
+	})
+}
+
+// === Import Operations ===
+
+// ImportSupplyChainRequest carries supply-chain records copied from another server
+type ImportSupplyChainRequest struct {
+	Signatures   []*models.Signature   `json:"signatures"`
+	SBOM         *models.SBOM          `json:"sbom"`
+	Attestations []*models.Attestation `json:"attestations"`
+}
+
+// ImportSupplyChain stores replicated records for an artifact, keeping their IDs
+// so that repeated imports overwrite rather than duplicate
+func (h *Handler) ImportSupplyChain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	key := vars["key"]
+	artifactID := bucket + "/" + key
+
+	var req ImportSupplyChainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate artifact exists
+	if _, err := h.metadataStore.GetArtifact(bucket, key); err != nil {
+		http.Error(w, "Artifact not found", http.StatusNotFound)
+		return
+	}
+
+	for _, signature := range req.Signatures {
+		signature.ArtifactID = artifactID
+		if err := h.metadataStore.StoreSignature(signature); err != nil {
+			h.logger.Error().Err(err).Msg("failed to import signature")
+			http.Error(w, "Failed to import signature", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.SBOM != nil {
+		req.SBOM.ArtifactID = artifactID
+		if err := h.metadataStore.StoreSBOM(req.SBOM); err != nil {
+			h.logger.Error().Err(err).Msg("failed to import SBOM")
+			http.Error(w, "Failed to import SBOM", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, attestation := range req.Attestations {
+		attestation.ArtifactID = artifactID
+		if err := h.metadataStore.StoreAttestation(attestation); err != nil {
+			h.logger.Error().Err(err).Msg("failed to import attestation")
+			http.Error(w, "Failed to import attestation", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.logger.Info().
+		Str("artifactId", artifactID).
+		Int("signatures", len(req.Signatures)).
+		Int("attestations", len(req.Attestations)).
+		Bool("sbom", req.SBOM != nil).
+		Msg("supply chain records imported")
+	h.notifyChanged(bucket, key)
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"artifactId":   artifactID,
+		"signatures":   len(req.Signatures),
+		"attestations": len(req.Attestations),
+		"sbom":         req.SBOM != nil,
+	})
+}
+
 // === Helper Functions ===
 
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -358,6 +488,15 @@ func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{})
 	json.NewEncoder(w).Encode(data)
 }
 
+func (h *Handler) notifyChanged(bucket, key string) {
+	if h.notifier == nil {
+		return
+	}
+	if err := h.notifier.ObjectChanged(bucket, key); err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("failed to record supply chain change")
+	}
+}
+
 func countVerified(results []*models.VerificationResult) int {
 	count := 0
 	for _, r := range results {
@@ -3,8 +3,9 @@ package supplychain
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
 	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	scPkg "github.com/candlekeep/zot-artifact-store/internal/supplychain"
 	"github.com/gorilla/mux"
@@ -18,19 +19,18 @@ type SupplyChainExtension struct {
 	config          *Config
 	logger          log.Logger
 	storeController zotStorage.StoreController
-	metadataStore   *storage.MetadataStore
+	metadataStore   storage.MetadataStore
 	signer          *scPkg.Signer
 	handler         *Handler
 }
 
 // Config holds the supply chain security extension configuration
 type Config struct {
-	Enabled        bool           `json:"enabled" mapstructure:"enabled"`
-	Signing        SigningConfig  `json:"signing" mapstructure:"signing"`
-	SBOM           SBOMConfig     `json:"sbom" mapstructure:"sbom"`
-	Attestation    AttestConfig   `json:"attestation" mapstructure:"attestation"`
-	MetadataDBPath string         `json:"metadataDBPath" mapstructure:"metadataDBPath"`
-	PrivateKeyPath string         `json:"privateKeyPath" mapstructure:"privateKeyPath"`
+	Enabled        bool          `json:"enabled" mapstructure:"enabled"`
+	Signing        SigningConfig `json:"signing" mapstructure:"signing"`
+	SBOM           SBOMConfig    `json:"sbom" mapstructure:"sbom"`
+	Attestation    AttestConfig  `json:"attestation" mapstructure:"attestation"`
+	PrivateKeyPath string        `json:"privateKeyPath" mapstructure:"privateKeyPath"`
 }
 
 // SigningConfig holds artifact signing configuration
@@ -69,6 +69,11 @@ func (e *SupplyChainExtension) IsEnabled(cfg *config.Config) bool {
 	return e.config != nil && e.config.Enabled
 }
 
+// SetMetadataStore sets the shared metadata store used by the extension
+func (e *SupplyChainExtension) SetMetadataStore(store storage.MetadataStore) {
+	e.metadataStore = store
+}
+
 // Setup initializes the extension
 func (e *SupplyChainExtension) Setup(cfg *config.Config, storeController zotStorage.StoreController, logger log.Logger) error {
 	e.logger = logger
@@ -93,20 +98,11 @@ func (e *SupplyChainExtension) Setup(cfg *config.Config, storeController zotStor
 		},
 	}
 
-	// Set metadata DB path
-	if cfg.Storage.RootDirectory != "" {
-		e.config.MetadataDBPath = filepath.Join(cfg.Storage.RootDirectory, "metadata.db")
-	} else {
-		e.config.MetadataDBPath = "/tmp/zot-artifacts/metadata.db"
+	// The metadata store is shared by all extensions and injected by the registry
+	if e.metadataStore == nil {
+		return fmt.Errorf("metadata store not configured")
 	}
 
-	// Initialize metadata store (shared with S3 API and RBAC extensions)
-	metadataStore, err := storage.NewMetadataStore(e.config.MetadataDBPath)
-	if err != nil {
-		return fmt.Errorf("failed to initialize metadata store: %w", err)
-	}
-	e.metadataStore = metadataStore
-
 	// Initialize signer (generate default key pair if not configured)
 	// In production, keys would be loaded from secure storage
 	signer, _, _, err := scPkg.GenerateKeyPair(2048)
@@ -128,6 +124,14 @@ func (e *SupplyChainExtension) Setup(cfg *config.Config, storeController zotStor
 	return nil
 }
 
+// SetChangeNotifier sets the notifier told about new supply-chain records,
+// so they are replicated with their artifact
+func (e *SupplyChainExtension) SetChangeNotifier(notifier replication.ChangeNotifier) {
+	if e.handler != nil {
+		e.handler.SetChangeNotifier(notifier)
+	}
+}
+
 // RegisterRoutes registers supply chain security routes
 func (e *SupplyChainExtension) RegisterRoutes(router *mux.Router, storeController zotStorage.StoreController) error {
 	if e.handler == nil {
@@ -140,17 +144,18 @@ func (e *SupplyChainExtension) RegisterRoutes(router *mux.Router, storeControlle
 	return nil
 }
 
+// RoutePermissions declares the permissions of the routes added by RegisterRoutes
+func (e *SupplyChainExtension) RoutePermissions() []auth.RoutePermission {
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.RoutePermissions()
+}
+
 // Shutdown performs cleanup
 func (e *SupplyChainExtension) Shutdown(ctx context.Context) error {
 	e.logger.Info().Msg("Supply chain security extension shutdown")
 
-	if e.metadataStore != nil {
-		if err := e.metadataStore.Close(); err != nil {
-			e.logger.Error().Err(err).Msg("failed to close metadata store")
-			return err
-		}
-	}
-
 	return nil
 }
 
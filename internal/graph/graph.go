@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+const (
+	// DefaultDepth is how far a walk goes when no depth is given
+	DefaultDepth = 10
+	// MaxDepth is the deepest walk allowed
+	MaxDepth = 50
+)
+
+// Direction selects which way a walk follows relations
+type Direction string
+
+const (
+	// Ancestors follows relations from artifacts to the targets they depend on
+	Ancestors Direction = "ancestors"
+	// Descendants follows relations from targets to the artifacts depending on them
+	Descendants Direction = "descendants"
+)
+
+// Node is an artifact reached by a walk
+type Node struct {
+	ArtifactID string `json:"artifactId"`
+	Depth      int    `json:"depth"` // Relations between the root and this artifact
+}
+
+// Graph is the part of the relationship graph reachable from a root artifact
+type Graph struct {
+	Root      string                     `json:"root"`
+	Direction Direction                  `json:"direction"`
+	Nodes     []Node                     `json:"nodes"`     // Reached artifacts, nearest first, without the root
+	Relations []*models.ArtifactRelation `json:"relations"` // Relations followed
+	Truncated bool                       `json:"truncated"` // More relations exist beyond the depth limit
+}
+
+// Walk collects the artifacts reachable from root in direction, up to depth
+// relations away. If types is not empty, only relations of those types are
+// followed. Each artifact is visited once, so cycles terminate.
+func Walk(store storage.MetadataStore, root string, direction Direction, depth int, types []models.RelationType) (*Graph, error) {
+	if direction != Ancestors && direction != Descendants {
+		return nil, fmt.Errorf("unknown walk direction %q", direction)
+	}
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	if depth > MaxDepth {
+		depth = MaxDepth
+	}
+
+	follow := func(t models.RelationType) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, allowed := range types {
+			if t == allowed {
+				return true
+			}
+		}
+		return false
+	}
+
+	graph := &Graph{Root: root, Direction: direction, Nodes: []Node{}, Relations: []*models.ArtifactRelation{}}
+	visited := map[string]bool{root: true}
+	frontier := []string{root}
+
+	for level := 1; len(frontier) > 0; level++ {
+		var next []string
+		for _, id := range frontier {
+			relations, err := neighbours(store, id, direction)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, relation := range relations {
+				if !follow(relation.Type) {
+					continue
+				}
+				if level > depth {
+					graph.Truncated = true
+					break
+				}
+
+				graph.Relations = append(graph.Relations, relation)
+				other := relation.TargetID
+				if direction == Descendants {
+					other = relation.ArtifactID
+				}
+				if !visited[other] {
+					visited[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		if level > depth {
+			break
+		}
+
+		sort.Strings(next)
+		for _, id := range next {
+			graph.Nodes = append(graph.Nodes, Node{ArtifactID: id, Depth: level})
+		}
+		frontier = next
+	}
+	return graph, nil
+}
+
+func neighbours(store storage.MetadataStore, id string, direction Direction) ([]*models.ArtifactRelation, error) {
+	if direction == Ancestors {
+		relations, err := store.ListRelationsFrom(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list relations of %s: %w", id, err)
+		}
+		return relations, nil
+	}
+
+	relations, err := store.ListRelationsTo(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dependents of %s: %w", id, err)
+	}
+	return relations, nil
+}
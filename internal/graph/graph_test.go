@@ -0,0 +1,112 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/graph"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func TestWalk(t *testing.T) {
+	// Given: A layer derived from a binary built from sources, with debug symbols
+	store := test.NewTestMetadataStore(t)
+	for _, key := range []string{"src.tar", "app.bin", "app.debug", "layer.tar"} {
+		test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "builds", Key: key}), "store artifact")
+	}
+	relate(t, store, "builds/app.bin", "builds/src.tar", models.RelationBuiltFrom)
+	relate(t, store, "builds/app.debug", "builds/app.bin", models.RelationAccompanies)
+	relate(t, store, "builds/layer.tar", "builds/app.bin", models.RelationDerivedFrom)
+
+	t.Run("Ancestors follow relations to their targets", func(t *testing.T) {
+		// When: Walking up from the layer
+		g, err := graph.Walk(store, "builds/layer.tar", graph.Ancestors, 0, nil)
+
+		// Then: The binary and its sources are reached in order
+		test.AssertNoError(t, err, "walk")
+		test.AssertEqual(t, 2, len(g.Nodes), "ancestors")
+		test.AssertEqual(t, "builds/app.bin", g.Nodes[0].ArtifactID, "parent")
+		test.AssertEqual(t, "builds/src.tar", g.Nodes[1].ArtifactID, "grandparent")
+		test.AssertEqual(t, 2, g.Nodes[1].Depth, "grandparent depth")
+		test.AssertTrue(t, !g.Truncated, "complete walk")
+	})
+
+	t.Run("Descendants are limited by depth and type", func(t *testing.T) {
+		// When: Walking down from the sources one level, then following only derived-from
+		shallow, err := graph.Walk(store, "builds/src.tar", graph.Descendants, 1, nil)
+		test.AssertNoError(t, err, "shallow walk")
+		filtered, err := graph.Walk(store, "builds/app.bin", graph.Descendants, 0, []models.RelationType{models.RelationDerivedFrom})
+		test.AssertNoError(t, err, "filtered walk")
+
+		// Then: The shallow walk stops at the binary and the filtered walk skips the debug symbols
+		test.AssertEqual(t, 1, len(shallow.Nodes), "shallow descendants")
+		test.AssertTrue(t, shallow.Truncated, "shallow walk is truncated")
+		test.AssertEqual(t, 1, len(filtered.Nodes), "filtered descendants")
+		test.AssertEqual(t, "builds/layer.tar", filtered.Nodes[0].ArtifactID, "derived layer")
+	})
+
+	t.Run("Handler adds, lists and removes relations", func(t *testing.T) {
+		// Given: The relations handler
+		router := mux.NewRouter()
+		graph.NewHandler(store, test.NewTestLogger(t)).RegisterRoutes(router)
+
+		// When: Linking the debug symbols to the sources as well, then listing the sources' relations
+		body, _ := json.Marshal(graph.AddRelationRequest{TargetID: "builds/src.tar", Type: models.RelationDependsOn})
+		added := httptest.NewRecorder()
+		router.ServeHTTP(added, httptest.NewRequest("POST", "/relations/builds/app.debug", bytes.NewReader(body)))
+		listed := httptest.NewRecorder()
+		router.ServeHTTP(listed, httptest.NewRequest("GET", "/relations/builds/src.tar", nil))
+
+		// Then: The sources have two dependents
+		test.AssertEqual(t, http.StatusCreated, added.Code, "add status")
+		var relations struct {
+			Dependents []*models.ArtifactRelation `json:"dependents"`
+		}
+		test.AssertNoError(t, json.NewDecoder(listed.Body).Decode(&relations), "decode relations")
+		test.AssertEqual(t, 2, len(relations.Dependents), "dependents")
+
+		// When: Removing the new relation twice
+		removed := httptest.NewRecorder()
+		router.ServeHTTP(removed, httptest.NewRequest("DELETE", "/relations/builds/app.debug?target=builds/src.tar&type=depends-on", nil))
+		missing := httptest.NewRecorder()
+		router.ServeHTTP(missing, httptest.NewRequest("DELETE", "/relations/builds/app.debug?target=builds/src.tar&type=depends-on", nil))
+
+		// Then: The second removal finds nothing
+		test.AssertEqual(t, http.StatusNoContent, removed.Code, "remove status")
+		test.AssertEqual(t, http.StatusNotFound, missing.Code, "missing relation status")
+	})
+
+	t.Run("Handler rejects invalid relations", func(t *testing.T) {
+		// Given: The relations handler
+		router := mux.NewRouter()
+		graph.NewHandler(store, test.NewTestLogger(t)).RegisterRoutes(router)
+
+		// When: Linking to a missing artifact and with an unknown type
+		for _, tc := range []struct {
+			req    graph.AddRelationRequest
+			status int
+		}{
+			{graph.AddRelationRequest{TargetID: "builds/missing", Type: models.RelationBuiltFrom}, http.StatusNotFound},
+			{graph.AddRelationRequest{TargetID: "builds/src.tar", Type: "copied-from"}, http.StatusBadRequest},
+		} {
+			body, _ := json.Marshal(tc.req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/relations/builds/app.bin", bytes.NewReader(body)))
+
+			// Then: The request is rejected
+			test.AssertEqual(t, tc.status, w.Code, "status for "+tc.req.TargetID)
+		}
+	})
+}
+
+func relate(t *testing.T, store storage.MetadataStore, artifactID, targetID string, relationType models.RelationType) {
+	t.Helper()
+	relation := &models.ArtifactRelation{ArtifactID: artifactID, TargetID: targetID, Type: relationType}
+	test.AssertNoError(t, store.AddArtifactRelation(relation), "add relation")
+}
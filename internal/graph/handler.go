@@ -0,0 +1,205 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Handler serves artifact relation and graph endpoints
+type Handler struct {
+	store  storage.MetadataStore
+	logger log.Logger
+}
+
+// NewHandler creates a new relation handler
+func NewHandler(store storage.MetadataStore, logger log.Logger) *Handler {
+	return &Handler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// RegisterRoutes registers relation and graph routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Relations of one artifact
+	router.HandleFunc("/relations/{bucket}/{key:.*}", h.AddRelation).Methods("POST")
+	router.HandleFunc("/relations/{bucket}/{key:.*}", h.GetRelations).Methods("GET")
+	router.HandleFunc("/relations/{bucket}/{key:.*}", h.RemoveRelation).Methods("DELETE")
+
+	// Graph walks
+	router.HandleFunc("/graph/ancestors/{bucket}/{key:.*}", h.walkHandler(Ancestors)).Methods("GET")
+	router.HandleFunc("/graph/descendants/{bucket}/{key:.*}", h.walkHandler(Descendants)).Methods("GET")
+}
+
+// RoutePermissions declares the action and resource each route requires.
+// Relations are part of the artifact's metadata, so changing them is a write.
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	const artifact = "{bucket}/{key}"
+	return []auth.RoutePermission{
+		{Method: "POST", Path: "/relations/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: artifact},
+		{Method: "GET", Path: "/relations/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "DELETE", Path: "/relations/{bucket}/{key:.*}", Action: models.ActionWrite, Resource: artifact},
+		{Method: "GET", Path: "/graph/ancestors/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+		{Method: "GET", Path: "/graph/descendants/{bucket}/{key:.*}", Action: models.ActionRead, Resource: artifact},
+	}
+}
+
+// AddRelationRequest links an artifact to a target it depends on
+type AddRelationRequest struct {
+	TargetID  string              `json:"targetId"` // bucket/key
+	Type      models.RelationType `json:"type"`
+	CreatedBy string              `json:"createdBy,omitempty"`
+}
+
+// AddRelation links the artifact in the path to a target
+func (h *Handler) AddRelation(w http.ResponseWriter, r *http.Request) {
+	artifactID := pathArtifactID(r)
+
+	var req AddRelationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Type.IsValid() {
+		http.Error(w, "Unknown relation type", http.StatusBadRequest)
+		return
+	}
+	if req.TargetID == "" || req.TargetID == artifactID {
+		http.Error(w, "A target other than the artifact is required", http.StatusBadRequest)
+		return
+	}
+
+	for _, id := range []string{artifactID, req.TargetID} {
+		bucket, key, _ := strings.Cut(id, "/")
+		if _, err := h.store.GetArtifact(bucket, key); err != nil {
+			http.Error(w, "Artifact not found: "+id, http.StatusNotFound)
+			return
+		}
+	}
+
+	relation := &models.ArtifactRelation{
+		ArtifactID: artifactID,
+		TargetID:   req.TargetID,
+		Type:       req.Type,
+		CreatedBy:  req.CreatedBy,
+	}
+	if err := h.store.AddArtifactRelation(relation); err != nil {
+		h.logger.Error().Err(err).Str("artifact", artifactID).Str("target", req.TargetID).Msg("failed to add relation")
+		http.Error(w, "Failed to add relation", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("artifact", artifactID).Str("target", req.TargetID).Str("type", string(req.Type)).Msg("relation added")
+	h.writeJSON(w, http.StatusCreated, relation)
+}
+
+// GetRelations lists the relations of an artifact to its targets and of its
+// dependents to it
+func (h *Handler) GetRelations(w http.ResponseWriter, r *http.Request) {
+	artifactID := pathArtifactID(r)
+
+	relations, err := h.store.ListRelationsFrom(artifactID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("artifact", artifactID).Msg("failed to list relations")
+		http.Error(w, "Failed to list relations", http.StatusInternalServerError)
+		return
+	}
+	dependents, err := h.store.ListRelationsTo(artifactID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("artifact", artifactID).Msg("failed to list dependents")
+		http.Error(w, "Failed to list relations", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"artifactId": artifactID,
+		"relations":  nonNil(relations),
+		"dependents": nonNil(dependents),
+	})
+}
+
+// RemoveRelation removes the relation selected by the target and type parameters
+func (h *Handler) RemoveRelation(w http.ResponseWriter, r *http.Request) {
+	artifactID := pathArtifactID(r)
+	targetID := r.URL.Query().Get("target")
+	relationType := models.RelationType(r.URL.Query().Get("type"))
+	if targetID == "" || relationType == "" {
+		http.Error(w, "target and type parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	err := h.store.RemoveArtifactRelation(artifactID, targetID, relationType)
+	if errors.Is(err, storage.ErrRelationNotFound) {
+		http.Error(w, "Relation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Str("artifact", artifactID).Str("target", targetID).Msg("failed to remove relation")
+		http.Error(w, "Failed to remove relation", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info().Str("artifact", artifactID).Str("target", targetID).Str("type", string(relationType)).Msg("relation removed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// walkHandler serves the graph reachable from an artifact in direction.
+// depth limits the walk and repeated type parameters select the relations followed.
+func (h *Handler) walkHandler(direction Direction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		depth := DefaultDepth
+		if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+			if parsed, err := strconv.Atoi(depthStr); err == nil && parsed > 0 {
+				depth = parsed
+			}
+		}
+
+		var types []models.RelationType
+		for _, t := range r.URL.Query()["type"] {
+			relationType := models.RelationType(t)
+			if !relationType.IsValid() {
+				http.Error(w, "Unknown relation type", http.StatusBadRequest)
+				return
+			}
+			types = append(types, relationType)
+		}
+
+		graph, err := Walk(h.store, pathArtifactID(r), direction, depth, types)
+		if err != nil {
+			h.logger.Error().Err(err).Str("direction", string(direction)).Msg("failed to walk relations")
+			http.Error(w, "Failed to walk relations", http.StatusInternalServerError)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, graph)
+	}
+}
+
+// === Helper Functions ===
+
+func pathArtifactID(r *http.Request) string {
+	vars := mux.Vars(r)
+	return vars["bucket"] + "/" + vars["key"]
+}
+
+// nonNil encodes empty lists as [] rather than null
+func nonNil(relations []*models.ArtifactRelation) []*models.ArtifactRelation {
+	if relations == nil {
+		return []*models.ArtifactRelation{}
+	}
+	return relations
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -11,14 +11,14 @@ import (
 
 // HealthChecker performs health checks on system components
 type HealthChecker struct {
-	metadataStore *storage.MetadataStore
+	metadataStore storage.MetadataStore
 	logger        log.Logger
 	startTime     time.Time
 	version       string
 }
 
 // NewHealthChecker creates a new health checker
-func NewHealthChecker(metadataStore *storage.MetadataStore, logger log.Logger, version string) *HealthChecker {
+func NewHealthChecker(metadataStore storage.MetadataStore, logger log.Logger, version string) *HealthChecker {
 	return &HealthChecker{
 		metadataStore: metadataStore,
 		logger:        logger,
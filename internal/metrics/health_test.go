@@ -19,7 +19,7 @@ func TestHealthChecker(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		logger := log.NewLogger("debug", "")
@@ -42,7 +42,7 @@ func TestHealthChecker(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		logger := log.NewLogger("debug", "")
@@ -28,6 +28,10 @@ type PrometheusCollector struct {
 	authenticationAttempts *prometheus.CounterVec
 	authorizationChecks    *prometheus.CounterVec
 
+	// Storage cache metrics
+	cacheRequests *prometheus.CounterVec
+	cacheSize     *prometheus.GaugeVec
+
 	// System metrics
 	activeConnections prometheus.Gauge
 	totalRequests     *prometheus.CounterVec
@@ -130,6 +134,22 @@ func NewPrometheusCollector() *PrometheusCollector {
 			[]string{"resource", "action", "result"},
 		),
 
+		// Storage cache metrics
+		cacheRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "storage_cache_requests_total",
+				Help: "Total number of storage cache lookups by result (hit or miss)",
+			},
+			[]string{"cache", "operation", "result"},
+		),
+		cacheSize: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "storage_cache_size_bytes",
+				Help: "Total size of data held in the storage cache in bytes",
+			},
+			[]string{"cache"},
+		),
+
 		// System metrics
 		activeConnections: promauto.NewGauge(
 			prometheus.GaugeOpts{
@@ -250,6 +270,21 @@ func (c *PrometheusCollector) RecordAuthorizationCheck(ctx context.Context, reso
 	c.authorizationChecks.WithLabelValues(resource, action, result).Inc()
 }
 
+// RecordCacheHit records a storage cache hit
+func (c *PrometheusCollector) RecordCacheHit(cache, operation string) {
+	c.cacheRequests.WithLabelValues(cache, operation, "hit").Inc()
+}
+
+// RecordCacheMiss records a storage cache miss
+func (c *PrometheusCollector) RecordCacheMiss(cache, operation string) {
+	c.cacheRequests.WithLabelValues(cache, operation, "miss").Inc()
+}
+
+// SetCacheSize records the current size of a storage cache
+func (c *PrometheusCollector) SetCacheSize(cache string, bytes int64) {
+	c.cacheSize.WithLabelValues(cache).Set(float64(bytes))
+}
+
 // RecordHTTPRequest records metrics for HTTP requests
 func (c *PrometheusCollector) RecordHTTPRequest(method, endpoint string, statusCode int) {
 	c.totalRequests.WithLabelValues(method, endpoint, string(rune(statusCode))).Inc()
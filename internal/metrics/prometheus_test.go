@@ -88,6 +88,17 @@ func TestPrometheusCollector(t *testing.T) {
 		test.AssertTrue(t, collector != nil, "collector not nil")
 	})
 
+	t.Run("Record cache hits and misses", func(t *testing.T) {
+		// Given: A Prometheus collector
+		// When: Recording cache lookups and size
+		collector.RecordCacheHit("s3", "read")
+		collector.RecordCacheMiss("s3", "read_range")
+		collector.SetCacheSize("s3", 4096)
+
+		// Then: No error occurs
+		test.AssertTrue(t, collector != nil, "collector not nil")
+	})
+
 	t.Run("Active connections tracking", func(t *testing.T) {
 		// Given: A Prometheus collector
 		// When: Incrementing and decrementing active connections
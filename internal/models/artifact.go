@@ -28,6 +28,9 @@ type Artifact struct {
 	// User metadata (custom headers)
 	Metadata    map[string]string `json:"metadata,omitempty"`
 
+	// Object tags (x-amz-tagging header)
+	Tags        map[string]string `json:"tags,omitempty"`
+
 	// Upload tracking
 	UploadID    string    `json:"uploadId,omitempty"`
 	IsMultipart bool      `json:"isMultipart"`
@@ -66,6 +69,7 @@ type MultipartUpload struct {
 	InitiatedAt time.Time         `json:"initiatedAt"`
 	Parts       []MultipartPart   `json:"parts"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
 	ContentType string            `json:"contentType"`
 }
 
@@ -108,4 +112,48 @@ type UploadProgress struct {
 	UploadedSize int64     `json:"uploadedSize"`
 	StartedAt    time.Time `json:"startedAt"`
 	LastActivity time.Time `json:"lastActivity"`
+
+	Status      UploadStatus      `json:"status"`
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	ETag        string            `json:"etag,omitempty"` // Set once the upload completes
+}
+
+// UploadStatus is the state of a resumable upload
+type UploadStatus string
+
+const (
+	UploadStatusInProgress UploadStatus = "in-progress"
+	UploadStatusCompleted  UploadStatus = "completed"
+)
+
+// ArtifactRelation is a typed link from an artifact to a target it depends
+// on, such as a tarball built from a source bundle. Both are artifact IDs
+// (bucket/key).
+type ArtifactRelation struct {
+	ArtifactID string       `json:"artifactId"`
+	TargetID   string       `json:"targetId"`
+	Type       RelationType `json:"type"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	CreatedBy  string       `json:"createdBy,omitempty"`
+}
+
+// RelationType describes how an artifact relates to its target
+type RelationType string
+
+const (
+	RelationBuiltFrom   RelationType = "built-from"   // Built from the target's sources
+	RelationDerivedFrom RelationType = "derived-from" // Derived from the target, such as a layer from a binary
+	RelationAccompanies RelationType = "accompanies"  // Ships with the target, such as its debug symbols
+	RelationDependsOn   RelationType = "depends-on"   // Requires the target at runtime
+)
+
+// IsValid reports whether t is a known relation type
+func (t RelationType) IsValid() bool {
+	switch t {
+	case RelationBuiltFrom, RelationDerivedFrom, RelationAccompanies, RelationDependsOn:
+		return true
+	}
+	return false
 }
@@ -12,6 +12,7 @@ type User struct {
 	Roles    []string          `json:"roles"`
 	Groups   []string          `json:"groups"`
 	Metadata map[string]string `json:"metadata,omitempty"`
+	Scopes   []Permission      `json:"scopes,omitempty"` // Set when authenticated by a scoped API token
 }
 
 // Policy represents an access control policy
@@ -19,11 +20,12 @@ type Policy struct {
 	ID          string            `json:"id"`
 	Name        string            `json:"name"`
 	Description string            `json:"description,omitempty"`
-	Resource    string            `json:"resource"` // bucket name or "bucket/key" pattern
+	Resource    string            `json:"resource"` // bucket name, glob such as "releases/**/*.jar", or "re:" regex
 	Actions     []string          `json:"actions"`  // read, write, delete, list
 	Effect      PolicyEffect      `json:"effect"`   // allow or deny
 	Principals  []string          `json:"principals,omitempty"`
 	Conditions  map[string]string `json:"conditions,omitempty"`
+	Priority    int               `json:"priority,omitempty"` // Higher priorities are evaluated first
 	CreatedAt   time.Time         `json:"createdAt"`
 	UpdatedAt   time.Time         `json:"updatedAt"`
 }
@@ -45,6 +47,7 @@ const (
 	ActionDelete Action = "delete"
 	ActionList   Action = "list"
 	ActionAdmin  Action = "admin"
+	ActionSign   Action = "sign"
 )
 
 // AuditLog represents an access audit log entry
@@ -61,6 +64,27 @@ type AuditLog struct {
 	UserAgent string            `json:"userAgent"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
 	Error     string            `json:"error,omitempty"`
+
+	// Hash chain: each entry carries the hash of its predecessor, so edits,
+	// deletions and insertions can be detected
+	Sequence uint64 `json:"sequence,omitempty"`
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash,omitempty"` // SHA-256 of the entry without this field
+}
+
+// AuditCheckpoint records the audit log hash chain's head at a point in
+// time, signed so it cannot be rewritten along with the entries
+type AuditCheckpoint struct {
+	ID        string    `json:"id"` // Zero-padded sequence, so checkpoints sort in order
+	Sequence  uint64    `json:"sequence"`
+	Hash      string    `json:"hash"`
+	Timestamp time.Time `json:"timestamp"`
+	KeyID     string    `json:"keyId,omitempty"`     // Fingerprint of the signing key; empty if unsigned
+	Signature string    `json:"signature,omitempty"` // Base64 Ed25519 signature
+
+	// Retention marks where retention cut the log: Sequence and Hash are
+	// the newest expired entry, which the oldest retained entry chains to
+	Retention bool `json:"retention,omitempty"`
 }
 
 // Permission represents a user's permission for a resource
@@ -69,6 +93,50 @@ type Permission struct {
 	Actions  []Action `json:"actions"`
 }
 
+// ServiceAccount is a non-human principal, such as a CI robot, that
+// authenticates with API tokens
+type ServiceAccount struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	Groups      []string  `json:"groups,omitempty"`
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// APIToken is a long-lived bearer token for a user or service account.
+// Only a hash of the token's secret is stored.
+type APIToken struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	UserID           string       `json:"userId"` // Owning user or service account ID
+	Username         string       `json:"username"`
+	ServiceAccountID string       `json:"serviceAccountId,omitempty"`
+	Roles            []string     `json:"roles,omitempty"`  // Owner's roles when a personal token was created
+	Groups           []string     `json:"groups,omitempty"` // Owner's groups when a personal token was created
+	Scopes           []Permission `json:"scopes,omitempty"` // Resources and actions the token is limited to (empty is unlimited)
+	SecretHash       string       `json:"secretHash,omitempty"`
+	ExpiresAt        *time.Time   `json:"expiresAt,omitempty"`
+	LastUsedAt       *time.Time   `json:"lastUsedAt,omitempty"`
+	RevokedAt        *time.Time   `json:"revokedAt,omitempty"`
+	CreatedAt        time.Time    `json:"createdAt"`
+}
+
+// KnownUser is a user recorded when they authenticate, so their roles and
+// groups can be looked up while they are not making a request
+type KnownUser struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	Source    string    `json:"source"` // How the user authenticated, e.g. oidc or certificate
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
 // AuthContext holds authentication and authorization context
 type AuthContext struct {
 	User        *User
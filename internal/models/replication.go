@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// ReplicationRule selects objects to replicate to a target
+type ReplicationRule struct {
+	ID                string            `json:"id"`
+	Bucket            string            `json:"bucket"`                      // Source bucket
+	Prefix            string            `json:"prefix,omitempty"`            // Optional key prefix filter
+	Target            string            `json:"target"`                      // Name of the configured target
+	DestinationBucket string            `json:"destinationBucket,omitempty"` // Defaults to the source bucket
+	DeleteMode        ReplicationDelete `json:"deleteMode"`                  // How source deletes are handled
+	Enabled           bool              `json:"enabled"`
+}
+
+// ReplicationDelete defines how deletes are propagated to a target
+type ReplicationDelete string
+
+const (
+	ReplicationDeletePropagate ReplicationDelete = "propagate" // Delete the replica
+	ReplicationDeleteRetain    ReplicationDelete = "retain"    // Keep the replica
+)
+
+// ReplicationOp is the operation a replication task performs
+type ReplicationOp string
+
+const (
+	ReplicationOpPut    ReplicationOp = "put"
+	ReplicationOpDelete ReplicationOp = "delete"
+)
+
+// ReplicationState is the replication state of an object for a rule
+type ReplicationState string
+
+const (
+	ReplicationStatePending   ReplicationState = "pending"
+	ReplicationStateCompleted ReplicationState = "completed"
+	ReplicationStateFailed    ReplicationState = "failed"
+)
+
+// ReplicationTask is a queued replication operation
+type ReplicationTask struct {
+	Sequence      uint64        `json:"sequence"`
+	RuleID        string        `json:"ruleId"`
+	Bucket        string        `json:"bucket"`
+	Key           string        `json:"key"`
+	Op            ReplicationOp `json:"op"`
+	EnqueuedAt    time.Time     `json:"enqueuedAt"`
+	Attempts      int           `json:"attempts"`
+	NextAttemptAt time.Time     `json:"nextAttemptAt"`
+	LastError     string        `json:"lastError,omitempty"`
+}
+
+// ReplicationStatus reports the replication state of an object for a rule
+type ReplicationStatus struct {
+	RuleID       string           `json:"ruleId"`
+	Target       string           `json:"target"`
+	Bucket       string           `json:"bucket"`
+	Key          string           `json:"key"`
+	Op           ReplicationOp    `json:"op"`
+	State        ReplicationState `json:"state"`
+	EnqueuedAt   time.Time        `json:"enqueuedAt"`
+	ReplicatedAt time.Time        `json:"replicatedAt,omitempty"`
+	Attempts     int              `json:"attempts"`
+	LastError    string           `json:"lastError,omitempty"`
+}
+
+// Lag returns how long the object waited (or has been waiting) to replicate
+func (s *ReplicationStatus) Lag(now time.Time) time.Duration {
+	if s.State == ReplicationStateCompleted {
+		return s.ReplicatedAt.Sub(s.EnqueuedAt)
+	}
+	return now.Sub(s.EnqueuedAt)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -70,3 +71,22 @@ type VerificationResult struct {
 	SignedAt    time.Time `json:"signedAt"`
 	Error       string    `json:"error,omitempty"`
 }
+
+// SupplyChainTombstone is a supply-chain record archived when its artifact
+// was deleted or overwritten
+type SupplyChainTombstone struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"` // signature, sbom, attestation
+	ArtifactID string          `json:"artifactId"`
+	Reason     TombstoneReason `json:"reason"`
+	ArchivedAt time.Time       `json:"archivedAt"`
+	Record     json.RawMessage `json:"record"` // The archived record as it was stored
+}
+
+// TombstoneReason records why supply-chain records were archived
+type TombstoneReason string
+
+const (
+	TombstoneReasonDeleted     TombstoneReason = "deleted"
+	TombstoneReasonOverwritten TombstoneReason = "overwritten"
+)
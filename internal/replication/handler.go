@@ -0,0 +1,92 @@
+package replication
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Handler serves replication status endpoints
+type Handler struct {
+	replicator *Replicator
+	logger     log.Logger
+}
+
+// NewHandler creates a new replication status handler
+func NewHandler(replicator *Replicator, logger log.Logger) *Handler {
+	return &Handler{
+		replicator: replicator,
+		logger:     logger,
+	}
+}
+
+// RegisterRoutes registers replication routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/replication/status", h.GetSummary).Methods("GET")
+	router.HandleFunc("/replication/status/{bucket}/{key:.*}", h.GetObjectStatus).Methods("GET")
+}
+
+// RoutePermissions declares the action and resource each route requires
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	return []auth.RoutePermission{
+		{Method: "GET", Path: "/replication/status", Action: models.ActionRead, Resource: "replication"},
+		{Method: "GET", Path: "/replication/status/{bucket}/{key:.*}", Action: models.ActionRead, Resource: "{bucket}/{key}"},
+	}
+}
+
+// GetSummary reports queue depth, lag and target circuit states
+func (h *Handler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.replicator.Summary()
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to get replication summary")
+		http.Error(w, "Failed to get replication status", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, summary)
+}
+
+// GetObjectStatus reports the replication status of an object for each rule
+func (h *Handler) GetObjectStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucket := vars["bucket"]
+	key := vars["key"]
+
+	statuses, err := h.replicator.Status(bucket, key)
+	if err != nil {
+		h.logger.Error().Err(err).Str("bucket", bucket).Str("key", key).Msg("failed to get replication status")
+		http.Error(w, "Failed to get replication status", http.StatusInternalServerError)
+		return
+	}
+
+	if len(statuses) == 0 {
+		http.Error(w, "No replication status for object", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	results := make([]map[string]interface{}, 0, len(statuses))
+	for _, status := range statuses {
+		results = append(results, map[string]interface{}{
+			"status":     status,
+			"lagSeconds": status.Lag(now).Seconds(),
+		})
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"bucket":      bucket,
+		"key":         key,
+		"replication": results,
+	})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,389 @@
+package replication
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/reliability"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Config holds the replication configuration
+type Config struct {
+	Enabled      bool                      `json:"enabled" mapstructure:"enabled"`
+	Rules        []*models.ReplicationRule `json:"rules" mapstructure:"rules"`
+	Targets      []*TargetConfig           `json:"targets" mapstructure:"targets"`
+	PollInterval time.Duration             `json:"pollInterval" mapstructure:"pollInterval"`
+	BatchSize    int                       `json:"batchSize" mapstructure:"batchSize"`
+	MaxAttempts  int                       `json:"maxAttempts" mapstructure:"maxAttempts"` // Attempts before a task is marked failed
+	MaxBackoff   time.Duration             `json:"maxBackoff" mapstructure:"maxBackoff"`
+}
+
+// DefaultConfig returns the default replication configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Enabled:      false,
+		PollInterval: 5 * time.Second,
+		BatchSize:    100,
+		MaxAttempts:  10,
+		MaxBackoff:   10 * time.Minute,
+	}
+}
+
+// ChangeNotifier is told about changes to objects and their supply-chain
+// records. Replicator implements it by queueing replication.
+type ChangeNotifier interface {
+	ObjectChanged(bucket, key string) error
+	ObjectDeleted(bucket, key string) error
+}
+
+// Replicator asynchronously copies objects to replication targets.
+// Changes are recorded in a persistent queue in the metadata store and
+// processed in order by Run.
+type Replicator struct {
+	config        *Config
+	metadataStore storage.MetadataStore
+	source        storage.Backend
+	targets       map[string]Target
+	rules         []*models.ReplicationRule
+	retryer       *reliability.Retryer
+	breakers      *reliability.CircuitBreakerManager
+	logger        log.Logger
+	wake          chan struct{}
+	mu            sync.Mutex // Serializes queue processing
+}
+
+// NewReplicator creates a new replicator. source is the backend holding
+// object data for the artifacts in metadataStore.
+func NewReplicator(config *Config, metadataStore storage.MetadataStore, source storage.Backend, targets []Target, logger log.Logger) (*Replicator, error) {
+	defaults := DefaultConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+
+	targetsByName := make(map[string]Target, len(targets))
+	for _, target := range targets {
+		targetsByName[target.Name()] = target
+	}
+
+	for _, rule := range config.Rules {
+		if rule.ID == "" || rule.Bucket == "" {
+			return nil, fmt.Errorf("replication rule requires an id and a bucket")
+		}
+		if _, ok := targetsByName[rule.Target]; !ok {
+			return nil, fmt.Errorf("replication rule %s references unknown target %q", rule.ID, rule.Target)
+		}
+		if rule.DeleteMode == "" {
+			rule.DeleteMode = models.ReplicationDeleteRetain
+		}
+	}
+
+	return &Replicator{
+		config:        config,
+		metadataStore: metadataStore,
+		source:        source,
+		targets:       targetsByName,
+		rules:         config.Rules,
+		retryer:       reliability.NewRetryer(reliability.DefaultRetryPolicy(), logger),
+		breakers:      reliability.NewCircuitBreakerManager(reliability.DefaultCircuitBreakerConfig(), logger),
+		logger:        logger,
+		wake:          make(chan struct{}, 1),
+	}, nil
+}
+
+// ObjectChanged queues an object for replication after it was written
+func (r *Replicator) ObjectChanged(bucket, key string) error {
+	return r.enqueue(bucket, key, models.ReplicationOpPut)
+}
+
+// ObjectDeleted queues an object deletion for rules that propagate deletes
+func (r *Replicator) ObjectDeleted(bucket, key string) error {
+	return r.enqueue(bucket, key, models.ReplicationOpDelete)
+}
+
+// Run processes the replication queue until the context is cancelled
+func (r *Replicator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	r.logger.Info().Int("rules", len(r.rules)).Int("targets", len(r.targets)).Msg("replication started")
+
+	for {
+		if _, err := r.ProcessPending(ctx); err != nil {
+			r.logger.Error().Err(err).Msg("failed to process replication queue")
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info().Msg("replication stopped")
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// ProcessPending processes up to one batch of due tasks and returns how many
+// completed. The queue is paged past tasks still backing off, so they never
+// hold up due tasks queued behind them. Tasks for an object are applied in
+// order: once a task is deferred, later tasks for the same object wait for it.
+func (r *Replicator) ProcessPending(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	completed, attempted := 0, 0
+	var after uint64
+
+	for attempted < r.config.BatchSize {
+		tasks, err := r.metadataStore.ListReplicationTasks(after, r.config.BatchSize)
+		if err != nil {
+			return completed, err
+		}
+
+		for _, task := range tasks {
+			if ctx.Err() != nil {
+				return completed, ctx.Err()
+			}
+			if attempted == r.config.BatchSize {
+				break
+			}
+			after = task.Sequence
+
+			objectID := task.RuleID + "/" + task.Bucket + "/" + task.Key
+			if blocked[objectID] || task.NextAttemptAt.After(now) {
+				blocked[objectID] = true
+				continue
+			}
+
+			attempted++
+			if err := r.process(ctx, task); err != nil {
+				blocked[objectID] = true
+				if err := r.fail(task, err); err != nil {
+					return completed, err
+				}
+				continue
+			}
+
+			if err := r.metadataStore.CompleteReplicationTask(task); err != nil {
+				return completed, err
+			}
+			completed++
+		}
+
+		if len(tasks) < r.config.BatchSize {
+			break
+		}
+	}
+
+	return completed, nil
+}
+
+// Status returns the replication status of an object across all rules
+func (r *Replicator) Status(bucket, key string) ([]*models.ReplicationStatus, error) {
+	return r.metadataStore.ListReplicationStatus(bucket, key)
+}
+
+// Summary reports queue depth and the lag of the oldest queued task
+func (r *Replicator) Summary() (map[string]interface{}, error) {
+	depth, err := r.metadataStore.CountReplicationTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	oldest, err := r.metadataStore.ListReplicationTasks(0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var lag time.Duration
+	if len(oldest) > 0 {
+		lag = time.Since(oldest[0].EnqueuedAt)
+	}
+
+	breakers := make(map[string]interface{}, len(r.targets))
+	for name := range r.targets {
+		breakers[name] = r.breakers.GetBreaker(name).GetState()
+	}
+
+	return map[string]interface{}{
+		"queueDepth":   depth,
+		"lagSeconds":   lag.Seconds(),
+		"rules":        r.rules,
+		"targetStates": breakers,
+	}, nil
+}
+
+// Helper methods
+
+func (r *Replicator) enqueue(bucket, key string, op models.ReplicationOp) error {
+	queued := false
+	for _, rule := range r.matchingRules(bucket, key) {
+		if op == models.ReplicationOpDelete && rule.DeleteMode != models.ReplicationDeletePropagate {
+			continue
+		}
+
+		task := &models.ReplicationTask{
+			RuleID: rule.ID,
+			Bucket: bucket,
+			Key:    key,
+			Op:     op,
+		}
+		if err := r.metadataStore.EnqueueReplicationTask(task, rule.Target); err != nil {
+			return fmt.Errorf("failed to enqueue replication of %s/%s: %w", bucket, key, err)
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (r *Replicator) matchingRules(bucket, key string) []*models.ReplicationRule {
+	var matched []*models.ReplicationRule
+	for _, rule := range r.rules {
+		if rule.Enabled && rule.Bucket == bucket && strings.HasPrefix(key, rule.Prefix) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+func (r *Replicator) findRule(id string) *models.ReplicationRule {
+	for _, rule := range r.rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+// process replicates a single task through the target's circuit breaker
+func (r *Replicator) process(ctx context.Context, task *models.ReplicationTask) error {
+	rule := r.findRule(task.RuleID)
+	if rule == nil || !rule.Enabled {
+		return errors.NewBadRequest("replication rule " + task.RuleID + " is no longer active")
+	}
+	target := r.targets[rule.Target]
+
+	destBucket := rule.DestinationBucket
+	if destBucket == "" {
+		destBucket = rule.Bucket
+	}
+
+	return r.breakers.Execute(ctx, target.Name(), func(ctx context.Context) error {
+		return r.retryer.Do(ctx, func(ctx context.Context) error {
+			var err error
+			switch task.Op {
+			case models.ReplicationOpDelete:
+				err = target.DeleteObject(ctx, destBucket, task.Key)
+			default:
+				err = r.replicateObject(ctx, target, destBucket, task)
+			}
+			return classifyError(err)
+		})
+	})
+}
+
+// replicateObject copies object data and supply-chain records to a target
+func (r *Replicator) replicateObject(ctx context.Context, target Target, destBucket string, task *models.ReplicationTask) error {
+	artifact, err := r.metadataStore.GetArtifact(task.Bucket, task.Key)
+	if stderrors.Is(err, storage.ErrArtifactNotFound) {
+		// Deleted since it was queued; a delete task follows if configured
+		r.logger.Debug().Str("bucket", task.Bucket).Str("key", task.Key).Msg("replicated object no longer exists, skipping")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	artifactID := task.Bucket + "/" + task.Key
+	record := &Record{Artifact: artifact}
+
+	if record.Signatures, err = r.metadataStore.ListSignaturesForArtifact(artifactID); err != nil {
+		return err
+	}
+	if record.Attestations, err = r.metadataStore.ListAttestationsForArtifact(artifactID); err != nil {
+		return err
+	}
+	if sbom, err := r.metadataStore.GetSBOMForArtifact(artifactID); err == nil {
+		record.SBOM = sbom
+	}
+
+	reader, err := r.source.ReadObject(ctx, task.Bucket, task.Key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return target.PutObject(ctx, destBucket, record, reader)
+}
+
+// fail records a failed attempt, scheduling a retry with exponential backoff
+func (r *Replicator) fail(task *models.ReplicationTask, err error) error {
+	task.Attempts++
+	task.LastError = err.Error()
+
+	if task.Attempts >= r.config.MaxAttempts || !isRetryable(err) {
+		task.NextAttemptAt = time.Time{}
+		r.logger.Error().Err(err).Str("rule", task.RuleID).Str("bucket", task.Bucket).Str("key", task.Key).
+			Int("attempts", task.Attempts).Msg("replication failed permanently")
+	} else {
+		backoff := r.config.PollInterval << uint(task.Attempts)
+		if backoff <= 0 || backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+		task.NextAttemptAt = time.Now().Add(backoff)
+		r.logger.Warn().Err(err).Str("rule", task.RuleID).Str("bucket", task.Bucket).Str("key", task.Key).
+			Int("attempts", task.Attempts).Dur("backoff", backoff).Msg("replication failed, will retry")
+	}
+
+	return r.metadataStore.FailReplicationTask(task)
+}
+
+// classifyError maps target and storage errors onto retryable application errors
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if appErr, ok := err.(*storage.AppError); ok {
+		switch appErr.Code {
+		case "NOT_FOUND", "INVALID_CONFIG", "CHECKSUM_MISMATCH", "INVALID_ARGUMENT", "INVALID_RANGE":
+			return errors.Wrap(err, errors.ErrorCodeBadRequest, appErr.Message)
+		default:
+			return errors.Wrap(err, errors.ErrorCodeStorageUnavailable, appErr.Message)
+		}
+	}
+	if _, ok := err.(*errors.AppError); ok {
+		return err
+	}
+	return errors.Wrap(err, errors.ErrorCodeServiceUnavailable, "replication target error")
+}
+
+// isRetryable reports whether a failed task should be attempted again.
+// An open circuit breaker is always worth retrying later.
+func isRetryable(err error) bool {
+	return errors.IsRetryable(err) || strings.Contains(err.Error(), "circuit breaker is open")
+}
@@ -0,0 +1,311 @@
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/replication"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// flakyTarget fails a fixed number of puts before delegating
+type flakyTarget struct {
+	replication.Target
+	failures atomic.Int64
+}
+
+func (f *flakyTarget) PutObject(ctx context.Context, bucket string, record *replication.Record, data io.Reader) error {
+	if f.failures.Add(-1) >= 0 {
+		return fmt.Errorf("target unavailable")
+	}
+	return f.Target.PutObject(ctx, bucket, record, data)
+}
+
+// unavailableStore fails artifact lookups as a metadata outage would
+type unavailableStore struct {
+	storage.MetadataStore
+}
+
+func (u *unavailableStore) GetArtifact(bucket, key string) (*models.Artifact, error) {
+	return nil, fmt.Errorf("database is locked")
+}
+
+type replicationFixture struct {
+	store       storage.MetadataStore
+	source      *storage.FileSystemBackend
+	destination *storage.FileSystemBackend
+}
+
+func newReplicationFixture(t *testing.T) *replicationFixture {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	store := test.NewTestMetadataStore(t)
+
+	source, err := storage.NewFileSystemBackend(filepath.Join(tmpDir, "source"), false)
+	test.AssertNoError(t, err, "create source backend")
+	destination, err := storage.NewFileSystemBackend(filepath.Join(tmpDir, "destination"), false)
+	test.AssertNoError(t, err, "create destination backend")
+
+	return &replicationFixture{store: store, source: source, destination: destination}
+}
+
+// putObject writes an object and its metadata the way the S3 handler does
+func (f *replicationFixture) putObject(t *testing.T, bucket, key, content string) {
+	t.Helper()
+	_, err := f.source.WriteObject(context.Background(), bucket, key, bytes.NewReader([]byte(content)), int64(len(content)))
+	test.AssertNoError(t, err, "write source object")
+	test.AssertNoError(t, f.store.StoreArtifact(&models.Artifact{
+		Bucket: bucket,
+		Key:    key,
+		Size:   int64(len(content)),
+	}), "store artifact")
+}
+
+func readObject(t *testing.T, backend storage.Backend, bucket, key string) string {
+	t.Helper()
+	reader, err := backend.ReadObject(context.Background(), bucket, key)
+	test.AssertNoError(t, err, "read object")
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	test.AssertNoError(t, err, "read content")
+	return string(data)
+}
+
+func TestReplicator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Replicates object data and supply chain records", func(t *testing.T) {
+		// Given: A rule replicating a bucket to a backend target
+		f := newReplicationFixture(t)
+		target := replication.NewBackendTarget("dr", f.destination)
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules: []*models.ReplicationRule{
+				{ID: "r1", Bucket: "releases", Target: "dr", DestinationBucket: "releases-dr", Enabled: true},
+			},
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		f.putObject(t, "releases", "app/v1.tar.gz", "release payload")
+		test.AssertNoError(t, f.store.StoreSignature(&models.Signature{
+			ID:         "sig-1",
+			ArtifactID: "releases/app/v1.tar.gz",
+		}), "store signature")
+
+		// When: The object changes and the queue is processed
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "app/v1.tar.gz"), "object changed")
+		completed, err := replicator.ProcessPending(ctx)
+
+		// Then: Data and records are present on the target
+		test.AssertNoError(t, err, "process pending")
+		test.AssertEqual(t, 1, completed, "completed tasks")
+		test.AssertEqual(t, "release payload", readObject(t, f.destination, "releases-dr", "app/v1.tar.gz"), "replicated content")
+
+		var record replication.Record
+		sidecar := readObject(t, f.destination, "releases-dr", ".replication/app/v1.tar.gz.json")
+		test.AssertNoError(t, json.Unmarshal([]byte(sidecar), &record), "decode sidecar")
+		test.AssertEqual(t, 1, len(record.Signatures), "replicated signatures")
+
+		// And: The status reports completion
+		status, err := f.store.GetReplicationStatus("r1", "releases", "app/v1.tar.gz")
+		test.AssertNoError(t, err, "get status")
+		test.AssertEqual(t, models.ReplicationStateCompleted, status.State, "replication state")
+		test.AssertEqual(t, "dr", status.Target, "status target")
+	})
+
+	t.Run("Only matching rules enqueue work", func(t *testing.T) {
+		// Given: A rule limited to a prefix
+		f := newReplicationFixture(t)
+		target := replication.NewBackendTarget("dr", f.destination)
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules: []*models.ReplicationRule{
+				{ID: "r1", Bucket: "releases", Prefix: "stable/", Target: "dr", Enabled: true},
+			},
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		// When: Objects inside and outside the prefix change
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "stable/a"), "matching change")
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "nightly/b"), "non-matching change")
+		test.AssertNoError(t, replicator.ObjectChanged("other", "stable/c"), "other bucket change")
+
+		// Then: Only the matching object is queued
+		depth, err := f.store.CountReplicationTasks()
+		test.AssertNoError(t, err, "count tasks")
+		test.AssertEqual(t, 1, depth, "queue depth")
+	})
+
+	t.Run("Deletes follow the rule delete mode", func(t *testing.T) {
+		// Given: One rule propagating deletes and one retaining replicas
+		f := newReplicationFixture(t)
+		target := replication.NewBackendTarget("dr", f.destination)
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules: []*models.ReplicationRule{
+				{ID: "propagate", Bucket: "a", Target: "dr", DeleteMode: models.ReplicationDeletePropagate, Enabled: true},
+				{ID: "retain", Bucket: "b", Target: "dr", Enabled: true},
+			},
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		for _, bucket := range []string{"a", "b"} {
+			f.putObject(t, bucket, "obj", "data")
+			test.AssertNoError(t, replicator.ObjectChanged(bucket, "obj"), "object changed")
+		}
+		_, err = replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "replicate objects")
+
+		// When: Both source objects are deleted
+		for _, bucket := range []string{"a", "b"} {
+			test.AssertNoError(t, f.store.DeleteArtifact(bucket, "obj"), "delete artifact")
+			test.AssertNoError(t, replicator.ObjectDeleted(bucket, "obj"), "object deleted")
+		}
+		_, err = replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "replicate deletes")
+
+		// Then: Only the propagating rule removes its replica
+		_, err = f.destination.ReadObject(ctx, "a", "obj")
+		test.AssertError(t, err, "propagated delete")
+		test.AssertEqual(t, "data", readObject(t, f.destination, "b", "obj"), "retained replica")
+	})
+
+	t.Run("Failed tasks are retried with backoff", func(t *testing.T) {
+		// Given: A target that fails once
+		f := newReplicationFixture(t)
+		target := &flakyTarget{Target: replication.NewBackendTarget("dr", f.destination)}
+		target.failures.Store(3) // One retryer round of attempts
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules:        []*models.ReplicationRule{{ID: "r1", Bucket: "releases", Target: "dr", Enabled: true}},
+			PollInterval: 10 * time.Millisecond,
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		f.putObject(t, "releases", "obj", "data")
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "obj"), "object changed")
+
+		// When: The first pass fails
+		completed, err := replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "first pass")
+		test.AssertEqual(t, 0, completed, "completed on first pass")
+
+		// Then: The task stays queued with the error recorded
+		status, err := f.store.GetReplicationStatus("r1", "releases", "obj")
+		test.AssertNoError(t, err, "get status")
+		test.AssertEqual(t, models.ReplicationStatePending, status.State, "state after failure")
+		test.AssertEqual(t, 1, status.Attempts, "attempts after failure")
+		test.AssertTrue(t, status.LastError != "", "last error recorded")
+
+		// And: A later pass succeeds once the backoff has elapsed
+		time.Sleep(50 * time.Millisecond)
+		completed, err = replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "second pass")
+		test.AssertEqual(t, 1, completed, "completed on second pass")
+
+		status, err = f.store.GetReplicationStatus("r1", "releases", "obj")
+		test.AssertNoError(t, err, "get status")
+		test.AssertEqual(t, models.ReplicationStateCompleted, status.State, "state after retry")
+	})
+
+	t.Run("Tasks are marked failed after max attempts", func(t *testing.T) {
+		// Given: A target that always fails and a single allowed attempt
+		f := newReplicationFixture(t)
+		target := &flakyTarget{Target: replication.NewBackendTarget("dr", f.destination)}
+		target.failures.Store(1 << 30)
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules:       []*models.ReplicationRule{{ID: "r1", Bucket: "releases", Target: "dr", Enabled: true}},
+			MaxAttempts: 1,
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		f.putObject(t, "releases", "obj", "data")
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "obj"), "object changed")
+
+		// When: Processing the queue
+		_, err = replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "process pending")
+
+		// Then: The task leaves the queue as failed
+		depth, err := f.store.CountReplicationTasks()
+		test.AssertNoError(t, err, "count tasks")
+		test.AssertEqual(t, 0, depth, "queue depth")
+
+		statuses, err := replicator.Status("releases", "obj")
+		test.AssertNoError(t, err, "get status")
+		test.AssertEqual(t, 1, len(statuses), "status count")
+		test.AssertEqual(t, models.ReplicationStateFailed, statuses[0].State, "final state")
+	})
+
+	t.Run("Backed-off tasks do not hold up due tasks", func(t *testing.T) {
+		// Given: A batch's worth of tasks backing off ahead of a due task
+		f := newReplicationFixture(t)
+		target := &flakyTarget{Target: replication.NewBackendTarget("dr", f.destination)}
+		target.failures.Store(6) // One retryer round for each of two objects
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules:        []*models.ReplicationRule{{ID: "r1", Bucket: "releases", Target: "dr", Enabled: true}},
+			PollInterval: time.Hour,
+			BatchSize:    2,
+		}, f.store, f.source, []replication.Target{target}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		for _, key := range []string{"a", "b"} {
+			f.putObject(t, "releases", key, "data")
+			test.AssertNoError(t, replicator.ObjectChanged("releases", key), "object changed")
+		}
+		completed, err := replicator.ProcessPending(ctx)
+		test.AssertNoError(t, err, "first pass")
+		test.AssertEqual(t, 0, completed, "completed on first pass")
+
+		// When: Another object changes
+		f.putObject(t, "releases", "c", "data")
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "c"), "object changed")
+		completed, err = replicator.ProcessPending(ctx)
+
+		// Then: It is replicated while the others keep backing off
+		test.AssertNoError(t, err, "second pass")
+		test.AssertEqual(t, 1, completed, "completed on second pass")
+		test.AssertEqual(t, "data", readObject(t, f.destination, "releases", "c"), "replicated content")
+		depth, err := f.store.CountReplicationTasks()
+		test.AssertNoError(t, err, "count tasks")
+		test.AssertEqual(t, 2, depth, "tasks backing off")
+	})
+
+	t.Run("Metadata errors are retried", func(t *testing.T) {
+		// Given: A metadata store that cannot look up artifacts
+		f := newReplicationFixture(t)
+		store := &unavailableStore{MetadataStore: f.store}
+		replicator, err := replication.NewReplicator(&replication.Config{
+			Rules: []*models.ReplicationRule{{ID: "r1", Bucket: "releases", Target: "dr", Enabled: true}},
+		}, store, f.source, []replication.Target{replication.NewBackendTarget("dr", f.destination)}, test.NewTestLogger(t))
+		test.AssertNoError(t, err, "create replicator")
+
+		f.putObject(t, "releases", "obj", "data")
+		test.AssertNoError(t, replicator.ObjectChanged("releases", "obj"), "object changed")
+
+		// When: Processing the queue
+		completed, err := replicator.ProcessPending(ctx)
+
+		// Then: The task stays queued for another attempt
+		test.AssertNoError(t, err, "process pending")
+		test.AssertEqual(t, 0, completed, "completed tasks")
+		status, err := f.store.GetReplicationStatus("r1", "releases", "obj")
+		test.AssertNoError(t, err, "get status")
+		test.AssertEqual(t, models.ReplicationStatePending, status.State, "state after metadata error")
+		test.AssertTrue(t, status.LastError != "", "last error recorded")
+	})
+
+	t.Run("Rules must reference configured targets", func(t *testing.T) {
+		f := newReplicationFixture(t)
+		_, err := replication.NewReplicator(&replication.Config{
+			Rules: []*models.ReplicationRule{{ID: "r1", Bucket: "releases", Target: "missing", Enabled: true}},
+		}, f.store, f.source, nil, test.NewTestLogger(t))
+		test.AssertError(t, err, "unknown target")
+	})
+}
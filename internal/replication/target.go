@@ -0,0 +1,186 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+)
+
+// sidecarPrefix is the key prefix for replicated metadata in backend targets
+const sidecarPrefix = ".replication/"
+
+// Record is an artifact and its supply-chain records as replicated to a target
+type Record struct {
+	Artifact     *models.Artifact      `json:"artifact"`
+	Signatures   []*models.Signature   `json:"signatures,omitempty"`
+	SBOM         *models.SBOM          `json:"sbom,omitempty"`
+	Attestations []*models.Attestation `json:"attestations,omitempty"`
+}
+
+// Target receives replicated objects
+type Target interface {
+	// Name returns the configured target name
+	Name() string
+
+	// PutObject stores object data and its supply-chain records in bucket
+	PutObject(ctx context.Context, bucket string, record *Record, data io.Reader) error
+
+	// DeleteObject removes a replicated object and its records
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// TargetConfig configures a replication target
+type TargetConfig struct {
+	Name    string                 `json:"name" mapstructure:"name"`
+	Type    string                 `json:"type" mapstructure:"type"` // backend or astore
+	Backend *storage.BackendConfig `json:"backend,omitempty" mapstructure:"backend"`
+	URL     string                 `json:"url,omitempty" mapstructure:"url"`
+	Token   string                 `json:"token,omitempty" mapstructure:"token"`
+}
+
+// NewTarget creates a target from configuration
+func NewTarget(config *TargetConfig) (Target, error) {
+	switch config.Type {
+	case "backend":
+		if config.Backend == nil {
+			return nil, fmt.Errorf("replication target %s: backend configuration is required", config.Name)
+		}
+		backend, err := storage.NewBackend(config.Backend)
+		if err != nil {
+			return nil, fmt.Errorf("replication target %s: %w", config.Name, err)
+		}
+		return NewBackendTarget(config.Name, backend), nil
+	case "astore":
+		c, err := client.NewClient(&client.Config{BaseURL: config.URL, Token: config.Token})
+		if err != nil {
+			return nil, fmt.Errorf("replication target %s: %w", config.Name, err)
+		}
+		return NewClientTarget(config.Name, c), nil
+	default:
+		return nil, fmt.Errorf("replication target %s: unsupported type %q", config.Name, config.Type)
+	}
+}
+
+// BackendTarget replicates into a storage.Backend. Artifact metadata and
+// supply-chain records are stored as a JSON sidecar next to the data.
+type BackendTarget struct {
+	name    string
+	backend storage.Backend
+}
+
+// NewBackendTarget creates a target backed by a storage backend
+func NewBackendTarget(name string, backend storage.Backend) *BackendTarget {
+	return &BackendTarget{name: name, backend: backend}
+}
+
+// Name returns the target name
+func (t *BackendTarget) Name() string {
+	return t.name
+}
+
+// PutObject writes the object data followed by its sidecar
+func (t *BackendTarget) PutObject(ctx context.Context, bucket string, record *Record, data io.Reader) error {
+	exists, err := t.backend.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := t.backend.CreateBucket(ctx, bucket); err != nil {
+			return err
+		}
+	}
+
+	key := record.Artifact.Key
+	if _, err := t.backend.WriteObject(ctx, bucket, key, data, record.Artifact.Size); err != nil {
+		return err
+	}
+
+	sidecar, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication record: %w", err)
+	}
+	_, err = t.backend.WriteObject(ctx, bucket, sidecarPrefix+key+".json", bytes.NewReader(sidecar), int64(len(sidecar)))
+	return err
+}
+
+// DeleteObject deletes the object data and its sidecar
+func (t *BackendTarget) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := t.backend.DeleteObject(ctx, bucket, key); err != nil && !isNotFound(err) {
+		return err
+	}
+	if err := t.backend.DeleteObject(ctx, bucket, sidecarPrefix+key+".json"); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// ClientTarget replicates to another astore server through pkg/client
+type ClientTarget struct {
+	name   string
+	client *client.Client
+}
+
+// NewClientTarget creates a target for a remote astore server
+func NewClientTarget(name string, c *client.Client) *ClientTarget {
+	return &ClientTarget{name: name, client: c}
+}
+
+// Name returns the target name
+func (t *ClientTarget) Name() string {
+	return t.name
+}
+
+// PutObject uploads the object and imports its supply-chain records
+func (t *ClientTarget) PutObject(ctx context.Context, bucket string, record *Record, data io.Reader) error {
+	if err := t.client.CreateBucket(ctx, bucket); err != nil && !isConflict(err) {
+		return err
+	}
+
+	artifact := record.Artifact
+	opts := &client.UploadOptions{
+		ContentType: artifact.ContentType,
+		Metadata:    artifact.Metadata,
+	}
+	if err := t.client.Upload(ctx, bucket, artifact.Key, data, artifact.Size, opts); err != nil {
+		return err
+	}
+
+	if len(record.Signatures) == 0 && record.SBOM == nil && len(record.Attestations) == 0 {
+		return nil
+	}
+
+	document, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication record: %w", err)
+	}
+	return t.client.ImportSupplyChain(ctx, bucket, artifact.Key, document)
+}
+
+// DeleteObject deletes the object on the remote server
+func (t *ClientTarget) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := t.client.DeleteObject(ctx, bucket, key); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// isNotFound checks for not-found errors from storage backends and the client
+func isNotFound(err error) bool {
+	if appErr, ok := err.(*storage.AppError); ok {
+		return appErr.Code == "NOT_FOUND"
+	}
+	return errors.GetHTTPStatus(err) == http.StatusNotFound
+}
+
+// isConflict checks for conflict errors from the client
+func isConflict(err error) bool {
+	return errors.GetHTTPStatus(err) == http.StatusConflict
+}
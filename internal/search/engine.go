@@ -0,0 +1,454 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+)
+
+const (
+	// DefaultLimit is the number of results returned when no limit is given
+	DefaultLimit = 100
+	// MaxLimit is the largest number of results returned per page
+	MaxLimit = 1000
+
+	// scanBatchSize is the number of artifacts read per scan of the metadata store
+	scanBatchSize = 500
+)
+
+// ErrInvalidCursor is returned for cursors not issued by Search
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// Hit is an artifact matching a search, with its supply-chain status
+type Hit struct {
+	*models.Artifact
+	Signed   bool `json:"signed"`
+	HasSBOM  bool `json:"hasSbom"`
+	Attested bool `json:"attested"`
+}
+
+// Result is one page of search hits, ordered by artifact ID
+type Result struct {
+	Results    []*Hit `json:"results"`
+	Count      int    `json:"count"`
+	NextCursor string `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
+// Engine evaluates queries against the metadata store, narrowing candidates
+// with the store's search indexes where the query allows
+type Engine struct {
+	store storage.MetadataStore
+}
+
+// NewEngine creates a search engine over a metadata store
+func NewEngine(store storage.MetadataStore) *Engine {
+	return &Engine{store: store}
+}
+
+// Search returns up to limit artifacts matching query, continuing after the
+// page that returned cursor
+func (e *Engine) Search(query *Query, limit int, cursor string) (*Result, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, indexed, err := e.indexCandidates(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+
+	var hits []*Hit
+	if indexed {
+		hits, err = e.searchCandidates(query, ids, after, limit+1)
+	} else {
+		hits, err = e.searchScan(query, after, limit+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Results: hits}
+	if len(hits) > limit {
+		result.Results = hits[:limit]
+		last := result.Results[limit-1]
+		result.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(artifactID(last.Artifact)))
+	}
+	result.Count = len(result.Results)
+	return result, nil
+}
+
+// indexCandidates intersects the index lookups of the query's positive
+// terms. It reports false when no term can be answered from an index.
+func (e *Engine) indexCandidates(query *Query) ([]string, bool, error) {
+	var (
+		candidates []string
+		indexed    bool
+	)
+	lookup := func(index, value string) error {
+		ids, err := e.store.FindArtifacts(index, value)
+		if err != nil {
+			return err
+		}
+		sort.Strings(ids)
+		if !indexed {
+			candidates, indexed = ids, true
+		} else {
+			candidates = intersect(candidates, ids)
+		}
+		return nil
+	}
+
+	for _, term := range query.Terms {
+		if term.Negate {
+			continue
+		}
+		exact := (term.Op == OpEq || term.Op == OpMatch) && !isGlob(term.Value)
+
+		var err error
+		switch {
+		case term.Field == "":
+			for _, token := range storage.ArtifactTokens(term.Value) {
+				if err = lookup(storage.ArtifactIndexToken, token); err != nil {
+					break
+				}
+			}
+		case strings.HasPrefix(term.Field, metaPrefix) && exact:
+			err = lookup(storage.ArtifactIndexMetadata, storage.MetadataIndexValue(term.Field[len(metaPrefix):], term.Value))
+		case strings.HasPrefix(term.Field, tagPrefix) && exact:
+			err = lookup(storage.ArtifactIndexTag, storage.MetadataIndexValue(term.Field[len(tagPrefix):], term.Value))
+		case term.Field == FieldType && exact:
+			err = lookup(storage.ArtifactIndexContentType, strings.ToLower(term.Value))
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return candidates, indexed, nil
+}
+
+// searchCandidates evaluates the query on indexed artifacts following after
+func (e *Engine) searchCandidates(query *Query, ids []string, after string, limit int) ([]*Hit, error) {
+	start := sort.SearchStrings(ids, after)
+	if start < len(ids) && ids[start] == after {
+		start++
+	}
+
+	var hits []*Hit
+	for _, id := range ids[start:] {
+		bucket, key, _ := strings.Cut(id, "/")
+		artifact, err := e.store.GetArtifact(bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read artifact %s: %w", id, err)
+		}
+
+		hit, err := e.evaluate(query, artifact)
+		if err != nil {
+			return nil, err
+		}
+		if hit != nil {
+			hits = append(hits, hit)
+			if len(hits) == limit {
+				break
+			}
+		}
+	}
+	return hits, nil
+}
+
+// searchScan evaluates the query on every artifact in store order, within a
+// bucket and key prefix if the query names them
+func (e *Engine) searchScan(query *Query, after string, limit int) ([]*Hit, error) {
+	bucket, prefix := scanScope(query)
+
+	var hits []*Hit
+	for {
+		artifacts, err := e.store.ScanArtifacts(bucket, prefix, after, scanBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan artifacts: %w", err)
+		}
+
+		// Supply-chain lookups read the store, so they run after the scan
+		for _, artifact := range artifacts {
+			hit, err := e.evaluate(query, artifact)
+			if err != nil {
+				return nil, err
+			}
+			if hit != nil {
+				hits = append(hits, hit)
+				if len(hits) == limit {
+					return hits, nil
+				}
+			}
+		}
+
+		if len(artifacts) < scanBatchSize {
+			return hits, nil
+		}
+		after = artifactID(artifacts[len(artifacts)-1])
+	}
+}
+
+// scanScope returns the bucket and key prefix every match must have
+func scanScope(query *Query) (string, string) {
+	var bucket, prefix string
+	for _, term := range query.Terms {
+		if term.Negate || (term.Op != OpEq && term.Op != OpMatch) {
+			continue
+		}
+		switch term.Field {
+		case FieldBucket:
+			if !isGlob(term.Value) {
+				bucket = term.Value
+			}
+		case FieldKey:
+			if i := strings.IndexAny(term.Value, "*?"); i >= 0 && term.Op == OpMatch {
+				prefix = term.Value[:i]
+			} else {
+				prefix = term.Value
+			}
+		}
+	}
+	if bucket == "" {
+		return "", ""
+	}
+	return bucket, prefix
+}
+
+// evaluate returns the hit for artifact if it matches every term, or nil
+func (e *Engine) evaluate(query *Query, artifact *models.Artifact) (*Hit, error) {
+	for _, term := range query.Terms {
+		if term.isSupplyChain() {
+			continue
+		}
+		if matchArtifact(term, artifact) == term.Negate {
+			return nil, nil
+		}
+	}
+
+	hit, err := e.supplyChainStatus(artifact)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range query.Terms {
+		if term.isSupplyChain() && matchSupplyChain(term, hit) == term.Negate {
+			return nil, nil
+		}
+	}
+	return hit, nil
+}
+
+// supplyChainStatus looks up whether an artifact is signed, has an SBOM, or is attested
+func (e *Engine) supplyChainStatus(artifact *models.Artifact) (*Hit, error) {
+	id := artifactID(artifact)
+	hit := &Hit{Artifact: artifact}
+
+	signatures, err := e.store.ListSignaturesForArtifact(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signatures of %s: %w", id, err)
+	}
+	hit.Signed = len(signatures) > 0
+
+	// The store reports a missing SBOM as an error
+	if sbom, err := e.store.GetSBOMForArtifact(id); err == nil && sbom != nil {
+		hit.HasSBOM = true
+	}
+
+	attestations, err := e.store.ListAttestationsForArtifact(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attestations of %s: %w", id, err)
+	}
+	hit.Attested = len(attestations) > 0
+	return hit, nil
+}
+
+func (t *Term) isSupplyChain() bool {
+	return t.Field == FieldSigned || t.Field == FieldSBOM || t.Field == FieldAttested
+}
+
+// matchArtifact reports whether a term, ignoring negation, matches an artifact
+func matchArtifact(term *Term, artifact *models.Artifact) bool {
+	switch {
+	case term.Field == "":
+		return matchWord(term.Value, artifact)
+	case term.Field == FieldKey:
+		return matchText(term, artifact.Key, true)
+	case term.Field == FieldBucket:
+		return matchText(term, artifact.Bucket, true)
+	case term.Field == FieldType:
+		return matchText(term, strings.ToLower(artifact.ContentType), true)
+	case term.Field == FieldDigest:
+		return matchText(term, artifact.Digest.String(), true)
+	case term.Field == FieldSize:
+		return compare(term.Op, compareInt(artifact.Size, term.size))
+	case term.Field == FieldCreated:
+		return matchTime(term, artifact.CreatedAt.UnixNano())
+	case term.Field == FieldUpdated:
+		return matchTime(term, artifact.UpdatedAt.UnixNano())
+	case strings.HasPrefix(term.Field, metaPrefix):
+		value, ok := lookupName(artifact.Metadata, term.Field[len(metaPrefix):])
+		return matchText(term, value, ok)
+	case strings.HasPrefix(term.Field, tagPrefix):
+		value, ok := lookupName(artifact.Tags, term.Field[len(tagPrefix):])
+		return matchText(term, value, ok)
+	}
+	return false
+}
+
+func matchSupplyChain(term *Term, hit *Hit) bool {
+	var status bool
+	switch term.Field {
+	case FieldSigned:
+		status = hit.Signed
+	case FieldSBOM:
+		status = hit.HasSBOM
+	case FieldAttested:
+		status = hit.Attested
+	}
+	if term.Op == OpNe {
+		return status != term.flag
+	}
+	return status == term.flag
+}
+
+// matchWord matches every indexed token of a word, or for words too short to
+// index, a case-insensitive substring of the key
+func matchWord(word string, artifact *models.Artifact) bool {
+	tokens := storage.ArtifactTokens(word)
+	if len(tokens) == 0 {
+		return strings.Contains(strings.ToLower(artifact.Key), strings.ToLower(word))
+	}
+
+	text := make(map[string]bool)
+	for _, token := range storage.ArtifactText(artifact) {
+		text[token] = true
+	}
+	for _, token := range tokens {
+		if !text[token] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchText matches a text field; present is false for a missing metadata entry or tag
+func matchText(term *Term, value string, present bool) bool {
+	expected := term.Value
+	if term.Field == FieldType {
+		expected = strings.ToLower(expected)
+	}
+
+	switch term.Op {
+	case OpMatch:
+		return present && globMatch(expected, value)
+	case OpEq:
+		return present && value == expected
+	case OpNe:
+		return !present || value != expected
+	}
+	return false
+}
+
+// matchTime compares a timestamp with the instant, or the day, a term names
+func matchTime(term *Term, nanos int64) bool {
+	from, to := term.from.UnixNano(), term.to.UnixNano()
+	switch term.Op {
+	case OpMatch, OpEq:
+		return nanos >= from && nanos <= to
+	case OpNe:
+		return nanos < from || nanos > to
+	case OpGt:
+		return nanos > to
+	case OpGe:
+		return nanos >= from
+	case OpLt:
+		return nanos < from
+	case OpLe:
+		return nanos <= to
+	}
+	return false
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare applies an operator to the result of a three-way comparison
+func compare(op string, c int) bool {
+	switch op {
+	case OpMatch, OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGe:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLe:
+		return c <= 0
+	}
+	return false
+}
+
+// lookupName finds a metadata entry or tag by case-insensitive name
+func lookupName(values map[string]string, name string) (string, bool) {
+	for k, v := range values {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func artifactID(artifact *models.Artifact) string {
+	return artifact.Bucket + "/" + artifact.Key
+}
+
+// intersect returns the IDs in both sorted slices
+func intersect(a, b []string) []string {
+	var out []string
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func parseCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.Contains(string(raw), "/") {
+		return "", ErrInvalidCursor
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/candlekeep/zot-artifact-store/internal/auth"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/gorilla/mux"
+	"zotregistry.io/zot/pkg/log"
+)
+
+// Handler serves the artifact search endpoint
+type Handler struct {
+	engine *Engine
+	logger log.Logger
+}
+
+// NewHandler creates a new search handler
+func NewHandler(engine *Engine, logger log.Logger) *Handler {
+	return &Handler{
+		engine: engine,
+		logger: logger,
+	}
+}
+
+// RegisterRoutes registers search routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/search", h.Search).Methods("GET")
+}
+
+// RoutePermissions declares the action and resource each route requires
+func (h *Handler) RoutePermissions() []auth.RoutePermission {
+	return []auth.RoutePermission{
+		{Method: "GET", Path: "/search", Action: models.ActionList, Resource: "*"},
+	}
+}
+
+// Search returns a page of artifacts matching the q parameter.
+// Pass the returned nextCursor as cursor to fetch the following page.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query, err := Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := DefaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	result, err := h.engine.Search(query, limit, r.URL.Query().Get("cursor"))
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("failed to search artifacts")
+		http.Error(w, "Failed to search artifacts", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
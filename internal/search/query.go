@@ -0,0 +1,275 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a parsed search expression: every term must match
+type Query struct {
+	Terms []*Term
+}
+
+// Term is one condition of a query, such as size>100MB or a bare word
+type Term struct {
+	Field  string // Empty for a full-text word
+	Op     string // One of the Op* operators; empty for a full-text word
+	Value  string
+	Negate bool // Prefixed with "-"
+
+	size int64     // Parsed value of size terms
+	from time.Time // Parsed value of time terms: the instant or day it names
+	to   time.Time // End of the day named by a date, or from
+	flag bool      // Parsed value of supply-chain terms
+}
+
+// Comparison operators
+const (
+	OpMatch = ":"  // Glob match for text, equality otherwise
+	OpEq    = "="  // Exact equality
+	OpNe    = "!=" // Inequality
+	OpGt    = ">"
+	OpGe    = ">="
+	OpLt    = "<"
+	OpLe    = "<="
+)
+
+// Searchable fields. User metadata and tags are addressed as meta.<name>
+// and tag.<name>.
+const (
+	FieldKey      = "key"
+	FieldBucket   = "bucket"
+	FieldType     = "type"
+	FieldDigest   = "digest"
+	FieldSize     = "size"
+	FieldCreated  = "created"
+	FieldUpdated  = "updated"
+	FieldSigned   = "signed"
+	FieldSBOM     = "sbom"
+	FieldAttested = "attested"
+
+	metaPrefix = "meta."
+	tagPrefix  = "tag."
+)
+
+// operators in the order they are looked for, longest first
+var operators = []string{OpGe, OpLe, OpNe, OpGt, OpLt, OpEq, OpMatch}
+
+// Parse parses a search expression. Terms are separated by spaces and all
+// must match; values containing spaces are double-quoted. A term is a field,
+// an operator and a value (size>100MB, meta.git-sha=abc123, key:*.rpm), or a
+// bare word matched against the words of keys, metadata and tags. A leading
+// "-" negates a term.
+func Parse(expression string) (*Query, error) {
+	words, err := splitTerms(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	for _, word := range words {
+		term, err := parseTerm(word)
+		if err != nil {
+			return nil, err
+		}
+		query.Terms = append(query.Terms, term)
+	}
+	return query, nil
+}
+
+// splitTerms splits an expression on spaces outside double quotes, removing the quotes
+func splitTerms(expression string) ([]string, error) {
+	var (
+		terms   []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+	for _, r := range expression {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			if started {
+				terms = append(terms, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in search query")
+	}
+	if started {
+		terms = append(terms, current.String())
+	}
+	return terms, nil
+}
+
+func parseTerm(word string) (*Term, error) {
+	term := &Term{}
+	if strings.HasPrefix(word, "-") && len(word) > 1 {
+		term.Negate = true
+		word = word[1:]
+	}
+
+	field, op, value, ok := splitCondition(word)
+	if !ok {
+		term.Value = word
+		return term, nil
+	}
+	term.Field, term.Op, term.Value = strings.ToLower(field), op, value
+
+	var err error
+	switch {
+	case term.Field == FieldKey, term.Field == FieldBucket, term.Field == FieldType, term.Field == FieldDigest,
+		strings.HasPrefix(term.Field, metaPrefix), strings.HasPrefix(term.Field, tagPrefix):
+		err = checkOperator(term, OpMatch, OpEq, OpNe)
+	case term.Field == FieldSize:
+		if err = checkOperator(term, operators...); err == nil {
+			term.size, err = parseSize(value)
+		}
+	case term.Field == FieldCreated, term.Field == FieldUpdated:
+		if err = checkOperator(term, operators...); err == nil {
+			term.from, term.to, err = parseTime(value, time.Now())
+		}
+	case term.Field == FieldSigned, term.Field == FieldSBOM, term.Field == FieldAttested:
+		if err = checkOperator(term, OpMatch, OpEq, OpNe); err == nil {
+			term.flag, err = parseFlag(value)
+		}
+	default:
+		// Not a field, so the whole term is a word such as a URL
+		return &Term{Value: word, Negate: term.Negate}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid search term %q: %w", word, err)
+	}
+	return term, nil
+}
+
+// splitCondition splits field, operator and value if word starts with a field name
+func splitCondition(word string) (field, op, value string, ok bool) {
+	end := strings.IndexFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '-' && r != '_'
+	})
+	if end <= 0 {
+		return "", "", "", false
+	}
+	for _, candidate := range operators {
+		if strings.HasPrefix(word[end:], candidate) {
+			return word[:end], candidate, word[end+len(candidate):], true
+		}
+	}
+	return "", "", "", false
+}
+
+func checkOperator(term *Term, allowed ...string) error {
+	for _, op := range allowed {
+		if term.Op == op {
+			return nil
+		}
+	}
+	return fmt.Errorf("operator %s is not supported for %s", term.Op, term.Field)
+}
+
+// sizeUnits are binary multiples, so 1MB is 1024*1024 bytes
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+func parseSize(value string) (int64, error) {
+	split := strings.IndexFunc(value, func(r rune) bool { return unicode.IsLetter(r) })
+	number, unit := value, ""
+	if split >= 0 {
+		number, unit = value[:split], strings.ToLower(value[split:])
+	}
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// parseTime parses an RFC 3339 instant, a date, or a duration such as 7d or
+// 12h meaning that long before now. A date names the whole day in UTC.
+func parseTime(value string, now time.Time) (time.Time, time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, t, nil
+	}
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day, day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid time %q", value)
+		}
+		t := now.Add(-time.Duration(n) * 24 * time.Hour)
+		return t, t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		t := now.Add(-d)
+		return t, t, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid time %q (expected RFC 3339, YYYY-MM-DD or a duration such as 7d)", value)
+}
+
+func parseFlag(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true or false, got %q", value)
+}
+
+// isGlob reports whether a value uses glob wildcards
+func isGlob(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// globMatch matches s against a pattern where * matches any run of
+// characters, including slashes, and ? matches one character
+func globMatch(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	var pi, si int
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
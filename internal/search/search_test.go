@@ -0,0 +1,158 @@
+package search_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/search"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Fields, operators, words and negation", func(t *testing.T) {
+		// Given: A query mixing every kind of term
+		expression := `bucket=releases size>=1.5MB -key:*.tmp meta.Git-SHA="abc 123" nginx`
+
+		// When: Parsing it
+		query, err := search.Parse(expression)
+
+		// Then: Each term is split into field, operator and value
+		test.AssertNoError(t, err, "parse")
+		test.AssertEqual(t, 5, len(query.Terms), "terms")
+		test.AssertEqual(t, "bucket", query.Terms[0].Field, "bucket field")
+		test.AssertEqual(t, search.OpEq, query.Terms[0].Op, "bucket operator")
+		test.AssertEqual(t, search.OpGe, query.Terms[1].Op, "size operator")
+		test.AssertTrue(t, query.Terms[2].Negate, "negated key")
+		test.AssertEqual(t, "*.tmp", query.Terms[2].Value, "key pattern")
+		test.AssertEqual(t, "meta.git-sha", query.Terms[3].Field, "metadata field is lowercased")
+		test.AssertEqual(t, "abc 123", query.Terms[3].Value, "quoted value")
+		test.AssertEqual(t, "", query.Terms[4].Field, "bare word")
+	})
+
+	t.Run("Invalid terms are rejected", func(t *testing.T) {
+		for _, expression := range []string{
+			`size>lots`,
+			`size>5XB`,
+			`created<yesterday`,
+			`signed=maybe`,
+			`key>abc`,
+			`meta.x="unterminated`,
+		} {
+			// When: Parsing an invalid expression
+			_, err := search.Parse(expression)
+
+			// Then: It fails
+			test.AssertError(t, err, expression)
+		}
+	})
+}
+
+func TestEngine(t *testing.T) {
+	store := test.NewTestMetadataStore(t)
+	now := time.Now().UTC()
+	storeArtifact(t, store, &models.Artifact{
+		Bucket: "releases", Key: "nginx/nginx-1.25.tar.gz", Size: 200 << 20, ContentType: "application/gzip",
+		Metadata: map[string]string{"git-sha": "abc123"}, Tags: map[string]string{"env": "prod"},
+		CreatedAt: now.Add(-30 * 24 * time.Hour), UpdatedAt: now,
+	})
+	storeArtifact(t, store, &models.Artifact{
+		Bucket: "releases", Key: "app/app-2.0.rpm", Size: 5 << 20, ContentType: "application/x-rpm",
+		Metadata: map[string]string{"git-sha": "def456"}, Tags: map[string]string{"env": "staging"},
+		CreatedAt: now.Add(-time.Hour), UpdatedAt: now,
+	})
+	storeArtifact(t, store, &models.Artifact{
+		Bucket: "snapshots", Key: "app/app-2.1-dev.rpm", Size: 4 << 20, ContentType: "application/x-rpm",
+		CreatedAt: now, UpdatedAt: now,
+	})
+	err := store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "releases/app/app-2.0.rpm", SignedAt: now})
+	test.AssertNoError(t, err, "store signature")
+
+	engine := search.NewEngine(store)
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{`bucket=releases size>100MB`, []string{"releases/nginx/nginx-1.25.tar.gz"}},
+		{`meta.git-sha=abc123`, []string{"releases/nginx/nginx-1.25.tar.gz"}},
+		{`tag.env:prod`, []string{"releases/nginx/nginx-1.25.tar.gz"}},
+		{`type=application/x-rpm -bucket=snapshots`, []string{"releases/app/app-2.0.rpm"}},
+		{`key:*.rpm created>7d`, []string{"releases/app/app-2.0.rpm", "snapshots/app/app-2.1-dev.rpm"}},
+		{`nginx`, []string{"releases/nginx/nginx-1.25.tar.gz"}},
+		{`app dev`, []string{"snapshots/app/app-2.1-dev.rpm"}},
+		{`signed:true`, []string{"releases/app/app-2.0.rpm"}},
+		{`key:app/* signed=false`, []string{"snapshots/app/app-2.1-dev.rpm"}},
+		{`meta.git-sha!=abc123 bucket=releases`, []string{"releases/app/app-2.0.rpm"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			// Given: A parsed query
+			query, err := search.Parse(tc.query)
+			test.AssertNoError(t, err, "parse")
+
+			// When: Searching
+			result, err := engine.Search(query, 0, "")
+
+			// Then: Exactly the expected artifacts match
+			test.AssertNoError(t, err, "search")
+			test.AssertEqual(t, len(tc.expected), result.Count, "result count")
+			for i, hit := range result.Results {
+				if i < len(tc.expected) {
+					test.AssertEqual(t, tc.expected[i], hit.Bucket+"/"+hit.Key, "result")
+				}
+			}
+		})
+	}
+
+	t.Run("Results are paged with a cursor", func(t *testing.T) {
+		// Given: A query matching three artifacts
+		query, err := search.Parse(`size>1MB`)
+		test.AssertNoError(t, err, "parse")
+
+		// When: Reading pages of two
+		first, err := engine.Search(query, 2, "")
+		test.AssertNoError(t, err, "first page")
+		second, err := engine.Search(query, 2, first.NextCursor)
+		test.AssertNoError(t, err, "second page")
+
+		// Then: The pages cover every match once
+		test.AssertEqual(t, 2, first.Count, "first page size")
+		test.AssertTrue(t, first.NextCursor != "", "first page has a cursor")
+		test.AssertEqual(t, 1, second.Count, "second page size")
+		test.AssertEqual(t, "", second.NextCursor, "last page has no cursor")
+
+		_, err = engine.Search(query, 2, "not a cursor")
+		test.AssertError(t, err, "invalid cursor")
+	})
+
+	t.Run("Handler rejects invalid queries", func(t *testing.T) {
+		// Given: The search handler
+		router := mux.NewRouter()
+		search.NewHandler(engine, test.NewTestLogger(t)).RegisterRoutes(router)
+
+		// When: Searching with a valid and an invalid query
+		valid := httptest.NewRecorder()
+		router.ServeHTTP(valid, httptest.NewRequest("GET", "/search?q=signed%3Dtrue", nil))
+		invalid := httptest.NewRecorder()
+		router.ServeHTTP(invalid, httptest.NewRequest("GET", "/search?q=size%3Ehuge", nil))
+
+		// Then: The valid query returns hits with their supply-chain status
+		test.AssertEqual(t, http.StatusOK, valid.Code, "valid query status")
+		var result search.Result
+		test.AssertNoError(t, json.NewDecoder(valid.Body).Decode(&result), "decode result")
+		test.AssertEqual(t, 1, result.Count, "result count")
+		test.AssertTrue(t, result.Results[0].Signed, "signed status")
+		test.AssertEqual(t, http.StatusBadRequest, invalid.Code, "invalid query status")
+	})
+}
+
+func storeArtifact(t *testing.T, store storage.MetadataStore, artifact *models.Artifact) {
+	t.Helper()
+	test.AssertNoError(t, store.StoreArtifact(artifact), "store artifact")
+}
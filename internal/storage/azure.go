@@ -22,30 +22,53 @@ type AzureBackend struct {
 	enableChecksum bool
 }
 
-// NewAzureBackend creates a new Azure Blob Storage backend
-func NewAzureBackend(config *BackendConfig) (*AzureBackend, error) {
-	if config.AzureAccountName == "" {
-		return nil, &AppError{
-			Code:    "INVALID_CONFIG",
-			Message: "Azure account name is required",
-		}
+// AzureConfig configures the Azure Blob Storage backend
+type AzureConfig struct {
+	AccountName   string `json:"accountName" yaml:"accountName"`
+	AccountKey    string `json:"accountKey" yaml:"accountKey"`
+	ContainerName string `json:"containerName" yaml:"containerName"`
+	Endpoint      string `json:"endpoint" yaml:"endpoint"` // Defaults to https://<account>.blob.core.windows.net/
+}
+
+// Validate checks the Azure configuration
+func (c *AzureConfig) Validate() error {
+	if c.AccountName == "" {
+		return fmt.Errorf("accountName is required")
 	}
+	if c.ContainerName == "" {
+		return fmt.Errorf("containerName is required")
+	}
+	return nil
+}
+
+func init() {
+	RegisterBackend(BackendRegistration{
+		Name:        "azure",
+		NewSettings: func() BackendSettings { return &AzureConfig{} },
+		Factory: func(config *BackendConfig) (Backend, error) {
+			return NewAzureBackend(config.Settings.(*AzureConfig), config.EnableChecksum)
+		},
+	})
+}
 
-	if config.AzureContainerName == "" {
+// NewAzureBackend creates a new Azure Blob Storage backend
+func NewAzureBackend(config *AzureConfig, enableChecksum bool) (*AzureBackend, error) {
+	if err := config.Validate(); err != nil {
 		return nil, &AppError{
 			Code:    "INVALID_CONFIG",
-			Message: "Azure container name is required",
+			Message: "invalid azure backend configuration",
+			Err:     err,
 		}
 	}
 
 	// Build service URL
-	serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net/", config.AzureAccountName)
-	if config.AzureEndpoint != "" {
-		serviceURL = config.AzureEndpoint
+	serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net/", config.AccountName)
+	if config.Endpoint != "" {
+		serviceURL = config.Endpoint
 	}
 
 	// Create credential
-	cred, err := azblob.NewSharedKeyCredential(config.AzureAccountName, config.AzureAccountKey)
+	cred, err := azblob.NewSharedKeyCredential(config.AccountName, config.AccountKey)
 	if err != nil {
 		return nil, &AppError{
 			Code:    "INIT_ERROR",
@@ -66,8 +89,8 @@ func NewAzureBackend(config *BackendConfig) (*AzureBackend, error) {
 
 	return &AzureBackend{
 		client:         client,
-		containerName:  config.AzureContainerName,
-		enableChecksum: config.EnableChecksum,
+		containerName:  config.ContainerName,
+		enableChecksum: enableChecksum,
 	}, nil
 }
 
@@ -78,6 +101,10 @@ func (az *AzureBackend) Name() string {
 
 // WriteObject writes an object to Azure Blob Storage
 func (az *AzureBackend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 
 	var data []byte
@@ -139,6 +166,10 @@ func (az *AzureBackend) WriteObject(ctx context.Context, bucket, key string, rea
 
 // ReadObject reads an object from Azure Blob Storage
 func (az *AzureBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -178,6 +209,23 @@ func (az *AzureBackend) ReadObject(ctx context.Context, bucket, key string) (io.
 
 // ReadObjectRange reads a byte range from an object in Azure
 func (az *AzureBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	// A zero count means "to the end" in Azure, so resolve the range first
+	size, err := az.GetObjectSize(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	length, err = resolveRange(offset, length, size)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -212,6 +260,10 @@ func (az *AzureBackend) ReadObjectRange(ctx context.Context, bucket, key string,
 
 // DeleteObject deletes an object from Azure Blob Storage
 func (az *AzureBackend) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := validateObjectName(bucket, key); err != nil {
+		return err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -239,6 +291,10 @@ func (az *AzureBackend) DeleteObject(ctx context.Context, bucket, key string) er
 
 // ObjectExists checks if an object exists in Azure
 func (az *AzureBackend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return false, err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -262,6 +318,10 @@ func (az *AzureBackend) ObjectExists(ctx context.Context, bucket, key string) (b
 
 // CreateBucket creates a new Azure container (no-op for prefix-based buckets)
 func (az *AzureBackend) CreateBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In Azure backend, we use prefixes within a single container
 	// So this is a no-op
 	return nil
@@ -269,6 +329,10 @@ func (az *AzureBackend) CreateBucket(ctx context.Context, bucket string) error {
 
 // DeleteBucket deletes an Azure container (no-op for prefix-based buckets)
 func (az *AzureBackend) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In Azure backend, we use prefixes within a single container
 	// So this is a no-op
 	return nil
@@ -276,6 +340,10 @@ func (az *AzureBackend) DeleteBucket(ctx context.Context, bucket string) error {
 
 // BucketExists checks if a container exists
 func (az *AzureBackend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return false, err
+	}
+
 	// Check if the main Azure container exists
 	containerClient := az.client.ServiceClient().NewContainerClient(az.containerName)
 	_, err := containerClient.GetProperties(ctx, nil)
@@ -298,6 +366,10 @@ func (az *AzureBackend) BucketExists(ctx context.Context, bucket string) (bool,
 
 // GetObjectSize returns the size of an object in Azure
 func (az *AzureBackend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -332,6 +404,10 @@ func (az *AzureBackend) GetObjectSize(ctx context.Context, bucket, key string) (
 
 // GetObjectHash returns the SHA256 hash of an object
 func (az *AzureBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
 	blobName := az.getBlobName(bucket, key)
 	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(blobName)
 
@@ -381,6 +457,35 @@ func (az *AzureBackend) GetObjectHash(ctx context.Context, bucket, key string) (
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// GetObjectETag returns the ETag of a blob
+func (az *AzureBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	blobClient := az.client.ServiceClient().NewContainerClient(az.containerName).NewBlockBlobClient(az.getBlobName(bucket, key))
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return "", &AppError{
+				Code:    "NOT_FOUND",
+				Message: "blob not found",
+				Err:     err,
+			}
+		}
+		return "", &AppError{
+			Code:    "STAT_ERROR",
+			Message: "failed to get blob metadata",
+			Err:     err,
+		}
+	}
+
+	if props.ETag == nil {
+		return "", nil
+	}
+	return string(*props.ETag), nil
+}
+
 // HealthCheck performs a health check on Azure
 func (az *AzureBackend) HealthCheck(ctx context.Context) error {
 	// Try to get container properties
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+
+	"zotregistry.io/zot/pkg/log"
 )
 
 // Backend defines the interface for storage backends
@@ -40,6 +43,10 @@ type Backend interface {
 	// GetObjectHash returns the SHA256 hash of an object
 	GetObjectHash(ctx context.Context, bucket, key string) (string, error)
 
+	// GetObjectETag returns an opaque tag that changes whenever the object is
+	// rewritten. It is read from object metadata and never downloads the object.
+	GetObjectETag(ctx context.Context, bucket, key string) (string, error)
+
 	// Name returns the backend name (filesystem, s3, gcs, azure)
 	Name() string
 
@@ -47,56 +54,46 @@ type Backend interface {
 	HealthCheck(ctx context.Context) error
 }
 
-// BackendConfig contains common configuration for storage backends
+// BackendConfig contains the configuration for a storage backend.
+// Backend-specific options live in Settings, whose concrete type is
+// defined by the backend's registration (e.g. *S3Config for "s3").
 type BackendConfig struct {
-	// Type is the backend type (filesystem, s3, gcs, azure)
+	// Type is the registered backend type (filesystem, s3, gcs, azure, ...)
 	Type string
 
-	// Filesystem configuration
-	RootDirectory string
-
-	// S3 configuration
-	S3Endpoint        string
-	S3Region          string
-	S3Bucket          string
-	S3AccessKeyID     string
-	S3SecretAccessKey string
-	S3UseSSL          bool
-
-	// GCS configuration
-	GCSBucket          string
-	GCSCredentialsFile string
-	GCSProjectID       string
-
-	// Azure configuration
-	AzureAccountName   string
-	AzureAccountKey    string
-	AzureContainerName string
-	AzureEndpoint      string
+	// Settings is the backend-specific configuration section
+	Settings BackendSettings
 
 	// Common options
 	EnableChecksum bool // Enable SHA256 integrity verification
 	MaxRetries     int  // Maximum number of retries for failed operations
 	RetryDelay     int  // Delay between retries in milliseconds
+
+	// Cache enables a local read-through cache in front of the backend
+	Cache *CacheConfig
 }
 
 // NewBackend creates a new storage backend based on configuration
 func NewBackend(config *BackendConfig) (Backend, error) {
-	switch config.Type {
-	case "filesystem", "":
-		return NewFileSystemBackend(config.RootDirectory, config.EnableChecksum)
-	case "s3":
-		return NewS3Backend(config)
-	case "gcs":
-		return NewGCSBackend(config)
-	case "azure":
-		return NewAzureBackend(config)
-	default:
-		return nil, &AppError{
-			Code:    "INVALID_BACKEND",
-			Message: "unsupported storage backend type: " + config.Type,
-		}
+	registration, err := lookupBackend(config.Type)
+	if err != nil {
+		return nil, err
 	}
+
+	settings, err := resolveSettings(registration, config)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *config
+	resolved.Type = registration.Name
+	resolved.Settings = settings
+	backend, err := registration.Factory(&resolved)
+	if err != nil || config.Cache == nil {
+		return backend, err
+	}
+
+	return NewCachingBackend(backend, config.Cache, nil, log.NewLogger("info", ""))
 }
 
 // AppError represents a storage backend error
@@ -116,3 +113,50 @@ func (e *AppError) Error() string {
 func (e *AppError) Unwrap() error {
 	return e.Err
 }
+
+// validateBucketName checks that a bucket name is usable
+func validateBucketName(bucket string) error {
+	if bucket == "" {
+		return &AppError{
+			Code:    "INVALID_ARGUMENT",
+			Message: "bucket name cannot be empty",
+		}
+	}
+	return nil
+}
+
+// validateObjectName checks that a bucket and key identify an object
+func validateObjectName(bucket, key string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+	if key == "" {
+		return &AppError{
+			Code:    "INVALID_ARGUMENT",
+			Message: "object key cannot be empty",
+		}
+	}
+	return nil
+}
+
+// resolveRange validates a byte range against an object size and returns the
+// number of bytes to read. Ranges extending past the end are clipped; ranges
+// starting past the end are rejected.
+func resolveRange(offset, length, size int64) (int64, error) {
+	if offset < 0 || length < 0 {
+		return 0, &AppError{
+			Code:    "INVALID_RANGE",
+			Message: fmt.Sprintf("invalid range: offset %d, length %d", offset, length),
+		}
+	}
+	if offset > size {
+		return 0, &AppError{
+			Code:    "INVALID_RANGE",
+			Message: fmt.Sprintf("range offset %d is beyond object size %d", offset, size),
+		}
+	}
+	if length > size-offset {
+		length = size - offset
+	}
+	return length, nil
+}
@@ -0,0 +1,556 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"zotregistry.io/zot/pkg/log"
+)
+
+const cacheFileSuffix = ".cache"
+
+// CacheConfig configures the read-through cache
+type CacheConfig struct {
+	Directory  string `yaml:"directory" json:"directory"`   // Local directory holding cached objects
+	MaxSize    int64  `yaml:"maxSize" json:"maxSize"`       // Maximum total size of cached data in bytes
+	Verify     bool   `yaml:"verify" json:"verify"`         // Verify SHA256 of cached data when serving a hit
+	Revalidate bool   `yaml:"revalidate" json:"revalidate"` // Compare the origin ETag before serving a hit
+}
+
+// CacheMetrics receives cache hit/miss events.
+// It is satisfied by metrics.PrometheusCollector.
+type CacheMetrics interface {
+	RecordCacheHit(cache, operation string)
+	RecordCacheMiss(cache, operation string)
+	SetCacheSize(cache string, bytes int64)
+}
+
+var (
+	defaultCacheMetricsMu sync.RWMutex
+	defaultCacheMetrics   CacheMetrics
+)
+
+// SetCacheMetrics sets the metrics sink used by caching backends created
+// without one, such as those built by NewBackend from a cache configuration.
+func SetCacheMetrics(metrics CacheMetrics) {
+	defaultCacheMetricsMu.Lock()
+	defer defaultCacheMetricsMu.Unlock()
+	defaultCacheMetrics = metrics
+}
+
+// CachingBackend wraps a Backend with a size-bounded LRU cache on local disk.
+// Whole objects and byte ranges are cached independently; writes and deletes
+// go straight to the wrapped backend and invalidate any cached entries.
+type CachingBackend struct {
+	backend Backend
+	config  *CacheConfig
+	metrics CacheMetrics
+	logger  log.Logger
+
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[cacheKey]*list.Element
+	inflight map[cacheKey]*cacheFill
+	size     int64
+}
+
+// cacheKey identifies a cached whole object or byte range
+type cacheKey struct {
+	bucket string
+	key    string
+	ranged bool
+	offset int64
+	length int64
+}
+
+// cacheEntry describes a single cached object or range
+type cacheEntry struct {
+	id     cacheKey
+	bucket string
+	key    string
+	path   string // Unique to this fill, so a refill never shares a file
+	size   int64
+	sha256 string // Hash of the cached bytes
+	etag   string // Origin ETag at fill time, used for revalidation
+
+	// transient entries exceed MaxSize and are removed once opened
+	transient bool
+}
+
+// cacheFill tracks an in-progress fill so concurrent misses share one fetch
+type cacheFill struct {
+	done  chan struct{}
+	entry *cacheEntry
+	err   error
+	stale bool // Set when the key is invalidated during the fetch
+}
+
+// NewCachingBackend creates a new caching backend
+func NewCachingBackend(backend Backend, config *CacheConfig, metrics CacheMetrics, logger log.Logger) (*CachingBackend, error) {
+	if config == nil || config.Directory == "" {
+		return nil, &AppError{
+			Code:    "INVALID_CONFIG",
+			Message: "cache directory cannot be empty",
+		}
+	}
+	if config.MaxSize <= 0 {
+		return nil, &AppError{
+			Code:    "INVALID_CONFIG",
+			Message: "cache max size must be positive",
+		}
+	}
+
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, &AppError{
+			Code:    "INIT_ERROR",
+			Message: "failed to create cache directory",
+			Err:     err,
+		}
+	}
+
+	// The index is kept in memory, so entries left by a previous run are unusable
+	stale, _ := filepath.Glob(filepath.Join(config.Directory, "*"+cacheFileSuffix))
+	for _, path := range stale {
+		os.Remove(path)
+	}
+
+	cb := &CachingBackend{
+		backend:  backend,
+		config:   config,
+		metrics:  metrics,
+		logger:   logger,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+		inflight: make(map[cacheKey]*cacheFill),
+	}
+	cb.reportSize()
+
+	return cb, nil
+}
+
+// Name returns the backend name
+func (cb *CachingBackend) Name() string {
+	return cb.backend.Name() + "-with-cache"
+}
+
+// WriteObject writes through to the wrapped backend and invalidates cached data
+func (cb *CachingBackend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	written, err := cb.backend.WriteObject(ctx, bucket, key, reader, size)
+	cb.Invalidate(bucket, key)
+	return written, err
+}
+
+// ReadObject reads an object, serving it from the cache when possible
+func (cb *CachingBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	id := wholeObject(bucket, key)
+
+	entry, err := cb.lookup(ctx, id, "read")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		entry, err = cb.fill(ctx, id, func(ctx context.Context) (io.ReadCloser, error) {
+			return cb.backend.ReadObject(ctx, bucket, key)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return cb.backend.ReadObject(ctx, bucket, key)
+		}
+	}
+
+	if reader, err := cb.open(entry, 0, entry.size); err == nil {
+		return reader, nil
+	}
+	return cb.backend.ReadObject(ctx, bucket, key)
+}
+
+// ReadObjectRange reads a byte range, serving it from a cached whole object or range
+func (cb *CachingBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	// A cached whole object can serve any range without touching the origin
+	if entry, err := cb.lookup(ctx, wholeObject(bucket, key), "read_range"); err != nil {
+		return nil, err
+	} else if entry != nil {
+		length, err := resolveRange(offset, length, entry.size)
+		if err != nil {
+			return nil, err
+		}
+		if reader, err := cb.open(entry, offset, length); err == nil {
+			return reader, nil
+		}
+		return cb.backend.ReadObjectRange(ctx, bucket, key, offset, length)
+	}
+
+	id := cacheKey{bucket: bucket, key: key, ranged: true, offset: offset, length: length}
+
+	entry, err := cb.lookup(ctx, id, "read_range")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		entry, err = cb.fill(ctx, id, func(ctx context.Context) (io.ReadCloser, error) {
+			return cb.backend.ReadObjectRange(ctx, bucket, key, offset, length)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return cb.backend.ReadObjectRange(ctx, bucket, key, offset, length)
+		}
+	}
+
+	if reader, err := cb.open(entry, 0, entry.size); err == nil {
+		return reader, nil
+	}
+	return cb.backend.ReadObjectRange(ctx, bucket, key, offset, length)
+}
+
+// DeleteObject deletes from the wrapped backend and invalidates cached data
+func (cb *CachingBackend) DeleteObject(ctx context.Context, bucket, key string) error {
+	err := cb.backend.DeleteObject(ctx, bucket, key)
+	cb.Invalidate(bucket, key)
+	return err
+}
+
+// ObjectExists checks if an object exists in the wrapped backend
+func (cb *CachingBackend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	return cb.backend.ObjectExists(ctx, bucket, key)
+}
+
+// CreateBucket creates a bucket in the wrapped backend
+func (cb *CachingBackend) CreateBucket(ctx context.Context, bucket string) error {
+	return cb.backend.CreateBucket(ctx, bucket)
+}
+
+// DeleteBucket deletes a bucket in the wrapped backend
+func (cb *CachingBackend) DeleteBucket(ctx context.Context, bucket string) error {
+	return cb.backend.DeleteBucket(ctx, bucket)
+}
+
+// BucketExists checks if a bucket exists in the wrapped backend
+func (cb *CachingBackend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return cb.backend.BucketExists(ctx, bucket)
+}
+
+// GetObjectSize returns the object size from the wrapped backend
+func (cb *CachingBackend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	return cb.backend.GetObjectSize(ctx, bucket, key)
+}
+
+// GetObjectHash returns the object hash, using a cached whole object when available
+func (cb *CachingBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	cb.mu.Lock()
+	elem, ok := cb.entries[wholeObject(bucket, key)]
+	cb.mu.Unlock()
+	if ok && !cb.config.Revalidate {
+		return elem.Value.(*cacheEntry).sha256, nil
+	}
+	return cb.backend.GetObjectHash(ctx, bucket, key)
+}
+
+// GetObjectETag returns the object ETag from the wrapped backend
+func (cb *CachingBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	return cb.backend.GetObjectETag(ctx, bucket, key)
+}
+
+// HealthCheck checks the wrapped backend and the cache directory
+func (cb *CachingBackend) HealthCheck(ctx context.Context) error {
+	if _, err := os.Stat(cb.config.Directory); err != nil {
+		return &AppError{
+			Code:    "HEALTH_CHECK_FAILED",
+			Message: "cache directory not accessible",
+			Err:     err,
+		}
+	}
+	return cb.backend.HealthCheck(ctx)
+}
+
+// Invalidate removes all cached entries (whole object and ranges) for a key
+func (cb *CachingBackend) Invalidate(bucket, key string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for id, elem := range cb.entries {
+		if id.bucket == bucket && id.key == key {
+			cb.removeLocked(elem)
+		}
+	}
+	for id, call := range cb.inflight {
+		if id.bucket == bucket && id.key == key {
+			call.stale = true
+		}
+	}
+	cb.reportSize()
+}
+
+// Size returns the total size of cached data in bytes
+func (cb *CachingBackend) Size() int64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.size
+}
+
+// Helper methods
+
+// lookup returns a valid cached entry, or nil on a miss
+func (cb *CachingBackend) lookup(ctx context.Context, id cacheKey, operation string) (*cacheEntry, error) {
+	cb.mu.Lock()
+	elem, ok := cb.entries[id]
+	if ok {
+		cb.lru.MoveToFront(elem)
+	}
+	cb.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*cacheEntry)
+
+	if cb.config.Revalidate && entry.etag != "" {
+		etag, err := cb.backend.GetObjectETag(ctx, entry.bucket, entry.key)
+		if err != nil {
+			if appErr, ok := err.(*AppError); ok && appErr.Code == "NOT_FOUND" {
+				cb.evict(entry)
+				return nil, err
+			}
+			// Serve the cached copy if the origin is unreachable
+			cb.logger.Warn().Err(err).Str("bucket", entry.bucket).Str("key", entry.key).Msg("cache revalidation failed, serving cached copy")
+		} else if etag != entry.etag {
+			cb.evict(entry)
+			return nil, nil
+		}
+	}
+
+	cb.recordHit(operation)
+	return entry, nil
+}
+
+// fill fetches data from the origin into the cache, coalescing concurrent misses.
+// A nil entry without error means the caller should read from the origin directly.
+func (cb *CachingBackend) fill(ctx context.Context, id cacheKey, fetch func(ctx context.Context) (io.ReadCloser, error)) (*cacheEntry, error) {
+	operation := "read"
+	if id.ranged {
+		operation = "read_range"
+	}
+
+	cb.mu.Lock()
+	if call, ok := cb.inflight[id]; ok {
+		cb.mu.Unlock()
+		select {
+		case <-call.done:
+			if call.err != nil || call.entry.transient {
+				// The shared fetch is not reusable; fall back to the origin
+				return nil, nil
+			}
+			cb.recordHit(operation)
+			return call.entry, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &cacheFill{done: make(chan struct{})}
+	cb.inflight[id] = call
+	cb.mu.Unlock()
+
+	cb.recordMiss(operation)
+	call.entry, call.err = cb.fetch(ctx, id, fetch)
+
+	cb.mu.Lock()
+	delete(cb.inflight, id)
+	if call.err == nil {
+		if call.stale || call.entry.size > cb.config.MaxSize {
+			call.entry.transient = true
+		} else {
+			cb.insertLocked(call.entry)
+		}
+	}
+	cb.mu.Unlock()
+	close(call.done)
+
+	return call.entry, call.err
+}
+
+// fetch streams data from the origin into a new cache file
+func (cb *CachingBackend) fetch(ctx context.Context, id cacheKey, fetch func(ctx context.Context) (io.ReadCloser, error)) (*cacheEntry, error) {
+	var etag string
+	if cb.config.Revalidate {
+		e, err := cb.backend.GetObjectETag(ctx, id.bucket, id.key)
+		if err != nil {
+			return nil, err
+		}
+		etag = e
+	}
+
+	reader, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	file, err := os.CreateTemp(cb.config.Directory, id.fileName()+"-*"+cacheFileSuffix)
+	if err != nil {
+		return nil, &AppError{
+			Code:    "WRITE_ERROR",
+			Message: "failed to create cache file",
+			Err:     err,
+		}
+	}
+
+	path := file.Name()
+
+	hasher := sha256.New()
+	size, err := io.Copy(file, io.TeeReader(reader, hasher))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &cacheEntry{
+		id:     id,
+		bucket: id.bucket,
+		key:    id.key,
+		path:   path,
+		size:   size,
+		sha256: hex.EncodeToString(hasher.Sum(nil)),
+		etag:   etag,
+	}, nil
+}
+
+// open opens a cached entry, optionally restricted to a byte range. An entry
+// whose file cannot be opened is evicted, and callers read from the origin.
+func (cb *CachingBackend) open(entry *cacheEntry, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(entry.path)
+	if entry.transient {
+		os.Remove(entry.path)
+	}
+	if err != nil {
+		cb.evict(entry)
+		cb.logger.Warn().Err(err).Str("bucket", entry.bucket).Str("key", entry.key).Msg("cache file unreadable, reading from origin")
+		return nil, &AppError{
+			Code:    "READ_ERROR",
+			Message: "failed to open cache file",
+			Err:     err,
+		}
+	}
+
+	// Full reads can be verified while streaming; partial reads cannot
+	if offset == 0 && length >= entry.size {
+		if cb.config.Verify {
+			return &cacheVerifyingReader{
+				checksumVerifyingReader: checksumVerifyingReader{reader: file, expectedSum: entry.sha256},
+				onMismatch:              func() { cb.evict(entry) },
+			}, nil
+		}
+		return file, nil
+	}
+
+	return &limitedReadCloser{
+		reader: io.NewSectionReader(file, offset, length),
+		closer: file,
+	}, nil
+}
+
+// insertLocked adds an entry and evicts least recently used entries over the limit
+func (cb *CachingBackend) insertLocked(entry *cacheEntry) {
+	if elem, ok := cb.entries[entry.id]; ok {
+		cb.removeLocked(elem)
+	}
+
+	cb.entries[entry.id] = cb.lru.PushFront(entry)
+	cb.size += entry.size
+
+	for cb.size > cb.config.MaxSize {
+		cb.removeLocked(cb.lru.Back())
+	}
+	cb.reportSize()
+}
+
+// evict removes an entry unless it has already been replaced by a refill
+func (cb *CachingBackend) evict(entry *cacheEntry) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if elem, ok := cb.entries[entry.id]; ok && elem.Value == entry {
+		cb.removeLocked(elem)
+		cb.reportSize()
+	}
+}
+
+// removeLocked removes an entry from the index and deletes its file.
+// Readers holding the file open keep working until they close it.
+func (cb *CachingBackend) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	cb.lru.Remove(elem)
+	delete(cb.entries, entry.id)
+	cb.size -= entry.size
+	os.Remove(entry.path)
+}
+
+// cacheMetrics returns the configured metrics sink, falling back to the
+// package default
+func (cb *CachingBackend) cacheMetrics() CacheMetrics {
+	if cb.metrics != nil {
+		return cb.metrics
+	}
+	defaultCacheMetricsMu.RLock()
+	defer defaultCacheMetricsMu.RUnlock()
+	return defaultCacheMetrics
+}
+
+func (cb *CachingBackend) recordHit(operation string) {
+	if metrics := cb.cacheMetrics(); metrics != nil {
+		metrics.RecordCacheHit(cb.backend.Name(), operation)
+	}
+}
+
+func (cb *CachingBackend) recordMiss(operation string) {
+	if metrics := cb.cacheMetrics(); metrics != nil {
+		metrics.RecordCacheMiss(cb.backend.Name(), operation)
+	}
+}
+
+func (cb *CachingBackend) reportSize() {
+	if metrics := cb.cacheMetrics(); metrics != nil {
+		metrics.SetCacheSize(cb.backend.Name(), cb.size)
+	}
+}
+
+func wholeObject(bucket, key string) cacheKey {
+	return cacheKey{bucket: bucket, key: key}
+}
+
+// fileName returns a file name prefix for the key. The hashed fields are
+// length-prefixed so distinct keys never produce the same input.
+func (k cacheKey) fileName() string {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%d:%s%d:%s", len(k.bucket), k.bucket, len(k.key), k.key)
+	if k.ranged {
+		fmt.Fprintf(hasher, "@%d+%d", k.offset, k.length)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// cacheVerifyingReader verifies a cached file and evicts it on mismatch
+type cacheVerifyingReader struct {
+	checksumVerifyingReader
+	onMismatch func()
+}
+
+func (cvr *cacheVerifyingReader) Read(p []byte) (int, error) {
+	n, err := cvr.checksumVerifyingReader.Read(p)
+	if appErr, ok := err.(*AppError); ok && appErr.Code == "CHECKSUM_MISMATCH" {
+		cvr.onMismatch()
+	}
+	return n, err
+}
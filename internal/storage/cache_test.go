@@ -0,0 +1,385 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"gopkg.in/yaml.v2"
+)
+
+// countingBackend counts reads and hash requests that reach the wrapped backend
+type countingBackend struct {
+	storage.Backend
+	reads  atomic.Int64
+	hashes atomic.Int64
+}
+
+func (c *countingBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	c.hashes.Add(1)
+	return c.Backend.GetObjectHash(ctx, bucket, key)
+}
+
+func (c *countingBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	c.reads.Add(1)
+	return c.Backend.ReadObject(ctx, bucket, key)
+}
+
+func (c *countingBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	c.reads.Add(1)
+	return c.Backend.ReadObjectRange(ctx, bucket, key, offset, length)
+}
+
+// cacheMetricsRecorder records cache metrics in memory
+type cacheMetricsRecorder struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	size   atomic.Int64
+}
+
+func (m *cacheMetricsRecorder) RecordCacheHit(cache, operation string)  { m.hits.Add(1) }
+func (m *cacheMetricsRecorder) RecordCacheMiss(cache, operation string) { m.misses.Add(1) }
+func (m *cacheMetricsRecorder) SetCacheSize(cache string, bytes int64)  { m.size.Store(bytes) }
+
+// contentReader returns a helper that reads a backend reader to a string
+func contentReader(t *testing.T) func(io.ReadCloser, error) string {
+	return func(reader io.ReadCloser, err error) string {
+		t.Helper()
+		test.AssertNoError(t, err, "open reader")
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		test.AssertNoError(t, err, "read content")
+		return string(data)
+	}
+}
+
+func TestCachingBackend(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cache-backend-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fs, err := storage.NewFileSystemBackend(tmpDir+"/origin", true)
+	if err != nil {
+		t.Fatalf("failed to create filesystem backend: %v", err)
+	}
+	origin := &countingBackend{Backend: fs}
+	recorder := &cacheMetricsRecorder{}
+
+	cache, err := storage.NewCachingBackend(origin, &storage.CacheConfig{
+		Directory: tmpDir + "/cache",
+		MaxSize:   64,
+		Verify:    true,
+	}, recorder, test.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create caching backend: %v", err)
+	}
+
+	ctx := context.Background()
+	bucket := "test-bucket"
+	content := []byte("cached content")
+
+	test.AssertNoError(t, cache.CreateBucket(ctx, bucket), "create bucket")
+	_, err = cache.WriteObject(ctx, bucket, "a.txt", bytes.NewReader(content), int64(len(content)))
+	test.AssertNoError(t, err, "write object")
+
+	t.Run("Backend name", func(t *testing.T) {
+		test.AssertEqual(t, "filesystem-with-cache", cache.Name(), "backend name")
+	})
+
+	t.Run("Second read is served from cache", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: An object that has not been read yet
+		before := origin.reads.Load()
+
+		// When: Reading it twice
+		first := readAll(cache.ReadObject(ctx, bucket, "a.txt"))
+		second := readAll(cache.ReadObject(ctx, bucket, "a.txt"))
+
+		// Then: Only the first read reaches the origin
+		test.AssertEqual(t, string(content), first, "first read")
+		test.AssertEqual(t, string(content), second, "second read")
+		test.AssertEqual(t, before+1, origin.reads.Load(), "origin reads")
+		test.AssertEqual(t, int64(len(content)), cache.Size(), "cache size")
+		test.AssertEqual(t, int64(len(content)), recorder.size.Load(), "reported cache size")
+		test.AssertTrue(t, recorder.hits.Load() >= 1, "cache hit recorded")
+	})
+
+	t.Run("Range is served from cached whole object", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: The whole object is cached
+		before := origin.reads.Load()
+
+		// When: Reading a range
+		data := readAll(cache.ReadObjectRange(ctx, bucket, "a.txt", 7, 7))
+
+		// Then: The range comes from the cache
+		test.AssertEqual(t, "content", data, "range content")
+		test.AssertEqual(t, before, origin.reads.Load(), "origin reads")
+	})
+
+	t.Run("Write invalidates cached object", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: A cached object
+		updated := []byte("updated")
+
+		// When: Overwriting it
+		_, err := cache.WriteObject(ctx, bucket, "a.txt", bytes.NewReader(updated), int64(len(updated)))
+		test.AssertNoError(t, err, "overwrite object")
+
+		// Then: The next read returns the new content
+		test.AssertEqual(t, string(updated), readAll(cache.ReadObject(ctx, bucket, "a.txt")), "content after overwrite")
+	})
+
+	t.Run("Ranges are cached independently", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: An uncached object
+		_, err := cache.WriteObject(ctx, bucket, "b.txt", bytes.NewReader(content), int64(len(content)))
+		test.AssertNoError(t, err, "write object")
+		before := origin.reads.Load()
+
+		// When: Reading the same range twice
+		first := readAll(cache.ReadObjectRange(ctx, bucket, "b.txt", 0, 6))
+		second := readAll(cache.ReadObjectRange(ctx, bucket, "b.txt", 0, 6))
+
+		// Then: Only the first range read reaches the origin
+		test.AssertEqual(t, "cached", first, "first range")
+		test.AssertEqual(t, "cached", second, "second range")
+		test.AssertEqual(t, before+1, origin.reads.Load(), "origin reads")
+	})
+
+	t.Run("Keys resembling ranges do not collide with ranges", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: A cached range and an object whose key looks like that range
+		_, err := cache.WriteObject(ctx, bucket, "e.txt", bytes.NewReader(content), int64(len(content)))
+		test.AssertNoError(t, err, "write object")
+		lookalike := []byte("other")
+		_, err = cache.WriteObject(ctx, bucket, "e.txt#0-6", bytes.NewReader(lookalike), int64(len(lookalike)))
+		test.AssertNoError(t, err, "write lookalike object")
+		test.AssertEqual(t, "cached", readAll(cache.ReadObjectRange(ctx, bucket, "e.txt", 0, 6)), "range")
+
+		// When: Reading the lookalike object
+		data := readAll(cache.ReadObject(ctx, bucket, "e.txt#0-6"))
+
+		// Then: Its own content is returned, not the cached range
+		test.AssertEqual(t, "other", data, "lookalike content")
+
+		// And: Overwriting the lookalike leaves the range cached
+		_, err = cache.WriteObject(ctx, bucket, "e.txt#0-6", bytes.NewReader(lookalike), int64(len(lookalike)))
+		test.AssertNoError(t, err, "rewrite lookalike object")
+		before := origin.reads.Load()
+		readAll(cache.ReadObjectRange(ctx, bucket, "e.txt", 0, 6))
+		test.AssertEqual(t, before, origin.reads.Load(), "origin reads")
+	})
+
+	t.Run("Least recently used entries are evicted", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: Objects whose total size exceeds the cache limit
+		large := bytes.Repeat([]byte("x"), 40)
+		for _, key := range []string{"c.txt", "d.txt"} {
+			_, err := cache.WriteObject(ctx, bucket, key, bytes.NewReader(large), int64(len(large)))
+			test.AssertNoError(t, err, "write object")
+			readAll(cache.ReadObject(ctx, bucket, key))
+		}
+
+		// Then: The cache stays within its limit
+		test.AssertTrue(t, cache.Size() <= 64, "cache size within limit")
+
+		// And: The older object is fetched from the origin again
+		before := origin.reads.Load()
+		readAll(cache.ReadObject(ctx, bucket, "c.txt"))
+		test.AssertEqual(t, before+1, origin.reads.Load(), "origin reads after eviction")
+	})
+
+	t.Run("Objects larger than the cache bypass it", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: An object larger than the cache
+		huge := bytes.Repeat([]byte("y"), 100)
+		_, err := cache.WriteObject(ctx, bucket, "huge.bin", bytes.NewReader(huge), int64(len(huge)))
+		test.AssertNoError(t, err, "write object")
+
+		// When: Reading it
+		data := readAll(cache.ReadObject(ctx, bucket, "huge.bin"))
+
+		// Then: Content is correct and the cache stays within its limit
+		test.AssertEqual(t, string(huge), data, "huge content")
+		test.AssertTrue(t, cache.Size() <= 64, "cache size within limit")
+	})
+
+	t.Run("Delete invalidates cached object", func(t *testing.T) {
+		readAll := contentReader(t)
+
+		// Given: A cached object
+		readAll(cache.ReadObject(ctx, bucket, "d.txt"))
+
+		// When: Deleting it
+		test.AssertNoError(t, cache.DeleteObject(ctx, bucket, "d.txt"), "delete object")
+
+		// Then: Reads fail instead of returning stale data
+		_, err := cache.ReadObject(ctx, bucket, "d.txt")
+		test.AssertError(t, err, "read after delete")
+	})
+}
+
+func TestCachingBackendCoalescesMisses(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "cache-backend-coalesce-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fs, err := storage.NewFileSystemBackend(tmpDir+"/origin", false)
+	if err != nil {
+		t.Fatalf("failed to create filesystem backend: %v", err)
+	}
+	origin := &countingBackend{Backend: fs}
+
+	cache, err := storage.NewCachingBackend(origin, &storage.CacheConfig{
+		Directory: tmpDir + "/cache",
+		MaxSize:   1024 * 1024,
+	}, nil, test.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create caching backend: %v", err)
+	}
+
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("z"), 1024)
+	_, err = fs.WriteObject(ctx, "bucket", "hot.bin", bytes.NewReader(content), int64(len(content)))
+	test.AssertNoError(t, err, "write object")
+
+	// When: Many goroutines read the same uncached object concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader, err := cache.ReadObject(ctx, "bucket", "hot.bin")
+			if err != nil {
+				t.Errorf("read object: %v", err)
+				return
+			}
+			defer reader.Close()
+			data, err := io.ReadAll(reader)
+			if err != nil || !bytes.Equal(data, content) {
+				t.Errorf("unexpected content (%d bytes): %v", len(data), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Then: The origin is read far fewer times than there were requests
+	test.AssertTrue(t, origin.reads.Load() < 20, "concurrent misses should be coalesced")
+}
+
+func TestCachingBackendRevalidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs, err := storage.NewFileSystemBackend(filepath.Join(tmpDir, "origin"), false)
+	test.AssertNoError(t, err, "create filesystem backend")
+	origin := &countingBackend{Backend: fs}
+
+	cache, err := storage.NewCachingBackend(origin, &storage.CacheConfig{
+		Directory:  filepath.Join(tmpDir, "cache"),
+		MaxSize:    1024,
+		Revalidate: true,
+	}, nil, test.NewTestLogger(t))
+	test.AssertNoError(t, err, "create caching backend")
+
+	ctx := context.Background()
+	readAll := contentReader(t)
+	_, err = fs.WriteObject(ctx, "bucket", "app.jar", bytes.NewReader([]byte("version 1")), 9)
+	test.AssertNoError(t, err, "write object")
+
+	// Given: A cached object
+	readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+
+	// When: Reading it again, then after it changed at the origin
+	cached := readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+	_, err = fs.WriteObject(ctx, "bucket", "app.jar", bytes.NewReader([]byte("version 2.0")), 11)
+	test.AssertNoError(t, err, "overwrite object at origin")
+	changed := readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+
+	// Then: Hits are revalidated by ETag without hashing the origin object
+	test.AssertEqual(t, "version 1", cached, "cached content")
+	test.AssertEqual(t, "version 2.0", changed, "content after origin change")
+	test.AssertEqual(t, int64(2), origin.reads.Load(), "origin reads")
+	test.AssertEqual(t, int64(0), origin.hashes.Load(), "origin hash requests")
+}
+
+func TestCachingBackendRecoversLostFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs, err := storage.NewFileSystemBackend(filepath.Join(tmpDir, "origin"), false)
+	test.AssertNoError(t, err, "create filesystem backend")
+	origin := &countingBackend{Backend: fs}
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	cache, err := storage.NewCachingBackend(origin, &storage.CacheConfig{Directory: cacheDir, MaxSize: 1024}, nil, test.NewTestLogger(t))
+	test.AssertNoError(t, err, "create caching backend")
+
+	ctx := context.Background()
+	readAll := contentReader(t)
+	_, err = fs.WriteObject(ctx, "bucket", "app.jar", bytes.NewReader([]byte("content")), 7)
+	test.AssertNoError(t, err, "write object")
+
+	// Given: A cached object whose file was removed from disk
+	readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+	files, err := filepath.Glob(filepath.Join(cacheDir, "*.cache"))
+	test.AssertNoError(t, err, "listing cache files")
+	test.AssertEqual(t, 1, len(files), "cache files")
+	test.AssertNoError(t, os.Remove(files[0]), "removing cache file")
+
+	// When: Reading it three times
+	first := readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+	second := readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+	third := readAll(cache.ReadObject(ctx, "bucket", "app.jar"))
+
+	// Then: The lost entry is evicted and read from the origin, the next
+	// read refills the cache and the last one is a hit
+	test.AssertEqual(t, "content", first, "content after losing the file")
+	test.AssertEqual(t, "content", second, "content of the refill")
+	test.AssertEqual(t, "content", third, "content from the refilled cache")
+	test.AssertEqual(t, int64(3), origin.reads.Load(), "origin reads")
+}
+
+func TestCachingBackendConfig(t *testing.T) {
+	t.Run("Missing directory", func(t *testing.T) {
+		_, err := storage.NewCachingBackend(nil, &storage.CacheConfig{MaxSize: 1}, nil, test.NewTestLogger(t))
+		test.AssertError(t, err, "missing directory")
+	})
+
+	t.Run("Non-positive size", func(t *testing.T) {
+		_, err := storage.NewCachingBackend(nil, &storage.CacheConfig{Directory: t.TempDir()}, nil, test.NewTestLogger(t))
+		test.AssertError(t, err, "non-positive size")
+	})
+
+	t.Run("Backend configuration enables the cache", func(t *testing.T) {
+		// Given: A backend configuration with a cache section
+		dir := t.TempDir()
+		data := []byte("type: filesystem\nfilesystem:\n  rootDirectory: " + dir + "/origin\ncache:\n  directory: " + dir + "/cache\n  maxSize: 1024\n")
+		var config storage.BackendConfig
+		test.AssertNoError(t, yaml.Unmarshal(data, &config), "decode config")
+
+		// When: Creating the backend
+		backend, err := storage.NewBackend(&config)
+
+		// Then: It is wrapped in a caching backend
+		test.AssertNoError(t, err, "create backend")
+		_, ok := backend.(*storage.CachingBackend)
+		test.AssertTrue(t, ok, "caching backend")
+		test.AssertEqual(t, int64(1024), config.Cache.MaxSize, "max size")
+	})
+}
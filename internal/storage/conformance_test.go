@@ -0,0 +1,98 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/internal/storage/storagetest"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestBackendConformance(t *testing.T) {
+	t.Run("filesystem", func(t *testing.T) {
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			backend, err := storage.NewFileSystemBackend(t.TempDir(), true)
+			test.AssertNoError(t, err, "create filesystem backend")
+			return backend
+		}, storagetest.Capabilities{})
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			return storage.NewMemoryBackend(nil)
+		}, storagetest.Capabilities{})
+	})
+
+	t.Run("memory-with-cache", func(t *testing.T) {
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			backend, err := storage.NewCachingBackend(storage.NewMemoryBackend(nil), &storage.CacheConfig{
+				Directory: filepath.Join(t.TempDir(), "cache"),
+				MaxSize:   1024 * 1024,
+				Verify:    true,
+			}, nil, test.NewTestLogger(t))
+			test.AssertNoError(t, err, "create caching backend")
+			return backend
+		}, storagetest.Capabilities{})
+	})
+
+	// Cloud backends run against local emulators when configured. The provider
+	// bucket or container must already exist.
+
+	t.Run("s3", func(t *testing.T) {
+		endpoint := os.Getenv("ASTORE_TEST_S3_ENDPOINT") // e.g. localhost:9000 for MinIO
+		if endpoint == "" {
+			t.Skip("ASTORE_TEST_S3_ENDPOINT not set")
+		}
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			backend, err := storage.NewS3Backend(&storage.S3Config{
+				Endpoint:        endpoint,
+				Region:          envOrDefault("ASTORE_TEST_S3_REGION", "us-east-1"),
+				Bucket:          envOrDefault("ASTORE_TEST_S3_BUCKET", "astore-conformance"),
+				AccessKeyID:     envOrDefault("ASTORE_TEST_S3_ACCESS_KEY", "minioadmin"),
+				SecretAccessKey: envOrDefault("ASTORE_TEST_S3_SECRET_KEY", "minioadmin"),
+			}, true)
+			test.AssertNoError(t, err, "create s3 backend")
+			return backend
+		}, storagetest.Capabilities{PrefixBuckets: true})
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		endpoint := os.Getenv("ASTORE_TEST_AZURE_ENDPOINT") // e.g. http://127.0.0.1:10000/devstoreaccount1/ for Azurite
+		if endpoint == "" {
+			t.Skip("ASTORE_TEST_AZURE_ENDPOINT not set")
+		}
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			backend, err := storage.NewAzureBackend(&storage.AzureConfig{
+				Endpoint:      endpoint,
+				AccountName:   envOrDefault("ASTORE_TEST_AZURE_ACCOUNT", "devstoreaccount1"),
+				AccountKey:    os.Getenv("ASTORE_TEST_AZURE_KEY"),
+				ContainerName: envOrDefault("ASTORE_TEST_AZURE_CONTAINER", "astore-conformance"),
+			}, true)
+			test.AssertNoError(t, err, "create azure backend")
+			return backend
+		}, storagetest.Capabilities{PrefixBuckets: true})
+	})
+
+	t.Run("gcs", func(t *testing.T) {
+		// The GCS client uses fake-gcs-server when STORAGE_EMULATOR_HOST is set
+		if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+			t.Skip("STORAGE_EMULATOR_HOST not set")
+		}
+		storagetest.RunBackendConformance(t, func(t *testing.T) storage.Backend {
+			backend, err := storage.NewGCSBackend(&storage.GCSConfig{
+				Bucket: envOrDefault("ASTORE_TEST_GCS_BUCKET", "astore-conformance"),
+			}, true)
+			test.AssertNoError(t, err, "create gcs backend")
+			return backend
+		}, storagetest.Capabilities{PrefixBuckets: true})
+	})
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -18,6 +18,29 @@ type FileSystemBackend struct {
 	mu             sync.RWMutex
 }
 
+// FileSystemConfig configures the filesystem backend
+type FileSystemConfig struct {
+	RootDirectory string `json:"rootDirectory" yaml:"rootDirectory"`
+}
+
+// Validate checks the filesystem configuration
+func (c *FileSystemConfig) Validate() error {
+	if c.RootDirectory == "" {
+		return fmt.Errorf("rootDirectory is required")
+	}
+	return nil
+}
+
+func init() {
+	RegisterBackend(BackendRegistration{
+		Name:        "filesystem",
+		NewSettings: func() BackendSettings { return &FileSystemConfig{} },
+		Factory: func(config *BackendConfig) (Backend, error) {
+			return NewFileSystemBackend(config.Settings.(*FileSystemConfig).RootDirectory, config.EnableChecksum)
+		},
+	})
+}
+
 // NewFileSystemBackend creates a new filesystem storage backend
 func NewFileSystemBackend(rootDir string, enableChecksum bool) (*FileSystemBackend, error) {
 	if rootDir == "" {
@@ -49,6 +72,10 @@ func (fs *FileSystemBackend) Name() string {
 
 // WriteObject writes an object to the filesystem
 func (fs *FileSystemBackend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -125,6 +152,10 @@ func (fs *FileSystemBackend) WriteObject(ctx context.Context, bucket, key string
 
 // ReadObject reads an object from the filesystem
 func (fs *FileSystemBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -159,6 +190,10 @@ func (fs *FileSystemBackend) ReadObject(ctx context.Context, bucket, key string)
 
 // ReadObjectRange reads a byte range from an object
 func (fs *FileSystemBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -180,6 +215,22 @@ func (fs *FileSystemBackend) ReadObjectRange(ctx context.Context, bucket, key st
 		}
 	}
 
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, &AppError{
+			Code:    "READ_ERROR",
+			Message: "failed to stat object",
+			Err:     err,
+		}
+	}
+
+	length, err = resolveRange(offset, length, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	// Seek to offset
 	if _, err := file.Seek(offset, io.SeekStart); err != nil {
 		file.Close()
@@ -201,6 +252,10 @@ func (fs *FileSystemBackend) ReadObjectRange(ctx context.Context, bucket, key st
 
 // DeleteObject deletes an object from the filesystem
 func (fs *FileSystemBackend) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := validateObjectName(bucket, key); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -230,6 +285,10 @@ func (fs *FileSystemBackend) DeleteObject(ctx context.Context, bucket, key strin
 
 // ObjectExists checks if an object exists
 func (fs *FileSystemBackend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return false, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -250,6 +309,10 @@ func (fs *FileSystemBackend) ObjectExists(ctx context.Context, bucket, key strin
 
 // CreateBucket creates a new bucket directory
 func (fs *FileSystemBackend) CreateBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -268,6 +331,10 @@ func (fs *FileSystemBackend) CreateBucket(ctx context.Context, bucket string) er
 
 // DeleteBucket deletes a bucket directory (must be empty)
 func (fs *FileSystemBackend) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
@@ -310,6 +377,10 @@ func (fs *FileSystemBackend) DeleteBucket(ctx context.Context, bucket string) er
 
 // BucketExists checks if a bucket exists
 func (fs *FileSystemBackend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return false, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -331,6 +402,10 @@ func (fs *FileSystemBackend) BucketExists(ctx context.Context, bucket string) (b
 
 // GetObjectSize returns the size of an object
 func (fs *FileSystemBackend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
@@ -354,8 +429,40 @@ func (fs *FileSystemBackend) GetObjectSize(ctx context.Context, bucket, key stri
 	return info.Size(), nil
 }
 
+// GetObjectETag returns a tag derived from the object's modification time and size
+func (fs *FileSystemBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	info, err := os.Stat(fs.getObjectPath(bucket, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", &AppError{
+				Code:    "NOT_FOUND",
+				Message: "object not found",
+				Err:     err,
+			}
+		}
+		return "", &AppError{
+			Code:    "STAT_ERROR",
+			Message: "failed to stat object",
+			Err:     err,
+		}
+	}
+
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()), nil
+}
+
 // GetObjectHash returns the SHA256 hash of an object
 func (fs *FileSystemBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
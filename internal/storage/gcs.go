@@ -19,20 +19,46 @@ type GCSBackend struct {
 	enableChecksum bool
 }
 
+// GCSConfig configures the Google Cloud Storage backend
+type GCSConfig struct {
+	Bucket          string `json:"bucket" yaml:"bucket"`
+	CredentialsFile string `json:"credentialsFile" yaml:"credentialsFile"` // Defaults to application default credentials
+	ProjectID       string `json:"projectId" yaml:"projectId"`
+}
+
+// Validate checks the GCS configuration
+func (c *GCSConfig) Validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	return nil
+}
+
+func init() {
+	RegisterBackend(BackendRegistration{
+		Name:        "gcs",
+		NewSettings: func() BackendSettings { return &GCSConfig{} },
+		Factory: func(config *BackendConfig) (Backend, error) {
+			return NewGCSBackend(config.Settings.(*GCSConfig), config.EnableChecksum)
+		},
+	})
+}
+
 // NewGCSBackend creates a new Google Cloud Storage backend
-func NewGCSBackend(config *BackendConfig) (*GCSBackend, error) {
-	if config.GCSBucket == "" {
+func NewGCSBackend(config *GCSConfig, enableChecksum bool) (*GCSBackend, error) {
+	if err := config.Validate(); err != nil {
 		return nil, &AppError{
 			Code:    "INVALID_CONFIG",
-			Message: "GCS bucket name is required",
+			Message: "invalid gcs backend configuration",
+			Err:     err,
 		}
 	}
 
 	var opts []option.ClientOption
 
 	// Add credentials file if provided
-	if config.GCSCredentialsFile != "" {
-		opts = append(opts, option.WithCredentialsFile(config.GCSCredentialsFile))
+	if config.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(config.CredentialsFile))
 	}
 
 	ctx := context.Background()
@@ -47,8 +73,8 @@ func NewGCSBackend(config *BackendConfig) (*GCSBackend, error) {
 
 	return &GCSBackend{
 		client:         client,
-		bucketName:     config.GCSBucket,
-		enableChecksum: config.EnableChecksum,
+		bucketName:     config.Bucket,
+		enableChecksum: enableChecksum,
 	}, nil
 }
 
@@ -59,6 +85,10 @@ func (gcs *GCSBackend) Name() string {
 
 // WriteObject writes an object to GCS
 func (gcs *GCSBackend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -114,6 +144,10 @@ func (gcs *GCSBackend) WriteObject(ctx context.Context, bucket, key string, read
 
 // ReadObject reads an object from GCS
 func (gcs *GCSBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -151,6 +185,19 @@ func (gcs *GCSBackend) ReadObject(ctx context.Context, bucket, key string) (io.R
 
 // ReadObjectRange reads a byte range from an object in GCS
 func (gcs *GCSBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	size, err := gcs.GetObjectSize(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	length, err = resolveRange(offset, length, size)
+	if err != nil {
+		return nil, err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -175,6 +222,10 @@ func (gcs *GCSBackend) ReadObjectRange(ctx context.Context, bucket, key string,
 
 // DeleteObject deletes an object from GCS
 func (gcs *GCSBackend) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := validateObjectName(bucket, key); err != nil {
+		return err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -198,6 +249,10 @@ func (gcs *GCSBackend) DeleteObject(ctx context.Context, bucket, key string) err
 
 // ObjectExists checks if an object exists in GCS
 func (gcs *GCSBackend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return false, err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -218,6 +273,10 @@ func (gcs *GCSBackend) ObjectExists(ctx context.Context, bucket, key string) (bo
 
 // CreateBucket creates a new GCS bucket (no-op for prefix-based buckets)
 func (gcs *GCSBackend) CreateBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In GCS backend, we use prefixes within a single bucket
 	// So this is a no-op
 	return nil
@@ -225,6 +284,10 @@ func (gcs *GCSBackend) CreateBucket(ctx context.Context, bucket string) error {
 
 // DeleteBucket deletes a GCS bucket (no-op for prefix-based buckets)
 func (gcs *GCSBackend) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In GCS backend, we use prefixes within a single bucket
 	// So this is a no-op
 	return nil
@@ -232,6 +295,10 @@ func (gcs *GCSBackend) DeleteBucket(ctx context.Context, bucket string) error {
 
 // BucketExists checks if a bucket exists
 func (gcs *GCSBackend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return false, err
+	}
+
 	// Check if the main GCS bucket exists
 	gcsBucket := gcs.client.Bucket(gcs.bucketName)
 	_, err := gcsBucket.Attrs(ctx)
@@ -251,6 +318,10 @@ func (gcs *GCSBackend) BucketExists(ctx context.Context, bucket string) (bool, e
 
 // GetObjectSize returns the size of an object in GCS
 func (gcs *GCSBackend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -275,6 +346,10 @@ func (gcs *GCSBackend) GetObjectSize(ctx context.Context, bucket, key string) (i
 
 // GetObjectHash returns the SHA256 hash of an object
 func (gcs *GCSBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
 	objectKey := gcs.getObjectKey(bucket, key)
 	obj := gcs.client.Bucket(gcs.bucketName).Object(objectKey)
 
@@ -321,6 +396,32 @@ func (gcs *GCSBackend) GetObjectHash(ctx context.Context, bucket, key string) (s
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// GetObjectETag returns the ETag of an object
+func (gcs *GCSBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	obj := gcs.client.Bucket(gcs.bucketName).Object(gcs.getObjectKey(bucket, key))
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return "", &AppError{
+				Code:    "NOT_FOUND",
+				Message: "object not found",
+				Err:     err,
+			}
+		}
+		return "", &AppError{
+			Code:    "STAT_ERROR",
+			Message: "failed to get object metadata",
+			Err:     err,
+		}
+	}
+
+	return attrs.Etag, nil
+}
+
 // HealthCheck performs a health check on GCS
 func (gcs *GCSBackend) HealthCheck(ctx context.Context) error {
 	// Try to list objects (with max 1 result)
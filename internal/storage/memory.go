@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FaultConfig configures fault injection for the in-memory backend
+type FaultConfig struct {
+	Latency         time.Duration `json:"latency" yaml:"latency"`                 // Delay added to every operation
+	ErrorRate       float64       `json:"errorRate" yaml:"errorRate"`             // Probability (0-1) an operation fails
+	PartialReadRate float64       `json:"partialReadRate" yaml:"partialReadRate"` // Probability (0-1) a reader stops early
+	Operations      []string      `json:"operations" yaml:"operations"`           // Limits faults to these operations; empty means all
+	Seed            int64         `json:"seed" yaml:"seed"`                       // Random seed for reproducible faults
+}
+
+// MemoryConfig configures the in-memory backend
+type MemoryConfig struct {
+	Faults FaultConfig `json:"faults" yaml:"faults"`
+}
+
+// Validate checks the in-memory backend configuration
+func (c *MemoryConfig) Validate() error {
+	if c.Faults.Latency < 0 {
+		return fmt.Errorf("faults.latency cannot be negative")
+	}
+	if c.Faults.ErrorRate < 0 || c.Faults.ErrorRate > 1 {
+		return fmt.Errorf("faults.errorRate must be between 0 and 1")
+	}
+	if c.Faults.PartialReadRate < 0 || c.Faults.PartialReadRate > 1 {
+		return fmt.Errorf("faults.partialReadRate must be between 0 and 1")
+	}
+	return nil
+}
+
+func init() {
+	RegisterBackend(BackendRegistration{
+		Name:        "memory",
+		NewSettings: func() BackendSettings { return &MemoryConfig{} },
+		Factory: func(config *BackendConfig) (Backend, error) {
+			faults := config.Settings.(*MemoryConfig).Faults
+			return NewMemoryBackend(&faults), nil
+		},
+	})
+}
+
+// MemoryBackend implements Backend in memory. It is safe for concurrent use
+// and can inject latency, errors and truncated reads for testing retry and
+// circuit breaker behaviour.
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*memoryObject
+
+	faultMu   sync.Mutex
+	faults    FaultConfig
+	failNext  int
+	random    *rand.Rand
+	faultOps  map[string]bool
+	faultHits int64
+}
+
+// memoryObject is an object stored by the in-memory backend
+type memoryObject struct {
+	data []byte
+	hash string
+}
+
+// NewMemoryBackend creates a new in-memory backend. faults may be nil.
+func NewMemoryBackend(faults *FaultConfig) *MemoryBackend {
+	m := &MemoryBackend{
+		buckets: make(map[string]map[string]*memoryObject),
+	}
+	m.SetFaults(faults)
+	return m
+}
+
+// SetFaults replaces the fault injection configuration. nil disables faults.
+func (m *MemoryBackend) SetFaults(faults *FaultConfig) {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+
+	m.faults = FaultConfig{}
+	if faults != nil {
+		m.faults = *faults
+	}
+	m.random = rand.New(rand.NewSource(m.faults.Seed))
+	m.faultOps = make(map[string]bool, len(m.faults.Operations))
+	for _, op := range m.faults.Operations {
+		m.faultOps[op] = true
+	}
+}
+
+// FailNext makes the next n faultable operations fail regardless of ErrorRate
+func (m *MemoryBackend) FailNext(n int) {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	m.failNext = n
+}
+
+// InjectedFaults returns how many operations failed due to fault injection
+func (m *MemoryBackend) InjectedFaults() int64 {
+	m.faultMu.Lock()
+	defer m.faultMu.Unlock()
+	return m.faultHits
+}
+
+// Name returns the backend name
+func (m *MemoryBackend) Name() string {
+	return "memory"
+}
+
+// WriteObject stores an object in memory
+func (m *MemoryBackend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
+	if err := m.inject(ctx, "WriteObject"); err != nil {
+		return 0, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, &AppError{
+			Code:    "WRITE_ERROR",
+			Message: "failed to write object",
+			Err:     err,
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	object := &memoryObject{data: data, hash: hex.EncodeToString(sum[:])}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		objects = make(map[string]*memoryObject)
+		m.buckets[bucket] = objects
+	}
+	objects[key] = object
+
+	return int64(len(data)), nil
+}
+
+// ReadObject reads an object from memory
+func (m *MemoryBackend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	if err := m.inject(ctx, "ReadObject"); err != nil {
+		return nil, err
+	}
+
+	object, err := m.getObject(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.reader(object.data), nil
+}
+
+// ReadObjectRange reads a byte range from an object
+func (m *MemoryBackend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	if err := m.inject(ctx, "ReadObjectRange"); err != nil {
+		return nil, err
+	}
+
+	object, err := m.getObject(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err = resolveRange(offset, length, int64(len(object.data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.reader(object.data[offset : offset+length]), nil
+}
+
+// DeleteObject deletes an object from memory
+func (m *MemoryBackend) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := validateObjectName(bucket, key); err != nil {
+		return err
+	}
+
+	if err := m.inject(ctx, "DeleteObject"); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok || objects[key] == nil {
+		return &AppError{
+			Code:    "NOT_FOUND",
+			Message: "object not found",
+		}
+	}
+	delete(objects, key)
+
+	return nil
+}
+
+// ObjectExists checks if an object exists
+func (m *MemoryBackend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return false, err
+	}
+
+	if err := m.inject(ctx, "ObjectExists"); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.buckets[bucket][key]
+	return ok, nil
+}
+
+// CreateBucket creates a new bucket
+func (m *MemoryBackend) CreateBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
+	if err := m.inject(ctx, "CreateBucket"); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.buckets[bucket]; !ok {
+		m.buckets[bucket] = make(map[string]*memoryObject)
+	}
+
+	return nil
+}
+
+// DeleteBucket deletes a bucket (must be empty)
+func (m *MemoryBackend) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
+	if err := m.inject(ctx, "DeleteBucket"); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return &AppError{
+			Code:    "NOT_FOUND",
+			Message: "bucket not found",
+		}
+	}
+	if len(objects) > 0 {
+		return &AppError{
+			Code:    "BUCKET_NOT_EMPTY",
+			Message: "bucket is not empty",
+		}
+	}
+	delete(m.buckets, bucket)
+
+	return nil
+}
+
+// BucketExists checks if a bucket exists
+func (m *MemoryBackend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return false, err
+	}
+
+	if err := m.inject(ctx, "BucketExists"); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.buckets[bucket]
+	return ok, nil
+}
+
+// GetObjectSize returns the size of an object
+func (m *MemoryBackend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
+	if err := m.inject(ctx, "GetObjectSize"); err != nil {
+		return 0, err
+	}
+
+	object, err := m.getObject(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(object.data)), nil
+}
+
+// GetObjectHash returns the SHA256 hash of an object
+func (m *MemoryBackend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	if err := m.inject(ctx, "GetObjectHash"); err != nil {
+		return "", err
+	}
+
+	object, err := m.getObject(bucket, key)
+	if err != nil {
+		return "", err
+	}
+
+	return object.hash, nil
+}
+
+// GetObjectETag returns the object's hash, which changes with its content
+func (m *MemoryBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	if err := m.inject(ctx, "GetObjectETag"); err != nil {
+		return "", err
+	}
+
+	object, err := m.getObject(bucket, key)
+	if err != nil {
+		return "", err
+	}
+
+	return object.hash, nil
+}
+
+// HealthCheck performs a health check
+func (m *MemoryBackend) HealthCheck(ctx context.Context) error {
+	if err := m.inject(ctx, "HealthCheck"); err != nil {
+		return &AppError{
+			Code:    "HEALTH_CHECK_FAILED",
+			Message: "memory backend unhealthy",
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// Helper methods
+
+func (m *MemoryBackend) getObject(bucket, key string) (*memoryObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, &AppError{
+			Code:    "NOT_FOUND",
+			Message: "object not found",
+		}
+	}
+	return object, nil
+}
+
+// inject applies configured latency and decides whether an operation fails
+func (m *MemoryBackend) inject(ctx context.Context, op string) error {
+	m.faultMu.Lock()
+	latency := m.faults.Latency
+	applies := len(m.faultOps) == 0 || m.faultOps[op]
+	fail := false
+	if applies {
+		if m.failNext > 0 {
+			m.failNext--
+			fail = true
+		} else if m.faults.ErrorRate > 0 && m.random.Float64() < m.faults.ErrorRate {
+			fail = true
+		}
+		if fail {
+			m.faultHits++
+		}
+	}
+	m.faultMu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if fail {
+		return &AppError{
+			Code:    "INJECTED_FAULT",
+			Message: "injected fault in " + op,
+		}
+	}
+	return nil
+}
+
+// reader returns a reader over a copy of data, possibly truncated by fault injection
+func (m *MemoryBackend) reader(data []byte) io.ReadCloser {
+	content := make([]byte, len(data))
+	copy(content, data)
+
+	m.faultMu.Lock()
+	partial := m.faults.PartialReadRate > 0 && len(content) > 0 && m.random.Float64() < m.faults.PartialReadRate
+	m.faultMu.Unlock()
+
+	if partial {
+		return io.NopCloser(&partialReader{reader: bytes.NewReader(content[:len(content)/2])})
+	}
+	return io.NopCloser(bytes.NewReader(content))
+}
+
+// partialReader returns io.ErrUnexpectedEOF once its content is exhausted
+type partialReader struct {
+	reader io.Reader
+}
+
+func (p *partialReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,191 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/reliability"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewMemoryBackend(nil)
+	content := []byte("in-memory content")
+
+	test.AssertNoError(t, backend.CreateBucket(ctx, "bucket"), "create bucket")
+	written, err := backend.WriteObject(ctx, "bucket", "obj", bytes.NewReader(content), int64(len(content)))
+	test.AssertNoError(t, err, "write object")
+	test.AssertEqual(t, int64(len(content)), written, "bytes written")
+
+	t.Run("Read object and range", func(t *testing.T) {
+		readAll := contentReader(t)
+		test.AssertEqual(t, string(content), readAll(backend.ReadObject(ctx, "bucket", "obj")), "object content")
+		test.AssertEqual(t, "memory", readAll(backend.ReadObjectRange(ctx, "bucket", "obj", 3, 6)), "range content")
+		test.AssertEqual(t, "content", readAll(backend.ReadObjectRange(ctx, "bucket", "obj", 10, 100)), "range clipped to object")
+	})
+
+	t.Run("Size and hash", func(t *testing.T) {
+		size, err := backend.GetObjectSize(ctx, "bucket", "obj")
+		test.AssertNoError(t, err, "get size")
+		test.AssertEqual(t, int64(len(content)), size, "object size")
+
+		sum := sha256.Sum256(content)
+		hash, err := backend.GetObjectHash(ctx, "bucket", "obj")
+		test.AssertNoError(t, err, "get hash")
+		test.AssertEqual(t, hex.EncodeToString(sum[:]), hash, "object hash")
+	})
+
+	t.Run("Stored data is isolated from callers", func(t *testing.T) {
+		reader, err := backend.ReadObject(ctx, "bucket", "obj")
+		test.AssertNoError(t, err, "read object")
+		data, _ := io.ReadAll(reader)
+		data[0] = 'X'
+
+		test.AssertEqual(t, string(content), contentReader(t)(backend.ReadObject(ctx, "bucket", "obj")), "content unchanged")
+	})
+
+	t.Run("Bucket lifecycle", func(t *testing.T) {
+		err := backend.DeleteBucket(ctx, "bucket")
+		test.AssertError(t, err, "delete non-empty bucket")
+
+		test.AssertNoError(t, backend.DeleteObject(ctx, "bucket", "obj"), "delete object")
+		test.AssertError(t, backend.DeleteObject(ctx, "bucket", "obj"), "delete missing object")
+		test.AssertNoError(t, backend.DeleteBucket(ctx, "bucket"), "delete empty bucket")
+
+		exists, err := backend.BucketExists(ctx, "bucket")
+		test.AssertNoError(t, err, "bucket exists")
+		test.AssertFalse(t, exists, "bucket deleted")
+	})
+
+	t.Run("Concurrent access", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				backend.WriteObject(ctx, "concurrent", "obj", bytes.NewReader(content), int64(len(content)))
+				if reader, err := backend.ReadObject(ctx, "concurrent", "obj"); err == nil {
+					io.Copy(io.Discard, reader)
+				}
+			}()
+		}
+		wg.Wait()
+
+		exists, err := backend.ObjectExists(ctx, "concurrent", "obj")
+		test.AssertNoError(t, err, "object exists")
+		test.AssertTrue(t, exists, "object written")
+	})
+}
+
+func TestMemoryBackendFaults(t *testing.T) {
+	ctx := context.Background()
+	content := []byte("fault injection")
+
+	t.Run("Retry backend recovers from transient faults", func(t *testing.T) {
+		// Given: A backend whose next two operations fail
+		backend := storage.NewMemoryBackend(nil)
+		retrying := storage.NewRetryBackend(backend, &storage.BackendConfig{MaxRetries: 3, RetryDelay: 1}, test.NewTestLogger(t))
+		backend.FailNext(2)
+
+		// When: Writing through the retry backend
+		_, err := retrying.WriteObject(ctx, "bucket", "obj", bytes.NewReader(content), int64(len(content)))
+
+		// Then: The write succeeds after the injected faults
+		test.AssertNoError(t, err, "write with retry")
+		test.AssertEqual(t, int64(2), backend.InjectedFaults(), "injected faults")
+	})
+
+	t.Run("Error rate of one always fails", func(t *testing.T) {
+		backend := storage.NewMemoryBackend(&storage.FaultConfig{ErrorRate: 1})
+		_, err := backend.WriteObject(ctx, "bucket", "obj", bytes.NewReader(content), int64(len(content)))
+		test.AssertError(t, err, "write fails")
+		test.AssertError(t, backend.HealthCheck(ctx), "health check fails")
+	})
+
+	t.Run("Faults can be limited to operations", func(t *testing.T) {
+		backend := storage.NewMemoryBackend(&storage.FaultConfig{ErrorRate: 1, Operations: []string{"ReadObject"}})
+		_, err := backend.WriteObject(ctx, "bucket", "obj", bytes.NewReader(content), int64(len(content)))
+		test.AssertNoError(t, err, "write unaffected")
+
+		_, err = backend.ReadObject(ctx, "bucket", "obj")
+		test.AssertError(t, err, "read fails")
+	})
+
+	t.Run("Same seed produces the same faults", func(t *testing.T) {
+		pattern := func() []bool {
+			backend := storage.NewMemoryBackend(&storage.FaultConfig{ErrorRate: 0.5, Seed: 42})
+			results := make([]bool, 20)
+			for i := range results {
+				results[i] = backend.HealthCheck(ctx) != nil
+			}
+			return results
+		}
+		first, second := pattern(), pattern()
+		for i := range first {
+			test.AssertEqual(t, first[i], second[i], "fault pattern")
+		}
+	})
+
+	t.Run("Partial reads end with unexpected EOF", func(t *testing.T) {
+		backend := storage.NewMemoryBackend(&storage.FaultConfig{PartialReadRate: 1})
+		_, err := backend.WriteObject(ctx, "bucket", "obj", bytes.NewReader(content), int64(len(content)))
+		test.AssertNoError(t, err, "write object")
+
+		reader, err := backend.ReadObject(ctx, "bucket", "obj")
+		test.AssertNoError(t, err, "open reader")
+		data, err := io.ReadAll(reader)
+		test.AssertEqual(t, io.ErrUnexpectedEOF, err, "read error")
+		test.AssertTrue(t, len(data) < len(content), "truncated content")
+	})
+
+	t.Run("Latency respects context cancellation", func(t *testing.T) {
+		backend := storage.NewMemoryBackend(&storage.FaultConfig{Latency: time.Minute})
+		cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := backend.ObjectExists(cancelled, "bucket", "obj")
+		test.AssertError(t, err, "cancelled operation")
+	})
+
+	t.Run("Repeated faults open a circuit breaker", func(t *testing.T) {
+		// Given: A failing backend behind a circuit breaker
+		backend := storage.NewMemoryBackend(&storage.FaultConfig{ErrorRate: 1})
+		breaker := reliability.NewCircuitBreaker(&reliability.CircuitBreakerConfig{
+			MaxFailures:     3,
+			Timeout:         time.Minute,
+			HalfOpenSuccess: 1,
+			HalfOpenMax:     1,
+		}, test.NewTestLogger(t))
+
+		// When: Calls keep failing
+		for i := 0; i < 3; i++ {
+			breaker.Execute(ctx, func(ctx context.Context) error { return backend.HealthCheck(ctx) })
+		}
+
+		// Then: The breaker opens and short-circuits calls
+		faults := backend.InjectedFaults()
+		err := breaker.Execute(ctx, func(ctx context.Context) error { return backend.HealthCheck(ctx) })
+		test.AssertError(t, err, "open breaker")
+		test.AssertEqual(t, faults, backend.InjectedFaults(), "backend not called while open")
+	})
+
+	t.Run("Registered as a backend type", func(t *testing.T) {
+		backend, err := storage.NewBackend(&storage.BackendConfig{Type: "memory"})
+		test.AssertNoError(t, err, "create memory backend")
+		test.AssertEqual(t, "memory", backend.Name(), "backend name")
+
+		_, err = storage.NewBackend(&storage.BackendConfig{
+			Type:     "memory",
+			Settings: &storage.MemoryConfig{Faults: storage.FaultConfig{ErrorRate: 2}},
+		})
+		test.AssertError(t, err, "invalid error rate")
+	})
+}
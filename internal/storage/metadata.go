@@ -1,595 +1,299 @@
 package storage
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/candlekeep/zot-artifact-store/internal/models"
-	bolt "go.etcd.io/bbolt"
 )
 
-var (
-	// Bucket names for BoltDB
-	bucketsBucket        = []byte("buckets")
-	artifactsBucket      = []byte("artifacts")
-	multipartBucket      = []byte("multipart_uploads")
-	uploadProgressBucket = []byte("upload_progress")
-	policiesBucket       = []byte("policies")
-	auditLogsBucket      = []byte("audit_logs")
-	signaturesBucket     = []byte("signatures")
-	sbomsBucket          = []byte("sboms")
-	attestationsBucket   = []byte("attestations")
+// ErrArtifactNotFound is returned when an artifact does not exist
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// MetadataStore manages bucket, artifact, policy, audit and supply-chain metadata.
+// Implementations must be safe for concurrent use.
+type MetadataStore interface {
+	// Close releases the underlying database
+	Close() error
+
+	// Bucket operations
+	CreateBucket(bucket *models.Bucket) error
+	GetBucket(name string) (*models.Bucket, error)
+	ListBuckets() ([]*models.Bucket, error)
+	DeleteBucket(name string) error
+	UpdateBucket(bucket *models.Bucket) error
+
+	// Artifact operations. Deleting an artifact, or overwriting it with
+	// different content, removes its supply-chain records in the same
+	// transaction, archiving them as tombstones if the store is configured to.
+	StoreArtifact(artifact *models.Artifact) error
+	GetArtifact(bucket, key string) (*models.Artifact, error)
+	ListArtifacts(bucket, prefix string, maxKeys int) ([]*models.Artifact, error)
+	DeleteArtifact(bucket, key string) error
+
+	// Artifact search. ScanArtifacts returns up to limit artifacts following
+	// the artifact ID after, in store order, optionally within a bucket and key
+	// prefix. FindArtifacts returns the IDs of artifacts indexed under value in
+	// one of the ArtifactIndex* indexes.
+	ScanArtifacts(bucket, prefix, after string, limit int) ([]*models.Artifact, error)
+	FindArtifacts(index, value string) ([]string, error)
+
+	// Artifact relations link an artifact to a target it depends on. Both
+	// artifacts must exist. Deleting an artifact removes its own relations and
+	// fails with ErrArtifactHasDependents while others relate to it.
+	AddArtifactRelation(relation *models.ArtifactRelation) error
+	RemoveArtifactRelation(artifactID, targetID string, relationType models.RelationType) error
+	ListRelationsFrom(artifactID string) ([]*models.ArtifactRelation, error)
+	ListRelationsTo(targetID string) ([]*models.ArtifactRelation, error)
+
+	// Multipart upload operations
+	CreateMultipartUpload(upload *models.MultipartUpload) error
+	GetMultipartUpload(uploadID string) (*models.MultipartUpload, error)
+	UpdateMultipartUpload(upload *models.MultipartUpload) error
+	DeleteMultipartUpload(uploadID string) error
+
+	// Resumable upload progress, recording the bytes received for an upload
+	CreateUploadProgress(progress *models.UploadProgress) error
+	GetUploadProgress(uploadID string) (*models.UploadProgress, error)
+	UpdateUploadProgress(progress *models.UploadProgress) error
+	DeleteUploadProgress(uploadID string) error
+	ListUploadProgress() ([]*models.UploadProgress, error)
+
+	// Policy operations
+	StorePolicy(policy *models.Policy) error
+	GetPolicy(id string) (*models.Policy, error)
+	ListPolicies() ([]*models.Policy, error)
+	DeletePolicy(id string) error
+	// ApplyPolicyChanges stores and deletes policies in one transaction, so
+	// either all changes are made or none are
+	ApplyPolicyChanges(stored []*models.Policy, deleted []string) error
+
+	// Service account and API token operations
+	StoreServiceAccount(account *models.ServiceAccount) error
+	GetServiceAccount(id string) (*models.ServiceAccount, error)
+	ListServiceAccounts() ([]*models.ServiceAccount, error)
+	DeleteServiceAccount(id string) error
+	StoreAPIToken(token *models.APIToken) error
+	GetAPIToken(id string) (*models.APIToken, error)
+	// UpdateAPIToken applies update to the stored token atomically, so
+	// concurrent updates, such as a revocation and a use, are never lost
+	UpdateAPIToken(id string, update func(token *models.APIToken) error) error
+	ListAPITokens() ([]*models.APIToken, error)
+	DeleteAPIToken(id string) error
+
+	// Known user operations, recording users seen authenticating
+	StoreKnownUser(user *models.KnownUser) error
+	GetKnownUser(id string) (*models.KnownUser, error)
+	ListKnownUsers() ([]*models.KnownUser, error)
+	DeleteKnownUser(id string) error
+
+	// Audit log operations. ExpireAuditLogs removes up to limit of the oldest
+	// entries logged before before (any age if zero), passing them to expired
+	// first. expired may archive them and returns a checkpoint, if any, that
+	// is stored in the same transaction; an error leaves them in place.
+	StoreAuditLog(log *models.AuditLog) error
+	// AppendAuditLog stores an entry after link has chained it to the newest
+	// stored entry and checkpoint, each nil if there is none. Appends are
+	// serialized across all processes sharing the store.
+	AppendAuditLog(log *models.AuditLog, link func(newest *models.AuditLog, checkpoint *models.AuditCheckpoint) error) error
+	ListAuditLogs(userID string, resource string, startTime, endTime time.Time, limit int) ([]*models.AuditLog, error)
+	QueryAuditLogs(query *AuditLogQuery) (*AuditLogPage, error)
+	CountAuditLogs() (int, error)
+	ExpireAuditLogs(before time.Time, limit int, expired func(logs []*models.AuditLog) (*models.AuditCheckpoint, error)) (int, error)
+
+	// Audit checkpoint operations. Checkpoints are listed by sequence.
+	StoreAuditCheckpoint(checkpoint *models.AuditCheckpoint) error
+	ListAuditCheckpoints() ([]*models.AuditCheckpoint, error)
+
+	// Signature operations
+	StoreSignature(signature *models.Signature) error
+	GetSignature(id string) (*models.Signature, error)
+	ListSignaturesForArtifact(artifactID string) ([]*models.Signature, error)
+	DeleteSignature(id string) error
+
+	// SBOM operations
+	StoreSBOM(sbom *models.SBOM) error
+	GetSBOM(id string) (*models.SBOM, error)
+	GetSBOMForArtifact(artifactID string) (*models.SBOM, error)
+	DeleteSBOM(id string) error
+
+	// Attestation operations
+	StoreAttestation(attestation *models.Attestation) error
+	GetAttestation(id string) (*models.Attestation, error)
+	ListAttestationsForArtifact(artifactID string) ([]*models.Attestation, error)
+	DeleteAttestation(id string) error
+
+	// Supply-chain tombstones, oldest first
+	ListSupplyChainTombstones(artifactID string) ([]*models.SupplyChainTombstone, error)
+
+	// Replication operations
+	EnqueueReplicationTask(task *models.ReplicationTask, target string) error
+	ListReplicationTasks(after uint64, limit int) ([]*models.ReplicationTask, error)
+	CountReplicationTasks() (int, error)
+	CompleteReplicationTask(task *models.ReplicationTask) error
+	FailReplicationTask(task *models.ReplicationTask) error
+	GetReplicationStatus(ruleID, bucket, key string) (*models.ReplicationStatus, error)
+	ListReplicationStatus(bucket, key string) ([]*models.ReplicationStatus, error)
+
+	// Backup and portability
+	Backup(w io.Writer) (int64, error)
+	ExportRecords(fn func(record *MetadataRecord) error) error
+	ImportRecords(records []*MetadataRecord) error
+}
+
+// Metadata store drivers
+const (
+	MetadataDriverBolt     = "bolt"
+	MetadataDriverSQLite   = "sqlite"
+	MetadataDriverPostgres = "postgres"
 )
 
-// MetadataStore manages artifact and bucket metadata using BoltDB
-type MetadataStore struct {
-	db *bolt.DB
+// MetadataConfig selects and configures the metadata store
+type MetadataConfig struct {
+	Driver     string           `json:"driver" mapstructure:"driver"`         // bolt (default), sqlite or postgres
+	DSN        string           `json:"dsn" mapstructure:"dsn"`               // File path for bolt and sqlite, connection string for postgres
+	Migrations MigrationOptions `json:"migrations" mapstructure:"migrations"` // How pending schema migrations are applied on open
+	Tombstones bool             `json:"tombstones" mapstructure:"tombstones"` // Archive supply-chain records of deleted or overwritten artifacts
 }
 
-// NewMetadataStore creates a new metadata store
-func NewMetadataStore(dbPath string) (*MetadataStore, error) {
-	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
-	if err != nil {
-		return nil, fmt.Errorf("failed to open metadata database: %w", err)
-	}
-
-	// Create buckets if they don't exist
-	err = db.Update(func(tx *bolt.Tx) error {
-		for _, bucket := range [][]byte{bucketsBucket, artifactsBucket, multipartBucket, uploadProgressBucket, policiesBucket, auditLogsBucket, signaturesBucket, sbomsBucket, attestationsBucket} {
-			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
-				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		db.Close()
-		return nil, err
-	}
-
-	return &MetadataStore{db: db}, nil
+// MigrationOptions controls how pending schema migrations are applied
+type MigrationOptions struct {
+	DryRun    bool   `json:"dryRun" mapstructure:"dryRun"`       // Report pending migrations without applying them
+	BackupDir string `json:"backupDir" mapstructure:"backupDir"` // Copy the database here before migrating (bolt and sqlite)
 }
 
-// Close closes the metadata store
-func (s *MetadataStore) Close() error {
-	return s.db.Close()
+// SchemaMigration describes one versioned schema migration
+type SchemaMigration struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
 }
 
-// === Bucket Operations ===
-
-// CreateBucket creates a new bucket
-func (s *MetadataStore) CreateBucket(bucket *models.Bucket) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketsBucket)
-		existing := b.Get([]byte(bucket.Name))
-		if existing != nil {
-			return fmt.Errorf("bucket %s already exists", bucket.Name)
-		}
-
-		bucket.CreatedAt = time.Now()
-		bucket.UpdatedAt = bucket.CreatedAt
-		data, err := json.Marshal(bucket)
-		if err != nil {
-			return fmt.Errorf("failed to marshal bucket: %w", err)
-		}
-
-		return b.Put([]byte(bucket.Name), data)
-	})
+// SchemaStatus reports the schema version of a metadata database
+type SchemaStatus struct {
+	Version int               `json:"version"`          // Latest applied migration
+	Latest  int               `json:"latest"`           // Latest migration known to this build
+	Applied []SchemaMigration `json:"applied"`          // Migrations applied by this run
+	Pending []SchemaMigration `json:"pending"`          // Migrations not applied yet
+	Backup  string            `json:"backup,omitempty"` // Backup taken before migrating
 }
 
-// GetBucket retrieves a bucket by name
-func (s *MetadataStore) GetBucket(name string) (*models.Bucket, error) {
-	var bucket models.Bucket
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketsBucket)
-		data := b.Get([]byte(name))
-		if data == nil {
-			return fmt.Errorf("bucket %s not found", name)
-		}
-
-		return json.Unmarshal(data, &bucket)
-	})
-	if err != nil {
-		return nil, err
-	}
-	return &bucket, nil
-}
-
-// ListBuckets lists all buckets
-func (s *MetadataStore) ListBuckets() ([]*models.Bucket, error) {
-	var buckets []*models.Bucket
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketsBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var bucket models.Bucket
-			if err := json.Unmarshal(v, &bucket); err != nil {
-				return err
-			}
-			buckets = append(buckets, &bucket)
-			return nil
-		})
-	})
-	return buckets, err
-}
-
-// DeleteBucket deletes a bucket
-func (s *MetadataStore) DeleteBucket(name string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketsBucket)
-		return b.Delete([]byte(name))
-	})
-}
-
-// UpdateBucket updates bucket metadata
-func (s *MetadataStore) UpdateBucket(bucket *models.Bucket) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketsBucket)
-		bucket.UpdatedAt = time.Now()
-		data, err := json.Marshal(bucket)
-		if err != nil {
-			return fmt.Errorf("failed to marshal bucket: %w", err)
-		}
-		return b.Put([]byte(bucket.Name), data)
-	})
+// migratingStore is a metadata store that can report and apply its schema migrations
+type migratingStore interface {
+	MetadataStore
+	migrate(opts MigrationOptions) (*SchemaStatus, error)
+	setTombstones(enabled bool)
 }
 
-// === Artifact Operations ===
-
-// StoreArtifact stores artifact metadata
-func (s *MetadataStore) StoreArtifact(artifact *models.Artifact) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(artifactsBucket)
-		key := artifactKey(artifact.Bucket, artifact.Key)
-
-		artifact.UpdatedAt = time.Now()
-		if artifact.CreatedAt.IsZero() {
-			artifact.CreatedAt = artifact.UpdatedAt
-		}
-
-		data, err := json.Marshal(artifact)
-		if err != nil {
-			return fmt.Errorf("failed to marshal artifact: %w", err)
-		}
-
-		return b.Put([]byte(key), data)
-	})
-}
-
-// GetArtifact retrieves artifact metadata
-func (s *MetadataStore) GetArtifact(bucket, key string) (*models.Artifact, error) {
-	var artifact models.Artifact
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(artifactsBucket)
-		data := b.Get([]byte(artifactKey(bucket, key)))
-		if data == nil {
-			return fmt.Errorf("artifact %s/%s not found", bucket, key)
-		}
-
-		return json.Unmarshal(data, &artifact)
-	})
+// OpenMetadataStore opens the metadata store selected by config and migrates
+// it to the latest schema. With config.Migrations.DryRun set, opening fails
+// while migrations are pending instead of applying them.
+func OpenMetadataStore(config *MetadataConfig) (MetadataStore, error) {
+	store, err := openMetadataDatabase(config)
 	if err != nil {
 		return nil, err
 	}
-	return &artifact, nil
-}
-
-// ListArtifacts lists artifacts in a bucket with optional prefix
-func (s *MetadataStore) ListArtifacts(bucket, prefix string, maxKeys int) ([]*models.Artifact, error) {
-	var artifacts []*models.Artifact
-	count := 0
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(artifactsBucket)
-		c := b.Cursor()
-
-		searchPrefix := []byte(bucket + "/")
-		if prefix != "" {
-			searchPrefix = []byte(artifactKey(bucket, prefix))
-		}
-
-		for k, v := c.Seek(searchPrefix); k != nil && count < maxKeys; k, v = c.Next() {
-			// Check if key matches bucket prefix
-			keyStr := string(k)
-			if len(keyStr) < len(bucket)+1 || keyStr[:len(bucket)+1] != bucket+"/" {
-				break
-			}
-
-			var artifact models.Artifact
-			if err := json.Unmarshal(v, &artifact); err != nil {
-				return err
-			}
-
-			artifacts = append(artifacts, &artifact)
-			count++
-		}
-
-		return nil
-	})
-
-	return artifacts, err
-}
-
-// DeleteArtifact deletes artifact metadata
-func (s *MetadataStore) DeleteArtifact(bucket, key string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(artifactsBucket)
-		return b.Delete([]byte(artifactKey(bucket, key)))
-	})
-}
-
-// === Multipart Upload Operations ===
-
-// CreateMultipartUpload creates a new multipart upload
-func (s *MetadataStore) CreateMultipartUpload(upload *models.MultipartUpload) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(multipartBucket)
-		upload.InitiatedAt = time.Now()
-		data, err := json.Marshal(upload)
-		if err != nil {
-			return fmt.Errorf("failed to marshal multipart upload: %w", err)
-		}
-		return b.Put([]byte(upload.UploadID), data)
-	})
-}
 
-// GetMultipartUpload retrieves a multipart upload
-func (s *MetadataStore) GetMultipartUpload(uploadID string) (*models.MultipartUpload, error) {
-	var upload models.MultipartUpload
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(multipartBucket)
-		data := b.Get([]byte(uploadID))
-		if data == nil {
-			return fmt.Errorf("multipart upload %s not found", uploadID)
-		}
-		return json.Unmarshal(data, &upload)
-	})
-	if err != nil {
-		return nil, err
+	status, err := store.migrate(config.Migrations)
+	if err == nil && len(status.Pending) > 0 {
+		err = fmt.Errorf("metadata schema is at version %d but this build requires version %d; run astore-admin migrate",
+			status.Version, status.Latest)
 	}
-	return &upload, nil
-}
-
-// UpdateMultipartUpload updates multipart upload state
-func (s *MetadataStore) UpdateMultipartUpload(upload *models.MultipartUpload) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(multipartBucket)
-		data, err := json.Marshal(upload)
-		if err != nil {
-			return fmt.Errorf("failed to marshal multipart upload: %w", err)
-		}
-		return b.Put([]byte(upload.UploadID), data)
-	})
-}
-
-// DeleteMultipartUpload deletes a multipart upload
-func (s *MetadataStore) DeleteMultipartUpload(uploadID string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(multipartBucket)
-		return b.Delete([]byte(uploadID))
-	})
-}
-
-// === Policy Operations ===
-
-// StorePolicy stores a policy
-func (s *MetadataStore) StorePolicy(policy *models.Policy) error {
-	policy.UpdatedAt = time.Now()
-	if policy.CreatedAt.IsZero() {
-		policy.CreatedAt = policy.UpdatedAt
-	}
-
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(policiesBucket)
-		data, err := json.Marshal(policy)
-		if err != nil {
-			return fmt.Errorf("failed to marshal policy: %w", err)
-		}
-		return b.Put([]byte(policy.ID), data)
-	})
-}
-
-// GetPolicy retrieves a policy by ID
-func (s *MetadataStore) GetPolicy(id string) (*models.Policy, error) {
-	var policy models.Policy
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(policiesBucket)
-		data := b.Get([]byte(id))
-		if data == nil {
-			return fmt.Errorf("policy %s not found", id)
-		}
-		return json.Unmarshal(data, &policy)
-	})
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
-	return &policy, nil
-}
 
-// ListPolicies lists all policies
-func (s *MetadataStore) ListPolicies() ([]*models.Policy, error) {
-	var policies []*models.Policy
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(policiesBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var policy models.Policy
-			if err := json.Unmarshal(v, &policy); err != nil {
-				return err
-			}
-			policies = append(policies, &policy)
-			return nil
-		})
-	})
+	store.setTombstones(config.Tombstones)
+	return store, nil
+}
 
+// MigrateMetadataStore applies pending schema migrations to the database
+// selected by config and reports its schema. With opts.DryRun set the
+// database is left unchanged and the report lists the pending migrations.
+func MigrateMetadataStore(config *MetadataConfig, opts MigrationOptions) (*SchemaStatus, error) {
+	store, err := openMetadataDatabase(config)
 	if err != nil {
 		return nil, err
 	}
-	return policies, nil
-}
+	defer store.Close()
 
-// DeletePolicy deletes a policy
-func (s *MetadataStore) DeletePolicy(id string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(policiesBucket)
-		return b.Delete([]byte(id))
-	})
+	return store.migrate(opts)
 }
 
-// === Audit Log Operations ===
-
-// StoreAuditLog stores an audit log entry
-func (s *MetadataStore) StoreAuditLog(log *models.AuditLog) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(auditLogsBucket)
-		data, err := json.Marshal(log)
-		if err != nil {
-			return fmt.Errorf("failed to marshal audit log: %w", err)
-		}
-		// Use timestamp + ID as key for chronological ordering
-		key := []byte(fmt.Sprintf("%d_%s", log.Timestamp.Unix(), log.ID))
-		return b.Put(key, data)
-	})
+// CompactResult reports database file sizes around a compaction
+type CompactResult struct {
+	Before int64 `json:"before"` // Bytes before compacting; 0 for postgres
+	After  int64 `json:"after"`  // Bytes after compacting; 0 for postgres
 }
 
-// ListAuditLogs retrieves audit logs with optional filtering
-func (s *MetadataStore) ListAuditLogs(userID string, resource string, startTime, endTime time.Time, limit int) ([]*models.AuditLog, error) {
-	var logs []*models.AuditLog
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(auditLogsBucket)
-		c := b.Cursor()
-
-		// Iterate in reverse chronological order
-		count := 0
-		for k, v := c.Last(); k != nil && (limit == 0 || count < limit); k, v = c.Prev() {
-			var log models.AuditLog
-			if err := json.Unmarshal(v, &log); err != nil {
-				continue
-			}
-
-			// Apply filters
-			if userID != "" && log.UserID != userID {
-				continue
-			}
-			if resource != "" && log.Resource != resource {
-				continue
-			}
-			if !startTime.IsZero() && log.Timestamp.Before(startTime) {
-				continue
-			}
-			if !endTime.IsZero() && log.Timestamp.After(endTime) {
-				continue
-			}
-
-			logs = append(logs, &log)
-			count++
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+// CompactMetadataStore rewrites the database selected by config to release
+// space freed by deleted records, such as expired audit log entries. BoltDB
+// files are locked while open, so the server must be stopped first.
+func CompactMetadataStore(config *MetadataConfig) (*CompactResult, error) {
+	if config.DSN == "" {
+		return nil, fmt.Errorf("metadata store %q requires a dsn", config.Driver)
 	}
-	return logs, nil
-}
-
-// === Signature Operations ===
 
-// StoreSignature stores an artifact signature
-func (s *MetadataStore) StoreSignature(signature *models.Signature) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(signaturesBucket)
-		data, err := json.Marshal(signature)
+	switch config.Driver {
+	case "", MetadataDriverBolt:
+		return compactBoltDatabase(config.DSN)
+	case MetadataDriverSQLite, MetadataDriverPostgres:
+		s, err := openSQLMetadataStore(config.Driver, config.DSN)
 		if err != nil {
-			return fmt.Errorf("failed to marshal signature: %w", err)
+			return nil, err
 		}
-		return b.Put([]byte(signature.ID), data)
-	})
-}
-
-// GetSignature retrieves a signature by ID
-func (s *MetadataStore) GetSignature(id string) (*models.Signature, error) {
-	var signature models.Signature
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(signaturesBucket)
-		data := b.Get([]byte(id))
-		if data == nil {
-			return fmt.Errorf("signature %s not found", id)
-		}
-		return json.Unmarshal(data, &signature)
-	})
-	if err != nil {
-		return nil, err
+		defer s.Close()
+		return s.compact(config.DSN)
+	default:
+		return nil, fmt.Errorf("unknown metadata store driver %q (expected %s, %s or %s)",
+			config.Driver, MetadataDriverBolt, MetadataDriverSQLite, MetadataDriverPostgres)
 	}
-	return &signature, nil
 }
 
-// ListSignaturesForArtifact lists all signatures for an artifact
-func (s *MetadataStore) ListSignaturesForArtifact(artifactID string) ([]*models.Signature, error) {
-	var signatures []*models.Signature
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(signaturesBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var signature models.Signature
-			if err := json.Unmarshal(v, &signature); err != nil {
-				return err
-			}
-			if signature.ArtifactID == artifactID {
-				signatures = append(signatures, &signature)
-			}
-			return nil
-		})
-	})
-
-	if err != nil {
-		return nil, err
+// openMetadataDatabase opens the database selected by config without migrating it
+func openMetadataDatabase(config *MetadataConfig) (migratingStore, error) {
+	if config.DSN == "" {
+		return nil, fmt.Errorf("metadata store %q requires a dsn", config.Driver)
 	}
-	return signatures, nil
-}
-
-// DeleteSignature deletes a signature
-func (s *MetadataStore) DeleteSignature(id string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(signaturesBucket)
-		return b.Delete([]byte(id))
-	})
-}
-
-// === SBOM Operations ===
-
-// StoreSBOM stores an SBOM
-func (s *MetadataStore) StoreSBOM(sbom *models.SBOM) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(sbomsBucket)
-		data, err := json.Marshal(sbom)
-		if err != nil {
-			return fmt.Errorf("failed to marshal SBOM: %w", err)
-		}
-		return b.Put([]byte(sbom.ID), data)
-	})
-}
 
-// GetSBOM retrieves an SBOM by ID
-func (s *MetadataStore) GetSBOM(id string) (*models.SBOM, error) {
-	var sbom models.SBOM
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(sbomsBucket)
-		data := b.Get([]byte(id))
-		if data == nil {
-			return fmt.Errorf("SBOM %s not found", id)
-		}
-		return json.Unmarshal(data, &sbom)
-	})
-	if err != nil {
-		return nil, err
+	switch config.Driver {
+	case "", MetadataDriverBolt:
+		return openBoltMetadataStore(config.DSN)
+	case MetadataDriverSQLite, MetadataDriverPostgres:
+		return openSQLMetadataStore(config.Driver, config.DSN)
+	default:
+		return nil, fmt.Errorf("unknown metadata store driver %q (expected %s, %s or %s)",
+			config.Driver, MetadataDriverBolt, MetadataDriverSQLite, MetadataDriverPostgres)
 	}
-	return &sbom, nil
 }
 
-// GetSBOMForArtifact retrieves the SBOM for an artifact
-func (s *MetadataStore) GetSBOMForArtifact(artifactID string) (*models.SBOM, error) {
-	var sbom *models.SBOM
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(sbomsBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var s models.SBOM
-			if err := json.Unmarshal(v, &s); err != nil {
-				return err
-			}
-			if s.ArtifactID == artifactID {
-				sbom = &s
-				return nil
-			}
-			return nil
-		})
-	})
-
-	if err != nil {
-		return nil, err
+// planMigrations reports which of migrations are pending at version current.
+// Databases written by a newer build are rejected rather than misread.
+func planMigrations(current int, migrations []SchemaMigration) (*SchemaStatus, error) {
+	status := &SchemaStatus{Version: current}
+	if len(migrations) > 0 {
+		status.Latest = migrations[len(migrations)-1].Version
 	}
-	if sbom == nil {
-		return nil, fmt.Errorf("SBOM not found for artifact %s", artifactID)
+	if current > status.Latest {
+		return nil, fmt.Errorf("metadata schema version %d is newer than this build supports (%d)", current, status.Latest)
 	}
-	return sbom, nil
-}
-
-// DeleteSBOM deletes an SBOM
-func (s *MetadataStore) DeleteSBOM(id string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(sbomsBucket)
-		return b.Delete([]byte(id))
-	})
-}
-
-// === Attestation Operations ===
-
-// StoreAttestation stores an attestation
-func (s *MetadataStore) StoreAttestation(attestation *models.Attestation) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(attestationsBucket)
-		data, err := json.Marshal(attestation)
-		if err != nil {
-			return fmt.Errorf("failed to marshal attestation: %w", err)
-		}
-		return b.Put([]byte(attestation.ID), data)
-	})
-}
 
-// GetAttestation retrieves an attestation by ID
-func (s *MetadataStore) GetAttestation(id string) (*models.Attestation, error) {
-	var attestation models.Attestation
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(attestationsBucket)
-		data := b.Get([]byte(id))
-		if data == nil {
-			return fmt.Errorf("attestation %s not found", id)
+	for _, migration := range migrations {
+		if migration.Version > current {
+			status.Pending = append(status.Pending, migration)
 		}
-		return json.Unmarshal(data, &attestation)
-	})
-	if err != nil {
-		return nil, err
 	}
-	return &attestation, nil
+	return status, nil
 }
 
-// ListAttestationsForArtifact lists all attestations for an artifact
-func (s *MetadataStore) ListAttestationsForArtifact(artifactID string) ([]*models.Attestation, error) {
-	var attestations []*models.Attestation
-
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(attestationsBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var attestation models.Attestation
-			if err := json.Unmarshal(v, &attestation); err != nil {
-				return err
-			}
-			if attestation.ArtifactID == artifactID {
-				attestations = append(attestations, &attestation)
-			}
-			return nil
-		})
-	})
-
-	if err != nil {
-		return nil, err
+// backupPath names a backup of database name taken at schema version
+func backupPath(dir, name string, version int) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
 	}
-	return attestations, nil
-}
-
-// DeleteAttestation deletes an attestation
-func (s *MetadataStore) DeleteAttestation(id string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(attestationsBucket)
-		return b.Delete([]byte(id))
-	})
-}
-
-// === Helper Functions ===
-
-func artifactKey(bucket, key string) string {
-	return bucket + "/" + key
+	return filepath.Join(dir, fmt.Sprintf("%s.v%d.%s.bak", name, version, time.Now().UTC().Format("20060102T150405Z"))), nil
 }
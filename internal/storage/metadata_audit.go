@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+// AuditLogQuery selects audit log entries, newest first
+type AuditLogQuery struct {
+	UserID    string    // Only entries for this user
+	Resource  string    // Only entries for this resource
+	StartTime time.Time // Only entries logged at or after this time
+	EndTime   time.Time // Only entries logged at or before this time
+	Limit     int       // Maximum entries returned; 0 returns all
+	Cursor    string    // Continue after the page that returned this cursor
+}
+
+// AuditLogPage is one page of audit log entries
+type AuditLogPage struct {
+	Logs       []*models.AuditLog `json:"logs"`
+	NextCursor string             `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
+// ErrInvalidAuditCursor is returned for cursors not issued by QueryAuditLogs
+var ErrInvalidAuditCursor = errors.New("invalid audit log cursor")
+
+// auditPosition orders audit log entries by time, then ID
+type auditPosition struct {
+	nanos int64
+	id    string
+}
+
+func auditPositionOf(log *models.AuditLog) auditPosition {
+	return auditPosition{nanos: log.Timestamp.UnixNano(), id: log.ID}
+}
+
+// cursor encodes the position as an opaque pagination cursor
+func (p auditPosition) cursor() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d_%s", p.nanos, p.id)))
+}
+
+// parseAuditCursor decodes a cursor returned in AuditLogPage.NextCursor
+func parseAuditCursor(cursor string) (auditPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return auditPosition{}, ErrInvalidAuditCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return auditPosition{}, ErrInvalidAuditCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return auditPosition{}, ErrInvalidAuditCursor
+	}
+	return auditPosition{nanos: n, id: id}, nil
+}
+
+// pageAuditLogs trims logs read with one extra entry to the query limit and
+// sets the cursor of the next page
+func pageAuditLogs(logs []*models.AuditLog, limit int) *AuditLogPage {
+	page := &AuditLogPage{Logs: logs}
+	if limit > 0 && len(logs) > limit {
+		page.Logs = logs[:limit]
+		page.NextCursor = auditPositionOf(page.Logs[limit-1]).cursor()
+	}
+	return page
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Metadata record kinds, in the order they are exported
+const (
+	RecordBucket            = "bucket"
+	RecordArtifact          = "artifact"
+	RecordMultipartUpload   = "multipartUpload"
+	RecordPolicy            = "policy"
+	RecordAuditLog          = "auditLog"
+	RecordSignature         = "signature"
+	RecordSBOM              = "sbom"
+	RecordAttestation       = "attestation"
+	RecordReplicationTask   = "replicationTask"
+	RecordReplicationStatus = "replicationStatus"
+
+	RecordSupplyChainTombstone = "supplyChainTombstone"
+	RecordArtifactRelation     = "artifactRelation"
+	RecordUploadProgress       = "uploadProgress"
+	RecordServiceAccount       = "serviceAccount"
+	RecordAPIToken             = "apiToken"
+	RecordKnownUser            = "knownUser"
+	RecordAuditCheckpoint      = "auditCheckpoint"
+)
+
+// importBatchSize is the number of records imported per transaction
+const importBatchSize = 500
+
+// MetadataRecord is one entity in a portable metadata export. Data holds the
+// entity's JSON document as returned by the store's getters.
+type MetadataRecord struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+// recordFields holds the fields stores use to place an imported record
+type recordFields struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Bucket     string    `json:"bucket"`
+	Key        string    `json:"key"`
+	UploadID   string    `json:"uploadId"`
+	ArtifactID string    `json:"artifactId"`
+	TargetID   string    `json:"targetId"`
+	Type       string    `json:"type"`
+	UserID     string    `json:"userId"`
+	Resource   string    `json:"resource"`
+	RuleID     string    `json:"ruleId"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// fields decodes and validates the fields identifying a record
+func (r *MetadataRecord) fields() (*recordFields, error) {
+	var f recordFields
+	if err := json.Unmarshal(r.Data, &f); err != nil {
+		return nil, fmt.Errorf("invalid %s record: %w", r.Kind, err)
+	}
+
+	var missing bool
+	switch r.Kind {
+	case RecordBucket:
+		missing = f.Name == ""
+	case RecordArtifact:
+		missing = f.Bucket == "" || f.Key == ""
+	case RecordMultipartUpload, RecordUploadProgress:
+		missing = f.UploadID == ""
+	case RecordPolicy, RecordAuditLog, RecordServiceAccount, RecordAPIToken, RecordKnownUser, RecordAuditCheckpoint:
+		missing = f.ID == ""
+	case RecordSignature, RecordSBOM, RecordAttestation, RecordSupplyChainTombstone:
+		missing = f.ID == "" || f.ArtifactID == ""
+	case RecordArtifactRelation:
+		missing = f.ArtifactID == "" || f.TargetID == "" || f.Type == ""
+	case RecordReplicationTask:
+	case RecordReplicationStatus:
+		missing = f.RuleID == "" || f.Bucket == "" || f.Key == ""
+	default:
+		return nil, fmt.Errorf("unknown metadata record kind %q", r.Kind)
+	}
+	if missing {
+		return nil, fmt.Errorf("%s record is missing its identifying fields", r.Kind)
+	}
+	return &f, nil
+}
+
+// ExportMetadata writes every record in store to w as JSON lines and returns
+// the number of records written. Replication tasks are exported in queue order.
+func ExportMetadata(store MetadataStore, w io.Writer) (int, error) {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+
+	count := 0
+	err := store.ExportRecords(func(record *MetadataRecord) error {
+		count++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to export metadata: %w", err)
+	}
+	return count, buffered.Flush()
+}
+
+// ImportMetadata reads JSON lines written by ExportMetadata into store and
+// returns the number of records imported. Existing records with the same
+// identity are replaced; replication tasks are appended to the queue.
+func ImportMetadata(store MetadataStore, r io.Reader) (int, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	count := 0
+	batch := make([]*MetadataRecord, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.ImportRecords(batch); err != nil {
+			return fmt.Errorf("failed to import metadata after %d records: %w", count, err)
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		var record MetadataRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("invalid metadata export at record %d: %w", count+len(batch)+1, err)
+		}
+
+		batch = append(batch, &record)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, flush()
+}
+
+// RestoreMetadataBackup replaces the bolt or SQLite database selected by
+// config with a backup read from r. The backup is checked before it replaces
+// the database, which must not be open.
+func RestoreMetadataBackup(config *MetadataConfig, r io.Reader) error {
+	if config.Driver == MetadataDriverPostgres {
+		return fmt.Errorf("postgres metadata databases cannot be restored by astore; use the database's own restore tools")
+	}
+	if config.DSN == "" {
+		return fmt.Errorf("metadata store %q requires a dsn", config.Driver)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(config.DSN), filepath.Base(config.DSN)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create restore file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write restore file: %w", err)
+	}
+
+	restored := *config
+	restored.DSN = tmp.Name()
+	if _, err := MigrateMetadataStore(&restored, MigrationOptions{DryRun: true}); err != nil {
+		return fmt.Errorf("backup is not a usable %s metadata database: %w", config.Driver, err)
+	}
+
+	// Stale SQLite journals would be replayed over the restored database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(config.DSN + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", config.DSN+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), config.DSN); err != nil {
+		return fmt.Errorf("failed to replace metadata database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestMetadataBackupAndExport(t *testing.T) {
+	t.Run("Export moves metadata between drivers", func(t *testing.T) {
+		// Given: A bolt store with supply-chain records
+		dir := t.TempDir()
+		source, err := storage.NewBoltMetadataStore(filepath.Join(dir, "metadata.db"))
+		test.AssertNoError(t, err, "open bolt store")
+		defer source.Close()
+		test.AssertNoError(t, source.StoreArtifact(&models.Artifact{Bucket: "releases", Key: "app.jar"}), "store artifact")
+		test.AssertNoError(t, source.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "releases/app.jar", SignedBy: "ci"}), "store signature")
+
+		// When: Exporting it and importing into SQLite
+		var export bytes.Buffer
+		_, err = storage.ExportMetadata(source, &export)
+		test.AssertNoError(t, err, "export")
+		target, err := storage.NewSQLMetadataStore(storage.MetadataDriverSQLite, filepath.Join(dir, "metadata.sqlite"))
+		test.AssertNoError(t, err, "open sqlite store")
+		defer target.Close()
+		_, err = storage.ImportMetadata(target, &export)
+		test.AssertNoError(t, err, "import")
+
+		// Then: The SQLite store serves the same records
+		signatures, err := target.ListSignaturesForArtifact("releases/app.jar")
+		test.AssertNoError(t, err, "list signatures")
+		test.AssertEqual(t, 1, len(signatures), "signatures")
+		test.AssertEqual(t, "ci", signatures[0].SignedBy, "signer")
+	})
+
+	for _, driver := range []string{storage.MetadataDriverBolt, storage.MetadataDriverSQLite} {
+		driver := driver
+		t.Run("Hot backup restores "+driver, func(t *testing.T) {
+			// Given: An open store with a policy
+			config := &storage.MetadataConfig{Driver: driver, DSN: filepath.Join(t.TempDir(), "metadata")}
+			store, err := storage.OpenMetadataStore(config)
+			test.AssertNoError(t, err, "open store")
+			test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "kept"}), "store policy")
+
+			// When: Backing it up while open, changing it, and restoring the backup
+			var backup bytes.Buffer
+			n, err := store.Backup(&backup)
+			test.AssertNoError(t, err, "backup")
+			test.AssertEqual(t, int64(backup.Len()), n, "bytes written")
+			test.AssertNoError(t, store.DeletePolicy("kept"), "delete policy")
+			test.AssertNoError(t, store.Close(), "close store")
+			test.AssertNoError(t, storage.RestoreMetadataBackup(config, &backup), "restore")
+
+			// Then: The store holds the backed up state
+			store, err = storage.OpenMetadataStore(config)
+			test.AssertNoError(t, err, "reopen store")
+			defer store.Close()
+			_, err = store.GetPolicy("kept")
+			test.AssertNoError(t, err, "policy restored")
+		})
+	}
+
+	t.Run("Invalid backups are not restored", func(t *testing.T) {
+		// Given: A bolt store
+		config := &storage.MetadataConfig{Driver: storage.MetadataDriverBolt, DSN: filepath.Join(t.TempDir(), "metadata.db")}
+		store, err := storage.OpenMetadataStore(config)
+		test.AssertNoError(t, err, "open store")
+		test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "kept"}), "store policy")
+		test.AssertNoError(t, store.Close(), "close store")
+
+		// When: Restoring something that is not a database
+		err = storage.RestoreMetadataBackup(config, strings.NewReader("not a database"))
+
+		// Then: The restore fails and the database is untouched
+		test.AssertError(t, err, "restore garbage")
+		store, err = storage.OpenMetadataStore(config)
+		test.AssertNoError(t, err, "reopen store")
+		defer store.Close()
+		_, err = store.GetPolicy("kept")
+		test.AssertNoError(t, err, "policy kept")
+	})
+}
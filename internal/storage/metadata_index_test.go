@@ -0,0 +1,147 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSupplyChainIndexes(t *testing.T) {
+	t.Run("Overwriting a record moves its index entry", func(t *testing.T) {
+		// Given: A signature stored for one artifact
+		store := newIndexTestStore(t, filepath.Join(t.TempDir(), "metadata.db"))
+		test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/old"}), "store signature")
+
+		// When: The signature is stored again for another artifact
+		test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/new"}), "overwrite signature")
+
+		// Then: Only the new artifact lists it
+		old, err := store.ListSignaturesForArtifact("bucket/old")
+		test.AssertNoError(t, err, "list old artifact")
+		test.AssertEqual(t, 0, len(old), "signatures for old artifact")
+		current, err := store.ListSignaturesForArtifact("bucket/new")
+		test.AssertNoError(t, err, "list new artifact")
+		test.AssertEqual(t, 1, len(current), "signatures for new artifact")
+	})
+
+	t.Run("Artifact IDs sharing a prefix are kept apart", func(t *testing.T) {
+		// Given: Attestations for "bucket/app" and "bucket/app-v2"
+		store := newIndexTestStore(t, filepath.Join(t.TempDir(), "metadata.db"))
+		test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-1", ArtifactID: "bucket/app"}), "store attestation")
+		test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-2", ArtifactID: "bucket/app-v2"}), "store attestation")
+
+		// When: Listing attestations for the shorter ID
+		attestations, err := store.ListAttestationsForArtifact("bucket/app")
+
+		// Then: Only its own attestation is returned
+		test.AssertNoError(t, err, "list attestations")
+		test.AssertEqual(t, 1, len(attestations), "attestation count")
+		test.AssertEqual(t, "att-1", attestations[0].ID, "attestation ID")
+	})
+
+	t.Run("Deleting an artifact removes its index entries", func(t *testing.T) {
+		// Given: An artifact with a signature, SBOM and attestation
+		store := newIndexTestStore(t, filepath.Join(t.TempDir(), "metadata.db"))
+		test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: "app"}), "store artifact")
+		test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/app"}), "store signature")
+		test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-1", ArtifactID: "bucket/app"}), "store SBOM")
+		test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-1", ArtifactID: "bucket/app"}), "store attestation")
+
+		// When: Deleting the artifact
+		test.AssertNoError(t, store.DeleteArtifact("bucket", "app"), "delete artifact")
+
+		// Then: No supply-chain record is found for it
+		signatures, err := store.ListSignaturesForArtifact("bucket/app")
+		test.AssertNoError(t, err, "list signatures")
+		test.AssertEqual(t, 0, len(signatures), "signatures after delete")
+		_, err = store.GetSBOMForArtifact("bucket/app")
+		test.AssertError(t, err, "SBOM after delete")
+		attestations, err := store.ListAttestationsForArtifact("bucket/app")
+		test.AssertNoError(t, err, "list attestations")
+		test.AssertEqual(t, 0, len(attestations), "attestations after delete")
+	})
+
+	t.Run("Reindex rebuilds missing and stale indexes", func(t *testing.T) {
+		// Given: A database whose index buckets were dropped or hold stale entries
+		path := filepath.Join(t.TempDir(), "metadata.db")
+		store, err := storage.NewBoltMetadataStore(path)
+		test.AssertNoError(t, err, "open store")
+		test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/app"}), "store signature")
+		test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-1", ArtifactID: "bucket/app"}), "store SBOM")
+		test.AssertNoError(t, store.Close(), "close store")
+
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		test.AssertNoError(t, err, "open raw database")
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := tx.DeleteBucket([]byte("signatures_by_artifact")); err != nil {
+				return err
+			}
+			return tx.Bucket([]byte("sboms_by_artifact")).Put([]byte("bucket/other\x00sbom-1"), []byte{})
+		})
+		test.AssertNoError(t, err, "corrupt indexes")
+		test.AssertNoError(t, db.Close(), "close raw database")
+
+		// When: Reopening the store and reindexing
+		store = newIndexTestStore(t, path)
+		test.AssertNoError(t, store.Reindex(), "reindex")
+
+		// Then: Lookups reflect the primary records only
+		signatures, err := store.ListSignaturesForArtifact("bucket/app")
+		test.AssertNoError(t, err, "list signatures")
+		test.AssertEqual(t, 1, len(signatures), "signatures after reindex")
+		_, err = store.GetSBOMForArtifact("bucket/other")
+		test.AssertError(t, err, "stale SBOM entry removed")
+		sbom, err := store.GetSBOMForArtifact("bucket/app")
+		test.AssertNoError(t, err, "SBOM after reindex")
+		test.AssertEqual(t, "sbom-1", sbom.ID, "SBOM ID")
+	})
+}
+
+// BenchmarkListSignaturesForArtifact shows lookup cost does not grow with
+// the number of signatures held for other artifacts.
+func BenchmarkListSignaturesForArtifact(b *testing.B) {
+	for _, others := range []int{100, 10000} {
+		b.Run(fmt.Sprintf("others=%d", others), func(b *testing.B) {
+			store, err := storage.NewBoltMetadataStore(filepath.Join(b.TempDir(), "metadata.db"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			for i := 0; i < others; i++ {
+				signature := &models.Signature{ID: fmt.Sprintf("other-%d", i), ArtifactID: fmt.Sprintf("bucket/other-%d", i)}
+				if err := store.StoreSignature(signature); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for i := 0; i < 3; i++ {
+				signature := &models.Signature{ID: fmt.Sprintf("sig-%d", i), ArtifactID: "bucket/target"}
+				if err := store.StoreSignature(signature); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				signatures, err := store.ListSignaturesForArtifact("bucket/target")
+				if err != nil || len(signatures) != 3 {
+					b.Fatalf("unexpected result: %d signatures, %v", len(signatures), err)
+				}
+			}
+		})
+	}
+}
+
+func newIndexTestStore(t *testing.T, path string) *storage.BoltMetadataStore {
+	t.Helper()
+	store, err := storage.NewBoltMetadataStore(path)
+	test.AssertNoError(t, err, "open store")
+	t.Cleanup(func() { store.Close() })
+	return store
+}
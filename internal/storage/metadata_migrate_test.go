@@ -0,0 +1,136 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMetadataSchemaMigrations(t *testing.T) {
+	t.Run("Unversioned bolt database is migrated with a backup", func(t *testing.T) {
+		// Given: A bolt database written before schema versioning
+		dir := t.TempDir()
+		path := filepath.Join(dir, "metadata.db")
+		writeUnversionedBoltDatabase(t, path)
+		config := &storage.MetadataConfig{Driver: storage.MetadataDriverBolt, DSN: path}
+
+		// When: Inspecting and then migrating it
+		plan, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{DryRun: true})
+		test.AssertNoError(t, err, "dry run")
+		status, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{BackupDir: filepath.Join(dir, "backups")})
+		test.AssertNoError(t, err, "migrate")
+
+		// Then: The dry run lists every migration and the real run applies them
+		test.AssertEqual(t, 0, plan.Version, "version before migrating")
+		test.AssertEqual(t, plan.Latest, len(plan.Pending), "pending migrations")
+		test.AssertEqual(t, 0, len(plan.Applied), "dry run applies nothing")
+		test.AssertEqual(t, plan.Latest, status.Version, "version after migrating")
+		test.AssertEqual(t, len(plan.Pending), len(status.Applied), "applied migrations")
+		test.AssertEqual(t, 0, len(status.Pending), "nothing left pending")
+
+		_, err = os.Stat(status.Backup)
+		test.AssertNoError(t, err, "backup written")
+
+		// And: Existing records are readable and indexed
+		store, err := storage.NewBoltMetadataStore(path)
+		test.AssertNoError(t, err, "open migrated store")
+		defer store.Close()
+		signatures, err := store.ListSignaturesForArtifact("bucket/app")
+		test.AssertNoError(t, err, "list signatures")
+		test.AssertEqual(t, 1, len(signatures), "indexed signature")
+		logs, err := store.ListAuditLogs("alice", "", time.Time{}, time.Time{}, 0)
+		test.AssertNoError(t, err, "list audit logs")
+		test.AssertEqual(t, 1, len(logs), "rekeyed audit log")
+	})
+
+	t.Run("Dry run on open refuses pending migrations", func(t *testing.T) {
+		// Given: An unversioned bolt database
+		path := filepath.Join(t.TempDir(), "metadata.db")
+		writeUnversionedBoltDatabase(t, path)
+
+		// When: Opening it without applying migrations
+		_, err := storage.OpenMetadataStore(&storage.MetadataConfig{DSN: path, Migrations: storage.MigrationOptions{DryRun: true}})
+
+		// Then: Opening fails and the database is left at version 0
+		test.AssertError(t, err, "pending migrations")
+		status, err := storage.MigrateMetadataStore(&storage.MetadataConfig{DSN: path}, storage.MigrationOptions{DryRun: true})
+		test.AssertNoError(t, err, "inspect schema")
+		test.AssertEqual(t, 0, status.Version, "schema version")
+	})
+
+	t.Run("Databases from a newer build are rejected", func(t *testing.T) {
+		// Given: A bolt database claiming a future schema version
+		path := filepath.Join(t.TempDir(), "metadata.db")
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		test.AssertNoError(t, err, "open raw database")
+		err = db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket([]byte("schema"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte("version"), []byte("999"))
+		})
+		test.AssertNoError(t, err, "write schema version")
+		test.AssertNoError(t, db.Close(), "close raw database")
+
+		// When: Opening it
+		_, err = storage.NewBoltMetadataStore(path)
+
+		// Then: It is not misread
+		test.AssertError(t, err, "newer schema")
+	})
+
+	t.Run("SQLite database is backed up before migrating", func(t *testing.T) {
+		// Given: An empty SQLite database
+		dir := t.TempDir()
+		config := &storage.MetadataConfig{Driver: storage.MetadataDriverSQLite, DSN: filepath.Join(dir, "metadata.sqlite")}
+
+		// When: Migrating it with a backup directory
+		status, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{BackupDir: dir})
+
+		// Then: The backup exists and a second run has nothing to do
+		test.AssertNoError(t, err, "migrate")
+		_, err = os.Stat(status.Backup)
+		test.AssertNoError(t, err, "backup written")
+		again, err := storage.MigrateMetadataStore(config, storage.MigrationOptions{BackupDir: dir})
+		test.AssertNoError(t, err, "migrate again")
+		test.AssertEqual(t, 0, len(again.Applied), "no migrations applied")
+		test.AssertEqual(t, "", again.Backup, "no backup without migrations")
+	})
+}
+
+// writeUnversionedBoltDatabase writes the bucket layout used before schema
+// versioning, holding one signature and one audit log entry
+func writeUnversionedBoltDatabase(t *testing.T, path string) {
+	t.Helper()
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	test.AssertNoError(t, err, "open raw database")
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"buckets", "artifacts", "multipart_uploads", "upload_progress", "policies", "sboms", "attestations", "replication_queue", "replication_status"} {
+			if _, err := tx.CreateBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		auditLogs, err := tx.CreateBucket([]byte("audit_logs"))
+		if err != nil {
+			return err
+		}
+		err = auditLogs.Put([]byte("1700000000_log-1"), []byte(`{"id":"log-1","timestamp":"2023-11-14T22:13:20.5Z","userId":"alice","resource":"bucket/app"}`))
+		if err != nil {
+			return err
+		}
+		signatures, err := tx.CreateBucket([]byte("signatures"))
+		if err != nil {
+			return err
+		}
+		return signatures.Put([]byte("sig-1"), []byte(`{"id":"sig-1","artifactId":"bucket/app"}`))
+	})
+	test.AssertNoError(t, err, "write legacy layout")
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+var (
+	// ErrArtifactHasDependents is returned when deleting an artifact that other artifacts relate to
+	ErrArtifactHasDependents = errors.New("artifact has dependents")
+	// ErrRelationNotFound is returned when removing a relation that does not exist
+	ErrRelationNotFound = errors.New("artifact relation not found")
+)
+
+// validateRelation checks a relation before it is stored
+func validateRelation(relation *models.ArtifactRelation) error {
+	if relation.ArtifactID == "" || relation.TargetID == "" {
+		return fmt.Errorf("artifact relation requires an artifact and a target")
+	}
+	if relation.ArtifactID == relation.TargetID {
+		return fmt.Errorf("artifact %s cannot relate to itself", relation.ArtifactID)
+	}
+	if !relation.Type.IsValid() {
+		return fmt.Errorf("unknown artifact relation type %q", relation.Type)
+	}
+	return nil
+}
+
+// dependentsError reports an artifact preventing the deletion of artifactID
+func dependentsError(artifactID, dependentID string) error {
+	return fmt.Errorf("%w: %s is required by %s", ErrArtifactHasDependents, artifactID, dependentID)
+}
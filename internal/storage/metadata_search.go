@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+// Artifact search indexes, looked up with FindArtifacts
+const (
+	ArtifactIndexMetadata    = "meta"  // Value is MetadataIndexValue(name, value)
+	ArtifactIndexTag         = "tag"   // Value is MetadataIndexValue(name, value)
+	ArtifactIndexContentType = "type"  // Value is the lowercased content type
+	ArtifactIndexToken       = "token" // Value is a word from ArtifactTokens
+)
+
+// MetadataIndexValue is the index value of a user metadata entry or tag.
+// Names are matched case-insensitively, as they arrive as HTTP headers.
+func MetadataIndexValue(name, value string) string {
+	return strings.ToLower(name) + "=" + value
+}
+
+// ArtifactTokens splits text into the lowercased words indexed for full-text
+// search. Words are runs of letters and digits of at least two characters.
+func ArtifactTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len(word) >= 2 && len(word) <= 64 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// artifactIndexEntry is one value under which an artifact is indexed
+type artifactIndexEntry struct {
+	index string
+	value string
+}
+
+// artifactIndexEntries lists the index entries of an artifact, without duplicates
+func artifactIndexEntries(artifact *models.Artifact) []artifactIndexEntry {
+	seen := make(map[artifactIndexEntry]bool)
+	add := func(index, value string) {
+		seen[artifactIndexEntry{index: index, value: value}] = true
+	}
+
+	for name, value := range artifact.Metadata {
+		add(ArtifactIndexMetadata, MetadataIndexValue(name, value))
+	}
+	for name, value := range artifact.Tags {
+		add(ArtifactIndexTag, MetadataIndexValue(name, value))
+	}
+	if artifact.ContentType != "" {
+		add(ArtifactIndexContentType, strings.ToLower(artifact.ContentType))
+	}
+	for _, token := range ArtifactText(artifact) {
+		add(ArtifactIndexToken, token)
+	}
+
+	entries := make([]artifactIndexEntry, 0, len(seen))
+	for entry := range seen {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].index != entries[j].index {
+			return entries[i].index < entries[j].index
+		}
+		return entries[i].value < entries[j].value
+	})
+	return entries
+}
+
+// ArtifactText returns the full-text tokens of an artifact, as indexed: the
+// words of its key and of its metadata and tag values
+func ArtifactText(artifact *models.Artifact) []string {
+	tokens := ArtifactTokens(artifact.Key)
+	for _, value := range artifact.Metadata {
+		tokens = append(tokens, ArtifactTokens(value)...)
+	}
+	for _, value := range artifact.Tags {
+		tokens = append(tokens, ArtifactTokens(value)...)
+	}
+	return tokens
+}
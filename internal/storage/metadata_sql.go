@@ -0,0 +1,1827 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	_ "github.com/lib/pq"           // PostgreSQL driver
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqlDialect captures the differences between the supported SQL databases
+type sqlDialect struct {
+	driverName     string // database/sql driver name
+	numbered       bool   // Placeholders are $1, $2, ... instead of ?
+	serialKey      string // Column type of an auto-incrementing primary key
+	keyType        string // Column type for keys that sort byte-wise
+	lockMigrations string // Statement serializing concurrent migrations, if needed
+	lockAuditLog   string // Statement serializing audit log appends, if needed
+	tableExists    string // Query counting tables named by its parameter
+	backup         string // Statement copying the database to the file named by its parameter, if supported
+}
+
+// maxUpdateAttempts bounds how often a compare-and-swap update is retried
+// when the row keeps changing underneath it
+const maxUpdateAttempts = 5
+
+var sqlDialects = map[string]*sqlDialect{
+	MetadataDriverSQLite: {
+		driverName:  "sqlite3",
+		serialKey:   "INTEGER PRIMARY KEY AUTOINCREMENT",
+		keyType:     "TEXT",
+		tableExists: `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		backup:      `VACUUM INTO ?`,
+	},
+	MetadataDriverPostgres: {
+		driverName:     "postgres",
+		numbered:       true,
+		serialKey:      "BIGSERIAL PRIMARY KEY",
+		keyType:        `TEXT COLLATE "C"`,
+		lockMigrations: "SELECT pg_advisory_xact_lock(4153)",
+		lockAuditLog:   "SELECT pg_advisory_xact_lock(4154)",
+		tableExists:    `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?`,
+	},
+}
+
+// sqlMigration is a versioned schema change. Migrations are applied in order
+// and recorded in schema_migrations; released migrations must never change.
+type sqlMigration struct {
+	version    int
+	name       string
+	statements func(d *sqlDialect) []string
+	backfill   func(s *SQLMetadataStore, tx *sql.Tx) error // Fills new tables from existing rows, if needed
+}
+
+var sqlMigrations = []sqlMigration{
+	{
+		version: 1,
+		name:    "initial schema",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE buckets (name ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+				`CREATE TABLE artifacts (bucket ` + d.keyType + ` NOT NULL, object_key ` + d.keyType + ` NOT NULL, data TEXT NOT NULL, PRIMARY KEY (bucket, object_key))`,
+				`CREATE TABLE multipart_uploads (upload_id TEXT PRIMARY KEY, data TEXT NOT NULL)`,
+				`CREATE TABLE policies (id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+				`CREATE TABLE audit_logs (id TEXT PRIMARY KEY, logged_at BIGINT NOT NULL, user_id TEXT NOT NULL, resource TEXT NOT NULL, data TEXT NOT NULL)`,
+				`CREATE INDEX audit_logs_logged_at ON audit_logs (logged_at)`,
+				`CREATE TABLE signatures (id ` + d.keyType + ` PRIMARY KEY, artifact_id TEXT NOT NULL, data TEXT NOT NULL)`,
+				`CREATE INDEX signatures_artifact_id ON signatures (artifact_id)`,
+				`CREATE TABLE sboms (id ` + d.keyType + ` PRIMARY KEY, artifact_id TEXT NOT NULL, data TEXT NOT NULL)`,
+				`CREATE INDEX sboms_artifact_id ON sboms (artifact_id)`,
+				`CREATE TABLE attestations (id ` + d.keyType + ` PRIMARY KEY, artifact_id TEXT NOT NULL, data TEXT NOT NULL)`,
+				`CREATE INDEX attestations_artifact_id ON attestations (artifact_id)`,
+				`CREATE TABLE replication_queue (sequence ` + d.serialKey + `, data TEXT NOT NULL)`,
+				`CREATE TABLE replication_status (rule_id ` + d.keyType + ` NOT NULL, bucket ` + d.keyType + ` NOT NULL, object_key ` + d.keyType + ` NOT NULL, data TEXT NOT NULL, PRIMARY KEY (rule_id, bucket, object_key))`,
+			}
+		},
+	},
+	{
+		version: 2,
+		name:    "supply-chain tombstones",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE supply_chain_tombstones (id ` + d.keyType + ` PRIMARY KEY, artifact_id TEXT NOT NULL, data TEXT NOT NULL)`,
+				`CREATE INDEX supply_chain_tombstones_artifact_id ON supply_chain_tombstones (artifact_id)`,
+			}
+		},
+	},
+	{
+		version: 3,
+		name:    "audit log user and resource indexes",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE INDEX audit_logs_user_id ON audit_logs (user_id, logged_at)`,
+				`CREATE INDEX audit_logs_resource ON audit_logs (resource, logged_at)`,
+			}
+		},
+	},
+	{
+		version: 4,
+		name:    "artifact search index",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE artifact_search (index_name ` + d.keyType + ` NOT NULL, index_value ` + d.keyType + ` NOT NULL, artifact_id ` + d.keyType + ` NOT NULL, PRIMARY KEY (index_name, index_value, artifact_id))`,
+				`CREATE INDEX artifact_search_artifact_id ON artifact_search (artifact_id)`,
+			}
+		},
+		backfill: func(s *SQLMetadataStore, tx *sql.Tx) error {
+			rows, err := tx.Query(`SELECT data FROM artifacts`)
+			if err != nil {
+				return err
+			}
+			var artifacts []*models.Artifact
+			for rows.Next() {
+				var data string
+				if err := rows.Scan(&data); err != nil {
+					rows.Close()
+					return err
+				}
+				var artifact models.Artifact
+				if err := json.Unmarshal([]byte(data), &artifact); err != nil {
+					rows.Close()
+					return fmt.Errorf("failed to read artifact: %w", err)
+				}
+				artifacts = append(artifacts, &artifact)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, artifact := range artifacts {
+				if err := s.putSearchEntries(tx, artifact); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 5,
+		name:    "artifact relations",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE artifact_relations (artifact_id ` + d.keyType + ` NOT NULL, relation_type ` + d.keyType + ` NOT NULL, target_id ` + d.keyType + ` NOT NULL, data TEXT NOT NULL, PRIMARY KEY (artifact_id, relation_type, target_id))`,
+				`CREATE INDEX artifact_relations_target_id ON artifact_relations (target_id)`,
+			}
+		},
+	},
+	{
+		version: 6,
+		name:    "resumable upload progress",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE upload_progress (upload_id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+			}
+		},
+	},
+	{
+		version: 7,
+		name:    "service accounts and API tokens",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE service_accounts (id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+				`CREATE TABLE api_tokens (id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+			}
+		},
+	},
+	{
+		version: 8,
+		name:    "known users",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE known_users (id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+			}
+		},
+	},
+	{
+		version: 9,
+		name:    "audit checkpoints",
+		statements: func(d *sqlDialect) []string {
+			return []string{
+				`CREATE TABLE audit_checkpoints (id ` + d.keyType + ` PRIMARY KEY, data TEXT NOT NULL)`,
+			}
+		},
+	},
+}
+
+// SQLMetadataStore implements MetadataStore on SQLite or PostgreSQL.
+// Records are stored as JSON documents next to the columns used for lookups,
+// so several astore replicas can share one PostgreSQL database.
+type SQLMetadataStore struct {
+	db         *sql.DB
+	dialect    *sqlDialect
+	tombstones bool // Archive supply-chain records removed with their artifact
+}
+
+// NewSQLMetadataStore opens a SQL metadata store and migrates it to the latest
+// schema. driver is sqlite or postgres; for sqlite the dsn is a file path.
+func NewSQLMetadataStore(driver, dsn string) (*SQLMetadataStore, error) {
+	s, err := openSQLMetadataStore(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.migrate(MigrationOptions{}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openSQLMetadataStore(driver, dsn string) (*SQLMetadataStore, error) {
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported SQL metadata driver %q", driver)
+	}
+
+	if driver == MetadataDriverSQLite && !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open(dialect.driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata database: %w", err)
+	}
+	if driver == MetadataDriverSQLite {
+		// SQLite allows a single writer; one connection avoids lock errors
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to metadata database: %w", err)
+	}
+
+	return &SQLMetadataStore{db: db, dialect: dialect}, nil
+}
+
+// Close closes the metadata store
+func (s *SQLMetadataStore) Close() error {
+	return s.db.Close()
+}
+
+// SchemaVersion returns the latest applied schema migration, or 0 for an
+// empty database
+func (s *SQLMetadataStore) SchemaVersion() (int, error) {
+	var tables int
+	if err := s.db.QueryRow(s.rebind(s.dialect.tableExists), "schema_migrations").Scan(&tables); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	return s.schemaVersion(s.db)
+}
+
+// === Bucket Operations ===
+
+// CreateBucket creates a new bucket
+func (s *SQLMetadataStore) CreateBucket(bucket *models.Bucket) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		exists, err := s.exists(tx, `SELECT 1 FROM buckets WHERE name = ?`, bucket.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("bucket %s already exists", bucket.Name)
+		}
+
+		bucket.CreatedAt = time.Now()
+		bucket.UpdatedAt = bucket.CreatedAt
+		data, err := json.Marshal(bucket)
+		if err != nil {
+			return fmt.Errorf("failed to marshal bucket: %w", err)
+		}
+
+		_, err = s.exec(tx, `INSERT INTO buckets (name, data) VALUES (?, ?)`, bucket.Name, string(data))
+		return err
+	})
+}
+
+// GetBucket retrieves a bucket by name
+func (s *SQLMetadataStore) GetBucket(name string) (*models.Bucket, error) {
+	var bucket models.Bucket
+	if err := s.get(&bucket, `SELECT data FROM buckets WHERE name = ?`, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("bucket %s not found", name)
+		}
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// ListBuckets lists all buckets
+func (s *SQLMetadataStore) ListBuckets() ([]*models.Bucket, error) {
+	return queryDocuments[models.Bucket](s, `SELECT data FROM buckets ORDER BY name`)
+}
+
+// DeleteBucket deletes a bucket
+func (s *SQLMetadataStore) DeleteBucket(name string) error {
+	_, err := s.exec(s.db, `DELETE FROM buckets WHERE name = ?`, name)
+	return err
+}
+
+// UpdateBucket updates bucket metadata
+func (s *SQLMetadataStore) UpdateBucket(bucket *models.Bucket) error {
+	bucket.UpdatedAt = time.Now()
+	data, err := json.Marshal(bucket)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bucket: %w", err)
+	}
+	return s.upsert(s.db, "buckets", []string{"name"}, bucket.Name, string(data))
+}
+
+// === Artifact Operations ===
+
+// StoreArtifact stores artifact metadata
+func (s *SQLMetadataStore) StoreArtifact(artifact *models.Artifact) error {
+	artifact.UpdatedAt = time.Now()
+	if artifact.CreatedAt.IsZero() {
+		artifact.CreatedAt = artifact.UpdatedAt
+	}
+
+	data, err := json.Marshal(artifact)
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact: %w", err)
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var existing string
+		err := tx.QueryRow(s.rebind(`SELECT data FROM artifacts WHERE bucket = ? AND object_key = ?`), artifact.Bucket, artifact.Key).Scan(&existing)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return err
+		default:
+			replaced, err := replacesContent([]byte(existing), artifact)
+			if err != nil {
+				return err
+			}
+			if replaced {
+				if err := s.removeSupplyChain(tx, artifactKey(artifact.Bucket, artifact.Key), models.TombstoneReasonOverwritten); err != nil {
+					return err
+				}
+			}
+		}
+		if err := s.upsert(tx, "artifacts", []string{"bucket", "object_key"}, artifact.Bucket, artifact.Key, string(data)); err != nil {
+			return err
+		}
+		return s.putSearchEntries(tx, artifact)
+	})
+}
+
+// GetArtifact retrieves artifact metadata
+func (s *SQLMetadataStore) GetArtifact(bucket, key string) (*models.Artifact, error) {
+	var artifact models.Artifact
+	if err := s.get(&artifact, `SELECT data FROM artifacts WHERE bucket = ? AND object_key = ?`, bucket, key); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s/%s", ErrArtifactNotFound, bucket, key)
+		}
+		return nil, err
+	}
+	return &artifact, nil
+}
+
+// ListArtifacts lists artifacts in a bucket with optional prefix
+func (s *SQLMetadataStore) ListArtifacts(bucket, prefix string, maxKeys int) ([]*models.Artifact, error) {
+	if maxKeys <= 0 {
+		return nil, nil
+	}
+	return queryDocuments[models.Artifact](s,
+		`SELECT data FROM artifacts WHERE bucket = ? AND object_key >= ? AND substr(object_key, 1, ?) = ? ORDER BY object_key LIMIT ?`,
+		bucket, prefix, utf8.RuneCountInString(prefix), prefix, maxKeys)
+}
+
+// DeleteArtifact deletes artifact metadata, its relations and its supply-chain
+// records, unless other artifacts relate to it
+func (s *SQLMetadataStore) DeleteArtifact(bucket, key string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		artifactID := artifactKey(bucket, key)
+		var dependent string
+		err := tx.QueryRow(s.rebind(`SELECT artifact_id FROM artifact_relations WHERE target_id = ? LIMIT 1`), artifactID).Scan(&dependent)
+		switch {
+		case err == nil:
+			return dependentsError(artifactID, dependent)
+		case err != sql.ErrNoRows:
+			return err
+		}
+		if _, err := s.exec(tx, `DELETE FROM artifact_relations WHERE artifact_id = ?`, artifactID); err != nil {
+			return err
+		}
+
+		if err := s.removeSupplyChain(tx, artifactID, models.TombstoneReasonDeleted); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, `DELETE FROM artifact_search WHERE artifact_id = ?`, artifactID); err != nil {
+			return err
+		}
+		_, err = s.exec(tx, `DELETE FROM artifacts WHERE bucket = ? AND object_key = ?`, bucket, key)
+		return err
+	})
+}
+
+// === Artifact Search ===
+
+// ScanArtifacts lists artifacts ordered by bucket and key following after
+func (s *SQLMetadataStore) ScanArtifacts(bucket, prefix, after string, limit int) ([]*models.Artifact, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if bucket != "" {
+		conditions = append(conditions, "bucket = ? AND object_key >= ? AND substr(object_key, 1, ?) = ?")
+		args = append(args, bucket, prefix, utf8.RuneCountInString(prefix), prefix)
+	}
+	if after != "" {
+		afterBucket, afterKey, _ := strings.Cut(after, "/")
+		conditions = append(conditions, "(bucket > ? OR (bucket = ? AND object_key > ?))")
+		args = append(args, afterBucket, afterBucket, afterKey)
+	}
+
+	query := `SELECT data FROM artifacts`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY bucket, object_key`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return queryDocuments[models.Artifact](s, query, args...)
+}
+
+// FindArtifacts lists the IDs of artifacts indexed under value
+func (s *SQLMetadataStore) FindArtifacts(index, value string) ([]string, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT artifact_id FROM artifact_search WHERE index_name = ? AND index_value = ? ORDER BY artifact_id`), index, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// putSearchEntries replaces the search index entries of an artifact
+func (s *SQLMetadataStore) putSearchEntries(q sqlQuerier, artifact *models.Artifact) error {
+	artifactID := artifactKey(artifact.Bucket, artifact.Key)
+	if _, err := s.exec(q, `DELETE FROM artifact_search WHERE artifact_id = ?`, artifactID); err != nil {
+		return err
+	}
+	for _, entry := range artifactIndexEntries(artifact) {
+		if _, err := s.exec(q, `INSERT INTO artifact_search (index_name, index_value, artifact_id) VALUES (?, ?, ?)`,
+			entry.index, entry.value, artifactID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importArtifact stores an exported artifact record and re-indexes it
+func (s *SQLMetadataStore) importArtifact(tx *sql.Tx, data []byte) error {
+	var artifact models.Artifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return err
+	}
+	if err := s.upsert(tx, "artifacts", []string{"bucket", "object_key"}, artifact.Bucket, artifact.Key, string(data)); err != nil {
+		return err
+	}
+	return s.putSearchEntries(tx, &artifact)
+}
+
+// === Artifact Relations ===
+
+// AddArtifactRelation stores a relation, replacing an identical one
+func (s *SQLMetadataStore) AddArtifactRelation(relation *models.ArtifactRelation) error {
+	if err := validateRelation(relation); err != nil {
+		return err
+	}
+	if relation.CreatedAt.IsZero() {
+		relation.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(relation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal relation: %w", err)
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, id := range []string{relation.ArtifactID, relation.TargetID} {
+			bucket, key, _ := strings.Cut(id, "/")
+			found, err := s.exists(tx, `SELECT 1 FROM artifacts WHERE bucket = ? AND object_key = ?`, bucket, key)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("artifact %s not found", id)
+			}
+		}
+		return s.upsert(tx, "artifact_relations", []string{"artifact_id", "relation_type", "target_id"},
+			relation.ArtifactID, string(relation.Type), relation.TargetID, string(data))
+	})
+}
+
+// RemoveArtifactRelation removes one relation
+func (s *SQLMetadataStore) RemoveArtifactRelation(artifactID, targetID string, relationType models.RelationType) error {
+	result, err := s.exec(s.db, `DELETE FROM artifact_relations WHERE artifact_id = ? AND relation_type = ? AND target_id = ?`, artifactID, string(relationType), targetID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s %s %s", ErrRelationNotFound, artifactID, relationType, targetID)
+	}
+	return nil
+}
+
+// ListRelationsFrom lists the relations of an artifact to its targets
+func (s *SQLMetadataStore) ListRelationsFrom(artifactID string) ([]*models.ArtifactRelation, error) {
+	return queryDocuments[models.ArtifactRelation](s, `SELECT data FROM artifact_relations WHERE artifact_id = ? ORDER BY relation_type, target_id`, artifactID)
+}
+
+// ListRelationsTo lists the relations of other artifacts to a target
+func (s *SQLMetadataStore) ListRelationsTo(targetID string) ([]*models.ArtifactRelation, error) {
+	return queryDocuments[models.ArtifactRelation](s, `SELECT data FROM artifact_relations WHERE target_id = ? ORDER BY relation_type, artifact_id`, targetID)
+}
+
+// === Multipart Upload Operations ===
+
+// CreateMultipartUpload creates a new multipart upload
+func (s *SQLMetadataStore) CreateMultipartUpload(upload *models.MultipartUpload) error {
+	upload.InitiatedAt = time.Now()
+	return s.UpdateMultipartUpload(upload)
+}
+
+// GetMultipartUpload retrieves a multipart upload
+func (s *SQLMetadataStore) GetMultipartUpload(uploadID string) (*models.MultipartUpload, error) {
+	var upload models.MultipartUpload
+	if err := s.get(&upload, `SELECT data FROM multipart_uploads WHERE upload_id = ?`, uploadID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("multipart upload %s not found", uploadID)
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// UpdateMultipartUpload updates multipart upload state
+func (s *SQLMetadataStore) UpdateMultipartUpload(upload *models.MultipartUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal multipart upload: %w", err)
+	}
+	return s.upsert(s.db, "multipart_uploads", []string{"upload_id"}, upload.UploadID, string(data))
+}
+
+// DeleteMultipartUpload deletes a multipart upload
+func (s *SQLMetadataStore) DeleteMultipartUpload(uploadID string) error {
+	_, err := s.exec(s.db, `DELETE FROM multipart_uploads WHERE upload_id = ?`, uploadID)
+	return err
+}
+
+// === Upload Progress Operations ===
+
+// CreateUploadProgress starts tracking a resumable upload
+func (s *SQLMetadataStore) CreateUploadProgress(progress *models.UploadProgress) error {
+	progress.StartedAt = time.Now()
+	progress.LastActivity = progress.StartedAt
+	if progress.Status == "" {
+		progress.Status = models.UploadStatusInProgress
+	}
+	return s.UpdateUploadProgress(progress)
+}
+
+// GetUploadProgress retrieves the progress of a resumable upload
+func (s *SQLMetadataStore) GetUploadProgress(uploadID string) (*models.UploadProgress, error) {
+	var progress models.UploadProgress
+	if err := s.get(&progress, `SELECT data FROM upload_progress WHERE upload_id = ?`, uploadID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("upload %s not found", uploadID)
+		}
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// UpdateUploadProgress records the progress of a resumable upload
+func (s *SQLMetadataStore) UpdateUploadProgress(progress *models.UploadProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload progress: %w", err)
+	}
+	return s.upsert(s.db, "upload_progress", []string{"upload_id"}, progress.UploadID, string(data))
+}
+
+// DeleteUploadProgress stops tracking a resumable upload
+func (s *SQLMetadataStore) DeleteUploadProgress(uploadID string) error {
+	_, err := s.exec(s.db, `DELETE FROM upload_progress WHERE upload_id = ?`, uploadID)
+	return err
+}
+
+// ListUploadProgress lists all tracked resumable uploads
+func (s *SQLMetadataStore) ListUploadProgress() ([]*models.UploadProgress, error) {
+	return queryDocuments[models.UploadProgress](s, `SELECT data FROM upload_progress ORDER BY upload_id`)
+}
+
+// === Policy Operations ===
+
+// StorePolicy stores a policy
+func (s *SQLMetadataStore) StorePolicy(policy *models.Policy) error {
+	policy.UpdatedAt = time.Now()
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = policy.UpdatedAt
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal policy: %w", err)
+	}
+	return s.upsert(s.db, "policies", []string{"id"}, policy.ID, string(data))
+}
+
+// GetPolicy retrieves a policy by ID
+func (s *SQLMetadataStore) GetPolicy(id string) (*models.Policy, error) {
+	var policy models.Policy
+	if err := s.get(&policy, `SELECT data FROM policies WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("policy %s not found", id)
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ListPolicies lists all policies
+func (s *SQLMetadataStore) ListPolicies() ([]*models.Policy, error) {
+	return queryDocuments[models.Policy](s, `SELECT data FROM policies ORDER BY id`)
+}
+
+// DeletePolicy deletes a policy
+func (s *SQLMetadataStore) DeletePolicy(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM policies WHERE id = ?`, id)
+	return err
+}
+
+// ApplyPolicyChanges stores and deletes policies in one transaction
+func (s *SQLMetadataStore) ApplyPolicyChanges(stored []*models.Policy, deleted []string) error {
+	now := time.Now()
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, policy := range stored {
+			if policy.ID == "" {
+				return fmt.Errorf("policy ID cannot be empty")
+			}
+			policy.UpdatedAt = now
+			if policy.CreatedAt.IsZero() {
+				policy.CreatedAt = now
+			}
+
+			data, err := json.Marshal(policy)
+			if err != nil {
+				return fmt.Errorf("failed to marshal policy: %w", err)
+			}
+			if err := s.upsert(tx, "policies", []string{"id"}, policy.ID, string(data)); err != nil {
+				return err
+			}
+		}
+		for _, id := range deleted {
+			if _, err := s.exec(tx, `DELETE FROM policies WHERE id = ?`, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// === Service Account and API Token Operations ===
+
+// StoreServiceAccount stores a service account
+func (s *SQLMetadataStore) StoreServiceAccount(account *models.ServiceAccount) error {
+	account.UpdatedAt = time.Now()
+	if account.CreatedAt.IsZero() {
+		account.CreatedAt = account.UpdatedAt
+	}
+
+	data, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service account: %w", err)
+	}
+	return s.upsert(s.db, "service_accounts", []string{"id"}, account.ID, string(data))
+}
+
+// GetServiceAccount retrieves a service account by ID
+func (s *SQLMetadataStore) GetServiceAccount(id string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := s.get(&account, `SELECT data FROM service_accounts WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("service account %s not found", id)
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListServiceAccounts lists all service accounts
+func (s *SQLMetadataStore) ListServiceAccounts() ([]*models.ServiceAccount, error) {
+	return queryDocuments[models.ServiceAccount](s, `SELECT data FROM service_accounts ORDER BY id`)
+}
+
+// DeleteServiceAccount deletes a service account
+func (s *SQLMetadataStore) DeleteServiceAccount(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM service_accounts WHERE id = ?`, id)
+	return err
+}
+
+// StoreAPIToken stores an API token
+func (s *SQLMetadataStore) StoreAPIToken(token *models.APIToken) error {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal API token: %w", err)
+	}
+	return s.upsert(s.db, "api_tokens", []string{"id"}, token.ID, string(data))
+}
+
+// GetAPIToken retrieves an API token by ID
+func (s *SQLMetadataStore) GetAPIToken(id string) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := s.get(&token, `SELECT data FROM api_tokens WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API token %s not found", id)
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListAPITokens lists all API tokens
+func (s *SQLMetadataStore) ListAPITokens() ([]*models.APIToken, error) {
+	return queryDocuments[models.APIToken](s, `SELECT data FROM api_tokens ORDER BY id`)
+}
+
+// UpdateAPIToken applies update to a stored API token. The row is only
+// replaced if it still holds the token update was given, and update is
+// applied again to the newer token otherwise.
+func (s *SQLMetadataStore) UpdateAPIToken(id string, update func(token *models.APIToken) error) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		var current string
+		if err := s.db.QueryRow(s.rebind(`SELECT data FROM api_tokens WHERE id = ?`), id).Scan(&current); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("API token %s not found", id)
+			}
+			return err
+		}
+
+		var token models.APIToken
+		if err := json.Unmarshal([]byte(current), &token); err != nil {
+			return err
+		}
+		if err := update(&token); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(&token)
+		if err != nil {
+			return fmt.Errorf("failed to marshal API token: %w", err)
+		}
+		result, err := s.exec(s.db, `UPDATE api_tokens SET data = ? WHERE id = ? AND data = ?`, string(data), id, current)
+		if err != nil {
+			return err
+		}
+		if updated, err := result.RowsAffected(); err != nil || updated == 1 {
+			return err
+		}
+	}
+	return fmt.Errorf("API token %s changed during %d update attempts", id, maxUpdateAttempts)
+}
+
+// DeleteAPIToken deletes an API token
+func (s *SQLMetadataStore) DeleteAPIToken(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM api_tokens WHERE id = ?`, id)
+	return err
+}
+
+// === Known User Operations ===
+
+// StoreKnownUser stores a user seen authenticating
+func (s *SQLMetadataStore) StoreKnownUser(user *models.KnownUser) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshal known user: %w", err)
+	}
+	return s.upsert(s.db, "known_users", []string{"id"}, user.ID, string(data))
+}
+
+// GetKnownUser retrieves a known user by ID
+func (s *SQLMetadataStore) GetKnownUser(id string) (*models.KnownUser, error) {
+	var user models.KnownUser
+	if err := s.get(&user, `SELECT data FROM known_users WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("known user %s not found", id)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListKnownUsers lists all known users
+func (s *SQLMetadataStore) ListKnownUsers() ([]*models.KnownUser, error) {
+	return queryDocuments[models.KnownUser](s, `SELECT data FROM known_users ORDER BY id`)
+}
+
+// DeleteKnownUser deletes a known user
+func (s *SQLMetadataStore) DeleteKnownUser(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM known_users WHERE id = ?`, id)
+	return err
+}
+
+// === Audit Log Operations ===
+
+// StoreAuditLog stores an audit log entry
+func (s *SQLMetadataStore) StoreAuditLog(log *models.AuditLog) error {
+	data, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log: %w", err)
+	}
+	return s.upsert(s.db, "audit_logs", []string{"id"}, log.ID, log.Timestamp.UnixNano(), log.UserID, log.Resource, string(data))
+}
+
+// AppendAuditLog links and stores an audit log entry in one transaction.
+// SQLite serializes transactions on its single connection; PostgreSQL
+// takes an advisory lock, so replicas sharing the database extend one chain.
+func (s *SQLMetadataStore) AppendAuditLog(log *models.AuditLog, link func(newest *models.AuditLog, checkpoint *models.AuditCheckpoint) error) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if s.dialect.lockAuditLog != "" {
+			if _, err := tx.Exec(s.dialect.lockAuditLog); err != nil {
+				return fmt.Errorf("failed to lock audit log: %w", err)
+			}
+		}
+
+		newest, err := latestDocument[models.AuditLog](tx, `SELECT data FROM audit_logs ORDER BY logged_at DESC, id DESC LIMIT 1`)
+		if err != nil {
+			return fmt.Errorf("failed to read newest audit log: %w", err)
+		}
+		checkpoint, err := latestDocument[models.AuditCheckpoint](tx, `SELECT data FROM audit_checkpoints ORDER BY id DESC LIMIT 1`)
+		if err != nil {
+			return fmt.Errorf("failed to read newest audit checkpoint: %w", err)
+		}
+		if err := link(newest, checkpoint); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(log)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit log: %w", err)
+		}
+		return s.upsert(tx, "audit_logs", []string{"id"}, log.ID, log.Timestamp.UnixNano(), log.UserID, log.Resource, string(data))
+	})
+}
+
+// latestDocument reads the document a query's single row holds, or nil if
+// it returns no row. The query has no parameters.
+func latestDocument[T any](q sqlQuerier, query string) (*T, error) {
+	var data string
+	if err := q.QueryRow(query).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	document := new(T)
+	if err := json.Unmarshal([]byte(data), document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// ListAuditLogs retrieves audit logs with optional filtering, newest first
+func (s *SQLMetadataStore) ListAuditLogs(userID string, resource string, startTime, endTime time.Time, limit int) ([]*models.AuditLog, error) {
+	page, err := s.QueryAuditLogs(&AuditLogQuery{UserID: userID, Resource: resource, StartTime: startTime, EndTime: endTime, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Logs, nil
+}
+
+// QueryAuditLogs retrieves a page of audit logs, newest first
+func (s *SQLMetadataStore) QueryAuditLogs(query *AuditLogQuery) (*AuditLogPage, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if query.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, query.UserID)
+	}
+	if query.Resource != "" {
+		conditions = append(conditions, "resource = ?")
+		args = append(args, query.Resource)
+	}
+	if !query.StartTime.IsZero() {
+		conditions = append(conditions, "logged_at >= ?")
+		args = append(args, query.StartTime.UnixNano())
+	}
+	if !query.EndTime.IsZero() {
+		conditions = append(conditions, "logged_at <= ?")
+		args = append(args, query.EndTime.UnixNano())
+	}
+	if query.Cursor != "" {
+		after, err := parseAuditCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "(logged_at < ? OR (logged_at = ? AND id < ?))")
+		args = append(args, after.nanos, after.nanos, after.id)
+	}
+
+	sqlQuery := `SELECT data FROM audit_logs`
+	if len(conditions) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += ` ORDER BY logged_at DESC, id DESC`
+	if query.Limit > 0 {
+		// One extra row tells whether another page follows
+		sqlQuery += ` LIMIT ?`
+		args = append(args, query.Limit+1)
+	}
+
+	logs, err := queryDocuments[models.AuditLog](s, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pageAuditLogs(logs, query.Limit), nil
+}
+
+// CountAuditLogs returns the number of stored audit log entries
+func (s *SQLMetadataStore) CountAuditLogs() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM audit_logs`).Scan(&count)
+	return count, err
+}
+
+// ExpireAuditLogs removes the oldest audit log entries in one transaction
+func (s *SQLMetadataStore) ExpireAuditLogs(before time.Time, limit int, expired func(logs []*models.AuditLog) (*models.AuditCheckpoint, error)) (int, error) {
+	var logs []*models.AuditLog
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		query := `SELECT data FROM audit_logs`
+		var args []interface{}
+		if !before.IsZero() {
+			query += ` WHERE logged_at < ?`
+			args = append(args, before.UnixNano())
+		}
+		query += ` ORDER BY logged_at, id`
+		if limit > 0 {
+			query += ` LIMIT ?`
+			args = append(args, limit)
+		}
+
+		rows, err := tx.Query(s.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var data string
+			if err := rows.Scan(&data); err != nil {
+				rows.Close()
+				return err
+			}
+			var log models.AuditLog
+			if err := json.Unmarshal([]byte(data), &log); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read audit log: %w", err)
+			}
+			logs = append(logs, &log)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(logs) == 0 {
+			return nil
+		}
+		if expired != nil {
+			checkpoint, err := expired(logs)
+			if err != nil {
+				return err
+			}
+			if checkpoint != nil {
+				data, err := json.Marshal(checkpoint)
+				if err != nil {
+					return fmt.Errorf("failed to marshal audit checkpoint: %w", err)
+				}
+				if err := s.upsert(tx, "audit_checkpoints", []string{"id"}, checkpoint.ID, string(data)); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, log := range logs {
+			if _, err := s.exec(tx, `DELETE FROM audit_logs WHERE id = ?`, log.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return len(logs), nil
+}
+
+// === Audit Checkpoint Operations ===
+
+// StoreAuditCheckpoint stores an audit log checkpoint
+func (s *SQLMetadataStore) StoreAuditCheckpoint(checkpoint *models.AuditCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit checkpoint: %w", err)
+	}
+	return s.upsert(s.db, "audit_checkpoints", []string{"id"}, checkpoint.ID, string(data))
+}
+
+// ListAuditCheckpoints lists all audit log checkpoints by sequence
+func (s *SQLMetadataStore) ListAuditCheckpoints() ([]*models.AuditCheckpoint, error) {
+	return queryDocuments[models.AuditCheckpoint](s, `SELECT data FROM audit_checkpoints ORDER BY id`)
+}
+
+// === Signature Operations ===
+
+// StoreSignature stores an artifact signature
+func (s *SQLMetadataStore) StoreSignature(signature *models.Signature) error {
+	data, err := json.Marshal(signature)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature: %w", err)
+	}
+	return s.upsert(s.db, "signatures", []string{"id"}, signature.ID, signature.ArtifactID, string(data))
+}
+
+// GetSignature retrieves a signature by ID
+func (s *SQLMetadataStore) GetSignature(id string) (*models.Signature, error) {
+	var signature models.Signature
+	if err := s.get(&signature, `SELECT data FROM signatures WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("signature %s not found", id)
+		}
+		return nil, err
+	}
+	return &signature, nil
+}
+
+// ListSignaturesForArtifact lists all signatures for an artifact
+func (s *SQLMetadataStore) ListSignaturesForArtifact(artifactID string) ([]*models.Signature, error) {
+	return queryDocuments[models.Signature](s, `SELECT data FROM signatures WHERE artifact_id = ? ORDER BY id`, artifactID)
+}
+
+// DeleteSignature deletes a signature
+func (s *SQLMetadataStore) DeleteSignature(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM signatures WHERE id = ?`, id)
+	return err
+}
+
+// === SBOM Operations ===
+
+// StoreSBOM stores an SBOM
+func (s *SQLMetadataStore) StoreSBOM(sbom *models.SBOM) error {
+	data, err := json.Marshal(sbom)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SBOM: %w", err)
+	}
+	return s.upsert(s.db, "sboms", []string{"id"}, sbom.ID, sbom.ArtifactID, string(data))
+}
+
+// GetSBOM retrieves an SBOM by ID
+func (s *SQLMetadataStore) GetSBOM(id string) (*models.SBOM, error) {
+	var sbom models.SBOM
+	if err := s.get(&sbom, `SELECT data FROM sboms WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("SBOM %s not found", id)
+		}
+		return nil, err
+	}
+	return &sbom, nil
+}
+
+// GetSBOMForArtifact retrieves the SBOM for an artifact
+func (s *SQLMetadataStore) GetSBOMForArtifact(artifactID string) (*models.SBOM, error) {
+	var sbom models.SBOM
+	if err := s.get(&sbom, `SELECT data FROM sboms WHERE artifact_id = ? ORDER BY id DESC LIMIT 1`, artifactID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("SBOM not found for artifact %s", artifactID)
+		}
+		return nil, err
+	}
+	return &sbom, nil
+}
+
+// DeleteSBOM deletes an SBOM
+func (s *SQLMetadataStore) DeleteSBOM(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM sboms WHERE id = ?`, id)
+	return err
+}
+
+// === Attestation Operations ===
+
+// StoreAttestation stores an attestation
+func (s *SQLMetadataStore) StoreAttestation(attestation *models.Attestation) error {
+	data, err := json.Marshal(attestation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attestation: %w", err)
+	}
+	return s.upsert(s.db, "attestations", []string{"id"}, attestation.ID, attestation.ArtifactID, string(data))
+}
+
+// GetAttestation retrieves an attestation by ID
+func (s *SQLMetadataStore) GetAttestation(id string) (*models.Attestation, error) {
+	var attestation models.Attestation
+	if err := s.get(&attestation, `SELECT data FROM attestations WHERE id = ?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attestation %s not found", id)
+		}
+		return nil, err
+	}
+	return &attestation, nil
+}
+
+// ListAttestationsForArtifact lists all attestations for an artifact
+func (s *SQLMetadataStore) ListAttestationsForArtifact(artifactID string) ([]*models.Attestation, error) {
+	return queryDocuments[models.Attestation](s, `SELECT data FROM attestations WHERE artifact_id = ? ORDER BY id`, artifactID)
+}
+
+// DeleteAttestation deletes an attestation
+func (s *SQLMetadataStore) DeleteAttestation(id string) error {
+	_, err := s.exec(s.db, `DELETE FROM attestations WHERE id = ?`, id)
+	return err
+}
+
+// === Supply Chain Tombstones ===
+
+// ListSupplyChainTombstones lists archived supply-chain records for an artifact
+func (s *SQLMetadataStore) ListSupplyChainTombstones(artifactID string) ([]*models.SupplyChainTombstone, error) {
+	return queryDocuments[models.SupplyChainTombstone](s, `SELECT data FROM supply_chain_tombstones WHERE artifact_id = ? ORDER BY id`, artifactID)
+}
+
+func (s *SQLMetadataStore) setTombstones(enabled bool) {
+	s.tombstones = enabled
+}
+
+// supplyChainTables maps supply-chain record kinds to their tables
+var supplyChainTables = []struct {
+	kind  string
+	table string
+}{
+	{RecordSignature, "signatures"},
+	{RecordSBOM, "sboms"},
+	{RecordAttestation, "attestations"},
+}
+
+// removeSupplyChain deletes every supply-chain record of an artifact,
+// archiving them first if tombstones are enabled
+func (s *SQLMetadataStore) removeSupplyChain(tx *sql.Tx, artifactID string, reason models.TombstoneReason) error {
+	now := time.Now()
+
+	for _, table := range supplyChainTables {
+		if s.tombstones {
+			if err := s.archiveSupplyChain(tx, table.kind, table.table, artifactID, reason, now); err != nil {
+				return err
+			}
+		}
+		if _, err := s.exec(tx, `DELETE FROM `+table.table+` WHERE artifact_id = ?`, artifactID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLMetadataStore) archiveSupplyChain(tx *sql.Tx, kind, table, artifactID string, reason models.TombstoneReason, now time.Time) error {
+	rows, err := tx.Query(s.rebind(`SELECT id, data FROM `+table+` WHERE artifact_id = ?`), artifactID)
+	if err != nil {
+		return err
+	}
+
+	var tombstones []*models.SupplyChainTombstone
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		tombstones = append(tombstones, newTombstone(kind, artifactID, id, []byte(data), reason, now))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tombstone := range tombstones {
+		data, err := json.Marshal(tombstone)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tombstone: %w", err)
+		}
+		if err := s.upsert(tx, "supply_chain_tombstones", []string{"id"}, tombstone.ID, artifactID, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// === Replication Operations ===
+
+// EnqueueReplicationTask appends a task to the replication queue and marks
+// the object as pending for the task's rule
+func (s *SQLMetadataStore) EnqueueReplicationTask(task *models.ReplicationTask, target string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if task.EnqueuedAt.IsZero() {
+			task.EnqueuedAt = time.Now()
+		}
+		if task.NextAttemptAt.IsZero() {
+			task.NextAttemptAt = task.EnqueuedAt
+		}
+
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to marshal replication task: %w", err)
+		}
+
+		var seq int64
+		if err := tx.QueryRow(s.rebind(`INSERT INTO replication_queue (data) VALUES (?) RETURNING sequence`), string(data)).Scan(&seq); err != nil {
+			return fmt.Errorf("failed to enqueue replication task: %w", err)
+		}
+		task.Sequence = uint64(seq)
+
+		return s.putReplicationStatus(tx, &models.ReplicationStatus{
+			RuleID:     task.RuleID,
+			Target:     target,
+			Bucket:     task.Bucket,
+			Key:        task.Key,
+			Op:         task.Op,
+			State:      models.ReplicationStatePending,
+			EnqueuedAt: task.EnqueuedAt,
+		})
+	})
+}
+
+// ListReplicationTasks returns queued tasks with a sequence above after, in enqueue order
+func (s *SQLMetadataStore) ListReplicationTasks(after uint64, limit int) ([]*models.ReplicationTask, error) {
+	query := `SELECT sequence, data FROM replication_queue WHERE sequence > ? ORDER BY sequence`
+	args := []interface{}{int64(after)}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*models.ReplicationTask
+	for rows.Next() {
+		var (
+			seq  int64
+			data string
+			task models.ReplicationTask
+		)
+		if err := rows.Scan(&seq, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, err
+		}
+		task.Sequence = uint64(seq)
+		tasks = append(tasks, &task)
+	}
+	return tasks, rows.Err()
+}
+
+// CountReplicationTasks returns the number of queued tasks
+func (s *SQLMetadataStore) CountReplicationTasks() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM replication_queue`).Scan(&count)
+	return count, err
+}
+
+// CompleteReplicationTask removes a task from the queue and records success
+func (s *SQLMetadataStore) CompleteReplicationTask(task *models.ReplicationTask) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx, `DELETE FROM replication_queue WHERE sequence = ?`, int64(task.Sequence)); err != nil {
+			return err
+		}
+
+		status, err := s.getReplicationStatus(tx, task.RuleID, task.Bucket, task.Key)
+		if err != nil {
+			return err
+		}
+		// A newer task for the same object keeps the object pending
+		if status.EnqueuedAt.After(task.EnqueuedAt) {
+			return nil
+		}
+
+		status.State = models.ReplicationStateCompleted
+		status.ReplicatedAt = time.Now()
+		status.Attempts = task.Attempts
+		status.LastError = ""
+		return s.putReplicationStatus(tx, status)
+	})
+}
+
+// FailReplicationTask records a failed attempt. Tasks with a zero
+// NextAttemptAt are removed from the queue and marked as failed.
+func (s *SQLMetadataStore) FailReplicationTask(task *models.ReplicationTask) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		final := task.NextAttemptAt.IsZero()
+
+		if final {
+			if _, err := s.exec(tx, `DELETE FROM replication_queue WHERE sequence = ?`, int64(task.Sequence)); err != nil {
+				return err
+			}
+		} else {
+			data, err := json.Marshal(task)
+			if err != nil {
+				return fmt.Errorf("failed to marshal replication task: %w", err)
+			}
+			if _, err := s.exec(tx, `UPDATE replication_queue SET data = ? WHERE sequence = ?`, string(data), int64(task.Sequence)); err != nil {
+				return err
+			}
+		}
+
+		status, err := s.getReplicationStatus(tx, task.RuleID, task.Bucket, task.Key)
+		if err != nil {
+			return err
+		}
+		if status.EnqueuedAt.After(task.EnqueuedAt) {
+			return nil
+		}
+
+		status.Attempts = task.Attempts
+		status.LastError = task.LastError
+		if final {
+			status.State = models.ReplicationStateFailed
+		}
+		return s.putReplicationStatus(tx, status)
+	})
+}
+
+// GetReplicationStatus retrieves the replication status of an object for a rule
+func (s *SQLMetadataStore) GetReplicationStatus(ruleID, bucket, key string) (*models.ReplicationStatus, error) {
+	return s.getReplicationStatus(s.db, ruleID, bucket, key)
+}
+
+// ListReplicationStatus lists replication status records, optionally for one object
+func (s *SQLMetadataStore) ListReplicationStatus(bucket, key string) ([]*models.ReplicationStatus, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if bucket != "" {
+		conditions = append(conditions, "bucket = ?")
+		args = append(args, bucket)
+	}
+	if key != "" {
+		conditions = append(conditions, "object_key = ?")
+		args = append(args, key)
+	}
+
+	query := `SELECT data FROM replication_status`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY rule_id, bucket, object_key`
+
+	return queryDocuments[models.ReplicationStatus](s, query, args...)
+}
+
+func (s *SQLMetadataStore) getReplicationStatus(q sqlQuerier, ruleID, bucket, key string) (*models.ReplicationStatus, error) {
+	var data string
+	err := q.QueryRow(s.rebind(`SELECT data FROM replication_status WHERE rule_id = ? AND bucket = ? AND object_key = ?`), ruleID, bucket, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("replication status for %s/%s under rule %s not found", bucket, key, ruleID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status models.ReplicationStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (s *SQLMetadataStore) putReplicationStatus(q sqlQuerier, status *models.ReplicationStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal replication status: %w", err)
+	}
+	return s.upsert(q, "replication_status", []string{"rule_id", "bucket", "object_key"}, status.RuleID, status.Bucket, status.Key, string(data))
+}
+
+// === Backup and Export ===
+
+// sqlRecordTables maps record kinds to their tables, in export order
+var sqlRecordTables = []struct {
+	kind  string
+	query string
+}{
+	{RecordBucket, `SELECT data FROM buckets ORDER BY name`},
+	{RecordArtifact, `SELECT data FROM artifacts ORDER BY bucket, object_key`},
+	{RecordMultipartUpload, `SELECT data FROM multipart_uploads ORDER BY upload_id`},
+	{RecordUploadProgress, `SELECT data FROM upload_progress ORDER BY upload_id`},
+	{RecordPolicy, `SELECT data FROM policies ORDER BY id`},
+	{RecordAuditLog, `SELECT data FROM audit_logs ORDER BY logged_at, id`},
+	{RecordSignature, `SELECT data FROM signatures ORDER BY id`},
+	{RecordSBOM, `SELECT data FROM sboms ORDER BY id`},
+	{RecordAttestation, `SELECT data FROM attestations ORDER BY id`},
+	{RecordReplicationTask, `SELECT sequence, data FROM replication_queue ORDER BY sequence`},
+	{RecordReplicationStatus, `SELECT data FROM replication_status ORDER BY rule_id, bucket, object_key`},
+	{RecordSupplyChainTombstone, `SELECT data FROM supply_chain_tombstones ORDER BY id`},
+	{RecordArtifactRelation, `SELECT data FROM artifact_relations ORDER BY artifact_id, relation_type, target_id`},
+	{RecordServiceAccount, `SELECT data FROM service_accounts ORDER BY id`},
+	{RecordAPIToken, `SELECT data FROM api_tokens ORDER BY id`},
+	{RecordKnownUser, `SELECT data FROM known_users ORDER BY id`},
+	{RecordAuditCheckpoint, `SELECT data FROM audit_checkpoints ORDER BY id`},
+}
+
+// Backup writes a consistent copy of a SQLite database to w. PostgreSQL
+// databases are backed up with pg_dump instead.
+func (s *SQLMetadataStore) Backup(w io.Writer) (int64, error) {
+	if s.dialect.backup == "" {
+		return 0, fmt.Errorf("%s metadata databases cannot be backed up by astore; use the database's own backup tools", s.dialect.driverName)
+	}
+
+	dir, err := os.MkdirTemp("", "astore-metadata-backup-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metadata.sqlite")
+	if _, err := s.db.Exec(s.dialect.backup, path); err != nil {
+		return 0, fmt.Errorf("failed to back up metadata database: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// ExportRecords calls fn with every record, read in a single transaction
+func (s *SQLMetadataStore) ExportRecords(fn func(record *MetadataRecord) error) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, table := range sqlRecordTables {
+			if err := s.exportTable(tx, table.kind, table.query, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLMetadataStore) exportTable(tx *sql.Tx, kind, query string, fn func(record *MetadataRecord) error) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if kind == RecordReplicationTask {
+			// The sequence lives in its own column
+			var (
+				seq  int64
+				task models.ReplicationTask
+			)
+			if err := rows.Scan(&seq, &data); err != nil {
+				return err
+			}
+			if err := json.Unmarshal([]byte(data), &task); err != nil {
+				return err
+			}
+			task.Sequence = uint64(seq)
+			encoded, err := json.Marshal(&task)
+			if err != nil {
+				return err
+			}
+			data = string(encoded)
+		} else if err := rows.Scan(&data); err != nil {
+			return err
+		}
+
+		if err := fn(&MetadataRecord{Kind: kind, Data: json.RawMessage(data)}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportRecords stores exported records in a single transaction
+func (s *SQLMetadataStore) ImportRecords(records []*MetadataRecord) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, record := range records {
+			f, err := record.fields()
+			if err != nil {
+				return err
+			}
+
+			data := string(record.Data)
+			switch record.Kind {
+			case RecordBucket:
+				err = s.upsert(tx, "buckets", []string{"name"}, f.Name, data)
+			case RecordArtifact:
+				err = s.importArtifact(tx, record.Data)
+			case RecordMultipartUpload:
+				err = s.upsert(tx, "multipart_uploads", []string{"upload_id"}, f.UploadID, data)
+			case RecordUploadProgress:
+				err = s.upsert(tx, "upload_progress", []string{"upload_id"}, f.UploadID, data)
+			case RecordPolicy:
+				err = s.upsert(tx, "policies", []string{"id"}, f.ID, data)
+			case RecordAuditLog:
+				err = s.upsert(tx, "audit_logs", []string{"id"}, f.ID, f.Timestamp.UnixNano(), f.UserID, f.Resource, data)
+			case RecordSignature:
+				err = s.upsert(tx, "signatures", []string{"id"}, f.ID, f.ArtifactID, data)
+			case RecordSBOM:
+				err = s.upsert(tx, "sboms", []string{"id"}, f.ID, f.ArtifactID, data)
+			case RecordAttestation:
+				err = s.upsert(tx, "attestations", []string{"id"}, f.ID, f.ArtifactID, data)
+			case RecordReplicationTask:
+				_, err = s.exec(tx, `INSERT INTO replication_queue (data) VALUES (?)`, data)
+			case RecordReplicationStatus:
+				err = s.upsert(tx, "replication_status", []string{"rule_id", "bucket", "object_key"}, f.RuleID, f.Bucket, f.Key, data)
+			case RecordSupplyChainTombstone:
+				err = s.upsert(tx, "supply_chain_tombstones", []string{"id"}, f.ID, f.ArtifactID, data)
+			case RecordArtifactRelation:
+				err = s.upsert(tx, "artifact_relations", []string{"artifact_id", "relation_type", "target_id"}, f.ArtifactID, f.Type, f.TargetID, data)
+			case RecordServiceAccount:
+				err = s.upsert(tx, "service_accounts", []string{"id"}, f.ID, data)
+			case RecordAPIToken:
+				err = s.upsert(tx, "api_tokens", []string{"id"}, f.ID, data)
+			case RecordKnownUser:
+				err = s.upsert(tx, "known_users", []string{"id"}, f.ID, data)
+			case RecordAuditCheckpoint:
+				err = s.upsert(tx, "audit_checkpoints", []string{"id"}, f.ID, data)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to import %s record: %w", record.Kind, err)
+			}
+		}
+		return nil
+	})
+}
+
+// === Compaction ===
+
+// compact rebuilds the database to release free pages. dsn locates the file
+// of a SQLite database.
+func (s *SQLMetadataStore) compact(dsn string) (*CompactResult, error) {
+	result := &CompactResult{}
+	if s.dialect.driverName == "postgres" {
+		_, err := s.db.Exec(`VACUUM ANALYZE`)
+		return result, err
+	}
+
+	path := strings.SplitN(dsn, "?", 2)[0]
+	if info, err := os.Stat(path); err == nil {
+		result.Before = info.Size()
+	}
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return nil, fmt.Errorf("failed to compact metadata database: %w", err)
+	}
+	// Fold the write-ahead log back into the database file
+	if _, err := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		result.After = info.Size()
+	}
+	return result, nil
+}
+
+// === Schema Migrations ===
+
+// migrate applies pending schema migrations, each in its own transaction
+func (s *SQLMetadataStore) migrate(opts MigrationOptions) (*SchemaStatus, error) {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]SchemaMigration, len(sqlMigrations))
+	for i, migration := range sqlMigrations {
+		migrations[i] = SchemaMigration{Version: migration.version, Description: migration.name}
+	}
+	status, err := planMigrations(current, migrations)
+	if err != nil || opts.DryRun || len(status.Pending) == 0 {
+		return status, err
+	}
+
+	if opts.BackupDir != "" {
+		if status.Backup, err = s.backup(opts.BackupDir, current); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at BIGINT NOT NULL)`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, migration := range sqlMigrations {
+		applied := false
+		err := s.withTx(func(tx *sql.Tx) error {
+			if s.dialect.lockMigrations != "" {
+				if _, err := tx.Exec(s.dialect.lockMigrations); err != nil {
+					return err
+				}
+			}
+
+			// Re-checked under the lock in case another replica migrated first
+			current, err := s.schemaVersion(tx)
+			if err != nil {
+				return err
+			}
+			if current >= migration.version {
+				return nil
+			}
+
+			for _, statement := range migration.statements(s.dialect) {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
+			if migration.backfill != nil {
+				if err := migration.backfill(s, tx); err != nil {
+					return err
+				}
+			}
+			_, err = s.exec(tx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				migration.version, migration.name, time.Now().Unix())
+			applied = err == nil
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply metadata migration %d (%s): %w", migration.version, migration.name, err)
+		}
+		if applied {
+			status.Applied = append(status.Applied, SchemaMigration{Version: migration.version, Description: migration.name})
+		}
+	}
+
+	status.Version = status.Latest
+	status.Pending = nil
+	return status, nil
+}
+
+// backup copies the database into dir before migrating from version
+func (s *SQLMetadataStore) backup(dir string, version int) (string, error) {
+	if s.dialect.backup == "" {
+		return "", fmt.Errorf("%s metadata databases cannot be backed up by astore; use the database's own backup tools", s.dialect.driverName)
+	}
+
+	var name string
+	if err := s.db.QueryRow(`SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&name); err != nil {
+		return "", fmt.Errorf("failed to locate metadata database: %w", err)
+	}
+	path, err := backupPath(dir, filepath.Base(name), version)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.db.Exec(s.dialect.backup, path); err != nil {
+		return "", fmt.Errorf("failed to back up metadata database: %w", err)
+	}
+	return path, nil
+}
+
+func (s *SQLMetadataStore) schemaVersion(q sqlQuerier) (int, error) {
+	var version sql.NullInt64
+	if err := q.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// === Helper Functions ===
+
+// sqlQuerier is implemented by *sql.DB and *sql.Tx
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (s *SQLMetadataStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind rewrites ? placeholders for dialects with numbered placeholders
+func (s *SQLMetadataStore) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLMetadataStore) exec(q sqlQuerier, query string, args ...interface{}) (sql.Result, error) {
+	return q.Exec(s.rebind(query), args...)
+}
+
+func (s *SQLMetadataStore) exists(q sqlQuerier, query string, args ...interface{}) (bool, error) {
+	var one int
+	err := q.QueryRow(s.rebind(query), args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// get decodes the JSON document selected by query into dest.
+// It returns sql.ErrNoRows if no row matches.
+func (s *SQLMetadataStore) get(dest interface{}, query string, args ...interface{}) error {
+	var data string
+	if err := s.db.QueryRow(s.rebind(query), args...).Scan(&data); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), dest)
+}
+
+// upsert inserts or replaces a row. values holds the key columns followed by
+// the table's remaining columns in schema order.
+func (s *SQLMetadataStore) upsert(q sqlQuerier, table string, keys []string, values ...interface{}) error {
+	columns := upsertColumns[table]
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	var updates []string
+	for _, column := range columns[len(keys):] {
+		updates = append(updates, column+" = excluded."+column)
+	}
+
+	query := `INSERT INTO ` + table + ` (` + strings.Join(columns, ", ") + `) VALUES (` + placeholders + `)` +
+		` ON CONFLICT (` + strings.Join(keys, ", ") + `) DO UPDATE SET ` + strings.Join(updates, ", ")
+	_, err := s.exec(q, query, values...)
+	return err
+}
+
+// upsertColumns lists the columns written by upsert, key columns first
+var upsertColumns = map[string][]string{
+	"buckets":            {"name", "data"},
+	"artifacts":          {"bucket", "object_key", "data"},
+	"multipart_uploads":  {"upload_id", "data"},
+	"policies":           {"id", "data"},
+	"audit_logs":         {"id", "logged_at", "user_id", "resource", "data"},
+	"signatures":         {"id", "artifact_id", "data"},
+	"sboms":              {"id", "artifact_id", "data"},
+	"attestations":       {"id", "artifact_id", "data"},
+	"replication_status": {"rule_id", "bucket", "object_key", "data"},
+
+	"supply_chain_tombstones": {"id", "artifact_id", "data"},
+	"artifact_relations":      {"artifact_id", "relation_type", "target_id", "data"},
+	"upload_progress":         {"upload_id", "data"},
+	"service_accounts":        {"id", "data"},
+	"api_tokens":              {"id", "data"},
+	"known_users":             {"id", "data"},
+	"audit_checkpoints":       {"id", "data"},
+}
+
+// queryDocuments decodes the JSON documents in the first column of a query
+func queryDocuments[T any](s *SQLMetadataStore, query string, args ...interface{}) ([]*T, error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		document := new(T)
+		if err := json.Unmarshal([]byte(data), document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
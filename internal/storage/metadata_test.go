@@ -19,7 +19,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("Create and get bucket", func(t *testing.T) {
 		// Given: A metadata store
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
@@ -41,7 +41,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("List buckets", func(t *testing.T) {
 		// Given: A metadata store with multiple buckets
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
@@ -55,7 +55,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("Store and retrieve artifact", func(t *testing.T) {
 		// Given: A metadata store
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
@@ -80,7 +80,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("List artifacts with prefix", func(t *testing.T) {
 		// Given: A metadata store with artifacts
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
@@ -105,7 +105,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("Delete artifact", func(t *testing.T) {
 		// Given: A metadata store with an artifact
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
@@ -120,7 +120,7 @@ func TestMetadataStore(t *testing.T) {
 
 	t.Run("Multipart upload lifecycle", func(t *testing.T) {
 		// Given: A metadata store
-		store, err := storage.NewMetadataStore(dbPath)
+		store, err := storage.NewBoltMetadataStore(dbPath)
 		test.AssertNoError(t, err, "creating metadata store")
 		defer store.Close()
 
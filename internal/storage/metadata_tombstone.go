@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+)
+
+// replacesContent reports whether storing artifact over the existing record
+// replaces its content. Supply-chain records describe content, so they only
+// survive an overwrite with the same digest.
+func replacesContent(existing []byte, artifact *models.Artifact) (bool, error) {
+	var previous struct {
+		Digest string `json:"digest"`
+	}
+	if err := json.Unmarshal(existing, &previous); err != nil {
+		return false, fmt.Errorf("failed to read stored artifact: %w", err)
+	}
+	return previous.Digest == "" || previous.Digest != artifact.Digest.String(), nil
+}
+
+// newTombstone archives a supply-chain record of kind removed with its artifact
+func newTombstone(kind, artifactID, recordID string, record []byte, reason models.TombstoneReason, now time.Time) *models.SupplyChainTombstone {
+	return &models.SupplyChainTombstone{
+		// Zero-padded so tombstones sort chronologically
+		ID:         fmt.Sprintf("%020d-%s-%s", now.UnixNano(), kind, recordID),
+		Kind:       kind,
+		ArtifactID: artifactID,
+		Reason:     reason,
+		ArchivedAt: now,
+		Record:     append(json.RawMessage(nil), record...),
+	}
+}
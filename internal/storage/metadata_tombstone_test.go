@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestSupplyChainTombstones(t *testing.T) {
+	for _, driver := range []string{storage.MetadataDriverBolt, storage.MetadataDriverSQLite} {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			t.Run("Removed records are archived when enabled", func(t *testing.T) {
+				// Given: A store keeping tombstones and a signed artifact
+				store, err := storage.OpenMetadataStore(&storage.MetadataConfig{
+					Driver:     driver,
+					DSN:        filepath.Join(t.TempDir(), "metadata.db"),
+					Tombstones: true,
+				})
+				test.AssertNoError(t, err, "open store")
+				defer store.Close()
+				test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: "app", Digest: "sha256:aaaa"}), "store artifact")
+				test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/app", SignedBy: "ci"}), "store signature")
+
+				// When: The artifact is overwritten, signed again and then deleted
+				test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: "app", Digest: "sha256:bbbb"}), "overwrite artifact")
+				test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-1", ArtifactID: "bucket/app"}), "store SBOM")
+				test.AssertNoError(t, store.DeleteArtifact("bucket", "app"), "delete artifact")
+
+				// Then: Both removals are archived in order with the original records
+				tombstones, err := store.ListSupplyChainTombstones("bucket/app")
+				test.AssertNoError(t, err, "list tombstones")
+				test.AssertEqual(t, 2, len(tombstones), "tombstone count")
+				test.AssertEqual(t, models.TombstoneReasonOverwritten, tombstones[0].Reason, "first reason")
+				test.AssertEqual(t, storage.RecordSignature, tombstones[0].Kind, "first kind")
+				test.AssertEqual(t, models.TombstoneReasonDeleted, tombstones[1].Reason, "second reason")
+				test.AssertEqual(t, storage.RecordSBOM, tombstones[1].Kind, "second kind")
+
+				var signature models.Signature
+				test.AssertNoError(t, json.Unmarshal(tombstones[0].Record, &signature), "decode archived signature")
+				test.AssertEqual(t, "ci", signature.SignedBy, "archived signer")
+
+				// And: Tombstones survive an export and import
+				var export bytes.Buffer
+				_, err = storage.ExportMetadata(store, &export)
+				test.AssertNoError(t, err, "export")
+				test.AssertTrue(t, bytes.Contains(export.Bytes(), []byte(`"kind":"supplyChainTombstone"`)), "tombstones exported")
+				_, err = storage.ImportMetadata(store, &export)
+				test.AssertNoError(t, err, "import")
+				tombstones, err = store.ListSupplyChainTombstones("bucket/app")
+				test.AssertNoError(t, err, "list tombstones after import")
+				test.AssertEqual(t, 2, len(tombstones), "tombstones after import")
+			})
+
+			t.Run("Removed records are dropped by default", func(t *testing.T) {
+				// Given: A store with default options and a signed artifact
+				store, err := storage.OpenMetadataStore(&storage.MetadataConfig{
+					Driver: driver,
+					DSN:    filepath.Join(t.TempDir(), "metadata.db"),
+				})
+				test.AssertNoError(t, err, "open store")
+				defer store.Close()
+				test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/app"}), "store signature")
+
+				// When: The artifact is deleted
+				test.AssertNoError(t, store.DeleteArtifact("bucket", "app"), "delete artifact")
+
+				// Then: Nothing is archived
+				tombstones, err := store.ListSupplyChainTombstones("bucket/app")
+				test.AssertNoError(t, err, "list tombstones")
+				test.AssertEqual(t, 0, len(tombstones), "tombstone count")
+			})
+		})
+	}
+}
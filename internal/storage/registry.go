@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BackendSettings is the backend-specific section of a BackendConfig
+type BackendSettings interface {
+	// Validate checks the settings and reports the first invalid field
+	Validate() error
+}
+
+// BackendFactory creates a backend from a validated configuration.
+// config.Settings holds the value returned by the registration's NewSettings.
+type BackendFactory func(config *BackendConfig) (Backend, error)
+
+// BackendRegistration describes a storage backend implementation
+type BackendRegistration struct {
+	// Name is the backend type used in BackendConfig.Type
+	Name string
+
+	// NewSettings returns a pointer to the backend's settings with defaults applied
+	NewSettings func() BackendSettings
+
+	// Factory creates the backend
+	Factory BackendFactory
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]*BackendRegistration)
+)
+
+// RegisterBackend makes a backend available to NewBackend. It is intended to be
+// called from init functions and panics if the registration is incomplete or
+// the name is already registered.
+func RegisterBackend(registration BackendRegistration) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if registration.Name == "" || registration.NewSettings == nil || registration.Factory == nil {
+		panic("storage: incomplete backend registration")
+	}
+	if _, exists := backends[registration.Name]; exists {
+		panic("storage: backend registered twice: " + registration.Name)
+	}
+	backends[registration.Name] = &registration
+}
+
+// RegisteredBackends returns the names of all registered backends
+func RegisteredBackends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupBackend finds the registration for a backend type
+func lookupBackend(backendType string) (*BackendRegistration, error) {
+	if backendType == "" {
+		backendType = "filesystem"
+	}
+
+	backendsMu.RLock()
+	registration, ok := backends[backendType]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, &AppError{
+			Code:    "INVALID_BACKEND",
+			Message: fmt.Sprintf("unsupported storage backend type: %s (registered: %s)", backendType, strings.Join(RegisteredBackends(), ", ")),
+		}
+	}
+	return registration, nil
+}
+
+// resolveSettings returns validated settings for a configuration, creating
+// defaults when none were provided
+func resolveSettings(registration *BackendRegistration, config *BackendConfig) (BackendSettings, error) {
+	defaults := registration.NewSettings()
+	settings := config.Settings
+	if settings == nil {
+		settings = defaults
+	}
+
+	if reflect.TypeOf(settings) != reflect.TypeOf(defaults) {
+		return nil, &AppError{
+			Code:    "INVALID_CONFIG",
+			Message: fmt.Sprintf("invalid %s backend configuration: expected %T settings, got %T", registration.Name, defaults, settings),
+		}
+	}
+
+	if err := settings.Validate(); err != nil {
+		return nil, &AppError{
+			Code:    "INVALID_CONFIG",
+			Message: fmt.Sprintf("invalid %s backend configuration", registration.Name),
+			Err:     err,
+		}
+	}
+	return settings, nil
+}
+
+// UnmarshalYAML decodes a backend configuration. Common options sit at the top
+// level and backend settings in a section named after the type, e.g.
+//
+//	type: s3
+//	enableChecksum: true
+//	s3:
+//	  bucket: artifacts
+//	  region: us-east-1
+//	cache:
+//	  directory: /var/cache/artifacts
+//	  maxSize: 10737418240
+//
+// Unknown fields in the backend section are rejected.
+func (c *BackendConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var common struct {
+		Type           string       `yaml:"type"`
+		EnableChecksum bool         `yaml:"enableChecksum"`
+		MaxRetries     int          `yaml:"maxRetries"`
+		RetryDelay     int          `yaml:"retryDelay"`
+		Cache          *CacheConfig `yaml:"cache"`
+	}
+	if err := unmarshal(&common); err != nil {
+		return err
+	}
+
+	registration, err := lookupBackend(common.Type)
+	if err != nil {
+		return err
+	}
+
+	var sections map[string]interface{}
+	if err := unmarshal(&sections); err != nil {
+		return err
+	}
+
+	settings := registration.NewSettings()
+	if section, ok := sections[registration.Name]; ok && section != nil {
+		data, err := yaml.Marshal(section)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(data, settings); err != nil {
+			return &AppError{
+				Code:    "INVALID_CONFIG",
+				Message: fmt.Sprintf("invalid %s backend configuration", registration.Name),
+				Err:     err,
+			}
+		}
+	}
+
+	c.Type = registration.Name
+	c.Settings = settings
+	c.EnableChecksum = common.EnableChecksum
+	c.MaxRetries = common.MaxRetries
+	c.RetryDelay = common.RetryDelay
+	c.Cache = common.Cache
+	return nil
+}
+
+// UnmarshalJSON decodes a backend configuration laid out as for UnmarshalYAML.
+// Unknown fields in the backend section are rejected.
+func (c *BackendConfig) UnmarshalJSON(data []byte) error {
+	var common struct {
+		Type           string       `json:"type"`
+		EnableChecksum bool         `json:"enableChecksum"`
+		MaxRetries     int          `json:"maxRetries"`
+		RetryDelay     int          `json:"retryDelay"`
+		Cache          *CacheConfig `json:"cache"`
+	}
+	if err := json.Unmarshal(data, &common); err != nil {
+		return err
+	}
+
+	registration, err := lookupBackend(common.Type)
+	if err != nil {
+		return err
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return err
+	}
+
+	settings := registration.NewSettings()
+	if section, ok := sections[registration.Name]; ok && string(section) != "null" {
+		decoder := json.NewDecoder(bytes.NewReader(section))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(settings); err != nil {
+			return &AppError{
+				Code:    "INVALID_CONFIG",
+				Message: fmt.Sprintf("invalid %s backend configuration", registration.Name),
+				Err:     err,
+			}
+		}
+	}
+
+	c.Type = registration.Name
+	c.Settings = settings
+	c.EnableChecksum = common.EnableChecksum
+	c.MaxRetries = common.MaxRetries
+	c.RetryDelay = common.RetryDelay
+	c.Cache = common.Cache
+	return nil
+}
+
+// BackendConfigDecodeHook returns a mapstructure decode hook that decodes
+// BackendConfig values through UnmarshalJSON, so their backend section is
+// filled from the registry. Configuration decoded with viper or mapstructure
+// must use it, e.g. viper.Unmarshal(&cfg, viper.DecodeHook(storage.BackendConfigDecodeHook())).
+func BackendConfigDecodeHook() func(from, to reflect.Type, data interface{}) (interface{}, error) {
+	configType := reflect.TypeOf(BackendConfig{})
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if to != configType || from == configType {
+			return data, nil
+		}
+
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode backend configuration: %w", err)
+		}
+		var config BackendConfig
+		if err := config.UnmarshalJSON(raw); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+}
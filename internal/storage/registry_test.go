@@ -0,0 +1,220 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"gopkg.in/yaml.v2"
+)
+
+// prefixedConfig configures a test backend that stores objects under a subdirectory
+type prefixedConfig struct {
+	RootDirectory string `yaml:"rootDirectory"`
+	Prefix        string `yaml:"prefix"`
+}
+
+func (c *prefixedConfig) Validate() error {
+	if c.Prefix == "" {
+		return fmt.Errorf("prefix is required")
+	}
+	return nil
+}
+
+var registerPrefixed sync.Once
+
+func registerPrefixedBackend() {
+	registerPrefixed.Do(func() {
+		storage.RegisterBackend(storage.BackendRegistration{
+			Name:        "prefixed",
+			NewSettings: func() storage.BackendSettings { return &prefixedConfig{Prefix: "default"} },
+			Factory: func(config *storage.BackendConfig) (storage.Backend, error) {
+				settings := config.Settings.(*prefixedConfig)
+				return storage.NewFileSystemBackend(settings.RootDirectory+"/"+settings.Prefix, config.EnableChecksum)
+			},
+		})
+	})
+}
+
+func TestBackendRegistry(t *testing.T) {
+	registerPrefixedBackend()
+
+	t.Run("Built-in backends are registered", func(t *testing.T) {
+		names := strings.Join(storage.RegisteredBackends(), ",")
+		for _, name := range []string{"azure", "filesystem", "gcs", "s3"} {
+			test.AssertTrue(t, strings.Contains(names, name), name+" registered")
+		}
+	})
+
+	t.Run("Create registered backend", func(t *testing.T) {
+		// Given: A configuration for a custom backend
+		config := &storage.BackendConfig{
+			Type:     "prefixed",
+			Settings: &prefixedConfig{RootDirectory: t.TempDir(), Prefix: "objects"},
+		}
+
+		// When: Creating the backend
+		backend, err := storage.NewBackend(config)
+
+		// Then: The registered factory is used
+		test.AssertNoError(t, err, "create backend")
+		test.AssertEqual(t, "filesystem", backend.Name(), "backend name")
+	})
+
+	t.Run("Empty type defaults to filesystem", func(t *testing.T) {
+		backend, err := storage.NewBackend(&storage.BackendConfig{
+			Settings: &storage.FileSystemConfig{RootDirectory: t.TempDir()},
+		})
+		test.AssertNoError(t, err, "create backend")
+		test.AssertEqual(t, "filesystem", backend.Name(), "backend name")
+	})
+
+	t.Run("Unknown type lists registered backends", func(t *testing.T) {
+		_, err := storage.NewBackend(&storage.BackendConfig{Type: "sftp"})
+		test.AssertError(t, err, "unknown backend")
+		test.AssertTrue(t, strings.Contains(err.Error(), "filesystem"), "error lists registered backends")
+	})
+
+	t.Run("Validation errors name the backend and field", func(t *testing.T) {
+		// Given: An S3 configuration without a bucket
+		config := &storage.BackendConfig{
+			Type:     "s3",
+			Settings: &storage.S3Config{AccessKeyID: "id", SecretAccessKey: "secret"},
+		}
+
+		// When: Creating the backend
+		_, err := storage.NewBackend(config)
+
+		// Then: The error is specific to the backend
+		test.AssertError(t, err, "invalid config")
+		var appErr *storage.AppError
+		test.AssertTrue(t, errors.As(err, &appErr), "storage error")
+		test.AssertEqual(t, "INVALID_CONFIG", appErr.Code, "error code")
+		test.AssertEqual(t, "invalid s3 backend configuration: bucket is required", err.Error(), "error message")
+	})
+
+	t.Run("Settings must match the backend type", func(t *testing.T) {
+		_, err := storage.NewBackend(&storage.BackendConfig{
+			Type:     "gcs",
+			Settings: &storage.S3Config{Bucket: "b"},
+		})
+		test.AssertError(t, err, "mismatched settings")
+	})
+}
+
+func TestBackendConfigYAML(t *testing.T) {
+	registerPrefixedBackend()
+
+	t.Run("Decode backend section", func(t *testing.T) {
+		// Given: A YAML configuration with an s3 section
+		data := []byte(`
+type: s3
+enableChecksum: true
+maxRetries: 4
+s3:
+  bucket: artifacts
+  region: eu-west-1
+  accessKeyId: id
+  secretAccessKey: secret
+`)
+
+		// When: Decoding it
+		var config storage.BackendConfig
+		err := yaml.Unmarshal(data, &config)
+
+		// Then: Common and backend options are populated
+		test.AssertNoError(t, err, "decode config")
+		test.AssertEqual(t, "s3", config.Type, "type")
+		test.AssertTrue(t, config.EnableChecksum, "checksum enabled")
+		test.AssertEqual(t, 4, config.MaxRetries, "max retries")
+
+		settings, ok := config.Settings.(*storage.S3Config)
+		test.AssertTrue(t, ok, "s3 settings")
+		test.AssertEqual(t, "artifacts", settings.Bucket, "bucket")
+		test.AssertEqual(t, "eu-west-1", settings.Region, "region")
+		test.AssertTrue(t, settings.UseSSL, "default applied")
+	})
+
+	t.Run("Decode custom backend", func(t *testing.T) {
+		var config storage.BackendConfig
+		err := yaml.Unmarshal([]byte("type: prefixed\nprefixed:\n  rootDirectory: /data\n"), &config)
+		test.AssertNoError(t, err, "decode config")
+
+		settings := config.Settings.(*prefixedConfig)
+		test.AssertEqual(t, "/data", settings.RootDirectory, "root directory")
+		test.AssertEqual(t, "default", settings.Prefix, "default prefix")
+	})
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		var config storage.BackendConfig
+		err := yaml.Unmarshal([]byte("type: gcs\ngcs:\n  bucket: b\n  bucketName: typo\n"), &config)
+		test.AssertError(t, err, "unknown field")
+		test.AssertTrue(t, strings.Contains(err.Error(), "gcs"), "error names backend")
+	})
+
+	t.Run("Unknown type is rejected", func(t *testing.T) {
+		var config storage.BackendConfig
+		err := yaml.Unmarshal([]byte("type: webdav\n"), &config)
+		test.AssertError(t, err, "unknown type")
+	})
+}
+
+func TestBackendConfigJSON(t *testing.T) {
+	registerPrefixedBackend()
+
+	t.Run("Decode backend section", func(t *testing.T) {
+		// Given: A JSON configuration embedding a backend with an s3 section
+		data := []byte(`{"backend": {"type": "s3", "maxRetries": 4, "s3": {"bucket": "artifacts", "accessKeyId": "id", "secretAccessKey": "secret"}}}`)
+
+		// When: Decoding it
+		var config struct {
+			Backend *storage.BackendConfig `json:"backend"`
+		}
+		err := json.Unmarshal(data, &config)
+
+		// Then: Common and backend options are populated
+		test.AssertNoError(t, err, "decode config")
+		test.AssertEqual(t, 4, config.Backend.MaxRetries, "max retries")
+		settings, ok := config.Backend.Settings.(*storage.S3Config)
+		test.AssertTrue(t, ok, "s3 settings")
+		test.AssertEqual(t, "artifacts", settings.Bucket, "bucket")
+		test.AssertTrue(t, settings.UseSSL, "default applied")
+	})
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		var config storage.BackendConfig
+		err := json.Unmarshal([]byte(`{"type": "gcs", "gcs": {"bucket": "b", "bucketName": "typo"}}`), &config)
+		test.AssertError(t, err, "unknown field")
+		test.AssertTrue(t, strings.Contains(err.Error(), "gcs"), "error names backend")
+	})
+
+	t.Run("Decode hook fills settings from a map", func(t *testing.T) {
+		// Given: A backend section as viper provides it, with lower-cased keys
+		data := map[string]interface{}{
+			"type":     "prefixed",
+			"prefixed": map[string]interface{}{"rootdirectory": "/data"},
+		}
+
+		// When: Decoding it to a BackendConfig
+		hook := storage.BackendConfigDecodeHook()
+		decoded, err := hook(reflect.TypeOf(data), reflect.TypeOf(storage.BackendConfig{}), data)
+
+		// Then: The registered settings are filled
+		test.AssertNoError(t, err, "decode config")
+		config := decoded.(storage.BackendConfig)
+		settings := config.Settings.(*prefixedConfig)
+		test.AssertEqual(t, "/data", settings.RootDirectory, "root directory")
+		test.AssertEqual(t, "default", settings.Prefix, "default prefix")
+
+		// And: Other types pass through
+		passed, err := hook(reflect.TypeOf(""), reflect.TypeOf(""), "value")
+		test.AssertNoError(t, err, "other type")
+		test.AssertEqual(t, "value", passed.(string), "passed through")
+	})
+}
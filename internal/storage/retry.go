@@ -166,6 +166,18 @@ func (rb *RetryBackend) GetObjectHash(ctx context.Context, bucket, key string) (
 	return hash, err
 }
 
+// GetObjectETag gets object ETag with retry
+func (rb *RetryBackend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	var etag string
+	err := rb.retryer.Do(ctx, func(ctx context.Context) error {
+		e, etagErr := rb.backend.GetObjectETag(ctx, bucket, key)
+		etag = e
+		return rb.wrapError(etagErr)
+	})
+
+	return etag, err
+}
+
 // HealthCheck performs health check with retry
 func (rb *RetryBackend) HealthCheck(ctx context.Context) error {
 	return rb.retryer.Do(ctx, func(ctx context.Context) error {
@@ -184,7 +196,7 @@ func (rb *RetryBackend) wrapError(err error) error {
 	if appErr, ok := err.(*AppError); ok {
 		// Determine if error is retryable based on error code
 		switch appErr.Code {
-		case "NOT_FOUND", "BUCKET_NOT_EMPTY", "CHECKSUM_MISMATCH", "INVALID_CONFIG":
+		case "NOT_FOUND", "BUCKET_NOT_EMPTY", "CHECKSUM_MISMATCH", "INVALID_CONFIG", "INVALID_ARGUMENT", "INVALID_RANGE":
 			// These are not retryable
 			return reliabilityErrors.New(reliabilityErrors.ErrorCodeBadRequest, appErr.Message).WithDetail("original_code", appErr.Code)
 		case "READ_ERROR", "WRITE_ERROR", "DELETE_ERROR", "STAT_ERROR", "HASH_ERROR", "HEALTH_CHECK_FAILED":
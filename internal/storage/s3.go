@@ -26,40 +26,68 @@ type S3Backend struct {
 	enableChecksum bool
 }
 
-// NewS3Backend creates a new S3 storage backend
-func NewS3Backend(config *BackendConfig) (*S3Backend, error) {
-	if config.S3AccessKeyID == "" || config.S3SecretAccessKey == "" {
-		return nil, &AppError{
-			Code:    "INVALID_CONFIG",
-			Message: "S3 access key and secret key are required",
-		}
+// S3Config configures the S3 backend
+type S3Config struct {
+	Endpoint        string `json:"endpoint" yaml:"endpoint"` // Custom endpoint for S3-compatible services
+	Region          string `json:"region" yaml:"region"`
+	Bucket          string `json:"bucket" yaml:"bucket"`
+	AccessKeyID     string `json:"accessKeyId" yaml:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey" yaml:"secretAccessKey"`
+	UseSSL          bool   `json:"useSSL" yaml:"useSSL"`
+}
+
+// Validate checks the S3 configuration
+func (c *S3Config) Validate() error {
+	if c.AccessKeyID == "" {
+		return fmt.Errorf("accessKeyId is required")
+	}
+	if c.SecretAccessKey == "" {
+		return fmt.Errorf("secretAccessKey is required")
 	}
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	return nil
+}
+
+func init() {
+	RegisterBackend(BackendRegistration{
+		Name:        "s3",
+		NewSettings: func() BackendSettings { return &S3Config{UseSSL: true} },
+		Factory: func(config *BackendConfig) (Backend, error) {
+			return NewS3Backend(config.Settings.(*S3Config), config.EnableChecksum)
+		},
+	})
+}
 
-	if config.S3Bucket == "" {
+// NewS3Backend creates a new S3 storage backend
+func NewS3Backend(config *S3Config, enableChecksum bool) (*S3Backend, error) {
+	if err := config.Validate(); err != nil {
 		return nil, &AppError{
 			Code:    "INVALID_CONFIG",
-			Message: "S3 bucket name is required",
+			Message: "invalid s3 backend configuration",
+			Err:     err,
 		}
 	}
 
 	// Configure AWS session
 	awsConfig := &aws.Config{
-		Region: aws.String(config.S3Region),
+		Region: aws.String(config.Region),
 		Credentials: credentials.NewStaticCredentials(
-			config.S3AccessKeyID,
-			config.S3SecretAccessKey,
+			config.AccessKeyID,
+			config.SecretAccessKey,
 			"",
 		),
 	}
 
 	// Set custom endpoint if provided (for S3-compatible services like MinIO)
-	if config.S3Endpoint != "" {
-		awsConfig.Endpoint = aws.String(config.S3Endpoint)
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
 		awsConfig.S3ForcePathStyle = aws.Bool(true) // Required for MinIO and some S3-compatible services
 	}
 
 	// Disable SSL if specified
-	if !config.S3UseSSL {
+	if !config.UseSSL {
 		awsConfig.DisableSSL = aws.Bool(true)
 	}
 
@@ -80,8 +108,8 @@ func NewS3Backend(config *BackendConfig) (*S3Backend, error) {
 		client:         client,
 		uploader:       uploader,
 		downloader:     downloader,
-		bucket:         config.S3Bucket,
-		enableChecksum: config.EnableChecksum,
+		bucket:         config.Bucket,
+		enableChecksum: enableChecksum,
 	}, nil
 }
 
@@ -92,6 +120,10 @@ func (s3b *S3Backend) Name() string {
 
 // WriteObject writes an object to S3
 func (s3b *S3Backend) WriteObject(ctx context.Context, bucket, key string, reader io.Reader, size int64) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	var body io.Reader = reader
 	var hash string
 
@@ -118,7 +150,7 @@ func (s3b *S3Backend) WriteObject(ctx context.Context, bucket, key string, reade
 	// Prepare upload input
 	input := &s3manager.UploadInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 		Body:   body,
 	}
 
@@ -160,9 +192,13 @@ func (s3b *S3Backend) WriteObject(ctx context.Context, bucket, key string, reade
 
 // ReadObject reads an object from S3
 func (s3b *S3Backend) ReadObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 	}
 
 	result, err := s3b.client.GetObjectWithContext(ctx, input)
@@ -211,11 +247,28 @@ func (s3b *S3Backend) ReadObject(ctx context.Context, bucket, key string) (io.Re
 
 // ReadObjectRange reads a byte range from an object in S3
 func (s3b *S3Backend) ReadObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return nil, err
+	}
+
+	// S3 rejects unsatisfiable and empty ranges, so resolve them against the size first
+	size, err := s3b.GetObjectSize(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	length, err = resolveRange(offset, length, size)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
 	rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
 
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 		Range:  aws.String(rangeHeader),
 	}
 
@@ -249,12 +302,28 @@ func (s3b *S3Backend) ReadObjectRange(ctx context.Context, bucket, key string, o
 
 // DeleteObject deletes an object from S3
 func (s3b *S3Backend) DeleteObject(ctx context.Context, bucket, key string) error {
+	if err := validateObjectName(bucket, key); err != nil {
+		return err
+	}
+
+	// S3 deletes are idempotent; report missing objects like other backends
+	exists, err := s3b.ObjectExists(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &AppError{
+			Code:    "NOT_FOUND",
+			Message: "object not found",
+		}
+	}
+
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 	}
 
-	_, err := s3b.client.DeleteObjectWithContext(ctx, input)
+	_, err = s3b.client.DeleteObjectWithContext(ctx, input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
@@ -284,9 +353,13 @@ func (s3b *S3Backend) DeleteObject(ctx context.Context, bucket, key string) erro
 
 // ObjectExists checks if an object exists in S3
 func (s3b *S3Backend) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return false, err
+	}
+
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 	}
 
 	_, err := s3b.client.HeadObjectWithContext(ctx, input)
@@ -315,6 +388,10 @@ func (s3b *S3Backend) ObjectExists(ctx context.Context, bucket, key string) (boo
 
 // CreateBucket creates a new S3 bucket (or uses the configured bucket)
 func (s3b *S3Backend) CreateBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In S3 backend, we use prefixes within a single bucket
 	// So this is a no-op, just verify the main bucket exists
 	return nil
@@ -322,6 +399,10 @@ func (s3b *S3Backend) CreateBucket(ctx context.Context, bucket string) error {
 
 // DeleteBucket deletes an S3 bucket (no-op for prefix-based buckets)
 func (s3b *S3Backend) DeleteBucket(ctx context.Context, bucket string) error {
+	if err := validateBucketName(bucket); err != nil {
+		return err
+	}
+
 	// In S3 backend, we use prefixes within a single bucket
 	// So this is a no-op
 	return nil
@@ -329,6 +410,10 @@ func (s3b *S3Backend) DeleteBucket(ctx context.Context, bucket string) error {
 
 // BucketExists checks if a bucket exists (always true for configured bucket)
 func (s3b *S3Backend) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return false, err
+	}
+
 	// In S3 backend, we use prefixes within a single bucket
 	// Check if the main bucket exists
 	input := &s3.HeadBucketInput{
@@ -361,9 +446,13 @@ func (s3b *S3Backend) BucketExists(ctx context.Context, bucket string) (bool, er
 
 // GetObjectSize returns the size of an object in S3
 func (s3b *S3Backend) GetObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return 0, err
+	}
+
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 	}
 
 	result, err := s3b.client.HeadObjectWithContext(ctx, input)
@@ -403,10 +492,14 @@ func (s3b *S3Backend) GetObjectSize(ctx context.Context, bucket, key string) (in
 
 // GetObjectHash returns the SHA256 hash of an object
 func (s3b *S3Backend) GetObjectHash(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
 	// Check if hash is stored in metadata
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(s3b.getBucket(bucket)),
-		Key:    aws.String(key),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
 	}
 
 	result, err := s3b.client.HeadObjectWithContext(ctx, input)
@@ -460,6 +553,36 @@ func (s3b *S3Backend) GetObjectHash(ctx context.Context, bucket, key string) (st
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// GetObjectETag returns the ETag of an object
+func (s3b *S3Backend) GetObjectETag(ctx context.Context, bucket, key string) (string, error) {
+	if err := validateObjectName(bucket, key); err != nil {
+		return "", err
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(s3b.getBucket(bucket)),
+		Key:    aws.String(s3b.objectKey(bucket, key)),
+	}
+
+	result, err := s3b.client.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == s3.ErrCodeNoSuchKey) {
+			return "", &AppError{
+				Code:    "NOT_FOUND",
+				Message: "object not found",
+				Err:     err,
+			}
+		}
+		return "", &AppError{
+			Code:    "STAT_ERROR",
+			Message: "failed to get object metadata",
+			Err:     err,
+		}
+	}
+
+	return aws.StringValue(result.ETag), nil
+}
+
 // HealthCheck performs a health check on S3
 func (s3b *S3Backend) HealthCheck(ctx context.Context) error {
 	// Try to list objects (with max 1 result)
@@ -489,12 +612,17 @@ func (s3b *S3Backend) HealthCheck(ctx context.Context) error {
 
 // Helper methods
 
-// getBucket returns the full S3 key with bucket prefix
+// getBucket returns the configured S3 bucket that holds all logical buckets
 func (s3b *S3Backend) getBucket(bucket string) string {
-	// Use prefixes within the configured S3 bucket
 	return s3b.bucket
 }
 
+// objectKey returns the full S3 key with bucket prefix
+func (s3b *S3Backend) objectKey(bucket, key string) string {
+	// Use prefixes to organize objects within the S3 bucket
+	return fmt.Sprintf("%s/%s", bucket, key)
+}
+
 // checksumVerifyingReader verifies SHA256 checksum while reading
 type checksumVerifyingReader struct {
 	reader      io.ReadCloser
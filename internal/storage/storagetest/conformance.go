@@ -0,0 +1,303 @@
+// Package storagetest provides a conformance suite for storage.Backend implementations.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// Capabilities describes backend behaviour that legitimately differs
+type Capabilities struct {
+	// PrefixBuckets is set for backends that map buckets to key prefixes in a
+	// single provider bucket or container. Bucket creation and deletion are
+	// no-ops for these backends, so bucket lifecycle cases are skipped.
+	PrefixBuckets bool
+}
+
+// Factory creates the backend under test. It is called once per case.
+type Factory func(t *testing.T) storage.Backend
+
+// conformanceCase is a single behaviour every backend must share
+type conformanceCase struct {
+	name string
+	skip func(caps Capabilities) bool
+	run  func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string)
+}
+
+// runID keeps bucket names unique across runs against persistent emulators
+var (
+	runID   = time.Now().UnixNano()
+	counter atomic.Int64
+)
+
+// RunBackendConformance runs the conformance suite against a backend
+func RunBackendConformance(t *testing.T, newBackend Factory, caps Capabilities) {
+	for _, tc := range conformanceCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.skip != nil && tc.skip(caps) {
+				t.Skip("not applicable to this backend")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			backend := newBackend(t)
+			bucket := fmt.Sprintf("conformance-%d-%d", runID, counter.Add(1))
+			test.AssertNoError(t, backend.CreateBucket(ctx, bucket), "create bucket")
+
+			tc.run(t, ctx, backend, bucket)
+		})
+	}
+}
+
+var conformanceCases = []conformanceCase{
+	{
+		name: "Write and read object",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			written := put(t, ctx, backend, bucket, "object.txt", "conformance")
+			test.AssertEqual(t, int64(len("conformance")), written, "bytes written")
+			test.AssertEqual(t, "conformance", get(t, ctx, backend, bucket, "object.txt"), "content")
+		},
+	},
+	{
+		name: "Overwrite replaces content",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "first version")
+			put(t, ctx, backend, bucket, "object.txt", "second")
+			test.AssertEqual(t, "second", get(t, ctx, backend, bucket, "object.txt"), "content")
+
+			size, err := backend.GetObjectSize(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "get size")
+			test.AssertEqual(t, int64(len("second")), size, "size after overwrite")
+		},
+	},
+	{
+		name: "Nested keys",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "a/b/c/object.txt", "nested")
+			test.AssertEqual(t, "nested", get(t, ctx, backend, bucket, "a/b/c/object.txt"), "content")
+		},
+	},
+	{
+		name: "Zero-length object",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "empty", "")
+			test.AssertEqual(t, "", get(t, ctx, backend, bucket, "empty"), "content")
+
+			size, err := backend.GetObjectSize(ctx, bucket, "empty")
+			test.AssertNoError(t, err, "get size")
+			test.AssertEqual(t, int64(0), size, "size")
+
+			hash, err := backend.GetObjectHash(ctx, bucket, "empty")
+			test.AssertNoError(t, err, "get hash")
+			test.AssertEqual(t, sha256Hex(""), hash, "hash")
+
+			test.AssertEqual(t, "", getRange(t, ctx, backend, bucket, "empty", 0, 10), "range of empty object")
+		},
+	},
+	{
+		name: "Size and hash",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "hash me")
+
+			size, err := backend.GetObjectSize(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "get size")
+			test.AssertEqual(t, int64(7), size, "size")
+
+			hash, err := backend.GetObjectHash(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "get hash")
+			test.AssertEqual(t, sha256Hex("hash me"), hash, "hash")
+		},
+	},
+	{
+		name: "ETag changes on overwrite",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "first version")
+			first, err := backend.GetObjectETag(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "get first ETag")
+			test.AssertTrue(t, first != "", "ETag is set")
+
+			put(t, ctx, backend, bucket, "object.txt", "second")
+			second, err := backend.GetObjectETag(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "get second ETag")
+			test.AssertTrue(t, first != second, "ETag changes with the content")
+		},
+	},
+	{
+		name: "Range reads",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "0123456789")
+
+			test.AssertEqual(t, "234", getRange(t, ctx, backend, bucket, "object.txt", 2, 3), "inner range")
+			test.AssertEqual(t, "0123456789", getRange(t, ctx, backend, bucket, "object.txt", 0, 10), "whole object")
+			test.AssertEqual(t, "789", getRange(t, ctx, backend, bucket, "object.txt", 7, 100), "range clipped at end")
+			test.AssertEqual(t, "", getRange(t, ctx, backend, bucket, "object.txt", 10, 5), "range at end")
+			test.AssertEqual(t, "", getRange(t, ctx, backend, bucket, "object.txt", 3, 0), "zero length")
+		},
+	},
+	{
+		name: "Range past end is rejected",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "0123456789")
+
+			_, err := backend.ReadObjectRange(ctx, bucket, "object.txt", 11, 1)
+			assertCode(t, err, "INVALID_RANGE")
+
+			_, err = backend.ReadObjectRange(ctx, bucket, "object.txt", -1, 1)
+			assertCode(t, err, "INVALID_RANGE")
+		},
+	},
+	{
+		name: "Missing object",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			_, err := backend.ReadObject(ctx, bucket, "missing")
+			assertCode(t, err, "NOT_FOUND")
+
+			_, err = backend.ReadObjectRange(ctx, bucket, "missing", 0, 1)
+			assertCode(t, err, "NOT_FOUND")
+
+			_, err = backend.GetObjectSize(ctx, bucket, "missing")
+			assertCode(t, err, "NOT_FOUND")
+
+			_, err = backend.GetObjectHash(ctx, bucket, "missing")
+			assertCode(t, err, "NOT_FOUND")
+
+			_, err = backend.GetObjectETag(ctx, bucket, "missing")
+			assertCode(t, err, "NOT_FOUND")
+
+			exists, err := backend.ObjectExists(ctx, bucket, "missing")
+			test.AssertNoError(t, err, "object exists")
+			test.AssertFalse(t, exists, "missing object exists")
+		},
+	},
+	{
+		name: "Delete object",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			put(t, ctx, backend, bucket, "object.txt", "delete me")
+			test.AssertNoError(t, backend.DeleteObject(ctx, bucket, "object.txt"), "delete object")
+
+			exists, err := backend.ObjectExists(ctx, bucket, "object.txt")
+			test.AssertNoError(t, err, "object exists")
+			test.AssertFalse(t, exists, "deleted object exists")
+
+			assertCode(t, backend.DeleteObject(ctx, bucket, "object.txt"), "NOT_FOUND")
+		},
+	},
+	{
+		name: "Empty bucket and key are rejected",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			_, err := backend.WriteObject(ctx, bucket, "", bytes.NewReader([]byte("x")), 1)
+			assertCode(t, err, "INVALID_ARGUMENT")
+
+			_, err = backend.WriteObject(ctx, "", "object.txt", bytes.NewReader([]byte("x")), 1)
+			assertCode(t, err, "INVALID_ARGUMENT")
+
+			_, err = backend.ReadObject(ctx, bucket, "")
+			assertCode(t, err, "INVALID_ARGUMENT")
+
+			_, err = backend.ObjectExists(ctx, bucket, "")
+			assertCode(t, err, "INVALID_ARGUMENT")
+
+			assertCode(t, backend.DeleteObject(ctx, bucket, ""), "INVALID_ARGUMENT")
+			assertCode(t, backend.CreateBucket(ctx, ""), "INVALID_ARGUMENT")
+		},
+	},
+	{
+		name: "Buckets isolate objects",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			other := bucket + "-other"
+			test.AssertNoError(t, backend.CreateBucket(ctx, other), "create other bucket")
+
+			put(t, ctx, backend, bucket, "object.txt", "first bucket")
+			exists, err := backend.ObjectExists(ctx, other, "object.txt")
+			test.AssertNoError(t, err, "object exists")
+			test.AssertFalse(t, exists, "object visible in other bucket")
+		},
+	},
+	{
+		name: "Bucket lifecycle",
+		skip: func(caps Capabilities) bool { return caps.PrefixBuckets },
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			exists, err := backend.BucketExists(ctx, bucket)
+			test.AssertNoError(t, err, "bucket exists")
+			test.AssertTrue(t, exists, "created bucket exists")
+
+			test.AssertNoError(t, backend.CreateBucket(ctx, bucket), "create existing bucket")
+
+			put(t, ctx, backend, bucket, "object.txt", "content")
+			assertCode(t, backend.DeleteBucket(ctx, bucket), "BUCKET_NOT_EMPTY")
+
+			test.AssertNoError(t, backend.DeleteObject(ctx, bucket, "object.txt"), "delete object")
+			test.AssertNoError(t, backend.DeleteBucket(ctx, bucket), "delete empty bucket")
+
+			exists, err = backend.BucketExists(ctx, bucket)
+			test.AssertNoError(t, err, "bucket exists")
+			test.AssertFalse(t, exists, "deleted bucket exists")
+
+			assertCode(t, backend.DeleteBucket(ctx, bucket), "NOT_FOUND")
+		},
+	},
+	{
+		name: "Health check",
+		run: func(t *testing.T, ctx context.Context, backend storage.Backend, bucket string) {
+			test.AssertNoError(t, backend.HealthCheck(ctx), "health check")
+		},
+	},
+}
+
+// Helper functions
+
+func put(t *testing.T, ctx context.Context, backend storage.Backend, bucket, key, content string) int64 {
+	t.Helper()
+	written, err := backend.WriteObject(ctx, bucket, key, bytes.NewReader([]byte(content)), int64(len(content)))
+	test.AssertNoError(t, err, "write "+key)
+	return written
+}
+
+func get(t *testing.T, ctx context.Context, backend storage.Backend, bucket, key string) string {
+	t.Helper()
+	reader, err := backend.ReadObject(ctx, bucket, key)
+	return readAll(t, reader, err)
+}
+
+func getRange(t *testing.T, ctx context.Context, backend storage.Backend, bucket, key string, offset, length int64) string {
+	t.Helper()
+	reader, err := backend.ReadObjectRange(ctx, bucket, key, offset, length)
+	return readAll(t, reader, err)
+}
+
+func readAll(t *testing.T, reader io.ReadCloser, err error) string {
+	t.Helper()
+	test.AssertNoError(t, err, "open reader")
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	test.AssertNoError(t, err, "read content")
+	return string(data)
+}
+
+func assertCode(t *testing.T, err error, code string) {
+	t.Helper()
+	test.AssertError(t, err, "expected "+code)
+	var appErr *storage.AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("expected storage error %s but got %T: %v", code, err, err)
+	}
+	test.AssertEqual(t, code, appErr.Code, "error code")
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
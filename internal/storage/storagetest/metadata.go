@@ -0,0 +1,708 @@
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+// MetadataFactory creates an empty metadata store. It is called once per case.
+type MetadataFactory func(t *testing.T) storage.MetadataStore
+
+// metadataCase is a single behaviour every metadata store must share
+type metadataCase struct {
+	name string
+	run  func(t *testing.T, store storage.MetadataStore)
+}
+
+// RunMetadataStoreConformance runs the conformance suite against a metadata store
+func RunMetadataStoreConformance(t *testing.T, newStore MetadataFactory) {
+	for _, tc := range metadataCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStore(t)
+			t.Cleanup(func() { store.Close() })
+			tc.run(t, store)
+		})
+	}
+}
+
+var metadataCases = []metadataCase{
+	{
+		name: "Bucket lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			test.AssertNoError(t, store.CreateBucket(&models.Bucket{Name: "beta"}), "create bucket")
+			test.AssertNoError(t, store.CreateBucket(&models.Bucket{Name: "alpha"}), "create bucket")
+			test.AssertError(t, store.CreateBucket(&models.Bucket{Name: "alpha"}), "create duplicate bucket")
+
+			bucket, err := store.GetBucket("alpha")
+			test.AssertNoError(t, err, "get bucket")
+			test.AssertFalse(t, bucket.CreatedAt.IsZero(), "created timestamp set")
+
+			bucket.Tags = map[string]string{"team": "build"}
+			test.AssertNoError(t, store.UpdateBucket(bucket), "update bucket")
+			bucket, err = store.GetBucket("alpha")
+			test.AssertNoError(t, err, "get updated bucket")
+			test.AssertEqual(t, "build", bucket.Tags["team"], "updated tag")
+
+			buckets, err := store.ListBuckets()
+			test.AssertNoError(t, err, "list buckets")
+			test.AssertEqual(t, 2, len(buckets), "bucket count")
+			test.AssertEqual(t, "alpha", buckets[0].Name, "buckets sorted by name")
+
+			test.AssertNoError(t, store.DeleteBucket("alpha"), "delete bucket")
+			_, err = store.GetBucket("alpha")
+			test.AssertError(t, err, "get deleted bucket")
+		},
+	},
+	{
+		name: "Artifacts are listed by bucket and prefix",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			for _, key := range []string{"lib/b.jar", "lib/a.jar", "lib2/c.jar", "app/d.tar.gz"} {
+				test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases", Key: key, Size: 10}), "store artifact")
+			}
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases-other", Key: "lib/e.jar"}), "store artifact")
+
+			all, err := store.ListArtifacts("releases", "", 100)
+			test.AssertNoError(t, err, "list artifacts")
+			test.AssertEqual(t, 4, len(all), "artifacts in bucket")
+			test.AssertEqual(t, "app/d.tar.gz", all[0].Key, "artifacts sorted by key")
+
+			lib, err := store.ListArtifacts("releases", "lib/", 100)
+			test.AssertNoError(t, err, "list with prefix")
+			test.AssertEqual(t, 2, len(lib), "artifacts with prefix")
+			test.AssertEqual(t, "lib/a.jar", lib[0].Key, "first artifact with prefix")
+			test.AssertEqual(t, "lib/b.jar", lib[1].Key, "second artifact with prefix")
+
+			limited, err := store.ListArtifacts("releases", "lib", 2)
+			test.AssertNoError(t, err, "list with max keys")
+			test.AssertEqual(t, 2, len(limited), "max keys respected")
+		},
+	},
+	{
+		name: "Artifact overwrite and delete",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			artifact := &models.Artifact{Bucket: "bucket", Key: "app.jar", Size: 1, Metadata: map[string]string{"v": "1"}}
+			test.AssertNoError(t, store.StoreArtifact(artifact), "store artifact")
+			artifact.Size = 2
+			test.AssertNoError(t, store.StoreArtifact(artifact), "overwrite artifact")
+
+			stored, err := store.GetArtifact("bucket", "app.jar")
+			test.AssertNoError(t, err, "get artifact")
+			test.AssertEqual(t, int64(2), stored.Size, "overwritten size")
+			test.AssertEqual(t, "1", stored.Metadata["v"], "metadata preserved")
+
+			test.AssertNoError(t, store.DeleteArtifact("bucket", "app.jar"), "delete artifact")
+			_, err = store.GetArtifact("bucket", "app.jar")
+			test.AssertTrue(t, errors.Is(err, storage.ErrArtifactNotFound), "get deleted artifact")
+		},
+	},
+	{
+		name: "Multipart upload lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			upload := &models.MultipartUpload{UploadID: "upload-1", Bucket: "bucket", Key: "big.bin"}
+			test.AssertNoError(t, store.CreateMultipartUpload(upload), "create upload")
+
+			upload.Parts = append(upload.Parts, models.MultipartPart{PartNumber: 1, ETag: "etag", Size: 5})
+			test.AssertNoError(t, store.UpdateMultipartUpload(upload), "update upload")
+
+			stored, err := store.GetMultipartUpload("upload-1")
+			test.AssertNoError(t, err, "get upload")
+			test.AssertEqual(t, 1, len(stored.Parts), "parts")
+			test.AssertFalse(t, stored.InitiatedAt.IsZero(), "initiated timestamp set")
+
+			test.AssertNoError(t, store.DeleteMultipartUpload("upload-1"), "delete upload")
+			_, err = store.GetMultipartUpload("upload-1")
+			test.AssertError(t, err, "get deleted upload")
+		},
+	},
+	{
+		name: "Upload progress lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			progress := &models.UploadProgress{UploadID: "upload-1", Bucket: "bucket", Key: "big.iso", TotalSize: 100}
+			test.AssertNoError(t, store.CreateUploadProgress(progress), "create progress")
+			test.AssertEqual(t, models.UploadStatusInProgress, progress.Status, "initial status")
+			test.AssertFalse(t, progress.StartedAt.IsZero(), "started timestamp set")
+
+			progress.UploadedSize = 60
+			test.AssertNoError(t, store.UpdateUploadProgress(progress), "update progress")
+
+			stored, err := store.GetUploadProgress("upload-1")
+			test.AssertNoError(t, err, "get progress")
+			test.AssertEqual(t, int64(60), stored.UploadedSize, "uploaded size")
+			uploads, err := store.ListUploadProgress()
+			test.AssertNoError(t, err, "list progress")
+			test.AssertEqual(t, 1, len(uploads), "tracked uploads")
+
+			test.AssertNoError(t, store.DeleteUploadProgress("upload-1"), "delete progress")
+			_, err = store.GetUploadProgress("upload-1")
+			test.AssertError(t, err, "get deleted progress")
+		},
+	},
+	{
+		name: "Policy lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			policy := &models.Policy{ID: "p1", Name: "readers", Resource: "bucket", Actions: []string{"read"}, Effect: models.PolicyEffectAllow}
+			test.AssertNoError(t, store.StorePolicy(policy), "store policy")
+			test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p2", Effect: models.PolicyEffectDeny}), "store policy")
+
+			stored, err := store.GetPolicy("p1")
+			test.AssertNoError(t, err, "get policy")
+			test.AssertEqual(t, "readers", stored.Name, "policy name")
+
+			policies, err := store.ListPolicies()
+			test.AssertNoError(t, err, "list policies")
+			test.AssertEqual(t, 2, len(policies), "policy count")
+
+			test.AssertNoError(t, store.DeletePolicy("p1"), "delete policy")
+			_, err = store.GetPolicy("p1")
+			test.AssertError(t, err, "get deleted policy")
+		},
+	},
+	{
+		name: "Policy changes are applied together",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p1", Name: "old"}), "store policy")
+			test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "p2"}), "store policy")
+
+			// A failing change leaves every policy as it was
+			err := store.ApplyPolicyChanges([]*models.Policy{{ID: "p1", Name: "new"}, {ID: ""}}, []string{"p2"})
+			test.AssertError(t, err, "apply invalid changes")
+			stored, err := store.GetPolicy("p1")
+			test.AssertNoError(t, err, "get policy")
+			test.AssertEqual(t, "old", stored.Name, "policy unchanged")
+			_, err = store.GetPolicy("p2")
+			test.AssertNoError(t, err, "policy not deleted")
+
+			test.AssertNoError(t, store.ApplyPolicyChanges([]*models.Policy{{ID: "p1", Name: "new"}, {ID: "p3"}}, []string{"p2"}), "apply changes")
+			policies, err := store.ListPolicies()
+			test.AssertNoError(t, err, "list policies")
+			test.AssertEqual(t, 2, len(policies), "policy count")
+			stored, err = store.GetPolicy("p1")
+			test.AssertNoError(t, err, "get policy")
+			test.AssertEqual(t, "new", stored.Name, "policy updated")
+			_, err = store.GetPolicy("p2")
+			test.AssertError(t, err, "get deleted policy")
+		},
+	},
+	{
+		name: "Service account and API token lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			account := &models.ServiceAccount{ID: "sa-1", Name: "ci", Roles: []string{"developer"}}
+			test.AssertNoError(t, store.StoreServiceAccount(account), "store service account")
+			test.AssertFalse(t, account.CreatedAt.IsZero(), "created timestamp set")
+
+			storedAccount, err := store.GetServiceAccount("sa-1")
+			test.AssertNoError(t, err, "get service account")
+			test.AssertEqual(t, "ci", storedAccount.Name, "service account name")
+			accounts, err := store.ListServiceAccounts()
+			test.AssertNoError(t, err, "list service accounts")
+			test.AssertEqual(t, 1, len(accounts), "service account count")
+
+			token := &models.APIToken{ID: "tok-1", Name: "deploy", UserID: "sa-1", ServiceAccountID: "sa-1", SecretHash: "hash"}
+			test.AssertNoError(t, store.StoreAPIToken(token), "store token")
+			revokedAt := time.Now()
+			token.RevokedAt = &revokedAt
+			test.AssertNoError(t, store.StoreAPIToken(token), "update token")
+
+			storedToken, err := store.GetAPIToken("tok-1")
+			test.AssertNoError(t, err, "get token")
+			test.AssertEqual(t, "hash", storedToken.SecretHash, "secret hash")
+			test.AssertTrue(t, storedToken.RevokedAt != nil, "revocation stored")
+			tokens, err := store.ListAPITokens()
+			test.AssertNoError(t, err, "list tokens")
+			test.AssertEqual(t, 1, len(tokens), "token count")
+
+			lastUsedAt := time.Now()
+			err = store.UpdateAPIToken("tok-1", func(token *models.APIToken) error {
+				token.LastUsedAt = &lastUsedAt
+				return nil
+			})
+			test.AssertNoError(t, err, "update token in place")
+			storedToken, err = store.GetAPIToken("tok-1")
+			test.AssertNoError(t, err, "get updated token")
+			test.AssertTrue(t, storedToken.LastUsedAt != nil, "last use stored")
+			test.AssertTrue(t, storedToken.RevokedAt != nil, "revocation kept")
+			err = store.UpdateAPIToken("tok-1", func(token *models.APIToken) error {
+				return fmt.Errorf("rejected")
+			})
+			test.AssertError(t, err, "update returning an error")
+			test.AssertError(t, store.UpdateAPIToken("missing", func(*models.APIToken) error { return nil }), "update missing token")
+
+			test.AssertNoError(t, store.DeleteAPIToken("tok-1"), "delete token")
+			_, err = store.GetAPIToken("tok-1")
+			test.AssertError(t, err, "get deleted token")
+			test.AssertNoError(t, store.DeleteServiceAccount("sa-1"), "delete service account")
+			_, err = store.GetServiceAccount("sa-1")
+			test.AssertError(t, err, "get deleted service account")
+		},
+	},
+	{
+		name: "Known user lifecycle",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			seen := time.Now().UTC().Truncate(time.Second)
+			user := &models.KnownUser{ID: "user-1", Username: "alice", Roles: []string{"developer"}, Source: "oidc", FirstSeen: seen, LastSeen: seen}
+			test.AssertNoError(t, store.StoreKnownUser(user), "store known user")
+			user.Groups = []string{"web"}
+			test.AssertNoError(t, store.StoreKnownUser(user), "update known user")
+
+			stored, err := store.GetKnownUser("user-1")
+			test.AssertNoError(t, err, "get known user")
+			test.AssertEqual(t, "alice", stored.Username, "username")
+			test.AssertEqual(t, "web", strings.Join(stored.Groups, ","), "groups updated")
+			test.AssertTrue(t, stored.LastSeen.Equal(seen), "last seen")
+			users, err := store.ListKnownUsers()
+			test.AssertNoError(t, err, "list known users")
+			test.AssertEqual(t, 1, len(users), "known user count")
+
+			test.AssertNoError(t, store.DeleteKnownUser("user-1"), "delete known user")
+			_, err = store.GetKnownUser("user-1")
+			test.AssertError(t, err, "get deleted known user")
+		},
+	},
+	{
+		name: "Audit checkpoints are listed by sequence",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			for _, sequence := range []uint64{1000, 20, 300} {
+				checkpoint := &models.AuditCheckpoint{ID: fmt.Sprintf("%020d", sequence), Sequence: sequence, Hash: fmt.Sprintf("hash-%d", sequence), Timestamp: time.Now()}
+				test.AssertNoError(t, store.StoreAuditCheckpoint(checkpoint), "store audit checkpoint")
+			}
+
+			checkpoints, err := store.ListAuditCheckpoints()
+			test.AssertNoError(t, err, "list audit checkpoints")
+			test.AssertEqual(t, 3, len(checkpoints), "checkpoint count")
+			test.AssertEqual(t, uint64(20), checkpoints[0].Sequence, "first checkpoint")
+			test.AssertEqual(t, "hash-1000", checkpoints[2].Hash, "last checkpoint")
+		},
+	},
+	{
+		name: "Audit log appends see the newest entry and checkpoint",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			var newest *models.AuditLog
+			var checkpoint *models.AuditCheckpoint
+			link := func(log *models.AuditLog, sequence uint64) func(*models.AuditLog, *models.AuditCheckpoint) error {
+				return func(n *models.AuditLog, c *models.AuditCheckpoint) error {
+					newest, checkpoint = n, c
+					log.Sequence = sequence
+					return nil
+				}
+			}
+			base := time.Now()
+
+			first := &models.AuditLog{ID: "log-1", Timestamp: base, Resource: "a"}
+			test.AssertNoError(t, store.AppendAuditLog(first, link(first, 1)), "append first")
+			test.AssertTrue(t, newest == nil && checkpoint == nil, "empty log has no head")
+
+			second := &models.AuditLog{ID: "log-2", Timestamp: base.Add(time.Second), Resource: "a"}
+			test.AssertNoError(t, store.AppendAuditLog(second, link(second, 2)), "append second")
+			test.AssertTrue(t, newest != nil, "head after first append")
+			test.AssertEqual(t, uint64(1), newest.Sequence, "newest entry")
+
+			test.AssertNoError(t, store.StoreAuditCheckpoint(&models.AuditCheckpoint{ID: fmt.Sprintf("%020d", 2), Sequence: 2, Timestamp: base}), "store checkpoint")
+			third := &models.AuditLog{ID: "log-3", Timestamp: base.Add(2 * time.Second), Resource: "a"}
+			err := store.AppendAuditLog(third, func(n *models.AuditLog, c *models.AuditCheckpoint) error {
+				newest, checkpoint = n, c
+				return fmt.Errorf("rejected")
+			})
+			test.AssertError(t, err, "append rejected by link")
+			test.AssertEqual(t, uint64(2), newest.Sequence, "newest entry after second append")
+			test.AssertTrue(t, checkpoint != nil, "newest checkpoint")
+			test.AssertEqual(t, uint64(2), checkpoint.Sequence, "checkpoint sequence")
+			count, err := store.CountAuditLogs()
+			test.AssertNoError(t, err, "count audit logs")
+			test.AssertEqual(t, 2, count, "rejected entry not stored")
+		},
+	},
+	{
+		name: "Audit logs are filtered newest first",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, userID := range []string{"alice", "bob", "alice", "alice"} {
+				test.AssertNoError(t, store.StoreAuditLog(&models.AuditLog{
+					ID:        fmt.Sprintf("log-%d", i),
+					Timestamp: base.Add(time.Duration(i) * time.Hour),
+					UserID:    userID,
+					Resource:  "bucket/app.jar",
+					Action:    "read",
+				}), "store audit log")
+			}
+
+			logs, err := store.ListAuditLogs("", "", time.Time{}, time.Time{}, 0)
+			test.AssertNoError(t, err, "list audit logs")
+			test.AssertEqual(t, 4, len(logs), "all logs")
+			test.AssertEqual(t, "log-3", logs[0].ID, "newest first")
+
+			logs, err = store.ListAuditLogs("alice", "", time.Time{}, time.Time{}, 2)
+			test.AssertNoError(t, err, "list by user")
+			test.AssertEqual(t, 2, len(logs), "limit respected")
+			test.AssertEqual(t, "log-3", logs[0].ID, "newest log for user")
+
+			logs, err = store.ListAuditLogs("", "bucket/app.jar", base.Add(time.Hour), base.Add(2*time.Hour), 0)
+			test.AssertNoError(t, err, "list by time range")
+			test.AssertEqual(t, 2, len(logs), "logs in time range")
+
+			logs, err = store.ListAuditLogs("", "other", time.Time{}, time.Time{}, 0)
+			test.AssertNoError(t, err, "list by resource")
+			test.AssertEqual(t, 0, len(logs), "no logs for other resource")
+		},
+	},
+	{
+		name: "Artifact search indexes",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{
+				Bucket: "releases", Key: "app/app-1.0.rpm", ContentType: "application/x-rpm",
+				Metadata: map[string]string{"Git-Sha": "abc123"}, Tags: map[string]string{"env": "prod"},
+			}), "store artifact")
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases", Key: "app/app-2.0.rpm", Metadata: map[string]string{"Git-Sha": "def456"}}), "store artifact")
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "nightly", Key: "lib.tar"}), "store artifact")
+
+			find := func(index, value string) string {
+				ids, err := store.FindArtifacts(index, value)
+				test.AssertNoError(t, err, "find "+index)
+				return strings.Join(ids, ",")
+			}
+			test.AssertEqual(t, "releases/app/app-1.0.rpm", find(storage.ArtifactIndexMetadata, storage.MetadataIndexValue("git-sha", "abc123")), "by metadata")
+			test.AssertEqual(t, "releases/app/app-1.0.rpm", find(storage.ArtifactIndexTag, storage.MetadataIndexValue("env", "prod")), "by tag")
+			test.AssertEqual(t, "releases/app/app-1.0.rpm", find(storage.ArtifactIndexContentType, "application/x-rpm"), "by content type")
+			test.AssertEqual(t, "releases/app/app-1.0.rpm,releases/app/app-2.0.rpm", find(storage.ArtifactIndexToken, "rpm"), "by token")
+
+			// Overwriting and deleting keep the indexes current
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases", Key: "app/app-1.0.rpm", Metadata: map[string]string{"Git-Sha": "fff000"}}), "overwrite artifact")
+			test.AssertEqual(t, "", find(storage.ArtifactIndexMetadata, storage.MetadataIndexValue("git-sha", "abc123")), "stale metadata entry")
+			test.AssertEqual(t, "releases/app/app-1.0.rpm", find(storage.ArtifactIndexToken, "fff000"), "new metadata token")
+			test.AssertNoError(t, store.DeleteArtifact("releases", "app/app-2.0.rpm"), "delete artifact")
+			test.AssertEqual(t, "releases/app/app-1.0.rpm", find(storage.ArtifactIndexToken, "rpm"), "deleted artifact unindexed")
+
+			// Scans page through every bucket, or one bucket and prefix
+			var keys []string
+			after := ""
+			for {
+				page, err := store.ScanArtifacts("", "", after, 1)
+				test.AssertNoError(t, err, "scan artifacts")
+				if len(page) == 0 {
+					break
+				}
+				keys = append(keys, page[0].Key)
+				after = page[0].Bucket + "/" + page[0].Key
+			}
+			test.AssertEqual(t, "lib.tar,app/app-1.0.rpm", strings.Join(keys, ","), "all artifacts in order")
+			scoped, err := store.ScanArtifacts("releases", "app/", "", 0)
+			test.AssertNoError(t, err, "scan prefix")
+			test.AssertEqual(t, 1, len(scoped), "artifacts under prefix")
+		},
+	},
+	{
+		name: "Artifact relations protect their targets",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			for _, key := range []string{"src.tar", "app.bin", "app.debug"} {
+				test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "builds", Key: key}), "store artifact")
+			}
+			test.AssertNoError(t, store.AddArtifactRelation(&models.ArtifactRelation{ArtifactID: "builds/app.bin", TargetID: "builds/src.tar", Type: models.RelationBuiltFrom}), "add built-from")
+			test.AssertNoError(t, store.AddArtifactRelation(&models.ArtifactRelation{ArtifactID: "builds/app.debug", TargetID: "builds/app.bin", Type: models.RelationAccompanies}), "add accompanies")
+			test.AssertError(t, store.AddArtifactRelation(&models.ArtifactRelation{ArtifactID: "builds/app.bin", TargetID: "builds/missing", Type: models.RelationDependsOn}), "missing target")
+			test.AssertError(t, store.AddArtifactRelation(&models.ArtifactRelation{ArtifactID: "builds/app.bin", TargetID: "builds/src.tar", Type: "copied-from"}), "unknown type")
+
+			from, err := store.ListRelationsFrom("builds/app.bin")
+			test.AssertNoError(t, err, "list from")
+			test.AssertEqual(t, 1, len(from), "relations from artifact")
+			test.AssertEqual(t, "builds/src.tar", from[0].TargetID, "target")
+			to, err := store.ListRelationsTo("builds/app.bin")
+			test.AssertNoError(t, err, "list to")
+			test.AssertEqual(t, 1, len(to), "relations to artifact")
+			test.AssertEqual(t, "builds/app.debug", to[0].ArtifactID, "dependent")
+
+			// Targets cannot be deleted while others depend on them
+			err = store.DeleteArtifact("builds", "app.bin")
+			test.AssertTrue(t, errors.Is(err, storage.ErrArtifactHasDependents), "delete depended-on artifact")
+			_, err = store.GetArtifact("builds", "app.bin")
+			test.AssertNoError(t, err, "artifact kept")
+
+			// Deleting a dependent removes its relations and frees its targets
+			test.AssertNoError(t, store.DeleteArtifact("builds", "app.debug"), "delete dependent")
+			test.AssertNoError(t, store.RemoveArtifactRelation("builds/app.bin", "builds/src.tar", models.RelationBuiltFrom), "remove relation")
+			err = store.RemoveArtifactRelation("builds/app.bin", "builds/src.tar", models.RelationBuiltFrom)
+			test.AssertTrue(t, errors.Is(err, storage.ErrRelationNotFound), "remove missing relation")
+			test.AssertNoError(t, store.DeleteArtifact("builds", "app.bin"), "delete freed artifact")
+			test.AssertNoError(t, store.DeleteArtifact("builds", "src.tar"), "delete freed target")
+		},
+	},
+	{
+		name: "Audit log pages and expiry",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			// Entries within the same second must keep their order
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < 5; i++ {
+				test.AssertNoError(t, store.StoreAuditLog(&models.AuditLog{
+					ID:        fmt.Sprintf("log-%d", i),
+					Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+					UserID:    "alice",
+					Resource:  fmt.Sprintf("bucket/%d", i%2),
+				}), "store audit log")
+			}
+
+			var ids []string
+			query := &storage.AuditLogQuery{UserID: "alice", Limit: 2}
+			for pages := 0; ; pages++ {
+				test.AssertTrue(t, pages < 5, "pagination terminates")
+				page, err := store.QueryAuditLogs(query)
+				test.AssertNoError(t, err, "query page")
+				for _, log := range page.Logs {
+					ids = append(ids, log.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			test.AssertEqual(t, "log-4,log-3,log-2,log-1,log-0", strings.Join(ids, ","), "pages newest first without gaps")
+
+			page, err := store.QueryAuditLogs(&storage.AuditLogQuery{Resource: "bucket/1", StartTime: base, EndTime: base.Add(3 * time.Millisecond)})
+			test.AssertNoError(t, err, "query by resource and time")
+			test.AssertEqual(t, 2, len(page.Logs), "entries for resource in range")
+			test.AssertEqual(t, "log-3", page.Logs[0].ID, "newest in range")
+			_, err = store.QueryAuditLogs(&storage.AuditLogQuery{Cursor: "not a cursor"})
+			test.AssertError(t, err, "invalid cursor")
+
+			// A failed archive keeps the entries
+			_, err = store.ExpireAuditLogs(base.Add(2*time.Millisecond), 0, func([]*models.AuditLog) (*models.AuditCheckpoint, error) {
+				return nil, fmt.Errorf("archive unavailable")
+			})
+			test.AssertError(t, err, "archive failure")
+			count, err := store.CountAuditLogs()
+			test.AssertNoError(t, err, "count")
+			test.AssertEqual(t, 5, count, "entries kept after archive failure")
+
+			var archived []string
+			removed, err := store.ExpireAuditLogs(base.Add(2*time.Millisecond), 0, func(logs []*models.AuditLog) (*models.AuditCheckpoint, error) {
+				for _, log := range logs {
+					archived = append(archived, log.ID)
+				}
+				return &models.AuditCheckpoint{ID: "expired-log-1", Retention: true}, nil
+			})
+			test.AssertNoError(t, err, "expire by age")
+			test.AssertEqual(t, 2, removed, "entries older than cutoff")
+			test.AssertEqual(t, "log-0,log-1", strings.Join(archived, ","), "archived oldest first")
+			checkpoints, err := store.ListAuditCheckpoints()
+			test.AssertNoError(t, err, "list checkpoints")
+			test.AssertEqual(t, 1, len(checkpoints), "checkpoint stored with the expiry")
+			test.AssertTrue(t, checkpoints[0].Retention, "retention checkpoint")
+
+			removed, err = store.ExpireAuditLogs(time.Time{}, 2, nil)
+			test.AssertNoError(t, err, "expire oldest")
+			test.AssertEqual(t, 2, removed, "entries removed by count")
+			logs, err := store.ListAuditLogs("alice", "", time.Time{}, time.Time{}, 0)
+			test.AssertNoError(t, err, "list remaining")
+			test.AssertEqual(t, 1, len(logs), "remaining entries")
+			test.AssertEqual(t, "log-4", logs[0].ID, "newest entry kept")
+			logs, err = store.ListAuditLogs("", "bucket/0", time.Time{}, time.Time{}, 0)
+			test.AssertNoError(t, err, "list by resource")
+			test.AssertEqual(t, 1, len(logs), "resource index updated")
+		},
+	},
+	{
+		name: "Supply chain records by artifact",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "bucket/app.jar", Signature: []byte{1, 2}}), "store signature")
+			test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-2", ArtifactID: "bucket/app.jar"}), "store signature")
+			test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-3", ArtifactID: "bucket/other.jar"}), "store signature")
+			test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-1", ArtifactID: "bucket/app.jar", Format: models.SBOMFormatSPDX, Content: []byte("{}")}), "store SBOM")
+			test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-1", ArtifactID: "bucket/app.jar", Type: models.AttestationTypeBuild}), "store attestation")
+
+			signatures, err := store.ListSignaturesForArtifact("bucket/app.jar")
+			test.AssertNoError(t, err, "list signatures")
+			test.AssertEqual(t, 2, len(signatures), "signatures for artifact")
+
+			signature, err := store.GetSignature("sig-1")
+			test.AssertNoError(t, err, "get signature")
+			test.AssertEqual(t, 2, len(signature.Signature), "signature bytes")
+
+			sbom, err := store.GetSBOMForArtifact("bucket/app.jar")
+			test.AssertNoError(t, err, "get SBOM for artifact")
+			test.AssertEqual(t, "sbom-1", sbom.ID, "SBOM id")
+			_, err = store.GetSBOMForArtifact("bucket/other.jar")
+			test.AssertError(t, err, "artifact without SBOM")
+
+			attestations, err := store.ListAttestationsForArtifact("bucket/app.jar")
+			test.AssertNoError(t, err, "list attestations")
+			test.AssertEqual(t, 1, len(attestations), "attestations for artifact")
+
+			test.AssertNoError(t, store.DeleteSignature("sig-1"), "delete signature")
+			test.AssertNoError(t, store.DeleteSBOM("sbom-1"), "delete SBOM")
+			test.AssertNoError(t, store.DeleteAttestation("att-1"), "delete attestation")
+			_, err = store.GetSignature("sig-1")
+			test.AssertError(t, err, "get deleted signature")
+			_, err = store.GetSBOM("sbom-1")
+			test.AssertError(t, err, "get deleted SBOM")
+			_, err = store.GetAttestation("att-1")
+			test.AssertError(t, err, "get deleted attestation")
+		},
+	},
+	{
+		name: "Artifact deletion and overwrite cascade to supply chain",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			attach := func(artifactID string) {
+				test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-" + artifactID, ArtifactID: artifactID}), "store signature")
+				test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-" + artifactID, ArtifactID: artifactID}), "store SBOM")
+				test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-" + artifactID, ArtifactID: artifactID}), "store attestation")
+			}
+			supplyChainCount := func(artifactID string) int {
+				signatures, err := store.ListSignaturesForArtifact(artifactID)
+				test.AssertNoError(t, err, "list signatures")
+				attestations, err := store.ListAttestationsForArtifact(artifactID)
+				test.AssertNoError(t, err, "list attestations")
+				count := len(signatures) + len(attestations)
+				if _, err := store.GetSBOMForArtifact(artifactID); err == nil {
+					count++
+				}
+				return count
+			}
+
+			for _, key := range []string{"app.jar", "lib.jar", "app.jar.sig"} {
+				test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: key, Digest: "sha256:aaaa"}), "store artifact")
+				attach("bucket/" + key)
+			}
+
+			// Deleting removes the records of that artifact only
+			test.AssertNoError(t, store.DeleteArtifact("bucket", "app.jar"), "delete artifact")
+			test.AssertEqual(t, 0, supplyChainCount("bucket/app.jar"), "records after delete")
+			_, err := store.GetSignature("sig-bucket/app.jar")
+			test.AssertError(t, err, "signature removed with artifact")
+			test.AssertEqual(t, 3, supplyChainCount("bucket/app.jar.sig"), "records of artifact sharing a prefix")
+
+			// Re-uploading the same content keeps them
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: "lib.jar", Digest: "sha256:aaaa"}), "store same content")
+			test.AssertEqual(t, 3, supplyChainCount("bucket/lib.jar"), "records after identical overwrite")
+
+			// Different content does not inherit them
+			test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "bucket", Key: "lib.jar", Digest: "sha256:bbbb"}), "store new content")
+			test.AssertEqual(t, 0, supplyChainCount("bucket/lib.jar"), "records after overwrite")
+		},
+	},
+	{
+		name: "Replication queue",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			first := &models.ReplicationTask{RuleID: "rule", Bucket: "bucket", Key: "a", Op: models.ReplicationOpPut}
+			second := &models.ReplicationTask{RuleID: "rule", Bucket: "bucket", Key: "b", Op: models.ReplicationOpPut}
+			test.AssertNoError(t, store.EnqueueReplicationTask(first, "dr"), "enqueue task")
+			test.AssertNoError(t, store.EnqueueReplicationTask(second, "dr"), "enqueue task")
+			test.AssertTrue(t, second.Sequence > first.Sequence, "sequence increases")
+
+			count, err := store.CountReplicationTasks()
+			test.AssertNoError(t, err, "count tasks")
+			test.AssertEqual(t, 2, count, "queued tasks")
+
+			tasks, err := store.ListReplicationTasks(0, 0)
+			test.AssertNoError(t, err, "list tasks")
+			test.AssertEqual(t, first.Sequence, tasks[0].Sequence, "tasks in enqueue order")
+			later, err := store.ListReplicationTasks(first.Sequence, 0)
+			test.AssertNoError(t, err, "list later tasks")
+			test.AssertEqual(t, 1, len(later), "tasks after the first")
+			test.AssertEqual(t, second.Sequence, later[0].Sequence, "task after the first")
+
+			// A retryable failure keeps the task queued
+			tasks[0].Attempts = 1
+			tasks[0].LastError = "unavailable"
+			tasks[0].NextAttemptAt = time.Now().Add(time.Minute)
+			test.AssertNoError(t, store.FailReplicationTask(tasks[0]), "fail task")
+			status, err := store.GetReplicationStatus("rule", "bucket", "a")
+			test.AssertNoError(t, err, "get status")
+			test.AssertEqual(t, models.ReplicationStatePending, status.State, "pending after retryable failure")
+			test.AssertEqual(t, "unavailable", status.LastError, "last error")
+			tasks, err = store.ListReplicationTasks(0, 1)
+			test.AssertNoError(t, err, "list tasks")
+			test.AssertEqual(t, 1, tasks[0].Attempts, "attempts persisted")
+
+			test.AssertNoError(t, store.CompleteReplicationTask(tasks[0]), "complete task")
+			status, err = store.GetReplicationStatus("rule", "bucket", "a")
+			test.AssertNoError(t, err, "get status")
+			test.AssertEqual(t, models.ReplicationStateCompleted, status.State, "completed")
+			test.AssertEqual(t, "dr", status.Target, "target")
+
+			// A final failure removes the task
+			second.NextAttemptAt = time.Time{}
+			second.LastError = "rejected"
+			test.AssertNoError(t, store.FailReplicationTask(second), "fail task permanently")
+			status, err = store.GetReplicationStatus("rule", "bucket", "b")
+			test.AssertNoError(t, err, "get status")
+			test.AssertEqual(t, models.ReplicationStateFailed, status.State, "failed")
+
+			count, err = store.CountReplicationTasks()
+			test.AssertNoError(t, err, "count tasks")
+			test.AssertEqual(t, 0, count, "queue drained")
+
+			statuses, err := store.ListReplicationStatus("bucket", "")
+			test.AssertNoError(t, err, "list statuses")
+			test.AssertEqual(t, 2, len(statuses), "statuses for bucket")
+			_, err = store.GetReplicationStatus("rule", "bucket", "missing")
+			test.AssertError(t, err, "missing status")
+		},
+	},
+	{
+		name: "Export and import round trip",
+		run: func(t *testing.T, store storage.MetadataStore) {
+			populateEveryRecordKind(t, store)
+
+			var export bytes.Buffer
+			exported, err := storage.ExportMetadata(store, &export)
+			test.AssertNoError(t, err, "export")
+			test.AssertEqual(t, 15, exported, "one record of each kind")
+
+			test.AssertNoError(t, store.DeleteBucket("releases"), "delete bucket")
+			test.AssertNoError(t, store.DeleteArtifact("releases", "app.jar"), "delete artifact")
+			test.AssertNoError(t, store.DeletePolicy("policy-1"), "delete policy")
+			test.AssertNoError(t, store.DeleteSignature("sig-1"), "delete signature")
+
+			imported, err := storage.ImportMetadata(store, &export)
+			test.AssertNoError(t, err, "import")
+			test.AssertEqual(t, exported, imported, "records imported")
+
+			bucket, err := store.GetBucket("releases")
+			test.AssertNoError(t, err, "restored bucket")
+			test.AssertEqual(t, "build", bucket.Tags["team"], "bucket tags")
+			artifact, err := store.GetArtifact("releases", "app.jar")
+			test.AssertNoError(t, err, "restored artifact")
+			test.AssertEqual(t, int64(42), artifact.Size, "artifact size")
+			_, err = store.GetPolicy("policy-1")
+			test.AssertNoError(t, err, "restored policy")
+			signatures, err := store.ListSignaturesForArtifact("releases/app.jar")
+			test.AssertNoError(t, err, "restored signature")
+			test.AssertEqual(t, 1, len(signatures), "signatures for artifact")
+
+			// Replication tasks are appended rather than replaced
+			count, err := store.CountReplicationTasks()
+			test.AssertNoError(t, err, "count tasks")
+			test.AssertEqual(t, 2, count, "queued tasks")
+
+			_, err = storage.ImportMetadata(store, strings.NewReader(`{"kind":"widget","data":{}}`))
+			test.AssertError(t, err, "unknown kind")
+			_, err = storage.ImportMetadata(store, strings.NewReader(`{"kind":"artifact","data":{"bucket":"releases"}}`))
+			test.AssertError(t, err, "record without key")
+		},
+	},
+}
+
+// populateEveryRecordKind stores one record of each exported kind
+func populateEveryRecordKind(t *testing.T, store storage.MetadataStore) {
+	t.Helper()
+	test.AssertNoError(t, store.CreateBucket(&models.Bucket{Name: "releases", Tags: map[string]string{"team": "build"}}), "create bucket")
+	test.AssertNoError(t, store.StoreArtifact(&models.Artifact{Bucket: "releases", Key: "app.jar", Size: 42}), "store artifact")
+	test.AssertNoError(t, store.CreateMultipartUpload(&models.MultipartUpload{UploadID: "upload-1", Bucket: "releases", Key: "big.iso"}), "create upload")
+	test.AssertNoError(t, store.CreateUploadProgress(&models.UploadProgress{UploadID: "upload-2", Bucket: "releases", Key: "huge.iso", TotalSize: 1 << 30}), "create upload progress")
+	test.AssertNoError(t, store.StorePolicy(&models.Policy{ID: "policy-1", Resource: "releases"}), "store policy")
+	test.AssertNoError(t, store.StoreServiceAccount(&models.ServiceAccount{ID: "sa-1", Name: "ci"}), "store service account")
+	test.AssertNoError(t, store.StoreAPIToken(&models.APIToken{ID: "tok-1", UserID: "sa-1", SecretHash: "hash"}), "store API token")
+	test.AssertNoError(t, store.StoreKnownUser(&models.KnownUser{ID: "user-1", Username: "alice", Source: "oidc"}), "store known user")
+	test.AssertNoError(t, store.StoreAuditCheckpoint(&models.AuditCheckpoint{ID: "00000000000000000001", Sequence: 1, Hash: "hash"}), "store audit checkpoint")
+	test.AssertNoError(t, store.StoreAuditLog(&models.AuditLog{ID: "log-1", Timestamp: time.Now(), UserID: "alice", Resource: "releases/app.jar"}), "store audit log")
+	test.AssertNoError(t, store.StoreSignature(&models.Signature{ID: "sig-1", ArtifactID: "releases/app.jar"}), "store signature")
+	test.AssertNoError(t, store.StoreSBOM(&models.SBOM{ID: "sbom-1", ArtifactID: "releases/app.jar"}), "store SBOM")
+	test.AssertNoError(t, store.StoreAttestation(&models.Attestation{ID: "att-1", ArtifactID: "releases/app.jar"}), "store attestation")
+	test.AssertNoError(t, store.EnqueueReplicationTask(&models.ReplicationTask{RuleID: "rule", Bucket: "releases", Key: "app.jar", Op: models.ReplicationOpPut}, "dr"), "enqueue task")
+}
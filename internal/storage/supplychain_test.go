@@ -17,7 +17,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, err := storage.NewMetadataStore(tmpFile.Name())
+		store, err := storage.NewBoltMetadataStore(tmpFile.Name())
 		test.AssertNoError(t, err, "creating store")
 		defer store.Close()
 
@@ -49,7 +49,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		artifactID := "bucket/artifact"
@@ -76,7 +76,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		sbom := &models.SBOM{
@@ -109,7 +109,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		sbom := &models.SBOM{
@@ -135,7 +135,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		attestation := &models.Attestation{
@@ -169,7 +169,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		artifactID := "bucket/artifact"
@@ -196,7 +196,7 @@ func TestSupplyChainStorage(t *testing.T) {
 		defer os.Remove(tmpFile.Name())
 		tmpFile.Close()
 
-		store, _ := storage.NewMetadataStore(tmpFile.Name())
+		store, _ := storage.NewBoltMetadataStore(tmpFile.Name())
 		defer store.Close()
 
 		signature := &models.Signature{ID: "sig-1", ArtifactID: "bucket/artifact"}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+)
+
+// DirectoryUser is a user of the server's user directory
+type DirectoryUser struct {
+	ID       string            `json:"id"`
+	Username string            `json:"username"`
+	Email    string            `json:"email,omitempty"`
+	Roles    []string          `json:"roles"`
+	Groups   []string          `json:"groups"`
+	Metadata map[string]string `json:"metadata,omitempty"` // Includes the directory source
+}
+
+// ActionDecision is the decision for one action on a resource
+type ActionDecision struct {
+	Action         string `json:"action"`
+	Allowed        bool   `json:"allowed"`
+	Reason         string `json:"reason"`
+	DecidingPolicy string `json:"decidingPolicy,omitempty"`
+}
+
+// EffectiveAccess lists what a user may do on a resource
+type EffectiveAccess struct {
+	User          DirectoryUser    `json:"user"`
+	Resource      string           `json:"resource"`
+	PolicyVersion uint64           `json:"policyVersion"`
+	Allowed       []string         `json:"allowed"`
+	Decisions     []ActionDecision `json:"decisions"`
+}
+
+// Grantee is a user allowed an action on a resource
+type Grantee struct {
+	UserID         string   `json:"userId"`
+	Username       string   `json:"username"`
+	Roles          []string `json:"roles,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+	Source         string   `json:"source,omitempty"`
+	Reason         string   `json:"reason"`
+	DecidingPolicy string   `json:"decidingPolicy,omitempty"`
+}
+
+// AccessGrantees lists the users allowed an action on a resource
+type AccessGrantees struct {
+	Resource      string    `json:"resource"`
+	Action        string    `json:"action"`
+	PolicyVersion uint64    `json:"policyVersion"`
+	Anonymous     bool      `json:"anonymous"` // Unauthenticated requests are allowed too
+	Evaluated     int       `json:"evaluated"` // Directory users checked
+	Users         []Grantee `json:"users"`
+}
+
+// ListUsers lists the users of the server's user directory
+func (c *Client) ListUsers(ctx context.Context) ([]DirectoryUser, error) {
+	resp, err := c.doRequest(ctx, "GET", "/rbac/users", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Users []DirectoryUser `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return result.Users, nil
+}
+
+// GetUserAccess returns what a user, given by ID or username, may do on a
+// resource. sourceIP, if set, is used for sourceIp policy conditions.
+func (c *Client) GetUserAccess(ctx context.Context, user, resource, sourceIP string) (*EffectiveAccess, error) {
+	params := url.Values{}
+	params.Set("resource", resource)
+	if sourceIP != "" {
+		params.Set("sourceIp", sourceIP)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", "/rbac/users/"+url.PathEscape(user)+"/access?"+params.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var access EffectiveAccess
+	if err := json.NewDecoder(resp.Body).Decode(&access); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &access, nil
+}
+
+// WhoCan lists the directory users allowed an action on a resource
+func (c *Client) WhoCan(ctx context.Context, action, resource string) (*AccessGrantees, error) {
+	params := url.Values{}
+	params.Set("action", action)
+	params.Set("resource", resource)
+
+	resp, err := c.doRequest(ctx, "GET", "/rbac/access?"+params.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var grantees AccessGrantees
+	if err := json.NewDecoder(resp.Body).Decode(&grantees); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &grantees, nil
+}
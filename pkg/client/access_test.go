@@ -0,0 +1,108 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestAccessQueries(t *testing.T) {
+	t.Run("List users", func(t *testing.T) {
+		// Given: Test server with two directory users
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "GET", r.Method, "HTTP method")
+			test.AssertEqual(t, "/rbac/users", r.URL.Path, "request path")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"users": [
+				{"id": "u1", "username": "alice", "roles": ["developer"], "groups": [], "metadata": {"source": "oidc"}},
+				{"id": "sa1", "username": "ci", "roles": [], "groups": ["build"], "metadata": {"source": "service-account"}}
+			], "count": 2}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Listing users
+		users, err := c.ListUsers(context.Background())
+
+		// Then: The users are returned
+		test.AssertNoError(t, err, "list users")
+		test.AssertEqual(t, 2, len(users), "user count")
+		test.AssertEqual(t, "developer", users[0].Roles[0], "role")
+		test.AssertEqual(t, "service-account", users[1].Metadata["source"], "source")
+	})
+
+	t.Run("Get user access", func(t *testing.T) {
+		// Given: Test server reporting a user's access to a resource
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "/rbac/users/alice/access", r.URL.Path, "request path")
+			test.AssertEqual(t, "releases/app.jar", r.URL.Query().Get("resource"), "resource")
+			test.AssertEqual(t, "10.0.0.1", r.URL.Query().Get("sourceIp"), "source IP")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"user": {"id": "u1", "username": "alice", "roles": ["developer"], "groups": []},
+				"resource": "releases/app.jar",
+				"policyVersion": 3,
+				"allowed": ["read", "list"],
+				"decisions": [{"action": "read", "allowed": true, "reason": "allowed by policy", "decidingPolicy": "devs"}]
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Getting the user's access
+		access, err := c.GetUserAccess(context.Background(), "alice", "releases/app.jar", "10.0.0.1")
+
+		// Then: The allowed actions and decisions are returned
+		test.AssertNoError(t, err, "get user access")
+		test.AssertEqual(t, 2, len(access.Allowed), "allowed actions")
+		test.AssertEqual(t, "devs", access.Decisions[0].DecidingPolicy, "deciding policy")
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		// Given: Test server that does not know the user
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "User not found", http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Getting the user's access
+		_, err := c.GetUserAccess(context.Background(), "nobody", "releases", "")
+
+		// Then: An error is returned
+		test.AssertError(t, err, "unknown user")
+	})
+
+	t.Run("Who can", func(t *testing.T) {
+		// Given: Test server with one user allowed to write
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "/rbac/access", r.URL.Path, "request path")
+			test.AssertEqual(t, "write", r.URL.Query().Get("action"), "action")
+			test.AssertEqual(t, "releases", r.URL.Query().Get("resource"), "resource")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"resource": "releases", "action": "write", "policyVersion": 3, "anonymous": false, "evaluated": 5,
+				"users": [{"userId": "sa1", "username": "ci", "source": "service-account", "reason": "allowed by policy", "decidingPolicy": "ci-write"}]
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Asking who can write to the bucket
+		grantees, err := c.WhoCan(context.Background(), "write", "releases")
+
+		// Then: The allowed users are returned
+		test.AssertNoError(t, err, "who can")
+		test.AssertEqual(t, 5, grantees.Evaluated, "evaluated users")
+		test.AssertEqual(t, "ci", grantees.Users[0].Username, "grantee")
+		test.AssertFalse(t, grantees.Anonymous, "anonymous access")
+	})
+}
@@ -212,6 +212,9 @@ type UploadOptions struct {
 	// Metadata contains custom key-value metadata
 	Metadata map[string]string
 
+	// Tags contains object tags, sent as the X-Amz-Tagging header
+	Tags map[string]string
+
 	// ProgressCallback is called periodically during upload with bytes transferred
 	ProgressCallback func(bytesTransferred int64)
 }
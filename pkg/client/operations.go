@@ -86,6 +86,14 @@ func (c *Client) Upload(ctx context.Context, bucket, key string, data io.Reader,
 		headers["X-Amz-Meta-"+key] = value
 	}
 
+	if len(opts.Tags) > 0 {
+		tags := url.Values{}
+		for key, value := range opts.Tags {
+			tags.Set(key, value)
+		}
+		headers["X-Amz-Tagging"] = tags.Encode()
+	}
+
 	urlPath := fmt.Sprintf("/s3/%s/%s", bucket, key)
 
 	resp, err := c.doRequest(ctx, "PUT", urlPath, reader, headers)
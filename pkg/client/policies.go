@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+)
+
+// Policy document formats
+const (
+	PolicyFormatYAML = "yaml"
+	PolicyFormatJSON = "json"
+)
+
+// PolicyImportOptions contains options for importing policies
+type PolicyImportOptions struct {
+	// DryRun reports the changes without applying them
+	DryRun bool
+
+	// IfVersion refuses the import if policies changed since this policy
+	// version; 0 imports unconditionally
+	IfVersion uint64
+}
+
+// PolicyUpdate names a policy that changes and the fields that differ
+type PolicyUpdate struct {
+	ID     string   `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// PolicyPlan lists the policies an import creates, updates and deletes
+type PolicyPlan struct {
+	Created   []string       `json:"created"`
+	Updated   []PolicyUpdate `json:"updated"`
+	Deleted   []string       `json:"deleted"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// PolicyImportResult reports the changes an import made or, in a dry run,
+// would make
+type PolicyImportResult struct {
+	Plan    PolicyPlan `json:"plan"`
+	DryRun  bool       `json:"dryRun"`
+	Applied bool       `json:"applied"`
+	Version uint64     `json:"version"`
+}
+
+// PolicySimulationOptions selects the audit log entries a simulation replays
+type PolicySimulationOptions struct {
+	// Since replays entries logged within this duration (the server defaults to 24h)
+	Since time.Duration
+
+	// Limit is the maximum number of entries replayed (the server defaults to 1000)
+	Limit int
+}
+
+// DecisionChange is an audited request whose decision proposed policies change
+type DecisionChange struct {
+	AuditID          string    `json:"auditId"`
+	Timestamp        time.Time `json:"timestamp"`
+	UserID           string    `json:"userId"`
+	Username         string    `json:"username"`
+	Action           string    `json:"action"`
+	Resource         string    `json:"resource"`
+	Current          string    `json:"current"`
+	Proposed         string    `json:"proposed"`
+	CurrentPolicy    string    `json:"currentPolicy,omitempty"`
+	ProposedPolicy   string    `json:"proposedPolicy,omitempty"`
+	ProposedReason   string    `json:"proposedReason"`
+	RecordedDecision string    `json:"recordedDecision"`
+}
+
+// PolicySimulation reports how proposed policies change the decisions of
+// recently audited requests
+type PolicySimulation struct {
+	CurrentVersion uint64           `json:"currentVersion"`
+	Replayed       int              `json:"replayed"`
+	Skipped        int              `json:"skipped"`
+	Granted        int              `json:"granted"`
+	Revoked        int              `json:"revoked"`
+	Changes        []DecisionChange `json:"changes"`
+}
+
+// ExportPolicies returns all policies as a YAML or JSON policy document
+func (c *Client) ExportPolicies(ctx context.Context, format string) ([]byte, error) {
+	params := url.Values{}
+	params.Set("format", format)
+
+	resp, err := c.doRequest(ctx, "GET", "/rbac/policies/export?"+params.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	document, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.NewInternal("failed to read response: " + err.Error())
+	}
+
+	return document, nil
+}
+
+// ImportPolicies replaces all policies with those of a YAML or JSON policy
+// document. Policies missing from the document are deleted.
+func (c *Client) ImportPolicies(ctx context.Context, document []byte, format string, opts *PolicyImportOptions) (*PolicyImportResult, error) {
+	if opts == nil {
+		opts = &PolicyImportOptions{}
+	}
+
+	params := url.Values{}
+	params.Set("format", format)
+	if opts.DryRun {
+		params.Set("dryRun", "true")
+	}
+	if opts.IfVersion > 0 {
+		params.Set("ifVersion", strconv.FormatUint(opts.IfVersion, 10))
+	}
+
+	resp, err := c.doRequest(ctx, "POST", "/rbac/policies/import?"+params.Encode(), bytes.NewReader(document), policyDocumentHeaders(format))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result PolicyImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &result, nil
+}
+
+// SimulatePolicies replays recently audited requests against the policies
+// of a YAML or JSON policy document and reports the decisions that change
+func (c *Client) SimulatePolicies(ctx context.Context, document []byte, format string, opts *PolicySimulationOptions) (*PolicySimulation, error) {
+	if opts == nil {
+		opts = &PolicySimulationOptions{}
+	}
+
+	params := url.Values{}
+	params.Set("format", format)
+	if opts.Since > 0 {
+		params.Set("since", opts.Since.String())
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	resp, err := c.doRequest(ctx, "POST", "/rbac/policies/simulate?"+params.Encode(), bytes.NewReader(document), policyDocumentHeaders(format))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var simulation PolicySimulation
+	if err := json.NewDecoder(resp.Body).Decode(&simulation); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &simulation, nil
+}
+
+// policyDocumentHeaders returns the headers of a request sending a policy document
+func policyDocumentHeaders(format string) map[string]string {
+	return map[string]string{
+		"Content-Type": "application/" + format,
+	}
+}
@@ -0,0 +1,121 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+const policyDocument = "policies:\n  - id: read-releases\n    resource: releases/**\n    actions: [read]\n    effect: allow\n"
+
+func TestPolicies(t *testing.T) {
+	t.Run("Export policies", func(t *testing.T) {
+		// Given: Test server returning a policy document
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "GET", r.Method, "HTTP method")
+			test.AssertEqual(t, "/rbac/policies/export", r.URL.Path, "request path")
+			test.AssertEqual(t, "yaml", r.URL.Query().Get("format"), "format")
+			w.Header().Set("Content-Type", "application/yaml")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(policyDocument))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Exporting policies
+		document, err := c.ExportPolicies(context.Background(), client.PolicyFormatYAML)
+
+		// Then: The document is returned as is
+		test.AssertNoError(t, err, "export policies")
+		test.AssertEqual(t, policyDocument, string(document), "policy document")
+	})
+
+	t.Run("Dry-run import", func(t *testing.T) {
+		// Given: Test server planning an import
+		var receivedBody []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "POST", r.Method, "HTTP method")
+			test.AssertEqual(t, "/rbac/policies/import", r.URL.Path, "request path")
+			test.AssertEqual(t, "true", r.URL.Query().Get("dryRun"), "dry run")
+			test.AssertEqual(t, "7", r.URL.Query().Get("ifVersion"), "if version")
+			test.AssertEqual(t, "application/yaml", r.Header.Get("Content-Type"), "content type")
+			receivedBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"plan": {"created": ["read-releases"], "updated": [{"id": "ops", "fields": ["resource"]}], "deleted": ["old"], "unchanged": 2},
+				"dryRun": true,
+				"applied": false,
+				"version": 7
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Importing with a dry run
+		result, err := c.ImportPolicies(context.Background(), []byte(policyDocument), client.PolicyFormatYAML, &client.PolicyImportOptions{DryRun: true, IfVersion: 7})
+
+		// Then: The planned changes are returned
+		test.AssertNoError(t, err, "import policies")
+		test.AssertEqual(t, policyDocument, string(receivedBody), "document sent")
+		test.AssertFalse(t, result.Applied, "not applied")
+		test.AssertEqual(t, "read-releases", result.Plan.Created[0], "created")
+		test.AssertEqual(t, "resource", result.Plan.Updated[0].Fields[0], "updated field")
+		test.AssertEqual(t, "old", result.Plan.Deleted[0], "deleted")
+		test.AssertEqual(t, 2, result.Plan.Unchanged, "unchanged")
+	})
+
+	t.Run("Import conflict", func(t *testing.T) {
+		// Given: Test server whose policies changed since the expected version
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "policies changed since the expected version", http.StatusConflict)
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Importing
+		_, err := c.ImportPolicies(context.Background(), []byte(policyDocument), client.PolicyFormatYAML, &client.PolicyImportOptions{IfVersion: 3})
+
+		// Then: The error is returned
+		test.AssertError(t, err, "version conflict")
+	})
+
+	t.Run("Simulate policies", func(t *testing.T) {
+		// Given: Test server reporting one changed decision
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "POST", r.Method, "HTTP method")
+			test.AssertEqual(t, "/rbac/policies/simulate", r.URL.Path, "request path")
+			test.AssertEqual(t, "2h0m0s", r.URL.Query().Get("since"), "since")
+			test.AssertEqual(t, "50", r.URL.Query().Get("limit"), "limit")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"currentVersion": 4,
+				"replayed": 10,
+				"skipped": 1,
+				"granted": 0,
+				"revoked": 1,
+				"changes": [{"auditId": "a1", "username": "dev", "action": "read", "resource": "releases/app.jar", "current": "allow", "proposed": "deny", "proposedReason": "no policy allows this action"}]
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Simulating a policy document
+		simulation, err := c.SimulatePolicies(context.Background(), []byte(policyDocument), client.PolicyFormatYAML, &client.PolicySimulationOptions{Since: 2 * time.Hour, Limit: 50})
+
+		// Then: The changed decisions are returned
+		test.AssertNoError(t, err, "simulate policies")
+		test.AssertEqual(t, 10, simulation.Replayed, "replayed")
+		test.AssertEqual(t, 1, simulation.Revoked, "revoked")
+		test.AssertEqual(t, "deny", simulation.Changes[0].Proposed, "proposed decision")
+	})
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+)
+
+// Relation types
+const (
+	RelationBuiltFrom   = "built-from"
+	RelationDerivedFrom = "derived-from"
+	RelationAccompanies = "accompanies"
+	RelationDependsOn   = "depends-on"
+)
+
+// Relation links an artifact to a target it depends on. IDs are bucket/key.
+type Relation struct {
+	ArtifactID string    `json:"artifactId"`
+	TargetID   string    `json:"targetId"`
+	Type       string    `json:"type"`
+	CreatedAt  time.Time `json:"createdAt"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+}
+
+// Relations lists the relations of an artifact and of its dependents to it
+type Relations struct {
+	ArtifactID string     `json:"artifactId"`
+	Relations  []Relation `json:"relations"`
+	Dependents []Relation `json:"dependents"`
+}
+
+// GraphNode is an artifact reached by a graph walk
+type GraphNode struct {
+	ArtifactID string `json:"artifactId"`
+	Depth      int    `json:"depth"`
+}
+
+// Graph is the part of the relationship graph reachable from an artifact
+type Graph struct {
+	Root      string      `json:"root"`
+	Direction string      `json:"direction"`
+	Nodes     []GraphNode `json:"nodes"`
+	Relations []Relation  `json:"relations"`
+	Truncated bool        `json:"truncated"`
+}
+
+// AddRelation links an artifact to a target artifact (bucket/key) it depends on
+func (c *Client) AddRelation(ctx context.Context, bucket, key, targetID, relationType string) (*Relation, error) {
+	body := map[string]string{
+		"targetId": targetID,
+		"type":     relationType,
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.NewInternal("failed to marshal request: " + err.Error())
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	urlPath := fmt.Sprintf("/relations/%s/%s", bucket, key)
+
+	resp, err := c.doRequest(ctx, "POST", urlPath, bytes.NewReader(bodyBytes), headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var relation Relation
+	if err := json.NewDecoder(resp.Body).Decode(&relation); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &relation, nil
+}
+
+// RemoveRelation removes a relation from an artifact to a target
+func (c *Client) RemoveRelation(ctx context.Context, bucket, key, targetID, relationType string) error {
+	params := url.Values{}
+	params.Set("target", targetID)
+	params.Set("type", relationType)
+
+	urlPath := fmt.Sprintf("/relations/%s/%s?%s", bucket, key, params.Encode())
+
+	resp, err := c.doRequest(ctx, "DELETE", urlPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// GetRelations lists an artifact's relations and its dependents
+func (c *Client) GetRelations(ctx context.Context, bucket, key string) (*Relations, error) {
+	urlPath := fmt.Sprintf("/relations/%s/%s", bucket, key)
+
+	resp, err := c.doRequest(ctx, "GET", urlPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var relations Relations
+	if err := json.NewDecoder(resp.Body).Decode(&relations); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &relations, nil
+}
+
+// GetAncestors walks the artifacts an artifact depends on, up to depth
+// relations away (0 uses the server default)
+func (c *Client) GetAncestors(ctx context.Context, bucket, key string, depth int) (*Graph, error) {
+	return c.walkGraph(ctx, "ancestors", bucket, key, depth)
+}
+
+// GetDescendants walks the artifacts depending on an artifact, up to depth
+// relations away (0 uses the server default)
+func (c *Client) GetDescendants(ctx context.Context, bucket, key string, depth int) (*Graph, error) {
+	return c.walkGraph(ctx, "descendants", bucket, key, depth)
+}
+
+func (c *Client) walkGraph(ctx context.Context, direction, bucket, key string, depth int) (*Graph, error) {
+	urlPath := fmt.Sprintf("/graph/%s/%s/%s", direction, bucket, key)
+	if depth > 0 {
+		urlPath += "?depth=" + strconv.Itoa(depth)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", urlPath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var graph Graph
+	if err := json.NewDecoder(resp.Body).Decode(&graph); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &graph, nil
+}
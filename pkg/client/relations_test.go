@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestRelations(t *testing.T) {
+	t.Run("Add relation", func(t *testing.T) {
+		// Given: Test server
+		var received map[string]string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "POST", r.Method, "HTTP method")
+			test.AssertEqual(t, "/relations/builds/app.bin", r.URL.Path, "request path")
+			json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"artifactId": "builds/app.bin", "targetId": "builds/src.tar", "type": "built-from", "createdAt": "2024-01-01T00:00:00Z"}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Linking a binary to its sources
+		relation, err := c.AddRelation(context.Background(), "builds", "app.bin", "builds/src.tar", client.RelationBuiltFrom)
+
+		// Then: The relation is created
+		test.AssertNoError(t, err, "add relation")
+		test.AssertEqual(t, "builds/src.tar", received["targetId"], "target sent")
+		test.AssertEqual(t, "built-from", received["type"], "type sent")
+		test.AssertEqual(t, "builds/src.tar", relation.TargetID, "target")
+	})
+
+	t.Run("Walk descendants", func(t *testing.T) {
+		// Given: Test server with a graph
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "/graph/descendants/builds/src.tar", r.URL.Path, "request path")
+			test.AssertEqual(t, "2", r.URL.Query().Get("depth"), "depth")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"root": "builds/src.tar",
+				"direction": "descendants",
+				"nodes": [{"artifactId": "builds/app.bin", "depth": 1}],
+				"relations": [{"artifactId": "builds/app.bin", "targetId": "builds/src.tar", "type": "built-from"}],
+				"truncated": false
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Walking the artifacts built from the sources
+		graph, err := c.GetDescendants(context.Background(), "builds", "src.tar", 2)
+
+		// Then: The graph is returned
+		test.AssertNoError(t, err, "walk descendants")
+		test.AssertEqual(t, 1, len(graph.Nodes), "nodes")
+		test.AssertEqual(t, "builds/app.bin", graph.Nodes[0].ArtifactID, "descendant")
+	})
+
+	t.Run("Remove missing relation", func(t *testing.T) {
+		// Given: Test server without the relation
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "DELETE", r.Method, "HTTP method")
+			test.AssertEqual(t, "builds/src.tar", r.URL.Query().Get("target"), "target")
+			http.Error(w, "Relation not found", http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Removing the relation
+		err := c.RemoveRelation(context.Background(), "builds", "app.bin", "builds/src.tar", client.RelationBuiltFrom)
+
+		// Then: The error is returned
+		test.AssertError(t, err, "missing relation")
+	})
+}
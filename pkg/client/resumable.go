@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+)
+
+// DefaultChunkSize is the amount of data sent per request by ResumeUpload
+const DefaultChunkSize = 16 * 1024 * 1024
+
+// ResumableUploadCompleted is the status of a resumable upload whose object is stored
+const ResumableUploadCompleted = "completed"
+
+// ResumableUpload is the server-side state of a resumable upload
+type ResumableUpload struct {
+	UploadID     string    `json:"uploadId"`
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	TotalSize    int64     `json:"totalSize"`
+	UploadedSize int64     `json:"uploadedSize"` // Bytes acknowledged by the server
+	Status       string    `json:"status"`
+	ETag         string    `json:"etag,omitempty"`
+	StartedAt    time.Time `json:"startedAt"`
+	LastActivity time.Time `json:"lastActivity"`
+}
+
+// ResumeOptions contains options for sending the data of a resumable upload
+type ResumeOptions struct {
+	// ChunkSize is the amount of data sent per request (default: DefaultChunkSize)
+	ChunkSize int64
+
+	// MaxRetries is how many consecutive failed requests are retried (default: 5)
+	MaxRetries int
+
+	// RetryDelay is the wait before the first retry, growing with each
+	// further attempt (default: 1s)
+	RetryDelay time.Duration
+
+	// ProgressCallback is called after each chunk with the bytes acknowledged
+	ProgressCallback func(bytesTransferred int64)
+}
+
+// CreateResumableUpload starts a resumable upload of size bytes. The data is
+// sent with ResumeUpload, which can be called again after an interruption.
+func (c *Client) CreateResumableUpload(ctx context.Context, bucket, key string, size int64, opts *UploadOptions) (*ResumableUpload, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+
+	headers := map[string]string{
+		"Upload-Length": strconv.FormatInt(size, 10),
+	}
+	if opts.ContentType != "" {
+		headers["Content-Type"] = opts.ContentType
+	}
+	for metaKey, value := range opts.Metadata {
+		headers["X-Amz-Meta-"+metaKey] = value
+	}
+	if len(opts.Tags) > 0 {
+		tags := url.Values{}
+		for tagKey, value := range opts.Tags {
+			tags.Set(tagKey, value)
+		}
+		headers["X-Amz-Tagging"] = tags.Encode()
+	}
+
+	urlPath := fmt.Sprintf("/s3/%s/%s?resumable", bucket, key)
+
+	resp, err := c.doRequest(ctx, "POST", urlPath, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var upload ResumableUpload
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &upload, nil
+}
+
+// GetResumableUpload returns the state of a resumable upload, including the
+// bytes the server has acknowledged
+func (c *Client) GetResumableUpload(ctx context.Context, bucket, key, uploadID string) (*ResumableUpload, error) {
+	resp, err := c.doRequest(ctx, "GET", resumablePath(bucket, key, uploadID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var upload ResumableUpload
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &upload, nil
+}
+
+// AbortResumableUpload discards a resumable upload and the data sent so far
+func (c *Client) AbortResumableUpload(ctx context.Context, bucket, key, uploadID string) error {
+	resp, err := c.doRequest(ctx, "DELETE", resumablePath(bucket, key, uploadID), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// ResumeUpload sends the data of a resumable upload from the offset the
+// server last acknowledged, retrying failed chunks, until the object is
+// stored. data must hold the whole object. It returns the completed upload.
+func (c *Client) ResumeUpload(ctx context.Context, bucket, key, uploadID string, data io.ReaderAt, opts *ResumeOptions) (*ResumableUpload, error) {
+	if opts == nil {
+		opts = &ResumeOptions{}
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 5
+	}
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	upload, err := c.GetResumableUpload(ctx, bucket, key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	failures := 0
+	for upload.Status != ResumableUploadCompleted {
+		length := upload.TotalSize - upload.UploadedSize
+		if length > chunkSize {
+			length = chunkSize
+		}
+
+		chunk := io.NewSectionReader(data, upload.UploadedSize, length)
+		offset, etag, err := c.patchResumableUpload(ctx, bucket, key, uploadID, upload.UploadedSize, chunk, length)
+		if err != nil {
+			// Offset conflicts are resolved by asking the server where it is
+			if !errors.IsRetryable(err) && errors.GetHTTPStatus(err) != http.StatusConflict {
+				return nil, err
+			}
+			failures++
+			if failures > maxRetries || ctx.Err() != nil {
+				return nil, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-time.After(retryDelay * time.Duration(failures)):
+			}
+
+			if upload, err = c.GetResumableUpload(ctx, bucket, key, uploadID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if offset == upload.UploadedSize && offset != upload.TotalSize {
+			return nil, errors.NewBadRequest(fmt.Sprintf("no data accepted at offset %d; the data is shorter than the upload", offset))
+		}
+
+		failures = 0
+		upload.UploadedSize = offset
+		if offset == upload.TotalSize {
+			upload.Status = ResumableUploadCompleted
+			upload.ETag = etag
+		}
+		if opts.ProgressCallback != nil {
+			opts.ProgressCallback(offset)
+		}
+	}
+
+	return upload, nil
+}
+
+// patchResumableUpload sends one chunk at offset and returns the offset the
+// server acknowledged, and the object's ETag once the upload completes
+func (c *Client) patchResumableUpload(ctx context.Context, bucket, key, uploadID string, offset int64, chunk io.Reader, length int64) (int64, string, error) {
+	headers := map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.FormatInt(offset, 10),
+	}
+
+	resp, err := c.doRequest(ctx, "PATCH", resumablePath(bucket, key, uploadID), io.LimitReader(chunk, length), headers)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	acknowledged, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, "", errors.NewInternal("invalid Upload-Offset in response")
+	}
+
+	return acknowledged, strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+func resumablePath(bucket, key, uploadID string) string {
+	return fmt.Sprintf("/s3/%s/%s?resumable=%s", bucket, key, url.QueryEscape(uploadID))
+}
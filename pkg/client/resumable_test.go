@@ -0,0 +1,105 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/api/s3"
+	"github.com/candlekeep/zot-artifact-store/internal/models"
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/candlekeep/zot-artifact-store/test"
+	"github.com/gorilla/mux"
+)
+
+func TestResumableUpload(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+
+	t.Run("Failed chunks are resent from the acknowledged offset", func(t *testing.T) {
+		// Given: A server that fails every third chunk
+		var patches atomic.Int32
+		server, dataDir := newResumableServer(t, func(r *http.Request) bool {
+			return r.Method == "PATCH" && patches.Add(1)%3 == 0
+		})
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+		ctx := context.Background()
+
+		// When: Uploading in chunks of 16 bytes
+		upload, err := c.CreateResumableUpload(ctx, "images", "disk.img", int64(len(data)), nil)
+		test.AssertNoError(t, err, "create upload")
+		var progress []int64
+		completed, err := c.ResumeUpload(ctx, "images", "disk.img", upload.UploadID, bytes.NewReader(data), &client.ResumeOptions{
+			ChunkSize:        16,
+			RetryDelay:       time.Millisecond,
+			ProgressCallback: func(n int64) { progress = append(progress, n) },
+		})
+
+		// Then: The whole object is stored
+		test.AssertNoError(t, err, "resume upload")
+		test.AssertEqual(t, client.ResumableUploadCompleted, completed.Status, "upload completed")
+		test.AssertTrue(t, completed.ETag != "", "ETag returned")
+		test.AssertEqual(t, int64(len(data)), progress[len(progress)-1], "final progress")
+		stored, err := os.ReadFile(filepath.Join(dataDir, "images", "disk.img"))
+		test.AssertNoError(t, err, "read object")
+		test.AssertEqual(t, string(data), string(stored), "object content")
+	})
+
+	t.Run("Interrupted upload continues in a later call", func(t *testing.T) {
+		// Given: A server that fails the two chunks after the first
+		var patches atomic.Int32
+		server, dataDir := newResumableServer(t, func(r *http.Request) bool {
+			if r.Method != "PATCH" {
+				return false
+			}
+			n := patches.Add(1)
+			return n == 2 || n == 3
+		})
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+		ctx := context.Background()
+		opts := &client.ResumeOptions{ChunkSize: 40, MaxRetries: 1, RetryDelay: time.Millisecond}
+
+		upload, err := c.CreateResumableUpload(ctx, "images", "disk.img", int64(len(data)), nil)
+		test.AssertNoError(t, err, "create upload")
+		_, err = c.ResumeUpload(ctx, "images", "disk.img", upload.UploadID, bytes.NewReader(data), opts)
+		test.AssertError(t, err, "upload giving up after a retry")
+
+		// When: The upload is resumed
+		status, err := c.GetResumableUpload(ctx, "images", "disk.img", upload.UploadID)
+		test.AssertNoError(t, err, "get upload")
+		_, err = c.ResumeUpload(ctx, "images", "disk.img", upload.UploadID, bytes.NewReader(data), opts)
+
+		// Then: Only the remaining data is sent and the object is stored
+		test.AssertNoError(t, err, "resume upload")
+		test.AssertEqual(t, int64(40), status.UploadedSize, "acknowledged before resuming")
+		stored, err := os.ReadFile(filepath.Join(dataDir, "images", "disk.img"))
+		test.AssertNoError(t, err, "read object")
+		test.AssertEqual(t, string(data), string(stored), "object content")
+	})
+}
+
+// newResumableServer serves the S3 API, answering 503 to requests fail selects
+func newResumableServer(t *testing.T, fail func(r *http.Request) bool) (*httptest.Server, string) {
+	t.Helper()
+	store := test.NewTestMetadataStore(t)
+	test.AssertNoError(t, store.CreateBucket(&models.Bucket{Name: "images"}), "create bucket")
+
+	dataDir := t.TempDir()
+	router := mux.NewRouter()
+	s3.NewHandler(store, dataDir, test.NewTestLogger(t)).RegisterRoutes(router)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail(r) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, dataDir
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/candlekeep/zot-artifact-store/internal/errors"
+)
+
+// SearchOptions contains options for searching artifacts
+type SearchOptions struct {
+	// Limit is the maximum number of results (the server defaults to 100)
+	Limit int
+
+	// Cursor continues a search after the page that returned it
+	Cursor string
+}
+
+// SearchHit is an artifact matching a search
+type SearchHit struct {
+	Bucket      string            `json:"bucket"`
+	Key         string            `json:"key"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	ContentType string            `json:"contentType"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Signed      bool              `json:"signed"`
+	HasSBOM     bool              `json:"hasSbom"`
+	Attested    bool              `json:"attested"`
+}
+
+// SearchResult is one page of search results
+type SearchResult struct {
+	Results    []SearchHit `json:"results"`
+	Count      int         `json:"count"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// Search finds artifacts matching a query such as
+// "bucket=releases size>100MB meta.git-sha=abc123 signed:true"
+func (c *Client) Search(ctx context.Context, query string, opts *SearchOptions) (*SearchResult, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		params.Set("cursor", opts.Cursor)
+	}
+
+	resp, err := c.doRequest(ctx, "GET", "/search?"+params.Encode(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result SearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewInternal("failed to parse response: " + err.Error())
+	}
+
+	return &result, nil
+}
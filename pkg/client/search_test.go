@@ -0,0 +1,72 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/candlekeep/zot-artifact-store/pkg/client"
+	"github.com/candlekeep/zot-artifact-store/test"
+)
+
+func TestSearch(t *testing.T) {
+	t.Run("Search artifacts", func(t *testing.T) {
+		// Given: Test server returning one page of results
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "GET", r.Method, "HTTP method")
+			test.AssertEqual(t, "/search", r.URL.Path, "request path")
+			test.AssertEqual(t, "bucket=releases size>100MB", r.URL.Query().Get("q"), "query")
+			test.AssertEqual(t, "10", r.URL.Query().Get("limit"), "limit")
+			test.AssertEqual(t, "page-1", r.URL.Query().Get("cursor"), "cursor")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"results": [
+					{
+						"bucket": "releases",
+						"key": "app.tar.gz",
+						"size": 209715200,
+						"tags": {"env": "prod"},
+						"signed": true,
+						"hasSbom": false,
+						"attested": true
+					}
+				],
+				"count": 1,
+				"nextCursor": "page-2"
+			}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Searching
+		ctx := context.Background()
+		result, err := c.Search(ctx, "bucket=releases size>100MB", &client.SearchOptions{Limit: 10, Cursor: "page-1"})
+
+		// Then: Results are returned with their supply-chain status
+		test.AssertNoError(t, err, "search")
+		test.AssertEqual(t, 1, result.Count, "result count")
+		test.AssertEqual(t, "app.tar.gz", result.Results[0].Key, "key")
+		test.AssertEqual(t, "prod", result.Results[0].Tags["env"], "tag")
+		test.AssertTrue(t, result.Results[0].Signed, "signed")
+		test.AssertTrue(t, !result.Results[0].HasSBOM, "no SBOM")
+		test.AssertEqual(t, "page-2", result.NextCursor, "next cursor")
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		// Given: Test server rejecting the query
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid search term", http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Searching
+		_, err := c.Search(context.Background(), "size>huge", nil)
+
+		// Then: The error is returned
+		test.AssertError(t, err, "invalid query")
+	})
+}
@@ -235,3 +235,22 @@ func (c *Client) GetAttestations(ctx context.Context, bucket, key string) ([]Att
 
 	return result.Attestations, nil
 }
+
+// ImportSupplyChain imports signatures, an SBOM and attestations for an artifact.
+// document is a JSON object with optional signatures, sbom and attestations fields
+// as returned by the server; record IDs are preserved.
+func (c *Client) ImportSupplyChain(ctx context.Context, bucket, key string, document []byte) error {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	urlPath := fmt.Sprintf("/supplychain/import/%s/%s", bucket, key)
+
+	resp, err := c.doRequest(ctx, "POST", urlPath, bytes.NewReader(document), headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
@@ -262,4 +262,29 @@ func TestSupplyChainOperations(t *testing.T) {
 		test.AssertEqual(t, "att-2", atts[1].ID, "second attestation ID")
 		test.AssertEqual(t, "test", atts[1].Type, "second attestation type")
 	})
+
+	t.Run("Import supply chain records", func(t *testing.T) {
+		// Given: Test server accepting an import
+		var receivedBody []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			test.AssertEqual(t, "POST", r.Method, "HTTP method")
+			test.AssertEqual(t, "/supplychain/import/test-bucket/test-key", r.URL.Path, "request path")
+			test.AssertEqual(t, "application/json", r.Header.Get("Content-Type"), "content type")
+			receivedBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"artifactId": "test-bucket/test-key", "signatures": 1}`))
+		}))
+		defer server.Close()
+
+		c, _ := client.NewClient(&client.Config{BaseURL: server.URL})
+
+		// When: Importing records
+		ctx := context.Background()
+		document := []byte(`{"signatures": [{"id": "sig-1"}]}`)
+		err := c.ImportSupplyChain(ctx, "test-bucket", "test-key", document)
+
+		// Then: The document is sent unchanged
+		test.AssertNoError(t, err, "import supply chain")
+		test.AssertEqual(t, string(document), string(receivedBody), "request body")
+	})
 }
@@ -26,7 +26,7 @@ func setupBenchServer(b *testing.B) (*httptest.Server, string, func()) {
 	}
 
 	logger := log.NewLogger("error", "")
-	metadataStore, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	metadataStore, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -338,7 +338,7 @@ func BenchmarkMemoryUsage(b *testing.B) {
 	defer os.RemoveAll(tmpDir)
 
 	logger := log.NewLogger("error", "")
-	metadataStore, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	metadataStore, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -24,7 +24,7 @@ func BenchmarkMetadataStore(b *testing.B) {
 	defer os.RemoveAll(tmpDir)
 
 	logger := log.NewLogger("error", "")
-	store, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	store, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -196,7 +196,7 @@ func BenchmarkConcurrentOperations(b *testing.B) {
 	defer os.RemoveAll(tmpDir)
 
 	logger := log.NewLogger("error", "")
-	metaStore, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	metaStore, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -252,7 +252,7 @@ func BenchmarkMultipartUpload(b *testing.B) {
 	defer os.RemoveAll(tmpDir)
 
 	logger := log.NewLogger("error", "")
-	metaStore, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	metaStore, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -185,7 +185,7 @@ func setupTestServer(t *testing.T, tmpDir string) (*httptest.Server, string) {
 	logger := log.NewLogger("error", "")
 
 	// Create metadata store
-	metadataStore, err := storage.NewMetadataStore(tmpDir + "/metadata.db")
+	metadataStore, err := storage.NewBoltMetadataStore(tmpDir + "/metadata.db")
 	if err != nil {
 		t.Fatalf("failed to create metadata store: %v", err)
 	}
@@ -1,8 +1,10 @@
 package test
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/candlekeep/zot-artifact-store/internal/storage"
 	"zotregistry.io/zot/pkg/log"
 )
 
@@ -11,6 +13,16 @@ func NewTestLogger(t *testing.T) log.Logger {
 	return log.NewLogger("debug", "")
 }
 
+// NewTestMetadataStore opens a Bolt metadata store in a temporary directory
+// that is closed when the test ends
+func NewTestMetadataStore(t *testing.T) storage.MetadataStore {
+	t.Helper()
+	store, err := storage.NewBoltMetadataStore(filepath.Join(t.TempDir(), "metadata.db"))
+	AssertNoError(t, err, "open metadata store")
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
 // AssertNoError fails the test if err is not nil
 func AssertNoError(t *testing.T, err error, msg string) {
 	t.Helper()